@@ -0,0 +1,193 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/internal/ethereum"
+	"github.com/spf13/viper"
+)
+
+// reloadedConfig is the config file read again on a reload, into its own viper instance. The global
+// configuration is read by the running components, so is left untouched until the new settings are
+// validated - and then only the tunable settings are applied from here.
+type reloadedConfig struct {
+	v *viper.Viper
+}
+
+// readReloadedConfig reads the config file from the same locations, and with the same environment
+// variable overrides, as config.ReadConfig does at startup
+func readReloadedConfig(cfgSuffix, cfgFile string) (*reloadedConfig, error) {
+	v := viper.New()
+	v.SetDefault(string(config.LogLevel), "info")
+	v.SetEnvPrefix("firefly")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	v.SetConfigType("yaml")
+	v.SetConfigName(fmt.Sprintf("firefly.%s", cfgSuffix))
+	v.AddConfigPath("/etc/firefly/")
+	v.AddConfigPath("$HOME/.firefly")
+	v.AddConfigPath(".")
+	if cfgFile != "" {
+		v.SetConfigFile(cfgFile)
+	}
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return &reloadedConfig{v: v}, nil
+}
+
+func (rc *reloadedConfig) logLevel() string {
+	return rc.v.GetString(string(config.LogLevel))
+}
+
+// connectorSection returns the connector section of the reloaded file, with the same defaults as
+// the connector section registered at startup
+func (rc *reloadedConfig) connectorSection() config.Section {
+	conf := &reloadedSection{v: rc.v, prefix: "connector"}
+	ethereum.InitConfig(conf)
+	return conf
+}
+
+// reloadedSection implements config.Section over the viper instance of a reloadedConfig, with the
+// same semantics for each type as the sections of the global configuration
+type reloadedSection struct {
+	v      *viper.Viper
+	prefix string
+}
+
+func (s *reloadedSection) key(k string) string {
+	return s.prefix + "." + k
+}
+
+func (s *reloadedSection) AddKnownKey(k string, defValue ...interface{}) {
+	if len(defValue) == 1 {
+		s.v.SetDefault(s.key(k), defValue[0])
+	} else if len(defValue) > 1 {
+		s.v.SetDefault(s.key(k), defValue)
+	}
+}
+
+func (s *reloadedSection) SetDefault(k string, defValue interface{}) {
+	s.v.SetDefault(s.key(k), defValue)
+}
+
+func (s *reloadedSection) SubSection(name string) config.Section {
+	return &reloadedSection{v: s.v, prefix: s.key(name)}
+}
+
+func (s *reloadedSection) SubArray(name string) config.ArraySection {
+	return &reloadedArray{v: s.v, base: s.key(name)}
+}
+
+func (s *reloadedSection) Set(k string, value interface{}) {
+	s.v.Set(s.key(k), value)
+}
+
+func (s *reloadedSection) IsSet(k string) bool {
+	return s.v.IsSet(s.key(k))
+}
+
+func (s *reloadedSection) Resolve(k string) string {
+	return s.key(k)
+}
+
+func (s *reloadedSection) GetString(k string) string {
+	return s.v.GetString(s.key(k))
+}
+
+func (s *reloadedSection) GetBool(k string) bool {
+	return s.v.GetBool(s.key(k))
+}
+
+func (s *reloadedSection) GetInt(k string) int {
+	return s.v.GetInt(s.key(k))
+}
+
+func (s *reloadedSection) GetInt64(k string) int64 {
+	return s.v.GetInt64(s.key(k))
+}
+
+func (s *reloadedSection) GetFloat64(k string) float64 {
+	return s.v.GetFloat64(s.key(k))
+}
+
+func (s *reloadedSection) GetByteSize(k string) int64 {
+	return fftypes.ParseToByteSize(s.v.GetString(s.key(k)))
+}
+
+func (s *reloadedSection) GetUint(k string) uint {
+	return s.v.GetUint(s.key(k))
+}
+
+func (s *reloadedSection) GetUint64(k string) uint64 {
+	return s.v.GetUint64(s.key(k))
+}
+
+func (s *reloadedSection) GetDuration(k string) time.Duration {
+	return fftypes.ParseToDuration(s.v.GetString(s.key(k)))
+}
+
+func (s *reloadedSection) GetStringSlice(k string) []string {
+	return s.v.GetStringSlice(s.key(k))
+}
+
+func (s *reloadedSection) GetObject(k string) fftypes.JSONObject {
+	return fftypes.JSONObject(s.v.GetStringMap(s.key(k)))
+}
+
+func (s *reloadedSection) GetObjectArray(k string) fftypes.JSONObjectArray {
+	v, _ := fftypes.ToJSONObjectArray(s.v.Get(s.key(k)))
+	return v
+}
+
+func (s *reloadedSection) Get(k string) interface{} {
+	return s.v.Get(s.key(k))
+}
+
+// reloadedArray implements config.ArraySection for the arrays registered by the connector. None of them
+// are tunable, so the defaults of the entries are not needed.
+type reloadedArray struct {
+	v    *viper.Viper
+	base string
+}
+
+func (a *reloadedArray) AddKnownKey(string, ...interface{}) {}
+
+func (a *reloadedArray) SetDefault(string, interface{}) {}
+
+func (a *reloadedArray) ArraySize() int {
+	v, _ := fftypes.ToJSONObjectArray(a.v.Get(a.base))
+	return len(v)
+}
+
+func (a *reloadedArray) ArrayEntry(i int) config.Section {
+	return &reloadedSection{v: a.v, prefix: fmt.Sprintf("%s.%d", a.base, i)}
+}
+
+func (a *reloadedArray) SubSection(name string) config.Section {
+	return &reloadedSection{v: a.v, prefix: a.base + "[]." + name}
+}
+
+func (a *reloadedArray) SubArray(name string) config.ArraySection {
+	return &reloadedArray{v: a.v, base: a.base + "[]." + name}
+}
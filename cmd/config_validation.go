@@ -0,0 +1,120 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/ethereum"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+)
+
+// The keys are registered by the transaction manager
+const (
+	confirmationsRequired  = config.RootKey("confirmations.required")
+	resubmitInterval       = config.RootKey("transactions.handler.simple.resubmitInterval")
+	persistenceType        = config.RootKey("persistence.type")
+	persistencePostgresURL = config.RootKey("persistence.postgres.url")
+)
+
+// legacyConfigKeys are sections no longer registered by the transaction manager, which existing
+// deployments still contain. They are ignored with a warning, rather than failing startup.
+var legacyConfigKeys = map[string]bool{
+	"ffcore": true, // the transaction manager no longer connects to FireFly core
+}
+
+// validateConfig checks the configuration as a whole, once it is read. Keys that are not known to any
+// component (typically typos) would otherwise be silently ignored. Settings that are related across
+// the connector and the transaction manager are cross-checked. Each component validates its own section.
+func validateConfig(ctx context.Context) error {
+	known := make(map[string]bool)
+	for _, key := range config.GetKnownKeys() {
+		known[strings.ToLower(key)] = true // keys are case insensitive
+	}
+	unknown := []string{}
+	for key, value := range config.GetConfig() {
+		if legacyConfigKeys[key] {
+			log.L(ctx).Warnf("Ignoring configuration '%s', which is no longer used", key)
+			continue
+		}
+		unknown = findUnknownConfigKeys(key, value, known, unknown)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return i18n.NewError(ctx, msgs.MsgUnknownConfigKeys, strings.Join(unknown, ", "))
+	}
+
+	// A chain profile sets a checkpoint block gap suited to the finality of the chain. Deployments that
+	// worked before this was checked need more confirmations than the default gap, so this only warns.
+	if connectorConfig.GetString(ethereum.ChainProfile) == "" {
+		required := config.GetInt64(confirmationsRequired)
+		checkpointBlockGap := connectorConfig.GetInt64(ethereum.EventsCheckpointBlockGap)
+		if required > checkpointBlockGap {
+			log.L(ctx).Warn(i18n.NewError(ctx, msgs.MsgConfirmationsBeyondHead, confirmationsRequired, required, checkpointBlockGap, "connector."+ethereum.EventsCheckpointBlockGap))
+		}
+	}
+
+	// The transaction handler re-sends the identical request for a stuck transaction, which must reach the node
+	if window := connectorConfig.GetDuration(ethereum.SubmissionIdempotencyWindow); window > 0 {
+		if interval := config.GetDuration(resubmitInterval); window >= interval {
+			return i18n.NewError(ctx, msgs.MsgIdempotencyWindowTooLong, "connector."+ethereum.SubmissionIdempotencyWindow, window, resubmitInterval, interval)
+		}
+	}
+
+	// The lease is held in the database FFTM persists to, so that an elected standby can read the checkpoints
+	// FFTM persisted. Other persistence never has them, so the event streams would not dispatch once elected.
+	if leaseURL := connectorConfig.GetString(ethereum.LeaderElectionURL); leaseURL != "" {
+		if config.GetString(persistenceType) != "postgres" || config.GetString(persistencePostgresURL) != leaseURL {
+			return i18n.NewError(ctx, msgs.MsgLeaderElectionPersistence, "connector."+ethereum.LeaderElectionURL, persistenceType, persistencePostgresURL)
+		}
+	}
+	return nil
+}
+
+// findUnknownConfigKeys walks a value in the configuration, stopping at any known key as some are objects.
+// The entries of arrays are checked against the keys of the array, which are known in the form "parent[].child".
+func findUnknownConfigKeys(key string, value interface{}, known map[string]bool, unknown []string) []string {
+	if known[key] {
+		return unknown
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for child, childValue := range v {
+			unknown = findUnknownConfigKeys(key+"."+strings.ToLower(child), childValue, known, unknown)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			unknown = append(unknown, key)
+		}
+		for _, entry := range v {
+			if _, isMap := entry.(map[string]interface{}); isMap {
+				unknown = findUnknownConfigKeys(key+"[]", entry, known, unknown)
+			} else {
+				unknown = append(unknown, key)
+				break
+			}
+		}
+	default:
+		unknown = append(unknown, key)
+	}
+	return unknown
+}
@@ -0,0 +1,151 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func readTestConfig(t *testing.T, yaml string) {
+	InitConfig()
+	cfgFile := path.Join(t.TempDir(), "firefly.evmconnect.yaml")
+	err := os.WriteFile(cfgFile, []byte(yaml), 0644)
+	assert.NoError(t, err)
+	err = config.ReadConfig("evmconnect", cfgFile)
+	assert.NoError(t, err)
+}
+
+func TestValidateConfigOK(t *testing.T) {
+
+	readTestConfig(t, `
+connector:
+  url: http://localhost:8545
+  headers:
+    x-custom: value
+  signers:
+  - url: http://localhost:8547
+    addresses:
+    - "0x6dd6e6c0b4d2b2d7fd9a2a6d2b2d9c3e0b8b1b4c"
+log:
+  level: debug
+`)
+	assert.NoError(t, validateConfig(context.Background()))
+
+}
+
+func TestValidateConfigLegacyKeys(t *testing.T) {
+
+	readTestConfig(t, `
+connector:
+  url: http://localhost:8545
+ffcore:
+  url: http://127.0.0.1:5101
+  namespaces:
+  - default
+`)
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	assert.NoError(t, validateConfig(context.Background()))
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	assert.Equal(t, "Ignoring configuration 'ffcore', which is no longer used", hook.LastEntry().Message)
+
+}
+
+func TestValidateConfigUnknownKeys(t *testing.T) {
+
+	readTestConfig(t, `
+connector:
+  url: http://localhost:8545
+  events:
+    catchupPagesize: 100
+    catchupPageSise: 100
+  signers:
+  - url: http://localhost:8547
+    adresses: []
+  typo:
+  - a
+`)
+	err := validateConfig(context.Background())
+	assert.Regexp(t, "FF23186.*connector.events.catchuppagesise, connector.signers\\[\\].adresses, connector.typo$", err)
+
+}
+
+func TestValidateConfigConfirmationsBeyondHead(t *testing.T) {
+
+	readTestConfig(t, `
+connector:
+  url: http://localhost:8545
+  events:
+    checkpointBlockGap: 10
+confirmations:
+  required: 11
+`)
+	// Only warns, as existing deployments need more confirmations than the default gap
+	assert.NoError(t, validateConfig(context.Background()))
+
+	// Chain profiles set the gap for the finality of the chain
+	connectorConfig.Set("chainProfile", "avalanche")
+	assert.NoError(t, validateConfig(context.Background()))
+
+}
+
+func TestValidateConfigIdempotencyWindowBeyondResubmit(t *testing.T) {
+
+	readTestConfig(t, `
+connector:
+  url: http://localhost:8545
+  submission:
+    idempotency:
+      window: 5m
+`)
+	err := validateConfig(context.Background())
+	assert.Regexp(t, "FF23197.*connector.submission.idempotency.window.*5m0s.*transactions.handler.simple.resubmitInterval.*5m0s", err)
+
+	connectorConfig.Set("submission.idempotency.window", "1m")
+	assert.NoError(t, validateConfig(context.Background()))
+
+}
+
+func TestValidateConfigLeaderElectionPersistence(t *testing.T) {
+
+	readTestConfig(t, `
+connector:
+  url: http://localhost:8545
+  leaderElection:
+    url: postgres://localhost:5432/fftm
+persistence:
+  type: leveldb
+`)
+	err := validateConfig(context.Background())
+	assert.Regexp(t, "FF23199.*connector.leaderElection.url.*persistence.type.*persistence.postgres.url", err)
+
+	config.Set(persistenceType, "postgres")
+	config.Set(persistencePostgresURL, "postgres://localhost:5432/other")
+	assert.Regexp(t, "FF23199", validateConfig(context.Background()))
+
+	config.Set(persistencePostgresURL, "postgres://localhost:5432/fftm")
+	assert.NoError(t, validateConfig(context.Background()))
+
+}
@@ -26,8 +26,13 @@ import (
 	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/ethconnect"
 	"github.com/hyperledger/firefly-evmconnect/internal/ethereum"
+	"github.com/hyperledger/firefly-evmconnect/internal/extensions"
+	"github.com/hyperledger/firefly-evmconnect/internal/loadtest"
+	"github.com/hyperledger/firefly-evmconnect/pkg/evmconnect"
 	fftmcmd "github.com/hyperledger/firefly-transaction-manager/cmd"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/fftm"
 	txhandlerfactory "github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/registry"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/txhandler/simple"
@@ -36,6 +41,7 @@ import (
 )
 
 var sigs = make(chan os.Signal, 1)
+var reloadSigs = make(chan os.Signal, 1)
 
 var rootCmd = &cobra.Command{
 	Use:   "evmconnect",
@@ -50,10 +56,16 @@ var cfgFile string
 
 var connectorConfig config.Section
 
+var ethconnectConfig config.Section
+
+var extensionsConfig config.Section
+
 func init() {
 	rootCmd.Flags().StringVarP(&cfgFile, "config", "f", "", "config file")
 	rootCmd.AddCommand(versionCommand())
 	rootCmd.AddCommand(configCommand())
+	rootCmd.AddCommand(simulatorCommand())
+	rootCmd.AddCommand(loadTestCommand())
 	rootCmd.AddCommand(fftmcmd.ClientCommand())
 	migrateCmd := fftmcmd.MigrateCommand(func() error {
 		InitConfig()
@@ -73,6 +85,12 @@ func InitConfig() {
 	fftm.InitConfig()
 	connectorConfig = config.RootSection("connector")
 	ethereum.InitConfig(connectorConfig)
+	loadTestConfig = config.RootSection("loadtest")
+	loadtest.InitConfig(loadTestConfig)
+	ethconnectConfig = config.RootSection("ethconnect")
+	ethconnect.InitConfig(ethconnectConfig)
+	extensionsConfig = config.RootSection("extensions")
+	extensions.InitConfig(extensionsConfig)
 	txhandlerfactory.RegisterHandler(&simple.TransactionHandlerFactory{})
 }
 
@@ -93,12 +111,40 @@ func run() error {
 		cancelCtx()
 		return i18n.WrapError(ctx, err, i18n.MsgConfigFailed)
 	}
+	if err := validateConfig(ctx); err != nil {
+		return err
+	}
 
 	// Init connector
-	c, err := ethereum.NewEthereumConnector(ctx, connectorConfig)
+	c, err := evmconnect.NewConnector(ctx, evmconnect.WithConfigSection(connectorConfig))
 	if err != nil {
 		return err
 	}
+	// Optionally serve the legacy ethconnect REST APIs, alongside the APIs of the transaction manager
+	if ethconnectConfig.GetBool(ethconnect.Enabled) {
+		f, err := ethconnect.NewFacade(ctx, c, ethconnectConfig)
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := f.Run(ctx); err != nil {
+				log.L(ctx).Errorf("ethconnect API server failed: %s", err)
+			}
+		}()
+	}
+	// Optionally serve the operations of the connector that are not part of the FFCAPI
+	if ext, ok := c.(ethereum.Extensions); ok && extensionsConfig.GetBool(extensions.Enabled) {
+		reload := func(ctx context.Context) error { return reloadConfig(ctx, c) }
+		s, err := extensions.NewServer(ctx, ext, reload, extensionsConfig)
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := s.Run(ctx); err != nil {
+				log.L(ctx).Errorf("Extensions API server failed: %s", err)
+			}
+		}()
+	}
 	m, err := fftm.NewManager(ctx, c)
 	if err != nil {
 		return err
@@ -109,12 +155,56 @@ func run() error {
 	go func() {
 		sig := <-sigs
 		log.L(ctx).Infof("Shutting down due to %s", sig.String())
+		drainConnector(ctx, c)
 		cancelCtx()
 	}()
+	signal.Notify(reloadSigs, syscall.SIGHUP)
+	go reloadOnSignal(ctx, c)
 
 	return runManager(ctx, m)
 }
 
+// reloadOnSignal re-reads the config file on SIGHUP, and applies the log level and the tunable
+// settings of the connector - without a restart that would interrupt event streams and WebSockets
+func reloadOnSignal(ctx context.Context, c ffcapi.API) {
+	for {
+		select {
+		case <-reloadSigs:
+			if err := reloadConfig(ctx, c); err != nil {
+				log.L(ctx).Errorf("Failed to reload configuration: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func reloadConfig(ctx context.Context, c ffcapi.API) error {
+	log.L(ctx).Infof("Reloading configuration")
+	rc, err := readReloadedConfig("evmconnect", cfgFile)
+	if err != nil {
+		return err
+	}
+	// The connector validates all of its tunable settings, before it applies any of them
+	if reloader, ok := c.(ethereum.ConfigReloader); ok {
+		if err := reloader.ReloadConfig(ctx, rc.connectorSection()); err != nil {
+			return err
+		}
+	}
+	log.SetLevel(rc.logLevel())
+	return nil
+}
+
+// drainConnector lets the connector complete its in-flight work, up to its configured deadline, before
+// the context is cancelled to stop everything
+func drainConnector(ctx context.Context, c ffcapi.API) {
+	if shutdown, ok := c.(ethereum.GracefulShutdown); ok {
+		if err := shutdown.Shutdown(ctx); err != nil {
+			log.L(ctx).Errorf("Graceful shutdown failed: %s", err)
+		}
+	}
+}
+
 func runManager(ctx context.Context, m fftm.Manager) error {
 	err := m.Start()
 	if err != nil {
@@ -20,10 +20,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-evmconnect/internal/ethereum"
 	"github.com/hyperledger/firefly-evmconnect/mocks/fftmmocks"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -50,6 +55,53 @@ func TestRunOK(t *testing.T) {
 
 }
 
+func TestReloadConfig(t *testing.T) {
+
+	InitConfig()
+	cfgFile = "../test/firefly.evmconnect.yaml"
+	defer func() { cfgFile = "" }()
+	err := config.ReadConfig("evmconnect", cfgFile)
+	assert.NoError(t, err)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	c, err := ethereum.NewEthereumConnector(ctx, connectorConfig)
+	assert.NoError(t, err)
+
+	err = reloadConfig(ctx, c)
+	assert.NoError(t, err)
+
+	// The changed file is applied to the connector and logging, but not read into the global config
+	defer logrus.SetLevel(logrus.GetLevel())
+	cfgFile = path.Join(t.TempDir(), "firefly.evmconnect.yaml")
+	err = os.WriteFile(cfgFile, []byte("log:\n  level: trace\nconnector:\n  url: http://localhost:8545\n  retry:\n    factor: 3.5\n"), 0644)
+	assert.NoError(t, err)
+	err = reloadConfig(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, logrus.TraceLevel, logrus.GetLevel())
+	assert.Equal(t, 2.0, connectorConfig.GetFloat64(ethereum.RetryFactor))
+
+	// Invalid tunables are rejected by the connector
+	cfgFile = path.Join(t.TempDir(), "firefly.evmconnect.yaml")
+	err = os.WriteFile(cfgFile, []byte("connector:\n  url: http://localhost:8545\n  rawTransactions:\n    maxFeePerGas: wrong\n"), 0644)
+	assert.NoError(t, err)
+	err = reloadConfig(ctx, c)
+	assert.Regexp(t, "FF23074", err)
+
+	cfgFile = "../test/bad-config.evmconnect.yaml"
+	err = reloadConfig(ctx, c)
+	assert.Regexp(t, "While parsing config", err)
+
+	// Errors from a signal are logged
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reloadOnSignal(ctx, c)
+	}()
+	reloadSigs <- syscall.SIGHUP
+	cancelCtx()
+	<-done
+
+}
+
 func TestRunBadConfig(t *testing.T) {
 
 	rootCmd.SetArgs([]string{"-f", "../test/bad-config.evmconnect.yaml"})
@@ -78,6 +130,24 @@ func TestRunBadServerConfig(t *testing.T) {
 
 }
 
+func TestRunBadEthConnectConfig(t *testing.T) {
+	rootCmd.SetArgs([]string{"-f", "../test/bad-ethconnect.evmconnect.yaml"})
+	defer rootCmd.SetArgs([]string{})
+
+	err := Execute()
+	assert.Regexp(t, "FF00151", err)
+
+}
+
+func TestRunBadExtensionsConfig(t *testing.T) {
+	rootCmd.SetArgs([]string{"-f", "../test/bad-extensions.evmconnect.yaml"})
+	defer rootCmd.SetArgs([]string{})
+
+	err := Execute()
+	assert.Regexp(t, "FF00151", err)
+
+}
+
 func TestRunBadConfirmationsConfig(t *testing.T) {
 	rootCmd.SetArgs([]string{"-f", "../test/fail-start.evmconnect.yaml"})
 	defer rootCmd.SetArgs([]string{})
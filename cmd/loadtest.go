@@ -0,0 +1,84 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"os/signal"
+	"syscall"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/ethereum"
+	"github.com/hyperledger/firefly-evmconnect/internal/loadtest"
+	"github.com/spf13/cobra"
+)
+
+var loadTestConfig config.Section
+
+func loadTestCommand() *cobra.Command {
+	loadTestCmd := &cobra.Command{
+		Use:   "loadtest",
+		Short: "Submits no-op transactions at a configured rate through the connector, and reports the latency",
+		Long:  "",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLoadTest(cmd.OutOrStdout())
+		},
+	}
+	loadTestCmd.Flags().StringVarP(&cfgFile, "config", "f", "", "config file")
+	return loadTestCmd
+}
+
+func runLoadTest(out io.Writer) error {
+	err := config.ReadConfig("evmconnect", cfgFile)
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+	config.SetupLogging(ctx)
+	if err != nil {
+		return i18n.WrapError(ctx, err, i18n.MsgConfigFailed)
+	}
+
+	cfg, err := loadtest.ReadConfig(ctx, loadTestConfig)
+	if err != nil {
+		return err
+	}
+	c, err := ethereum.NewEthereumConnector(ctx, connectorConfig)
+	if err != nil {
+		return err
+	}
+
+	// Stopping early still waits for the transactions in flight, and outputs the report
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigs:
+			log.L(ctx).Infof("Stopping load test due to %s", sig.String())
+			cancelCtx()
+		case <-ctx.Done():
+		}
+	}()
+
+	report, err := loadtest.Run(ctx, c, cfg)
+	if err != nil {
+		return err
+	}
+	report.Write(out)
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLoadTestConfig(t *testing.T, url, loadTestConf string) string {
+	cfgFile := filepath.Join(t.TempDir(), "firefly.evmconnect.yaml")
+	err := os.WriteFile(cfgFile, []byte(fmt.Sprintf("connector:\n  url: %s\nloadtest:\n%s", url, loadTestConf)), 0600)
+	assert.NoError(t, err)
+	return cfgFile
+}
+
+func TestRunLoadTestOK(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		result := `"0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc"`
+		if req.Method == "eth_getTransactionCount" {
+			result = `"0x0a"`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, result)
+	}))
+	defer server.Close()
+
+	cfgFile := writeLoadTestConfig(t, server.URL, `
+  enabled: true
+  from: "0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8"
+  rate: 100
+  duration: 1m
+  gas: 50000
+`)
+	out := new(bytes.Buffer)
+	rootCmd.SetOut(out)
+	rootCmd.SetArgs([]string{"loadtest", "-f", cfgFile})
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs([]string{})
+	}()
+
+	// Stopped early by a signal, which still outputs the report
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		sigs <- os.Kill
+	}()
+	err := Execute()
+	assert.NoError(t, err)
+	assert.Regexp(t, "Submitted: [1-9][0-9]*  Failed: 0", out.String())
+
+}
+
+func TestRunLoadTestNotEnabled(t *testing.T) {
+
+	rootCmd.SetArgs([]string{"loadtest", "-f", "../test/firefly.evmconnect.yaml"})
+	defer rootCmd.SetArgs([]string{})
+
+	err := Execute()
+	assert.Regexp(t, "FF23097", err)
+
+}
+
+func TestRunLoadTestBadConfig(t *testing.T) {
+
+	rootCmd.SetArgs([]string{"loadtest", "-f", "../test/bad-config.evmconnect.yaml"})
+	defer rootCmd.SetArgs([]string{})
+
+	err := Execute()
+	assert.Regexp(t, "FF00101", err)
+
+}
+
+func TestRunLoadTestBadConnectorConfig(t *testing.T) {
+
+	rootCmd.SetArgs([]string{"loadtest", "-f", writeLoadTestConfig(t, "", "  enabled: true\n  from: \"0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8\"\n")})
+	defer rootCmd.SetArgs([]string{})
+
+	err := Execute()
+	assert.Regexp(t, "FF23025", err)
+
+}
+
+func TestRunLoadTestNonceFail(t *testing.T) {
+
+	rootCmd.SetArgs([]string{"loadtest", "-f", writeLoadTestConfig(t, "http://localhost:0", "  enabled: true\n  from: \"0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8\"\n")})
+	defer rootCmd.SetArgs([]string{})
+
+	err := Execute()
+	assert.Error(t, err)
+
+}
@@ -0,0 +1,75 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"net"
+	"os/signal"
+	"syscall"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/simulator"
+	"github.com/spf13/cobra"
+)
+
+var simulatorListen string
+var simulatorScript string
+
+func simulatorCommand() *cobra.Command {
+	simulatorCmd := &cobra.Command{
+		Use:   "simulator",
+		Short: "Runs a deterministic simulated chain over JSON/RPC, with scripted re-orgs, dropped filters and latency",
+		Long:  "",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSimulator()
+		},
+	}
+	simulatorCmd.Flags().StringVarP(&simulatorListen, "listen", "l", "127.0.0.1:8545", "address to listen on for JSON/RPC")
+	simulatorCmd.Flags().StringVarP(&simulatorScript, "script", "s", "", "YAML or JSON script describing the simulated chain")
+	return simulatorCmd
+}
+
+func runSimulator() error {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	script := simulator.DefaultScript()
+	if simulatorScript != "" {
+		var err error
+		if script, err = simulator.LoadScript(ctx, simulatorScript); err != nil {
+			return err
+		}
+	}
+
+	l, err := net.Listen("tcp", simulatorListen)
+	if err != nil {
+		return err
+	}
+
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigs:
+			log.L(ctx).Infof("Shutting down simulator due to %s", sig.String())
+			cancelCtx()
+		case <-ctx.Done():
+		}
+	}()
+
+	return simulator.NewSimulator(ctx, script).Run(l)
+}
@@ -0,0 +1,64 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSimulatorOK(t *testing.T) {
+
+	rootCmd.SetArgs([]string{"simulator", "-l", "127.0.0.1:0", "-s", "../test/simulator/reorg.yaml"})
+	defer rootCmd.SetArgs([]string{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := Execute()
+		assert.NoError(t, err)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	sigs <- os.Kill
+
+	<-done
+
+}
+
+func TestRunSimulatorBadScript(t *testing.T) {
+
+	rootCmd.SetArgs([]string{"simulator", "-l", "127.0.0.1:0", "-s", "../test/simulator/missing.yaml"})
+	defer rootCmd.SetArgs([]string{})
+
+	err := Execute()
+	assert.Regexp(t, "FF23055", err)
+
+}
+
+func TestRunSimulatorBadListener(t *testing.T) {
+
+	rootCmd.SetArgs([]string{"simulator", "-l", "!!!badness", "-s", ""})
+	defer rootCmd.SetArgs([]string{})
+
+	err := Execute()
+	assert.Error(t, err)
+
+}
@@ -0,0 +1,441 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ethconnect provides an optional HTTP façade with the legacy ethconnect REST APIs for uploading ABIs,
+// deploying contracts, and invoking and querying them - including synchronous submission with ?fly-sync=true.
+// Requests are served directly with the FFCAPI operations of the connector, so applications written against
+// ethconnect can be migrated without rewriting their API calls. Transactions submitted through the façade are
+// not tracked by the transaction manager, so should use signing keys that are not also used via FireFly.
+package ethconnect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/httpserver"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const (
+	Enabled                = "enabled"
+	StoragePath            = "storagePath"
+	ReceiptTimeout         = "receiptTimeout"
+	ReceiptPollingInterval = "receiptPollingInterval"
+)
+
+const defaultPort = 5009
+
+// Connector is the subset of the FFCAPI used by the façade
+type Connector interface {
+	NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (*ffcapi.NextNonceForSignerResponse, ffcapi.ErrorReason, error)
+	GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (*ffcapi.GasPriceEstimateResponse, ffcapi.ErrorReason, error)
+	TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error)
+	DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error)
+	TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error)
+	TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (*ffcapi.TransactionReceiptResponse, ffcapi.ErrorReason, error)
+	QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (*ffcapi.QueryInvokeResponse, ffcapi.ErrorReason, error)
+}
+
+// EventSignatureRegistry is implemented by connectors that label events by their signature, so that the
+// events of the uploaded ABIs are labeled even when they are emitted by contracts not deployed via the façade
+type EventSignatureRegistry interface {
+	RegisterEventSignatures(ctx context.Context, source string, a abi.ABI)
+}
+
+func InitConfig(conf config.Section) {
+	conf.AddKnownKey(Enabled, false)
+	conf.AddKnownKey(StoragePath)
+	conf.AddKnownKey(ReceiptTimeout, "2m")
+	conf.AddKnownKey(ReceiptPollingInterval, "1s")
+	httpserver.InitHTTPConfig(conf, defaultPort)
+	httpserver.InitCORSConfig(conf.SubSection("cors"))
+}
+
+// Facade serves the ethconnect REST APIs
+type Facade struct {
+	ctx             context.Context
+	c               Connector
+	store           *store
+	server          httpserver.HTTPServer
+	onClose         chan error
+	receiptTimeout  time.Duration
+	pollingInterval time.Duration
+	mux             sync.Mutex
+	signerLocks     map[string]*sync.Mutex
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// statusError carries the HTTP status for an error returned from the connector, based on the reason
+type statusError struct {
+	status int
+	err    error
+}
+
+func (se *statusError) Error() string {
+	return se.err.Error()
+}
+
+// NewFacade loads the stored ABIs and contracts, and starts listening on the configured address.
+// The context is used for the background tracking of contracts deployed asynchronously.
+func NewFacade(ctx context.Context, c Connector, conf config.Section) (*Facade, error) {
+	f := &Facade{
+		ctx:             log.WithLogField(ctx, "role", "ethconnect"),
+		c:               c,
+		onClose:         make(chan error, 1),
+		receiptTimeout:  conf.GetDuration(ReceiptTimeout),
+		pollingInterval: conf.GetDuration(ReceiptPollingInterval),
+		signerLocks:     make(map[string]*sync.Mutex),
+	}
+	var err error
+	if f.store, err = newStore(ctx, conf.GetString(StoragePath)); err != nil {
+		return nil, err
+	}
+	// The list of ABIs does not include the full ABI, so the loaded ABIs are used directly before we start serving
+	for _, a := range f.store.abis {
+		f.registerEventSignatures(ctx, a)
+	}
+	f.server, err = httpserver.NewHTTPServer(f.ctx, "ethconnect", f.router(), f.onClose, conf, conf.SubSection("cors"), &httpserver.ServerOptions{
+		MaximumRequestTimeout: f.receiptTimeout, // synchronous requests wait for the receipt
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Facade) Addr() net.Addr {
+	return f.server.Addr()
+}
+
+// Run serves the APIs until the context is cancelled
+func (f *Facade) Run(ctx context.Context) error {
+	go f.server.ServeHTTP(ctx)
+	return <-f.onClose
+}
+
+func (f *Facade) router() *mux.Router {
+	r := mux.NewRouter()
+	f.route(r, http.MethodGet, "/abis", f.getABIs)
+	f.route(r, http.MethodPost, "/abis", f.postABI)
+	f.route(r, http.MethodGet, "/abis/{abi}", f.getABI)
+	f.route(r, http.MethodPost, "/abis/{abi}", f.postDeploy)
+	f.route(r, http.MethodGet, "/abis/{abi}/{address}/{method}", f.invokeABIMethod)
+	f.route(r, http.MethodPost, "/abis/{abi}/{address}/{method}", f.invokeABIMethod)
+	f.route(r, http.MethodGet, "/contracts", f.getContracts)
+	f.route(r, http.MethodGet, "/contracts/{address}", f.getContract)
+	f.route(r, http.MethodGet, "/contracts/{address}/{method}", f.invokeContractMethod)
+	f.route(r, http.MethodPost, "/contracts/{address}/{method}", f.invokeContractMethod)
+	f.route(r, http.MethodGet, "/replies/{id}", f.getReply)
+	return r
+}
+
+func (f *Facade) route(r *mux.Router, method, path string, handler func(req *http.Request) (int, interface{}, error)) {
+	r.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		status, body, err := handler(req)
+		if err != nil {
+			log.L(req.Context()).Errorf("%s %s failed: %s", req.Method, req.URL.Path, err)
+			status = http.StatusInternalServerError
+			switch e := err.(type) {
+			case *statusError:
+				status = e.status
+			case i18n.FFError:
+				status = e.HTTPStatus()
+			}
+			body = &errorResponse{Error: err.Error()}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	}).Methods(method)
+}
+
+// connectorError maps the reason returned with an error from the connector to an HTTP status
+func connectorError(reason ffcapi.ErrorReason, err error) error {
+	switch reason {
+	case ffcapi.ErrorReasonInvalidInputs:
+		return &statusError{status: http.StatusBadRequest, err: err}
+	case ffcapi.ErrorReasonNotFound:
+		return &statusError{status: http.StatusNotFound, err: err}
+	case ffcapi.ErrorReasonTransactionReverted:
+		return &statusError{status: http.StatusConflict, err: err}
+	default:
+		return err
+	}
+}
+
+// flyParam returns an ethconnect parameter from the fly-<name> query parameter, or the x-firefly-<name> header
+func flyParam(req *http.Request, name string) string {
+	if v := req.URL.Query().Get("fly-" + name); v != "" {
+		return v
+	}
+	return req.Header.Get("x-firefly-" + name)
+}
+
+func flyBoolParam(req *http.Request, name string) bool {
+	v := strings.ToLower(flyParam(req, name))
+	return v == "true" || v == "" && req.URL.Query().Has("fly-"+name)
+}
+
+func flyIntParam(req *http.Request, name string) (*fftypes.FFBigInt, error) {
+	v := flyParam(req, name)
+	if v == "" {
+		return nil, nil
+	}
+	i, ok := new(big.Int).SetString(v, 0)
+	if !ok {
+		return nil, i18n.NewError(req.Context(), msgs.MsgEthConnectBadRequest, fmt.Sprintf("fly-%s=%s", name, v))
+	}
+	return (*fftypes.FFBigInt)(i), nil
+}
+
+func (f *Facade) getABIs(_ *http.Request) (int, interface{}, error) {
+	return http.StatusOK, f.store.listABIs(), nil
+}
+
+func (f *Facade) getABI(req *http.Request) (int, interface{}, error) {
+	a, err := f.store.getABI(req.Context(), mux.Vars(req)["abi"])
+	if err != nil {
+		return -1, nil, err
+	}
+	return http.StatusOK, a, nil
+}
+
+// postABI stores a compiled ABI, with optional bytecode. Unlike ethconnect, Solidity source is not
+// compiled by the façade - the output of the compiler must be supplied.
+func (f *Facade) postABI(req *http.Request) (int, interface{}, error) {
+	var upload struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		ABI         abi.ABI         `json:"abi"`
+		Bytecode    fftypes.JSONAny `json:"bytecode"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&upload); err != nil {
+		return -1, nil, i18n.NewError(req.Context(), msgs.MsgEthConnectBadRequest, err)
+	}
+	if len(upload.ABI) == 0 {
+		return -1, nil, i18n.NewError(req.Context(), msgs.MsgEthConnectBadRequest, "abi")
+	}
+	id := fftypes.NewUUID().String()
+	a := &ABIInfo{
+		ID:          id,
+		Name:        upload.Name,
+		Description: upload.Description,
+		Created:     fftypes.Now(),
+		Path:        "/abis/" + id,
+		ABI:         upload.ABI,
+	}
+	if upload.Bytecode != "" {
+		if err := json.Unmarshal(upload.Bytecode.Bytes(), &a.Bytecode); err != nil {
+			return -1, nil, i18n.NewError(req.Context(), msgs.MsgEthConnectBadRequest, err)
+		}
+		a.Deployable = len(a.Bytecode) > 0
+	}
+	if err := f.store.addABI(req.Context(), a); err != nil {
+		return -1, nil, err
+	}
+	f.registerEventSignatures(req.Context(), a)
+	return http.StatusOK, a, nil
+}
+
+func (f *Facade) registerEventSignatures(ctx context.Context, a *ABIInfo) {
+	if r, ok := f.c.(EventSignatureRegistry); ok {
+		r.RegisterEventSignatures(ctx, a.Name, a.ABI)
+	}
+}
+
+func (f *Facade) getContracts(_ *http.Request) (int, interface{}, error) {
+	return http.StatusOK, f.store.listContracts(), nil
+}
+
+func (f *Facade) getContract(req *http.Request) (int, interface{}, error) {
+	c, err := f.store.getContract(req.Context(), strings.ToLower(mux.Vars(req)["address"]))
+	if err != nil {
+		return -1, nil, err
+	}
+	return http.StatusOK, c, nil
+}
+
+func (f *Facade) postDeploy(req *http.Request) (int, interface{}, error) {
+	ctx := req.Context()
+	a, err := f.store.getABI(ctx, mux.Vars(req)["abi"])
+	if err != nil {
+		return -1, nil, err
+	}
+	if !a.Deployable {
+		return -1, nil, i18n.NewError(ctx, msgs.MsgEthConnectNotDeployable, a.ID)
+	}
+	constructor := a.ABI.Constructor()
+	if constructor == nil {
+		constructor = &abi.Entry{Type: abi.Constructor}
+	}
+	params, err := inputParams(req, constructor)
+	if err != nil {
+		return -1, nil, err
+	}
+	definition, _ := json.Marshal(a.ABI)
+	bytecode, _ := json.Marshal(a.Bytecode)
+	return f.submit(req, func(headers ffcapi.TransactionHeaders) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+		return f.c.DeployContractPrepare(ctx, &ffcapi.ContractDeployPrepareRequest{
+			TransactionHeaders: headers,
+			Definition:         fftypes.JSONAnyPtrBytes(definition),
+			Contract:           fftypes.JSONAnyPtrBytes(bytecode),
+			Params:             params,
+			Errors:             errorEntries(a.ABI),
+		})
+	}, a.ID)
+}
+
+func (f *Facade) invokeABIMethod(req *http.Request) (int, interface{}, error) {
+	a, err := f.store.getABI(req.Context(), mux.Vars(req)["abi"])
+	if err != nil {
+		return -1, nil, err
+	}
+	return f.invoke(req, a)
+}
+
+func (f *Facade) invokeContractMethod(req *http.Request) (int, interface{}, error) {
+	c, err := f.store.getContract(req.Context(), strings.ToLower(mux.Vars(req)["address"]))
+	if err != nil {
+		return -1, nil, err
+	}
+	a, err := f.store.getABI(req.Context(), c.ABI)
+	if err != nil {
+		return -1, nil, err
+	}
+	return f.invoke(req, a)
+}
+
+// invoke queries the method for a GET, or with fly-call=true, and otherwise submits a transaction
+func (f *Facade) invoke(req *http.Request, a *ABIInfo) (int, interface{}, error) {
+	ctx := req.Context()
+	vars := mux.Vars(req)
+	method := a.ABI.Functions()[vars["method"]]
+	if method == nil {
+		return -1, nil, i18n.NewError(ctx, msgs.MsgEthConnectNoMethod, vars["method"])
+	}
+	params, err := inputParams(req, method)
+	if err != nil {
+		return -1, nil, err
+	}
+	methodJSON, _ := json.Marshal(method)
+	input := ffcapi.TransactionInput{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: flyParam(req, "from"),
+			To:   vars["address"],
+		},
+		Method: fftypes.JSONAnyPtrBytes(methodJSON),
+		Params: params,
+		Errors: errorEntries(a.ABI),
+	}
+
+	if req.Method == http.MethodGet || flyBoolParam(req, "call") {
+		res, reason, err := f.c.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{TransactionInput: input})
+		if err != nil {
+			return -1, nil, connectorError(reason, err)
+		}
+		return http.StatusOK, res.Outputs, nil
+	}
+	return f.submit(req, func(headers ffcapi.TransactionHeaders) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+		headers.To = input.To
+		input.TransactionHeaders = headers
+		return f.c.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{TransactionInput: input})
+	}, "")
+}
+
+// inputParams builds the ordered parameters for a method from the request. A POST supplies a JSON object with
+// the inputs by name (or an array in order), and a GET supplies each input as a query parameter.
+func inputParams(req *http.Request, method *abi.Entry) ([]*fftypes.JSONAny, error) {
+	ctx := req.Context()
+	named := make(map[string]*fftypes.JSONAny)
+	if req.Method == http.MethodGet {
+		for k, v := range req.URL.Query() {
+			named[k] = queryParamJSON(v[0])
+		}
+	} else {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, i18n.NewError(ctx, msgs.MsgEthConnectBadRequest, err)
+		}
+		body = []byte(strings.TrimSpace(string(body)))
+		if len(body) > 0 && body[0] == '[' {
+			var params []*fftypes.JSONAny
+			if err := json.Unmarshal(body, &params); err != nil {
+				return nil, i18n.NewError(ctx, msgs.MsgEthConnectBadRequest, err)
+			}
+			return params, nil
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &named); err != nil {
+				return nil, i18n.NewError(ctx, msgs.MsgEthConnectBadRequest, err)
+			}
+		}
+	}
+	params := make([]*fftypes.JSONAny, len(method.Inputs))
+	for i, input := range method.Inputs {
+		name := input.Name
+		if name == "" {
+			// Matches the default naming of outputs
+			name = "input"
+			if i > 0 {
+				name = fmt.Sprintf("%s%d", name, i)
+			}
+		}
+		if params[i] = named[name]; params[i] == nil {
+			return nil, i18n.NewError(ctx, msgs.MsgEthConnectMissingParam, name, method.String())
+		}
+	}
+	return params, nil
+}
+
+// queryParamJSON passes through JSON arrays and objects in a query parameter, for array and tuple inputs,
+// and otherwise supplies the value as a JSON string - which the ABI encoding parses for numbers and booleans
+func queryParamJSON(v string) *fftypes.JSONAny {
+	trimmed := strings.TrimSpace(v)
+	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		return fftypes.JSONAnyPtr(trimmed)
+	}
+	b, _ := json.Marshal(v)
+	return fftypes.JSONAnyPtrBytes(b)
+}
+
+func errorEntries(a abi.ABI) []*fftypes.JSONAny {
+	errors := make([]*fftypes.JSONAny, 0)
+	for _, e := range a {
+		if e.Type == abi.Error {
+			b, _ := json.Marshal(e)
+			errors = append(errors, fftypes.JSONAnyPtrBytes(b))
+		}
+	}
+	return errors
+}
@@ -0,0 +1,423 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethconnect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testFrom     = "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8"
+	testContract = "0x302259069aaa5b10dc6f29a9a3f72a8e52837cc3"
+	testTXHash   = "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2"
+	testABI      = `[
+		{"type":"constructor","inputs":[{"name":"initial","type":"uint256"}]},
+		{"type":"function","name":"set","inputs":[{"name":"x","type":"uint256"}],"outputs":[]},
+		{"type":"function","name":"get","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+		{"type":"error","name":"TooHigh","inputs":[{"name":"max","type":"uint256"}]}
+	]`
+)
+
+func newTestFacade(t *testing.T, setup ...func(conf config.Section)) (*Facade, *ffcapimocks.API) {
+	config.RootConfigReset()
+	conf := config.RootSection("ethconnect")
+	InitConfig(conf)
+	conf.Set("port", 0)
+	conf.Set(ReceiptPollingInterval, "1ms")
+	for _, fn := range setup {
+		fn(conf)
+	}
+	mc := &ffcapimocks.API{}
+	f, err := NewFacade(context.Background(), mc, conf)
+	assert.NoError(t, err)
+	return f, mc
+}
+
+func testRequest(t *testing.T, f *Facade, method, path, body string, result interface{}) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	res := httptest.NewRecorder()
+	f.router().ServeHTTP(res, req)
+	if result != nil {
+		err := json.Unmarshal(res.Body.Bytes(), result)
+		assert.NoError(t, err)
+	}
+	return res.Code
+}
+
+func testUploadABI(t *testing.T, f *Facade) *ABIInfo {
+	var a ABIInfo
+	status := testRequest(t, f, http.MethodPost, "/abis", `{"name":"store","abi":`+testABI+`,"bytecode":"0xfeedbeef"}`, &a)
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, a.Deployable)
+	return &a
+}
+
+func mockSend(mc *ffcapimocks.API, gasPrice string) {
+	mc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: testFrom}).
+		Return(&ffcapi.NextNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(10)}, ffcapi.ErrorReason(""), nil).Once()
+	mc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.Nonce.Int64() == 10 && req.Gas.Int64() == 50000 && req.GasPrice.String() == gasPrice && req.TransactionData == "0xfeedbeef"
+	})).Return(&ffcapi.TransactionSendResponse{TransactionHash: testTXHash}, ffcapi.ErrorReason(""), nil).Once()
+}
+
+func mockReceipt(mc *ffcapimocks.API, success bool) {
+	mc.On("TransactionReceipt", mock.Anything, &ffcapi.TransactionReceiptRequest{TransactionHash: testTXHash}).
+		Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not yet")).Once()
+	mc.On("TransactionReceipt", mock.Anything, &ffcapi.TransactionReceiptRequest{TransactionHash: testTXHash}).
+		Return(&ffcapi.TransactionReceiptResponse{
+			TransactionReceiptResponseBase: ffcapi.TransactionReceiptResponseBase{
+				BlockNumber:      fftypes.NewFFBigInt(1024),
+				TransactionIndex: fftypes.NewFFBigInt(1),
+				BlockHash:        "0x6197ef1a58a2a592bb447efb651f0db7945de21aa8048801b250bd7b7431f9b6",
+				Success:          success,
+				ExtraInfo: fftypes.JSONAnyPtr(`{
+					"contractAddress": "` + testContract + `",
+					"from": "` + testFrom + `",
+					"gasUsed": "48000",
+					"cumulativeGasUsed": "96000",
+					"errorMessage": "reverted"
+				}`),
+			},
+		}, ffcapi.ErrorReason(""), nil).Once()
+}
+
+func TestDeployAndInvokeSync(t *testing.T) {
+
+	f, mc := newTestFacade(t)
+	a := testUploadABI(t, f)
+
+	mockSend(mc, `{"gasPrice":"20000000000"}`)
+	mc.On("DeployContractPrepare", mock.Anything, mock.MatchedBy(func(req *ffcapi.ContractDeployPrepareRequest) bool {
+		return req.From == testFrom && req.Nonce.Int64() == 10 && req.Contract.String() == `"0xfeedbeef"` &&
+			len(req.Params) == 1 && req.Params[0].String() == `5` && len(req.Errors) == 1
+	})).Return(&ffcapi.TransactionPrepareResponse{Gas: fftypes.NewFFBigInt(50000), TransactionData: "0xfeedbeef"}, ffcapi.ErrorReason(""), nil)
+	mc.On("GasPriceEstimate", mock.Anything, mock.Anything).
+		Return(&ffcapi.GasPriceEstimateResponse{GasPrice: fftypes.JSONAnyPtr(`{"gasPrice":"20000000000"}`)}, ffcapi.ErrorReason(""), nil)
+	mockReceipt(mc, true)
+
+	var receipt transactionReceipt
+	status := testRequest(t, f, http.MethodPost, "/abis/"+a.ID+"?fly-from="+testFrom+"&fly-sync", `{"initial":5}`, &receipt)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, replyTypeSuccess, receipt.Headers.Type)
+	assert.Equal(t, "1024", receipt.BlockNumber)
+	assert.Equal(t, "1", receipt.Status)
+	assert.Equal(t, "10", receipt.Nonce)
+	assert.Equal(t, "48000", receipt.GasUsed)
+	assert.Equal(t, testContract, receipt.ContractAddress)
+
+	// The contract is registered under its address
+	var contracts []*ContractInfo
+	status = testRequest(t, f, http.MethodGet, "/contracts", "", &contracts)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, contracts, 1)
+	assert.Equal(t, a.ID, contracts[0].ABI)
+	assert.Equal(t, "/contracts/"+testContract, contracts[0].Path)
+
+	// Queries can be by GET, or by POST with fly-call
+	mc.On("QueryInvoke", mock.Anything, mock.MatchedBy(func(req *ffcapi.QueryInvokeRequest) bool {
+		return strings.EqualFold(req.To, testContract) && len(req.Params) == 0
+	})).Return(&ffcapi.QueryInvokeResponse{Outputs: fftypes.JSONAnyPtr(`{"output":"5"}`)}, ffcapi.ErrorReason(""), nil).Times(3)
+	var outputs map[string]string
+	status = testRequest(t, f, http.MethodGet, "/contracts/0x"+strings.ToUpper(testContract[2:])+"/get", "", &outputs)
+	assert.Equal(t, http.StatusOK, status)
+	status = testRequest(t, f, http.MethodGet, "/contracts/"+testContract+"/get", "", &outputs)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "5", outputs["output"])
+	status = testRequest(t, f, http.MethodPost, "/abis/"+a.ID+"/"+testContract+"/get?fly-call=true", "", &outputs)
+	assert.Equal(t, http.StatusOK, status)
+
+	mc.AssertExpectations(t)
+
+}
+
+func TestInvokeAsync(t *testing.T) {
+
+	f, mc := newTestFacade(t)
+	a := testUploadABI(t, f)
+	err := f.store.addContract(context.Background(), &ContractInfo{Address: testContract, ABI: a.ID})
+	assert.NoError(t, err)
+
+	mockSend(mc, `"30000000000"`)
+	mc.On("TransactionPrepare", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionPrepareRequest) bool {
+		return req.To == testContract && req.Value.Int64() == 100 && len(req.Params) == 1 && req.Params[0].String() == `"12"`
+	})).Return(&ffcapi.TransactionPrepareResponse{Gas: fftypes.NewFFBigInt(50000), TransactionData: "0xfeedbeef"}, ffcapi.ErrorReason(""), nil)
+
+	var sent sentResponse
+	req := httptest.NewRequest(http.MethodPost, "/contracts/"+testContract+"/set?fly-gasprice=30000000000", strings.NewReader(`["12"]`))
+	req.Header.Set("x-firefly-from", testFrom)
+	req.Header.Set("x-firefly-ethvalue", "100")
+	res := httptest.NewRecorder()
+	f.router().ServeHTTP(res, req)
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	err = json.Unmarshal(res.Body.Bytes(), &sent)
+	assert.NoError(t, err)
+	assert.True(t, sent.Sent)
+	assert.Equal(t, testTXHash, sent.ID)
+
+	// The receipt is available as a reply once mined
+	mockReceipt(mc, false)
+	var receipt transactionReceipt
+	status := testRequest(t, f, http.MethodGet, "/replies/"+testTXHash, "", &receipt)
+	assert.Equal(t, http.StatusNotFound, status)
+	status = testRequest(t, f, http.MethodGet, "/replies/"+testTXHash, "", &receipt)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, replyTypeFailure, receipt.Headers.Type)
+	assert.Equal(t, "0", receipt.Status)
+	assert.Equal(t, "reverted", receipt.ErrorMessage)
+
+	mc.AssertExpectations(t)
+
+}
+
+func TestDeployAsyncRegistersContract(t *testing.T) {
+
+	f, mc := newTestFacade(t)
+	a := testUploadABI(t, f)
+
+	mockSend(mc, `"30000000000"`)
+	mc.On("DeployContractPrepare", mock.Anything, mock.Anything).
+		Return(&ffcapi.TransactionPrepareResponse{Gas: fftypes.NewFFBigInt(50000), TransactionData: "0xfeedbeef"}, ffcapi.ErrorReason(""), nil)
+	mockReceipt(mc, true)
+
+	status := testRequest(t, f, http.MethodPost, "/abis/"+a.ID+"?fly-from="+testFrom+"&fly-gasprice=30000000000", `{"initial":"5"}`, nil)
+	assert.Equal(t, http.StatusAccepted, status)
+
+	for {
+		var c ContractInfo
+		if testRequest(t, f, http.MethodGet, "/contracts/"+testContract, "", &c) == http.StatusOK {
+			assert.Equal(t, a.ID, c.ABI)
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+
+}
+
+func TestABIs(t *testing.T) {
+
+	f, _ := newTestFacade(t)
+	a1 := testUploadABI(t, f)
+	a2 := testUploadABI(t, f)
+
+	var abis []*ABIInfo
+	status := testRequest(t, f, http.MethodGet, "/abis", "", &abis)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, abis, 2)
+	assert.ElementsMatch(t, []string{a1.ID, a2.ID}, []string{abis[0].ID, abis[1].ID})
+	assert.Nil(t, abis[0].ABI)
+
+	var a ABIInfo
+	status = testRequest(t, f, http.MethodGet, "/abis/"+a1.ID, "", &a)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, a.ABI, 4)
+
+	var errRes errorResponse
+	status = testRequest(t, f, http.MethodGet, "/abis/unknown", "", &errRes)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Regexp(t, "FF23126", errRes.Error)
+
+	status = testRequest(t, f, http.MethodPost, "/abis", `{"name":"noabi"}`, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Regexp(t, "FF23129", errRes.Error)
+
+	status = testRequest(t, f, http.MethodPost, "/abis", `{"abi":`+testABI+`,"bytecode":false}`, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Regexp(t, "FF23129", errRes.Error)
+
+	status = testRequest(t, f, http.MethodPost, "/abis", `!json`, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Regexp(t, "FF23129", errRes.Error)
+
+	// Without bytecode the ABI can be used with existing contracts, but cannot be deployed
+	status = testRequest(t, f, http.MethodPost, "/abis", `{"abi":`+testABI+`}`, &a)
+	assert.Equal(t, http.StatusOK, status)
+	assert.False(t, a.Deployable)
+	status = testRequest(t, f, http.MethodPost, "/abis/"+a.ID+"?fly-from="+testFrom, `{}`, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Regexp(t, "FF23132", errRes.Error)
+
+}
+
+type testSignatureRegistry struct {
+	*ffcapimocks.API
+	registered []string
+}
+
+func (r *testSignatureRegistry) RegisterEventSignatures(_ context.Context, source string, a abi.ABI) {
+	r.registered = append(r.registered, fmt.Sprintf("%s:%d", source, len(a)))
+}
+
+func TestABIsRegisterEventSignatures(t *testing.T) {
+
+	storagePath := t.TempDir()
+	config.RootConfigReset()
+	conf := config.RootSection("ethconnect")
+	InitConfig(conf)
+	conf.Set("port", 0)
+	conf.Set(StoragePath, storagePath)
+	r := &testSignatureRegistry{API: &ffcapimocks.API{}}
+	f, err := NewFacade(context.Background(), r, conf)
+	assert.NoError(t, err)
+	testUploadABI(t, f)
+	assert.Equal(t, []string{"store:4"}, r.registered)
+
+	// The stored ABIs are registered on startup
+	r = &testSignatureRegistry{API: &ffcapimocks.API{}}
+	_, err = NewFacade(context.Background(), r, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"store:4"}, r.registered)
+
+}
+
+func TestRequestErrors(t *testing.T) {
+
+	f, mc := newTestFacade(t, func(conf config.Section) {
+		conf.Set(ReceiptTimeout, "10ms")
+	})
+	a := testUploadABI(t, f)
+	base := "/abis/" + a.ID + "/" + testContract
+	var errRes errorResponse
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		err                string
+	}{
+		{http.MethodPost, "/abis/unknown?fly-from=" + testFrom, `{}`, http.StatusNotFound, "FF23126"},
+		{http.MethodPost, "/abis/" + a.ID, `{"initial":5}`, http.StatusBadRequest, "FF23130"},
+		{http.MethodPost, "/abis/" + a.ID + "?fly-from=" + testFrom, `{}`, http.StatusBadRequest, "FF23131.*initial"},
+		{http.MethodPost, "/abis/" + a.ID + "?fly-from=" + testFrom, `{!json`, http.StatusBadRequest, "FF23129"},
+		{http.MethodPost, "/abis/" + a.ID + "?fly-from=" + testFrom, `[!json`, http.StatusBadRequest, "FF23129"},
+		{http.MethodPost, "/abis/" + a.ID + "?fly-from=" + testFrom + "&fly-gas=wrong", `[5]`, http.StatusBadRequest, "FF23129.*fly-gas=wrong"},
+		{http.MethodPost, "/abis/" + a.ID + "?fly-from=" + testFrom + "&fly-ethvalue=wrong", `[5]`, http.StatusBadRequest, "FF23129.*fly-ethvalue=wrong"},
+		{http.MethodGet, "/abis/unknown/" + testContract + "/get", "", http.StatusNotFound, "FF23126"},
+		{http.MethodGet, base + "/unknown", "", http.StatusNotFound, "FF23128"},
+		{http.MethodGet, base + "/set", "", http.StatusBadRequest, "FF23131.*x"},
+		{http.MethodGet, "/contracts/" + testContract, "", http.StatusNotFound, "FF23127"},
+		{http.MethodPost, "/contracts/" + testContract + "/set", `{"x":1}`, http.StatusNotFound, "FF23127"},
+	} {
+		status := testRequest(t, f, tc.method, tc.path, tc.body, &errRes)
+		assert.Equal(t, tc.status, status, tc.path)
+		assert.Regexp(t, tc.err, errRes.Error, tc.path)
+	}
+
+	// Contracts registered with an ABI that has since been removed
+	err := f.store.addContract(context.Background(), &ContractInfo{Address: testContract, ABI: "removed"})
+	assert.NoError(t, err)
+	status := testRequest(t, f, http.MethodGet, "/contracts/"+testContract+"/get", "", &errRes)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Regexp(t, "FF23126", errRes.Error)
+
+	// Errors from the connector
+	mc.On("QueryInvoke", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("TooHigh(10)")).Once()
+	status = testRequest(t, f, http.MethodGet, base+"/get", "", &errRes)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "TooHigh(10)", errRes.Error)
+
+	// Array and tuple inputs are passed through as JSON in query parameters
+	mc.On("QueryInvoke", mock.Anything, mock.MatchedBy(func(req *ffcapi.QueryInvokeRequest) bool {
+		return req.Params[0].String() == `[1,2]`
+	})).Return(nil, ffcapi.ErrorReasonInvalidInputs, fmt.Errorf("pop")).Once()
+	status = testRequest(t, f, http.MethodGet, base+"/set?x=[1,2]", "", &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	mc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop1")).Once()
+	status = testRequest(t, f, http.MethodPost, base+"/set?fly-from="+testFrom, `{"x":1}`, &errRes)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, "pop1", errRes.Error)
+
+	mc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(10)}, ffcapi.ErrorReason(""), nil)
+	mc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonInvalidInputs, fmt.Errorf("pop2")).Once()
+	status = testRequest(t, f, http.MethodPost, base+"/set?fly-from="+testFrom, `{"x":1}`, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "pop2", errRes.Error)
+
+	mc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{TransactionData: "0xfeedbeef"}, ffcapi.ErrorReason(""), nil)
+	mc.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop3")).Once()
+	status = testRequest(t, f, http.MethodPost, base+"/set?fly-from="+testFrom, `{"x":1}`, &errRes)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, "pop3", errRes.Error)
+
+	mc.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("pop4")).Once()
+	status = testRequest(t, f, http.MethodPost, base+"/set?fly-from="+testFrom+"&fly-gasprice=0", `{"x":1}`, &errRes)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, "pop4", errRes.Error)
+
+	// Timeouts and failures waiting for the receipt
+	mc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{TransactionHash: testTXHash}, ffcapi.ErrorReason(""), nil)
+	mc.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not yet")).Once()
+	mc.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop5")).Once()
+	status = testRequest(t, f, http.MethodPost, base+"/set?fly-from="+testFrom+"&fly-gasprice=0&fly-sync=true", `{"x":1}`, &errRes)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, "pop5", errRes.Error)
+
+	mc.On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not yet"))
+	status = testRequest(t, f, http.MethodPost, base+"/set?fly-from="+testFrom+"&fly-gasprice=0&fly-sync=true", `{"x":1}`, &errRes)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Regexp(t, "FF23133", errRes.Error)
+
+	// Asynchronous deployments are not registered if the receipt is not available
+	mc.On("DeployContractPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{TransactionData: "0xfeedbeef"}, ffcapi.ErrorReason(""), nil)
+	status = testRequest(t, f, http.MethodPost, "/abis/"+a.ID+"?fly-from="+testFrom+"&fly-gasprice=0", `[5]`, nil)
+	assert.Equal(t, http.StatusAccepted, status)
+
+}
+
+func TestRunAndStop(t *testing.T) {
+
+	f, _ := newTestFacade(t)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- f.Run(ctx)
+	}()
+
+	res, err := http.Get(fmt.Sprintf("http://%s/abis", f.Addr()))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res.Body.Close()
+
+	cancelCtx()
+	assert.NoError(t, <-done)
+
+}
+
+func TestNewFacadeBadConfig(t *testing.T) {
+
+	config.RootConfigReset()
+	conf := config.RootSection("ethconnect")
+	InitConfig(conf)
+	conf.Set("address", ":::::wrong")
+	_, err := NewFacade(context.Background(), &ffcapimocks.API{}, conf)
+	assert.Regexp(t, "FF00151", err)
+
+}
@@ -0,0 +1,203 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethconnect
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// ABIInfo is an uploaded ABI, with the bytecode needed to deploy new instances of the contract
+type ABIInfo struct {
+	ID          string                    `json:"id"`
+	Name        string                    `json:"name"`
+	Description string                    `json:"description,omitempty"`
+	Created     *fftypes.FFTime           `json:"created"`
+	Deployable  bool                      `json:"deployable"`
+	Path        string                    `json:"path"`
+	ABI         abi.ABI                   `json:"abi,omitempty"`
+	Bytecode    ethtypes.HexBytes0xPrefix `json:"bytecode,omitempty"`
+}
+
+// ContractInfo is a deployed (or registered) contract instance, and the ID of its ABI
+type ContractInfo struct {
+	Address string          `json:"address"`
+	Path    string          `json:"path"`
+	ABI     string          `json:"abi"`
+	Created *fftypes.FFTime `json:"created"`
+}
+
+// store holds the ABIs and contracts in memory, and writes each one as a JSON file under the
+// storage path (if configured) so they survive a restart - in the same way as ethconnect did
+type store struct {
+	path      string
+	mux       sync.Mutex
+	abis      map[string]*ABIInfo
+	contracts map[string]*ContractInfo
+}
+
+func newStore(ctx context.Context, path string) (*store, error) {
+	s := &store{
+		path:      path,
+		abis:      make(map[string]*ABIInfo),
+		contracts: make(map[string]*ContractInfo),
+	}
+	if path == "" {
+		return s, nil
+	}
+	for _, dir := range []string{"abis", "contracts"} {
+		if err := os.MkdirAll(filepath.Join(path, dir), 0700); err != nil {
+			return nil, i18n.NewError(ctx, msgs.MsgEthConnectStoreFailed, dir, err)
+		}
+	}
+	if err := loadAll(ctx, filepath.Join(path, "abis"), func(b []byte) error {
+		var a ABIInfo
+		err := json.Unmarshal(b, &a)
+		s.abis[a.ID] = &a
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if err := loadAll(ctx, filepath.Join(path, "contracts"), func(b []byte) error {
+		var c ContractInfo
+		err := json.Unmarshal(b, &c)
+		s.contracts[c.Address] = &c
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func loadAll(ctx context.Context, dir string, load func(b []byte) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return i18n.NewError(ctx, msgs.MsgEthConnectStoreFailed, dir, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		filename := filepath.Join(dir, e.Name())
+		b, err := os.ReadFile(filename)
+		if err == nil {
+			err = load(b)
+		}
+		if err != nil {
+			return i18n.NewError(ctx, msgs.MsgEthConnectStoreFailed, filename, err)
+		}
+	}
+	return nil
+}
+
+func (s *store) persist(ctx context.Context, dir, name string, v interface{}) error {
+	if s.path == "" {
+		return nil
+	}
+	filename := filepath.Join(s.path, dir, name+".json")
+	b, _ := json.Marshal(v)
+	if err := os.WriteFile(filename, b, 0600); err != nil {
+		return i18n.NewError(ctx, msgs.MsgEthConnectStoreFailed, filename, err)
+	}
+	return nil
+}
+
+func (s *store) addABI(ctx context.Context, a *ABIInfo) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.persist(ctx, "abis", a.ID, a); err != nil {
+		return err
+	}
+	s.abis[a.ID] = a
+	return nil
+}
+
+func (s *store) getABI(ctx context.Context, id string) (*ABIInfo, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	a := s.abis[id]
+	if a == nil {
+		return nil, i18n.NewError(ctx, msgs.MsgEthConnectABINotFound, id)
+	}
+	return a, nil
+}
+
+func (s *store) listABIs() []*ABIInfo {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	abis := make([]*ABIInfo, 0, len(s.abis))
+	for _, a := range s.abis {
+		// The list does not include the full ABI and bytecode
+		abis = append(abis, &ABIInfo{
+			ID:          a.ID,
+			Name:        a.Name,
+			Description: a.Description,
+			Created:     a.Created,
+			Deployable:  a.Deployable,
+			Path:        a.Path,
+		})
+	}
+	sort.Slice(abis, func(i, j int) bool {
+		if abis[i].Created.Equal(abis[j].Created) {
+			return abis[i].ID < abis[j].ID
+		}
+		return abis[i].Created.Time().Before(*abis[j].Created.Time())
+	})
+	return abis
+}
+
+func (s *store) addContract(ctx context.Context, c *ContractInfo) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.persist(ctx, "contracts", c.Address, c); err != nil {
+		return err
+	}
+	s.contracts[c.Address] = c
+	return nil
+}
+
+func (s *store) getContract(ctx context.Context, address string) (*ContractInfo, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c := s.contracts[address]
+	if c == nil {
+		return nil, i18n.NewError(ctx, msgs.MsgEthConnectNoContract, address)
+	}
+	return c, nil
+}
+
+func (s *store) listContracts() []*ContractInfo {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	contracts := make([]*ContractInfo, 0, len(s.contracts))
+	for _, c := range s.contracts {
+		contracts = append(contracts, c)
+	}
+	sort.Slice(contracts, func(i, j int) bool { return contracts[i].Address < contracts[j].Address })
+	return contracts
+}
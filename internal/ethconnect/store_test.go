@@ -0,0 +1,94 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethconnect
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorePersistence(t *testing.T) {
+
+	dir := t.TempDir()
+	f, _ := newTestFacade(t, func(conf config.Section) {
+		conf.Set(StoragePath, dir)
+	})
+	a := testUploadABI(t, f)
+	err := f.store.addContract(context.Background(), &ContractInfo{Address: testContract, ABI: a.ID})
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "abis", "README"), []byte("ignored"), 0600)
+	assert.NoError(t, err)
+
+	// A new store loads the ABIs and contracts from the directory
+	s, err := newStore(context.Background(), dir)
+	assert.NoError(t, err)
+	a2, err := s.getABI(context.Background(), a.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, a.Bytecode, a2.Bytecode)
+	assert.Len(t, a2.ABI, 4)
+	c, err := s.getContract(context.Background(), testContract)
+	assert.NoError(t, err)
+	assert.Equal(t, a.ID, c.ABI)
+
+}
+
+func TestStoreLoadFailures(t *testing.T) {
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "abis"), []byte{}, 0600)
+	assert.NoError(t, err)
+	_, err = newStore(context.Background(), dir)
+	assert.Regexp(t, "FF23134", err)
+
+	dir = t.TempDir()
+	err = os.MkdirAll(filepath.Join(dir, "abis"), 0700)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "abis", "bad.json"), []byte("!json"), 0600)
+	assert.NoError(t, err)
+	_, err = newStore(context.Background(), dir)
+	assert.Regexp(t, "FF23134.*bad.json", err)
+
+	dir = t.TempDir()
+	err = os.MkdirAll(filepath.Join(dir, "contracts"), 0700)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "contracts", "bad.json"), []byte("!json"), 0600)
+	assert.NoError(t, err)
+	_, err = newStore(context.Background(), dir)
+	assert.Regexp(t, "FF23134.*bad.json", err)
+
+}
+
+func TestStorePersistFailures(t *testing.T) {
+
+	dir := t.TempDir()
+	s, err := newStore(context.Background(), dir)
+	assert.NoError(t, err)
+	err = os.RemoveAll(dir)
+	assert.NoError(t, err)
+
+	err = s.addABI(context.Background(), &ABIInfo{ID: "abi1"})
+	assert.Regexp(t, "FF23134", err)
+	err = s.addContract(context.Background(), &ContractInfo{Address: testContract})
+	assert.Regexp(t, "FF23134", err)
+	assert.Empty(t, s.listContracts())
+
+}
@@ -0,0 +1,261 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethconnect
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const (
+	replyTypeSuccess = "TransactionSuccess"
+	replyTypeFailure = "TransactionFailure"
+)
+
+// sentResponse is returned for a transaction submitted without fly-sync. The ID is the transaction hash,
+// which can be used to get the receipt from /replies/{id} once the transaction is mined.
+type sentResponse struct {
+	Sent bool   `json:"sent"`
+	ID   string `json:"id"`
+}
+
+type replyHeaders struct {
+	ID          string  `json:"id"`
+	Type        string  `json:"type"`
+	TimeElapsed float64 `json:"timeElapsed,omitempty"`
+}
+
+// transactionReceipt is the receipt in the layout of an ethconnect reply, with numbers as decimal strings
+type transactionReceipt struct {
+	Headers           replyHeaders `json:"headers"`
+	BlockHash         string       `json:"blockHash"`
+	BlockNumber       string       `json:"blockNumber"`
+	ContractAddress   string       `json:"contractAddress,omitempty"`
+	CumulativeGasUsed string       `json:"cumulativeGasUsed,omitempty"`
+	From              string       `json:"from,omitempty"`
+	To                string       `json:"to,omitempty"`
+	GasUsed           string       `json:"gasUsed,omitempty"`
+	Nonce             string       `json:"nonce,omitempty"`
+	Status            string       `json:"status"`
+	TransactionHash   string       `json:"transactionHash"`
+	TransactionIndex  string       `json:"transactionIndex"`
+	ErrorMessage      string       `json:"errorMessage,omitempty"`
+}
+
+// receiptExtraInfo is the subset of the extra information in the receipts of the connector used in the reply
+type receiptExtraInfo struct {
+	ContractAddress   string            `json:"contractAddress"`
+	CumulativeGasUsed *fftypes.FFBigInt `json:"cumulativeGasUsed"`
+	From              string            `json:"from"`
+	To                string            `json:"to"`
+	GasUsed           *fftypes.FFBigInt `json:"gasUsed"`
+	ErrorMessage      *string           `json:"errorMessage"`
+}
+
+func (f *Facade) signerLock(from string) *sync.Mutex {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	from = strings.ToLower(from)
+	l := f.signerLocks[from]
+	if l == nil {
+		l = &sync.Mutex{}
+		f.signerLocks[from] = l
+	}
+	return l
+}
+
+// submit prepares and sends a transaction, then either waits for the receipt (fly-sync=true) or returns
+// as soon as the transaction is sent. Submissions are serialized for each signer, as the nonce is queried
+// from the node for each transaction. Where the transaction is a deployment of an uploaded ABI, the new
+// contract is registered under its address once the receipt is available.
+func (f *Facade) submit(req *http.Request, prepare func(headers ffcapi.TransactionHeaders) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error), deployABI string) (int, interface{}, error) {
+	ctx := req.Context()
+	start := time.Now()
+	headers := ffcapi.TransactionHeaders{
+		From: flyParam(req, "from"),
+	}
+	if headers.From == "" {
+		return -1, nil, i18n.NewError(ctx, msgs.MsgEthConnectMissingFrom)
+	}
+	var err error
+	if headers.Gas, err = flyIntParam(req, "gas"); err != nil {
+		return -1, nil, err
+	}
+	if headers.Value, err = flyIntParam(req, "ethvalue"); err != nil {
+		return -1, nil, err
+	}
+	var gasPrice *fftypes.JSONAny
+	if gp := flyParam(req, "gasprice"); gp != "" {
+		b, _ := json.Marshal(gp)
+		gasPrice = fftypes.JSONAnyPtrBytes(b)
+	}
+
+	txHash, nonce, reason, err := f.prepareAndSend(ctx, headers, gasPrice, prepare)
+	if err != nil {
+		return -1, nil, connectorError(reason, err)
+	}
+
+	if !flyBoolParam(req, "sync") {
+		if deployABI != "" {
+			go func() {
+				if receipt, err := f.waitForReceipt(f.ctx, txHash); err == nil {
+					f.registerDeployed(f.ctx, deployABI, receipt)
+				} else {
+					log.L(f.ctx).Warnf("Contract deployed with ABI %s in transaction %s not registered: %s", deployABI, txHash, err)
+				}
+			}()
+		}
+		return http.StatusAccepted, &sentResponse{Sent: true, ID: txHash}, nil
+	}
+
+	receipt, err := f.waitForReceipt(ctx, txHash)
+	if err != nil {
+		return -1, nil, err
+	}
+	reply := buildReply(txHash, receipt)
+	reply.Nonce = nonce.String()
+	reply.Headers.TimeElapsed = time.Since(start).Seconds()
+	if deployABI != "" {
+		f.registerDeployed(ctx, deployABI, receipt)
+	}
+	return http.StatusOK, reply, nil
+}
+
+func (f *Facade) prepareAndSend(ctx context.Context, headers ffcapi.TransactionHeaders, gasPrice *fftypes.JSONAny, prepare func(headers ffcapi.TransactionHeaders) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error)) (string, *fftypes.FFBigInt, ffcapi.ErrorReason, error) {
+	l := f.signerLock(headers.From)
+	l.Lock()
+	defer l.Unlock()
+
+	nonceRes, reason, err := f.c.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: headers.From})
+	if err != nil {
+		return "", nil, reason, err
+	}
+	headers.Nonce = nonceRes.Nonce
+	prepared, reason, err := prepare(headers)
+	if err != nil {
+		return "", nil, reason, err
+	}
+	headers.Gas = prepared.Gas
+	if gasPrice == nil {
+		gasPriceRes, reason, err := f.c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+		if err != nil {
+			return "", nil, reason, err
+		}
+		gasPrice = gasPriceRes.GasPrice
+	}
+	sent, reason, err := f.c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: headers,
+		GasPrice:           gasPrice,
+		TransactionData:    prepared.TransactionData,
+	})
+	if err != nil {
+		return "", nil, reason, err
+	}
+	log.L(ctx).Infof("Sent transaction %s from %s with nonce %s", sent.TransactionHash, headers.From, headers.Nonce)
+	return sent.TransactionHash, headers.Nonce, "", nil
+}
+
+func (f *Facade) waitForReceipt(ctx context.Context, txHash string) (*ffcapi.TransactionReceiptResponse, error) {
+	ctx, cancelCtx := context.WithTimeout(ctx, f.receiptTimeout)
+	defer cancelCtx()
+	for {
+		receipt, reason, err := f.c.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+		if err == nil {
+			return receipt, nil
+		}
+		if reason != ffcapi.ErrorReasonNotFound {
+			return nil, connectorError(reason, err)
+		}
+		select {
+		case <-time.After(f.pollingInterval):
+		case <-ctx.Done():
+			return nil, i18n.NewError(ctx, msgs.MsgEthConnectReceiptTimeout, txHash)
+		}
+	}
+}
+
+func (f *Facade) registerDeployed(ctx context.Context, abiID string, receipt *ffcapi.TransactionReceiptResponse) {
+	address := buildReply("", receipt).ContractAddress
+	if !receipt.Success || address == "" {
+		return
+	}
+	address = strings.ToLower(address)
+	err := f.store.addContract(ctx, &ContractInfo{
+		Address: address,
+		Path:    "/contracts/" + address,
+		ABI:     abiID,
+		Created: fftypes.Now(),
+	})
+	if err != nil {
+		log.L(ctx).Errorf("Failed to register contract %s: %s", address, err)
+	}
+}
+
+func buildReply(txHash string, receipt *ffcapi.TransactionReceiptResponse) *transactionReceipt {
+	reply := &transactionReceipt{
+		Headers: replyHeaders{
+			ID:   txHash,
+			Type: replyTypeSuccess,
+		},
+		BlockHash:        receipt.BlockHash,
+		BlockNumber:      receipt.BlockNumber.String(),
+		Status:           "1",
+		TransactionHash:  txHash,
+		TransactionIndex: receipt.TransactionIndex.String(),
+	}
+	if !receipt.Success {
+		reply.Headers.Type = replyTypeFailure
+		reply.Status = "0"
+	}
+	var extraInfo receiptExtraInfo
+	if receipt.ExtraInfo != nil && json.Unmarshal(receipt.ExtraInfo.Bytes(), &extraInfo) == nil {
+		reply.ContractAddress = extraInfo.ContractAddress
+		reply.From = extraInfo.From
+		reply.To = extraInfo.To
+		if extraInfo.CumulativeGasUsed != nil {
+			reply.CumulativeGasUsed = extraInfo.CumulativeGasUsed.String()
+		}
+		if extraInfo.GasUsed != nil {
+			reply.GasUsed = extraInfo.GasUsed.String()
+		}
+		if extraInfo.ErrorMessage != nil {
+			reply.ErrorMessage = *extraInfo.ErrorMessage
+		}
+	}
+	return reply
+}
+
+// getReply returns the receipt of a transaction submitted without fly-sync, once it has been mined
+func (f *Facade) getReply(req *http.Request) (int, interface{}, error) {
+	txHash := mux.Vars(req)["id"]
+	receipt, reason, err := f.c.TransactionReceipt(req.Context(), &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+	if err != nil {
+		return -1, nil, connectorError(reason, err)
+	}
+	return http.StatusOK, buildReply(txHash, receipt), nil
+}
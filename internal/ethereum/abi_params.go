@@ -0,0 +1,80 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+)
+
+// The ABI library reports the path to a field it fails to parse, relative to the parameter array
+// it is given, such as ".0[1].legs[2].amount" for the first parameter
+var abiComponentPathRegex = regexp.MustCompile(`component \.0((?:\[\d+\]|\.\w+)*)`)
+
+// parseABIParams parses the JSON parameters against the inputs of a method, constructor or error.
+// Nested tuples, arrays of tuples, fixed size nested arrays, and user-defined value types (which
+// appear in the ABI as their underlying elementary type) are all handled by the ABI library.
+// Each parameter is parsed on its own first, so that a failure is reported against the full path
+// of the mismatched field, starting with the name of the parameter.
+func parseABIParams(ctx context.Context, entry *abi.Entry, params []*fftypes.JSONAny) (*abi.ComponentValue, error) {
+	// Parse the params into the standard semantics of Go JSON unmarshalling, with []interface{}
+	ethParams := make([]interface{}, len(params))
+	for i, p := range params {
+		if p != nil {
+			err := json.Unmarshal([]byte(*p), &ethParams[i])
+			if err != nil {
+				return nil, i18n.NewError(ctx, msgs.MsgUnmarshalParamFail, i, err)
+			}
+		}
+	}
+
+	if len(ethParams) == len(entry.Inputs) {
+		for i, input := range entry.Inputs {
+			if _, err := (abi.ParameterArray{input}).ParseExternalDataCtx(ctx, []interface{}{ethParams[i]}); err != nil {
+				if path, ok := abiParamPath(i, input, err); ok {
+					return nil, i18n.NewError(ctx, msgs.MsgInvalidParamValue, path, entry.String(), err)
+				}
+				return nil, err
+			}
+		}
+	}
+
+	// Match the parameters to the ABI call data for the method.
+	// Note the FireFly ABI decoding package handles formatting errors / translation etc.
+	return entry.Inputs.ParseExternalDataCtx(ctx, ethParams)
+}
+
+// abiParamPath returns the path to the field that failed to parse, such as "orders[1].legs[2].amount",
+// or false if the error is not for a value (such as an invalid type in the ABI)
+func abiParamPath(i int, input *abi.Parameter, err error) (string, bool) {
+	match := abiComponentPathRegex.FindStringSubmatch(err.Error())
+	if match == nil {
+		return "", false
+	}
+	name := input.Name
+	if name == "" {
+		name = strconv.Itoa(i)
+	}
+	return name + match[1], true
+}
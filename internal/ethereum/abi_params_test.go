@@ -0,0 +1,167 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+// An order book method with nested tuple arrays, a fixed size nested array, and user-defined value types
+// (which solc emits as their underlying type, with the UDVT name in internalType)
+const abiNestedOrders = `{
+	"name": "settle",
+	"type": "function",
+	"inputs": [{
+		"name": "batches",
+		"type": "tuple[][2]",
+		"internalType": "struct Orders.Order[][2]",
+		"components": [
+			{"name": "id", "type": "uint256", "internalType": "OrderId"},
+			{"name": "legs", "type": "tuple[]", "components": [
+				{"name": "amount", "type": "uint64", "internalType": "Amount"},
+				{"name": "path", "type": "address[2][]"}
+			]}
+		]
+	}, {
+		"type": "bytes32",
+		"internalType": "Ref"
+	}]
+}`
+
+func testParseABIParams(t *testing.T, abiJSON string, params ...string) (*abi.Entry, *abi.ComponentValue, error) {
+	var entry *abi.Entry
+	assert.NoError(t, json.Unmarshal([]byte(abiJSON), &entry))
+	jsonParams := make([]*fftypes.JSONAny, len(params))
+	for i, p := range params {
+		jsonParams[i] = fftypes.JSONAnyPtr(p)
+	}
+	cv, err := parseABIParams(context.Background(), entry, jsonParams)
+	return entry, cv, err
+}
+
+func TestParseABIParamsNestedTuples(t *testing.T) {
+
+	entry, cv, err := testParseABIParams(t, abiNestedOrders,
+		`[
+			[{"id": 1, "legs": [{"amount": "100", "path": [["0x4a8c8f1717570f9774652075e249ded38124d708", "0x20355f3e852d4b6a9944ada8d5399ddd3409a431"]]}]}],
+			[{"id": "0x02", "legs": []}, {"id": 3, "legs": [{"amount": 5, "path": []}]}]
+		]`,
+		`"0x000000000000000000000000000000000000000000000000000000000000abcd"`,
+	)
+	assert.NoError(t, err)
+	callData, err := entry.EncodeCallDataCtx(context.Background(), cv)
+	assert.NoError(t, err)
+
+	// Decodes back to the same values
+	decoded, err := entry.DecodeCallDataCtx(context.Background(), callData)
+	assert.NoError(t, err)
+	b, err := abi.NewSerializer().SetFormattingMode(abi.FormatAsObjects).SerializeJSON(decoded)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"batches": [
+			[{"id": "1", "legs": [{"amount": "100", "path": [["4a8c8f1717570f9774652075e249ded38124d708", "20355f3e852d4b6a9944ada8d5399ddd3409a431"]]}]}],
+			[{"id": "2", "legs": []}, {"id": "3", "legs": [{"amount": "5", "path": []}]}]
+		],
+		"1": "000000000000000000000000000000000000000000000000000000000000abcd"
+	}`, string(b))
+
+}
+
+func TestParseABIParamsFieldPath(t *testing.T) {
+
+	_, _, err := testParseABIParams(t, abiNestedOrders,
+		`[[], [{"id": 1, "legs": [{"amount": 1, "path": []}, {"amount": "lots", "path": []}]}]]`,
+		`"0x00"`,
+	)
+	assert.Regexp(t, "FF23156.*'batches\\[1\\]\\[0\\]\\.legs\\[1\\]\\.amount' in settle.*FF22030", err)
+
+	_, _, err = testParseABIParams(t, abiNestedOrders,
+		`[[], [{"id": 1, "legs": [{"amount": 1}]}]]`,
+		`"0x00"`,
+	)
+	assert.Regexp(t, "FF23156.*'batches\\[1\\]\\[0\\]\\.legs\\[0\\]\\.path'.*FF22040", err)
+
+	_, _, err = testParseABIParams(t, abiNestedOrders, `[[]]`, `"0x00"`)
+	assert.Regexp(t, "FF23156.*'batches'.*FF22036", err)
+
+	// Unnamed parameters are identified by their position
+	_, _, err = testParseABIParams(t, abiNestedOrders, `[[], []]`, `"not hex"`)
+	assert.Regexp(t, "FF23156.*'1' in settle", err)
+
+	// Errors that are not for a value are returned as they are
+	_, _, err = testParseABIParams(t, `{"name": "f", "type": "function", "inputs": [{"type": "!wrong"}]}`, `1`)
+	assert.Regexp(t, "^FF22025", err)
+	_, _, err = testParseABIParams(t, abiNestedOrders, `[[], []]`)
+	assert.Regexp(t, "^FF22037", err)
+	_, _, err = testParseABIParams(t, abiNestedOrders, `!!!`, `"0x00"`)
+	assert.Regexp(t, "FF23014", err)
+
+}
+
+func TestDecodeLogDataNestedTuples(t *testing.T) {
+
+	l, _, _ := newTestListener(t, false)
+
+	var abiEvent *abi.Entry
+	err := json.Unmarshal([]byte(`{
+		"name": "Settled",
+		"type": "event",
+		"inputs": [
+			{"name": "id", "type": "uint256", "internalType": "OrderId", "indexed": true},
+			{"name": "legs", "type": "tuple[][]", "components": [
+				{"name": "amount", "type": "uint64", "internalType": "Amount"},
+				{"name": "pair", "type": "address[2]"}
+			]}
+		]
+	}`), &abiEvent)
+	assert.NoError(t, err)
+
+	data, err := abi.ParameterArray{abiEvent.Inputs[1]}.EncodeABIDataValues([]interface{}{
+		[]interface{}{
+			[]interface{}{map[string]interface{}{"amount": 7, "pair": []interface{}{"0x4a8c8f1717570f9774652075e249ded38124d708", "0x20355f3e852d4b6a9944ada8d5399ddd3409a431"}}},
+			[]interface{}{},
+		},
+	})
+	assert.NoError(t, err)
+	topics := []ethtypes.HexBytes0xPrefix{
+		abiEvent.SignatureHashBytes(),
+		ethtypes.MustNewHexBytes0xPrefix("0x0000000000000000000000000000000000000000000000000000000000000009"),
+	}
+
+	res, decoded := l.ee.decodeLogData(l.es.ctx, abiEvent, topics, data)
+	assert.True(t, decoded)
+	assert.JSONEq(t, `{
+		"id": "9",
+		"legs": [
+			[{"amount": "7", "pair": ["0x4a8c8f1717570f9774652075e249ded38124d708", "0x20355f3e852d4b6a9944ada8d5399ddd3409a431"]}],
+			[]
+		]
+	}`, res.String())
+
+	// Truncated data cannot be decoded
+	res, decoded = l.ee.decodeLogData(l.es.ctx, abiEvent, topics, data[0:40])
+	assert.False(t, decoded)
+	assert.Nil(t, res)
+
+}
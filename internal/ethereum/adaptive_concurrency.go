@@ -0,0 +1,157 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+)
+
+// adaptiveConcurrency wraps the JSON/RPC backend, to limit the requests in flight to what the node or provider
+// can sustain. The limit is controlled AIMD style - it grows by one for each limit's worth of successful requests,
+// and is multiplied down when the provider throttles us (429 or -32005) or responses exceed the latency target.
+type adaptiveConcurrency struct {
+	rpcbackend.Backend
+	minLimit       float64
+	maxLimit       float64 // zero for no ceiling
+	latencyTarget  time.Duration
+	decreaseFactor float64
+	mux            sync.Mutex
+	limit          float64
+	inFlight       int
+	lastDecrease   time.Time
+	changed        chan struct{} // closed and replaced when a slot might have become available
+}
+
+type ConcurrencyStatus struct {
+	Limit    int `json:"limit"`
+	InFlight int `json:"inFlight"`
+}
+
+func newAdaptiveConcurrency(backend rpcbackend.Backend, conf config.Section) *adaptiveConcurrency {
+	ac := &adaptiveConcurrency{
+		Backend:        backend,
+		minLimit:       math.Max(1, float64(conf.GetInt(AdaptiveConcurrencyMin))),
+		maxLimit:       float64(conf.GetInt(MaxConcurrentRequests)),
+		latencyTarget:  conf.GetDuration(AdaptiveConcurrencyLatency),
+		decreaseFactor: conf.GetFloat64(AdaptiveConcurrencyDecrease),
+		changed:        make(chan struct{}),
+	}
+	ac.limit = ac.bound(float64(conf.GetInt(AdaptiveConcurrencyInitial)))
+	return ac
+}
+
+func (ac *adaptiveConcurrency) CallRPC(ctx context.Context, result interface{}, method string, params ...interface{}) *rpcbackend.RPCError {
+	if err := ac.acquire(ctx); err != nil {
+		return &rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeInternalError), Message: err.Error()}
+	}
+	start := time.Now()
+	rpcErr := ac.Backend.CallRPC(ctx, result, method, params...)
+	ac.release(ctx, start, rpcErr)
+	return rpcErr
+}
+
+func (ac *adaptiveConcurrency) SyncRequest(ctx context.Context, rpcReq *rpcbackend.RPCRequest) (*rpcbackend.RPCResponse, error) {
+	if err := ac.acquire(ctx); err != nil {
+		return rpcbackend.RPCErrorResponse(err, rpcReq.ID, rpcbackend.RPCCodeInternalError), err
+	}
+	start := time.Now()
+	rpcRes, err := ac.Backend.SyncRequest(ctx, rpcReq)
+	var rpcErr *rpcbackend.RPCError
+	if err != nil && rpcRes != nil {
+		rpcErr = rpcRes.Error
+	}
+	ac.release(ctx, start, rpcErr)
+	return rpcRes, err
+}
+
+func (ac *adaptiveConcurrency) bound(limit float64) float64 {
+	if ac.maxLimit > 0 && limit > ac.maxLimit {
+		return ac.maxLimit
+	}
+	return math.Max(limit, ac.minLimit)
+}
+
+func (ac *adaptiveConcurrency) acquire(ctx context.Context) error {
+	for {
+		ac.mux.Lock()
+		if ac.inFlight < int(ac.limit) {
+			ac.inFlight++
+			ac.mux.Unlock()
+			return nil
+		}
+		changed := ac.changed
+		ac.mux.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return i18n.NewError(ctx, i18n.MsgContextCanceled)
+		}
+	}
+}
+
+// isThrottled returns whether the node or provider rejected the request due to a rate or capacity limit
+func isThrottled(rpcErr *rpcbackend.RPCError) bool {
+	if rpcErr == nil {
+		return false
+	}
+	return rpcErr.Code == 429 || rpcErr.Code == -32005 ||
+		(strings.HasPrefix(rpcErr.Message, rpcRequestFailedPrefix) && strings.Contains(rpcErr.Message, "429"))
+}
+
+func (ac *adaptiveConcurrency) release(ctx context.Context, start time.Time, rpcErr *rpcbackend.RPCError) {
+	latency := time.Since(start)
+	ac.mux.Lock()
+	defer ac.mux.Unlock()
+	ac.inFlight--
+	throttled := isThrottled(rpcErr)
+	slow := ac.latencyTarget > 0 && latency > ac.latencyTarget
+	switch {
+	case ctx.Err() != nil:
+		// A cancelled request tells us nothing about the node
+	case throttled || slow:
+		// Only one decrease for the requests that were in flight together, so a burst of rejections
+		// at the same time does not collapse the limit
+		if start.After(ac.lastDecrease) {
+			previous := ac.limit
+			ac.limit = ac.bound(ac.limit * ac.decreaseFactor)
+			ac.lastDecrease = time.Now()
+			log.L(ctx).Warnf("Reduced JSON/RPC concurrency limit %d -> %d (throttled=%t latency=%s)", int(previous), int(ac.limit), throttled, latency.Truncate(time.Millisecond))
+		}
+	default:
+		ac.limit = ac.bound(ac.limit + 1/ac.limit)
+	}
+	close(ac.changed)
+	ac.changed = make(chan struct{})
+}
+
+func (ac *adaptiveConcurrency) getStatus() *ConcurrencyStatus {
+	ac.mux.Lock()
+	defer ac.mux.Unlock()
+	return &ConcurrencyStatus{
+		Limit:    int(ac.limit),
+		InFlight: ac.inFlight,
+	}
+}
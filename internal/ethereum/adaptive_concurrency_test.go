@@ -0,0 +1,182 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestAdaptiveConcurrency(t *testing.T, setConf func(conf config.Section)) (*adaptiveConcurrency, *rpcbackendmocks.Backend) {
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	setConf(conf)
+	mRPC := &rpcbackendmocks.Backend{}
+	return newAdaptiveConcurrency(mRPC, conf), mRPC
+}
+
+func TestAdaptiveConcurrencyAdditiveIncrease(t *testing.T) {
+
+	ac, mRPC := newTestAdaptiveConcurrency(t, func(conf config.Section) {
+		conf.Set(AdaptiveConcurrencyInitial, 2)
+		conf.Set(MaxConcurrentRequests, 4)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_chainId").Return(nil)
+
+	ctx := context.Background()
+	var chainID string
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, ac.CallRPC(ctx, &chainID, "eth_chainId"))
+	}
+	// One is added for each limit's worth of successes
+	assert.Equal(t, 2, ac.getStatus().Limit)
+	assert.Nil(t, ac.CallRPC(ctx, &chainID, "eth_chainId"))
+	assert.Equal(t, 3, ac.getStatus().Limit)
+
+	// Up to the configured maximum
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, ac.CallRPC(ctx, &chainID, "eth_chainId"))
+	}
+	assert.Equal(t, &ConcurrencyStatus{Limit: 4, InFlight: 0}, ac.getStatus())
+
+}
+
+func TestAdaptiveConcurrencyMultiplicativeDecrease(t *testing.T) {
+
+	ac, mRPC := newTestAdaptiveConcurrency(t, func(conf config.Section) {
+		conf.Set(AdaptiveConcurrencyInitial, 40)
+		conf.Set(AdaptiveConcurrencyMin, 4)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_chainId").Return(&rpcbackend.RPCError{Code: -32005, Message: "limit exceeded"})
+	mRPC.On("SyncRequest", mock.Anything, mock.Anything).Return(&rpcbackend.RPCResponse{
+		Error: &rpcbackend.RPCError{Code: 429, Message: "compute units exceeded"},
+	}, assert.AnError)
+
+	ctx := context.Background()
+	var chainID string
+	rpcErr := ac.CallRPC(ctx, &chainID, "eth_chainId")
+	assert.Equal(t, "limit exceeded", rpcErr.Message)
+	assert.Equal(t, 20, ac.getStatus().Limit)
+	_, err := ac.SyncRequest(ctx, &rpcbackend.RPCRequest{Method: "eth_chainId"})
+	assert.Error(t, err)
+	assert.Equal(t, 10, ac.getStatus().Limit)
+
+	// Requests that were in flight together only cause one decrease
+	inFlightStart := time.Now()
+	assert.NoError(t, ac.acquire(ctx))
+	assert.NoError(t, ac.acquire(ctx))
+	ac.release(ctx, inFlightStart, &rpcbackend.RPCError{Code: 429})
+	ac.release(ctx, inFlightStart, &rpcbackend.RPCError{Code: 429})
+	assert.Equal(t, 5, ac.getStatus().Limit)
+
+	// Down to the minimum
+	_ = ac.CallRPC(ctx, &chainID, "eth_chainId")
+	assert.Equal(t, 4, ac.getStatus().Limit)
+
+	// Other errors from the node show it is keeping up
+	ac.release(ctx, time.Now(), &rpcbackend.RPCError{Code: -32000, Message: "execution reverted"})
+	assert.Greater(t, ac.limit, 4.0)
+
+}
+
+func TestAdaptiveConcurrencyLatencyTarget(t *testing.T) {
+
+	ac, _ := newTestAdaptiveConcurrency(t, func(conf config.Section) {
+		conf.Set(AdaptiveConcurrencyInitial, 10)
+		conf.Set(AdaptiveConcurrencyLatency, "100ms")
+	})
+
+	ctx := context.Background()
+	assert.NoError(t, ac.acquire(ctx))
+	ac.release(ctx, time.Now().Add(-50*time.Millisecond), nil)
+	assert.Equal(t, 10, ac.getStatus().Limit)
+	assert.NoError(t, ac.acquire(ctx))
+	ac.release(ctx, time.Now().Add(-200*time.Millisecond), nil)
+	assert.Equal(t, 5, ac.getStatus().Limit)
+
+	// Cancelled requests do not change the limit
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.NoError(t, ac.acquire(ctx))
+	ac.release(cancelledCtx, time.Now().Add(-1*time.Hour), nil)
+	assert.Equal(t, 5, ac.getStatus().Limit)
+
+}
+
+func TestAdaptiveConcurrencyWaitForSlot(t *testing.T) {
+
+	ac, mRPC := newTestAdaptiveConcurrency(t, func(conf config.Section) {
+		conf.Set(AdaptiveConcurrencyInitial, 1)
+	})
+	mRPC.On("SyncRequest", mock.Anything, mock.Anything).Return(&rpcbackend.RPCResponse{Result: fftypes.JSONAnyPtr(`"0x539"`)}, nil)
+
+	ctx := context.Background()
+	assert.NoError(t, ac.acquire(ctx))
+	assert.Equal(t, 1, ac.getStatus().InFlight)
+
+	// Waiters give up when their context is cancelled
+	cancelledCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	rpcErr := ac.CallRPC(cancelledCtx, nil, "eth_chainId")
+	assert.Regexp(t, "FF00154", rpcErr.Message)
+	_, err := ac.SyncRequest(cancelledCtx, &rpcbackend.RPCRequest{Method: "eth_chainId"})
+	assert.Regexp(t, "FF00154", err)
+
+	// Or proceed when a slot is released
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, err := ac.SyncRequest(ctx, &rpcbackend.RPCRequest{Method: "eth_chainId"})
+		assert.NoError(t, err)
+		assert.Equal(t, `"0x539"`, res.Result.String())
+	}()
+	time.Sleep(10 * time.Millisecond)
+	ac.release(ctx, time.Now(), nil)
+	<-done
+
+}
+
+func TestAdaptiveConcurrencyHTTP429(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`Too Many Requests`))
+	}))
+	defer server.Close()
+
+	conf := newTestAuthConf(t, server.URL)
+	conf.Set(AdaptiveConcurrencyEnabled, true)
+	conf.Set(AdaptiveConcurrencyInitial, 16)
+	cc, err := NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+
+	var chainID string
+	rpcErr := c.backend.CallRPC(context.Background(), &chainID, "eth_chainId")
+	assert.Regexp(t, "FF22012.*429", rpcErr.Message)
+	assert.Equal(t, 8, c.adaptiveConcurrency.getStatus().Limit)
+
+}
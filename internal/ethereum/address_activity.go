@@ -0,0 +1,192 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const (
+	AddressActivityIn   = "in"   // the watched address is the recipient of the transaction
+	AddressActivityOut  = "out"  // the watched address is the sender of the transaction
+	AddressActivitySelf = "self" // the watched address sent the transaction to itself
+)
+
+// AddressActivityEvent is pushed to an address activity listener for each transaction in a new block that is sent
+// from, or to, one of the watched addresses. The consumer remains responsible for tracking confirmations, as the block
+// might yet be re-org'd.
+type AddressActivityEvent struct {
+	Address          string            `json:"address"`                // the watched address
+	Direction        string            `json:"direction"`              // in, out or self
+	Counterparty     string            `json:"counterparty,omitempty"` // the other party - omitted for a contract deployment
+	Value            *fftypes.FFBigInt `json:"value"`
+	TransactionHash  string            `json:"transactionHash"`
+	TransactionIndex *fftypes.FFBigInt `json:"transactionIndex"`
+	BlockNumber      *fftypes.FFBigInt `json:"blockNumber"`
+	BlockHash        string            `json:"blockHash"`
+	ContractCall     bool              `json:"contractCall"` // true if the transaction has input data
+}
+
+type AddressActivityListenerRequest struct {
+	ID               *fftypes.UUID                // unique identifier for this listener
+	ListenerContext  context.Context              // context that will be cancelled when the listener is no longer required
+	Addresses        []*ethtypes.Address0xHex     // the addresses to watch
+	IncludeZeroValue bool                         // also notify transactions that do not transfer any value, such as contract calls
+	AddressActivity  chan<- *AddressActivityEvent // channel to deliver events to
+}
+
+type AddressActivityListenerResponse struct {
+}
+
+// addressActivityListener reads each new block with its full transactions, to find the native transfers to and from
+// the watched addresses, which unlike token transfers do not emit logs. Value moved by internal calls of contracts is
+// not visible in the block, so is not notified.
+type addressActivityListener struct {
+	id               *fftypes.UUID
+	ctx              context.Context
+	c                *ethConnector
+	addresses        map[ethtypes.Address0xHex]bool
+	includeZeroValue bool
+	events           chan<- *AddressActivityEvent
+	blockUpdates     chan *ffcapi.BlockHashEvent
+}
+
+// NewAddressActivityListener registers a listener for the transactions sent from, or to, a set of addresses
+// in each new block
+func (c *ethConnector) NewAddressActivityListener(ctx context.Context, req *AddressActivityListenerRequest) (*AddressActivityListenerResponse, ffcapi.ErrorReason, error) {
+	if len(req.Addresses) == 0 {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgNoActivityAddresses)
+	}
+	al := &addressActivityListener{
+		id:               req.ID,
+		ctx:              req.ListenerContext,
+		c:                c,
+		addresses:        make(map[ethtypes.Address0xHex]bool, len(req.Addresses)),
+		includeZeroValue: req.IncludeZeroValue,
+		events:           req.AddressActivity,
+		blockUpdates:     make(chan *ffcapi.BlockHashEvent, 1),
+	}
+	for _, a := range req.Addresses {
+		al.addresses[*a] = true
+	}
+
+	c.blockListener.addConsumer(&blockUpdateConsumer{
+		id:      req.ID,
+		ctx:     req.ListenerContext,
+		updates: al.blockUpdates,
+	})
+	go al.run()
+
+	return &AddressActivityListenerResponse{}, "", nil
+}
+
+func (al *addressActivityListener) run() {
+	for {
+		select {
+		case update := <-al.blockUpdates:
+			if !al.checkBlocks(update.BlockHashes) {
+				return
+			}
+		case <-al.ctx.Done():
+			log.L(al.ctx).Debugf("Address activity listener %s closed", al.id)
+			return
+		}
+	}
+}
+
+func (al *addressActivityListener) checkBlocks(blockHashes []string) bool {
+	for _, blockHash := range blockHashes {
+		if al.c.addressActivitySparseScan && al.isEmptyBlock(blockHash) {
+			al.c.addressActivitySkipped.Add(1)
+			continue
+		}
+		al.c.addressActivityScanned.Add(1)
+		var block *blockTransactionsJSONRPC
+		if rpcErr := al.c.backend.CallRPC(al.ctx, &block, "eth_getBlockByHash", blockHash, true /* full transactions */); rpcErr != nil || block == nil {
+			log.L(al.ctx).Warnf("Block '%s' not available for address activity checks: %v", blockHash, rpcErr)
+			continue
+		}
+		for _, tx := range block.Transactions {
+			for _, event := range al.transactionActivity(tx) {
+				event.BlockNumber = (*fftypes.FFBigInt)(block.Number)
+				event.BlockHash = blockHash
+				log.L(al.ctx).Debugf("Address activity listener %s notifying %s transaction %s for %s", al.id, event.Direction, event.TransactionHash, event.Address)
+				select {
+				case al.events <- event:
+				case <-al.ctx.Done():
+					log.L(al.ctx).Debugf("Address activity listener %s closed", al.id)
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// isEmptyBlock checks the transaction count of a block, which is much cheaper for the node to return than the block
+// with its full transactions. The hash is used rather than the number, as the block might have been re-org'd.
+// If the count is not available the block is read in full.
+func (al *addressActivityListener) isEmptyBlock(blockHash string) bool {
+	var txCount *ethtypes.HexInteger
+	if rpcErr := al.c.backend.CallRPC(al.ctx, &txCount, "eth_getBlockTransactionCountByHash", blockHash); rpcErr != nil || txCount == nil {
+		log.L(al.ctx).Debugf("Transaction count of block '%s' not available: %v", blockHash, rpcErr)
+		return false
+	}
+	return txCount.BigInt().Sign() == 0
+}
+
+// transactionActivity returns an event for each watched address that sent or received the transaction
+func (al *addressActivityListener) transactionActivity(tx *txInfoJSONRPC) []*AddressActivityEvent {
+	if !al.includeZeroValue && (tx.Value == nil || tx.Value.BigInt().Sign() == 0) {
+		return nil
+	}
+	fromWatched := tx.From != nil && al.addresses[*tx.From]
+	toWatched := tx.To != nil && al.addresses[*tx.To]
+	newEvent := func(address *ethtypes.Address0xHex, direction string, counterparty *ethtypes.Address0xHex) *AddressActivityEvent {
+		event := &AddressActivityEvent{
+			Address:          address.String(),
+			Direction:        direction,
+			Value:            (*fftypes.FFBigInt)(tx.Value),
+			TransactionHash:  tx.Hash.String(),
+			TransactionIndex: (*fftypes.FFBigInt)(tx.TransactionIndex),
+			ContractCall:     len(tx.Input) > 0,
+		}
+		if counterparty != nil {
+			event.Counterparty = counterparty.String()
+		}
+		return event
+	}
+	switch {
+	case fromWatched && toWatched && *tx.From == *tx.To:
+		return []*AddressActivityEvent{newEvent(tx.From, AddressActivitySelf, tx.To)}
+	case fromWatched && toWatched:
+		return []*AddressActivityEvent{newEvent(tx.From, AddressActivityOut, tx.To), newEvent(tx.To, AddressActivityIn, tx.From)}
+	case fromWatched:
+		return []*AddressActivityEvent{newEvent(tx.From, AddressActivityOut, tx.To)}
+	case toWatched:
+		return []*AddressActivityEvent{newEvent(tx.To, AddressActivityIn, tx.From)}
+	default:
+		return nil
+	}
+}
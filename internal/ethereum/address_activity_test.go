@@ -0,0 +1,200 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testTreasury = "0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4"
+	testPayee    = "0xd0f2f5103fd050739a9fb567251bc460cc24d091"
+	testOther    = "0x20355f3e852d4b6a9944ada8d5399ddd3409a431"
+)
+
+func newTestAddressActivityListener(t *testing.T, includeZeroValue bool) (*addressActivityListener, chan *AddressActivityEvent, func()) {
+	ctx, c, mRPC, done := newTestConnector(t)
+	events := make(chan *AddressActivityEvent, 10)
+	al := &addressActivityListener{
+		id:  fftypes.NewUUID(),
+		ctx: ctx,
+		c:   c,
+		addresses: map[ethtypes.Address0xHex]bool{
+			*ethtypes.MustNewAddress(testTreasury): true,
+			*ethtypes.MustNewAddress(testPayee):    true,
+		},
+		includeZeroValue: includeZeroValue,
+		events:           events,
+		blockUpdates:     make(chan *ffcapi.BlockHashEvent, 1),
+	}
+	testTX := func(i int64, from, to string, value int64, input string) *txInfoJSONRPC {
+		tx := &txInfoJSONRPC{
+			Hash:             ethtypes.MustNewHexBytes0xPrefix(testBlockHash(5000 + i)),
+			TransactionIndex: ethtypes.NewHexInteger64(i),
+			From:             ethtypes.MustNewAddress(from),
+			Value:            ethtypes.NewHexInteger64(value),
+			Input:            ethtypes.MustNewHexBytes0xPrefix(input),
+		}
+		if to != "" {
+			tx.To = ethtypes.MustNewAddress(to)
+		}
+		return tx
+	}
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", testBlockHash(1001), true).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockTransactionsJSONRPC) = &blockTransactionsJSONRPC{
+			Number: ethtypes.NewHexInteger64(1001),
+			Transactions: []*txInfoJSONRPC{
+				testTX(0, testOther, testTreasury, 1000, "0x"),
+				testTX(1, testTreasury, testPayee, 250, "0x"),
+				testTX(2, testTreasury, testOther, 0, "0xfeedbeef"),
+				testTX(3, testPayee, testPayee, 1, "0x"),
+				testTX(4, testOther, testOther, 1, "0x"),
+				testTX(5, testTreasury, "", 5, "0xfeedbeef"),
+			},
+		}
+	}).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", testBlockHash(1002), true).Return(&rpcbackend.RPCError{Message: "pop"}).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", testBlockHash(1003), true).Return(nil).Maybe()
+	return al, events, done
+}
+
+func TestAddressActivityListenerNotifiesTransfers(t *testing.T) {
+
+	al, events, done := newTestAddressActivityListener(t, false)
+	defer done()
+
+	assert.True(t, al.checkBlocks([]string{testBlockHash(1001), testBlockHash(1002), testBlockHash(1003)}))
+
+	e := <-events
+	assert.Equal(t, testTreasury, e.Address)
+	assert.Equal(t, AddressActivityIn, e.Direction)
+	assert.Equal(t, testOther, e.Counterparty)
+	assert.Equal(t, int64(1000), e.Value.Int64())
+	assert.Equal(t, int64(1001), e.BlockNumber.Int64())
+	assert.Equal(t, testBlockHash(1001), e.BlockHash)
+	assert.Equal(t, testBlockHash(5000), e.TransactionHash)
+	assert.False(t, e.ContractCall)
+
+	// A transfer between two watched addresses is notified for each
+	e = <-events
+	assert.Equal(t, testTreasury, e.Address)
+	assert.Equal(t, AddressActivityOut, e.Direction)
+	assert.Equal(t, testPayee, e.Counterparty)
+	e = <-events
+	assert.Equal(t, testPayee, e.Address)
+	assert.Equal(t, AddressActivityIn, e.Direction)
+	assert.Equal(t, testTreasury, e.Counterparty)
+
+	e = <-events
+	assert.Equal(t, AddressActivitySelf, e.Direction)
+	assert.Equal(t, int64(3), e.TransactionIndex.Int64())
+
+	// A contract deployment with value has no counterparty
+	e = <-events
+	assert.Equal(t, AddressActivityOut, e.Direction)
+	assert.Empty(t, e.Counterparty)
+	assert.True(t, e.ContractCall)
+	assert.Empty(t, events)
+
+}
+
+func TestAddressActivityListenerIncludeZeroValue(t *testing.T) {
+
+	al, events, done := newTestAddressActivityListener(t, true)
+	defer done()
+
+	assert.True(t, al.checkBlocks([]string{testBlockHash(1001)}))
+	assert.Len(t, events, 6)
+
+}
+
+func TestAddressActivityListenerSparseScan(t *testing.T) {
+
+	al, events, done := newTestAddressActivityListener(t, false)
+	defer done()
+	al.c.addressActivitySparseScan = true
+
+	mRPC := al.c.backend.(*rpcbackendmocks.Backend)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockTransactionCountByHash", testBlockHash(1001)).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**ethtypes.HexInteger) = ethtypes.NewHexInteger64(6)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockTransactionCountByHash", testBlockHash(1004)).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**ethtypes.HexInteger) = ethtypes.NewHexInteger64(0)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockTransactionCountByHash", testBlockHash(1002)).Return(&rpcbackend.RPCError{Message: "pop"})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockTransactionCountByHash", testBlockHash(1003)).Return(nil)
+
+	assert.True(t, al.checkBlocks([]string{testBlockHash(1001), testBlockHash(1004), testBlockHash(1002), testBlockHash(1003)}))
+	assert.Len(t, events, 5)
+	assert.Equal(t, int64(1), al.c.addressActivitySkipped.Load())
+	assert.Equal(t, int64(3), al.c.addressActivityScanned.Load())
+
+	// The empty block is never read with its full transactions
+	mRPC.AssertNotCalled(t, "CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", testBlockHash(1004), true)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil)
+	status, _, err := al.c.IsReady(al.ctx)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"blocksScanned":3,"blocksSkipped":1}`, status.DownstreamDetails.JSONObject().GetObject("addressActivity").String())
+
+}
+
+func TestAddressActivityListenerClosed(t *testing.T) {
+
+	al, _, done := newTestAddressActivityListener(t, false)
+	al.events = make(chan *AddressActivityEvent)
+
+	al.blockUpdates <- &ffcapi.BlockHashEvent{BlockHashes: []string{testBlockHash(1001)}}
+	done()
+	al.run()
+	assert.False(t, al.checkBlocks([]string{testBlockHash(1001)}))
+
+}
+
+func TestNewAddressActivityListener(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.NewAddressActivityListener(ctx, &AddressActivityListenerRequest{})
+	assert.Regexp(t, "FF23149", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_newBlockFilter").Return(nil).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", mock.Anything).Return(nil).Maybe()
+	listenerCtx, cancelListener := context.WithCancel(ctx)
+	defer cancelListener()
+	res, _, err := c.NewAddressActivityListener(ctx, &AddressActivityListenerRequest{
+		ID:              fftypes.NewUUID(),
+		ListenerContext: listenerCtx,
+		Addresses:       []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testTreasury)},
+		AddressActivity: make(chan *AddressActivityEvent),
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, res)
+
+}
@@ -0,0 +1,95 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// checksumAddressComponent presents an address to the ABI serializer as a string, because the serializer
+// formats addresses as lowercase hex bytes - with no way to distinguish them from other bytes20 values
+type checksumAddressComponent struct {
+	abi.TypeComponent
+}
+
+func (checksumAddressComponent) ElementaryType() abi.ElementaryTypeInfo {
+	return abi.ElementaryTypeString
+}
+
+// checksumABIAddresses updates a decoded value tree, so that all addresses within it are serialized
+// with an EIP-55 mixed-case checksum
+func checksumABIAddresses(cv *abi.ComponentValue) {
+	if cv.Component.ComponentType() == abi.ElementaryComponent && cv.Component.ElementaryType() == abi.ElementaryTypeAddress {
+		var addr ethtypes.AddressWithChecksum
+		cv.Value.(*big.Int).FillBytes(addr[:])
+		cv.Value = addr.String()
+		cv.Component = checksumAddressComponent{cv.Component}
+	}
+	for _, child := range cv.Children {
+		checksumABIAddresses(child)
+	}
+}
+
+func checksumAddress(a *ethtypes.Address0xHex) *ethtypes.AddressWithChecksum {
+	return (*ethtypes.AddressWithChecksum)(a)
+}
+
+// formatAddress returns an address for inclusion in a response, with a checksum if configured
+func (c *ethConnector) formatAddress(a *ethtypes.Address0xHex) string {
+	if c.checksumAddresses {
+		return checksumAddress(a).String()
+	}
+	return a.String()
+}
+
+func (ei *eventInfo) MarshalJSON() ([]byte, error) {
+	type eventInfoJSON eventInfo // without this method, to avoid recursion
+	if !ei.checksumAddresses {
+		return json.Marshal((*eventInfoJSON)(ei))
+	}
+	return json.Marshal(&struct {
+		*eventInfoJSON
+		Address     *ethtypes.AddressWithChecksum `json:"address"`
+		InputSigner *ethtypes.AddressWithChecksum `json:"inputSigner,omitempty"`
+	}{
+		eventInfoJSON: (*eventInfoJSON)(ei),
+		Address:       checksumAddress(ei.Address),
+		InputSigner:   checksumAddress(ei.InputSigner),
+	})
+}
+
+func (ri *receiptExtraInfo) MarshalJSON() ([]byte, error) {
+	type receiptExtraInfoJSON receiptExtraInfo // without this method, to avoid recursion
+	if !ri.checksumAddresses {
+		return json.Marshal((*receiptExtraInfoJSON)(ri))
+	}
+	return json.Marshal(&struct {
+		*receiptExtraInfoJSON
+		ContractAddress *ethtypes.AddressWithChecksum `json:"contractAddress"`
+		From            *ethtypes.AddressWithChecksum `json:"from"`
+		To              *ethtypes.AddressWithChecksum `json:"to"`
+	}{
+		receiptExtraInfoJSON: (*receiptExtraInfoJSON)(ri),
+		ContractAddress:      checksumAddress(ri.ContractAddress),
+		From:                 checksumAddress(ri.From),
+		To:                   checksumAddress(ri.To),
+	})
+}
@@ -0,0 +1,224 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChecksumABIAddressesNested(t *testing.T) {
+
+	params := abi.ParameterArray{
+		{Name: "owner", Type: "address"},
+		{Name: "holders", Type: "address[]"},
+		{Name: "grant", Type: "tuple", Components: abi.ParameterArray{
+			{Name: "to", Type: "address"},
+			{Name: "id", Type: "bytes20"},
+		}},
+	}
+	cv, err := params.ParseJSON([]byte(`{
+		"owner": "0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4",
+		"holders": ["0xd0f2f5103fd050739a9fb567251bc460cc24d091"],
+		"grant": {
+			"to": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431",
+			"id": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431"
+		}
+	}`))
+	assert.NoError(t, err)
+
+	checksumABIAddresses(cv)
+	b, err := abi.NewSerializer().SerializeJSON(cv)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"owner": "0x3968eF051b422D3D1CdC182A88BBA8dD922e6Fa4",
+		"holders": ["0xD0f2f5103Fd050739a9FB567251bC460CC24D091"],
+		"grant": {
+			"to": "0x20355f3E852D4b6a9944AdA8d5399dDD3409A431",
+			"id": "20355f3e852d4b6a9944ada8d5399ddd3409a431"
+		}
+	}`, string(b))
+
+}
+
+func TestEventInfoMarshalChecksumAddresses(t *testing.T) {
+
+	ei := &eventInfo{
+		logJSONRPC: logJSONRPC{
+			Address: ethtypes.MustNewAddress("0x20355f3e852d4b6a9944ada8d5399ddd3409a431"),
+		},
+		InputSigner: ethtypes.MustNewAddress("0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4"),
+	}
+	b, err := json.Marshal(ei)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"address":"0x20355f3e852d4b6a9944ada8d5399ddd3409a431"`)
+	assert.Contains(t, string(b), `"inputSigner":"0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4"`)
+
+	ei.checksumAddresses = true
+	b, err = json.Marshal(ei)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"address":"0x20355f3E852D4b6a9944AdA8d5399dDD3409A431"`)
+	assert.Contains(t, string(b), `"inputSigner":"0x3968eF051b422D3D1CdC182A88BBA8dD922e6Fa4"`)
+
+	ei.InputSigner = nil
+	b, err = json.Marshal(ei)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), `inputSigner`)
+
+}
+
+func TestListenerChecksumAddressesOption(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ChecksumAddresses, true)
+	})
+	mockStreamLoopEmpty(mRPC)
+	newListener := func(options string) *ffcapi.EventListenerAddRequest {
+		return &ffcapi.EventListenerAddRequest{
+			ListenerID: fftypes.NewUUID(),
+			EventListenerOptions: ffcapi.EventListenerOptions{
+				Filters: []fftypes.JSONAny{
+					*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`),
+				},
+				Options:   fftypes.JSONAnyPtr(options),
+				FromBlock: strconv.Itoa(testHighBlock),
+			},
+		}
+	}
+	l1, l2 := newListener(`{}`), newListener(`{"checksumAddresses":false}`)
+	es, _, _, done := testEventStreamExistingConnector(t, ctx, done, c, mRPC, l1, l2)
+	defer done()
+
+	assert.True(t, es.listeners[*l1.ListenerID].ee.checksumAddresses)
+	assert.False(t, es.listeners[*l2.ListenerID].ee.checksumAddresses)
+
+}
+
+func TestDecodeLogDataChecksumAddresses(t *testing.T) {
+
+	l, _, _ := newTestListener(t, false)
+	l.ee.checksumAddresses = true
+
+	var abiEvent *abi.Entry
+	err := json.Unmarshal([]byte(abiTransferEvent), &abiEvent)
+	assert.NoError(t, err)
+	ethLog := sampleTransferLog()
+
+	res, decoded := l.ee.decodeLogData(l.es.ctx, abiEvent, ethLog.Topics, ethLog.Data)
+	assert.True(t, decoded)
+	assert.JSONEq(t, `{
+		"from": "0x3968eF051b422D3D1CdC182A88BBA8dD922e6Fa4",
+		"to": "0xD0f2f5103Fd050739a9FB567251bC460CC24D091",
+		"value": "1000"
+	}`, res.String())
+
+}
+
+func TestFilterEnrichEthLogMethodInputsChecksumAddresses(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, true)
+	l.ee.checksumAddresses = true
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1024),
+		}
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**txInfoJSONRPC) = &txInfoJSONRPC{
+			From:  ethtypes.MustNewAddress("0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4"),
+			Input: ethtypes.MustNewHexBytes0xPrefix("0xa9059cbb000000000000000000000000d0f2f5103fd050739a9fb567251bc460cc24d09100000000000000000000000000000000000000000000000000000000000003e8"),
+		}
+	})
+
+	ev, ok, err := l.filterEnrichEthLog(context.Background(), l.config.filters[0], l.config.options.Methods, sampleTransferLog())
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	ei := ev.Event.Info.(*eventInfo)
+	assert.Equal(t, `{"_to":"0xD0f2f5103Fd050739a9FB567251bC460CC24D091","_value":"1000"}`, ei.InputArgs.String())
+
+	b, err := json.Marshal(ei)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"address":"0x20355f3E852D4b6a9944AdA8d5399dDD3409A431"`)
+	assert.Contains(t, string(b), `"inputSigner":"0x3968eF051b422D3D1CdC182A88BBA8dD922e6Fa4"`)
+
+}
+
+func TestGetReceiptChecksumAddresses(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ChecksumAddresses, true)
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte(sampleJSONRPCReceipt), args[1])
+			assert.NoError(t, err)
+		})
+
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+	req.IncludeLogs = true
+	res, _, err := c.TransactionReceipt(ctx, &req)
+	assert.NoError(t, err)
+
+	assert.JSONEq(t, `{"address":"0x87ae94ab290932C4E6269648bB47c86978aF4436"}`, res.ContractLocation.String())
+	extraInfo := res.ExtraInfo.String()
+	assert.Contains(t, extraInfo, `"contractAddress":"0x87ae94ab290932C4E6269648bB47c86978aF4436"`)
+	assert.Contains(t, extraInfo, `"from":"0x2B1C769ef5Ad304A4889f2A07a6617cd935849aE"`)
+	assert.Contains(t, extraInfo, `"to":"0x302259069AAA5b10dC6f29a9A3F72A8e52837cC3"`)
+	assert.Contains(t, string(res.Logs[0]), `"address":"0x302259069AAA5b10dC6f29a9A3F72A8e52837cC3"`)
+
+}
+
+func TestExecQueryChecksumAddresses(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	c.checksumAddresses = true
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.AnythingOfType("*ethsigner.Transaction"), "latest").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x0000000000000000000000003968ef051b422d3d1cdc182a88bba8dd922e6fa4")
+		}).
+		Return(nil)
+
+	res, _, err := c.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				To: "0x20355f3e852d4b6a9944ada8d5399ddd3409a431",
+			},
+			Method: fftypes.JSONAnyPtr(`{"name":"owner","type":"function","inputs":[],"outputs":[{"name":"","type":"address"}]}`),
+		},
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"output":"0x3968eF051b422D3D1CdC182A88BBA8dD922e6Fa4"}`, res.Outputs.String())
+
+}
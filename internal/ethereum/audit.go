@@ -0,0 +1,373 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type AuditRecordType string
+
+const (
+	AuditRecordOperation AuditRecordType = "operation" // an FFCAPI operation
+	AuditRecordRPC       AuditRecordType = "rpc"       // a JSON/RPC call to the node
+)
+
+// AuditRecord is a line of the audit log. Calldata never appears in a record, except in the request of an
+// operation when that is enabled, and then only with the configured fields redacted.
+type AuditRecord struct {
+	Time       *fftypes.FFTime `json:"time"`
+	Type       AuditRecordType `json:"type"`
+	RequestID  string          `json:"requestId,omitempty"`
+	Operation  string          `json:"operation,omitempty"`
+	Method     string          `json:"method,omitempty"`
+	ParamsHash string          `json:"paramsHash,omitempty"`
+	LatencyMS  float64         `json:"latencyMs"`
+	Outcome    string          `json:"outcome"`
+	Reason     string          `json:"reason,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Request    interface{}     `json:"request,omitempty"`
+}
+
+type auditOperationKey struct{}
+
+// auditor writes the audit records to a JSONL file, which is rotated at a maximum size, or to the log
+type auditor struct {
+	ctx             context.Context
+	mux             sync.Mutex
+	path            string // empty to write to the log
+	maxSize         int64
+	maxBackups      int
+	file            *os.File
+	size            int64
+	includeRequests bool
+	redactFields    map[string]bool
+}
+
+func (c *ethConnector) initAudit(ctx context.Context, conf config.Section) error {
+	if !conf.GetBool(AuditEnabled) {
+		return nil
+	}
+	a := &auditor{
+		ctx:             ctx,
+		path:            conf.GetString(AuditFile),
+		maxSize:         conf.GetByteSize(AuditMaxSize),
+		maxBackups:      conf.GetInt(AuditMaxBackups),
+		includeRequests: conf.GetBool(AuditIncludeRequests),
+		redactFields:    make(map[string]bool),
+	}
+	for _, field := range conf.GetStringSlice(AuditRedactFields) {
+		a.redactFields[field] = true
+	}
+	if a.path != "" {
+		if err := os.MkdirAll(filepath.Dir(a.path), 0700); err != nil {
+			return i18n.WrapError(ctx, err, msgs.MsgAuditFailed, a.path)
+		}
+		if err := a.open(); err != nil {
+			return err
+		}
+	}
+	c.audit = a
+	c.backend = &auditBackend{Backend: c.backend, auditor: a}
+	log.L(ctx).Infof("Audit records enabled (file='%s')", a.path)
+	return nil
+}
+
+func (a *auditor) open() (err error) {
+	a.file, err = os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err == nil {
+		var fi os.FileInfo
+		if fi, err = a.file.Stat(); err == nil {
+			a.size = fi.Size()
+		}
+	}
+	if err != nil {
+		return i18n.WrapError(a.ctx, err, msgs.MsgAuditFailed, a.path)
+	}
+	return nil
+}
+
+// rotate moves the current file to the first backup, shifting the existing backups along and
+// discarding the oldest
+func (a *auditor) rotate() error {
+	_ = a.file.Close()
+	a.file = nil
+	_ = os.Remove(fmt.Sprintf("%s.%d", a.path, a.maxBackups))
+	for i := a.maxBackups - 1; i > 0; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+	}
+	if a.maxBackups > 0 {
+		_ = os.Rename(a.path, a.path+".1")
+	} else {
+		_ = os.Remove(a.path)
+	}
+	return a.open()
+}
+
+func (a *auditor) record(ctx context.Context, record *AuditRecord) {
+	record.Time = fftypes.Now()
+	if requestID, ok := ctx.Value(ffapi.CtxFFRequestIDKey{}).(string); ok {
+		record.RequestID = requestID
+	}
+	if op, ok := ctx.Value(auditOperationKey{}).(string); ok && record.Type == AuditRecordRPC {
+		record.Operation = op
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		// Only the request can fail to marshal, such as the channels of an event stream
+		record.Request = nil
+		b, _ = json.Marshal(record)
+	}
+	if a.path == "" {
+		log.L(ctx).WithField("audit", true).Info(string(b))
+		return
+	}
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	b = append(b, '\n')
+	if a.file == nil {
+		// re-open after a previous failure
+		err = a.open()
+	} else if a.size > 0 && a.size+int64(len(b)) > a.maxSize {
+		err = a.rotate()
+	}
+	if err == nil {
+		_, err = a.file.Write(b)
+		a.size += int64(len(b))
+	}
+	if err != nil {
+		// Auditing must not block the operation being audited, so failures are logged and the record is dropped
+		log.L(ctx).Errorf("Failed to write audit record to '%s': %s", a.path, err)
+	}
+}
+
+func (a *auditor) close() {
+	if a == nil {
+		return
+	}
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.file != nil {
+		_ = a.file.Close()
+		a.file = nil
+	}
+}
+
+func auditHash(v interface{}) string {
+	b, _ := json.Marshal(v)
+	h := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+// redactRequest returns the request as generic JSON, with the value of each redacted field replaced by
+// a hash, so that it can be correlated across records without being disclosed
+func (a *auditor) redactRequest(req interface{}) interface{} {
+	if !a.includeRequests {
+		return nil
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	_ = json.Unmarshal(b, &generic)
+	return a.redact(generic)
+}
+
+func (a *auditor) redact(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range vt {
+			if a.redactFields[k] {
+				vt[k] = auditHash(fv)
+			} else {
+				vt[k] = a.redact(fv)
+			}
+		}
+	case []interface{}:
+		for i, iv := range vt {
+			vt[i] = a.redact(iv)
+		}
+	}
+	return v
+}
+
+func auditOutcome(record *AuditRecord, err error) {
+	if err != nil {
+		record.Outcome = "error"
+		record.Error = err.Error()
+	} else {
+		record.Outcome = "success"
+	}
+}
+
+// auditBackend wraps the JSON/RPC backend to record each call, with a hash of its parameters
+type auditBackend struct {
+	rpcbackend.Backend
+	auditor *auditor
+}
+
+func (ab *auditBackend) CallRPC(ctx context.Context, result interface{}, method string, params ...interface{}) *rpcbackend.RPCError {
+	start := time.Now()
+	rpcErr := ab.Backend.CallRPC(ctx, result, method, params...)
+	ab.recordRPC(ctx, start, method, params, rpcErr)
+	return rpcErr
+}
+
+func (ab *auditBackend) SyncRequest(ctx context.Context, rpcReq *rpcbackend.RPCRequest) (*rpcbackend.RPCResponse, error) {
+	start := time.Now()
+	rpcRes, err := ab.Backend.SyncRequest(ctx, rpcReq)
+	var rpcErr *rpcbackend.RPCError
+	if err != nil {
+		rpcErr = &rpcbackend.RPCError{Message: err.Error()}
+	}
+	ab.recordRPC(ctx, start, rpcReq.Method, rpcReq.Params, rpcErr)
+	return rpcRes, err
+}
+
+func (ab *auditBackend) recordRPC(ctx context.Context, start time.Time, method string, params interface{}, rpcErr *rpcbackend.RPCError) {
+	record := &AuditRecord{
+		Type:       AuditRecordRPC,
+		Method:     method,
+		ParamsHash: auditHash(params),
+		LatencyMS:  float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if rpcErr != nil {
+		auditOutcome(record, rpcErr.Error())
+	} else {
+		auditOutcome(record, nil)
+	}
+	ab.auditor.record(ctx, record)
+}
+
+// auditedConnector is returned in place of the connector when auditing is enabled, to record each FFCAPI
+// operation. The operation is added to the context, so the JSON/RPC calls made for it are attributed to it.
+// Everything other than the FFCAPI operations, such as the connector specific operations, passes through.
+type auditedConnector struct {
+	*ethConnector
+}
+
+func auditOperation[Req, Res any](ac *auditedConnector, ctx context.Context, op string, req Req, fn func(context.Context, Req) (Res, ffcapi.ErrorReason, error)) (Res, ffcapi.ErrorReason, error) {
+	ctx = context.WithValue(ctx, auditOperationKey{}, op)
+	start := time.Now()
+	res, reason, err := fn(ctx, req)
+	record := &AuditRecord{
+		Type:      AuditRecordOperation,
+		Operation: op,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Reason:    string(reason),
+		Request:   ac.audit.redactRequest(req),
+	}
+	auditOutcome(record, err)
+	ac.audit.record(ctx, record)
+	return res, reason, err
+}
+
+func (ac *auditedConnector) AddressBalance(ctx context.Context, req *ffcapi.AddressBalanceRequest) (*ffcapi.AddressBalanceResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "AddressBalance", req, ac.ethConnector.AddressBalance)
+}
+
+func (ac *auditedConnector) BlockInfoByHash(ctx context.Context, req *ffcapi.BlockInfoByHashRequest) (*ffcapi.BlockInfoByHashResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "BlockInfoByHash", req, ac.ethConnector.BlockInfoByHash)
+}
+
+func (ac *auditedConnector) BlockInfoByNumber(ctx context.Context, req *ffcapi.BlockInfoByNumberRequest) (*ffcapi.BlockInfoByNumberResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "BlockInfoByNumber", req, ac.ethConnector.BlockInfoByNumber)
+}
+
+func (ac *auditedConnector) NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (*ffcapi.NextNonceForSignerResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "NextNonceForSigner", req, ac.ethConnector.NextNonceForSigner)
+}
+
+func (ac *auditedConnector) GasEstimate(ctx context.Context, req *ffcapi.TransactionInput) (*ffcapi.GasEstimateResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "GasEstimate", req, ac.ethConnector.GasEstimate)
+}
+
+func (ac *auditedConnector) GasPriceEstimate(ctx context.Context, req *ffcapi.GasPriceEstimateRequest) (*ffcapi.GasPriceEstimateResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "GasPriceEstimate", req, ac.ethConnector.GasPriceEstimate)
+}
+
+func (ac *auditedConnector) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (*ffcapi.QueryInvokeResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "QueryInvoke", req, ac.ethConnector.QueryInvoke)
+}
+
+func (ac *auditedConnector) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (*ffcapi.TransactionReceiptResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "TransactionReceipt", req, ac.ethConnector.TransactionReceipt)
+}
+
+func (ac *auditedConnector) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "TransactionPrepare", req, ac.ethConnector.TransactionPrepare)
+}
+
+func (ac *auditedConnector) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "TransactionSend", req, ac.ethConnector.TransactionSend)
+}
+
+func (ac *auditedConnector) DeployContractPrepare(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "DeployContractPrepare", req, ac.ethConnector.DeployContractPrepare)
+}
+
+func (ac *auditedConnector) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (*ffcapi.EventStreamStartResponse, ffcapi.ErrorReason, error) {
+	// Not attributed to the operation in the context, as the stream runs on with the context after it returns
+	return auditOperation(ac, ctx, "EventStreamStart", req, func(_ context.Context, req *ffcapi.EventStreamStartRequest) (*ffcapi.EventStreamStartResponse, ffcapi.ErrorReason, error) {
+		return ac.ethConnector.EventStreamStart(ctx, req)
+	})
+}
+
+func (ac *auditedConnector) EventStreamStopped(ctx context.Context, req *ffcapi.EventStreamStoppedRequest) (*ffcapi.EventStreamStoppedResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "EventStreamStopped", req, ac.ethConnector.EventStreamStopped)
+}
+
+func (ac *auditedConnector) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (*ffcapi.EventListenerVerifyOptionsResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "EventListenerVerifyOptions", req, ac.ethConnector.EventListenerVerifyOptions)
+}
+
+func (ac *auditedConnector) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (*ffcapi.EventListenerAddResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "EventListenerAdd", req, ac.ethConnector.EventListenerAdd)
+}
+
+func (ac *auditedConnector) EventListenerRemove(ctx context.Context, req *ffcapi.EventListenerRemoveRequest) (*ffcapi.EventListenerRemoveResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "EventListenerRemove", req, ac.ethConnector.EventListenerRemove)
+}
+
+func (ac *auditedConnector) EventListenerHWM(ctx context.Context, req *ffcapi.EventListenerHWMRequest) (*ffcapi.EventListenerHWMResponse, ffcapi.ErrorReason, error) {
+	return auditOperation(ac, ctx, "EventListenerHWM", req, ac.ethConnector.EventListenerHWM)
+}
+
+func (ac *auditedConnector) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (*ffcapi.NewBlockListenerResponse, ffcapi.ErrorReason, error) {
+	// Not attributed to the operation in the context, as the listener runs on with the context after it returns
+	return auditOperation(ac, ctx, "NewBlockListener", req, func(_ context.Context, req *ffcapi.NewBlockListenerRequest) (*ffcapi.NewBlockListenerResponse, ffcapi.ErrorReason, error) {
+		return ac.ethConnector.NewBlockListener(ctx, req)
+	})
+}
@@ -0,0 +1,212 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestAuditedConnector(t *testing.T, confSetup func(conf config.Section)) (context.Context, *auditedConnector, *rpcbackendmocks.Backend, func()) {
+	ctx, c, mRPC, done := newTestConnector(t)
+	conf := config.RootSection("audittest")
+	InitConfig(conf)
+	conf.Set(AuditEnabled, true)
+	confSetup(conf)
+	assert.NoError(t, c.initAudit(ctx, conf))
+	return ctx, &auditedConnector{ethConnector: c}, mRPC, done
+}
+
+func readAuditRecords(t *testing.T, path string) []*AuditRecord {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	var records []*AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record AuditRecord
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, &record)
+	}
+	return records
+}
+
+func TestAuditOperationToFile(t *testing.T) {
+
+	auditFile := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	ctx, ac, mRPC, done := newTestAuditedConnector(t, func(conf config.Section) {
+		conf.Set(AuditFile, auditFile)
+		conf.Set(AuditIncludeRequests, true)
+		conf.Set(AuditRedactFields, []string{"address"})
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBalance", "0x4a8c8f1717570f9774652075e249ded38124d708", "latest").
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			args[1].(*ethtypes.HexInteger).BigInt().SetString("999", 10)
+		}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBalance", "0x4a8c8f1717570f9774652075e249ded38124d708", "latest").
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+
+	var req ffcapi.AddressBalanceRequest
+	assert.NoError(t, json.Unmarshal([]byte(sampleGetBalance), &req))
+	requestCtx := context.WithValue(ctx, ffapi.CtxFFRequestIDKey{}, "abcd1234")
+	_, _, err := ac.AddressBalance(requestCtx, &req)
+	assert.NoError(t, err)
+	_, _, err = ac.AddressBalance(ctx, &req)
+	assert.Regexp(t, "pop", err)
+	ac.audit.close()
+
+	records := readAuditRecords(t, auditFile)
+	assert.Len(t, records, 4)
+
+	assert.Equal(t, AuditRecordRPC, records[0].Type)
+	assert.Equal(t, "abcd1234", records[0].RequestID)
+	assert.Equal(t, "AddressBalance", records[0].Operation)
+	assert.Equal(t, "eth_getBalance", records[0].Method)
+	assert.Equal(t, auditHash([]interface{}{"0x4a8c8f1717570f9774652075e249ded38124d708", "latest"}), records[0].ParamsHash)
+	assert.Equal(t, "success", records[0].Outcome)
+
+	assert.Equal(t, AuditRecordOperation, records[1].Type)
+	assert.Equal(t, "abcd1234", records[1].RequestID)
+	assert.Equal(t, "AddressBalance", records[1].Operation)
+	assert.Empty(t, records[1].Method)
+	assert.Equal(t, "success", records[1].Outcome)
+	assert.Equal(t, auditHash("0x4a8c8f1717570f9774652075e249ded38124d708"), records[1].Request.(map[string]interface{})["address"])
+
+	assert.Equal(t, "error", records[2].Outcome)
+	assert.Equal(t, "pop", records[2].Error)
+	assert.Equal(t, "error", records[3].Outcome)
+	assert.Regexp(t, "pop", records[3].Error)
+
+}
+
+func TestAuditSyncRequestToLog(t *testing.T) {
+
+	ctx, ac, mRPC, done := newTestAuditedConnector(t, func(conf config.Section) {})
+	defer done()
+
+	mRPC.On("SyncRequest", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("pop")).Once()
+	mRPC.On("SyncRequest", mock.Anything, mock.Anything).Return(&rpcbackend.RPCResponse{}, nil).Once()
+
+	_, err := ac.backend.SyncRequest(ctx, &rpcbackend.RPCRequest{Method: "eth_blockNumber"})
+	assert.Regexp(t, "pop", err)
+	_, err = ac.backend.SyncRequest(ctx, &rpcbackend.RPCRequest{Method: "eth_blockNumber"})
+	assert.NoError(t, err)
+
+	// Requests are not included by default, and ones that cannot be marshalled are omitted
+	assert.Nil(t, ac.audit.redactRequest(&ffcapi.EventStreamStartRequest{}))
+	ac.audit.includeRequests = true
+	assert.Nil(t, ac.audit.redactRequest(map[string]interface{}{"bad": map[bool]bool{true: true}}))
+	assert.Equal(t, map[string]interface{}{
+		"nested": []interface{}{map[string]interface{}{"data": auditHash("0x1234")}},
+	}, ac.audit.redactRequest(map[string]interface{}{
+		"nested": []interface{}{map[string]interface{}{"data": "0x1234"}},
+	}))
+	ac.audit.record(ctx, &AuditRecord{Type: AuditRecordOperation, Request: map[bool]bool{true: true}})
+
+}
+
+func TestAuditFileRotation(t *testing.T) {
+
+	auditFile := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx, ac, _, done := newTestAuditedConnector(t, func(conf config.Section) {
+		conf.Set(AuditFile, auditFile)
+		conf.Set(AuditMaxSize, "200b")
+		conf.Set(AuditMaxBackups, 2)
+	})
+	defer done()
+
+	for i := 0; i < 10; i++ {
+		ac.audit.record(ctx, &AuditRecord{Type: AuditRecordRPC, Method: fmt.Sprintf("method%d", i)})
+	}
+	ac.audit.close()
+
+	latest := readAuditRecords(t, auditFile)
+	assert.Equal(t, "method9", latest[len(latest)-1].Method)
+	assert.NotEmpty(t, readAuditRecords(t, auditFile+".1"))
+	assert.NotEmpty(t, readAuditRecords(t, auditFile+".2"))
+	_, err := os.Stat(auditFile + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// Without backups the file is truncated, and a failure to re-open is retried with the next record
+	ac.audit.maxBackups = 0
+	assert.NoError(t, ac.audit.open())
+	ac.audit.path = filepath.Join(auditFile, "notadir")
+	ac.audit.size = ac.audit.maxSize
+	ac.audit.record(ctx, &AuditRecord{Type: AuditRecordRPC, Method: "dropped"})
+	assert.Nil(t, ac.audit.file)
+	ac.audit.record(ctx, &AuditRecord{Type: AuditRecordRPC, Method: "dropped"})
+
+}
+
+func TestAuditInitFail(t *testing.T) {
+
+	dir := t.TempDir()
+	notADir := filepath.Join(dir, "file")
+	assert.NoError(t, os.WriteFile(notADir, []byte{}, 0600))
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+	conf := config.RootSection("audittest")
+	InitConfig(conf)
+	conf.Set(AuditEnabled, true)
+	conf.Set(AuditFile, filepath.Join(notADir, "audit.jsonl"))
+	assert.Regexp(t, "FF23155", c.initAudit(ctx, conf))
+
+	conf.Set(AuditFile, dir)
+	assert.Regexp(t, "FF23155", c.initAudit(ctx, conf))
+
+}
+
+func TestNewEthereumConnectorAudited(t *testing.T) {
+
+	config.RootConfigReset()
+	conf := config.RootSection("unittest")
+	InitConfig(conf)
+	conf.Set(ffresty.HTTPConfigURL, "http://localhost:8545")
+	conf.Set(BlockPollingInterval, "1h")
+	conf.Set(AuditEnabled, true)
+	ctx, done := context.WithCancel(context.Background())
+
+	cc, err := NewEthereumConnector(ctx, conf)
+	assert.NoError(t, err)
+	ac := cc.(*auditedConnector)
+	_, isReloader := cc.(ConfigReloader)
+	assert.True(t, isReloader)
+	assert.IsType(t, &auditBackend{}, ac.backend)
+
+	done()
+	ac.WaitClosed()
+
+}
@@ -0,0 +1,109 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+const (
+	BlockHashQueriesNever  = "never"  // block range queries use fromBlock and toBlock
+	BlockHashQueriesAlways = "always" // block range queries are made per block, pinned to the hash of each block
+	BlockHashQueriesAuto   = "auto"   // pinned once a re-org of at least the configured depth has been seen
+)
+
+type BlockHashQueriesStatus struct {
+	Mode          string `json:"mode"`
+	Active        bool   `json:"active"`
+	MaxReorgDepth int64  `json:"maxReorgDepth"` // the deepest re-org seen by the block listener
+}
+
+func (c *ethConnector) initBlockHashQueries(ctx context.Context, conf config.Section) error {
+	c.blockHashQueries = conf.GetString(EventsBlockHashQueries)
+	c.blockHashReorgDepth = conf.GetInt64(EventsBlockHashReorgDepth)
+	switch c.blockHashQueries {
+	case BlockHashQueriesNever, BlockHashQueriesAlways, BlockHashQueriesAuto:
+		return nil
+	default:
+		return i18n.NewError(ctx, msgs.MsgInvalidBlockHashQueries, c.blockHashQueries, strings.Join([]string{BlockHashQueriesNever, BlockHashQueriesAlways, BlockHashQueriesAuto}, ","))
+	}
+}
+
+// pinLogQueries returns true if the logs of a block range should be queried block by block with the blockHash
+// parameter, so that a re-org while the query runs cannot return logs from a mix of the old and new chains
+func (c *ethConnector) pinLogQueries(ctx context.Context) bool {
+	switch c.blockHashQueries {
+	case BlockHashQueriesAlways:
+		return true
+	case BlockHashQueriesAuto:
+		if c.blockHashQueriesActive.Load() {
+			return true
+		}
+		if depth := c.blockListener.reorgStats.maxDepth(); depth >= c.blockHashReorgDepth {
+			if c.blockHashQueriesActive.CompareAndSwap(false, true) {
+				log.L(ctx).Warnf("Switching to log queries pinned by block hash after a re-org of %d blocks (threshold=%d)", depth, c.blockHashReorgDepth)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ethConnector) getBlockHashQueriesStatus(ctx context.Context) *BlockHashQueriesStatus {
+	return &BlockHashQueriesStatus{
+		Mode:          c.blockHashQueries,
+		Active:        c.pinLogQueries(ctx),
+		MaxReorgDepth: c.blockListener.reorgStats.maxDepth(),
+	}
+}
+
+// blockRangeLogFilters returns the filters to query the logs of a block range with - either a single filter for
+// the whole range, or a filter per block pinned to the hash of the block on the chain we currently see
+func (es *eventStream) blockRangeLogFilters(ctx context.Context, ag *aggregatedListener, fromBlock, toBlock int64) ([]*logFilterJSONRPC, error) {
+	if !es.c.pinLogQueries(ctx) {
+		return []*logFilterJSONRPC{{
+			FromBlock: ethtypes.NewHexInteger64(fromBlock),
+			ToBlock:   ethtypes.NewHexInteger64(toBlock),
+			Address:   ag.addressSet,
+			Topics:    ag.topics,
+		}}, nil
+	}
+	filters := make([]*logFilterJSONRPC, 0, toBlock-fromBlock+1)
+	for blockNumber := fromBlock; blockNumber <= toBlock; blockNumber++ {
+		// The cache is not used, as it might hold a block that has since been replaced by a re-org
+		bi, _, err := es.c.blockListener.getBlockInfoByNumber(ctx, blockNumber, false, "")
+		if err != nil {
+			return nil, err
+		}
+		if bi == nil {
+			return nil, i18n.NewError(ctx, msgs.MsgBlockNotAvailable)
+		}
+		filters = append(filters, &logFilterJSONRPC{
+			BlockHash: bi.Hash,
+			Address:   ag.addressSet,
+			Topics:    ag.topics,
+		})
+	}
+	return filters, nil
+}
@@ -0,0 +1,129 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockPinnedBlock(mRPC *rpcbackendmocks.Backend, blockNumber int64) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(blockNumber), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(blockNumber),
+			Hash:   ethtypes.MustNewHexBytes0xPrefix(testBlockHash(blockNumber)),
+		}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		return f.FromBlock == nil && f.ToBlock == nil && f.BlockHash.String() == testBlockHash(blockNumber)
+	})).Return(nil).Run(func(args mock.Arguments) {
+		ethLog := sampleTransferLog()
+		ethLog.BlockNumber = ethtypes.NewHexInteger64(blockNumber)
+		ethLog.BlockHash = ethtypes.MustNewHexBytes0xPrefix(testBlockHash(blockNumber))
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{ethLog}
+	}).Once()
+}
+
+func TestQueryBlockRangeEventsPinnedByBlockHash(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, false)
+	l.c.eventBlockTimestamps = false
+	l.c.blockHashQueries = BlockHashQueriesAlways
+	ag := l.es.buildAggregatedListener([]*listener{l})
+
+	mockPinnedBlock(mRPC, 1024)
+	mockPinnedBlock(mRPC, 1025)
+	events, err := l.es.queryBlockRangeEvents(context.Background(), ag, 1024, 1025)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, testBlockHash(1024), events[0].Event.ID.BlockHash)
+	assert.Equal(t, testBlockHash(1025), events[1].Event.ID.BlockHash)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestQueryBlockRangeEventsPinnedFailures(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, false)
+	l.c.blockHashQueries = BlockHashQueriesAlways
+	ag := l.es.buildAggregatedListener([]*listener{l})
+
+	// The block is not yet available
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(1024), false).Return(nil).Once()
+	_, err := l.es.queryBlockRangeEvents(context.Background(), ag, 1024, 1024)
+	assert.Regexp(t, "FF23011", err)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(1024), false).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	_, err = l.es.queryBlockRangeEvents(context.Background(), ag, 1024, 1024)
+	assert.Regexp(t, "pop", err)
+
+	// The block was replaced by a re-org before its logs were queried
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(1024), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{Number: ethtypes.NewHexInteger64(1024), Hash: ethtypes.MustNewHexBytes0xPrefix(testBlockHash(1024))}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(&rpcbackend.RPCError{Message: "unknown block"}).Once()
+	_, err = l.es.queryBlockRangeEvents(context.Background(), ag, 1024, 1024)
+	assert.Regexp(t, "unknown block", err)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestBlockHashQueriesAuto(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsBlockHashQueries, BlockHashQueriesAuto)
+		conf.Set(EventsBlockHashReorgDepth, 3)
+	})
+	defer done()
+	assert.False(t, c.pinLogQueries(ctx))
+
+	// Extending the chain is not a re-org
+	c.blockListener.reorgStats.record(ctx, 100, 101, 100)
+	c.blockListener.reorgStats.record(ctx, 100, 99, 100)
+	assert.False(t, c.pinLogQueries(ctx))
+	assert.Equal(t, int64(2), c.blockListener.reorgStats.maxDepth())
+
+	// Once switched on, queries stay pinned
+	c.blockListener.reorgStats.record(ctx, 100, 98, 100)
+	assert.True(t, c.pinLogQueries(ctx))
+	c.blockListener.reorgStats.record(ctx, 100, 100, 100)
+	assert.True(t, c.pinLogQueries(ctx))
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil)
+	res, _, err := c.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, res.DownstreamDetails.String(), `"blockHashQueries":{"mode":"auto","active":true,"maxReorgDepth":3}`)
+
+}
+
+func TestBlockHashQueriesBadMode(t *testing.T) {
+
+	config.RootConfigReset()
+	conf := config.RootSection("unittest")
+	InitConfig(conf)
+	conf.Set(EventsBlockHashQueries, "sometimes")
+	err := (&ethConnector{}).initBlockHashQueries(context.Background(), conf)
+	assert.Regexp(t, "FF23171.*sometimes.*never,always,auto", err)
+
+}
@@ -0,0 +1,156 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// prefetchLogsPerBlock sizes the queue of logs waiting to be decoded, so that the fetches for the following
+// blocks can be started while the decoder waits for a block with many matching logs
+const prefetchLogsPerBlock = 100
+
+// blockPrefetcher decodes the logs of a block range query in a separate stage to the one that receives them,
+// so that the full block for each log is fetched with eth_getBlockByNumber ahead of decoding. This loads the
+// transaction cache with one call per block, rather than an eth_getTransactionByHash call per event, when the
+// listeners need the input data or signer of the transactions. At most depth blocks are fetched ahead of the
+// decoder, which bounds the memory used.
+type blockPrefetcher struct {
+	ctx        context.Context
+	c          *ethConnector
+	decode     func(ethLog *logJSONRPC) error
+	logs       chan *prefetchLog
+	slots      chan struct{}
+	current    *blockFetch
+	decodeDone chan struct{}
+	decodeErr  error
+}
+
+type prefetchLog struct {
+	ethLog *logJSONRPC
+	block  *blockFetch
+}
+
+type blockFetch struct {
+	number int64
+	done   chan struct{}
+}
+
+// newBlockPrefetcher returns nil if prefetch is disabled, in which case each log is decoded as it is added
+func (c *ethConnector) newBlockPrefetcher(ctx context.Context, ag *aggregatedListener, decode func(ethLog *logJSONRPC) error) *blockPrefetcher {
+	if c.blockPrefetchDepth <= 0 || !ag.transactionData {
+		return nil
+	}
+	bp := &blockPrefetcher{
+		ctx:        ctx,
+		c:          c,
+		decode:     decode,
+		logs:       make(chan *prefetchLog, c.blockPrefetchDepth*prefetchLogsPerBlock),
+		slots:      make(chan struct{}, c.blockPrefetchDepth),
+		decodeDone: make(chan struct{}),
+	}
+	go bp.decodeLoop()
+	return bp
+}
+
+// add queues a log for decoding, starting the fetch of its block if it is the first log in that block.
+// Logs are returned by eth_getLogs in block order, so each block is only fetched once.
+func (bp *blockPrefetcher) add(ethLog *logJSONRPC) error {
+	if bp == nil {
+		return nil
+	}
+	blockNumber := ethLog.BlockNumber.BigInt().Int64()
+	if bp.current == nil || bp.current.number != blockNumber {
+		select {
+		case bp.slots <- struct{}{}:
+		case <-bp.decodeDone:
+			return bp.decodeErr
+		case <-bp.ctx.Done():
+			return bp.ctx.Err()
+		}
+		bp.current = &blockFetch{number: blockNumber, done: make(chan struct{})}
+		go bp.fetch(bp.current)
+	}
+	select {
+	case bp.logs <- &prefetchLog{ethLog: ethLog, block: bp.current}:
+		return nil
+	case <-bp.decodeDone:
+		return bp.decodeErr
+	case <-bp.ctx.Done():
+		return bp.ctx.Err()
+	}
+}
+
+// close waits for the decoding of all the logs that were added, returning the first error
+func (bp *blockPrefetcher) close(err error) error {
+	if bp == nil {
+		return err
+	}
+	close(bp.logs)
+	<-bp.decodeDone
+	if err != nil {
+		return err
+	}
+	return bp.decodeErr
+}
+
+// fetch loads the transactions of the block into the transaction cache. A failure is not returned, as the
+// decoder falls back to querying each transaction that is not in the cache.
+func (bp *blockPrefetcher) fetch(bf *blockFetch) {
+	defer close(bf.done)
+	var block *blockTransactionsJSONRPC
+	if rpcErr := bp.c.backend.CallRPC(bp.ctx, &block, "eth_getBlockByNumber", ethtypes.NewHexInteger64(bf.number), true /* full transactions */); rpcErr != nil || block == nil {
+		log.L(bp.ctx).Debugf("Unable to prefetch block %d: %v", bf.number, rpcErr)
+		return
+	}
+	for _, tx := range block.Transactions {
+		if tx != nil && tx.Hash != nil {
+			bp.c.txCache.Add(tx.Hash.String(), tx)
+		}
+	}
+	log.L(bp.ctx).Tracef("Prefetched %d transactions of block %d", len(block.Transactions), bf.number)
+}
+
+func (bp *blockPrefetcher) decodeLoop() {
+	defer close(bp.decodeDone)
+	var prev *blockFetch
+	defer func() {
+		if prev != nil {
+			<-bp.slots
+		}
+	}()
+	for pl := range bp.logs {
+		if pl.block != prev {
+			if prev != nil {
+				<-bp.slots
+			}
+			prev = pl.block
+		}
+		select {
+		case <-pl.block.done:
+		case <-bp.ctx.Done():
+			bp.decodeErr = bp.ctx.Err()
+			return
+		}
+		if bp.decodeErr = bp.decode(pl.ethLog); bp.decodeErr != nil {
+			return
+		}
+	}
+}
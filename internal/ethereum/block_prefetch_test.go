@@ -0,0 +1,142 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleTransferInput = "0xa9059cbb000000000000000000000000d0f2f5103fd050739a9fb567251bc460cc24d09100000000000000000000000000000000000000000000000000000000000003e8"
+
+func samplePrefetchLog(blockNumber, txIndex int64) *logJSONRPC {
+	ethLog := sampleTransferLog()
+	ethLog.BlockNumber = ethtypes.NewHexInteger64(blockNumber)
+	ethLog.TransactionIndex = ethtypes.NewHexInteger64(txIndex)
+	ethLog.LogIndex = ethtypes.NewHexInteger64(txIndex)
+	ethLog.TransactionHash = ethtypes.MustNewHexBytes0xPrefix(fmt.Sprintf("0x%.64x", blockNumber*1000+txIndex))
+	return ethLog
+}
+
+func sampleTransferTxInfo(hash ethtypes.HexBytes0xPrefix) *txInfoJSONRPC {
+	return &txInfoJSONRPC{
+		Hash:  hash,
+		From:  ethtypes.MustNewAddress("0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4"),
+		Input: ethtypes.MustNewHexBytes0xPrefix(sampleTransferInput),
+	}
+}
+
+func TestQueryBlockRangeEventsBlockPrefetch(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, true)
+	l.c.eventBlockTimestamps = false
+	l.c.blockPrefetchDepth = 2
+	ag := l.es.buildAggregatedListener([]*listener{l})
+	assert.True(t, ag.transactionData)
+
+	ethLogs := []*logJSONRPC{samplePrefetchLog(1024, 1), samplePrefetchLog(1024, 2), samplePrefetchLog(1025, 1)}
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = ethLogs
+	})
+	// The transactions of the first block are all loaded with one call
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(1024), true).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockTransactionsJSONRPC) = &blockTransactionsJSONRPC{
+			Number:       ethtypes.NewHexInteger64(1024),
+			Transactions: []*txInfoJSONRPC{sampleTransferTxInfo(ethLogs[0].TransactionHash), sampleTransferTxInfo(ethLogs[1].TransactionHash)},
+		}
+	}).Once()
+	// The decoder falls back to querying the transaction, if the block cannot be fetched
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(1025), true).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", ethLogs[2].TransactionHash).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**txInfoJSONRPC) = sampleTransferTxInfo(ethLogs[2].TransactionHash)
+	}).Once()
+
+	events, err := l.es.queryBlockRangeEvents(context.Background(), ag, 1024, 1025)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	for _, ev := range events {
+		ei := ev.Event.Info.(*eventInfo)
+		assert.Equal(t, `transfer(address,uint256)`, ei.InputMethod)
+		assert.Equal(t, `0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4`, ei.InputSigner.String())
+	}
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestBlockPrefetcherDisabled(t *testing.T) {
+
+	l, _, _ := newTestListener(t, false)
+	l.c.blockPrefetchDepth = 10
+	ag := l.es.buildAggregatedListener([]*listener{l})
+	assert.False(t, ag.transactionData)
+	assert.Nil(t, l.c.newBlockPrefetcher(context.Background(), ag, nil))
+
+	ag.transactionData = true
+	l.c.blockPrefetchDepth = 0
+	bp := l.c.newBlockPrefetcher(context.Background(), ag, nil)
+	assert.Nil(t, bp)
+	assert.NoError(t, bp.add(sampleTransferLog()))
+	assert.Regexp(t, "pop", bp.close(fmt.Errorf("pop")))
+
+}
+
+func TestBlockPrefetcherDecodeError(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, true)
+	l.c.blockPrefetchDepth = 1
+	ag := l.es.buildAggregatedListener([]*listener{l})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", mock.Anything, true).Return(nil)
+
+	bp := l.c.newBlockPrefetcher(context.Background(), ag, func(ethLog *logJSONRPC) error {
+		return fmt.Errorf("pop")
+	})
+	// The error is returned once the decoder has stopped
+	var err error
+	for i := int64(0); err == nil; i++ {
+		err = bp.add(samplePrefetchLog(1024+i, 0))
+	}
+	assert.Regexp(t, "pop", err)
+	assert.Regexp(t, "pop", bp.close(nil))
+
+}
+
+func TestBlockPrefetcherCancelled(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, true)
+	l.c.blockPrefetchDepth = 1
+	ag := l.es.buildAggregatedListener([]*listener{l})
+	blocked := make(chan struct{})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", mock.Anything, true).Return(nil).Run(func(args mock.Arguments) {
+		<-blocked
+	})
+	defer close(blocked)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bp := l.c.newBlockPrefetcher(ctx, ag, func(ethLog *logJSONRPC) error { return nil })
+	assert.NoError(t, bp.add(samplePrefetchLog(1024, 0)))
+	cancel()
+	// The next block cannot be started until the decoder has finished the first, which is waiting for the fetch
+	assert.Regexp(t, "canceled", bp.add(samplePrefetchLog(1025, 0)))
+	assert.Regexp(t, "canceled", bp.close(nil))
+
+}
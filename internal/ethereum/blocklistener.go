@@ -35,9 +35,10 @@ import (
 )
 
 type blockUpdateConsumer struct {
-	id      *fftypes.UUID // could be an event stream ID for example - must be unique
-	ctx     context.Context
-	updates chan<- *ffcapi.BlockHashEvent
+	id          *fftypes.UUID // could be an event stream ID for example - must be unique
+	ctx         context.Context
+	updates     chan<- *ffcapi.BlockHashEvent
+	infoUpdates chan<- *NewBlockInfoEvent // set instead of updates, for consumers that want the block header info
 }
 
 // blockListener has two functions:
@@ -55,11 +56,21 @@ type blockListener struct {
 	highestBlock               int64
 	mux                        sync.Mutex
 	consumers                  map[fftypes.UUID]*blockUpdateConsumer
-	blockPollingInterval       time.Duration
+	blockPollingInterval       time.Duration // under mux, as it can be reloaded
+	blockPolling               *blockPolling
 	unstableHeadLength         int
 	canonicalChain             *list.List
+	canonicalChainView         []*minimalBlockInfo // copy of the canonical chain for external inspection, under mux
+	canonicalChainUpdated      *fftypes.FFTime
 	hederaCompatibilityMode    bool
 	blockCache                 *lru.Cache
+	maxReorgDepth              int64
+	reorgAutoResumeDelay       time.Duration
+	reorgHalt                  *ReorgHalt // under mux - set while notifications are halted by a deep re-org
+	deepReorgs                 int64      // under mux
+	trustedCheckpoint          *trustedCheckpoint
+	checkpointMismatch         error // under mux - set if the node disagrees with the trusted checkpoint
+	reorgStats                 *reorgStatistics
 }
 
 type minimalBlockInfo struct {
@@ -78,9 +89,15 @@ func newBlockListener(ctx context.Context, c *ethConnector, conf config.Section,
 		highestBlock:               -1,
 		consumers:                  make(map[fftypes.UUID]*blockUpdateConsumer),
 		blockPollingInterval:       conf.GetDuration(BlockPollingInterval),
+		blockPolling:               newBlockPolling(ctx, conf),
 		canonicalChain:             list.New(),
 		unstableHeadLength:         int(c.checkpointBlockGap),
 		hederaCompatibilityMode:    conf.GetBool(HederaCompatibilityMode),
+		maxReorgDepth:              conf.GetInt64(ReorgMaxDepth),
+		reorgAutoResumeDelay:       conf.GetDuration(ReorgAutoResumeDelay),
+	}
+	if bl.maxReorgDepth > 0 && bl.reorgAutoResumeDelay <= 0 {
+		log.L(ctx).Warnf("A re-org deeper than %d blocks halts new block notifications until acknowledged with the acknowledgeReorg operation of the extensions API, as reorg.autoResumeDelay is not set", bl.maxReorgDepth)
 	}
 	if wsConf != nil {
 		bl.wsBackend = rpcbackend.NewWSRPCClient(wsConf)
@@ -89,9 +106,21 @@ func newBlockListener(ctx context.Context, c *ethConnector, conf config.Section,
 	if err != nil {
 		return nil, i18n.WrapError(ctx, err, msgs.MsgCacheInitFail, "block")
 	}
+	if bl.trustedCheckpoint, err = parseTrustedCheckpoint(ctx, conf); err != nil {
+		return nil, err
+	}
+	if bl.reorgStats, err = newReorgStatistics(ctx, conf.GetString(ReorgStatisticsFile)); err != nil {
+		return nil, err
+	}
 	return bl, nil
 }
 
+func (bl *blockListener) getBlockPollingInterval() time.Duration {
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+	return bl.blockPollingInterval
+}
+
 func (bl *blockListener) newHeadsSubListener() {
 	for range bl.newHeadsSub.Notifications() {
 		select {
@@ -106,7 +135,7 @@ func (bl *blockListener) newHeadsSubListener() {
 // getBlockHeightWithRetry keeps retrying attempting to get the initial block height until successful
 func (bl *blockListener) establishBlockHeightWithRetry() error {
 	wsConnected := false
-	return bl.c.retry.Do(bl.ctx, "get initial block height", func(attempt int) (retry bool, err error) {
+	return bl.c.tuned().retry.Do(bl.ctx, "get initial block height", func(attempt int) (retry bool, err error) {
 
 		// If we have a WebSocket backend, then we connect it and switch over to using it
 		// (we accept an un-locked update here to backend, as the most important routine that's
@@ -142,6 +171,11 @@ func (bl *blockListener) establishBlockHeightWithRetry() error {
 			log.L(bl.ctx).Warnf("Block height could not be obtained: %s", rpcErr.Message)
 			return true, rpcErr.Error()
 		}
+		if bl.trustedCheckpoint != nil {
+			if retry, err := bl.verifyTrustedCheckpoint(hexBlockHeight.BigInt().Int64()); err != nil {
+				return retry, err
+			}
+		}
 		bl.mux.Lock()
 		bl.highestBlock = hexBlockHeight.BigInt().Int64()
 		bl.mux.Unlock()
@@ -156,6 +190,7 @@ func (bl *blockListener) listenLoop() {
 	close(bl.initialBlockHeightObtained)
 	if err != nil {
 		log.L(bl.ctx).Warnf("Block listener exiting before establishing initial block height: %s", err)
+		return
 	}
 
 	var filter string
@@ -170,7 +205,7 @@ func (bl *blockListener) listenLoop() {
 		} else {
 			// Sleep for the polling interval, or until we're shoulder tapped by the newHeads listener
 			select {
-			case <-time.After(bl.blockPollingInterval):
+			case <-time.After(bl.blockPolling.nextInterval(bl.getBlockPollingInterval())):
 			case <-bl.newHeadsTap:
 			case <-bl.ctx.Done():
 				log.L(bl.ctx).Debugf("Block listener loop stopping")
@@ -190,7 +225,7 @@ func (bl *blockListener) listenLoop() {
 		var blockHashes []ethtypes.HexBytes0xPrefix
 		rpcErr := bl.backend.CallRPC(bl.ctx, &blockHashes, "eth_getFilterChanges", filter)
 		if rpcErr != nil {
-			if mapError(filterRPCMethods, rpcErr.Error()) == ffcapi.ErrorReasonNotFound {
+			if bl.c.mapRPCError(filterRPCMethods, rpcErr) == ffcapi.ErrorReasonNotFound {
 				log.L(bl.ctx).Warnf("Block filter '%v' no longer valid. Recreating filter: %s", filter, rpcErr.Message)
 				filter = ""
 				gapPotential = true
@@ -201,6 +236,10 @@ func (bl *blockListener) listenLoop() {
 		}
 
 		update := &ffcapi.BlockHashEvent{GapPotential: gapPotential, Created: fftypes.Now()}
+		previousHead := int64(-1)
+		if head := bl.canonicalChain.Back(); head != nil {
+			previousHead = head.Value.(*minimalBlockInfo).number
+		}
 		var notifyPos *list.Element
 		for _, h := range blockHashes {
 			if len(h) != 32 {
@@ -227,6 +266,7 @@ func (bl *blockListener) listenLoop() {
 			case bi == nil:
 				log.L(bl.ctx).Debugf("Block '%s' no longer available after notification (assuming due to re-org)", h)
 			default:
+				bl.blockPolling.recordBlock(bi)
 				candidate := bl.reconcileCanonicalChain(bi)
 				// Check this is the lowest position to notify from
 				if candidate != nil && (notifyPos == nil || candidate.Value.(*minimalBlockInfo).number < notifyPos.Value.(*minimalBlockInfo).number) {
@@ -234,12 +274,25 @@ func (bl *blockListener) listenLoop() {
 				}
 			}
 		}
+		if notifyPos != nil {
+			bl.reorgStats.record(bl.ctx, previousHead, notifyPos.Value.(*minimalBlockInfo).number, bl.canonicalChain.Back().Value.(*minimalBlockInfo).number)
+		}
+		if bl.resumeAfterReorgHalt() {
+			// Consumers missed an unknown set of changes while halted, so they re-check the whole unstable head
+			notifyPos = bl.canonicalChain.Front()
+			update.GapPotential = true
+		} else if notifyPos != nil && bl.haltForDeepReorg(previousHead, notifyPos.Value.(*minimalBlockInfo).number) {
+			// We continue to track the chain, but do not notify consumers
+			bl.updateCanonicalChainView()
+			notifyPos = nil
+		}
 		if notifyPos != nil {
 			// We notify for all hashes from the point of change in the chain onwards
 			for notifyPos != nil {
 				update.BlockHashes = append(update.BlockHashes, notifyPos.Value.(*minimalBlockInfo).hash)
 				notifyPos = notifyPos.Next()
 			}
+			bl.updateCanonicalChainView()
 
 			// Take a copy of the consumers in the lock
 			bl.mux.Lock()
@@ -372,7 +425,7 @@ func (bl *blockListener) rebuildCanonicalChain() *list.Element {
 	for {
 		var bi *blockInfoJSONRPC
 		var reason ffcapi.ErrorReason
-		err := bl.c.retry.Do(bl.ctx, "rebuild listener canonical chain", func(attempt int) (retry bool, err error) {
+		err := bl.c.tuned().retry.Do(bl.ctx, "rebuild listener canonical chain", func(attempt int) (retry bool, err error) {
 			bi, reason, err = bl.getBlockInfoByNumber(bl.ctx, nextBlockNumber, false, "")
 			return reason != ffcapi.ErrorReasonNotFound, err
 		})
@@ -426,7 +479,7 @@ func (bl *blockListener) trimToLastValidBlock() (lastValidBlock *minimalBlockInf
 		currentViewBlock := lastElem.Value.(*minimalBlockInfo)
 		var freshBlockInfo *blockInfoJSONRPC
 		var reason ffcapi.ErrorReason
-		err := bl.c.retry.Do(bl.ctx, "rebuild listener canonical chain", func(attempt int) (retry bool, err error) {
+		err := bl.c.tuned().retry.Do(bl.ctx, "rebuild listener canonical chain", func(attempt int) (retry bool, err error) {
 			freshBlockInfo, reason, err = bl.getBlockInfoByNumber(bl.ctx, currentViewBlock.number, false, "")
 			return reason != ffcapi.ErrorReasonNotFound, err
 		})
@@ -455,10 +508,17 @@ func (bl *blockListener) trimToLastValidBlock() (lastValidBlock *minimalBlockInf
 }
 
 func (bl *blockListener) dispatchToConsumers(consumers []*blockUpdateConsumer, update *ffcapi.BlockHashEvent) {
+	var infoUpdate *NewBlockInfoEvent
 	for _, c := range consumers {
 		log.L(bl.ctx).Tracef("Notifying consumer %s of blocks %v (gap=%t)", c.id, update.BlockHashes, update.GapPotential)
+		updates, infoUpdates := c.updates, c.infoUpdates
+		if infoUpdates != nil && infoUpdate == nil {
+			// Only built once, and only if there are consumers that want it
+			infoUpdate = bl.buildNewBlockInfoEvent(update)
+		}
 		select {
-		case c.updates <- update:
+		case updates <- update:
+		case infoUpdates <- infoUpdate:
 		case <-bl.ctx.Done(): // loop, we're stopping and will exit on next loop
 		case <-c.ctx.Done():
 			log.L(bl.ctx).Debugf("Block update consumer %s closed", c.id)
@@ -499,7 +559,11 @@ func (bl *blockListener) getHighestBlock(ctx context.Context) (int64, bool) {
 	}
 	bl.mux.Lock()
 	highestBlock = bl.highestBlock
+	checkpointMismatch := bl.checkpointMismatch
 	bl.mux.Unlock()
+	if checkpointMismatch != nil {
+		return -1, false
+	}
 	log.L(ctx).Debugf("ChainHead=%d", highestBlock)
 	return highestBlock, true
 }
@@ -30,11 +30,14 @@ import (
 
 // blockInfoJSONRPC are the info fields we parse from the JSON/RPC response, and cache
 type blockInfoJSONRPC struct {
-	Number       *ethtypes.HexInteger        `json:"number"`
-	Hash         ethtypes.HexBytes0xPrefix   `json:"hash"`
-	ParentHash   ethtypes.HexBytes0xPrefix   `json:"parentHash"`
-	Timestamp    *ethtypes.HexInteger        `json:"timestamp"`
-	Transactions []ethtypes.HexBytes0xPrefix `json:"transactions"`
+	Number        *ethtypes.HexInteger        `json:"number"`
+	Hash          ethtypes.HexBytes0xPrefix   `json:"hash"`
+	ParentHash    ethtypes.HexBytes0xPrefix   `json:"parentHash"`
+	Timestamp     *ethtypes.HexInteger        `json:"timestamp"`
+	BaseFeePerGas *ethtypes.HexInteger        `json:"baseFeePerGas,omitempty"` // null before the London fork
+	GasLimit      *ethtypes.HexInteger        `json:"gasLimit"`
+	GasUsed       *ethtypes.HexInteger        `json:"gasUsed"`
+	Transactions  []ethtypes.HexBytes0xPrefix `json:"transactions"`
 }
 
 func transformBlockInfo(bi *blockInfoJSONRPC, t *ffcapi.BlockInfo) {
@@ -67,9 +70,9 @@ func (bl *blockListener) getBlockInfoByNumber(ctx context.Context, blockNumber i
 	}
 
 	if blockInfo == nil {
-		rpcErr := bl.backend.CallRPC(ctx, &blockInfo, "eth_getBlockByNumber", ethtypes.NewHexInteger64(blockNumber), false /* only the txn hashes */)
+		rpcErr := bl.c.readQuorum.callRPC(ctx, bl.backend, &blockInfo, "eth_getBlockByNumber", ethtypes.NewHexInteger64(blockNumber), false /* only the txn hashes */)
 		if rpcErr != nil {
-			if mapError(blockRPCMethods, rpcErr.Error()) == ffcapi.ErrorReasonNotFound {
+			if bl.c.mapRPCError(blockRPCMethods, rpcErr) == ffcapi.ErrorReasonNotFound {
 				log.L(ctx).Debugf("Received error signifying 'block not found': '%s'", rpcErr.Message)
 				return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgBlockNotAvailable)
 			}
@@ -92,7 +95,7 @@ func (bl *blockListener) getBlockInfoByHash(ctx context.Context, hash0xString st
 	}
 
 	if blockInfo == nil {
-		rpcErr := bl.backend.CallRPC(ctx, &blockInfo, "eth_getBlockByHash", hash0xString, false /* only the txn hashes */)
+		rpcErr := bl.c.readQuorum.callRPC(ctx, bl.backend, &blockInfo, "eth_getBlockByHash", hash0xString, false /* only the txn hashes */)
 		if rpcErr != nil || blockInfo == nil {
 			var err error
 			if rpcErr != nil {
@@ -0,0 +1,110 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/log"
+)
+
+// The number of recent block headers used to learn the average block time of the chain.
+// Block timestamps only have second granularity, so this needs to span multiple seconds on
+// chains with sub-second block times.
+const blockTimeSampleSize = 32
+
+type blockTimeSample struct {
+	number    int64
+	timestamp int64
+}
+
+// blockPolling decides how long the block listener waits between polls for new blocks
+type blockPolling struct {
+	jitter      float64
+	adaptive    bool
+	minInterval time.Duration
+	maxInterval time.Duration
+	samples     []blockTimeSample // only accessed from the listen loop
+}
+
+func newBlockPolling(ctx context.Context, conf config.Section) *blockPolling {
+	bp := &blockPolling{
+		jitter:      conf.GetFloat64(BlockPollingJitter),
+		adaptive:    conf.GetBool(BlockPollingAdaptive),
+		minInterval: conf.GetDuration(BlockPollingMinInterval),
+		maxInterval: conf.GetDuration(BlockPollingMaxInterval),
+	}
+	if bp.jitter < 0 || bp.jitter > 1 {
+		log.L(ctx).Warnf("Block polling jitter %f must be between 0 and 1 (overridden to 0)", bp.jitter)
+		bp.jitter = 0
+	}
+	if bp.adaptive && bp.maxInterval < bp.minInterval {
+		log.L(ctx).Warnf("Block polling max interval %s must be at least the min interval %s (overridden to %s)", bp.maxInterval, bp.minInterval, bp.minInterval)
+		bp.maxInterval = bp.minInterval
+	}
+	return bp
+}
+
+// recordBlock adds the header of a new block at the head of the chain to the samples used to learn the block time
+func (bp *blockPolling) recordBlock(bi *blockInfoJSONRPC) {
+	if !bp.adaptive || bi.Number == nil || bi.Timestamp == nil {
+		return
+	}
+	sample := blockTimeSample{
+		number:    bi.Number.BigInt().Int64(),
+		timestamp: bi.Timestamp.BigInt().Int64(),
+	}
+	if len(bp.samples) > 0 && sample.number <= bp.samples[len(bp.samples)-1].number {
+		// Re-orgs and duplicate notifications do not tell us anything new about the block time
+		return
+	}
+	bp.samples = append(bp.samples, sample)
+	if len(bp.samples) > blockTimeSampleSize {
+		bp.samples = bp.samples[len(bp.samples)-blockTimeSampleSize:]
+	}
+}
+
+// averageBlockTime returns the average time between the sampled blocks, or zero if it is not yet known
+func (bp *blockPolling) averageBlockTime() time.Duration {
+	if len(bp.samples) < 2 {
+		return 0
+	}
+	first, last := bp.samples[0], bp.samples[len(bp.samples)-1]
+	return time.Duration(last.timestamp-first.timestamp) * time.Second / time.Duration(last.number-first.number)
+}
+
+// nextInterval returns how long to wait before the next poll. When adaptive, that is the learned block time
+// within the configured bounds, with the configured interval used until the block time is known.
+// Jitter spreads the polls of connectors that were started at the same time.
+func (bp *blockPolling) nextInterval(interval time.Duration) time.Duration {
+	if bp.adaptive && len(bp.samples) >= 2 {
+		interval = bp.averageBlockTime()
+		if interval < bp.minInterval {
+			interval = bp.minInterval
+		}
+		if interval > bp.maxInterval {
+			interval = bp.maxInterval
+		}
+	}
+	if bp.jitter > 0 {
+		interval += time.Duration(float64(interval) * bp.jitter * (2*rand.Float64() - 1)) // #nosec G404 -- not security sensitive
+	}
+	return interval
+}
@@ -0,0 +1,119 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBlockTimeInfo(number, timestamp int64) *blockInfoJSONRPC {
+	return &blockInfoJSONRPC{
+		Number:    ethtypes.NewHexInteger64(number),
+		Timestamp: ethtypes.NewHexInteger64(timestamp),
+	}
+}
+
+func TestBlockPollingAdaptiveFastChain(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(BlockPollingAdaptive, true)
+	})
+	defer done()
+	bp := c.blockListener.blockPolling
+
+	// The configured interval is used until the block time is known
+	assert.Equal(t, time.Hour, bp.nextInterval(time.Hour))
+	bp.recordBlock(testBlockTimeInfo(1000, 1700000000))
+	assert.Equal(t, time.Hour, bp.nextInterval(time.Hour))
+
+	// Four blocks a second, with second granularity timestamps
+	for i := int64(1); i <= 40; i++ {
+		bp.recordBlock(testBlockTimeInfo(1000+i, 1700000000+i/4))
+	}
+	assert.Len(t, bp.samples, blockTimeSampleSize)
+	assert.Equal(t, int64(1009), bp.samples[0].number)
+	blockTime := bp.averageBlockTime()
+	assert.InDelta(t, 250*time.Millisecond, blockTime, float64(10*time.Millisecond))
+	assert.Equal(t, blockTime, bp.nextInterval(time.Hour))
+
+	// Re-orgs and duplicates are ignored, as are blocks without a timestamp
+	bp.recordBlock(testBlockTimeInfo(1040, 1700000100))
+	bp.recordBlock(&blockInfoJSONRPC{Number: ethtypes.NewHexInteger64(1041)})
+	assert.Equal(t, blockTime, bp.nextInterval(time.Hour))
+
+}
+
+func TestBlockPollingAdaptiveBounds(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(BlockPollingAdaptive, true)
+		conf.Set(BlockPollingMinInterval, "500ms")
+		conf.Set(BlockPollingMaxInterval, "10s")
+	})
+	defer done()
+	bp := c.blockListener.blockPolling
+
+	// Multiple blocks in the same second
+	bp.recordBlock(testBlockTimeInfo(1000, 1700000000))
+	bp.recordBlock(testBlockTimeInfo(1002, 1700000000))
+	assert.Equal(t, 500*time.Millisecond, bp.nextInterval(time.Second))
+
+	// A slow chain
+	bp.recordBlock(testBlockTimeInfo(1003, 1700000060))
+	assert.Equal(t, 20*time.Second, bp.averageBlockTime())
+	assert.Equal(t, 10*time.Second, bp.nextInterval(time.Second))
+
+}
+
+func TestBlockPollingJitter(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(BlockPollingJitter, 0.1)
+	})
+	defer done()
+	bp := c.blockListener.blockPolling
+
+	bp.recordBlock(testBlockTimeInfo(1000, 1700000000)) // not adaptive, so not recorded
+	assert.Empty(t, bp.samples)
+	for i := 0; i < 100; i++ {
+		interval := bp.nextInterval(time.Second)
+		assert.GreaterOrEqual(t, interval, 900*time.Millisecond)
+		assert.LessOrEqual(t, interval, 1100*time.Millisecond)
+	}
+
+}
+
+func TestBlockPollingBadConfig(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(BlockPollingJitter, 1.5)
+		conf.Set(BlockPollingAdaptive, true)
+		conf.Set(BlockPollingMinInterval, "5s")
+		conf.Set(BlockPollingMaxInterval, "1s")
+	})
+	defer done()
+	bp := c.blockListener.blockPolling
+
+	assert.Zero(t, bp.jitter)
+	assert.Equal(t, 5*time.Second, bp.maxInterval)
+
+}
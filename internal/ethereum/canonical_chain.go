@@ -0,0 +1,85 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type CanonicalChainRequest struct {
+}
+
+type CanonicalChainBlock struct {
+	BlockNumber *fftypes.FFBigInt `json:"blockNumber"`
+	BlockHash   string            `json:"blockHash"`
+	ParentHash  string            `json:"parentHash"`
+}
+
+type CanonicalChainResponse struct {
+	HighestBlock int64                  `json:"highestBlock"`          // -1 until the block height has been established
+	Blocks       []*CanonicalChainBlock `json:"blocks"`                // oldest first, up to the configured number of unstable blocks at the head of the chain
+	HeadUpdated  *fftypes.FFTime        `json:"headUpdated,omitempty"` // when the block listener last added to or re-organized the chain
+	HeadAge      fftypes.FFDuration     `json:"headAge"`               // time since headUpdated
+	ReorgHalt    *ReorgHalt             `json:"reorgHalt,omitempty"`   // set while notifications are halted by a deep re-org
+}
+
+// CanonicalChain returns the view of the head of the chain held in memory by the block listener, which is used to
+// detect re-orgs and determine what new block notifications are sent to consumers. This is intended for operators
+// diagnosing stalled confirmations, or forks, on a running connector.
+func (c *ethConnector) CanonicalChain(_ context.Context, _ *CanonicalChainRequest) (*CanonicalChainResponse, ffcapi.ErrorReason, error) {
+	bl := c.blockListener
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+	res := &CanonicalChainResponse{
+		HighestBlock: bl.highestBlock,
+		Blocks:       make([]*CanonicalChainBlock, len(bl.canonicalChainView)),
+		HeadUpdated:  bl.canonicalChainUpdated,
+		ReorgHalt:    bl.reorgHalt,
+	}
+	for i, mbi := range bl.canonicalChainView {
+		res.Blocks[i] = canonicalChainBlock(mbi)
+	}
+	if res.HeadUpdated != nil {
+		res.HeadAge = fftypes.FFDuration(time.Since(*res.HeadUpdated.Time()))
+	}
+	return res, "", nil
+}
+
+func canonicalChainBlock(mbi *minimalBlockInfo) *CanonicalChainBlock {
+	return &CanonicalChainBlock{
+		BlockNumber: fftypes.NewFFBigInt(mbi.number),
+		BlockHash:   mbi.hash,
+		ParentHash:  mbi.parentHash,
+	}
+}
+
+// updateCanonicalChainView must be called from the listen loop whenever the canonical chain changes,
+// as the canonical chain itself is only accessed from the listen loop without locking
+func (bl *blockListener) updateCanonicalChainView() {
+	view := make([]*minimalBlockInfo, 0, bl.canonicalChain.Len())
+	for e := bl.canonicalChain.Front(); e != nil; e = e.Next() {
+		view = append(view, e.Value.(*minimalBlockInfo))
+	}
+	bl.mux.Lock()
+	bl.canonicalChainView = view
+	bl.canonicalChainUpdated = fftypes.Now()
+	bl.mux.Unlock()
+}
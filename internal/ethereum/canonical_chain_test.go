@@ -0,0 +1,94 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCanonicalChain(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	bl := c.blockListener
+	bl.blockPollingInterval = 1 * time.Microsecond
+
+	// Nothing until the block listener has received blocks
+	res, _, err := c.CanonicalChain(ctx, &CanonicalChainRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), res.HighestBlock)
+	assert.Empty(t, res.Blocks)
+	assert.Nil(t, res.HeadUpdated)
+
+	block1000Hash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	block1001Hash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	block1002Hash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexInteger) = *ethtypes.NewHexInteger64(1000)
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_newBlockFilter").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*string) = "filter_id1"
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", "filter_id1").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]ethtypes.HexBytes0xPrefix) = []ethtypes.HexBytes0xPrefix{block1001Hash, block1002Hash}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", mock.Anything).Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", block1001Hash.String(), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number:     ethtypes.NewHexInteger64(1001),
+			Hash:       block1001Hash,
+			ParentHash: block1000Hash,
+		}
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", block1002Hash.String(), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number:     ethtypes.NewHexInteger64(1002),
+			Hash:       block1002Hash,
+			ParentHash: block1001Hash,
+		}
+	})
+
+	updates := make(chan *ffcapi.BlockHashEvent)
+	bl.addConsumer(&blockUpdateConsumer{
+		id:      fftypes.NewUUID(),
+		ctx:     context.Background(),
+		updates: updates,
+	})
+	<-updates
+
+	res, _, err = c.CanonicalChain(ctx, &CanonicalChainRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1002), res.HighestBlock)
+	assert.Equal(t, []*CanonicalChainBlock{
+		{BlockNumber: fftypes.NewFFBigInt(1001), BlockHash: block1001Hash.String(), ParentHash: block1000Hash.String()},
+		{BlockNumber: fftypes.NewFFBigInt(1002), BlockHash: block1002Hash.String(), ParentHash: block1001Hash.String()},
+	}, res.Blocks)
+	assert.NotNil(t, res.HeadUpdated)
+	assert.GreaterOrEqual(t, res.HeadAge, fftypes.FFDuration(0))
+
+	done()
+	<-bl.listenLoopDone
+
+}
@@ -0,0 +1,95 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+)
+
+// chainProfile holds the defaults that differ for a family of chains.
+// Required confirmations are configured in the transaction manager, not the connector.
+type chainProfile struct {
+	checkpointBlockGap         int64 // the re-org depth we consider possible at the head of the chain
+	receiptsNotFoundRetries    int   // for nodes that return receipts with eventual consistency
+	receiptsNotFoundRetryDelay time.Duration
+	receiptsNotFoundGrace      time.Duration // how long to wait for the receipt of a transaction that is in a block
+}
+
+var chainProfiles = map[string]*chainProfile{
+	// Polygon PoS (bor) nodes can return a null receipt for a short time after the block containing it
+	// has been announced, and the chain can re-org deeper than Ethereum mainnet
+	"polygon": {
+		checkpointBlockGap:         128,
+		receiptsNotFoundRetries:    2,
+		receiptsNotFoundRetryDelay: 500 * time.Millisecond,
+		receiptsNotFoundGrace:      5 * time.Second,
+	},
+	// BNB Smart Chain has fast block times, with a correspondingly higher re-org depth in blocks
+	"bsc": {
+		checkpointBlockGap: 64,
+	},
+	// Avalanche C-Chain has instant finality, so there are no re-orgs at the head of the chain
+	"avalanche": {
+		checkpointBlockGap: 1,
+	},
+}
+
+func chainProfileNames() string {
+	names := make([]string, 0, len(chainProfiles))
+	for name := range chainProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// applyChainProfile updates the settings of the connector that are still at their default values,
+// with the defaults of the configured chain profile (if any)
+func (c *ethConnector) applyChainProfile(ctx context.Context, conf config.Section) error {
+	profileName := strings.ToLower(conf.GetString(ChainProfile))
+	if profileName == "" {
+		return nil
+	}
+	profile := chainProfiles[profileName]
+	if profile == nil {
+		return i18n.NewError(ctx, msgs.MsgUnknownChainProfile, profileName, chainProfileNames())
+	}
+
+	if profile.checkpointBlockGap > 0 && c.checkpointBlockGap == DefaultEventsCheckpointBlockGap {
+		c.checkpointBlockGap = profile.checkpointBlockGap
+	}
+	if profile.receiptsNotFoundRetries > 0 && c.receiptsNotFoundRetries == DefaultReceiptsNotFoundRetries {
+		c.receiptsNotFoundRetries = profile.receiptsNotFoundRetries
+	}
+	if profile.receiptsNotFoundRetryDelay > 0 && conf.GetString(ReceiptsNotFoundRetryDelay) == DefaultReceiptsNotFoundRetryDelay {
+		c.receiptsNotFoundRetryDelay = profile.receiptsNotFoundRetryDelay
+	}
+	if profile.receiptsNotFoundGrace > 0 && conf.GetString(ReceiptsNotFoundGracePeriod) == DefaultReceiptsNotFoundGracePeriod {
+		c.receiptsNotFoundGrace = profile.receiptsNotFoundGrace
+	}
+	log.L(ctx).Infof("Applied chain profile '%s': checkpointBlockGap=%d receiptsNotFoundRetries=%d receiptsNotFoundRetryDelay=%s receiptsNotFoundGracePeriod=%s",
+		profileName, c.checkpointBlockGap, c.receiptsNotFoundRetries, c.receiptsNotFoundRetryDelay, c.receiptsNotFoundGrace)
+	return nil
+}
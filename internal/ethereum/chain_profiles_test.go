@@ -0,0 +1,83 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProfileConf(profile string) config.Section {
+	config.RootConfigReset()
+	conf := config.RootSection("unittest")
+	InitConfig(conf)
+	conf.Set(ffresty.HTTPConfigURL, "http://localhost:8545")
+	conf.Set(ChainProfile, profile)
+	return conf
+}
+
+func TestChainProfilePolygon(t *testing.T) {
+
+	cc, err := NewEthereumConnector(context.Background(), newTestProfileConf("Polygon"))
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+	assert.Equal(t, int64(128), c.checkpointBlockGap)
+	assert.Equal(t, 128, c.blockListener.unstableHeadLength)
+	assert.Equal(t, 2, c.receiptsNotFoundRetries)
+	assert.Equal(t, 500*time.Millisecond, c.receiptsNotFoundRetryDelay)
+	assert.Equal(t, 5*time.Second, c.receiptsNotFoundGrace)
+
+}
+
+func TestChainProfileExplicitConfigWins(t *testing.T) {
+
+	conf := newTestProfileConf("polygon")
+	conf.Set(EventsCheckpointBlockGap, 10)
+	conf.Set(ReceiptsNotFoundRetries, 5)
+	conf.Set(ReceiptsNotFoundRetryDelay, "1s")
+	conf.Set(ReceiptsNotFoundGracePeriod, "2s")
+	cc, err := NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+	assert.Equal(t, int64(10), c.checkpointBlockGap)
+	assert.Equal(t, 5, c.receiptsNotFoundRetries)
+	assert.Equal(t, 1*time.Second, c.receiptsNotFoundRetryDelay)
+	assert.Equal(t, 2*time.Second, c.receiptsNotFoundGrace)
+
+}
+
+func TestChainProfileAvalanche(t *testing.T) {
+
+	cc, err := NewEthereumConnector(context.Background(), newTestProfileConf("avalanche"))
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+	assert.Equal(t, int64(1), c.checkpointBlockGap)
+	assert.Equal(t, DefaultReceiptsNotFoundRetries, c.receiptsNotFoundRetries)
+
+}
+
+func TestChainProfileUnknown(t *testing.T) {
+
+	_, err := NewEthereumConnector(context.Background(), newTestProfileConf("wrong"))
+	assert.Regexp(t, "FF23066.*avalanche,bsc,polygon", err)
+
+}
@@ -0,0 +1,155 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+)
+
+// The JSON/RPC error code we return when failing fast with an open circuit, which the default
+// error mappings map to the retryable downstream_down reason
+const rpcCodeCircuitOpen = -32099
+
+// The prefix of the error firefly-signer returns when no JSON/RPC response is received from the node
+const rpcRequestFailedPrefix = "FF22012"
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // requests are sent to the node
+	CircuitOpen     CircuitState = "open"      // requests fail fast without being sent
+	CircuitHalfOpen CircuitState = "half_open" // a single probe request is in flight
+)
+
+// circuitBreaker wraps the JSON/RPC backend, so that after a number of consecutive failures to get any
+// response from the node we fail fast rather than queuing up requests (each with their own retries)
+// against a node that is down. After the reset delay a single request is let through as a probe,
+// and its success closes the circuit again.
+type circuitBreaker struct {
+	rpcbackend.Backend
+	failureThreshold int
+	resetDelay       time.Duration
+	mux              sync.Mutex
+	state            CircuitState
+	failures         int
+	openedAt         time.Time
+}
+
+func newCircuitBreaker(backend rpcbackend.Backend, failureThreshold int, resetDelay time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		Backend:          backend,
+		failureThreshold: failureThreshold,
+		resetDelay:       resetDelay,
+		state:            CircuitClosed,
+	}
+}
+
+func (cb *circuitBreaker) CallRPC(ctx context.Context, result interface{}, method string, params ...interface{}) *rpcbackend.RPCError {
+	probe, err := cb.allow(ctx)
+	if err != nil {
+		return err
+	}
+	rpcErr := cb.Backend.CallRPC(ctx, result, method, params...)
+	cb.record(ctx, probe, rpcErr)
+	return rpcErr
+}
+
+func (cb *circuitBreaker) SyncRequest(ctx context.Context, rpcReq *rpcbackend.RPCRequest) (*rpcbackend.RPCResponse, error) {
+	probe, openErr := cb.allow(ctx)
+	if openErr != nil {
+		return rpcbackend.RPCErrorResponse(openErr.Error(), rpcReq.ID, rpcCodeCircuitOpen), openErr.Error()
+	}
+	rpcRes, err := cb.Backend.SyncRequest(ctx, rpcReq)
+	var rpcErr *rpcbackend.RPCError
+	if err != nil && rpcRes != nil {
+		rpcErr = rpcRes.Error
+	}
+	cb.record(ctx, probe, rpcErr)
+	return rpcRes, err
+}
+
+// allow returns whether the request is the probe in the half-open state, or an error if it must fail fast
+func (cb *circuitBreaker) allow(ctx context.Context) (bool, *rpcbackend.RPCError) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	switch {
+	case cb.state == CircuitClosed:
+		return false, nil
+	case cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.resetDelay:
+		cb.setState(ctx, CircuitHalfOpen)
+		return true, nil
+	default:
+		retryAfter := cb.resetDelay - time.Since(cb.openedAt)
+		if retryAfter < 0 {
+			retryAfter = 0 // waiting for the probe
+		}
+		return false, rpcbackend.NewRPCError(ctx, rpcCodeCircuitOpen, msgs.MsgCircuitBreakerOpen, cb.failures, retryAfter.Truncate(time.Millisecond))
+	}
+}
+
+// record counts failures to get any JSON/RPC response from the node - errors returned by the node show it is up.
+// A cancelled request tells us nothing about the node, but a cancelled probe needs to re-open the circuit.
+// Once the circuit is not closed, only the probe is counted.
+func (cb *circuitBreaker) record(ctx context.Context, probe bool, rpcErr *rpcbackend.RPCError) {
+	cancelled := ctx.Err() != nil
+	if cancelled && !probe {
+		return
+	}
+	failed := cancelled || (rpcErr != nil && rpcErr.Code == int64(rpcbackend.RPCCodeInternalError) &&
+		(strings.HasPrefix(rpcErr.Message, rpcRequestFailedPrefix) || strings.HasPrefix(rpcErr.Message, ipcRequestFailedPrefix) ||
+			strings.HasPrefix(rpcErr.Message, logsRequestFailedPrefix)))
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	if !probe && cb.state != CircuitClosed {
+		// The request was sent before the circuit opened, so only the result of the probe changes the state
+		return
+	}
+	if !failed {
+		cb.failures = 0
+		if cb.state != CircuitClosed {
+			cb.setState(ctx, CircuitClosed)
+		}
+		return
+	}
+	cb.failures++
+	if probe || (cb.state == CircuitClosed && cb.failures >= cb.failureThreshold) {
+		cb.openedAt = time.Now()
+		cb.setState(ctx, CircuitOpen)
+	}
+}
+
+func (cb *circuitBreaker) setState(ctx context.Context, state CircuitState) {
+	if state == CircuitOpen {
+		log.L(ctx).Warnf("Circuit breaker %s -> %s after %d consecutive failures - failing requests for %s", cb.state, state, cb.failures, cb.resetDelay)
+	} else {
+		log.L(ctx).Infof("Circuit breaker %s -> %s", cb.state, state)
+	}
+	cb.state = state
+}
+
+func (cb *circuitBreaker) getState() CircuitState {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	return cb.state
+}
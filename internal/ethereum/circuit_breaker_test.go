@@ -0,0 +1,211 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func connectionRefused() *rpcbackend.RPCError {
+	return &rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeInternalError), Message: "FF22012: Backend RPC request failed: connection refused"}
+}
+
+func TestCircuitBreakerOpenAndProbe(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	cb := newCircuitBreaker(mRPC, 2, 1*time.Hour)
+	c.backend = cb
+	c.circuitBreaker = cb
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(connectionRefused()).Twice()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := c.IsReady(ctx)
+		assert.Regexp(t, "FF22012", err)
+	}
+	assert.Equal(t, CircuitOpen, cb.getState())
+
+	// Fails fast, with a retryable reason
+	_, reason, err := c.IsReady(ctx)
+	assert.Regexp(t, "FF23107.*2 consecutive failures", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+	mRPC.AssertNumberOfCalls(t, "CallRPC", 2)
+
+	// A successful probe after the reset delay closes the circuit
+	cb.openedAt = time.Now().Add(-1 * time.Hour)
+	res, _, err := c.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitClosed, cb.getState())
+	assert.Equal(t, "closed", res.DownstreamDetails.JSONObject().GetString("circuitBreaker"))
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestCircuitBreakerProbeFails(t *testing.T) {
+
+	mRPC := &rpcbackendmocks.Backend{}
+	cb := newCircuitBreaker(mRPC, 1, 1*time.Hour)
+	ctx := context.Background()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(connectionRefused())
+
+	rpcErr := cb.CallRPC(ctx, nil, "eth_blockNumber")
+	assert.Regexp(t, "FF22012", rpcErr.Message)
+	assert.Equal(t, CircuitOpen, cb.getState())
+
+	// The failed probe re-opens the circuit for another reset delay
+	cb.openedAt = time.Now().Add(-1 * time.Hour)
+	rpcErr = cb.CallRPC(ctx, nil, "eth_blockNumber")
+	assert.Regexp(t, "FF22012", rpcErr.Message)
+	assert.Equal(t, CircuitOpen, cb.getState())
+	assert.Equal(t, 2, cb.failures)
+	rpcErr = cb.CallRPC(ctx, nil, "eth_blockNumber")
+	assert.Equal(t, int64(rpcCodeCircuitOpen), rpcErr.Code)
+
+	mRPC.AssertNumberOfCalls(t, "CallRPC", 2)
+
+}
+
+func TestCircuitBreakerHalfOpenFailsFast(t *testing.T) {
+
+	mRPC := &rpcbackendmocks.Backend{}
+	cb := newCircuitBreaker(mRPC, 1, 1*time.Hour)
+	ctx := context.Background()
+
+	probing := make(chan struct{})
+	probeDone := make(chan struct{})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(connectionRefused()).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		close(probing)
+		<-probeDone
+	}).Once()
+
+	_ = cb.CallRPC(ctx, nil, "eth_blockNumber")
+	cb.openedAt = time.Now().Add(-1 * time.Hour)
+
+	probeResult := make(chan *rpcbackend.RPCError)
+	go func() {
+		probeResult <- cb.CallRPC(ctx, nil, "eth_blockNumber")
+	}()
+	<-probing
+	assert.Equal(t, CircuitHalfOpen, cb.getState())
+	rpcErr := cb.CallRPC(ctx, nil, "eth_blockNumber")
+	assert.Regexp(t, "FF23107", rpcErr.Message)
+
+	close(probeDone)
+	assert.Nil(t, <-probeResult)
+	assert.Equal(t, CircuitClosed, cb.getState())
+
+}
+
+func TestCircuitBreakerInFlightRequestsDoNotChangeState(t *testing.T) {
+
+	mRPC := &rpcbackendmocks.Backend{}
+	cb := newCircuitBreaker(mRPC, 1, 1*time.Hour)
+	ctx := context.Background()
+
+	// A request sent before the circuit opened completes afterwards
+	inFlight := make(chan struct{})
+	inFlightDone := make(chan struct{})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_chainId").Return(nil).Run(func(args mock.Arguments) {
+		close(inFlight)
+		<-inFlightDone
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(connectionRefused())
+
+	inFlightResult := make(chan *rpcbackend.RPCError)
+	go func() {
+		inFlightResult <- cb.CallRPC(ctx, nil, "eth_chainId")
+	}()
+	<-inFlight
+	_ = cb.CallRPC(ctx, nil, "eth_blockNumber")
+	assert.Equal(t, CircuitOpen, cb.getState())
+
+	close(inFlightDone)
+	assert.Nil(t, <-inFlightResult)
+	assert.Equal(t, CircuitOpen, cb.getState())
+	assert.Equal(t, 1, cb.failures)
+
+}
+
+func TestCircuitBreakerIgnoresNodeErrors(t *testing.T) {
+
+	mRPC := &rpcbackendmocks.Backend{}
+	cb := newCircuitBreaker(mRPC, 1, 1*time.Hour)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything).Return(&rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeInternalError), Message: "execution reverted"})
+	rpcErr := cb.CallRPC(context.Background(), nil, "eth_call", "0x")
+	assert.Regexp(t, "reverted", rpcErr.Message)
+	assert.Equal(t, CircuitClosed, cb.getState())
+
+	// Cancelled requests are not counted either
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(connectionRefused())
+	_ = cb.CallRPC(cancelled, nil, "eth_blockNumber")
+	assert.Equal(t, CircuitClosed, cb.getState())
+
+}
+
+func TestCircuitBreakerSyncRequest(t *testing.T) {
+
+	mRPC := &rpcbackendmocks.Backend{}
+	cb := newCircuitBreaker(mRPC, 1, 1*time.Hour)
+	ctx := context.Background()
+
+	mRPC.On("SyncRequest", mock.Anything, mock.Anything).Return(&rpcbackend.RPCResponse{Error: connectionRefused()}, fmt.Errorf("pop")).Once()
+
+	_, err := cb.SyncRequest(ctx, &rpcbackend.RPCRequest{Method: "eth_blockNumber"})
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, CircuitOpen, cb.getState())
+
+	res, err := cb.SyncRequest(ctx, &rpcbackend.RPCRequest{Method: "eth_blockNumber"})
+	assert.Regexp(t, "FF23107", err)
+	assert.Equal(t, int64(rpcCodeCircuitOpen), res.Error.Code)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestCircuitBreakerConfig(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(CircuitBreakerThreshold, 3)
+	conf.Set(CircuitBreakerResetDelay, "5s")
+	cc, err := NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+	assert.Equal(t, c.circuitBreaker, c.backend)
+	assert.Equal(t, 3, c.circuitBreaker.failureThreshold)
+	assert.Equal(t, 5*time.Second, c.circuitBreaker.resetDelay)
+
+	cc, err = NewEthereumConnector(context.Background(), newTestAuthConf(t, "http://localhost:8545"))
+	assert.NoError(t, err)
+	assert.Nil(t, cc.(*ethConnector).circuitBreaker)
+
+}
@@ -23,57 +23,351 @@ import (
 
 const (
 	ConfigGasEstimationFactor   = "gasEstimationFactor"
+	ConfigGasEstimationFloor    = "gasEstimationFloor"
+	ConfigGasEstimationCeiling  = "gasEstimationCeiling"
+	ConfigGasEstimationFallback = "gasEstimationFallback"
 	ConfigDataFormat            = "dataFormat"
+	ChecksumAddresses           = "checksumAddresses"
+	ReplacementFeeBumpPercent   = "replacementFeeBumpPercent"
+	LegacyFeeFallback           = "legacyFeeFallback"
+	PriorityFeeEnabled          = "priorityFee.enabled"
+	PriorityFeeWindow           = "priorityFee.window"
+	PriorityFeeSpikeClamp       = "priorityFee.spikeClamp"
 	BlockPollingInterval        = "blockPollingInterval"
+	BlockPollingJitter          = "blockPollingJitter"
+	BlockPollingAdaptive        = "blockPollingAdaptive.enabled"
+	BlockPollingMinInterval     = "blockPollingAdaptive.minInterval"
+	BlockPollingMaxInterval     = "blockPollingAdaptive.maxInterval"
 	BlockCacheSize              = "blockCacheSize"
 	EventsCatchupPageSize       = "events.catchupPageSize"
 	EventsCatchupThreshold      = "events.catchupThreshold"
 	EventsCatchupDownscaleRegex = "events.catchupDownscaleRegex"
+	EventsCatchupParallelism    = "events.catchupParallelism"
+	EventsDedupeCacheSize       = "events.dedupeCacheSize"
+	EventsLogIndexSize          = "events.logIndexSize"
 	EventsCheckpointBlockGap    = "events.checkpointBlockGap"
+	EventsDryRunMaxBlocks       = "events.dryRunMaxBlocks"
+	EventsContinuityRescans     = "events.continuityRescans"
 	EventsBlockTimestamps       = "events.blockTimestamps"
+	EventsSchemaVersion         = "events.schemaVersion"
+	EventsOrdering              = "events.ordering"
 	EventsFilterPollingInterval = "events.filterPollingInterval"
+	EventsMaxLogsResponseSize   = "events.maxLogsResponseSize"
+	EventsWALPath               = "events.writeAheadLog.path"
+	EventsWALMaxEntries         = "events.writeAheadLog.maxEntries"
+	EventsWildcardEventRate     = "events.wildcardEventRate"
+	EventsBlockPrefetchDepth    = "events.blockPrefetchDepth"
+	EventsSignatureLabels       = "events.signatureLabels.enabled"
+	EventsSignatureLabelsFile   = "events.signatureLabels.file"
+	EventsBootstrapDirectory    = "events.bootstrapDirectory"
+	EventsBlockHashQueries      = "events.blockHashQueries.mode"
+	EventsBlockHashReorgDepth   = "events.blockHashQueries.reorgDepth"
+	LeaderElectionURL           = "leaderElection.url"
+	LeaderElectionName          = "leaderElection.name"
+	LeaderElectionInstanceID    = "leaderElection.instanceID"
+	LeaderElectionLeaseDuration = "leaderElection.leaseDuration"
+	LeaderElectionRenewInterval = "leaderElection.renewInterval"
+	AuditEnabled                = "audit.enabled"
+	AuditFile                   = "audit.file"
+	AuditMaxSize                = "audit.maxSize"
+	AuditMaxBackups             = "audit.maxBackups"
+	AuditIncludeRequests        = "audit.includeRequests"
+	AuditRedactFields           = "audit.redactFields"
 	RetryInitDelay              = "retry.initialDelay"
 	RetryMaxDelay               = "retry.maxDelay"
 	RetryFactor                 = "retry.factor"
 	MaxConcurrentRequests       = "maxConcurrentRequests"
+	CircuitBreakerThreshold     = "circuitBreaker.failureThreshold"
+	CircuitBreakerResetDelay    = "circuitBreaker.resetDelay"
+	ReadQuorumURL               = "readQuorum.url"
+	ReadQuorumMode              = "readQuorum.mode"
+	ReadQuorumRetries           = "readQuorum.retries"
+	ReadQuorumRetryDelay        = "readQuorum.retryDelay"
+	ReadQuorumAuthUsername      = "readQuorum.auth.username"
+	ReadQuorumAuthPassword      = "readQuorum.auth.password"
+	ReadQuorumTLS               = "readQuorum.tls"
 	TxCacheSize                 = "txCacheSize"
+	TokenCacheSize              = "tokenCacheSize"
 	HederaCompatibilityMode     = "hederaCompatibilityMode"
 	TraceTXForRevertReason      = "traceTXForRevertReason"
+	PendingState                = "pendingState"
 	WebSocketsEnabled           = "ws.enabled"
+	IPCPath                     = "ipc.path"
+	IPCDialTimeout              = "ipc.dialTimeout"
+	ChainProfile                = "chainProfile"
+	ReceiptsNotFoundRetries     = "receipts.notFoundRetries"
+	ReceiptsNotFoundRetryDelay  = "receipts.notFoundRetryDelay"
+	ReceiptsNotFoundGracePeriod = "receipts.notFoundGracePeriod"
+	AuthOAuth2TokenURL          = "auth.oauth2.tokenURL"
+	AuthOAuth2ClientID          = "auth.oauth2.clientID"
+	AuthOAuth2ClientSecret      = "auth.oauth2.clientSecret"
+	AuthOAuth2Scopes            = "auth.oauth2.scopes"
+	AuthSigV4Region             = "auth.sigv4.region"
+	AuthSigV4Service            = "auth.sigv4.service"
+	AuthSigV4AccessKeyID        = "auth.sigv4.accessKeyID"
+	AuthSigV4SecretAccessKey    = "auth.sigv4.secretAccessKey"
+	AuthSigV4SessionToken       = "auth.sigv4.sessionToken"
+	ProxyUsername               = "proxy.username"
+	ProxyPassword               = "proxy.password"
+	ProxyNoProxy                = "proxy.noProxy"
+	RawTransactionsMaxFeePerGas = "rawTransactions.maxFeePerGas"
+	RawTransactionsValidate     = "rawTransactions.validate"
+	GraphQLURL                  = "graphql.url"
+	TracingEnabled              = "tracing.enabled"
+	TracingOTLPURL              = "tracing.otlp.url"
+	TracingOTLPServiceName      = "tracing.otlp.serviceName"
+	ENSEnabled                  = "ens.enabled"
+	ENSRegistry                 = "ens.registry"
+	ENSCacheSize                = "ens.cacheSize"
+	ENSCacheTTL                 = "ens.cacheTTL"
+	SubmissionMaxHeadAge        = "submission.maxHeadAge"
+	SubmissionRejectSyncing     = "submission.rejectWhileSyncing"
+	SubmissionMaxDataSize       = "submission.maxDataSize"
+	SubmissionIntrinsicGasCheck = "submission.checkIntrinsicGas"
+	SubmissionBalanceCheck      = "submission.checkBalance"
+	SubmissionIdempotencyWindow = "submission.idempotency.window"
+	SubmissionIdempotencySize   = "submission.idempotency.cacheSize"
+	SubmissionPreSubmitHook     = "submission.hooks.preSubmitURL"
+	SubmissionPostSubmitHook    = "submission.hooks.postSubmitURL"
+	SubmissionRelayURL          = "submission.privateRelay.url"
+	SubmissionRelayMethod       = "submission.privateRelay.method"
+	SubmissionRelayMaxBlocks    = "submission.privateRelay.maxBlocks"
+	SubmissionRelayAddresses    = "submission.privateRelay.addresses"
+	SubmissionRelayCacheSize    = "submission.privateRelay.cacheSize"
+	SubmissionRelayAuthUsername = "submission.privateRelay.auth.username"
+	SubmissionRelayAuthPassword = "submission.privateRelay.auth.password"
+	SubmissionRelayTLS          = "submission.privateRelay.tls"
+	SubmissionDependencyTimeout = "submission.dependencies.timeout"
+	SubmissionDependencyConfs   = "submission.dependencies.confirmations"
+	SubmissionDependencySize    = "submission.dependencies.cacheSize"
+	DeployBatchReceiptTimeout   = "deployBatch.receiptTimeout"
+	DeployVerificationEnabled   = "deployVerification.enabled"
+	DeployVerificationCacheSize = "deployVerification.cacheSize"
+	AddressActivitySparseScan   = "addressActivity.sparseScan"
+	ReorgMaxDepth               = "reorg.maxDepth"
+	ReorgAutoResumeDelay        = "reorg.autoResumeDelay"
+	ReorgStatisticsFile         = "reorg.statisticsFile"
+	ShutdownTimeout             = "shutdown.timeout"
+	TrustedCheckpointNumber     = "trustedCheckpoint.blockNumber"
+	TrustedCheckpointHash       = "trustedCheckpoint.blockHash"
+	AdaptiveConcurrencyEnabled  = "adaptiveConcurrency.enabled"
+	AdaptiveConcurrencyMin      = "adaptiveConcurrency.minLimit"
+	AdaptiveConcurrencyInitial  = "adaptiveConcurrency.initialLimit"
+	AdaptiveConcurrencyLatency  = "adaptiveConcurrency.latencyTarget"
+	AdaptiveConcurrencyDecrease = "adaptiveConcurrency.decreaseFactor"
+	RPCPriorityEnabled          = "rpcPriority.enabled"
+	TransactionSearchMaxBlocks  = "transactionSearch.maxBlocks"
+	SnapshotsURL                = "snapshots.url"
+	SnapshotsPrefix             = "snapshots.prefix"
+	SnapshotsInterval           = "snapshots.interval"
+	SnapshotsRetention          = "snapshots.retention"
+	SnapshotsRestore            = "snapshots.restore"
+	SnapshotsRegion             = "snapshots.region"
+	SnapshotsAccessKeyID        = "snapshots.accessKeyID"
+	SnapshotsSecretAccessKey    = "snapshots.secretAccessKey"
+	SnapshotsSessionToken       = "snapshots.sessionToken"
+	Signers                     = "signers"
+	ErrorMappings               = "errorMappings"
 )
 
+// Keys of each entry in the signers array
 const (
-	DefaultListenerPort        = 5102
-	DefaultGasEstimationFactor = 1.5
+	SignerURL           = "url"
+	SignerAddresses     = "addresses"
+	SignerAddressRanges = "addressRanges"
+	SignerAuthUsername  = "auth.username"
+	SignerAuthPassword  = "auth.password"
+	SignerTLS           = "tls"
+)
+
+// Keys added to the tls section of the node and of each additional JSON/RPC endpoint
+const (
+	TLSServerName = "serverName"
+)
+
+// Keys of each entry in the errorMappings array
+const (
+	ErrorMappingMethods = "methods"
+	ErrorMappingCode    = "code"
+	ErrorMappingMessage = "message"
+	ErrorMappingReason  = "reason"
+)
+
+const (
+	DefaultListenerPort              = 5102
+	DefaultGasEstimationFactor       = 1.5
+	DefaultReplacementFeeBumpPercent = 12.5
 
 	DefaultCatchupPageSize             = 500
 	DefaultEventsCatchupThreshold      = 500
 	DefaultEventsCatchupDownscaleRegex = "Response size is larger than.*limit"
+	DefaultEventsCatchupParallelism    = 10
 	DefaultEventsCheckpointBlockGap    = 50
+	DefaultEventsDedupeCacheSize       = 0
+	DefaultEventsWALMaxEntries         = 10000
+	DefaultEventsWildcardEventRate     = 1000
 
 	DefaultRetryInitDelay   = "100ms"
 	DefaultRetryMaxDelay    = "30s"
 	DefaultRetryDelayFactor = 2.0
+
+	DefaultReceiptsNotFoundRetries     = 0
+	DefaultReceiptsNotFoundRetryDelay  = "250ms"
+	DefaultReceiptsNotFoundGracePeriod = "0"
+
+	DefaultAuthSigV4Service = "managedblockchain"
+
+	// The ENS registry is at the same address on Ethereum mainnet and the public testnets
+	DefaultENSRegistry = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
 )
 
 func InitConfig(conf config.Section) {
 	wsclient.InitConfig(conf)
+	conf.SubSection("tls").AddKnownKey(TLSServerName)
 	conf.AddKnownKey(WebSocketsEnabled, false)
+	conf.AddKnownKey(IPCPath)
+	conf.AddKnownKey(IPCDialTimeout, "5s")
 	conf.AddKnownKey(BlockCacheSize, 250)
 	conf.AddKnownKey(BlockPollingInterval, "1s")
+	conf.AddKnownKey(BlockPollingJitter, 0)
+	conf.AddKnownKey(BlockPollingAdaptive, false)
+	conf.AddKnownKey(BlockPollingMinInterval, "100ms")
+	conf.AddKnownKey(BlockPollingMaxInterval, "15s")
 	conf.AddKnownKey(ConfigDataFormat, "map")
+	conf.AddKnownKey(ChecksumAddresses, false)
 	conf.AddKnownKey(ConfigGasEstimationFactor, DefaultGasEstimationFactor)
+	conf.AddKnownKey(ConfigGasEstimationFloor)
+	conf.AddKnownKey(ConfigGasEstimationCeiling)
+	conf.AddKnownKey(ConfigGasEstimationFallback, false)
+	conf.AddKnownKey(ReplacementFeeBumpPercent, DefaultReplacementFeeBumpPercent)
+	conf.AddKnownKey(LegacyFeeFallback, true)
+	conf.AddKnownKey(PriorityFeeEnabled, false)
+	conf.AddKnownKey(PriorityFeeWindow, 20)
+	conf.AddKnownKey(PriorityFeeSpikeClamp, 2.0)
 	conf.AddKnownKey(EventsBlockTimestamps, true)
+	conf.AddKnownKey(EventsSchemaVersion, EventSchemaEVMConnect)
+	conf.AddKnownKey(EventsOrdering, EventOrderingListener)
 	conf.AddKnownKey(EventsFilterPollingInterval, "1s")
 	conf.AddKnownKey(EventsCatchupPageSize, DefaultCatchupPageSize)
+	conf.AddKnownKey(EventsLogIndexSize, 0)
 	conf.AddKnownKey(EventsCatchupThreshold, DefaultEventsCatchupThreshold)
 	conf.AddKnownKey(EventsCatchupDownscaleRegex, DefaultEventsCatchupDownscaleRegex)
+	conf.AddKnownKey(EventsCatchupParallelism, DefaultEventsCatchupParallelism)
+	conf.AddKnownKey(EventsDedupeCacheSize, DefaultEventsDedupeCacheSize)
 	conf.AddKnownKey(EventsCheckpointBlockGap, DefaultEventsCheckpointBlockGap)
+	conf.AddKnownKey(EventsDryRunMaxBlocks, 100000)
+	conf.AddKnownKey(EventsContinuityRescans, 3)
+	conf.AddKnownKey(EventsMaxLogsResponseSize, "100mb")
+	conf.AddKnownKey(EventsWALPath)
+	conf.AddKnownKey(EventsWALMaxEntries, DefaultEventsWALMaxEntries)
+	conf.AddKnownKey(EventsWildcardEventRate, DefaultEventsWildcardEventRate)
+	conf.AddKnownKey(EventsBlockPrefetchDepth, 0)
+	conf.AddKnownKey(EventsSignatureLabels, false)
+	conf.AddKnownKey(EventsSignatureLabelsFile)
+	conf.AddKnownKey(EventsBootstrapDirectory)
+	conf.AddKnownKey(EventsBlockHashQueries, BlockHashQueriesNever)
+	conf.AddKnownKey(EventsBlockHashReorgDepth, 3)
+	conf.AddKnownKey(LeaderElectionURL)
+	conf.AddKnownKey(LeaderElectionName, "evmconnect")
+	conf.AddKnownKey(LeaderElectionInstanceID)
+	conf.AddKnownKey(LeaderElectionLeaseDuration, "15s")
+	conf.AddKnownKey(LeaderElectionRenewInterval, "5s")
+	conf.AddKnownKey(AuditEnabled, false)
+	conf.AddKnownKey(AuditFile)
+	conf.AddKnownKey(AuditMaxSize, "100mb")
+	conf.AddKnownKey(AuditMaxBackups, 5)
+	conf.AddKnownKey(AuditIncludeRequests, false)
+	conf.AddKnownKey(AuditRedactFields, []string{"data", "input", "transactionData", "params"})
 	conf.AddKnownKey(RetryFactor, DefaultRetryDelayFactor)
 	conf.AddKnownKey(RetryInitDelay, DefaultRetryInitDelay)
 	conf.AddKnownKey(RetryMaxDelay, DefaultRetryMaxDelay)
+	conf.AddKnownKey(CircuitBreakerThreshold, 0)
+	conf.AddKnownKey(CircuitBreakerResetDelay, "10s")
+	conf.AddKnownKey(ReadQuorumURL)
+	conf.AddKnownKey(ReadQuorumMode, string(QuorumPreferPrimary))
+	conf.AddKnownKey(ReadQuorumRetries, 3)
+	conf.AddKnownKey(ReadQuorumRetryDelay, "1s")
+	conf.AddKnownKey(ReadQuorumAuthUsername)
+	conf.AddKnownKey(ReadQuorumAuthPassword)
+	initEndpointTLSConfig(conf.SubSection(ReadQuorumTLS))
 	conf.AddKnownKey(MaxConcurrentRequests, 50)
 	conf.AddKnownKey(TxCacheSize, 250)
+	conf.AddKnownKey(TokenCacheSize, 250)
 	conf.AddKnownKey(HederaCompatibilityMode, false)
 	conf.AddKnownKey(TraceTXForRevertReason, false)
+	conf.AddKnownKey(PendingState, false)
+	conf.AddKnownKey(ChainProfile)
+	conf.AddKnownKey(ReceiptsNotFoundRetries, DefaultReceiptsNotFoundRetries)
+	conf.AddKnownKey(ReceiptsNotFoundRetryDelay, DefaultReceiptsNotFoundRetryDelay)
+	conf.AddKnownKey(ReceiptsNotFoundGracePeriod, DefaultReceiptsNotFoundGracePeriod)
+	conf.AddKnownKey(AuthOAuth2TokenURL)
+	conf.AddKnownKey(AuthOAuth2ClientID)
+	conf.AddKnownKey(AuthOAuth2ClientSecret)
+	conf.AddKnownKey(AuthOAuth2Scopes)
+	conf.AddKnownKey(AuthSigV4Region)
+	conf.AddKnownKey(AuthSigV4Service, DefaultAuthSigV4Service)
+	conf.AddKnownKey(AuthSigV4AccessKeyID)
+	conf.AddKnownKey(AuthSigV4SecretAccessKey)
+	conf.AddKnownKey(AuthSigV4SessionToken)
+	conf.AddKnownKey(ProxyUsername)
+	conf.AddKnownKey(ProxyPassword)
+	conf.AddKnownKey(ProxyNoProxy)
+	conf.AddKnownKey(RawTransactionsMaxFeePerGas)
+	conf.AddKnownKey(RawTransactionsValidate, false)
+	conf.AddKnownKey(GraphQLURL)
+	conf.AddKnownKey(TracingEnabled, false)
+	conf.AddKnownKey(TracingOTLPURL)
+	conf.AddKnownKey(TracingOTLPServiceName, "evmconnect")
+	conf.AddKnownKey(ENSEnabled, false)
+	conf.AddKnownKey(ENSRegistry, DefaultENSRegistry)
+	conf.AddKnownKey(ENSCacheSize, 100)
+	conf.AddKnownKey(ENSCacheTTL, "5m")
+	conf.AddKnownKey(SubmissionMaxHeadAge, "0")
+	conf.AddKnownKey(SubmissionRejectSyncing, false)
+	conf.AddKnownKey(SubmissionMaxDataSize, "0")
+	conf.AddKnownKey(SubmissionIntrinsicGasCheck, false)
+	conf.AddKnownKey(SubmissionBalanceCheck, false)
+	conf.AddKnownKey(SubmissionIdempotencyWindow, "0")
+	conf.AddKnownKey(SubmissionIdempotencySize, 1000)
+	conf.AddKnownKey(SubmissionPreSubmitHook)
+	conf.AddKnownKey(SubmissionPostSubmitHook)
+	conf.AddKnownKey(SubmissionRelayURL)
+	conf.AddKnownKey(SubmissionRelayMethod, RelayMethodPrivateTransaction)
+	conf.AddKnownKey(SubmissionRelayMaxBlocks, 25)
+	conf.AddKnownKey(SubmissionRelayAddresses)
+	conf.AddKnownKey(SubmissionRelayCacheSize, 1000)
+	conf.AddKnownKey(SubmissionRelayAuthUsername)
+	conf.AddKnownKey(SubmissionRelayAuthPassword)
+	initEndpointTLSConfig(conf.SubSection(SubmissionRelayTLS))
+	conf.AddKnownKey(SubmissionDependencyTimeout, "5m")
+	conf.AddKnownKey(SubmissionDependencyConfs, 0)
+	conf.AddKnownKey(SubmissionDependencySize, 1000)
+	conf.AddKnownKey(DeployBatchReceiptTimeout, "2m")
+	conf.AddKnownKey(DeployVerificationEnabled, false)
+	conf.AddKnownKey(DeployVerificationCacheSize, 1000)
+	conf.AddKnownKey(AddressActivitySparseScan, false)
+	conf.AddKnownKey(ReorgMaxDepth, 0)
+	conf.AddKnownKey(ReorgAutoResumeDelay, "0")
+	conf.AddKnownKey(ReorgStatisticsFile)
+	conf.AddKnownKey(ShutdownTimeout, "30s")
+	conf.AddKnownKey(TrustedCheckpointNumber, 0)
+	conf.AddKnownKey(TrustedCheckpointHash)
+	conf.AddKnownKey(AdaptiveConcurrencyEnabled, false)
+	conf.AddKnownKey(AdaptiveConcurrencyMin, 1)
+	conf.AddKnownKey(AdaptiveConcurrencyInitial, 10)
+	conf.AddKnownKey(AdaptiveConcurrencyLatency, "0")
+	conf.AddKnownKey(AdaptiveConcurrencyDecrease, 0.5)
+	conf.AddKnownKey(RPCPriorityEnabled, false)
+	conf.AddKnownKey(TransactionSearchMaxBlocks, 1000)
+	conf.AddKnownKey(SnapshotsURL)
+	conf.AddKnownKey(SnapshotsPrefix, "evmconnect/")
+	conf.AddKnownKey(SnapshotsInterval, "5m")
+	conf.AddKnownKey(SnapshotsRetention, 24)
+	conf.AddKnownKey(SnapshotsRestore)
+	conf.AddKnownKey(SnapshotsRegion, "us-east-1")
+	conf.AddKnownKey(SnapshotsAccessKeyID)
+	conf.AddKnownKey(SnapshotsSecretAccessKey)
+	conf.AddKnownKey(SnapshotsSessionToken)
+	initSignersConfig(conf.SubArray(Signers))
+	initErrorMappingsConfig(conf.SubArray(ErrorMappings))
 }
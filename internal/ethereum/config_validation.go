@@ -0,0 +1,71 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-common/pkg/wsclient"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+)
+
+// validateConfig cross-checks related settings at startup, after the chain profile is applied. Combinations
+// that would otherwise be silently ignored, or only misbehave at runtime, fail with an error naming the settings.
+func (c *ethConnector) validateConfig(ctx context.Context, conf config.Section) error {
+	// Existing deployments set these harmlessly while WebSockets are disabled, so they only warn
+	if !conf.GetBool(WebSocketsEnabled) {
+		for _, key := range []string{wsclient.WSConfigURL, wsclient.WSConfigKeyPath} {
+			if conf.GetString(key) != "" {
+				log.L(ctx).Warn(i18n.NewError(ctx, msgs.MsgConfigHasNoEffect, key, WebSocketsEnabled))
+			}
+		}
+	}
+
+	maxReorgDepth := conf.GetInt64(ReorgMaxDepth)
+	if maxReorgDepth <= 0 && conf.GetDuration(ReorgAutoResumeDelay) > 0 {
+		return i18n.NewError(ctx, msgs.MsgConfigHasNoEffect, ReorgAutoResumeDelay, ReorgMaxDepth)
+	}
+	// The block listener only holds the unstable head of the chain in memory, so cannot detect deeper re-orgs
+	if maxReorgDepth > c.checkpointBlockGap {
+		return i18n.NewError(ctx, msgs.MsgReorgDepthBeyondHead, ReorgMaxDepth, maxReorgDepth, c.checkpointBlockGap, EventsCheckpointBlockGap)
+	}
+
+	if conf.GetString(LeaderElectionURL) != "" {
+		leaseDuration, renewInterval := conf.GetDuration(LeaderElectionLeaseDuration), conf.GetDuration(LeaderElectionRenewInterval)
+		if leaseDuration <= renewInterval+renewInterval/2 {
+			return i18n.NewError(ctx, msgs.MsgLeaseTooShort, LeaderElectionLeaseDuration, leaseDuration, LeaderElectionRenewInterval, renewInterval)
+		}
+	}
+
+	if feeBumpPercent := conf.GetFloat64(ReplacementFeeBumpPercent); feeBumpPercent < minReplacementFeeBumpPercent {
+		return i18n.NewError(ctx, msgs.MsgFeeBumpPercentTooLow, ReplacementFeeBumpPercent, feeBumpPercent, minReplacementFeeBumpPercent)
+	}
+
+	cacheSizes := []string{BlockCacheSize, TxCacheSize, TokenCacheSize, SubmissionDependencySize}
+	if c.sendIdempotencyWindow > 0 {
+		cacheSizes = append(cacheSizes, SubmissionIdempotencySize)
+	}
+	for _, key := range cacheSizes {
+		if size := conf.GetInt(key); size <= 0 {
+			return i18n.NewError(ctx, msgs.MsgInvalidCacheSize, key, size)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/wsclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+
+	for _, tc := range []struct {
+		setup func(conf config.Section)
+		err   string
+	}{
+		{func(conf config.Section) { conf.Set(ReorgAutoResumeDelay, "1m") }, "FF23183.*reorg.autoResumeDelay.*reorg.maxDepth"},
+		{func(conf config.Section) { conf.Set(ReorgMaxDepth, 51) }, "FF23184.*reorg.maxDepth.*51.*50.*events.checkpointBlockGap"},
+		{func(conf config.Section) {
+			conf.Set(ChainProfile, "avalanche")
+			conf.Set(ReorgMaxDepth, 2)
+		}, "FF23184.*2.*1 blocks"},
+		{func(conf config.Section) { conf.Set(ReplacementFeeBumpPercent, 5) }, "FF23202.*replacementFeeBumpPercent 5"},
+		{func(conf config.Section) { conf.Set(TokenCacheSize, 0) }, "FF23185.*tokenCacheSize.*0"},
+		{func(conf config.Section) { conf.Set(SubmissionDependencySize, 0) }, "FF23185.*submission.dependencies.cacheSize"},
+		{func(conf config.Section) {
+			conf.Set(LeaderElectionURL, "postgres://localhost:1/evmconnect")
+			conf.Set(LeaderElectionRenewInterval, "10s")
+		}, "FF23196.*leaderElection.leaseDuration.*15s.*leaderElection.renewInterval.*10s"},
+	} {
+		conf := newTestAuthConf(t, "http://localhost:8545")
+		tc.setup(conf)
+		_, err := NewEthereumConnector(context.Background(), conf)
+		assert.Regexp(t, tc.err, err)
+	}
+
+	// Related settings that are consistent
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(WebSocketsEnabled, true)
+	conf.Set(wsclient.WSConfigURL, "ws://localhost:8546")
+	conf.Set(ReorgMaxDepth, 50)
+	conf.Set(ReorgAutoResumeDelay, "1m")
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+
+	// WebSocket settings while WebSockets are disabled only warn
+	conf = newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(wsclient.WSConfigURL, "ws://localhost:8546")
+	conf.Set(wsclient.WSConfigKeyPath, "/ws")
+	_, err = NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+
+}
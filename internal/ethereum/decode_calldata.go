@@ -0,0 +1,79 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type DecodeCallDataRequest struct {
+	CallData   string           `json:"callData"`             // hex, with or without the 0x prefix
+	Method     *fftypes.JSONAny `json:"method,omitempty"`     // the ABI entry of the function that was called
+	Definition *fftypes.JSONAny `json:"definition,omitempty"` // the ABI of a contract, searched for the function that matches the selector
+}
+
+// DecodeCallData is the reverse of EncodeCallData, returning the method and parameters of call data, such as
+// a transaction queued in a multisig wallet, so that it can be audited or displayed. The ABI must be supplied,
+// as either the method that was called, or the whole ABI of the contract.
+func (c *ethConnector) DecodeCallData(ctx context.Context, req *DecodeCallDataRequest) (*DecodedCallData, ffcapi.ErrorReason, error) {
+	if (req.Method == nil) == (req.Definition == nil) {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgDecodeTargetInvalid)
+	}
+	callData, err := ethtypes.NewHexBytes0xPrefix(req.CallData)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidCallData, req.CallData, err)
+	}
+
+	var method *abi.Entry
+	if req.Method != nil {
+		if err := json.Unmarshal(req.Method.Bytes(), &method); err != nil {
+			return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgUnmarshalABIMethodFail, err)
+		}
+	} else if method, err = findMethodForCallData(ctx, req.Definition, callData); err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+
+	decoded, err := c.decodeCallData(ctx, method, callData)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	return decoded, "", nil
+}
+
+func findMethodForCallData(ctx context.Context, definition *fftypes.JSONAny, callData []byte) (*abi.Entry, error) {
+	var a abi.ABI
+	if err := json.Unmarshal(definition.Bytes(), &a); err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgUnmarshalABIMethodFail, err)
+	}
+	if len(callData) >= 4 {
+		for _, e := range a {
+			if e.IsFunction() && bytes.Equal(e.FunctionSelectorBytes(), callData[0:4]) {
+				return e, nil
+			}
+		}
+	}
+	return nil, i18n.NewError(ctx, msgs.MsgNoMethodForSelector, ethtypes.HexBytes0xPrefix(callData[0:min(4, len(callData))]))
+}
@@ -0,0 +1,128 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleSetCallData = "0x60fe47b100000000000000000000000000000000000000000000000000000000feedbeef"
+
+const sampleContractABI = `[
+	{"name": "get", "type": "function", "inputs": [], "outputs": [{"type": "uint256"}]},
+	{"name": "Set", "type": "event", "inputs": [{"name": "x", "type": "uint256"}]},
+	{"name": "set", "type": "function", "inputs": [{"name": "x", "type": "uint256"}]}
+]`
+
+func TestDecodeCallDataMethod(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	res, reason, err := c.DecodeCallData(ctx, &DecodeCallDataRequest{
+		CallData: sampleSetCallData,
+		Method:   fftypes.JSONAnyPtr(`{"name": "set", "type": "function", "inputs": [{"name": "x", "type": "uint256"}]}`),
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, "set(uint256)", res.Method)
+	assert.Equal(t, "0x60fe47b1", res.Selector)
+	assert.Equal(t, 36, res.CallDataSize)
+	assert.JSONEq(t, `{"x": "4276993775"}`, res.Params.String())
+
+}
+
+func TestDecodeCallDataDefinition(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	res, _, err := c.DecodeCallData(ctx, &DecodeCallDataRequest{
+		CallData:   sampleSetCallData[2:],
+		Definition: fftypes.JSONAnyPtr(sampleContractABI),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "set", res.Name)
+	assert.JSONEq(t, `{"x": "4276993775"}`, res.Params.String())
+
+	// Round trips with the encoding
+	enc, _, err := c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Method: fftypes.JSONAnyPtr(`{"name": "get", "type": "function", "inputs": []}`),
+	})
+	assert.NoError(t, err)
+	res, _, err = c.DecodeCallData(ctx, &DecodeCallDataRequest{
+		CallData:   enc.CallData.String(),
+		Definition: fftypes.JSONAnyPtr(sampleContractABI),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "get()", res.Method)
+	assert.JSONEq(t, `{}`, res.Params.String())
+
+}
+
+func TestDecodeCallDataErrors(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.DecodeCallData(ctx, &DecodeCallDataRequest{CallData: sampleSetCallData})
+	assert.Regexp(t, "FF23160", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, _, err = c.DecodeCallData(ctx, &DecodeCallDataRequest{
+		CallData:   "not hex",
+		Definition: fftypes.JSONAnyPtr(sampleContractABI),
+	})
+	assert.Regexp(t, "FF23161", err)
+
+	_, _, err = c.DecodeCallData(ctx, &DecodeCallDataRequest{
+		CallData: sampleSetCallData,
+		Method:   fftypes.JSONAnyPtr(`[]`),
+	})
+	assert.Regexp(t, "FF23013", err)
+
+	_, _, err = c.DecodeCallData(ctx, &DecodeCallDataRequest{
+		CallData:   sampleSetCallData,
+		Definition: fftypes.JSONAnyPtr(`{}`),
+	})
+	assert.Regexp(t, "FF23013", err)
+
+	_, _, err = c.DecodeCallData(ctx, &DecodeCallDataRequest{
+		CallData:   "0xa9059cbb",
+		Definition: fftypes.JSONAnyPtr(sampleContractABI),
+	})
+	assert.Regexp(t, "FF23162.*0xa9059cbb", err)
+
+	_, _, err = c.DecodeCallData(ctx, &DecodeCallDataRequest{
+		CallData:   "0x60fe",
+		Definition: fftypes.JSONAnyPtr(sampleContractABI),
+	})
+	assert.Regexp(t, "FF23162.*0x60fe", err)
+
+	// Truncated arguments
+	_, reason, err = c.DecodeCallData(ctx, &DecodeCallDataRequest{
+		CallData:   sampleSetCallData[0:20],
+		Definition: fftypes.JSONAnyPtr(sampleContractABI),
+	})
+	assert.Regexp(t, "FF23119", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
@@ -0,0 +1,238 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// deployRefRegex matches references to the address of an earlier contract in the batch, such as "${token}"
+var deployRefRegex = regexp.MustCompile(`\$\{([^${}]+)\}`)
+
+type DeployContractsRequest struct {
+	From      string              `json:"from"`
+	GasPrice  *fftypes.JSONAny    `json:"gasPrice,omitempty"` // in any format accepted by TransactionSend - estimated once for the batch if not set
+	Contracts []*ContractToDeploy `json:"contracts"`
+}
+
+// ContractToDeploy is one contract in a batch. String values in the params can reference the address of another
+// contract in the batch as "${name}", and the contract is then deployed after the one it references.
+type ContractToDeploy struct {
+	Name       string             `json:"name"`
+	DependsOn  []string           `json:"dependsOn,omitempty"` // additional ordering constraints, not referenced in the params
+	Definition *fftypes.JSONAny   `json:"definition"`
+	Contract   *fftypes.JSONAny   `json:"contract"`
+	Params     []*fftypes.JSONAny `json:"params"`
+	Errors     []*fftypes.JSONAny `json:"errors,omitempty"`
+	Gas        *fftypes.FFBigInt  `json:"gas,omitempty"`
+	Value      *fftypes.FFBigInt  `json:"value,omitempty"`
+}
+
+type DeployContractsResponse struct {
+	Contracts []*DeployedContract `json:"contracts"` // in the order they were deployed
+}
+
+type DeployedContract struct {
+	Name            string                             `json:"name"`
+	Address         string                             `json:"address"`
+	TransactionHash string                             `json:"transactionHash"`
+	Receipt         *ffcapi.TransactionReceiptResponse `json:"receipt"`
+}
+
+// DeployContracts deploys a set of contracts in dependency order, each one after the receipt of the contracts
+// it depends on, so their addresses can be passed to its constructor. The nonces are assigned by the signer.
+// The batch stops at the first failure, and the contracts deployed up to that point are returned with the error.
+func (c *ethConnector) DeployContracts(ctx context.Context, req *DeployContractsRequest) (*DeployContractsResponse, ffcapi.ErrorReason, error) {
+	ordered, err := orderDeployments(ctx, req.Contracts)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	if reason, err := c.checkLeader(ctx); err != nil {
+		return nil, reason, err
+	}
+	done, reason, err := c.beginWork(ctx)
+	if err != nil {
+		return nil, reason, err
+	}
+	defer done()
+
+	gasPrice := req.GasPrice
+	if gasPrice == nil {
+		gasPriceRes, reason, err := c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+		if err != nil {
+			return nil, reason, err
+		}
+		gasPrice = gasPriceRes.GasPrice
+	}
+
+	res := &DeployContractsResponse{Contracts: make([]*DeployedContract, 0, len(ordered))}
+	addresses := make(map[string]string, len(ordered))
+	for _, d := range ordered {
+		deployed, reason, err := c.deployBatchContract(ctx, req.From, gasPrice, d, addresses)
+		if err != nil {
+			log.L(ctx).Errorf("Batch deployment stopped at '%s' after deploying %d of %d contracts", d.Name, len(res.Contracts), len(ordered))
+			return res, reason, i18n.NewError(ctx, msgs.MsgDeployBatchFailed, d.Name, err)
+		}
+		log.L(ctx).Infof("Batch deployed contract '%s' at %s (tx=%s)", d.Name, deployed.Address, deployed.TransactionHash)
+		addresses[d.Name] = deployed.Address
+		res.Contracts = append(res.Contracts, deployed)
+	}
+	return res, "", nil
+}
+
+func (c *ethConnector) deployBatchContract(ctx context.Context, from string, gasPrice *fftypes.JSONAny, d *ContractToDeploy, addresses map[string]string) (*DeployedContract, ffcapi.ErrorReason, error) {
+	params := make([]*fftypes.JSONAny, len(d.Params))
+	for i, p := range d.Params {
+		if p != nil {
+			params[i] = fftypes.JSONAnyPtr(deployRefRegex.ReplaceAllStringFunc(p.String(), func(ref string) string {
+				return addresses[deployRefRegex.FindStringSubmatch(ref)[1]]
+			}))
+		}
+	}
+
+	callData, constructor, err := c.prepareDeployData(ctx, &ffcapi.ContractDeployPrepareRequest{
+		Definition: d.Definition,
+		Contract:   d.Contract,
+		Params:     params,
+	})
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	tx, err := c.buildTx(ctx, txTypeDeployContract, from, "", nil, d.Gas, d.Value, callData)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	errors, err := buildErrorsABI(ctx, d.Errors)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	gas, reason, err := c.ensureGasEstimate(ctx, tx, constructor, errors, d.Gas)
+	if err != nil {
+		return nil, reason, err
+	}
+	tx.GasLimit = (*ethtypes.HexInteger)(gas)
+	if err := c.mapGasPrice(ctx, gasPrice, tx); err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+
+	hookTx := unsignedHookTransaction(tx)
+	if reason, err := c.runPreSubmitHook(ctx, hookTx, tx); err != nil {
+		return nil, reason, err
+	}
+	var txHash ethtypes.HexBytes0xPrefix
+	if rpcErr := c.signingBackend(ctx, from).CallRPC(ctx, &txHash, "eth_sendTransaction", tx); rpcErr != nil {
+		return nil, c.mapRPCError(sendRPCMethods, rpcErr), rpcErr.Error()
+	}
+	c.runPostSubmitHook(ctx, hookTx, txHash.String())
+
+	receipt, reason, err := c.waitForDeployReceipt(ctx, txHash.String())
+	if err != nil {
+		return nil, reason, err
+	}
+	if !receipt.Success {
+		return nil, ffcapi.ErrorReasonTransactionReverted, i18n.NewError(ctx, msgs.MsgDeployBatchReverted, d.Name, txHash)
+	}
+	return &DeployedContract{
+		Name:            d.Name,
+		Address:         receipt.ContractLocation.JSONObject().GetString("address"),
+		TransactionHash: txHash.String(),
+		Receipt:         receipt,
+	}, "", nil
+}
+
+// waitForDeployReceipt polls for the receipt with the standard retry backoff, until the receipt timeout
+func (c *ethConnector) waitForDeployReceipt(ctx context.Context, txHash string) (*ffcapi.TransactionReceiptResponse, ffcapi.ErrorReason, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.deployBatchReceiptTimeout)
+	defer cancel()
+	for attempt := 0; ; attempt++ {
+		if c.doFailureDelay(ctx, attempt) {
+			return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgReceiptNotAvailable, txHash)
+		}
+		receipt, _, err := c.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+		if err == nil {
+			return receipt, "", nil
+		}
+		log.L(ctx).Debugf("Waiting for deployment receipt %s: %s", txHash, err)
+	}
+}
+
+// orderDeployments sorts the contracts so each is after those it depends on, otherwise keeping the order supplied
+func orderDeployments(ctx context.Context, contracts []*ContractToDeploy) ([]*ContractToDeploy, error) {
+	deps := make(map[string][]string, len(contracts))
+	for _, d := range contracts {
+		if _, dup := deps[d.Name]; dup || d.Name == "" {
+			return nil, i18n.NewError(ctx, msgs.MsgDeployBatchDuplicateName, d.Name)
+		}
+		deps[d.Name] = append([]string{}, d.DependsOn...)
+		for _, p := range d.Params {
+			if p != nil {
+				for _, ref := range deployRefRegex.FindAllStringSubmatch(p.String(), -1) {
+					deps[d.Name] = append(deps[d.Name], ref[1])
+				}
+			}
+		}
+	}
+	for _, d := range contracts {
+		for _, dep := range deps[d.Name] {
+			if _, ok := deps[dep]; !ok {
+				return nil, i18n.NewError(ctx, msgs.MsgDeployBatchUnknownDep, d.Name, dep)
+			}
+		}
+	}
+
+	ordered := make([]*ContractToDeploy, 0, len(contracts))
+	done := make(map[string]bool, len(contracts))
+	for len(ordered) < len(contracts) {
+		progressed := false
+		for _, d := range contracts {
+			if !done[d.Name] && allDone(done, deps[d.Name]) {
+				ordered = append(ordered, d)
+				done[d.Name] = true
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			var remaining []string
+			for _, d := range contracts {
+				if !done[d.Name] {
+					remaining = append(remaining, d.Name)
+				}
+			}
+			return nil, i18n.NewError(ctx, msgs.MsgDeployBatchCycle, strings.Join(remaining, ","))
+		}
+	}
+	return ordered, nil
+}
+
+func allDone(done map[string]bool, names []string) bool {
+	for _, n := range names {
+		if !done[n] {
+			return false
+		}
+	}
+	return true
+}
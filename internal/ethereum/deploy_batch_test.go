@@ -0,0 +1,312 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleDeployBatch = `{
+	"from": "0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8",
+	"gasPrice": "1000",
+	"contracts": [
+		{
+			"name": "market",
+			"contract": "0xfeedbeef",
+			"definition": [{"type":"constructor","inputs":[{"name":"token","type":"address"},{"name":"registry","type":"address"}]}],
+			"params": ["${token}", "${registry}"],
+			"gas": 1000000
+		},
+		{
+			"name": "token",
+			"contract": "0xfeedbeef",
+			"definition": [],
+			"params": [],
+			"gas": 1000000
+		},
+		{
+			"name": "registry",
+			"dependsOn": ["token"],
+			"contract": "0xfeedbeef",
+			"definition": [],
+			"params": [],
+			"gas": 1000000
+		}
+	]
+}`
+
+func testDeployBatchRequest(t *testing.T) *DeployContractsRequest {
+	var req DeployContractsRequest
+	err := json.Unmarshal([]byte(sampleDeployBatch), &req)
+	assert.NoError(t, err)
+	return &req
+}
+
+// mockDeployments returns a deterministic hash and contract address for each deployment, in order,
+// with the receipt status from the supplied list
+func mockDeployments(mRPC *rpcbackendmocks.Backend, statuses ...string) *[]*ethsigner.Transaction {
+	var sent []*ethsigner.Transaction
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Run(func(args mock.Arguments) {
+			sent = append(sent, args[3].(*ethsigner.Transaction))
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(fmt.Sprintf("0x%064x", len(sent)))
+		}).
+		Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Run(func(args mock.Arguments) {
+			var n int
+			_, _ = fmt.Sscanf(args[3].(string), "0x%x", &n)
+			err := json.Unmarshal([]byte(fmt.Sprintf(`{
+				"blockHash": "0x6197ef1a58a2a592bb447efb651f0db7945de21aa8048801b250bd7b7431f9b6",
+				"blockNumber": "0x7b9",
+				"contractAddress": "0x%040x",
+				"status": "%s",
+				"transactionHash": "%s",
+				"transactionIndex": "0x0"
+			}`, 0xc0ffee00+n, statuses[n-1], args[3])), args[1])
+			if err != nil {
+				panic(err)
+			}
+		}).
+		Return(nil)
+	return &sent
+}
+
+func TestDeployContractsDependencyOrder(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	sent := mockDeployments(mRPC, "0x1", "0x1", "0x1")
+
+	res, reason, err := c.DeployContracts(ctx, testDeployBatchRequest(t))
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+	assert.Len(t, res.Contracts, 3)
+	assert.Equal(t, "token", res.Contracts[0].Name)
+	assert.Equal(t, "0x00000000000000000000000000000000c0ffee01", res.Contracts[0].Address)
+	assert.Equal(t, "registry", res.Contracts[1].Name)
+	assert.Equal(t, "0x00000000000000000000000000000000c0ffee02", res.Contracts[1].Address)
+	assert.Equal(t, "market", res.Contracts[2].Name)
+	assert.Equal(t, "0x00000000000000000000000000000000c0ffee03", res.Contracts[2].Address)
+	assert.True(t, res.Contracts[2].Receipt.Success)
+	assert.Equal(t, fmt.Sprintf("0x%064x", 3), res.Contracts[2].TransactionHash)
+
+	// The constructor of the market contract is passed the addresses of the others
+	assert.Len(t, *sent, 3)
+	marketTX := (*sent)[2]
+	assert.Equal(t, "0xfeedbeef"+
+		"00000000000000000000000000000000000000000000000000000000c0ffee01"+
+		"00000000000000000000000000000000000000000000000000000000c0ffee02", marketTX.Data.String())
+	assert.Nil(t, marketTX.To)
+	assert.Nil(t, marketTX.Nonce)
+	assert.Equal(t, int64(1000), marketTX.GasPrice.BigInt().Int64())
+	assert.Equal(t, int64(1000000), marketTX.GasLimit.BigInt().Int64())
+
+}
+
+func TestDeployContractsRevertStopsBatch(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_gasPrice").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(12345)
+		}).
+		Return(nil)
+	sent := mockDeployments(mRPC, "0x1", "0x0")
+
+	req := testDeployBatchRequest(t)
+	req.GasPrice = nil
+	res, reason, err := c.DeployContracts(ctx, req)
+	assert.Regexp(t, "FF23116.*registry.*FF23117", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, reason)
+
+	// The contracts deployed before the failure are returned
+	assert.Len(t, res.Contracts, 1)
+	assert.Equal(t, "token", res.Contracts[0].Name)
+	assert.Len(t, *sent, 2)
+	assert.Equal(t, int64(12345), (*sent)[0].GasPrice.BigInt().Int64())
+
+}
+
+func TestDeployContractsReceiptTimeout(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(DeployBatchReceiptTimeout, "50ms")
+		conf.Set(RetryInitDelay, "10ms")
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(sampleRawTXHash)
+		}).
+		Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte("null"), args[1])
+			assert.NoError(t, err)
+		}).
+		Return(nil)
+
+	res, reason, err := c.DeployContracts(ctx, testDeployBatchRequest(t))
+	assert.Regexp(t, "FF23116.*token", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+	assert.Empty(t, res.Contracts)
+
+}
+
+func TestDeployContractsSendFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "insufficient funds for gas * price + value"})
+
+	_, reason, err := c.DeployContracts(ctx, testDeployBatchRequest(t))
+	assert.Regexp(t, "FF23116.*token.*insufficient funds", err)
+	assert.Equal(t, ffcapi.ErrorReasonInsufficientFunds, reason)
+
+}
+
+func TestDeployContractsBadInputs(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_gasPrice").Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	req := testDeployBatchRequest(t)
+	req.GasPrice = nil
+	_, _, err := c.DeployContracts(ctx, req)
+	assert.Regexp(t, "pop", err)
+
+	req = testDeployBatchRequest(t)
+	req.Contracts[1].Contract = fftypes.JSONAnyPtr(`"not bytecode"`)
+	res, reason, err := c.DeployContracts(ctx, req)
+	assert.Regexp(t, "FF23116.*token.*FF23047", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+	assert.Empty(t, res.Contracts)
+
+	req = testDeployBatchRequest(t)
+	req.From = "wrong"
+	_, reason, err = c.DeployContracts(ctx, req)
+	assert.Regexp(t, "FF23116.*token", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	req = testDeployBatchRequest(t)
+	req.Contracts[1].Errors = []*fftypes.JSONAny{fftypes.JSONAnyPtr(`"wrong"`)}
+	_, reason, err = c.DeployContracts(ctx, req)
+	assert.Regexp(t, "FF23116.*token", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	req = testDeployBatchRequest(t)
+	req.GasPrice = fftypes.JSONAnyPtr(`{"gasPrice": false}`)
+	_, reason, err = c.DeployContracts(ctx, req)
+	assert.Regexp(t, "FF23116.*token", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").Return(nil).Once()
+	req = testDeployBatchRequest(t)
+	req.Contracts[1].Gas = nil
+	_, _, err = c.DeployContracts(ctx, req)
+	assert.Regexp(t, "FF23116.*token", err)
+
+	req = testDeployBatchRequest(t)
+	req.Contracts[0].DependsOn = []string{"unknown"}
+	res, reason, err = c.DeployContracts(ctx, req)
+	assert.Regexp(t, "FF23114", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+	assert.Nil(t, res)
+
+}
+
+func TestDeployContractsPreSubmitHookVeto(t *testing.T) {
+
+	preHook := newTestHookServer(t, 200, `{"approved": false, "reason": "not on the allow list"}`, make(chan *SubmissionHookTransaction, 1))
+	defer preHook.Close()
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionPreSubmitHook, preHook.URL)
+	})
+	defer done()
+
+	_, reason, err := c.DeployContracts(ctx, testDeployBatchRequest(t))
+	assert.Regexp(t, "FF23116.*token.*FF23111.*not on the allow list", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+	mRPC.AssertNotCalled(t, "CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything)
+
+}
+
+func TestOrderDeploymentsErrors(t *testing.T) {
+
+	ctx := context.Background()
+	deployment := func(name string, params ...string) *ContractToDeploy {
+		d := &ContractToDeploy{Name: name}
+		for _, p := range params {
+			d.Params = append(d.Params, fftypes.JSONAnyPtr(p))
+		}
+		return d
+	}
+
+	_, err := orderDeployments(ctx, []*ContractToDeploy{deployment("a"), deployment("a")})
+	assert.Regexp(t, "FF23113.*'a'", err)
+
+	_, err = orderDeployments(ctx, []*ContractToDeploy{deployment("")})
+	assert.Regexp(t, "FF23113", err)
+
+	_, err = orderDeployments(ctx, []*ContractToDeploy{deployment("a", `"${b}"`)})
+	assert.Regexp(t, "FF23114.*'a'.*'b'", err)
+
+	_, err = orderDeployments(ctx, []*ContractToDeploy{
+		deployment("a"),
+		deployment("b", `{"x": "${c}"}`),
+		deployment("c", `["${b}"]`, "null"),
+	})
+	assert.Regexp(t, "FF23115.*b,c", err)
+
+	ordered, err := orderDeployments(ctx, []*ContractToDeploy{
+		deployment("a", `"${b}${c}"`),
+		deployment("b", `"${c}"`),
+		deployment("c"),
+	})
+	assert.NoError(t, err)
+	names := make([]string, len(ordered))
+	for i, d := range ordered {
+		names[i] = d.Name
+	}
+	assert.Equal(t, "c,b,a", strings.Join(names, ","))
+
+}
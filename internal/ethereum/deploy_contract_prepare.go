@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"strings"
 
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
@@ -64,17 +65,9 @@ func (c *ethConnector) DeployContractPrepare(ctx context.Context, req *ffcapi.Co
 }
 
 func (c *ethConnector) prepareDeployData(ctx context.Context, req *ffcapi.ContractDeployPrepareRequest) ([]byte, *abi.Entry, error) {
-	// Parse the bytecode as a hex string, or fallback to Base64
-	var bytecodeString string
-	if err := req.Contract.Unmarshal(ctx, &bytecodeString); err != nil {
-		return nil, nil, i18n.NewError(ctx, msgs.MsgDecodeBytecodeFailed)
-	}
-	bytecode, err := hex.DecodeString(strings.TrimPrefix(bytecodeString, "0x"))
+	bytecode, err := parseBytecode(ctx, req.Contract)
 	if err != nil {
-		bytecode, err = base64.StdEncoding.DecodeString(bytecodeString)
-		if err != nil {
-			return nil, nil, i18n.NewError(ctx, msgs.MsgDecodeBytecodeFailed)
-		}
+		return nil, nil, err
 	}
 
 	// Parse the ABI
@@ -88,24 +81,12 @@ func (c *ethConnector) prepareDeployData(ctx context.Context, req *ffcapi.Contra
 	method := a.Constructor()
 	if method == nil {
 		// Constructors are optional, so if there is none, simply return the bytecode as the calldata
+		c.recordConstructorArgs(bytecode, len(bytecode))
 		return bytecode, nil, nil
 	}
 
-	// Parse the params into the standard semantics of Go JSON unmarshalling, with []interface{}
-	ethParams := make([]interface{}, len(req.Params))
-	for i, p := range req.Params {
-		if p != nil {
-			err := json.Unmarshal([]byte(*p), &ethParams[i])
-			if err != nil {
-				return nil, nil, i18n.NewError(ctx, msgs.MsgUnmarshalParamFail, i, err)
-			}
-		}
-	}
-
-	// Match the parameters to the ABI call data for the method.
-	// Note the FireFly ABI decoding package handles formatting errors / translation etc.
 	var callData []byte
-	paramValues, err := method.Inputs.ParseExternalDataCtx(ctx, ethParams)
+	paramValues, err := parseABIParams(ctx, method, req.Params)
 	if err == nil {
 		callData, err = paramValues.EncodeABIData()
 	}
@@ -115,6 +96,23 @@ func (c *ethConnector) prepareDeployData(ctx context.Context, req *ffcapi.Contra
 
 	// Concatenate bytecode and constructor args for deployment transaction
 	callData = append(bytecode, callData...)
+	c.recordConstructorArgs(callData, len(bytecode))
 
 	return callData, method, err
 }
+
+// parseBytecode parses the bytecode of a contract as a hex string, or falls back to Base64
+func parseBytecode(ctx context.Context, contract *fftypes.JSONAny) ([]byte, error) {
+	var bytecodeString string
+	if err := contract.Unmarshal(ctx, &bytecodeString); err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgDecodeBytecodeFailed)
+	}
+	bytecode, err := hex.DecodeString(strings.TrimPrefix(bytecodeString, "0x"))
+	if err != nil {
+		bytecode, err = base64.StdEncoding.DecodeString(bytecodeString)
+		if err != nil {
+			return nil, i18n.NewError(ctx, msgs.MsgDecodeBytecodeFailed)
+		}
+	}
+	return bytecode, nil
+}
@@ -0,0 +1,91 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"golang.org/x/crypto/sha3"
+)
+
+// deploymentMetadata is added to the receipt of a successful deployment, when enabled, with the information
+// a contract verification service (such as Sourcify or Etherscan) needs alongside the source and compiler settings
+type deploymentMetadata struct {
+	RuntimeBytecodeHash ethtypes.HexBytes0xPrefix  `json:"runtimeBytecodeHash"` // keccak256 of the code at the contract address
+	RuntimeBytecodeSize int                        `json:"runtimeBytecodeSize"`
+	CreationInputHash   ethtypes.HexBytes0xPrefix  `json:"creationInputHash"`         // keccak256 of the input of the deployment transaction
+	ConstructorArgs     *ethtypes.HexBytes0xPrefix `json:"constructorArgs,omitempty"` // ABI encoded, if the deployment was prepared by this connector
+}
+
+func keccak256(data []byte) ethtypes.HexBytes0xPrefix {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(data)
+	return hash.Sum(nil)
+}
+
+func (c *ethConnector) initDeployVerification(ctx context.Context, conf config.Section) (err error) {
+	if !conf.GetBool(DeployVerificationEnabled) {
+		return nil
+	}
+	c.constructorArgs, err = lru.New(conf.GetInt(DeployVerificationCacheSize))
+	if err != nil {
+		return i18n.WrapError(ctx, err, msgs.MsgCacheInitFail, "constructor arguments")
+	}
+	return nil
+}
+
+// recordConstructorArgs remembers the split between the bytecode and the constructor arguments of a deployment
+// this connector prepared, as this cannot be determined reliably from the transaction input alone.
+// The arguments are lost on restart, leaving the creation input hash for verification pipelines to match against.
+func (c *ethConnector) recordConstructorArgs(callData []byte, bytecodeLen int) {
+	if c.constructorArgs != nil {
+		c.constructorArgs.Add(keccak256(callData).String(), ethtypes.HexBytes0xPrefix(callData[bytecodeLen:]))
+	}
+}
+
+// getDeploymentMetadata queries the runtime bytecode of a deployed contract as of the block of its receipt,
+// and the input of the deployment transaction
+func (c *ethConnector) getDeploymentMetadata(ctx context.Context, ethReceipt *txReceiptJSONRPC) (*deploymentMetadata, error) {
+	var code ethtypes.HexBytes0xPrefix
+	if rpcErr := c.backend.CallRPC(ctx, &code, "eth_getCode", ethReceipt.ContractAddress, ethReceipt.BlockNumber); rpcErr != nil {
+		return nil, rpcErr.Error()
+	}
+	txInfo, err := c.getTransactionInfo(ctx, ethReceipt.TransactionHash)
+	if err != nil {
+		return nil, err
+	}
+	if txInfo == nil {
+		return nil, i18n.NewError(ctx, msgs.MsgTransactionNotFound, ethReceipt.TransactionHash)
+	}
+	metadata := &deploymentMetadata{
+		RuntimeBytecodeHash: keccak256(code),
+		RuntimeBytecodeSize: len(code),
+		CreationInputHash:   keccak256(txInfo.Input),
+	}
+	if cached, ok := c.constructorArgs.Get(metadata.CreationInputHash.String()); ok {
+		args := cached.(ethtypes.HexBytes0xPrefix)
+		metadata.ConstructorArgs = &args
+	}
+	log.L(ctx).Debugf("Deployment of %s runtimeBytecodeHash=%s constructorArgs=%t", ethReceipt.ContractAddress, metadata.RuntimeBytecodeHash, metadata.ConstructorArgs != nil)
+	return metadata, nil
+}
@@ -0,0 +1,151 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func withDeployVerification(conf config.Section) {
+	conf.Set(DeployVerificationEnabled, true)
+}
+
+func mockDeploymentReceipt(mRPC *rpcbackendmocks.Backend, input string) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		_ = json.Unmarshal([]byte(sampleJSONRPCReceipt), args[1])
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getCode", mock.MatchedBy(func(addr *ethtypes.Address0xHex) bool {
+		return addr.String() == "0x87ae94ab290932c4e6269648bb47c86978af4436"
+	}), mock.MatchedBy(func(blockNumber *ethtypes.HexInteger) bool {
+		return blockNumber.BigInt().Int64() == 1977
+	})).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexBytes0xPrefix) = ethtypes.MustNewHexBytes0xPrefix("0x6080604052")
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**txInfoJSONRPC) = &txInfoJSONRPC{Input: ethtypes.MustNewHexBytes0xPrefix(input)}
+	})
+}
+
+func getDeploymentReceiptMetadata(t *testing.T, ctx context.Context, c *ethConnector) *deploymentMetadata {
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+	res, _, err := c.TransactionReceipt(ctx, &req)
+	assert.NoError(t, err)
+	var extraInfo receiptExtraInfo
+	err = json.Unmarshal(res.ExtraInfo.Bytes(), &extraInfo)
+	assert.NoError(t, err)
+	return extraInfo.Deployment
+}
+
+func TestDeploymentMetadataConstructorArgs(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withDeployVerification)
+	defer done()
+
+	var req ffcapi.ContractDeployPrepareRequest
+	err := json.Unmarshal([]byte(samplePrepareDeployTX), &req)
+	assert.NoError(t, err)
+	prepared, _, err := c.DeployContractPrepare(ctx, &req)
+	assert.NoError(t, err)
+	mockDeploymentReceipt(mRPC, prepared.TransactionData)
+
+	deployment := getDeploymentReceiptMetadata(t, ctx, c)
+	assert.Equal(t, keccak256(ethtypes.MustNewHexBytes0xPrefix("0x6080604052")), deployment.RuntimeBytecodeHash)
+	assert.Equal(t, 5, deployment.RuntimeBytecodeSize)
+	assert.Equal(t, keccak256(ethtypes.MustNewHexBytes0xPrefix(prepared.TransactionData)), deployment.CreationInputHash)
+	assert.Equal(t, "0x00000000000000000000000000000000000000000000000000000000feedbeef", deployment.ConstructorArgs.String())
+
+}
+
+func TestDeploymentMetadataNoConstructor(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withDeployVerification)
+	defer done()
+
+	var req ffcapi.ContractDeployPrepareRequest
+	err := json.Unmarshal([]byte(samplePrepareDeployTX), &req)
+	assert.NoError(t, err)
+	req.Definition = fftypes.JSONAnyPtr(`[]`)
+	req.Params = nil
+	prepared, _, err := c.DeployContractPrepare(ctx, &req)
+	assert.NoError(t, err)
+	mockDeploymentReceipt(mRPC, prepared.TransactionData)
+
+	deployment := getDeploymentReceiptMetadata(t, ctx, c)
+	assert.Equal(t, "0x", deployment.ConstructorArgs.String())
+
+}
+
+func TestDeploymentMetadataNotPrepared(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withDeployVerification)
+	defer done()
+
+	mockDeploymentReceipt(mRPC, "0xfeedbeef")
+
+	deployment := getDeploymentReceiptMetadata(t, ctx, c)
+	assert.Equal(t, keccak256(ethtypes.MustNewHexBytes0xPrefix("0xfeedbeef")), deployment.CreationInputHash)
+	assert.Nil(t, deployment.ConstructorArgs)
+
+}
+
+func TestDeploymentMetadataErrors(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withDeployVerification)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		_ = json.Unmarshal([]byte(sampleJSONRPCReceipt), args[1])
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getCode", mock.Anything, mock.Anything).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getCode", mock.Anything, mock.Anything).Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).Return(nil)
+
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+	_, _, err = c.TransactionReceipt(ctx, &req)
+	assert.Regexp(t, "pop", err)
+	_, _, err = c.TransactionReceipt(ctx, &req)
+	assert.Regexp(t, "pop", err)
+	_, _, err = c.TransactionReceipt(ctx, &req)
+	assert.Regexp(t, "FF23060", err)
+
+}
+
+func TestDeployVerificationBadCacheSize(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(DeployVerificationEnabled, true)
+	conf.Set(DeployVerificationCacheSize, 0)
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23040", err)
+
+}
@@ -0,0 +1,108 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type EncodeCallDataRequest struct {
+	Method     *fftypes.JSONAny   `json:"method,omitempty"`     // the ABI entry of a function, to encode a call to it
+	Definition *fftypes.JSONAny   `json:"definition,omitempty"` // the ABI of a contract, to encode the arguments of its constructor
+	Contract   *fftypes.JSONAny   `json:"contract,omitempty"`   // optional bytecode to prefix to the constructor arguments, as hex or Base64
+	Params     []*fftypes.JSONAny `json:"params"`
+}
+
+type EncodeCallDataResponse struct {
+	CallData ethtypes.HexBytes0xPrefix `json:"callData"`
+	Selector ethtypes.HexBytes0xPrefix `json:"selector,omitempty"` // not set for a constructor
+	Decoded  *DecodedCallData          `json:"decoded,omitempty"`  // not set for a constructor
+}
+
+// EncodeCallData ABI encodes a method call, or the arguments of a constructor, exactly as TransactionPrepare and
+// DeployContractPrepare would, but without estimating gas or submitting anything. This allows systems that build
+// their own transactions, such as multisig wallets, to use encodings that are consistent with the connector.
+func (c *ethConnector) EncodeCallData(ctx context.Context, req *EncodeCallDataRequest) (*EncodeCallDataResponse, ffcapi.ErrorReason, error) {
+	if (req.Method == nil) == (req.Definition == nil) {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgEncodeTargetInvalid)
+	}
+
+	if req.Method != nil {
+		callData, method, err := c.prepareCallData(ctx, &ffcapi.TransactionInput{Method: req.Method, Params: req.Params})
+		if err != nil {
+			return nil, ffcapi.ErrorReasonInvalidInputs, err
+		}
+		decoded, err := c.decodeCallData(ctx, method, callData)
+		if err != nil {
+			return nil, ffcapi.ErrorReasonInvalidInputs, err
+		}
+		log.L(ctx).Debugf("Encoded call data method=%s selector=%s dataLen=%d", decoded.Method, decoded.Selector, len(callData))
+		return &EncodeCallDataResponse{
+			CallData: callData,
+			Selector: method.FunctionSelectorBytes(),
+			Decoded:  decoded,
+		}, "", nil
+	}
+
+	callData, err := c.encodeConstructorData(ctx, req)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	log.L(ctx).Debugf("Encoded constructor data dataLen=%d", len(callData))
+	return &EncodeCallDataResponse{
+		CallData: callData,
+	}, "", nil
+}
+
+func (c *ethConnector) encodeConstructorData(ctx context.Context, req *EncodeCallDataRequest) ([]byte, error) {
+	var bytecode []byte
+	if req.Contract != nil {
+		var err error
+		if bytecode, err = parseBytecode(ctx, req.Contract); err != nil {
+			return nil, err
+		}
+	}
+
+	var a *abi.ABI
+	if err := json.Unmarshal(req.Definition.Bytes(), &a); err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgUnmarshalABIMethodFail, err)
+	}
+
+	// A contract without a constructor takes no arguments
+	constructor := a.Constructor()
+	if constructor == nil {
+		constructor = &abi.Entry{Type: abi.Constructor}
+	}
+	paramValues, err := parseABIParams(ctx, constructor, req.Params)
+	if err != nil {
+		return nil, err
+	}
+	args, err := paramValues.EncodeABIDataCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(bytecode, args...), nil
+}
@@ -0,0 +1,124 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleConstructorABI = `[{
+	"inputs": [{"name": "x", "type": "uint256"}],
+	"outputs": [],
+	"type": "constructor"
+}]`
+
+func TestEncodeCallDataMethod(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	res, reason, err := c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Method: fftypes.JSONAnyPtr(`{"name": "set", "type": "function", "inputs": [{"name": "x", "type": "uint256"}]}`),
+		Params: []*fftypes.JSONAny{fftypes.JSONAnyPtr(`4276993775`)},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, "0x60fe47b100000000000000000000000000000000000000000000000000000000feedbeef", res.CallData.String())
+	assert.Equal(t, "0x60fe47b1", res.Selector.String())
+	assert.Equal(t, "set(uint256)", res.Decoded.Method)
+	assert.JSONEq(t, `{"x": "4276993775"}`, res.Decoded.Params.String())
+
+}
+
+func TestEncodeCallDataConstructor(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	// Just the arguments, for a factory or multisig that supplies the bytecode itself
+	res, _, err := c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Definition: fftypes.JSONAnyPtr(sampleConstructorABI),
+		Params:     []*fftypes.JSONAny{fftypes.JSONAnyPtr(`4276993775`)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x00000000000000000000000000000000000000000000000000000000feedbeef", res.CallData.String())
+	assert.Nil(t, res.Selector)
+	assert.Nil(t, res.Decoded)
+
+	// Prefixed with the bytecode
+	res, _, err = c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Definition: fftypes.JSONAnyPtr(sampleConstructorABI),
+		Contract:   fftypes.JSONAnyPtr(`"0x1234"`),
+		Params:     []*fftypes.JSONAny{fftypes.JSONAnyPtr(`4276993775`)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x123400000000000000000000000000000000000000000000000000000000feedbeef", res.CallData.String())
+
+	// No constructor in the ABI
+	res, _, err = c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Definition: fftypes.JSONAnyPtr(`[]`),
+		Contract:   fftypes.JSONAnyPtr(`"0x1234"`),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x1234", res.CallData.String())
+
+}
+
+func TestEncodeCallDataErrors(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.EncodeCallData(ctx, &EncodeCallDataRequest{})
+	assert.Regexp(t, "FF23159", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, _, err = c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Method:     fftypes.JSONAnyPtr(`{}`),
+		Definition: fftypes.JSONAnyPtr(`[]`),
+	})
+	assert.Regexp(t, "FF23159", err)
+
+	_, reason, err = c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Method: fftypes.JSONAnyPtr(`{"name": "set", "type": "function", "inputs": [{"name": "x", "type": "uint256"}]}`),
+		Params: []*fftypes.JSONAny{fftypes.JSONAnyPtr(`"not a number"`)},
+	})
+	assert.Regexp(t, "FF23156.*'x' in set", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, _, err = c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Definition: fftypes.JSONAnyPtr(sampleConstructorABI),
+		Contract:   fftypes.JSONAnyPtr(`"!!!"`),
+	})
+	assert.Regexp(t, "FF23047", err)
+
+	_, _, err = c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Definition: fftypes.JSONAnyPtr(`{}`),
+	})
+	assert.Regexp(t, "FF23013", err)
+
+	_, _, err = c.EncodeCallData(ctx, &EncodeCallDataRequest{
+		Definition: fftypes.JSONAnyPtr(`[]`),
+		Params:     []*fftypes.JSONAny{fftypes.JSONAnyPtr(`1`)},
+	})
+	assert.Regexp(t, "FF22037", err)
+
+}
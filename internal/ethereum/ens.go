@@ -0,0 +1,127 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	ensResolverSelector = ethtypes.MustNewHexBytes0xPrefix("0x0178b8bf") // resolver(bytes32) on the registry
+	ensAddrSelector     = ethtypes.MustNewHexBytes0xPrefix("0x3b3b57de") // addr(bytes32) on the resolver
+)
+
+type ensCacheEntry struct {
+	address  *ethtypes.Address0xHex
+	resolved time.Time
+}
+
+func (c *ethConnector) initENS(ctx context.Context, conf config.Section) (err error) {
+	if !conf.GetBool(ENSEnabled) {
+		return nil
+	}
+	registry := conf.GetString(ENSRegistry)
+	if c.ensRegistry, err = ethtypes.NewAddress(registry); err != nil {
+		return i18n.NewError(ctx, msgs.MsgInvalidENSRegistry, registry, err)
+	}
+	if c.ensCache, err = lru.New(conf.GetInt(ENSCacheSize)); err != nil {
+		return i18n.WrapError(ctx, err, msgs.MsgCacheInitFail, "ENS")
+	}
+	c.ensCacheTTL = conf.GetDuration(ENSCacheTTL)
+	log.L(ctx).Infof("ENS names will be resolved using registry %s", c.ensRegistry)
+	return nil
+}
+
+// isENSName returns whether a to-address should be resolved through ENS, rather than parsed as an address
+func (c *ethConnector) isENSName(s string) bool {
+	return c.ensRegistry != nil && strings.Contains(s, ".") && !strings.HasPrefix(s, "0x")
+}
+
+// ensNamehash implements the namehash algorithm of EIP-137. Names are lower-cased, but not otherwise
+// normalized, so must be supplied in their normalized form.
+func ensNamehash(name string) []byte {
+	node := make([]byte, 32)
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		labelHash := sha3.NewLegacyKeccak256()
+		labelHash.Write([]byte(labels[i]))
+		nodeHash := sha3.NewLegacyKeccak256()
+		nodeHash.Write(node)
+		nodeHash.Write(labelHash.Sum(nil))
+		node = nodeHash.Sum(nil)
+	}
+	return node
+}
+
+// resolveENSName looks up the resolver of the name in the ENS registry, then the address from that resolver.
+// Results are cached for the configured time, as the contracts behind names change rarely.
+func (c *ethConnector) resolveENSName(ctx context.Context, name string) (*ethtypes.Address0xHex, error) {
+	if cached, ok := c.ensCache.Get(name); ok {
+		entry := cached.(*ensCacheEntry)
+		if time.Since(entry.resolved) < c.ensCacheTTL {
+			return entry.address, nil
+		}
+	}
+
+	node := ensNamehash(name)
+	resolver, err := c.ensCallForAddress(ctx, name, c.ensRegistry, ensResolverSelector, node)
+	if err != nil {
+		return nil, err
+	}
+	address, err := c.ensCallForAddress(ctx, name, resolver, ensAddrSelector, node)
+	if err != nil {
+		return nil, err
+	}
+
+	log.L(ctx).Infof("Resolved ENS name '%s' to %s", name, address)
+	c.ensCache.Add(name, &ensCacheEntry{address: address, resolved: time.Now()})
+	return address, nil
+}
+
+func (c *ethConnector) ensCallForAddress(ctx context.Context, name string, to *ethtypes.Address0xHex, selector ethtypes.HexBytes0xPrefix, node []byte) (*ethtypes.Address0xHex, error) {
+	tx := &ethsigner.Transaction{
+		To:   to,
+		Data: append(append([]byte{}, selector...), node...),
+	}
+	var result ethtypes.HexBytes0xPrefix
+	if rpcErr := c.backend.CallRPC(ctx, &result, "eth_call", tx, "latest"); rpcErr != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgENSResolveFailed, name, rpcErr.Message)
+	}
+	if len(result) < 32 {
+		return nil, i18n.NewError(ctx, msgs.MsgENSNameNotFound, name)
+	}
+	var address ethtypes.Address0xHex
+	copy(address[:], result[12:32])
+	if address == (ethtypes.Address0xHex{}) {
+		return nil, i18n.NewError(ctx, msgs.MsgENSNameNotFound, name)
+	}
+	return &address, nil
+}
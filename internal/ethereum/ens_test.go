@@ -0,0 +1,144 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testENSResolver = "0x231b0ee14048e9dccd1d247744d114a4eb5e8e63"
+const testENSTarget = "0xe1a078b9e2b145d0a7387f09277c6ae1d9470771"
+
+func withENS(conf config.Section) {
+	conf.Set(ENSEnabled, true)
+}
+
+func mockENSCall(mRPC *rpcbackendmocks.Backend, to, dataPrefix, result string) *mock.Call {
+	return mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call",
+		mock.MatchedBy(func(tx *ethsigner.Transaction) bool {
+			return tx.To.String() == to && tx.Data.String()[0:10] == dataPrefix
+		}), "latest").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(result)
+		})
+}
+
+func TestENSNamehash(t *testing.T) {
+	assert.Equal(t, "0000000000000000000000000000000000000000000000000000000000000000", ethtypes.HexBytesPlain(ensNamehash("")).String())
+	assert.Equal(t, "93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae", ethtypes.HexBytesPlain(ensNamehash("eth")).String())
+	assert.Equal(t, "de9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f", ethtypes.HexBytesPlain(ensNamehash("Foo.eth")).String())
+}
+
+func TestExecQueryENSName(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withENS)
+	defer done()
+
+	mockENSCall(mRPC, "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e", "0x0178b8bf",
+		"0x000000000000000000000000"+testENSResolver[2:]).Return(nil).Once()
+	mockENSCall(mRPC, testENSResolver, "0x3b3b57de",
+		"0x000000000000000000000000"+testENSTarget[2:]).Return(nil).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call",
+		mock.MatchedBy(func(tx *ethsigner.Transaction) bool {
+			return tx.To.String() == testENSTarget
+		}), "latest").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x00000000000000000000000000000000000000000000000000000000baadf00d0000000000000000000000000000000000000000000000000000000000000040000000000000000000000000000000000000000000000000000000000000000b68656c6c6f20776f726c64000000000000000000000000000000000000000000")
+		}).
+		Return(nil).Twice()
+
+	var req ffcapi.QueryInvokeRequest
+	err := json.Unmarshal([]byte(sampleExecQuery), &req)
+	assert.NoError(t, err)
+	req.To = "mycontract.eth"
+
+	// The second query uses the cached resolution
+	for i := 0; i < 2; i++ {
+		res, reason, err := c.QueryInvoke(ctx, &req)
+		assert.NoError(t, err)
+		assert.Empty(t, reason)
+		assert.JSONEq(t, `{"output": "3131961357", "output1":"hello world"}`, res.Outputs.String())
+	}
+
+	// Until it expires
+	cached, ok := c.ensCache.Get("mycontract.eth")
+	assert.True(t, ok)
+	cached.(*ensCacheEntry).resolved = time.Now().Add(-c.ensCacheTTL)
+	mockENSCall(mRPC, "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e", "0x0178b8bf", "0x").Return(nil).Once()
+	_, reason, err := c.QueryInvoke(ctx, &req)
+	assert.Regexp(t, "FF23108", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestENSNameNotResolved(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withENS)
+	defer done()
+
+	mockENSCall(mRPC, "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e", "0x0178b8bf",
+		"0x000000000000000000000000"+testENSResolver[2:]).Return(nil)
+	mockENSCall(mRPC, testENSResolver, "0x3b3b57de",
+		"0x0000000000000000000000000000000000000000000000000000000000000000").Return(nil).Once()
+	mockENSCall(mRPC, testENSResolver, "0x3b3b57de", "0x").Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+
+	_, err := c.resolveENSName(ctx, "unknown.eth")
+	assert.Regexp(t, "FF23108", err)
+	_, err = c.resolveENSName(ctx, "unknown.eth")
+	assert.Regexp(t, "FF23109.*pop", err)
+
+}
+
+func TestENSDisabled(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, err := c.buildTx(ctx, txTypeQuery, "", "mycontract.eth", nil, nil, nil, nil)
+	assert.Regexp(t, "FF23020", err)
+
+}
+
+func TestENSBadConfig(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(ENSEnabled, true)
+	conf.Set(ENSRegistry, "wrong")
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23110", err)
+
+	conf = newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(ENSEnabled, true)
+	conf.Set(ENSCacheSize, -1)
+	_, err = NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23040", err)
+
+}
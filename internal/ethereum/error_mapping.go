@@ -17,8 +17,15 @@
 package ethereum
 
 import (
+	"context"
+	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
@@ -32,51 +39,167 @@ const (
 	netVersionRPCMethods
 )
 
-// mapErrorToReason provides a common place for mapping Ethereum client
-// error strings, to a more consistent set of cross-client (and
-// cross blockchain) reasons for errors defined by FFCPI for use by
-// FireFly Transaction Manager.
-//
-// Sadly there is no place in Ethereum JSON/RPC where these are
-// formally defined. So this logic may get complex over time to
-// deal with the differences between client implementations.
-func mapError(methodType ethRPCMethodCategory, err error) ffcapi.ErrorReason {
+// The names used for each category of methods in the errorMappings configuration
+var ethRPCMethodCategoryNames = map[string]ethRPCMethodCategory{
+	"filter":     filterRPCMethods,
+	"send":       sendRPCMethods,
+	"call":       callRPCMethods,
+	"block":      blockRPCMethods,
+	"netVersion": netVersionRPCMethods,
+}
 
-	errString := strings.ToLower(err.Error())
+var ffcapiErrorReasons = []ffcapi.ErrorReason{
+	ffcapi.ErrorReasonInvalidInputs,
+	ffcapi.ErrorReasonTransactionReverted,
+	ffcapi.ErrorReasonNonceTooLow,
+	ffcapi.ErrorReasonTransactionUnderpriced,
+	ffcapi.ErrorReasonInsufficientFunds,
+	ffcapi.ErrorReasonNotFound,
+	ffcapi.ErrorKnownTransaction,
+	ffcapi.ErrorReasonDownstreamDown,
+}
+
+// errorMapping maps an error returned by a JSON/RPC endpoint to an FFCAPI reason. Each of the
+// set conditions must match - the JSON/RPC error code, and a substring or pattern in the message.
+type errorMapping struct {
+	methods  []ethRPCMethodCategory // all methods if empty
+	code     int64
+	contains string // lower case
+	regex    *regexp.Regexp
+	reason   ffcapi.ErrorReason
+}
+
+// defaultErrorMappings cover the error strings and codes of the common Ethereum clients and node
+// providers. Sadly there is no place in Ethereum JSON/RPC where these are formally defined.
+var defaultErrorMappings = []*errorMapping{
+	{methods: []ethRPCMethodCategory{filterRPCMethods}, contains: "filter not found", reason: ffcapi.ErrorReasonNotFound},
+	{methods: []ethRPCMethodCategory{sendRPCMethods}, contains: "nonce too low", reason: ffcapi.ErrorReasonNonceTooLow},
+	{methods: []ethRPCMethodCategory{sendRPCMethods}, contains: "insufficient funds", reason: ffcapi.ErrorReasonInsufficientFunds},
+	// includes "replacement transaction underpriced" from geth
+	{methods: []ethRPCMethodCategory{sendRPCMethods}, contains: "transaction underpriced", reason: ffcapi.ErrorReasonTransactionUnderpriced},
+	{methods: []ethRPCMethodCategory{sendRPCMethods}, contains: "known transaction", reason: ffcapi.ErrorKnownTransaction},
+	{methods: []ethRPCMethodCategory{sendRPCMethods}, contains: "already known", reason: ffcapi.ErrorKnownTransaction},
+	// ALREADY_EXISTS is the gRPC status returned by some node providers and enterprise clients
+	{methods: []ethRPCMethodCategory{sendRPCMethods}, contains: "already_exists", reason: ffcapi.ErrorKnownTransaction},
+	{methods: []ethRPCMethodCategory{callRPCMethods}, contains: "execution reverted", reason: ffcapi.ErrorReasonTransactionReverted},
+	// https://docs.avax.network/quickstart/integrate-exchange-with-avalanche#determining-finality
+	{methods: []ethRPCMethodCategory{blockRPCMethods}, contains: "cannot query unfinalized data", reason: ffcapi.ErrorReasonNotFound},
+	{methods: []ethRPCMethodCategory{netVersionRPCMethods}, contains: "the method net_version does not exist/is not available", reason: ffcapi.ErrorReasonNotFound},
+	// Infura returns the EIP-1474 "limit exceeded" code when a request rate limit is exceeded, and Alchemy
+	// returns 429 when compute unit capacity is exceeded. The endpoint is unavailable to us until we back off.
+	{code: -32005, reason: ffcapi.ErrorReasonDownstreamDown},
+	{code: 429, reason: ffcapi.ErrorReasonDownstreamDown},
+	{contains: "exceeded its compute units per second capacity", reason: ffcapi.ErrorReasonDownstreamDown},
+	// Requests failed fast by our own circuit breaker, while the node is not responding
+	{code: rpcCodeCircuitOpen, reason: ffcapi.ErrorReasonDownstreamDown},
+}
 
-	switch methodType {
-	case filterRPCMethods:
-		if strings.Contains(errString, "filter not found") {
-			return ffcapi.ErrorReasonNotFound
+func initErrorMappingsConfig(mappings config.ArraySection) config.ArraySection {
+	mappings.AddKnownKey(ErrorMappingMethods)
+	mappings.AddKnownKey(ErrorMappingCode)
+	mappings.AddKnownKey(ErrorMappingMessage)
+	mappings.AddKnownKey(ErrorMappingReason)
+	return mappings
+}
+
+// newErrorMappings builds the additional error mappings from configuration, which are checked before the defaults
+func newErrorMappings(ctx context.Context, conf config.Section) ([]*errorMapping, error) {
+	mappingsConf := initErrorMappingsConfig(conf.SubArray(ErrorMappings))
+	mappings := make([]*errorMapping, mappingsConf.ArraySize())
+	for i := range mappings {
+		mappingConf := mappingsConf.ArrayEntry(i)
+		m := &errorMapping{
+			code:   mappingConf.GetInt64(ErrorMappingCode),
+			reason: ffcapi.ErrorReason(mappingConf.GetString(ErrorMappingReason)),
 		}
-	case sendRPCMethods:
-		switch {
-		case strings.Contains(errString, "nonce too low"):
-			return ffcapi.ErrorReasonNonceTooLow
-		case strings.Contains(errString, "insufficient funds"):
-			return ffcapi.ErrorReasonInsufficientFunds
-		case strings.Contains(errString, "transaction underpriced"):
-			return ffcapi.ErrorReasonTransactionUnderpriced
-		case strings.Contains(errString, "known transaction"):
-			return ffcapi.ErrorKnownTransaction
-		case strings.Contains(errString, "already known"):
-			return ffcapi.ErrorKnownTransaction
+		if !isKnownErrorReason(m.reason) {
+			return nil, i18n.NewError(ctx, msgs.MsgBadErrorMappingReason, m.reason, i, ffcapiErrorReasonNames())
 		}
-	case callRPCMethods:
-		if strings.Contains(errString, "execution reverted") {
-			return ffcapi.ErrorReasonTransactionReverted
+		for _, name := range mappingConf.GetStringSlice(ErrorMappingMethods) {
+			category, ok := ethRPCMethodCategoryNames[name]
+			if !ok {
+				return nil, i18n.NewError(ctx, msgs.MsgBadErrorMappingMethods, name, i, ethRPCMethodCategoryNamesList())
+			}
+			m.methods = append(m.methods, category)
 		}
-	case blockRPCMethods:
-		// https://docs.avax.network/quickstart/integrate-exchange-with-avalanche#determining-finality
-		if strings.Contains(errString, "cannot query unfinalized data") {
-			return ffcapi.ErrorReasonNotFound
+		if message := mappingConf.GetString(ErrorMappingMessage); message != "" {
+			var err error
+			if m.regex, err = regexp.Compile(message); err != nil {
+				return nil, i18n.WrapError(ctx, err, msgs.MsgBadErrorMappingMessage, message, i)
+			}
 		}
-	case netVersionRPCMethods:
-		if strings.Contains(errString, "the method net_version does not exist/is not available") {
-			return ffcapi.ErrorReasonNotFound
+		if m.code == 0 && m.regex == nil {
+			return nil, i18n.NewError(ctx, msgs.MsgErrorMappingNoMatch, i)
 		}
+		mappings[i] = m
 	}
+	return mappings, nil
+}
 
-	// Best default in FFCAPI is to provide no mapping
+func isKnownErrorReason(reason ffcapi.ErrorReason) bool {
+	for _, r := range ffcapiErrorReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func ffcapiErrorReasonNames() string {
+	names := make([]string, len(ffcapiErrorReasons))
+	for i, r := range ffcapiErrorReasons {
+		names[i] = string(r)
+	}
+	return strings.Join(names, ",")
+}
+
+func ethRPCMethodCategoryNamesList() string {
+	names := make([]string, 0, len(ethRPCMethodCategoryNames))
+	for name := range ethRPCMethodCategoryNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (m *errorMapping) matches(methodType ethRPCMethodCategory, code int64, errString string) bool {
+	if len(m.methods) > 0 {
+		found := false
+		for _, mt := range m.methods {
+			found = found || mt == methodType
+		}
+		if !found {
+			return false
+		}
+	}
+	return (m.code == 0 || m.code == code) &&
+		(m.contains == "" || strings.Contains(strings.ToLower(errString), m.contains)) &&
+		(m.regex == nil || m.regex.MatchString(errString))
+}
+
+func matchErrorMappings(mappings []*errorMapping, methodType ethRPCMethodCategory, code int64, errString string) ffcapi.ErrorReason {
+	for _, m := range mappings {
+		if m.matches(methodType, code, errString) {
+			return m.reason
+		}
+	}
 	return ""
 }
+
+// mapError provides a common place for mapping Ethereum client
+// error strings, to a more consistent set of cross-client (and
+// cross blockchain) reasons for errors defined by FFCPI for use by
+// FireFly Transaction Manager.
+func mapError(methodType ethRPCMethodCategory, err error) ffcapi.ErrorReason {
+	// Best default in FFCAPI is to provide no mapping
+	return matchErrorMappings(defaultErrorMappings, methodType, 0, err.Error())
+}
+
+// mapRPCError maps a JSON/RPC error using its code as well as its message, checking any
+// configured mappings before the defaults
+func (c *ethConnector) mapRPCError(methodType ethRPCMethodCategory, rpcErr *rpcbackend.RPCError) ffcapi.ErrorReason {
+	if reason := matchErrorMappings(c.errorMappings, methodType, rpcErr.Code, rpcErr.Message); reason != "" {
+		return reason
+	}
+	return matchErrorMappings(defaultErrorMappings, methodType, rpcErr.Code, rpcErr.Message)
+}
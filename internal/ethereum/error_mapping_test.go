@@ -0,0 +1,110 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setTestErrorMapping(conf config.Section, i int, reason string) config.Section {
+	mappingConf := initErrorMappingsConfig(conf.SubArray(ErrorMappings)).ArrayEntry(i)
+	mappingConf.Set(ErrorMappingReason, reason)
+	return mappingConf
+}
+
+func TestProviderErrorMapping(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t)
+	defer done()
+
+	// Infura
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, c.mapRPCError(sendRPCMethods, &rpcbackend.RPCError{Code: -32005, Message: "daily request count exceeded, request rate limited"}))
+	// Alchemy
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, c.mapRPCError(callRPCMethods, &rpcbackend.RPCError{Code: 429, Message: "Your app has exceeded its compute units per second capacity"}))
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, c.mapRPCError(blockRPCMethods, &rpcbackend.RPCError{Code: -32000, Message: "Your app has exceeded its compute units per second capacity"}))
+	// geth
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, c.mapRPCError(sendRPCMethods, &rpcbackend.RPCError{Code: -32000, Message: "replacement transaction underpriced"}))
+	assert.Equal(t, ffcapi.ErrorKnownTransaction, c.mapRPCError(sendRPCMethods, &rpcbackend.RPCError{Code: -32000, Message: "already known"}))
+	assert.Equal(t, ffcapi.ErrorKnownTransaction, c.mapRPCError(sendRPCMethods, &rpcbackend.RPCError{Message: "rpc error: code = ALREADY_EXISTS desc = transaction already exists"}))
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, c.mapRPCError(sendRPCMethods, &rpcbackend.RPCError{Code: -32000, Message: "Nonce too low"}))
+	// Only mapped for the applicable methods
+	assert.Empty(t, c.mapRPCError(callRPCMethods, &rpcbackend.RPCError{Code: -32000, Message: "nonce too low"}))
+	assert.Empty(t, c.mapRPCError(sendRPCMethods, &rpcbackend.RPCError{Code: -32000, Message: "pop"}))
+
+}
+
+func TestConfiguredErrorMapping(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		m0 := setTestErrorMapping(conf, 0, string(ffcapi.ErrorReasonNonceTooLow))
+		m0.Set(ErrorMappingMethods, []string{"send"})
+		m0.Set(ErrorMappingMessage, "^nonce [0-9]+ has already been used$")
+		m1 := setTestErrorMapping(conf, 1, string(ffcapi.ErrorReasonDownstreamDown))
+		m1.Set(ErrorMappingCode, -32099)
+		m2 := setTestErrorMapping(conf, 2, string(ffcapi.ErrorReasonInsufficientFunds))
+		m2.Set(ErrorMappingMessage, "nonce too low")
+		m2.Set(ErrorMappingMethods, []string{"call"})
+	})
+	defer done()
+	assert.Len(t, c.errorMappings, 3)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "nonce 12 has already been used"})
+
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &req)
+	assert.NoError(t, err)
+	_, reason, err := c.TransactionSend(ctx, &req)
+	assert.Regexp(t, "nonce 12", err)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, c.mapRPCError(blockRPCMethods, &rpcbackend.RPCError{Code: -32099, Message: "busy"}))
+	assert.Empty(t, c.mapRPCError(blockRPCMethods, &rpcbackend.RPCError{Code: -32098, Message: "busy"}))
+	// Configured mappings take precedence, but only for the configured methods
+	assert.Equal(t, ffcapi.ErrorReasonInsufficientFunds, c.mapRPCError(callRPCMethods, &rpcbackend.RPCError{Message: "nonce too low"}))
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, c.mapRPCError(sendRPCMethods, &rpcbackend.RPCError{Message: "nonce too low"}))
+
+}
+
+func TestConfiguredErrorMappingBadConfig(t *testing.T) {
+
+	for _, tc := range []struct {
+		reason string
+		key    string
+		value  interface{}
+		err    string
+	}{
+		{reason: "wrong", key: ErrorMappingCode, value: 1, err: "FF23093"},
+		{reason: "not_found", key: ErrorMappingMethods, value: []string{"wrong"}, err: "FF23094"},
+		{reason: "not_found", key: ErrorMappingMessage, value: "[", err: "FF23095"},
+		{reason: "not_found", key: ErrorMappingMethods, value: []string{"send"}, err: "FF23096"},
+	} {
+		conf := newTestAuthConf(t, "http://localhost:8545")
+		setTestErrorMapping(conf, 0, tc.reason).Set(tc.key, tc.value)
+		_, err := NewEthereumConnector(context.Background(), conf)
+		assert.Regexp(t, tc.err, err)
+	}
+
+}
@@ -29,10 +29,58 @@ import (
 	"github.com/hyperledger/firefly-signer/pkg/abi"
 	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
 	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
+const (
+	gasEstimateLimitFloor   = "floor"
+	gasEstimateLimitCeiling = "ceiling"
+)
+
+// The methods by which the base estimate can be derived, when gasEstimationFallback is enabled
+const (
+	GasEstimateMethodEstimateGas     = "estimateGas"
+	GasEstimateMethodBalanceOverride = "balanceOverride"
+	GasEstimateMethodCallSearch      = "callSearch"
+	GasEstimateMethodCeiling         = "ceiling"
+)
+
+const (
+	// The balance given to the sender with a state override, so estimation is not limited by the funds of the sender
+	gasEstimateOverrideBalance = "0xffffffffffffffffffffffffffffffff"
+	// The lowest gas limit of any transaction, and the starting lower bound of the eth_call search
+	gasEstimateIntrinsicGas = 21000
+	// The search stops once the gap between the highest failing and lowest working gas limits is within 1/64th
+	gasEstimateSearchTolerance = 64
+)
+
+// GasEstimateBreakdown describes how a gas estimate was derived from the result of eth_estimateGas
+type GasEstimateBreakdown struct {
+	BaseEstimate *fftypes.FFBigInt `json:"baseEstimate,omitempty"` // the result of eth_estimateGas, or the limit derived by the fallback - not set if the ceiling was used
+	Factor       float64           `json:"factor"`                 // the gasEstimationFactor applied to the base estimate
+	Floor        *fftypes.FFBigInt `json:"floor,omitempty"`
+	Ceiling      *fftypes.FFBigInt `json:"ceiling,omitempty"`
+	Limited      string            `json:"limited,omitempty"` // "floor" or "ceiling" if the estimate was raised or lowered to that limit
+	Fallback     bool              `json:"fallback"`          // true if eth_estimateGas failed, and the ceiling was used as the estimate
+	Method       string            `json:"method"`            // how the base estimate was derived - see GasEstimateMethod*
+}
+
+type GasEstimateDetailedResponse struct {
+	ffcapi.GasEstimateResponse
+	Breakdown *GasEstimateBreakdown `json:"breakdown"`
+}
+
 func (c *ethConnector) GasEstimate(ctx context.Context, transaction *ffcapi.TransactionInput) (*ffcapi.GasEstimateResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := c.GasEstimateDetailed(ctx, transaction)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res.GasEstimateResponse, "", nil
+}
+
+// GasEstimateDetailed is GasEstimate, with a breakdown of how the estimate was derived included in the response
+func (c *ethConnector) GasEstimateDetailed(ctx context.Context, transaction *ffcapi.TransactionInput) (*GasEstimateDetailedResponse, ffcapi.ErrorReason, error) {
 
 	tx := &ethsigner.Transaction{
 		Nonce:    (*ethtypes.HexInteger)(transaction.Nonce),
@@ -58,37 +106,152 @@ func (c *ethConnector) GasEstimate(ctx context.Context, transaction *ffcapi.Tran
 	}
 
 	// Do the gas estimation
-	gasEstimate, reason, err := c.gasEstimate(ctx, tx, nil, nil)
+	gasEstimate, breakdown, reason, err := c.gasEstimateBreakdown(ctx, tx, nil, nil)
 	if err != nil {
 		return nil, reason, err
 	}
-	return &ffcapi.GasEstimateResponse{GasEstimate: (*fftypes.FFBigInt)(gasEstimate)}, "", nil
+	return &GasEstimateDetailedResponse{
+		GasEstimateResponse: ffcapi.GasEstimateResponse{GasEstimate: (*fftypes.FFBigInt)(gasEstimate)},
+		Breakdown:           breakdown,
+	}, "", nil
 }
 
 func (c *ethConnector) gasEstimate(ctx context.Context, tx *ethsigner.Transaction, method *abi.Entry, errors []*abi.Entry) (*ethtypes.HexInteger, ffcapi.ErrorReason, error) {
+	gasEstimate, _, reason, err := c.gasEstimateBreakdown(ctx, tx, method, errors)
+	return gasEstimate, reason, err
+}
+
+func (c *ethConnector) gasEstimateBreakdown(ctx context.Context, tx *ethsigner.Transaction, method *abi.Entry, errors []*abi.Entry) (*ethtypes.HexInteger, *GasEstimateBreakdown, ffcapi.ErrorReason, error) {
+
+	// Take a single snapshot of the settings, so the breakdown is consistent
+	t := c.tuned()
+	factor, _ := t.gasEstimationFactor.Float64()
+	breakdown := &GasEstimateBreakdown{
+		Factor:  factor,
+		Floor:   (*fftypes.FFBigInt)(t.gasEstimationFloor),
+		Ceiling: (*fftypes.FFBigInt)(t.gasEstimationCeiling),
+	}
 
 	// Do the gas estimation
 	var gasEstimate ethtypes.HexInteger
-	rpcErr := c.backend.CallRPC(ctx, &gasEstimate, "eth_estimateGas", tx)
+	var rpcErr *rpcbackend.RPCError
+	if c.pendingState {
+		// Only passed when configured, as not all nodes accept a block parameter for eth_estimateGas
+		rpcErr = c.backend.CallRPC(ctx, &gasEstimate, "eth_estimateGas", tx, "pending")
+	} else {
+		rpcErr = c.backend.CallRPC(ctx, &gasEstimate, "eth_estimateGas", tx)
+	}
 	if rpcErr != nil {
 		if reason, revertErr := c.attemptProcessingRevertData(ctx, errors, rpcErr); revertErr != nil {
-			return nil, reason, revertErr
+			return nil, nil, reason, revertErr
 		}
 
 		// If it fails, fall back to an eth_call to see if we get a reverted reason
 		_, reason, errCall := c.callTransaction(ctx, tx, method, errors, nil)
 		if reason == ffcapi.ErrorReasonTransactionReverted {
-			return nil, reason, errCall
+			return nil, nil, reason, errCall
+		}
+		if errCall == nil && t.gasEstimationFallback {
+			if base, method := c.gasEstimateFallbackLadder(ctx, t, tx); base != nil {
+				log.L(ctx).Warnf("Gas estimation failed for a non-revert reason: %s (derived %s using %s)", rpcErr.Message, base, method)
+				breakdown.Method = method
+				return applyGasEstimateLimits(t, base, breakdown)
+			}
+		}
+		if errCall == nil && t.gasEstimationCeiling != nil {
+			// The transaction executes, so the configured ceiling is a usable gas limit
+			log.L(ctx).Warnf("Gas estimation failed for a non-revert reason: %s (using ceiling %s)", rpcErr.Message, t.gasEstimationCeiling)
+			breakdown.Fallback = true
+			breakdown.Method = GasEstimateMethodCeiling
+			return (*ethtypes.HexInteger)(new(big.Int).Set(t.gasEstimationCeiling)), breakdown, "", nil
 		}
 		log.L(ctx).Errorf("Gas estimation failed for a non-revert reason: %s (call result: %v)", rpcErr.Message, errCall)
 		// Return the original error - as the eth_call did not give us a revert result (it might even
 		// have succeeded). So we need to fall back to the original error.
-		return nil, mapError(callRPCMethods, rpcErr.Error()), rpcErr.Error()
+		return nil, nil, c.mapRPCError(callRPCMethods, rpcErr), rpcErr.Error()
 	}
+	breakdown.Method = GasEstimateMethodEstimateGas
+	return applyGasEstimateLimits(t, gasEstimate.BigInt(), breakdown)
+}
+
+func applyGasEstimateLimits(t *tunables, base *big.Int, breakdown *GasEstimateBreakdown) (*ethtypes.HexInteger, *GasEstimateBreakdown, ffcapi.ErrorReason, error) {
+	breakdown.BaseEstimate = (*fftypes.FFBigInt)(new(big.Int).Set(base))
+	var gasEstimate ethtypes.HexInteger
+	gasEstimate.BigInt().Set(base)
 
-	// Multiply the gas estimate by the configured factor
+	// Multiply the gas estimate by the configured factor, then apply the configured limits
 	fGasEstimate := new(big.Float).SetInt(gasEstimate.BigInt())
-	_ = fGasEstimate.Mul(fGasEstimate, c.gasEstimationFactor)
+	_ = fGasEstimate.Mul(fGasEstimate, t.gasEstimationFactor)
 	_, _ = fGasEstimate.Int(gasEstimate.BigInt())
-	return &gasEstimate, "", nil
+	switch {
+	case t.gasEstimationFloor != nil && gasEstimate.BigInt().Cmp(t.gasEstimationFloor) < 0:
+		gasEstimate.BigInt().Set(t.gasEstimationFloor)
+		breakdown.Limited = gasEstimateLimitFloor
+	case t.gasEstimationCeiling != nil && gasEstimate.BigInt().Cmp(t.gasEstimationCeiling) > 0:
+		gasEstimate.BigInt().Set(t.gasEstimationCeiling)
+		breakdown.Limited = gasEstimateLimitCeiling
+	}
+	return &gasEstimate, breakdown, "", nil
+}
+
+// gasEstimateFallbackLadder is used when eth_estimateGas fails for a non-revert reason, but an eth_call of the
+// transaction succeeds. Some nodes reject estimation for provider specific reasons, such as "gas required exceeds
+// allowance" when the sender has an insufficient balance. So first eth_estimateGas is retried with a state override
+// that funds the sender, then the lowest gas limit at which eth_call succeeds is found by binary search.
+func (c *ethConnector) gasEstimateFallbackLadder(ctx context.Context, t *tunables, tx *ethsigner.Transaction) (*big.Int, string) {
+	blockTag := "latest"
+	if c.pendingState {
+		blockTag = "pending"
+	}
+
+	var from string
+	if err := json.Unmarshal(tx.From, &from); err == nil && from != "" {
+		var gasEstimate ethtypes.HexInteger
+		overrides := map[string]interface{}{
+			from: map[string]string{"balance": gasEstimateOverrideBalance},
+		}
+		rpcErr := c.backend.CallRPC(ctx, &gasEstimate, "eth_estimateGas", tx, blockTag, overrides)
+		if rpcErr == nil {
+			return gasEstimate.BigInt(), GasEstimateMethodBalanceOverride
+		}
+		log.L(ctx).Warnf("Gas estimation with balance override failed: %s", rpcErr.Message)
+	}
+
+	// The upper bound of the search is the ceiling if configured, otherwise the gas limit of the latest block
+	hi := t.gasEstimationCeiling
+	if hi == nil {
+		var bi *blockInfoJSONRPC
+		rpcErr := c.backend.CallRPC(ctx, &bi, "eth_getBlockByNumber", blockTag, false)
+		if rpcErr != nil || bi == nil || bi.GasLimit == nil {
+			log.L(ctx).Warnf("Unable to query block gas limit for gas estimation search: %v", rpcErr)
+			return nil, ""
+		}
+		hi = bi.GasLimit.BigInt()
+	}
+	hi = new(big.Int).Set(hi)
+	if !c.callSucceedsWithGas(ctx, tx, blockTag, hi) {
+		log.L(ctx).Warnf("Gas estimation search failed: eth_call fails with gas limit %s", hi)
+		return nil, ""
+	}
+	lo := big.NewInt(gasEstimateIntrinsicGas - 1)
+	for {
+		gap := new(big.Int).Sub(hi, lo)
+		if gap.Cmp(big.NewInt(1)) <= 0 || gap.Cmp(new(big.Int).Div(hi, big.NewInt(gasEstimateSearchTolerance))) <= 0 {
+			break
+		}
+		mid := new(big.Int).Add(lo, gap.Rsh(gap, 1))
+		if c.callSucceedsWithGas(ctx, tx, blockTag, mid) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi, GasEstimateMethodCallSearch
+}
+
+func (c *ethConnector) callSucceedsWithGas(ctx context.Context, tx *ethsigner.Transaction, blockTag string, gas *big.Int) bool {
+	probe := *tx
+	probe.GasLimit = (*ethtypes.HexInteger)(gas)
+	var outputData ethtypes.HexBytes0xPrefix
+	return c.backend.CallRPC(ctx, &outputData, "eth_call", &probe, blockTag) == nil
 }
@@ -22,7 +22,9 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
 	"github.com/hyperledger/firefly-signer/pkg/abi"
 	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
 	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
@@ -95,6 +97,39 @@ func TestGasEstimateFail(t *testing.T) {
 
 }
 
+func TestGasEstimatePendingState(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(PendingState, true)
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything, "pending").
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything, "pending").
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything, "pending").
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			args[1].(*ethtypes.HexInteger).BigInt().SetString("12345", 10)
+		})
+
+	var req ffcapi.TransactionInput
+	err := json.Unmarshal([]byte(sampleGasEstimate), &req)
+	assert.NoError(t, err)
+
+	// The fallback eth_call for a revert reason is also against the pending block
+	_, _, err = c.GasEstimate(ctx, &req)
+	assert.Regexp(t, "pop", err)
+
+	res, _, err := c.GasEstimate(ctx, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(18517), res.GasEstimate.Int64())
+
+	mRPC.AssertExpectations(t)
+
+}
+
 func TestGasEstimateBadFromAddress(t *testing.T) {
 
 	ctx, c, _, done := newTestConnector(t)
@@ -284,3 +319,246 @@ func TestGasEstimateFailCustomErrorCannotParse(t *testing.T) {
 func TestFormatErrorComponentBadCV(t *testing.T) {
 	assert.Equal(t, "?", formatErrorComponent(context.Background(), &abi.ComponentValue{}))
 }
+
+func mockEstimateGas(mRPC *rpcbackendmocks.Backend, gas string) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			args[1].(*ethtypes.HexInteger).BigInt().SetString(gas, 10)
+		}).Once()
+}
+
+func TestGasEstimateDetailedLimits(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ConfigGasEstimationFloor, "20000")
+		conf.Set(ConfigGasEstimationCeiling, "0x7530") // 30000
+	})
+	defer done()
+
+	mockEstimateGas(mRPC, "12345")
+	mockEstimateGas(mRPC, "15000")
+	mockEstimateGas(mRPC, "25000")
+
+	var req ffcapi.TransactionInput
+	err := json.Unmarshal([]byte(sampleGasEstimate), &req)
+	assert.NoError(t, err)
+
+	res, reason, err := c.GasEstimateDetailed(ctx, &req)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	b, err := json.Marshal(res)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"gasEstimate": "20000",
+		"breakdown": {
+			"baseEstimate": "12345",
+			"factor": 1.5,
+			"floor": "20000",
+			"ceiling": "30000",
+			"limited": "floor",
+			"fallback": false,
+			"method": "estimateGas"
+		}
+	}`, string(b))
+
+	res, _, err = c.GasEstimateDetailed(ctx, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(22500), res.GasEstimate.Int64())
+	assert.Empty(t, res.Breakdown.Limited)
+
+	res, _, err = c.GasEstimateDetailed(ctx, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30000), res.GasEstimate.Int64())
+	assert.Equal(t, int64(25000), res.Breakdown.BaseEstimate.Int64())
+	assert.Equal(t, "ceiling", res.Breakdown.Limited)
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestGasEstimateDetailedCeilingFallback(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ConfigGasEstimationCeiling, "30000")
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").Return(nil)
+
+	var req ffcapi.TransactionInput
+	err := json.Unmarshal([]byte(sampleGasEstimate), &req)
+	assert.NoError(t, err)
+
+	res, reason, err := c.GasEstimateDetailed(ctx, &req)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, int64(30000), res.GasEstimate.Int64())
+	assert.Nil(t, res.Breakdown.BaseEstimate)
+	assert.True(t, res.Breakdown.Fallback)
+	assert.Equal(t, GasEstimateMethodCeiling, res.Breakdown.Method)
+
+	// The ceiling returned is not shared with the configuration
+	res.GasEstimate.Int().SetInt64(1)
+	assert.Equal(t, int64(30000), c.tuned().gasEstimationCeiling.Int64())
+
+}
+
+func mockCallNeedsGas(mRPC *rpcbackendmocks.Backend, gasNeeded int64) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").
+		Return(func(_ context.Context, _ interface{}, _ string, args ...interface{}) *rpcbackend.RPCError {
+			tx := args[0].(*ethsigner.Transaction)
+			if tx.GasLimit != nil && tx.GasLimit.Int64() < gasNeeded {
+				return &rpcbackend.RPCError{Message: "out of gas"}
+			}
+			return nil
+		})
+}
+
+func TestGasEstimateFallbackBalanceOverride(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ConfigGasEstimationFallback, true)
+		conf.Set(ConfigGasEstimationFactor, 1)
+	})
+	defer done()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "gas required exceeds allowance (0)"}).Once()
+
+	mockCallNeedsGas(mRPC, 0)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything, "latest",
+		mock.MatchedBy(func(overrides map[string]interface{}) bool {
+			balance := overrides["0x73bd8f17787a0f9774652075e2ba5ed381246bef"].(map[string]string)["balance"]
+			return balance == gasEstimateOverrideBalance
+		})).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			args[1].(*ethtypes.HexInteger).BigInt().SetString("50000", 10)
+		})
+
+	var req ffcapi.TransactionInput
+	err := json.Unmarshal([]byte(sampleGasEstimate), &req)
+	assert.NoError(t, err)
+	res, _, err := c.GasEstimateDetailed(ctx, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50000), res.GasEstimate.Int64())
+	assert.Equal(t, int64(50000), res.Breakdown.BaseEstimate.Int64())
+	assert.Equal(t, GasEstimateMethodBalanceOverride, res.Breakdown.Method)
+	assert.False(t, res.Breakdown.Fallback)
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestGasEstimateFallbackCallSearchCeiling(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ConfigGasEstimationFallback, true)
+		conf.Set(ConfigGasEstimationFactor, 1)
+		conf.Set(ConfigGasEstimationCeiling, "1000000")
+	})
+	defer done()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "gas required exceeds allowance (0)"}).Once()
+
+	mockCallNeedsGas(mRPC, 123456)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything, "latest", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "state overrides not supported"})
+
+	var req ffcapi.TransactionInput
+	err := json.Unmarshal([]byte(sampleGasEstimate), &req)
+	assert.NoError(t, err)
+	res, _, err := c.GasEstimateDetailed(ctx, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, GasEstimateMethodCallSearch, res.Breakdown.Method)
+	// The tightest working limit, to within 1/64th
+	estimate := res.GasEstimate.Int64()
+	assert.GreaterOrEqual(t, estimate, int64(123456))
+	assert.LessOrEqual(t, estimate, int64(123456+123456/64))
+
+}
+
+func TestGasEstimateFallbackCallSearchBlockGasLimit(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ConfigGasEstimationFallback, true)
+		conf.Set(ConfigGasEstimationFactor, 1)
+	})
+	defer done()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "gas required exceeds allowance (0)"}).Once()
+
+	mockCallNeedsGas(mRPC, 21000)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything, "latest", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "latest", false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			GasLimit: ethtypes.NewHexInteger64(30000000),
+		}
+	})
+
+	var req ffcapi.TransactionInput
+	err := json.Unmarshal([]byte(sampleGasEstimate), &req)
+	assert.NoError(t, err)
+	res, _, err := c.GasEstimateDetailed(ctx, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, GasEstimateMethodCallSearch, res.Breakdown.Method)
+	estimate := res.GasEstimate.Int64()
+	assert.GreaterOrEqual(t, estimate, int64(21000))
+	assert.LessOrEqual(t, estimate, int64(21000+21000/64))
+
+}
+
+func TestGasEstimateFallbackCallSearchFails(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ConfigGasEstimationFallback, true)
+		conf.Set(ConfigGasEstimationFactor, 1)
+	})
+	defer done()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "gas required exceeds allowance (0)"}).Once()
+
+	mockCallNeedsGas(mRPC, 40000000)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything, "latest", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "latest", false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			GasLimit: ethtypes.NewHexInteger64(30000000),
+		}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "latest", false).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+
+	var req ffcapi.TransactionInput
+	err := json.Unmarshal([]byte(sampleGasEstimate), &req)
+	assert.NoError(t, err)
+
+	// eth_call fails even with the block gas limit, so the original error is returned
+	_, _, err = c.GasEstimateDetailed(ctx, &req)
+	assert.Regexp(t, "gas required exceeds allowance", err)
+
+	// The block gas limit cannot be queried
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_estimateGas", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "gas required exceeds allowance (0)"}).Once()
+	_, _, err = c.GasEstimateDetailed(ctx, &req)
+	assert.Regexp(t, "gas required exceeds allowance", err)
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestGasEstimateBadLimitConfig(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(ConfigGasEstimationCeiling, "-1")
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23121.*gasEstimationCeiling", err)
+
+	conf.Set(ConfigGasEstimationCeiling, "")
+	conf.Set(ConfigGasEstimationFloor, "lots")
+	_, err = NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23121.*gasEstimationFloor", err)
+
+}
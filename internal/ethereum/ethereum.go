@@ -19,73 +19,233 @@ package ethereum
 import (
 	"context"
 	"fmt"
-	"math/big"
 	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-resty/resty/v2"
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/ffresty"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
-	"github.com/hyperledger/firefly-common/pkg/retry"
 	"github.com/hyperledger/firefly-common/pkg/wsclient"
 	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
 	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
 	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ethConnector struct {
 	backend                    rpcbackend.Backend
 	serializer                 *abi.Serializer
-	gasEstimationFactor        *big.Float
-	catchupPageSize            int64
+	dataFormat                 abi.FormattingMode
+	checksumAddresses          bool
+	replacementFeeBumpPercent  float64
+	catchupPageSize            atomic.Int64 // reduced by catchup queries in parallel, on errors matching catchupDownscaleRegex
 	catchupThreshold           int64
 	catchupDownscaleRegex      *regexp.Regexp
+	catchupSlots               chan struct{}
+	dedupeCacheSize            int
+	walPath                    string // empty if the event write-ahead log is disabled
+	walMaxEntries              int
+	wildcardEventRate          int
+	blockPrefetchDepth         int
+	bootstrapDirectory         string
+	graphqlURL                 string
+	graphqlClient              *resty.Client
+	graphqlUnavailable         atomic.Bool
+	graphqlNoFeeMarket         atomic.Bool
+	logIndexAnomalies          atomic.Int64 // blocks re-queried by hash due to inconsistent log indexes
+	addressActivitySparseScan  bool
+	addressActivityScanned     atomic.Int64 // blocks read with their full transactions by address activity listeners
+	addressActivitySkipped     atomic.Int64 // empty blocks skipped by address activity listeners
+	logsStreaming              bool         // eth_getLogs responses are streamed by the logsStreamBackend
+	adaptiveConcurrency        *adaptiveConcurrency
+	rpcPriority                *priorityGate            // nil if disabled
+	circuitBreaker             *circuitBreaker          // nil if disabled
+	tracer                     trace.Tracer             // nil unless spans are exported
+	tracerProvider             *sdktrace.TracerProvider // flushed on shutdown
+	readQuorum                 *readQuorum              // nil if disabled
+	ipc                        *ipcBackend              // nil if JSON/RPC is over HTTP
+	eventSignatures            *eventSignatureRegistry  // nil if disabled
+	blockHashQueries           string
+	blockHashReorgDepth        int64
+	blockHashQueriesActive     atomic.Bool   // set once auto mode has seen a deep enough re-org
+	audit                      *auditor      // nil if disabled
+	preSubmitHook              *resty.Client // nil if not configured
+	postSubmitHook             *resty.Client // nil if not configured
+	maxLogsResponseSize        int64
 	checkpointBlockGap         int64
-	retry                      *retry.Retry
+	dryRunMaxBlocks            int64
+	continuityRescans          int
+	continuityViolations       atomic.Int64 // events held back as they were behind the last event delivered to their listener
+	tunables                   atomic.Pointer[tunables]
 	eventBlockTimestamps       bool
+	eventsSchemaVersion        string
+	orderedStreams             bool
 	blockListener              *blockListener
-	eventFilterPollingInterval time.Duration
 	traceTXForRevertReason     bool
+	pendingState               bool
+	receiptsNotFoundRetries    int
+	receiptsNotFoundRetryDelay time.Duration
+	receiptsNotFoundGrace      time.Duration
+	signerRoutes               []*signerRoute
+	privateRelay               *privateRelay // nil if not configured
+	errorMappings              []*errorMapping
+	submissionMaxHeadAge       time.Duration
+	submissionRejectSyncing    bool
+	submissionMaxDataSize      int64
+	submissionIntrinsicGas     bool
+	submissionBalanceCheck     bool
+	rawTxValidate              bool
+	sendIdempotencyWindow      time.Duration
+	legacyFeeFallbackEnabled   bool
+	feeModeMux                 sync.Mutex
+	feeMode                    FeeMode
+	feeModeChecked             time.Time
+	ensRegistry                *ethtypes.Address0xHex // nil if ENS resolution is disabled
+	ensCacheTTL                time.Duration
+	priorityFee                *priorityFeeEstimator // nil if disabled
+	dependencyTimeout          time.Duration
+	dependencyConfirmations    int64
+	deployBatchReceiptTimeout  time.Duration
+	txSearchMaxBlocks          int64
 
-	mux          sync.Mutex
-	eventStreams map[fftypes.UUID]*eventStream
-	txCache      *lru.Cache
+	mux              sync.Mutex
+	eventStreams     map[fftypes.UUID]*eventStream
+	receiptListeners map[fftypes.UUID]*receiptListener
+	txCache          *lru.Cache
+	sentTxCache      *lru.Cache
+	submittedOps     *lru.Cache // operation ID to the latest transaction hash, for dependent submissions
+	constructorArgs  *lru.Cache // keccak256 of deployment call data to constructor args - nil unless deployVerification is enabled
+	tokenCache       *lru.Cache
+	ensCache         *lru.Cache
+	logIndex         *logIndex         // nil if disabled
+	snapshots        *snapshotExporter // nil if disabled
+	leaderElection   *leaderElector    // nil if disabled
+	shutdown         *shutdownCoordinator
 }
 
 func NewEthereumConnector(ctx context.Context, conf config.Section) (cc ffcapi.API, err error) {
 	c := &ethConnector{
 		eventStreams:               make(map[fftypes.UUID]*eventStream),
-		catchupPageSize:            conf.GetInt64(EventsCatchupPageSize),
+		receiptListeners:           make(map[fftypes.UUID]*receiptListener),
 		catchupThreshold:           conf.GetInt64(EventsCatchupThreshold),
 		checkpointBlockGap:         conf.GetInt64(EventsCheckpointBlockGap),
+		dryRunMaxBlocks:            conf.GetInt64(EventsDryRunMaxBlocks),
+		continuityRescans:          conf.GetInt(EventsContinuityRescans),
+		dedupeCacheSize:            conf.GetInt(EventsDedupeCacheSize),
+		walPath:                    conf.GetString(EventsWALPath),
+		walMaxEntries:              conf.GetInt(EventsWALMaxEntries),
+		wildcardEventRate:          conf.GetInt(EventsWildcardEventRate),
+		blockPrefetchDepth:         conf.GetInt(EventsBlockPrefetchDepth),
+		bootstrapDirectory:         conf.GetString(EventsBootstrapDirectory),
+		shutdown:                   newShutdownCoordinator(conf.GetDuration(ShutdownTimeout)),
 		eventBlockTimestamps:       conf.GetBool(EventsBlockTimestamps),
-		eventFilterPollingInterval: conf.GetDuration(EventsFilterPollingInterval),
+		eventsSchemaVersion:        conf.GetString(EventsSchemaVersion),
+		maxLogsResponseSize:        conf.GetByteSize(EventsMaxLogsResponseSize),
 		traceTXForRevertReason:     conf.GetBool(TraceTXForRevertReason),
-		retry: &retry.Retry{
-			InitialDelay: conf.GetDuration(RetryInitDelay),
-			MaximumDelay: conf.GetDuration(RetryMaxDelay),
-			Factor:       conf.GetFloat64(RetryFactor),
-		},
+		pendingState:               conf.GetBool(PendingState),
+		checksumAddresses:          conf.GetBool(ChecksumAddresses),
+		replacementFeeBumpPercent:  conf.GetFloat64(ReplacementFeeBumpPercent),
+		receiptsNotFoundRetries:    conf.GetInt(ReceiptsNotFoundRetries),
+		receiptsNotFoundRetryDelay: conf.GetDuration(ReceiptsNotFoundRetryDelay),
+		receiptsNotFoundGrace:      conf.GetDuration(ReceiptsNotFoundGracePeriod),
+		submissionMaxHeadAge:       conf.GetDuration(SubmissionMaxHeadAge),
+		submissionRejectSyncing:    conf.GetBool(SubmissionRejectSyncing),
+		submissionMaxDataSize:      conf.GetByteSize(SubmissionMaxDataSize),
+		submissionIntrinsicGas:     conf.GetBool(SubmissionIntrinsicGasCheck),
+		submissionBalanceCheck:     conf.GetBool(SubmissionBalanceCheck),
+		rawTxValidate:              conf.GetBool(RawTransactionsValidate),
+		sendIdempotencyWindow:      conf.GetDuration(SubmissionIdempotencyWindow),
+		legacyFeeFallbackEnabled:   conf.GetBool(LegacyFeeFallback),
+		dependencyTimeout:          conf.GetDuration(SubmissionDependencyTimeout),
+		dependencyConfirmations:    conf.GetInt64(SubmissionDependencyConfs),
+		deployBatchReceiptTimeout:  conf.GetDuration(DeployBatchReceiptTimeout),
+		addressActivitySparseScan:  conf.GetBool(AddressActivitySparseScan),
+		txSearchMaxBlocks:          conf.GetInt64(TransactionSearchMaxBlocks),
 	}
-	if c.catchupThreshold < c.catchupPageSize {
-		log.L(ctx).Warnf("Catchup threshold %d must be at least as large as the catchup page size %d (overridden to %d)", c.catchupThreshold, c.catchupPageSize, c.catchupPageSize)
-		c.catchupThreshold = c.catchupPageSize
+	if err := c.applyChainProfile(ctx, conf); err != nil {
+		return nil, err
+	}
+	if err := c.validateConfig(ctx, conf); err != nil {
+		return nil, err
+	}
+	if err := validateEventSchema(ctx, c.eventsSchemaVersion); err != nil {
+		return nil, err
+	}
+	switch ordering := conf.GetString(EventsOrdering); ordering {
+	case EventOrderingListener:
+	case EventOrderingStream:
+		c.orderedStreams = true
+	default:
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidEventOrdering, ordering, strings.Join([]string{EventOrderingListener, EventOrderingStream}, ","))
+	}
+	c.catchupPageSize.Store(conf.GetInt64(EventsCatchupPageSize))
+	if catchupPageSize := c.catchupPageSize.Load(); c.catchupThreshold < catchupPageSize {
+		log.L(ctx).Warnf("Catchup threshold %d must be at least as large as the catchup page size %d (overridden to %d)", c.catchupThreshold, catchupPageSize, catchupPageSize)
+		c.catchupThreshold = catchupPageSize
+	}
+	catchupParallelism := conf.GetInt(EventsCatchupParallelism)
+	if catchupParallelism < 1 {
+		log.L(ctx).Warnf("Catchup parallelism %d must be at least 1 (overridden to 1)", catchupParallelism)
+		catchupParallelism = 1
 	}
+	c.catchupSlots = make(chan struct{}, catchupParallelism)
 
 	c.txCache, err = lru.New(conf.GetInt(TxCacheSize))
 	if err != nil {
 		return nil, i18n.WrapError(ctx, err, msgs.MsgCacheInitFail, "transaction")
 	}
+	c.tokenCache, err = lru.New(conf.GetInt(TokenCacheSize))
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, msgs.MsgCacheInitFail, "token")
+	}
+	if c.sendIdempotencyWindow > 0 {
+		c.sentTxCache, err = lru.New(conf.GetInt(SubmissionIdempotencySize))
+		if err != nil {
+			return nil, i18n.WrapError(ctx, err, msgs.MsgCacheInitFail, "sent transaction")
+		}
+	}
+	c.submittedOps, err = lru.New(conf.GetInt(SubmissionDependencySize))
+	if err != nil {
+		return nil, i18n.WrapError(ctx, err, msgs.MsgCacheInitFail, "submitted operation")
+	}
+	if err := c.initDeployVerification(ctx, conf); err != nil {
+		return nil, err
+	}
+	if err := c.initENS(ctx, conf); err != nil {
+		return nil, err
+	}
+	if err := c.initPriorityFee(ctx, conf); err != nil {
+		return nil, err
+	}
+	if err := c.initBlockHashQueries(ctx, conf); err != nil {
+		return nil, err
+	}
+	if err := c.initEventSignatures(ctx, conf); err != nil {
+		return nil, err
+	}
+	if logIndexSize := conf.GetInt(EventsLogIndexSize); logIndexSize > 0 {
+		c.logIndex = newLogIndex(logIndexSize)
+	}
 
-	if conf.GetString(ffresty.HTTPConfigURL) == "" {
+	ipcPath := conf.GetString(IPCPath)
+	if conf.GetString(ffresty.HTTPConfigURL) == "" && ipcPath == "" {
 		return nil, i18n.NewError(ctx, msgs.MsgMissingBackendURL)
 	}
-	c.gasEstimationFactor = big.NewFloat(conf.GetFloat64(ConfigGasEstimationFactor))
+	t, err := newTunables(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	c.tunables.Store(t)
 
 	c.catchupDownscaleRegex, err = regexp.Compile(conf.GetString(EventsCatchupDownscaleRegex))
 	if err != nil {
@@ -99,40 +259,117 @@ func NewEthereumConnector(ctx context.Context, conf config.Section) (cc ffcapi.A
 		// not as a full replacement for HTTP.
 		wsConf, err = wsclient.GenerateConfig(ctx, conf)
 	}
+	if err == nil && wsConf != nil {
+		err = checkWebSocketProxy(ctx, conf, wsConf)
+	}
 	if err == nil {
 		httpConf, err = ffresty.GenerateConfig(ctx, conf)
 	}
 	if err != nil {
 		return nil, err
 	}
+	// The SNI override applies to both transports, for nodes reached by IP address or via a load balancer
+	serverName := conf.SubSection("tls").GetString(TLSServerName)
+	if wsConf != nil {
+		wsConf.TLSClientConfig = withServerName(wsConf.TLSClientConfig, serverName)
+	}
+	httpConf.TLSClientConfig = withServerName(httpConf.TLSClientConfig, serverName)
 	httpClient := ffresty.NewWithConfig(ctx, *httpConf)
-	c.backend = rpcbackend.NewRPCClientWithOption(httpClient, rpcbackend.RPCClientOptions{
-		MaxConcurrentRequest: conf.GetInt64(MaxConcurrentRequests),
-	})
+	if err := configureRPCProxy(ctx, conf, httpClient); err != nil {
+		return nil, err
+	}
+	if err := configureRPCAuth(ctx, conf, httpClient); err != nil {
+		return nil, err
+	}
+	configureRPCTracing(ctx, conf, httpClient)
+	if err := c.initOTLPTracing(ctx, conf); err != nil {
+		return nil, err
+	}
+	if c.graphqlURL = conf.GetString(GraphQLURL); c.graphqlURL != "" {
+		// Shares the HTTP client of the JSON/RPC endpoint, including TLS and authentication
+		c.graphqlClient = httpClient
+	}
+	adaptiveConcurrency := conf.GetBool(AdaptiveConcurrencyEnabled)
+	if ipcPath != "" {
+		// All JSON/RPC requests, including eth_getLogs, are sent over the socket rather than HTTP
+		maxConcurrentRequests := conf.GetInt64(MaxConcurrentRequests)
+		if adaptiveConcurrency {
+			maxConcurrentRequests = 0
+		}
+		c.ipc = newIPCBackend(ipcPath, conf.GetDuration(IPCDialTimeout), httpConf, maxConcurrentRequests)
+	}
+	c.logsStreaming = c.ipc == nil
+	switch {
+	case adaptiveConcurrency:
+		// The configured maximum is the ceiling of the adaptive limit, rather than a fixed limit
+		var backend rpcbackend.Backend = newLogsStreamBackend(httpClient, c.maxLogsResponseSize, 0)
+		if c.ipc != nil {
+			backend = c.ipc
+		}
+		c.adaptiveConcurrency = newAdaptiveConcurrency(backend, conf)
+		c.backend = c.adaptiveConcurrency
+	case c.ipc != nil:
+		c.backend = c.ipc
+	default:
+		c.backend = newLogsStreamBackend(httpClient, c.maxLogsResponseSize, conf.GetInt64(MaxConcurrentRequests))
+	}
+	c.initRPCPriority(ctx, conf)
+	if failureThreshold := conf.GetInt(CircuitBreakerThreshold); failureThreshold > 0 {
+		c.circuitBreaker = newCircuitBreaker(c.backend, failureThreshold, conf.GetDuration(CircuitBreakerResetDelay))
+		c.backend = c.circuitBreaker
+	}
+	if c.tracer != nil {
+		c.backend = &tracedBackend{Backend: c.backend, tracer: c.tracer}
+	}
+	if err := c.initReadQuorum(ctx, conf, httpConf); err != nil {
+		return nil, err
+	}
+	if err := c.initAudit(ctx, conf); err != nil {
+		return nil, err
+	}
+	if c.signerRoutes, err = newSignerRoutes(ctx, conf, httpConf); err != nil {
+		return nil, err
+	}
+	if c.errorMappings, err = newErrorMappings(ctx, conf); err != nil {
+		return nil, err
+	}
+	c.initSubmissionHooks(ctx, conf, httpConf)
+	if err := c.initPrivateRelay(ctx, conf, httpConf); err != nil {
+		return nil, err
+	}
 
-	c.serializer = abi.NewSerializer().SetByteSerializer(abi.HexByteSerializer0xPrefix)
 	switch conf.Get(ConfigDataFormat) {
 	case "map":
-		c.serializer.SetFormattingMode(abi.FormatAsObjects)
+		c.dataFormat = abi.FormatAsObjects
 	case "flat_array":
-		c.serializer.SetFormattingMode(abi.FormatAsFlatArrays)
+		c.dataFormat = abi.FormatAsFlatArrays
 	case "self_describing":
-		c.serializer.SetFormattingMode(abi.FormatAsSelfDescribingArrays)
+		c.dataFormat = abi.FormatAsSelfDescribingArrays
 	default:
 		return nil, i18n.NewError(ctx, msgs.MsgBadDataFormat, conf.Get(ConfigDataFormat), "map,flat_array,self_describing")
 	}
-	c.serializer.SetDefaultNameGenerator(func(idx int) string {
-		name := "output"
-		if idx > 0 {
-			name = fmt.Sprintf("%s%v", name, idx)
-		}
-		return name
-	})
+	c.serializer = c.newSerializer()
+
+	if err := c.initSnapshots(ctx, conf); err != nil {
+		return nil, err
+	}
+	if err := c.initLeaderElection(ctx, conf); err != nil {
+		return nil, err
+	}
 
 	if c.blockListener, err = newBlockListener(ctx, c, conf, wsConf); err != nil {
 		return nil, err
 	}
+	if c.snapshots != nil {
+		c.snapshots.start(ctx)
+	}
+	if c.leaderElection != nil {
+		c.leaderElection.start(ctx)
+	}
 
+	if c.audit != nil {
+		return &auditedConnector{ethConnector: c}, nil
+	}
 	return c, nil
 }
 
@@ -144,4 +381,27 @@ func (c *ethConnector) WaitClosed() {
 	for _, s := range c.eventStreams {
 		<-s.streamLoopDone
 	}
+	if c.snapshots != nil {
+		<-c.snapshots.loopDone
+	}
+	if c.leaderElection != nil {
+		<-c.leaderElection.loopDone
+	}
+	c.audit.close()
+	c.ipc.close()
+}
+
+// newSerializer builds a serializer for the configured data format, so that variations of it can be
+// built with different options (such as the integer formatting requested by a listener)
+func (c *ethConnector) newSerializer() *abi.Serializer {
+	return abi.NewSerializer().
+		SetByteSerializer(abi.HexByteSerializer0xPrefix).
+		SetFormattingMode(c.dataFormat).
+		SetDefaultNameGenerator(func(idx int) string {
+			name := "output"
+			if idx > 0 {
+				name = fmt.Sprintf("%s%v", name, idx)
+			}
+			return name
+		})
 }
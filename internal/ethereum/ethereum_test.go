@@ -49,6 +49,7 @@ func newTestConnector(t *testing.T, confSetup ...func(conf config.Section)) (con
 	assert.NoError(t, err)
 	c := cc.(*ethConnector)
 	c.backend = mRPC
+	c.logsStreaming = false
 	c.blockListener.backend = mRPC
 	return ctx, c, mRPC, func() {
 		done()
@@ -76,6 +77,12 @@ func TestConnectorInit(t *testing.T) {
 	cc, err = NewEthereumConnector(context.Background(), conf)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(500), cc.(*ethConnector).catchupThreshold) // set to page size
+	assert.Equal(t, DefaultEventsCatchupParallelism, cap(cc.(*ethConnector).catchupSlots))
+
+	conf.Set(EventsCatchupParallelism, 0)
+	cc, err = NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cap(cc.(*ethConnector).catchupSlots)) // overridden to 1
 
 	params := &abi.ParameterArray{
 		{Name: "x", Type: "uint256"},
@@ -119,12 +126,12 @@ func TestConnectorInit(t *testing.T) {
 	conf.Set(ConfigDataFormat, "map")
 	conf.Set(BlockCacheSize, "-1")
 	cc, err = NewEthereumConnector(context.Background(), conf)
-	assert.Regexp(t, "FF23040", err)
+	assert.Regexp(t, "FF23185.*blockCacheSize", err)
 
 	conf.Set(BlockCacheSize, "1")
 	conf.Set(TxCacheSize, "-1")
 	cc, err = NewEthereumConnector(context.Background(), conf)
-	assert.Regexp(t, "FF23040", err)
+	assert.Regexp(t, "FF23185.*txCacheSize", err)
 
 	conf.Set(TxCacheSize, "1")
 	conf.Set(EventsCatchupDownscaleRegex, "[")
@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
@@ -27,6 +28,9 @@ import (
 )
 
 func (c *ethConnector) EventStreamStart(ctx context.Context, req *ffcapi.EventStreamStartRequest) (*ffcapi.EventStreamStartResponse, ffcapi.ErrorReason, error) {
+	if c.shutdown.isDraining() {
+		return nil, ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, msgs.MsgShuttingDown)
+	}
 	c.mux.Lock()
 	defer c.mux.Unlock()
 	es := c.eventStreams[*req.ID]
@@ -42,6 +46,14 @@ func (c *ethConnector) EventStreamStart(ctx context.Context, req *ffcapi.EventSt
 		headBlock:      -1,
 		listeners:      make(map[fftypes.UUID]*listener),
 		streamLoopDone: make(chan struct{}),
+		leaderTerm:     c.leaderElection.currentTerm(),
+	}
+	if c.dedupeCacheSize > 0 {
+		es.dedupeCache, _ = lru.New(c.dedupeCacheSize) // only errors on a size <= 0
+	}
+	var err error
+	if es.wal, err = c.openEventWAL(ctx, req.ID); err != nil {
+		return nil, "", err
 	}
 
 	// We add all the initial event listeners, checking for errors, before kicking off the streamLoop().
@@ -51,6 +63,7 @@ func (c *ethConnector) EventStreamStart(ctx context.Context, req *ffcapi.EventSt
 		// the chain head, then start them all after that.
 		_, err := es.addEventListener(ctx, il)
 		if err != nil {
+			es.wal.close()
 			return nil, "", err
 		}
 	}
@@ -98,13 +111,14 @@ func (c *ethConnector) EventStreamStopped(ctx context.Context, req *ffcapi.Event
 				<-l.catchupLoopDone
 			}
 		}
+		es.wal.close()
 	}
 	return &ffcapi.EventStreamStoppedResponse{}, "", nil
 }
 
 func (c *ethConnector) EventListenerVerifyOptions(ctx context.Context, req *ffcapi.EventListenerVerifyOptionsRequest) (*ffcapi.EventListenerVerifyOptionsResponse, ffcapi.ErrorReason, error) {
 
-	signature, _, err := parseEventFilters(ctx, req.Filters)
+	signature, filters, err := parseEventFilters(ctx, req.Filters)
 	if err != nil {
 		return nil, "", err
 	}
@@ -114,6 +128,16 @@ func (c *ethConnector) EventListenerVerifyOptions(ctx context.Context, req *ffca
 		return nil, "", err
 	}
 
+	// A listener that matches events from all contracts must be explicit about where it starts
+	if req.FromBlock == "" && isWildcardListener(filters, options) {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgWildcardFromBlockRequired)
+	}
+
+	if options.Factory != nil {
+		ff, _ := newFactoryFilter(ctx, options.Factory) // validated by parseListenerOptions
+		signature = ff.listenerSignature(signature)
+	}
+
 	ob, _ := json.Marshal(&options)
 	return &ffcapi.EventListenerVerifyOptionsResponse{
 		ResolvedSignature: signature,
@@ -123,6 +147,9 @@ func (c *ethConnector) EventListenerVerifyOptions(ctx context.Context, req *ffca
 }
 
 func (c *ethConnector) EventListenerAdd(ctx context.Context, req *ffcapi.EventListenerAddRequest) (*ffcapi.EventListenerAddResponse, ffcapi.ErrorReason, error) {
+	if c.shutdown.isDraining() {
+		return nil, ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, msgs.MsgShuttingDown)
+	}
 	c.mux.Lock()
 	es := c.eventStreams[*req.StreamID]
 	c.mux.Unlock()
@@ -225,6 +225,35 @@ func TestEventListenerVerifyOptionsOk(t *testing.T) {
 
 }
 
+func TestEventListenerVerifyOptionsWildcardFromBlock(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	mockStreamLoopEmpty(mRPC)
+
+	req := &ffcapi.EventListenerVerifyOptionsRequest{
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{*fftypes.JSONAnyPtr(`{"event": ` + abiTransferEvent + `}`)},
+			Options: fftypes.JSONAnyPtr(`{}`),
+		},
+	}
+	_, reason, err := c.EventListenerVerifyOptions(ctx, req)
+	assert.Regexp(t, "FF23152", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	// Not required when the addresses are restricted by the listener options
+	req.Options = fftypes.JSONAnyPtr(`{"addresses":["0x5600fF383458ae30dE902D096bA89f7F81f0a2fC"]}`)
+	_, _, err = c.EventListenerVerifyOptions(ctx, req)
+	assert.NoError(t, err)
+
+	req.Options = fftypes.JSONAnyPtr(`{}`)
+	req.FromBlock = "latest"
+	res, _, err := c.EventListenerVerifyOptions(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, `*:Transfer(address,address,uint256)`, res.ResolvedSignature)
+
+}
+
 func TestEventListenerVerifyOptionsBadFilters(t *testing.T) {
 
 	ctx, c, mRPC, done := newTestConnector(t)
@@ -0,0 +1,165 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// eventPosition returns the position of an event on the chain, in the order of the protocol IDs
+func eventPosition(event *ffcapi.ListenerEvent) *listenerCheckpoint {
+	id := &event.Event.ID
+	return &listenerCheckpoint{
+		Block:            int64(id.BlockNumber),
+		TransactionIndex: int64(id.TransactionIndex),
+		LogIndex:         int64(id.LogIndex),
+	}
+}
+
+// deliveredKey identifies an event delivered to a listener, including the block hash so that the event of a new
+// fork in the same position is not mistaken for it
+func deliveredKey(event *ffcapi.ListenerEvent) string {
+	id := &event.Event.ID
+	return fmt.Sprintf("%s/%s", getEventProtoID(int64(id.BlockNumber), int64(id.TransactionIndex), int64(id.LogIndex)), id.BlockHash)
+}
+
+// continuityState is the position of a listener as a batch is checked, which only replaces that of the listener
+// if the batch is delivered
+type continuityState struct {
+	lastDelivered *listenerCheckpoint
+	added         map[string]int64 // events delivered by the batch, to the block they are in
+	removed       map[string]bool  // events removed by the batch
+}
+
+func (cs *continuityState) isDelivered(l *listener, key string) bool {
+	if _, added := cs.added[key]; added {
+		return true
+	}
+	_, delivered := l.delivered[key]
+	return delivered && !cs.removed[key]
+}
+
+// checkContinuity checks the events are not behind the last event delivered to their listener. Within a batch
+// the events are sorted, so this only happens when a provider is inconsistent between queries - such as a load
+// balanced endpoint with a node that is behind the others, returning a log late or from a stale fork.
+// Rather than deliver the event out of order, rescan is returned true with no events, and the caller must query
+// again from the checkpoint of the listeners, without moving it. Otherwise the caller must call commit once the
+// batch is to be delivered, to move the position of the listeners past it. The whole batch is held back, as the events
+// before the late one are returned again by that query. An event that is still returned after the configured
+// number of re-scans is genuinely late, so is delivered out of order rather than lost.
+//
+// The events delivered at or above the checkpoint of each listener are recorded, as any query from the checkpoint
+// returns them again. They are removed rather than treated as late. Events for removed logs lower the position
+// of the listener to the start of their block, so the events of the new fork are accepted after a re-org.
+func (es *eventStream) checkContinuity(listeners []*listener, events ffcapi.ListenerEvents) (_ ffcapi.ListenerEvents, rescan bool, commit func()) {
+	maxRescans := es.c.continuityRescans
+	if maxRescans <= 0 {
+		return events, false, func() {}
+	}
+	byID := make(map[string]*listener, len(listeners))
+	states := make(map[*listener]*continuityState, len(listeners))
+	for _, l := range listeners {
+		byID[l.id.String()] = l
+		l.hwmMux.Lock()
+		for key, block := range l.delivered {
+			if block < l.hwmBlock {
+				delete(l.delivered, key)
+			}
+		}
+		states[l] = &continuityState{lastDelivered: l.lastDelivered, added: map[string]int64{}, removed: map[string]bool{}}
+		l.hwmMux.Unlock()
+	}
+	violated := make(map[*listener]bool)
+	batch := make(ffcapi.ListenerEvents, 0, len(events))
+	for _, event := range events {
+		var l *listener
+		if event.Event != nil {
+			l = byID[event.Event.ID.ListenerID.String()]
+		}
+		if l == nil {
+			batch = append(batch, event)
+			continue
+		}
+		cs := states[l]
+		position := eventPosition(event)
+		key := deliveredKey(event)
+		l.hwmMux.Lock()
+		delivered := cs.isDelivered(l, key)
+		hwmBlock, rescans := l.hwmBlock, l.continuityRescans
+		l.hwmMux.Unlock()
+		switch {
+		case event.Removed:
+			if floor := (&listenerCheckpoint{Block: position.Block, TransactionIndex: -1, LogIndex: -1}); cs.lastDelivered == nil || floor.LessThan(cs.lastDelivered) {
+				cs.lastDelivered = floor
+			}
+			delete(cs.added, key)
+			cs.removed[key] = true
+		case delivered:
+			// Returned again by a query from the checkpoint of the listener
+			continue
+		case cs.lastDelivered != nil && position.Block >= hwmBlock && position.LessThan(cs.lastDelivered):
+			if rescans < maxRescans {
+				violated[l] = true
+				es.c.continuityViolations.Add(1)
+				log.L(es.ctx).Warnf("Event %s is behind the last event delivered to listener %s at %d/%d/%d - querying again from checkpoint block %d (rescan %d/%d)",
+					event.Event, l.id, cs.lastDelivered.Block, cs.lastDelivered.TransactionIndex, cs.lastDelivered.LogIndex, hwmBlock, rescans+1, maxRescans)
+				continue
+			}
+			log.L(es.ctx).Errorf("Event %s is behind the last event delivered to listener %s at %d/%d/%d after %d rescans - delivering out of order",
+				event.Event, l.id, cs.lastDelivered.Block, cs.lastDelivered.TransactionIndex, cs.lastDelivered.LogIndex, maxRescans)
+			cs.added[key] = position.Block
+		default:
+			if cs.lastDelivered == nil || cs.lastDelivered.LessThan(position) {
+				cs.lastDelivered = position
+			}
+			cs.added[key] = position.Block
+		}
+		batch = append(batch, event)
+	}
+	if len(violated) > 0 {
+		for l := range states {
+			l.hwmMux.Lock()
+			if violated[l] {
+				l.continuityRescans++
+			} else {
+				l.continuityRescans = 0
+			}
+			l.hwmMux.Unlock()
+		}
+		return nil, true, func() {}
+	}
+	return batch, false, func() {
+		for l, cs := range states {
+			l.hwmMux.Lock()
+			l.continuityRescans = 0
+			l.lastDelivered = cs.lastDelivered
+			if l.delivered == nil {
+				l.delivered = make(map[string]int64)
+			}
+			for key := range cs.removed {
+				delete(l.delivered, key)
+			}
+			for key, block := range cs.added {
+				l.delivered[key] = block
+			}
+			l.hwmMux.Unlock()
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newContinuityEvent(l *listener, block, txIndex, logIndex uint64, removed bool) *ffcapi.ListenerEvent {
+	return &ffcapi.ListenerEvent{
+		Event: &ffcapi.Event{ID: ffcapi.EventID{
+			ListenerID:       l.id,
+			BlockNumber:      fftypes.FFuint64(block),
+			TransactionIndex: fftypes.FFuint64(txIndex),
+			LogIndex:         fftypes.FFuint64(logIndex),
+		}},
+		Removed: removed,
+	}
+}
+
+func checkContinuityCommit(es *eventStream, listeners []*listener, events ffcapi.ListenerEvents) (ffcapi.ListenerEvents, bool) {
+	batch, rescan, commit := es.checkContinuity(listeners, events)
+	commit()
+	return batch, rescan
+}
+
+func TestCheckContinuity(t *testing.T) {
+
+	l, _, cancelCtx := newTestListener(t, false)
+	defer cancelCtx()
+	es := l.es
+	l.hwmBlock = 100
+
+	events, rescan := checkContinuityCommit(es, []*listener{l}, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 105, 1, 0, false),
+		newContinuityEvent(l, 105, 1, 1, false),
+		{BlockEvent: &ffcapi.BlockEvent{}},
+	})
+	assert.False(t, rescan)
+	assert.Len(t, events, 3)
+	assert.Equal(t, &listenerCheckpoint{Block: 105, TransactionIndex: 1, LogIndex: 1}, l.lastDelivered)
+
+	// The events already delivered are removed when the range is queried again
+	events, rescan = checkContinuityCommit(es, []*listener{l}, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 105, 1, 0, false),
+		newContinuityEvent(l, 105, 1, 1, false),
+		newContinuityEvent(l, 106, 0, 0, false),
+	})
+	assert.False(t, rescan)
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(106), l.lastDelivered.Block)
+
+	// A late event holds back the whole batch for each of the re-scans, then is delivered out of order
+	for i := 1; i <= 3; i++ {
+		events, rescan = checkContinuityCommit(es, []*listener{l}, ffcapi.ListenerEvents{
+			newContinuityEvent(l, 103, 0, 0, false),
+			newContinuityEvent(l, 107, 0, 0, false),
+		})
+		assert.True(t, rescan)
+		assert.Empty(t, events)
+		assert.Equal(t, i, l.continuityRescans)
+		assert.Equal(t, int64(106), l.lastDelivered.Block)
+	}
+	events, rescan = checkContinuityCommit(es, []*listener{l}, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 103, 0, 0, false),
+		newContinuityEvent(l, 107, 0, 0, false),
+	})
+	assert.False(t, rescan)
+	assert.Len(t, events, 2)
+	assert.Zero(t, l.continuityRescans)
+	assert.Equal(t, int64(3), es.c.continuityViolations.Load())
+	assert.Equal(t, int64(107), l.lastDelivered.Block)
+
+	// Events behind the checkpoint of the listener are not checked
+	events, rescan = checkContinuityCommit(es, []*listener{l}, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 99, 0, 0, false),
+	})
+	assert.False(t, rescan)
+	assert.Len(t, events, 1)
+
+	// A re-org lowers the position to the start of the block of the removed event
+	events, rescan = checkContinuityCommit(es, []*listener{l}, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 107, 0, 0, true),
+		newContinuityEvent(l, 104, 0, 0, true),
+		newContinuityEvent(l, 104, 0, 0, false),
+		newContinuityEvent(l, 105, 3, 0, false),
+	})
+	assert.False(t, rescan)
+	assert.Len(t, events, 4)
+	assert.Equal(t, &listenerCheckpoint{Block: 105, TransactionIndex: 3, LogIndex: 0}, l.lastDelivered)
+
+	// Disabled
+	es.c.continuityRescans = 0
+	events, rescan = checkContinuityCommit(es, []*listener{l}, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 101, 0, 0, false),
+	})
+	assert.False(t, rescan)
+	assert.Len(t, events, 1)
+
+}
+
+func TestCheckContinuityListenerGroup(t *testing.T) {
+
+	l1, _, cancelCtx := newTestListener(t, false)
+	defer cancelCtx()
+	es := l1.es
+	l1.hwmBlock = 100
+	l2 := &listener{id: fftypes.NewUUID(), hwmBlock: 100}
+	group := []*listener{l1, l2}
+
+	events, rescan := checkContinuityCommit(es, group, ffcapi.ListenerEvents{
+		newContinuityEvent(l2, 104, 0, 0, false),
+		newContinuityEvent(l1, 105, 0, 0, false),
+	})
+	assert.False(t, rescan)
+	assert.Len(t, events, 2)
+
+	// A late event for one listener holds back the events of the other, which are not yet delivered
+	events, rescan = checkContinuityCommit(es, group, ffcapi.ListenerEvents{
+		newContinuityEvent(l1, 103, 0, 0, false),
+		newContinuityEvent(l2, 104, 0, 0, false),
+		newContinuityEvent(l2, 106, 0, 0, false),
+	})
+	assert.True(t, rescan)
+	assert.Empty(t, events)
+	assert.Equal(t, 1, l1.continuityRescans)
+	assert.Zero(t, l2.continuityRescans)
+	assert.Equal(t, int64(104), l2.lastDelivered.Block)
+
+	// The re-scan delivers each event once, without treating those delivered before as late
+	events, rescan = checkContinuityCommit(es, group, ffcapi.ListenerEvents{
+		newContinuityEvent(l2, 104, 0, 0, false),
+		newContinuityEvent(l1, 105, 0, 0, false),
+		newContinuityEvent(l2, 106, 0, 0, false),
+		newContinuityEvent(l1, 107, 0, 0, false),
+	})
+	assert.False(t, rescan)
+	assert.Len(t, events, 2)
+	assert.Equal(t, uint64(106), uint64(events[0].Event.ID.BlockNumber))
+	assert.Equal(t, uint64(107), uint64(events[1].Event.ID.BlockNumber))
+	assert.Zero(t, l1.continuityRescans)
+	assert.Equal(t, int64(1), es.c.continuityViolations.Load())
+
+	// Once the checkpoint moves past them, the delivered events are no longer recorded
+	l1.hwmBlock, l2.hwmBlock = 108, 108
+	_, _ = checkContinuityCommit(es, group, ffcapi.ListenerEvents{})
+	assert.Empty(t, l1.delivered)
+	assert.Empty(t, l2.delivered)
+
+}
+
+func TestDispatchContinuityHoldsHWM(t *testing.T) {
+
+	l, _, cancelCtx := newTestListener(t, false)
+	defer cancelCtx()
+	es := l.es
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	es.events = events
+	l.hwmBlock = 100
+	l.lastDelivered = &listenerCheckpoint{Block: 105}
+	ag := &aggregatedListener{listeners: []*listener{l}}
+
+	exiting, err := es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 103, 0, 0, false),
+	}, 110, 0)
+	assert.False(t, exiting)
+	assert.NoError(t, err)
+	assert.True(t, es.rescan)
+	assert.Empty(t, events)
+	assert.Equal(t, int64(100), l.hwmBlock)
+
+	// The re-scan does not return the inconsistent event
+	exiting, err = es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{}, 110, 0)
+	assert.False(t, exiting)
+	assert.NoError(t, err)
+	assert.False(t, es.rescan)
+	assert.Equal(t, int64(110), l.hwmBlock)
+
+}
+
+func TestDispatchContinuityDedupeRescan(t *testing.T) {
+
+	l, _, cancelCtx := newTestListener(t, false)
+	defer cancelCtx()
+	es := l.es
+	es.dedupeCache, _ = lru.New(10)
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	es.events = events
+	l.hwmBlock = 100
+	l.lastDelivered = &listenerCheckpoint{Block: 105}
+	ag := &aggregatedListener{listeners: []*listener{l}}
+
+	_, err := es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 103, 0, 0, false),
+		newContinuityEvent(l, 106, 0, 0, false),
+	}, 110, 0)
+	assert.NoError(t, err)
+	assert.True(t, es.rescan)
+	assert.Empty(t, events)
+
+	// The events held back are not mistaken for duplicates when the re-scan returns them again
+	_, err = es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 103, 0, 0, false),
+		newContinuityEvent(l, 106, 0, 0, false),
+	}, 110, 0)
+	assert.NoError(t, err)
+	assert.True(t, es.rescan)
+	assert.Zero(t, es.duplicates.Load())
+
+	// The listener does not move past a batch that cannot be recorded in the write-ahead log
+	es.wal = &eventWAL{ctx: es.ctx, closed: true, maxEntries: 10}
+	_, err = es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 106, 0, 0, false),
+	}, 110, 0)
+	assert.Regexp(t, "FF23150", err)
+	assert.True(t, es.rescan)
+	assert.Equal(t, int64(105), l.lastDelivered.Block)
+	assert.Empty(t, l.delivered)
+
+	es.wal = nil
+	_, err = es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 106, 0, 0, false),
+	}, 110, 0)
+	assert.NoError(t, err)
+	assert.False(t, es.rescan)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, fftypes.FFuint64(106), (<-events).Event.ID.BlockNumber)
+	}
+	assert.Zero(t, es.duplicates.Load())
+	assert.Equal(t, int64(110), l.hwmBlock)
+
+}
+
+func TestListenerCatchupContinuityRescan(t *testing.T) {
+
+	l, mRPC, cancelCtx := newTestListener(t, false)
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	l.es.events = events
+	l.hwmBlock = 1000
+	l.lastDelivered = &listenerCheckpoint{Block: 1030}
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1024),
+		}
+	})
+	fromBlock1000 := mock.MatchedBy(func(filter *logFilterJSONRPC) bool {
+		return filter.FromBlock.BigInt().Int64() == 1000
+	})
+	// The log at block 1024 is behind the last event delivered, and is not returned when the range is queried again
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", fromBlock1000).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{sampleTransferLog()}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", fromBlock1000).Return(nil).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(&rpcbackend.RPCError{Message: "pop"}).Run(func(args mock.Arguments) {
+		cancelCtx()
+	})
+
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
+
+	assert.Empty(t, events)
+	assert.Equal(t, int64(1), l.c.continuityViolations.Load())
+	assert.Greater(t, l.hwmBlock, int64(1000))
+
+}
+
+func TestEventPosition(t *testing.T) {
+	l := &listener{id: fftypes.NewUUID()}
+	assert.Equal(t, &listenerCheckpoint{Block: 1, TransactionIndex: 2, LogIndex: 3}, eventPosition(newContinuityEvent(l, 1, 2, 3, false)))
+}
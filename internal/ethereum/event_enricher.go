@@ -19,6 +19,8 @@ package ethereum
 import (
 	"bytes"
 	"context"
+	"math/big"
+	"strings"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/log"
@@ -27,9 +29,56 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
+const (
+	intFormatDecimal = "decimal"
+	intFormatHex     = "hex"
+	intFormatScaled  = "scaled"
+
+	maxScaledDecimals = 77 // a uint256 has at most 78 decimal digits
+)
+
 type eventEnricher struct {
-	connector     *ethConnector
-	extractSigner bool
+	connector         *ethConnector
+	extractSigner     bool
+	tokenTransfers    bool
+	serializer        *abi.Serializer
+	checksumAddresses bool
+	schemaVersion     string
+	subID             string           // the listener ID, for the ethconnect schema
+	fields            *fieldProjection // nil to deliver all fields
+}
+
+// serializerForOptions returns the connector serializer, or a serializer with the integer formatting
+// requested in the listener options
+func (c *ethConnector) serializerForOptions(options *listenerOptions) *abi.Serializer {
+	switch options.IntFormat {
+	case intFormatHex:
+		return c.newSerializer().SetIntSerializer(abi.HexIntSerializer0xPrefix)
+	case intFormatScaled:
+		return c.newSerializer().SetIntSerializer(scaledDecimalIntSerializer(*options.Decimals))
+	default:
+		return c.serializer
+	}
+}
+
+// scaledDecimalIntSerializer formats integers as decimal strings, with the decimal point shifted left by the
+// supplied number of decimals. Trailing zeros after the decimal point are removed, so the result is exact.
+func scaledDecimalIntSerializer(decimals int) abi.IntSerializer {
+	return func(i *big.Int) interface{} {
+		digits := new(big.Int).Abs(i).String()
+		if len(digits) <= decimals {
+			digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+		}
+		whole, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+		s := whole
+		if fraction != "" {
+			s += "." + fraction
+		}
+		if i.Sign() < 0 {
+			s = "-" + s
+		}
+		return s
+	}
 }
 
 func (ee *eventEnricher) filterEnrichEthLog(ctx context.Context, f *eventFilter, methods []*abi.Entry, ethLog *logJSONRPC) (_ *ffcapi.Event, matched bool, decoded bool, err error) {
@@ -43,17 +92,34 @@ func (ee *eventEnricher) filterEnrichEthLog(ctx context.Context, f *eventFilter,
 	// Apply a post-filter check to the event
 	topicMatches := len(ethLog.Topics) > 0 && bytes.Equal(ethLog.Topics[0], f.Topic0)
 	addrMatches := f.Address == nil || bytes.Equal(ethLog.Address[:], f.Address[:])
+	if topicMatches && !f.matchesTopics(ethLog.Topics) {
+		log.L(ctx).Debugf("skipping event '%s' with indexed values not in the filter topics", protoID)
+		return nil, matched, decoded, nil
+	}
 	if !topicMatches || !addrMatches {
 		log.L(ctx).Debugf("skipping event '%s' topicMatches=%t addrMatches=%t", protoID, topicMatches, addrMatches)
 		return nil, matched, decoded, nil
 	}
 	matched = true
 
-	log.L(ctx).Infof("detected event '%s'", protoID)
+	label := ee.connector.eventSignatures.label(ethLog.Topics)
+	if label != "" {
+		log.L(ctx).Infof("detected event '%s' [%s]", protoID, label)
+	} else {
+		log.L(ctx).Infof("detected event '%s'", protoID)
+	}
 	data, decoded := ee.decodeLogData(ctx, f.Event, ethLog.Topics, ethLog.Data)
+	if !decoded && label != "" {
+		log.L(ctx).Errorf("Event '%s' from %s could not be decoded, and is registered as: %s", protoID, ethLog.Address, label)
+	}
 
 	info := eventInfo{
-		logJSONRPC: *ethLog,
+		logJSONRPC:        *ethLog,
+		EventLabel:        label,
+		checksumAddresses: ee.checksumAddresses,
+	}
+	if ee.tokenTransfers {
+		info.Token = ee.connector.getTokenEventInfo(ctx, ethLog, ee.checksumAddresses)
 	}
 
 	var timestamp *fftypes.FFTime
@@ -85,7 +151,7 @@ func (ee *eventEnricher) filterEnrichEthLog(ctx context.Context, f *eventFilter,
 	}
 
 	signature := f.Signature
-	return &ffcapi.Event{
+	event := &ffcapi.Event{
 		ID: ffcapi.EventID{
 			Signature:        signature,
 			BlockHash:        ethLog.BlockHash.String(),
@@ -95,19 +161,24 @@ func (ee *eventEnricher) filterEnrichEthLog(ctx context.Context, f *eventFilter,
 			LogIndex:         fftypes.FFuint64(logIndex),
 			Timestamp:        timestamp,
 		},
-		Info: &info,
+		Info: ee.schemaEventInfo(&info),
 		Data: data,
-	}, matched, decoded, nil
+	}
+	ee.fields.apply(ctx, event)
+	return event, matched, decoded, nil
 }
 
 func (ee *eventEnricher) decodeLogData(ctx context.Context, event *abi.Entry, topics []ethtypes.HexBytes0xPrefix, data ethtypes.HexBytes0xPrefix) (*fftypes.JSONAny, bool) {
 	var b []byte
 	v, err := event.DecodeEventDataCtx(ctx, topics, data)
 	if err == nil {
-		b, err = ee.connector.serializer.SerializeJSONCtx(ctx, v)
+		if ee.checksumAddresses {
+			checksumABIAddresses(v)
+		}
+		b, err = ee.serializer.SerializeJSONCtx(ctx, v)
 	}
 	if err != nil {
-		log.L(ctx).Errorf("Failed to process event log: %s", err)
+		log.L(ctx).Errorf("Failed to process event log using '%s': %s", event.String(), err)
 		return nil, false
 	}
 	return fftypes.JSONAnyPtrBytes(b), true
@@ -134,7 +205,10 @@ func (ee *eventEnricher) matchMethod(ctx context.Context, methods []*abi.Entry,
 	v, err := method.DecodeCallDataCtx(ctx, txInfo.Input)
 	var b []byte
 	if err == nil {
-		b, err = ee.connector.serializer.SerializeJSONCtx(ctx, v)
+		if ee.checksumAddresses {
+			checksumABIAddresses(v)
+		}
+		b, err = ee.serializer.SerializeJSONCtx(ctx, v)
 	}
 	if err != nil {
 		log.L(ctx).Warnf("Failed to decode input for TX '%s' using '%s'", txInfo.Hash, info.InputMethod)
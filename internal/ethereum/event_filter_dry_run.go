@@ -0,0 +1,163 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const defaultDryRunSampleSize = 10
+
+type EventFilterDryRunRequest struct {
+	Filters    []fftypes.JSONAny `json:"filters"`              // the filters of the listener, as for EventListenerAdd
+	FromBlock  *fftypes.FFBigInt `json:"fromBlock,omitempty"`  // defaults to the maximum range back from the toBlock
+	ToBlock    *fftypes.FFBigInt `json:"toBlock,omitempty"`    // defaults to the head of the chain
+	SampleSize int               `json:"sampleSize,omitempty"` // the number of matching events to return
+}
+
+type EventFilterDryRunResponse struct {
+	FromBlock    int64           `json:"fromBlock"`
+	ToBlock      int64           `json:"toBlock"`
+	Count        int64           `json:"count"`        // the events that matched any of the filters
+	FilterCounts []int64         `json:"filterCounts"` // the events matched by each filter, in the order of the request
+	Undecoded    int64           `json:"undecoded"`    // matching events whose data could not be decoded with the ABI of the filter
+	Samples      []*ffcapi.Event `json:"samples"`      // the earliest matching events, up to the sample size
+}
+
+// EventFilterDryRun queries the historical events in a block range that match a set of listener filters, without
+// creating a listener. This validates the event ABIs, addresses and topics of the filters, before committing to a
+// long catchup. The range is queried in pages of catchupPageSize blocks, and is limited to dryRunMaxBlocks.
+func (c *ethConnector) EventFilterDryRun(ctx context.Context, req *EventFilterDryRunRequest) (*EventFilterDryRunResponse, ffcapi.ErrorReason, error) {
+	_, filters, err := parseEventFilters(ctx, req.Filters)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+
+	var toBlock int64
+	if req.ToBlock != nil {
+		toBlock = req.ToBlock.Int64()
+	} else {
+		head, ok := c.blockListener.getHighestBlock(ctx)
+		if !ok {
+			return nil, "", i18n.NewError(ctx, msgs.MsgTimedOutQueryingChainHead)
+		}
+		toBlock = head
+	}
+	fromBlock := toBlock - c.dryRunMaxBlocks + 1
+	if req.FromBlock != nil {
+		fromBlock = req.FromBlock.Int64()
+	} else if fromBlock < 0 {
+		fromBlock = 0
+	}
+	if fromBlock < 0 || fromBlock > toBlock {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidBlockRange, fromBlock, toBlock)
+	}
+	if blocks := toBlock - fromBlock + 1; blocks > c.dryRunMaxBlocks {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgBlockRangeTooLarge, blocks, c.dryRunMaxBlocks, EventsDryRunMaxBlocks)
+	}
+	sampleSize := req.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = defaultDryRunSampleSize
+	}
+
+	logFilter := dryRunLogFilter(filters)
+	ee := &eventEnricher{
+		connector:         c,
+		serializer:        c.serializer,
+		checksumAddresses: c.checksumAddresses,
+	}
+	res := &EventFilterDryRunResponse{
+		FromBlock:    fromBlock,
+		ToBlock:      toBlock,
+		FilterCounts: make([]int64, len(filters)),
+		Samples:      []*ffcapi.Event{},
+	}
+	matchLog := func(ethLog *logJSONRPC) error {
+		// As for receipts, the filter that both matches and decodes the event is the best match
+		bestMatch, bestFilter, bestDecoded := (*ffcapi.Event)(nil), -1, false
+		for i, f := range filters {
+			event, matched, decoded, err := ee.filterEnrichEthLog(ctx, f, nil, ethLog)
+			if err != nil {
+				return err
+			}
+			if matched && (decoded || bestMatch == nil) {
+				bestMatch, bestFilter, bestDecoded = event, i, decoded
+			}
+		}
+		if bestMatch == nil {
+			return nil
+		}
+		res.Count++
+		res.FilterCounts[bestFilter]++
+		if !bestDecoded {
+			res.Undecoded++
+		}
+		if len(res.Samples) < sampleSize {
+			res.Samples = append(res.Samples, bestMatch)
+		}
+		return nil
+	}
+	pageSize := c.catchupPageSize.Load()
+	for pageStart := fromBlock; pageStart <= toBlock; pageStart += pageSize {
+		pageEnd := pageStart + pageSize - 1
+		if pageEnd > toBlock {
+			pageEnd = toBlock
+		}
+		logFilter.FromBlock = ethtypes.NewHexInteger64(pageStart)
+		logFilter.ToBlock = ethtypes.NewHexInteger64(pageEnd)
+		if err := c.getLogs(ctx, logFilter, matchLog); err != nil {
+			return nil, "", err
+		}
+	}
+	log.L(ctx).Infof("Filter dry run fromBlock=%d toBlock=%d matched %d events (undecoded=%d)", fromBlock, toBlock, res.Count, res.Undecoded)
+	return res, "", nil
+}
+
+// dryRunLogFilter builds a single eth_getLogs query for all the filters, with the topics and addresses
+// of each filter then checked on the logs returned, as the query matches the logs of any of them
+func dryRunLogFilter(filters []*eventFilter) *logFilterJSONRPC {
+	logFilter := &logFilterJSONRPC{}
+	signatures := make([]ethtypes.HexBytes0xPrefix, 0, len(filters))
+	uniqueSignatures := make(map[string]bool)
+	uniqueAddresses := make(map[ethtypes.Address0xHex]bool)
+	allAddressed := true
+	for _, f := range filters {
+		if !uniqueSignatures[f.Topic0.String()] {
+			uniqueSignatures[f.Topic0.String()] = true
+			signatures = append(signatures, f.Topic0)
+		}
+		switch {
+		case f.Address == nil:
+			allAddressed = false
+		case !uniqueAddresses[*f.Address]:
+			uniqueAddresses[*f.Address] = true
+			logFilter.Address = append(logFilter.Address, f.Address)
+		}
+	}
+	logFilter.Topics = logFilterTopics(signatures, filters)
+	if !allAddressed {
+		logFilter.Address = nil
+	}
+	return logFilter
+}
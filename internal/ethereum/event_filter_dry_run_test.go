@@ -0,0 +1,178 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// A Transfer event with no indexed parameters has the same topic0 as the ERC-20 event, but cannot decode it
+const abiTransferEventUnindexed = `{
+	"anonymous": false,
+	"inputs": [
+		{"name": "from", "type": "address"},
+		{"name": "to", "type": "address"},
+		{"name": "value", "type": "uint256"}
+	],
+	"name": "Transfer",
+	"type": "event"
+}`
+
+func withDryRunPaging(conf config.Section) {
+	conf.Set(EventsCatchupPageSize, 100)
+	conf.Set(EventsDryRunMaxBlocks, 250)
+}
+
+func TestEventFilterDryRun(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withDryRunPaging)
+	defer done()
+
+	mockRelayChainHead(mRPC, 1100)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1024),
+		}
+	})
+	pages := make([][2]int64, 0)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		filter := args[3].(*logFilterJSONRPC)
+		assert.Len(t, filter.Topics, 1)
+		assert.Len(t, filter.Topics[0], 1) // both filters share the Transfer signature
+		assert.Nil(t, filter.Address)      // as the second filter is for any address
+		from, to := filter.FromBlock.BigInt().Int64(), filter.ToBlock.BigInt().Int64()
+		pages = append(pages, [2]int64{from, to})
+		if from <= 1024 && to >= 1024 {
+			otherAddress := sampleTransferLog()
+			otherAddress.Address = ethtypes.MustNewAddress(testTreasury)
+			otherAddress.LogIndex = ethtypes.NewHexInteger64(3)
+			*args[1].(*[]*logJSONRPC) = []*logJSONRPC{sampleTransferLog(), sampleTransferLog(), otherAddress}
+		}
+	})
+
+	res, _, err := c.EventFilterDryRun(ctx, &EventFilterDryRunRequest{
+		Filters: []fftypes.JSONAny{
+			*fftypes.JSONAnyPtr(`{"address":"0x20355f3E852D4b6a9944AdA8d5399dDD3409A431","event":` + abiTransferEvent + `}`),
+			*fftypes.JSONAnyPtr(`{"event":` + abiTransferEventUnindexed + `}`),
+		},
+		SampleSize: 2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]int64{{851, 950}, {951, 1050}, {1051, 1100}}, pages)
+	assert.Equal(t, int64(851), res.FromBlock)
+	assert.Equal(t, int64(1100), res.ToBlock)
+	assert.Equal(t, int64(3), res.Count)
+	assert.Equal(t, []int64{2, 1}, res.FilterCounts)
+	assert.Equal(t, int64(1), res.Undecoded)
+	assert.Len(t, res.Samples, 2)
+	assert.Equal(t, "1000", res.Samples[0].Data.JSONObject().GetString("value"))
+
+}
+
+func TestEventFilterDryRunAddresses(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withDryRunPaging)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(filter *logFilterJSONRPC) bool {
+		return len(filter.Address) == 2 && filter.FromBlock.BigInt().Int64() == 0 && filter.ToBlock.BigInt().Int64() == 9
+	})).Return(nil)
+
+	res, _, err := c.EventFilterDryRun(ctx, &EventFilterDryRunRequest{
+		Filters: []fftypes.JSONAny{
+			*fftypes.JSONAnyPtr(`{"address":"` + testTreasury + `","event":` + abiTransferEvent + `}`),
+			*fftypes.JSONAnyPtr(`{"address":"` + testPayee + `","event":` + abiTransferEvent + `}`),
+			*fftypes.JSONAnyPtr(`{"address":"` + testPayee + `","event":` + abiTransferEvent + `}`),
+		},
+		ToBlock: fftypes.NewFFBigInt(9),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), res.FromBlock)
+	assert.Zero(t, res.Count)
+	assert.Empty(t, res.Samples)
+
+}
+
+func TestEventFilterDryRunBadInputs(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t, withDryRunPaging)
+	defer done()
+
+	filters := []fftypes.JSONAny{*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`)}
+
+	_, reason, err := c.EventFilterDryRun(ctx, &EventFilterDryRunRequest{})
+	assert.Regexp(t, "FF23035", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = c.EventFilterDryRun(ctx, &EventFilterDryRunRequest{
+		Filters:   filters,
+		FromBlock: fftypes.NewFFBigInt(100),
+		ToBlock:   fftypes.NewFFBigInt(99),
+	})
+	assert.Regexp(t, "FF23189", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = c.EventFilterDryRun(ctx, &EventFilterDryRunRequest{
+		Filters:   filters,
+		FromBlock: fftypes.NewFFBigInt(0),
+		ToBlock:   fftypes.NewFFBigInt(250),
+	})
+	assert.Regexp(t, "FF23190.*251.*250", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestEventFilterDryRunQueryFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withDryRunPaging)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(&rpcbackend.RPCError{Message: "pop"})
+
+	_, _, err := c.EventFilterDryRun(ctx, &EventFilterDryRunRequest{
+		Filters: []fftypes.JSONAny{*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`)},
+		ToBlock: fftypes.NewFFBigInt(10),
+	})
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestEventFilterDryRunEnrichFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withDryRunPaging)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{sampleTransferLog()}
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(&rpcbackend.RPCError{Message: "pop"})
+
+	_, _, err := c.EventFilterDryRun(ctx, &EventFilterDryRunRequest{
+		Filters: []fftypes.JSONAny{*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`)},
+		ToBlock: fftypes.NewFFBigInt(1024),
+	})
+	assert.Regexp(t, "pop", err)
+
+}
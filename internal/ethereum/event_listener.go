@@ -20,7 +20,10 @@ import (
 	"context"
 	"encoding/json"
 	"math/big"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
@@ -29,19 +32,32 @@ import (
 	"github.com/hyperledger/firefly-signer/pkg/abi"
 	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"golang.org/x/time/rate"
 )
 
 // listenerCheckpoint is our Ethereum specific custom options that can be specified when creating a listener
 type listenerOptions struct {
-	Methods []*abi.Entry `json:"methods,omitempty"` // An optional array of ABI methods. If specified and the input data for a transaction matches, the decoded inputs will be included in the event
-	Signer  bool         `json:"signer,omitempty"`  // An optional boolean for whether to extract the signer of the transaction that emitted the event
+	Methods           []*abi.Entry `json:"methods,omitempty"`           // An optional array of ABI methods. If specified and the input data for a transaction matches, the decoded inputs will be included in the event
+	Signer            bool         `json:"signer,omitempty"`            // An optional boolean for whether to extract the signer of the transaction that emitted the event
+	IntFormat         string       `json:"intFormat,omitempty"`         // How integers are formatted in the decoded data - "decimal" (default), "hex" or "scaled"
+	Decimals          *int         `json:"decimals,omitempty"`          // The number of decimals for the "scaled" intFormat, such as the decimals of an ERC-20 token
+	ChecksumAddresses *bool        `json:"checksumAddresses,omitempty"` // Overrides the connector checksumAddresses setting for this listener
+	TokenTransfers    bool         `json:"tokenTransfers,omitempty"`    // An optional boolean to add normalized token information to ERC-20/721/1155 Transfer and Approval events
+	SchemaVersion     string       `json:"schemaVersion,omitempty"`     // Overrides the connector events.schemaVersion setting for this listener
+
+	Addresses []*ethtypes.Address0xHex `json:"addresses,omitempty"` // An optional set of contract addresses for filters without an address, that can be updated while the listener is running
+	Factory   *factoryOptions          `json:"factory,omitempty"`   // An optional factory contract, whose child contracts are added to the addresses as they are created
+	Exclude   *exclusionOptions        `json:"exclude,omitempty"`   // Optional addresses and topic values, whose events are dropped by the connector
+	Fields    *fieldOptions            `json:"fields,omitempty"`    // Optional selection of the decoded fields and metadata delivered in each event
+	Bootstrap *bootstrapOptions        `json:"bootstrap,omitempty"` // Optional export of historic events to seed a new listener with, before polling the chain
 }
 
 // listenerCheckpoint is our Ethereum specific checkpoint structure
 type listenerCheckpoint struct {
-	Block            int64 `json:"block"`
-	TransactionIndex int64 `json:"transactionIndex"`
-	LogIndex         int64 `json:"logIndex"`
+	Block            int64                    `json:"block"`
+	TransactionIndex int64                    `json:"transactionIndex"`
+	LogIndex         int64                    `json:"logIndex"`
+	Addresses        []*ethtypes.Address0xHex `json:"addresses,omitempty"` // The child contracts discovered by a factory listener
 }
 
 // listenerConfig is the configuration parsed from generic FFCAPI connector framework JSON, into our Ethereum specific options
@@ -55,25 +71,38 @@ type listenerConfig struct {
 
 // listener is the state we hold in memory for each individual listener that has been added
 type listener struct {
-	id              *fftypes.UUID
-	c               *ethConnector
-	es              *eventStream
-	ee              *eventEnricher
-	hwmMux          sync.Mutex // Protects checkpoint of an individual listener. May hold ES lock when taking this, must NOT attempt to obtain ES lock while holding this
-	hwmBlock        int64
-	config          listenerConfig
-	removed         bool
-	catchup         bool
-	catchupLoopDone chan struct{}
+	id                *fftypes.UUID
+	c                 *ethConnector
+	es                *eventStream
+	ee                *eventEnricher
+	hwmMux            sync.Mutex // Protects checkpoint of an individual listener. May hold ES lock when taking this, must NOT attempt to obtain ES lock while holding this
+	hwmBlock          int64
+	config            listenerConfig
+	removed           bool
+	catchup           bool
+	catchupLoopDone   chan struct{}
+	parked            chan struct{}                     // non-nil while paused, and closed once the loop running the listener has stopped
+	addresses         atomic.Pointer[listenerAddresses] // nil unless the listener was created with the addresses or factory option
+	factory           *factoryFilter                    // nil unless the listener was created with the factory option
+	exclusions        *listenerExclusions               // nil unless the listener was created with the exclude option
+	wildcardLimiter   *rate.Limiter                     // nil unless the listener matches events from all contracts, and the rate is capped
+	bootstrap         *bootstrapExport                  // nil unless the listener is new with the bootstrap option, until its events are delivered
+	lastDelivered     *listenerCheckpoint               // position of the latest event delivered, protected by hwmMux
+	continuityRescans int                               // consecutive re-scans for events behind lastDelivered, protected by hwmMux
+	delivered         map[string]int64                  // events delivered at or above the HWM, to the block they are in, protected by hwmMux
 }
 
 type logFilterJSONRPC struct {
 	FromBlock *ethtypes.HexInteger          `json:"fromBlock,omitempty"`
 	ToBlock   *ethtypes.HexInteger          `json:"toBlock,omitempty"`
-	Address   *ethtypes.Address0xHex        `json:"address,omitempty"`
+	BlockHash ethtypes.HexBytes0xPrefix     `json:"blockHash,omitempty"` // instead of fromBlock and toBlock, to query a single block
+	Address   logFilterAddresses            `json:"address,omitempty"`
 	Topics    [][]ethtypes.HexBytes0xPrefix `json:"topics,omitempty"`
 }
 
+// logFilterAddresses serializes as a single address when there is only one, and an array otherwise
+type logFilterAddresses []*ethtypes.Address0xHex
+
 type logJSONRPC struct {
 	Removed          bool                        `json:"removed"`
 	LogIndex         *ethtypes.HexInteger        `json:"logIndex"`
@@ -118,9 +147,52 @@ func parseListenerOptions(ctx context.Context, o *fftypes.JSONAny) (*listenerOpt
 			return nil, i18n.NewError(ctx, msgs.MsgInvalidListenerOptions, err)
 		}
 	}
+	switch options.IntFormat {
+	case "", intFormatDecimal, intFormatHex:
+	case intFormatScaled:
+		if options.Decimals == nil || *options.Decimals < 0 || *options.Decimals > maxScaledDecimals {
+			return nil, i18n.NewError(ctx, msgs.MsgInvalidScaledDecimals, maxScaledDecimals)
+		}
+	default:
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidIntFormat, options.IntFormat, strings.Join([]string{intFormatDecimal, intFormatHex, intFormatScaled}, ","))
+	}
+	if options.SchemaVersion != "" {
+		if err := validateEventSchema(ctx, options.SchemaVersion); err != nil {
+			return nil, err
+		}
+	}
+	if options.Factory != nil {
+		if _, err := newFactoryFilter(ctx, options.Factory); err != nil {
+			return nil, err
+		}
+	}
+	if options.Exclude != nil {
+		if _, err := newListenerExclusions(ctx, options.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	if options.Fields != nil {
+		if _, err := newFieldProjection(ctx, options.Fields); err != nil {
+			return nil, err
+		}
+	}
 	return &options, nil
 }
 
+// isWildcardListener returns true if any of the filters match the event from all contracts on the chain,
+// as the listener has no address and no addresses or factory option to restrict it
+func isWildcardListener(filters []*eventFilter, options *listenerOptions) bool {
+	if options.Factory != nil || len(options.Addresses) > 0 {
+		return false
+	}
+	for _, f := range filters {
+		if f.Address == nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (l *listener) ensureHWM(ctx context.Context) error {
 	l.hwmMux.Lock()
 	defer l.hwmMux.Unlock()
@@ -161,6 +233,7 @@ func (l *listener) getHWMCheckpoint() *listenerCheckpoint {
 		Block:            l.hwmBlock,
 		TransactionIndex: -1,
 		LogIndex:         -1,
+		Addresses:        l.factoryCheckpointAddresses(),
 	}
 }
 
@@ -172,47 +245,100 @@ func (l *listener) moveHWM(hwmBlock int64) {
 	}
 }
 
-// listenerCatchupLoop reads pages of blocks at a time, until it gets within the configured catchup-threshold
-// of the head of the blockchain.
-// Then it moves this listener into the head-set of listeners, which share a common filter, listening
+func (a logFilterAddresses) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]*ethtypes.Address0xHex(a))
+}
+
+// listenerCatchupLoop reads pages of blocks at a time for a group of listeners that are all at the same
+// high water mark, until they get within the configured catchup-threshold of the head of the blockchain.
+// Then it moves each listener into the head-set of listeners, which share a common filter, listening
 // for new events to arrive at the head of the chain.
-func (l *listener) listenerCatchupLoop() {
-	defer close(l.catchupLoopDone)
+func (es *eventStream) listenerCatchupLoop(listeners []*listener, catchupLoopDone chan struct{}) {
+	defer close(catchupLoopDone)
+
+	ctx := es.ctx
+	if len(listeners) == 1 {
+		ctx = log.WithLogField(ctx, "listener", listeners[0].id.String())
+	} else {
+		ctx = log.WithLogField(ctx, "listeners", strconv.Itoa(len(listeners)))
+	}
 
-	// Only filtering on a single listener
-	ctx := log.WithLogField(l.es.ctx, "listener", l.id.String())
-	al := l.es.buildAggregatedListener([]*listener{l})
+	// A listener with a bootstrap export is always started in a group of its own
+	if len(listeners) == 1 && listeners[0].bootstrap != nil {
+		if es.bootstrapListener(ctx, listeners[0]) {
+			log.L(ctx).Infof("Listener catchup loop exiting as stream is stopping")
+			return
+		}
+	}
 
 	failCount := 0
 	for {
-		if l.c.doFailureDelay(ctx, failCount) {
+		if es.c.doFailureDelay(ctx, failCount) {
 			log.L(ctx).Debugf("Listener catchup loop loop exiting")
 			return
 		}
 
-		readyForLead, removed := l.checkReadyForLeadPackOrRemoved(ctx)
-		if removed {
-			log.L(ctx).Infof("Listener removed during catchup")
-			return
+		remaining := make([]*listener, 0, len(listeners))
+		for _, l := range listeners {
+			if es.parkIfPaused(l) {
+				// Started again when it is resumed
+				log.L(ctx).Infof("Listener %s paused during catchup", l.id)
+				continue
+			}
+			readyForLead, removed := l.checkReadyForLeadPackOrRemoved(ctx)
+			switch {
+			case removed:
+				log.L(ctx).Infof("Listener %s removed during catchup", l.id)
+			case readyForLead:
+				// We're done with catchup for this listener - it can join the main group
+				es.rejoinLeadGroup(l)
+				log.L(ctx).Infof("Listener %s completed catchup, and rejoined lead group", l.id)
+			default:
+				remaining = append(remaining, l)
+			}
 		}
-		if readyForLead {
-			// We're done with catchup for this listener - it can join the main group
-			l.es.rejoinLeadGroup(l)
-			log.L(ctx).Infof("Listener completed catchup, and rejoined lead group")
+		listeners = remaining
+		if len(listeners) == 0 {
 			return
 		}
 
-		fromBlock := l.hwmBlock
-		toBlock := l.hwmBlock + l.c.catchupPageSize - 1
-		events, err := l.es.getBlockRangeEvents(ctx, al, fromBlock, toBlock)
+		// All the listeners in the group share a high water mark, so we can query them all with one filter.
+		// They can differ once they resume from their persisted checkpoints on election, in which case
+		// we query from the lowest and events below the high water mark of each listener are skipped.
+		al := es.buildAggregatedListener(listeners)
+		term := es.syncedTerm()
+		fromBlock := int64(-1)
+		for _, l := range listeners {
+			l.hwmMux.Lock()
+			if fromBlock < 0 || l.hwmBlock < fromBlock {
+				fromBlock = l.hwmBlock
+			}
+			l.hwmMux.Unlock()
+		}
+		if es.c.logIndex != nil && al.addressSet != nil && !al.factories {
+			if skipTo := es.c.logIndex.skipEmpty(al, fromBlock); skipTo > fromBlock {
+				log.L(ctx).Infof("Listener catchup skipped fromBlock=%d toBlock=%d with no events in log index listeners=%d", fromBlock, skipTo-1, len(listeners))
+				for _, l := range listeners {
+					l.moveHWM(skipTo)
+				}
+				continue
+			}
+		}
+		toBlock := fromBlock + es.c.catchupPageSize.Load() - 1
+		pollCtx, span := es.c.startSpan(ctx, "ListenerCatchup", attrFromBlock.Int64(fromBlock), attrToBlock.Int64(toBlock))
+		events, err := es.getCatchupBlockRangeEvents(pollCtx, al, fromBlock, toBlock)
+		span.SetAttributes(attrEventCount.Int(len(events)))
+		endSpan(span, err)
 		if err != nil {
-			if l.c.catchupDownscaleRegex.String() != "" && l.c.catchupDownscaleRegex.MatchString(err.Error()) {
+			if es.c.catchupDownscaleRegex.String() != "" && es.c.catchupDownscaleRegex.MatchString(err.Error()) {
 				log.L(ctx).Warnf("Failed to query block range fromBlock=%d toBlock=%d. Error %s matches configured downscale regex, catchup page size will automatically be reduced", fromBlock, toBlock, err.Error())
-				if l.c.catchupPageSize > 1 {
-					l.c.catchupPageSize /= 2
-
-					if l.c.catchupPageSize < 20 {
-						log.L(ctx).Warnf("Catchup page size auto-reduced to extremely low value %d. The connector may never catch up with the head of the chain.", l.c.catchupPageSize)
+				// Other catchup groups might reduce the page size in parallel, so we halve the size we queried with
+				if pageSize := toBlock - fromBlock + 1; pageSize > 1 && es.c.catchupPageSize.CompareAndSwap(pageSize, pageSize/2) {
+					if pageSize/2 < 20 {
+						log.L(ctx).Warnf("Catchup page size auto-reduced to extremely low value %d. The connector may never catch up with the head of the chain.", pageSize/2)
 					}
 				}
 			} else {
@@ -221,20 +347,51 @@ func (l *listener) listenerCatchupLoop() {
 			}
 			continue
 		}
-		log.L(ctx).Infof("Listener catchup fromBlock=%d toBlock=%d events=%d", fromBlock, toBlock, len(events))
+		if err := es.limitWildcardEvents(ctx, al, events); err != nil {
+			log.L(ctx).Infof("Listener catchup loop exiting as stream is stopping")
+			return
+		}
+		log.L(ctx).Infof("Listener catchup fromBlock=%d toBlock=%d events=%d listeners=%d", fromBlock, toBlock, len(events), len(listeners))
 
-		for _, event := range events {
+		exiting, stale := es.waitLeader(term, len(events) > 0)
+		if exiting {
+			log.L(ctx).Infof("Listener catchup loop exiting as stream is stopping")
+			return
+		}
+		if stale {
+			// Query again from the persisted checkpoints
+			continue
+		}
+		events = es.dropDelivered(al, events)
+		batch, rescan, err := es.filterBatch(listeners, events)
+		if err != nil {
+			log.L(ctx).Errorf("Failed to record events fromBlock=%d toBlock=%d in write-ahead log: %s", fromBlock, toBlock, err)
+			failCount++
+			continue
+		}
+		for _, event := range batch {
 			log.L(ctx).Debugf("Detected event %s (listener catchup)", event.Event)
 			select {
-			case l.es.events <- event:
-			case <-l.es.ctx.Done():
+			case es.events <- event:
+				es.wal.markDispatched(event)
+			case <-es.ctx.Done():
 				log.L(ctx).Infof("Listener catchup loop exiting as stream is stopping")
 				return
 			}
 		}
-		l.hwmMux.Lock()
-		l.hwmBlock = toBlock + 1
-		l.hwmMux.Unlock()
+		if rescan {
+			// Query the same range again, from the unchanged checkpoint
+			continue
+		}
+		for _, l := range listeners {
+			l.hwmMux.Lock()
+			l.hwmBlock = toBlock + 1
+			l.hwmMux.Unlock()
+		}
+		if es.c.shutdown.isDraining() {
+			log.L(ctx).Infof("Listener catchup loop exiting for shutdown")
+			return
+		}
 		failCount = 0 // Reset on success
 	}
 }
@@ -250,10 +407,23 @@ func (l *listener) filterEnrichEthLog(ctx context.Context, f *eventFilter, metho
 		return nil, false, nil
 	}
 
+	if !l.matchesAddress(f, ethLog) {
+		log.L(ctx).Debugf("Listener %s skipping event '%s' from address %s not in the listener addresses", l.id, getEventProtoID(blockNumber, transactionIndex, logIndex), ethLog.Address)
+		return nil, false, nil
+	}
+
+	if l.exclusions != nil && l.exclusions.excludes(ethLog) {
+		log.L(ctx).Debugf("Listener %s skipping excluded event '%s' from address %s", l.id, getEventProtoID(blockNumber, transactionIndex, logIndex), ethLog.Address)
+		return nil, false, nil
+	}
+
 	e, matched, _, err := l.ee.filterEnrichEthLog(ctx, f, methods, ethLog)
 	if !matched || err != nil {
 		return nil, false, err
 	}
+	if l.factory != nil && f == l.factory.eventFilter && !ethLog.Removed {
+		l.addFactoryChild(ctx, ethLog)
+	}
 
 	e.ID.ListenerID = l.id
 	return &ffcapi.ListenerEvent{
@@ -261,6 +431,7 @@ func (l *listener) filterEnrichEthLog(ctx context.Context, f *eventFilter, metho
 			Block:            blockNumber,
 			TransactionIndex: transactionIndex,
 			LogIndex:         logIndex,
+			Addresses:        l.factoryCheckpointAddresses(),
 		},
 		Event: e,
 	}, true, nil
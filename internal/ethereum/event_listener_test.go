@@ -18,11 +18,13 @@ package ethereum
 
 import (
 	"encoding/json"
+	"math/big"
 	"regexp"
 	"strconv"
 	"testing"
 	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
 	"github.com/hyperledger/firefly-signer/pkg/abi"
@@ -32,6 +34,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
 )
 
 func TestListenerCheckpointLessThan(t *testing.T) {
@@ -183,7 +186,34 @@ func TestListenerCatchupErrorsThenDeliveryExit(t *testing.T) {
 		cancelCtx()
 	})
 
-	l.listenerCatchupLoop()
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
+
+}
+
+func TestListenerCatchupSuppressesDuplicates(t *testing.T) {
+
+	l, mRPC, cancelCtx := newTestListener(t, false)
+	l.es.dedupeCache, _ = lru.New(10)
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	l.es.events = events
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1001),
+		}
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		// The node returns the same log twice
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{sampleTransferLog(), sampleTransferLog()}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(&rpcbackend.RPCError{Message: "pop"}).Run(func(args mock.Arguments) {
+		cancelCtx()
+	})
+
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(1), l.es.duplicates.Load())
 
 }
 
@@ -208,10 +238,10 @@ func TestListenerCatchupScalesBackOnExpectedError(t *testing.T) {
 		cancelCtx()
 	})
 
-	l.listenerCatchupLoop()
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
 
 	// The response size error from an JSON/RPC endpoint should cause us to scale back the catchup page size
-	assert.Equal(t, int64(250), l.c.catchupPageSize)
+	assert.Equal(t, int64(250), l.c.catchupPageSize.Load())
 }
 
 func TestListenerCatchupScalesBackNTimesOnExpectedError(t *testing.T) {
@@ -235,10 +265,10 @@ func TestListenerCatchupScalesBackNTimesOnExpectedError(t *testing.T) {
 		cancelCtx()
 	})
 
-	l.listenerCatchupLoop()
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
 
 	// The response size error from an JSON/RPC endpoint should cause us to scale back the catchup page size
-	assert.Equal(t, int64(15), l.c.catchupPageSize)
+	assert.Equal(t, int64(15), l.c.catchupPageSize.Load())
 }
 
 func TestListenerCatchupScalesBackToOne(t *testing.T) {
@@ -262,10 +292,10 @@ func TestListenerCatchupScalesBackToOne(t *testing.T) {
 		cancelCtx()
 	})
 
-	l.listenerCatchupLoop()
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
 
 	// The response size error from an JSON/RPC endpoint should cause us to scale back the catchup page size
-	assert.Equal(t, int64(1), l.c.catchupPageSize)
+	assert.Equal(t, int64(1), l.c.catchupPageSize.Load())
 }
 
 func TestListenerNoCatchupScaleBackOnErrorMismatch(t *testing.T) {
@@ -289,10 +319,10 @@ func TestListenerNoCatchupScaleBackOnErrorMismatch(t *testing.T) {
 		cancelCtx()
 	})
 
-	l.listenerCatchupLoop()
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
 
 	// The response size error doesn't match what we expect, catchup page size remains 500
-	assert.Equal(t, int64(500), l.c.catchupPageSize)
+	assert.Equal(t, int64(500), l.c.catchupPageSize.Load())
 }
 
 func TestListenerCatchupScalesBackCustomRegex(t *testing.T) {
@@ -320,10 +350,10 @@ func TestListenerCatchupScalesBackCustomRegex(t *testing.T) {
 		cancelCtx()
 	})
 
-	l.listenerCatchupLoop()
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
 
 	// The response size error from an JSON/RPC endpoint should cause us to scale back the catchup page size
-	assert.Equal(t, int64(15), l.c.catchupPageSize)
+	assert.Equal(t, int64(15), l.c.catchupPageSize.Load())
 }
 
 func TestListenerCatchupNoScaleBackEmptyRegex(t *testing.T) {
@@ -351,10 +381,10 @@ func TestListenerCatchupNoScaleBackEmptyRegex(t *testing.T) {
 		cancelCtx()
 	})
 
-	l.listenerCatchupLoop()
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
 
 	// The response size error from an JSON/RPC endpoint should cause us to scale back the catchup page size
-	assert.Equal(t, int64(500), l.c.catchupPageSize)
+	assert.Equal(t, int64(500), l.c.catchupPageSize.Load())
 }
 
 func TestListenerCatchupErrorThenExit(t *testing.T) {
@@ -368,7 +398,7 @@ func TestListenerCatchupErrorThenExit(t *testing.T) {
 		cancelCtx()
 	})
 
-	l.listenerCatchupLoop()
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
 
 }
 
@@ -380,7 +410,7 @@ func TestListenerCatchupRemoved(t *testing.T) {
 	l.hwmBlock = 0
 	l.removed = true
 
-	l.listenerCatchupLoop()
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
 
 }
 
@@ -412,6 +442,70 @@ func TestSerializeEventDataFail(t *testing.T) {
 
 }
 
+func TestDecodeLogDataIntFormats(t *testing.T) {
+
+	l, _, _ := newTestListener(t, false)
+
+	var abiEvent *abi.Entry
+	err := json.Unmarshal([]byte(abiTransferEvent), &abiEvent)
+	assert.NoError(t, err)
+	ethLog := sampleTransferLog()
+	decimals := 2
+
+	for _, tc := range []struct {
+		options  listenerOptions
+		expected string
+	}{
+		{options: listenerOptions{}, expected: `"value":"1000"`},
+		{options: listenerOptions{IntFormat: "decimal"}, expected: `"value":"1000"`},
+		{options: listenerOptions{IntFormat: "hex"}, expected: `"value":"0x3e8"`},
+		{options: listenerOptions{IntFormat: "scaled", Decimals: &decimals}, expected: `"value":"10"`},
+	} {
+		l.ee.serializer = l.c.serializerForOptions(&tc.options)
+		res, decoded := l.ee.decodeLogData(l.es.ctx, abiEvent, ethLog.Topics, ethLog.Data)
+		assert.True(t, decoded)
+		assert.Contains(t, res.String(), tc.expected)
+	}
+
+}
+
+func TestScaledDecimalIntSerializer(t *testing.T) {
+
+	for _, tc := range []struct {
+		value    int64
+		decimals int
+		expected string
+	}{
+		{value: 1500000000000000000, decimals: 18, expected: "1.5"},
+		{value: 1000000000000000000, decimals: 18, expected: "1"},
+		{value: 1, decimals: 18, expected: "0.000000000000000001"},
+		{value: 0, decimals: 18, expected: "0"},
+		{value: -12345, decimals: 2, expected: "-123.45"},
+		{value: -5, decimals: 3, expected: "-0.005"},
+		{value: 12345, decimals: 0, expected: "12345"},
+	} {
+		assert.Equal(t, tc.expected, scaledDecimalIntSerializer(tc.decimals)(big.NewInt(tc.value)))
+	}
+
+}
+
+func TestParseListenerOptionsIntFormat(t *testing.T) {
+
+	options, err := parseListenerOptions(context.Background(), fftypes.JSONAnyPtr(`{"intFormat":"scaled","decimals":18}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 18, *options.Decimals)
+
+	_, err = parseListenerOptions(context.Background(), fftypes.JSONAnyPtr(`{"intFormat":"octal"}`))
+	assert.Regexp(t, "FF23076.*decimal,hex,scaled", err)
+
+	_, err = parseListenerOptions(context.Background(), fftypes.JSONAnyPtr(`{"intFormat":"scaled"}`))
+	assert.Regexp(t, "FF23077", err)
+
+	_, err = parseListenerOptions(context.Background(), fftypes.JSONAnyPtr(`{"intFormat":"scaled","decimals":78}`))
+	assert.Regexp(t, "FF23077", err)
+
+}
+
 func TestFilterEnrichEthLogBlockBelowHWM(t *testing.T) {
 
 	l, _, _ := newTestListener(t, true)
@@ -655,3 +749,198 @@ func TestFilterEnrichEthLogMethodBadInputABIData(t *testing.T) {
 	assert.Nil(t, ei.InputArgs)
 
 }
+
+func TestListenerCatchupGroupSharesQuery(t *testing.T) {
+
+	l1req := &ffcapi.EventListenerAddRequest{
+		ListenerID: fftypes.NewUUID(),
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{
+				*fftypes.JSONAnyPtr(`{"address":"0x20355f3E852D4b6a9944AdA8d5399dDD3409A431","event":` + abiTransferEvent + `}`),
+			},
+			Options:   fftypes.JSONAnyPtr(`{}`),
+			FromBlock: strconv.Itoa(testHighBlock),
+		},
+	}
+	l2req := &ffcapi.EventListenerAddRequest{
+		ListenerID: fftypes.NewUUID(),
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{
+				*fftypes.JSONAnyPtr(`{"address":"0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4","event":` + abiTransferEvent + `}`),
+			},
+			Options:   fftypes.JSONAnyPtr(`{}`),
+			FromBlock: strconv.Itoa(testHighBlock),
+		},
+	}
+	es, _, mRPC, done := testEventStream(t, l1req, l2req)
+	done() // stop it so we can safely call the catchup loop directly
+	esCtxReplaced, cancelCtx := context.WithCancel(context.Background())
+	es.ctx = esCtxReplaced
+
+	l1 := es.listeners[*l1req.ListenerID]
+	l2 := es.listeners[*l2req.ListenerID]
+	l1.hwmBlock = 0
+	l2.hwmBlock = 0
+	catchupLoopDone := make(chan struct{})
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		b, _ := json.Marshal(f.Address)
+		return f.FromBlock.BigInt().Int64() == 0 &&
+			string(b) == `["0x20355f3e852d4b6a9944ada8d5399ddd3409a431","0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4"]`
+	})).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		return f.FromBlock.BigInt().Int64() == 0
+	})).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		return f.FromBlock.BigInt().Int64() == es.c.catchupPageSize.Load()
+	})).Return(&rpcbackend.RPCError{Message: "pop"}).Run(func(args mock.Arguments) {
+		cancelCtx()
+	})
+
+	es.listenerCatchupLoop([]*listener{l1, l2}, catchupLoopDone)
+	<-catchupLoopDone
+
+	assert.Equal(t, es.c.catchupPageSize.Load(), l1.hwmBlock)
+	assert.Equal(t, es.c.catchupPageSize.Load(), l2.hwmBlock)
+}
+
+func TestListenerCatchupWaitForSlotCancelled(t *testing.T) {
+
+	l, _, cancelCtx := newTestListener(t, false)
+
+	// Fill all the slots, so the catchup loop cannot query
+	for i := 0; i < cap(l.c.catchupSlots); i++ {
+		l.c.catchupSlots <- struct{}{}
+	}
+	cancelCtx()
+
+	_, err := l.es.getCatchupBlockRangeEvents(l.es.ctx, l.es.buildAggregatedListener([]*listener{l}), 0, 100)
+	assert.Regexp(t, "FF00154", err)
+
+}
+
+func TestLogFilterAddressesMarshal(t *testing.T) {
+
+	b, err := json.Marshal(&logFilterJSONRPC{
+		Address: logFilterAddresses{ethtypes.MustNewAddress("0x20355f3E852D4b6a9944AdA8d5399dDD3409A431")},
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"address":"0x20355f3e852d4b6a9944ada8d5399ddd3409a431"}`, string(b))
+
+	b, err = json.Marshal(&logFilterJSONRPC{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(b))
+
+}
+
+func TestWildcardListenerTopics(t *testing.T) {
+
+	l1req := testAddressesListenerReq(`{}`)
+	l1req.Filters = []fftypes.JSONAny{
+		*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `,"topics":[[],[],["0xd0f2f5103fd050739a9fb567251bc460cc24d091","0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4"]]}`),
+	}
+	es, _, _, done := testEventStream(t, l1req)
+	done() // stop it so we can safely call the listener directly
+
+	l := es.listeners[*l1req.ListenerID]
+	l.hwmBlock = 0
+	l.c.eventBlockTimestamps = false
+	assert.NotNil(t, l.wildcardLimiter)
+	assert.Equal(t, "*:Transfer(address,address,uint256):[,,0xd0f2f5103fd050739a9fb567251bc460cc24d091|0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4]", l.config.signature)
+
+	// Matches the recipient of the sample log
+	ethLog := sampleTransferLog()
+	_, ok, err := l.filterEnrichEthLog(context.Background(), l.config.filters[0], nil, ethLog)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ethLog.Topics[2] = ethtypes.MustNewHexBytes0xPrefix("0x00000000000000000000000020355f3e852d4b6a9944ada8d5399ddd3409a431")
+	_, ok, err = l.filterEnrichEthLog(context.Background(), l.config.filters[0], nil, ethLog)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ethLog.Topics = ethLog.Topics[0:2]
+	assert.False(t, l.config.filters[0].matchesTopics(ethLog.Topics))
+
+	// The indexed values are sent to the node in the topics of the query
+	ag := es.buildAggregatedListener([]*listener{l})
+	b, err := json.Marshal(&logFilterJSONRPC{Topics: ag.topics})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"topics":[
+		["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"],
+		null,
+		["0x0000000000000000000000003968ef051b422d3d1cdc182a88bba8dd922e6fa4","0x000000000000000000000000d0f2f5103fd050739a9fb567251bc460cc24d091"]
+	]}`, string(b))
+
+}
+
+func TestLogFilterTopicsMerge(t *testing.T) {
+
+	_, filters, err := parseEventFilters(context.Background(), []fftypes.JSONAny{
+		*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `,"topics":[[],["0x01"],["0x02"]]}`),
+		*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `,"topics":[[],["0x03","0x01"]]}`),
+	})
+	assert.NoError(t, err)
+
+	// Only the position restricted by both filters is sent to the node, with the union of the values
+	topics := logFilterTopics([]ethtypes.HexBytes0xPrefix{filters[0].Topic0}, filters)
+	assert.Len(t, topics, 2)
+	assert.Equal(t, []ethtypes.HexBytes0xPrefix{
+		ethtypes.MustNewHexBytes0xPrefix("0x0000000000000000000000000000000000000000000000000000000000000001"),
+		ethtypes.MustNewHexBytes0xPrefix("0x0000000000000000000000000000000000000000000000000000000000000003"),
+	}, topics[1])
+
+	// A filter without indexed values matches any, so falls back to matching on the connector
+	_, unrestricted, err := parseEventFilters(context.Background(), []fftypes.JSONAny{
+		*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`),
+	})
+	assert.NoError(t, err)
+	topics = logFilterTopics([]ethtypes.HexBytes0xPrefix{filters[0].Topic0}, append(filters, unrestricted...))
+	assert.Len(t, topics, 1)
+
+	// GraphQL uses an empty list for a position that matches any value
+	gqlTopics := graphqlTopics([][]ethtypes.HexBytes0xPrefix{{filters[0].Topic0}, nil, {filters[0].Topic0}})
+	assert.Equal(t, []ethtypes.HexBytes0xPrefix{}, gqlTopics[1])
+
+}
+
+func TestWildcardListenerRateLimit(t *testing.T) {
+
+	l1req := testAddressesListenerReq(`{}`)
+	es, _, _, done := testEventStream(t, l1req)
+	done() // stop it so we can safely call the listener directly
+
+	l := es.listeners[*l1req.ListenerID]
+	l.hwmBlock = 0
+	l.c.eventBlockTimestamps = false
+	ag := es.buildAggregatedListener([]*listener{l})
+
+	// Within the burst of the default rate
+	ev, ok, err := l.filterEnrichEthLog(context.Background(), l.config.filters[0], nil, sampleTransferLog())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	err = es.limitWildcardEvents(context.Background(), ag, ffcapi.ListenerEvents{ev, ev})
+	assert.NoError(t, err)
+
+	l.wildcardLimiter = rate.NewLimiter(rate.Limit(0.001), 1)
+	err = es.limitWildcardEvents(context.Background(), ag, ffcapi.ListenerEvents{ev})
+	assert.NoError(t, err)
+
+	// The rate is exceeded
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	cancelCtx()
+	err = es.limitWildcardEvents(ctx, ag, ffcapi.ListenerEvents{ev})
+	assert.Error(t, err)
+
+}
+
+func TestParseEventFiltersBadTopic(t *testing.T) {
+
+	_, _, err := parseEventFilters(context.Background(), []fftypes.JSONAny{
+		*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `,"topics":[[],["0x000000000000000000000000000000000000000000000000000000000000000000"]]}`),
+	})
+	assert.Regexp(t, "FF23151", err)
+
+}
@@ -0,0 +1,213 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type ListenerPauseState string
+
+const (
+	ListenerActive  ListenerPauseState = "active"
+	ListenerPausing ListenerPauseState = "pausing" // finishing the dispatch of events already fetched
+	ListenerPaused  ListenerPauseState = "paused"
+)
+
+type EventStreamPauseRequest struct {
+	StreamID   *fftypes.UUID `json:"streamId"`
+	ListenerID *fftypes.UUID `json:"listenerId,omitempty"` // if not set, all listeners of the stream are paused, including any added while it is paused
+	Drain      bool          `json:"drain,omitempty"`      // wait for the events already fetched to be delivered, and the listeners to stop, before returning
+}
+
+type EventStreamResumeRequest struct {
+	StreamID   *fftypes.UUID `json:"streamId"`
+	ListenerID *fftypes.UUID `json:"listenerId,omitempty"` // if not set, all listeners of the stream are resumed
+}
+
+type EventStreamPauseResponse struct {
+	Listeners []*ListenerPauseStatus `json:"listeners"`
+}
+
+type ListenerPauseStatus struct {
+	ListenerID *fftypes.UUID       `json:"listenerId"`
+	State      ListenerPauseState  `json:"state"`
+	Checkpoint *listenerCheckpoint `json:"checkpoint,omitempty"`
+}
+
+// EventStreamPause stops polling for the events of a listener, or all the listeners of a stream, so that the consumer
+// can be taken down for maintenance without deleting the listeners. A batch of events that has already been fetched
+// is delivered in full, and the checkpoint of the listener does not move while it is paused, so FFTM persists a
+// checkpoint that resumes exactly where it stopped. With drain, the request returns once the listeners have stopped.
+func (c *ethConnector) EventStreamPause(ctx context.Context, req *EventStreamPauseRequest) (*EventStreamPauseResponse, ffcapi.ErrorReason, error) {
+	es, listeners, reason, err := c.getPauseListeners(ctx, req.StreamID, req.ListenerID)
+	if err != nil {
+		return nil, reason, err
+	}
+
+	es.mux.Lock()
+	if req.ListenerID == nil {
+		es.paused = true
+	}
+	parked := make([]chan struct{}, len(listeners))
+	for i, l := range listeners {
+		parked[i] = es.pauseListenerLocked(l)
+	}
+	es.mux.Unlock()
+	log.L(ctx).Infof("Pausing %d listeners of stream '%s' (drain=%t)", len(listeners), es.id, req.Drain)
+
+	if req.Drain {
+		for i, l := range listeners {
+			select {
+			case <-parked[i]:
+			case <-es.ctx.Done():
+				return nil, "", i18n.NewError(ctx, msgs.MsgStreamNotStarted, es.id)
+			case <-ctx.Done():
+				return nil, "", i18n.NewError(ctx, msgs.MsgDrainIncomplete, l.id)
+			}
+		}
+	}
+	return es.pauseStatus(listeners), "", nil
+}
+
+// EventStreamResume resumes a paused listener, or all the listeners of a stream. Each listener catches up
+// from the checkpoint it paused at.
+func (c *ethConnector) EventStreamResume(ctx context.Context, req *EventStreamResumeRequest) (*EventStreamPauseResponse, ffcapi.ErrorReason, error) {
+	es, listeners, reason, err := c.getPauseListeners(ctx, req.StreamID, req.ListenerID)
+	if err != nil {
+		return nil, reason, err
+	}
+
+	es.mux.Lock()
+	if req.ListenerID == nil {
+		es.paused = false
+	}
+	restart := make([]*listener, 0, len(listeners))
+	for _, l := range listeners {
+		if l.parked == nil {
+			continue
+		}
+		if isClosed(l.parked) {
+			// Excluded from the lead group until we have determined whether it needs to catch up
+			l.catchup = true
+			restart = append(restart, l)
+		}
+		l.parked = nil
+	}
+	es.mux.Unlock()
+
+	es.startEventListeners(restart)
+	// The lead group is rebuilt including the listeners that are ready to rejoin it
+	es.mux.Lock()
+	es.updateCount++
+	es.mux.Unlock()
+	log.L(ctx).Infof("Resumed %d listeners of stream '%s'", len(listeners), es.id)
+
+	return es.pauseStatus(listeners), "", nil
+}
+
+func (c *ethConnector) getPauseListeners(ctx context.Context, streamID, listenerID *fftypes.UUID) (*eventStream, []*listener, ffcapi.ErrorReason, error) {
+	c.mux.Lock()
+	es := c.eventStreams[*streamID]
+	c.mux.Unlock()
+	if es == nil {
+		return nil, nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgStreamNotStarted, streamID)
+	}
+
+	es.mux.Lock()
+	defer es.mux.Unlock()
+	if listenerID != nil {
+		l := es.listeners[*listenerID]
+		if l == nil {
+			return nil, nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgListenerNotStarted, listenerID, es.id)
+		}
+		return es, []*listener{l}, "", nil
+	}
+	listeners := make([]*listener, 0, len(es.listeners))
+	for _, l := range es.listeners {
+		listeners = append(listeners, l)
+	}
+	return es, listeners, "", nil
+}
+
+func (es *eventStream) pauseStatus(listeners []*listener) *EventStreamPauseResponse {
+	res := &EventStreamPauseResponse{
+		Listeners: make([]*ListenerPauseStatus, len(listeners)),
+	}
+	for i, l := range listeners {
+		es.mux.Lock()
+		state := ListenerActive
+		if l.parked != nil {
+			state = ListenerPausing
+			if isClosed(l.parked) {
+				state = ListenerPaused
+			}
+		}
+		es.mux.Unlock()
+		res.Listeners[i] = &ListenerPauseStatus{
+			ListenerID: l.id,
+			State:      state,
+			Checkpoint: l.getHWMCheckpoint(),
+		}
+	}
+	return res
+}
+
+// pauseListenerLocked marks a listener as paused, so the loop it is running in stops at the end of the
+// current batch, and returns the channel that is closed when it has stopped. Must hold the ES lock.
+func (es *eventStream) pauseListenerLocked(l *listener) chan struct{} {
+	if l.parked == nil {
+		l.parked = make(chan struct{})
+		// The lead group is rebuilt without the listener on the next poll
+		es.updateCount++
+	}
+	return l.parked
+}
+
+// parkListenerLocked is called by the loop running a paused listener, when it has stopped. Must hold the ES lock.
+func (es *eventStream) parkListenerLocked(l *listener) {
+	if !isClosed(l.parked) {
+		log.L(es.ctx).Infof("Listener '%s' paused", l.id)
+		close(l.parked)
+	}
+}
+
+// parkIfPaused is called by a catchup loop, to remove a paused listener from the group it is catching up
+func (es *eventStream) parkIfPaused(l *listener) bool {
+	es.mux.Lock()
+	defer es.mux.Unlock()
+	if l.parked != nil {
+		es.parkListenerLocked(l)
+		return true
+	}
+	return false
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventStreamPauseDrainResumeListener(t *testing.T) {
+
+	l1req := testAddressesListenerReq(`{}`)
+	l2req := testAddressesListenerReq(`{}`)
+	es, _, _, done := testEventStream(t, l1req, l2req)
+	defer done()
+	ctx := context.Background()
+
+	// The lead group stops polling for the listener on its next poll
+	res, reason, err := es.c.EventStreamPause(ctx, &EventStreamPauseRequest{
+		StreamID:   es.id,
+		ListenerID: l1req.ListenerID,
+		Drain:      true,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Len(t, res.Listeners, 1)
+	assert.Equal(t, ListenerPaused, res.Listeners[0].State)
+	assert.Equal(t, int64(testHighBlock), res.Listeners[0].Checkpoint.Block)
+
+	var ag *aggregatedListener
+	lastUpdate := -1
+	es.buildReuseLeadGroupListener(&lastUpdate, &ag)
+	assert.Len(t, ag.listeners, 1)
+	assert.Equal(t, l2req.ListenerID, ag.listeners[0].id)
+
+	// Pausing again is a no-op
+	res, _, err = es.c.EventStreamPause(ctx, &EventStreamPauseRequest{StreamID: es.id, ListenerID: l1req.ListenerID})
+	assert.NoError(t, err)
+	assert.Equal(t, ListenerPaused, res.Listeners[0].State)
+
+	// Rejoins the lead group, as it is close to the head of the chain
+	res, reason, err = es.c.EventStreamResume(ctx, &EventStreamResumeRequest{StreamID: es.id, ListenerID: l1req.ListenerID})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, ListenerActive, res.Listeners[0].State)
+	es.buildReuseLeadGroupListener(&lastUpdate, &ag)
+	assert.Len(t, ag.listeners, 2)
+
+}
+
+func TestEventStreamPauseResumeStream(t *testing.T) {
+
+	l1req := testAddressesListenerReq(`{}`)
+	es, _, _, done := testEventStream(t, l1req)
+	defer done()
+	ctx := context.Background()
+
+	res, _, err := es.c.EventStreamPause(ctx, &EventStreamPauseRequest{StreamID: es.id, Drain: true})
+	assert.NoError(t, err)
+	assert.Len(t, res.Listeners, 1)
+	assert.Equal(t, ListenerPaused, res.Listeners[0].State)
+
+	// Listeners added while the stream is paused start paused
+	l2req := testAddressesListenerReq(`{}`)
+	l2, err := es.addEventListener(ctx, l2req)
+	assert.NoError(t, err)
+	es.mux.Lock()
+	assert.NotNil(t, l2.parked)
+	es.mux.Unlock()
+
+	// All the listeners are resumed, whether or not they had stopped yet
+	res, _, err = es.c.EventStreamResume(ctx, &EventStreamResumeRequest{StreamID: es.id})
+	assert.NoError(t, err)
+	assert.Len(t, res.Listeners, 2)
+	for _, ls := range res.Listeners {
+		assert.Equal(t, ListenerActive, ls.State)
+	}
+	assert.False(t, es.paused)
+
+	// Resuming an active listener is a no-op
+	res, _, err = es.c.EventStreamResume(ctx, &EventStreamResumeRequest{StreamID: es.id, ListenerID: l1req.ListenerID})
+	assert.NoError(t, err)
+	assert.Equal(t, ListenerActive, res.Listeners[0].State)
+
+}
+
+func TestEventStreamPauseCatchupListener(t *testing.T) {
+
+	l, _, done := newTestListener(t, false)
+	defer done()
+	es := l.es
+
+	es.mux.Lock()
+	l.catchup = true
+	parked := es.pauseListenerLocked(l)
+	es.mux.Unlock()
+	assert.Equal(t, ListenerPausing, es.pauseStatus([]*listener{l}).Listeners[0].State)
+
+	// The catchup loop drops the listener at the start of its next page
+	es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
+	<-parked
+	<-l.catchupLoopDone
+	assert.Equal(t, ListenerPaused, es.pauseStatus([]*listener{l}).Listeners[0].State)
+	assert.False(t, es.parkIfPaused(&listener{id: fftypes.NewUUID()}))
+
+}
+
+func TestEventStreamPauseErrors(t *testing.T) {
+
+	l1req := testAddressesListenerReq(`{}`)
+	es, _, _, done := testEventStream(t, l1req)
+	defer done()
+
+	_, reason, err := es.c.EventStreamPause(context.Background(), &EventStreamPauseRequest{StreamID: fftypes.NewUUID()})
+	assert.Regexp(t, "FF23041", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	_, reason, err = es.c.EventStreamResume(context.Background(), &EventStreamResumeRequest{StreamID: es.id, ListenerID: fftypes.NewUUID()})
+	assert.Regexp(t, "FF23043", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	// A listener that is catching up, but has no catchup loop running, never stops
+	l := es.listeners[*l1req.ListenerID]
+	es.mux.Lock()
+	l.catchup = true
+	es.mux.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = es.c.EventStreamPause(ctx, &EventStreamPauseRequest{StreamID: es.id, Drain: true})
+	assert.Regexp(t, "FF23157", err)
+
+}
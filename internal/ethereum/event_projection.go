@@ -0,0 +1,118 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// The metadata of an event that can be selected, across both event schemas. The signature and blockHash
+// are also removed from the ID of the event if they are not selected.
+var projectableInfoFields = map[string]bool{
+	"signature": true, "blockHash": true, "blockNumber": true, "transactionHash": true, "transactionIndex": true,
+	"logIndex": true, "removed": true, "address": true, "topics": true, "data": true,
+	"inputMethod": true, "inputArgs": true, "inputSigner": true, "token": true, "eventLabel": true, "subId": true,
+}
+
+// fieldOptions select the parts of each event that are delivered, to reduce the size of high volume streams
+// that only need a few fields. A list that is not set includes everything, and an empty list includes nothing.
+type fieldOptions struct {
+	Data []string `json:"data,omitempty"` // The top-level fields of the decoded event data to include, when the data is formatted as an object
+	Info []string `json:"info,omitempty"` // The metadata to include, such as address, transactionHash, topics and data (the raw log), blockHash and signature
+}
+
+// fieldProjection is the parsed form of the field options
+type fieldProjection struct {
+	data map[string]bool // nil to include all
+	info map[string]bool // nil to include all
+}
+
+func newFieldProjection(ctx context.Context, o *fieldOptions) (*fieldProjection, error) {
+	fp := &fieldProjection{}
+	if o.Data != nil {
+		fp.data = make(map[string]bool, len(o.Data))
+		for _, f := range o.Data {
+			fp.data[f] = true
+		}
+	}
+	if o.Info != nil {
+		fp.info = make(map[string]bool, len(o.Info))
+		for _, f := range o.Info {
+			if !projectableInfoFields[f] {
+				valid := make([]string, 0, len(projectableInfoFields))
+				for k := range projectableInfoFields {
+					valid = append(valid, k)
+				}
+				sort.Strings(valid)
+				return nil, i18n.NewError(ctx, msgs.MsgInvalidInfoField, f, strings.Join(valid, ","))
+			}
+			fp.info[f] = true
+		}
+	}
+	return fp, nil
+}
+
+// apply removes the fields that are not selected from the event
+func (fp *fieldProjection) apply(ctx context.Context, event *ffcapi.Event) {
+	if fp == nil {
+		return
+	}
+	if fp.info != nil {
+		if !fp.info["signature"] {
+			event.ID.Signature = ""
+		}
+		if !fp.info["blockHash"] {
+			event.ID.BlockHash = ""
+		}
+		event.Info = projectJSONObject(ctx, event.Info, fp.info)
+	}
+	if fp.data != nil && event.Data != nil {
+		if data := projectJSONObject(ctx, event.Data, fp.data); data != nil {
+			b, _ := json.Marshal(data)
+			event.Data = fftypes.JSONAnyPtrBytes(b)
+		}
+	}
+}
+
+// projectJSONObject returns the selected top-level fields of a value that serializes to a JSON object,
+// or nil if it is not an object (such as event data formatted as an array)
+func projectJSONObject(ctx context.Context, v interface{}, fields map[string]bool) map[string]json.RawMessage {
+	b, err := json.Marshal(v)
+	var obj map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(b, &obj)
+	}
+	if err != nil {
+		log.L(ctx).Debugf("Fields not projected from value that is not a JSON object: %s", err)
+		return nil
+	}
+	for k := range obj {
+		if !fields[k] {
+			delete(obj, k)
+		}
+	}
+	return obj
+}
@@ -0,0 +1,97 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterEnrichEthLogFieldProjection(t *testing.T) {
+
+	l, _, _ := newTestListener(t, false)
+	l.c.eventBlockTimestamps = false
+	ctx := context.Background()
+
+	options, err := parseListenerOptions(ctx, fftypes.JSONAnyPtr(`{"fields":{"data":["value"],"info":["transactionHash","topics"]}}`))
+	assert.NoError(t, err)
+	l.ee.fields, err = newFieldProjection(ctx, options.Fields)
+	assert.NoError(t, err)
+
+	ev, matched, err := l.filterEnrichEthLog(ctx, l.config.filters[0], nil, sampleTransferLog())
+	assert.NoError(t, err)
+	assert.True(t, matched)
+	assert.JSONEq(t, `{"value":"1000"}`, ev.Event.Data.String())
+	b, err := json.Marshal(ev.Event.Info)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"transactionHash": "0x1a1f797ee000c529b6a2dd330cedd0d081417a30d16a4eecb3f863ab4657246f",
+		"topics": [
+			"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+			"0x0000000000000000000000003968ef051b422d3d1cdc182a88bba8dd922e6fa4",
+			"0x000000000000000000000000d0f2f5103fd050739a9fb567251bc460cc24d091"
+		]
+	}`, string(b))
+	assert.Empty(t, ev.Event.ID.Signature)
+	assert.Empty(t, ev.Event.ID.BlockHash)
+	assert.Equal(t, fftypes.FFuint64(1024), ev.Event.ID.BlockNumber)
+
+	// The signature and block hash are kept in the ID when selected, and an empty list selects nothing
+	l.ee.fields, err = newFieldProjection(ctx, &fieldOptions{Data: []string{}, Info: []string{"signature", "blockHash"}})
+	assert.NoError(t, err)
+	ev, _, err = l.filterEnrichEthLog(ctx, l.config.filters[0], nil, sampleTransferLog())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{}`, ev.Event.Data.String())
+	b, _ = json.Marshal(ev.Event.Info)
+	assert.JSONEq(t, `{"blockHash":"0x6b012339fbb85b70c58ecfd97b31950c4a28bcef5226e12dbe551cb1abaf3b4c"}`, string(b))
+	assert.Equal(t, l.config.filters[0].Signature, ev.Event.ID.Signature)
+	assert.Equal(t, "0x6b012339fbb85b70c58ecfd97b31950c4a28bcef5226e12dbe551cb1abaf3b4c", ev.Event.ID.BlockHash)
+
+}
+
+func TestFieldProjectionNonObjectData(t *testing.T) {
+
+	fp, err := newFieldProjection(context.Background(), &fieldOptions{Data: []string{"value"}})
+	assert.NoError(t, err)
+
+	// Data formatted as an array is delivered as it is
+	event := &ffcapi.Event{
+		ID:   ffcapi.EventID{Signature: "Transfer(address,address,uint256)"},
+		Info: map[string]interface{}{"address": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431"},
+		Data: fftypes.JSONAnyPtr(`["0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4","1000"]`),
+	}
+	fp.apply(context.Background(), event)
+	assert.Equal(t, `["0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4","1000"]`, event.Data.String())
+	assert.Equal(t, "Transfer(address,address,uint256)", event.ID.Signature)
+	assert.Len(t, event.Info, 1)
+
+	var nilProjection *fieldProjection
+	nilProjection.apply(context.Background(), event)
+
+}
+
+func TestParseListenerOptionsFieldsInvalid(t *testing.T) {
+
+	_, err := parseListenerOptions(context.Background(), fftypes.JSONAnyPtr(`{"fields":{"info":["address","wrong"]}}`))
+	assert.Regexp(t, "FF23158.*wrong.*address,blockHash", err)
+
+}
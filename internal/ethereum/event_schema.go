@@ -0,0 +1,76 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+const (
+	EventSchemaEVMConnect = "evmconnect" // the full log information, including the topics and raw data
+	EventSchemaEthConnect = "ethconnect" // compatible with the events delivered by ethconnect
+)
+
+// ethconnectEventInfo is the event information in the layout delivered by ethconnect. The block, transaction
+// and log positions, and the signature, are the standard fields added to every event by the transaction manager.
+type ethconnectEventInfo struct {
+	Address     string           `json:"address"`
+	SubID       string           `json:"subId"`
+	InputMethod string           `json:"inputMethod,omitempty"`
+	InputArgs   *fftypes.JSONAny `json:"inputArgs,omitempty"`
+	InputSigner string           `json:"inputSigner,omitempty"`
+}
+
+func validateEventSchema(ctx context.Context, schemaVersion string) error {
+	switch schemaVersion {
+	case EventSchemaEVMConnect, EventSchemaEthConnect:
+		return nil
+	default:
+		return i18n.NewError(ctx, msgs.MsgInvalidEventSchema, schemaVersion, strings.Join([]string{EventSchemaEVMConnect, EventSchemaEthConnect}, ","))
+	}
+}
+
+// schemaEventInfo returns the event information in the layout of the schema version of the listener
+func (ee *eventEnricher) schemaEventInfo(info *eventInfo) interface{} {
+	if ee.schemaVersion != EventSchemaEthConnect {
+		return info
+	}
+	formatAddress := func(a *ethtypes.Address0xHex) string {
+		if ee.checksumAddresses {
+			return checksumAddress(a).String()
+		}
+		return a.String()
+	}
+	ei := &ethconnectEventInfo{
+		SubID:       ee.subID,
+		InputMethod: info.InputMethod,
+		InputArgs:   info.InputArgs,
+	}
+	if info.Address != nil {
+		ei.Address = formatAddress(info.Address)
+	}
+	if info.InputSigner != nil {
+		ei.InputSigner = formatAddress(info.InputSigner)
+	}
+	return ei
+}
@@ -0,0 +1,121 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFilterEnrichEthLogEthConnectSchema(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, true)
+	l.ee.schemaVersion = EventSchemaEthConnect
+	l.ee.subID = l.id.String()
+	l.ee.checksumAddresses = true
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1024),
+		}
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**txInfoJSONRPC) = &txInfoJSONRPC{
+			From:  ethtypes.MustNewAddress("0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4"),
+			Input: ethtypes.MustNewHexBytes0xPrefix("0xa9059cbb000000000000000000000000d0f2f5103fd050739a9fb567251bc460cc24d09100000000000000000000000000000000000000000000000000000000000003e8"),
+		}
+	})
+
+	ev, ok, err := l.filterEnrichEthLog(context.Background(), l.config.filters[0], l.config.options.Methods, sampleTransferLog())
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	b, err := json.Marshal(ev.Event.Info)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"address": "0x20355f3E852D4b6a9944AdA8d5399dDD3409A431",
+		"subId": "`+l.id.String()+`",
+		"inputMethod": "transfer(address,uint256)",
+		"inputArgs": {
+			"_to": "0xD0f2f5103Fd050739a9FB567251bC460CC24D091",
+			"_value": "1000"
+		},
+		"inputSigner": "0x3968eF051b422D3D1CdC182A88BBA8dD922e6Fa4"
+	}`, string(b))
+
+	// Without checksums, and without a transaction lookup
+	l.ee.checksumAddresses = false
+	info := l.ee.schemaEventInfo(&eventInfo{logJSONRPC: *sampleTransferLog()})
+	b, err = json.Marshal(info)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"address": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431",
+		"subId": "`+l.id.String()+`"
+	}`, string(b))
+
+}
+
+func TestEventSchemaListenerOption(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsSchemaVersion, EventSchemaEthConnect)
+	})
+	mockStreamLoopEmpty(mRPC)
+	newListener := func(options string) *ffcapi.EventListenerAddRequest {
+		return &ffcapi.EventListenerAddRequest{
+			ListenerID: fftypes.NewUUID(),
+			EventListenerOptions: ffcapi.EventListenerOptions{
+				Filters: []fftypes.JSONAny{
+					*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`),
+				},
+				Options:   fftypes.JSONAnyPtr(options),
+				FromBlock: strconv.Itoa(testHighBlock),
+			},
+		}
+	}
+	l1, l2 := newListener(`{}`), newListener(`{"schemaVersion":"evmconnect"}`)
+	es, _, _, done := testEventStreamExistingConnector(t, ctx, done, c, mRPC, l1, l2)
+	defer done()
+
+	ee1 := es.listeners[*l1.ListenerID].ee
+	assert.Equal(t, EventSchemaEthConnect, ee1.schemaVersion)
+	assert.Equal(t, l1.ListenerID.String(), ee1.subID)
+	ee2 := es.listeners[*l2.ListenerID].ee
+	assert.Equal(t, EventSchemaEVMConnect, ee2.schemaVersion)
+	assert.Empty(t, ee2.subID)
+
+}
+
+func TestEventSchemaInvalid(t *testing.T) {
+
+	_, err := parseListenerOptions(context.Background(), fftypes.JSONAnyPtr(`{"schemaVersion":"v0"}`))
+	assert.Regexp(t, "FF23125.*v0", err)
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(EventsSchemaVersion, "v0")
+	_, err = NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23125.*v0", err)
+
+}
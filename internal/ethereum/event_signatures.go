@@ -0,0 +1,127 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// eventSignatureRegistry maps the topic0 of events to human-readable labels, so that events can be
+// identified in logs and delivered payloads - including those matched from contracts we do not have
+// the ABI for, where the event signature matches but the layout of the event might not. Different
+// events can share a signature (such as the ERC-20 and ERC-721 Transfer events) so each topic can
+// have multiple labels.
+type eventSignatureRegistry struct {
+	mux    sync.RWMutex
+	labels map[string][]string
+}
+
+func (c *ethConnector) initEventSignatures(ctx context.Context, conf config.Section) error {
+	if !conf.GetBool(EventsSignatureLabels) {
+		return nil
+	}
+	c.eventSignatures = &eventSignatureRegistry{labels: make(map[string][]string)}
+	if file := conf.GetString(EventsSignatureLabelsFile); file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return i18n.NewError(ctx, msgs.MsgInvalidSignatureLabels, file, err)
+		}
+		var mapping map[string]string
+		if err := json.Unmarshal(b, &mapping); err != nil {
+			return i18n.NewError(ctx, msgs.MsgInvalidSignatureLabels, file, err)
+		}
+		for topic, label := range mapping {
+			topic0, err := ethtypes.NewHexBytes0xPrefix(topic)
+			if err != nil || len(topic0) != 32 {
+				return i18n.NewError(ctx, msgs.MsgInvalidSignatureLabels, file, topic)
+			}
+			c.eventSignatures.add(topic0, label)
+		}
+		log.L(ctx).Infof("Loaded %d event signature labels from %s", len(mapping), file)
+	}
+	return nil
+}
+
+// RegisterEventSignatures adds labels for all the events in an ABI, prefixed by the name of its source
+// (such as the name an ABI was uploaded with). Nothing is registered if event signature labels are disabled.
+func (c *ethConnector) RegisterEventSignatures(ctx context.Context, source string, a abi.ABI) {
+	if c.eventSignatures == nil {
+		return
+	}
+	for _, e := range a {
+		if e.Type == abi.Event {
+			c.eventSignatures.addEvent(ctx, source, e)
+		}
+	}
+}
+
+func (r *eventSignatureRegistry) addEvent(ctx context.Context, source string, e *abi.Entry) {
+	topic0, err := e.SignatureHashCtx(ctx)
+	if err != nil {
+		log.L(ctx).Warnf("Unable to label event '%s': %s", e.Name, err)
+		return
+	}
+	params := make([]string, len(e.Inputs))
+	for i, p := range e.Inputs {
+		param := p.String()
+		if p.Indexed {
+			param += " indexed"
+		}
+		if p.Name != "" {
+			param += " " + p.Name
+		}
+		params[i] = param
+	}
+	label := e.Name + "(" + strings.Join(params, ", ") + ")"
+	if source != "" {
+		label = source + ":" + label
+	}
+	r.add(topic0, label)
+}
+
+func (r *eventSignatureRegistry) add(topic0 ethtypes.HexBytes0xPrefix, label string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	key := topic0.String()
+	for _, existing := range r.labels[key] {
+		if existing == label {
+			return
+		}
+	}
+	r.labels[key] = append(r.labels[key], label)
+}
+
+// label returns the labels for the topic, or an empty string if the registry is disabled or has none
+func (r *eventSignatureRegistry) label(topics []ethtypes.HexBytes0xPrefix) string {
+	if r == nil || len(topics) == 0 {
+		return ""
+	}
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return strings.Join(r.labels[topics[0].String()], " | ")
+}
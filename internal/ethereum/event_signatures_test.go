@@ -0,0 +1,135 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const transferTopic0 = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+func TestEventSignatureLabels(t *testing.T) {
+
+	labelsFile := filepath.Join(t.TempDir(), "labels.json")
+	err := os.WriteFile(labelsFile, []byte(`{"`+transferTopic0+`":"ERC721:Transfer(address indexed from, address indexed to, uint256 indexed tokenId)"}`), 0600)
+	assert.NoError(t, err)
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsSignatureLabels, true)
+		conf.Set(EventsSignatureLabelsFile, labelsFile)
+	})
+	defer done()
+
+	var erc20 abi.ABI
+	err = json.Unmarshal([]byte(`[`+abiTransferEvent+`,`+abiTransferFn+`]`), &erc20)
+	assert.NoError(t, err)
+	c.RegisterEventSignatures(ctx, "ERC20", erc20)
+	c.RegisterEventSignatures(ctx, "ERC20", erc20) // not added twice
+	c.RegisterEventSignatures(ctx, "", abi.ABI{{Type: abi.Event, Name: "Bad", Inputs: abi.ParameterArray{{Type: "wrong"}}}})
+
+	topics := []ethtypes.HexBytes0xPrefix{ethtypes.MustNewHexBytes0xPrefix(transferTopic0)}
+	assert.Equal(t, "ERC721:Transfer(address indexed from, address indexed to, uint256 indexed tokenId) | ERC20:Transfer(address indexed from, address indexed to, uint256 value)", c.eventSignatures.label(topics))
+	assert.Empty(t, c.eventSignatures.label([]ethtypes.HexBytes0xPrefix{ethtypes.MustNewHexBytes0xPrefix("0x1234")}))
+	assert.Empty(t, c.eventSignatures.label(nil))
+
+}
+
+func TestEventSignatureLabelsDelivered(t *testing.T) {
+
+	// Without labels, nothing is added to the event
+	l, _, _ := newTestListener(t, false)
+	l.c.eventBlockTimestamps = false
+	ev, _, err := l.filterEnrichEthLog(context.Background(), l.config.filters[0], nil, sampleTransferLog())
+	assert.NoError(t, err)
+	assert.Empty(t, ev.Event.Info.(*eventInfo).EventLabel)
+
+	// The events of listeners are registered as they are added
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsSignatureLabels, true)
+		conf.Set(EventsBlockTimestamps, false)
+	})
+	mockStreamLoopEmpty(mRPC)
+	lID := fftypes.NewUUID()
+	es, _, _, done := testEventStreamExistingConnector(t, ctx, done, c, mRPC, &ffcapi.EventListenerAddRequest{
+		ListenerID: lID,
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters:   []fftypes.JSONAny{*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`)},
+			Options:   fftypes.JSONAnyPtr(`{}`),
+			FromBlock: strconv.Itoa(testHighBlock),
+		},
+	})
+	defer done()
+	l = es.listeners[*lID]
+	headLog := sampleTransferLog()
+	headLog.BlockNumber = ethtypes.NewHexInteger64(testHighBlock)
+	ev, _, err = l.filterEnrichEthLog(ctx, l.config.filters[0], nil, headLog)
+	assert.NoError(t, err)
+	assert.Equal(t, "Transfer(address indexed from, address indexed to, uint256 value)", ev.Event.Info.(*eventInfo).EventLabel)
+
+	// An event with the same signature, that cannot be decoded with the filter, is still labeled
+	erc721Log := sampleTransferLog()
+	erc721Log.BlockNumber = ethtypes.NewHexInteger64(testHighBlock)
+	erc721Log.Topics = append(erc721Log.Topics, ethtypes.MustNewHexBytes0xPrefix("0x00000000000000000000000000000000000000000000000000000000000003e8"))
+	erc721Log.Data = nil
+	ev, _, err = l.filterEnrichEthLog(ctx, l.config.filters[0], nil, erc721Log)
+	assert.NoError(t, err)
+	assert.Nil(t, ev.Event.Data)
+	assert.Equal(t, "Transfer(address indexed from, address indexed to, uint256 value)", ev.Event.Info.(*eventInfo).EventLabel)
+
+}
+
+func TestEventSignatureLabelsDisabled(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+	c.RegisterEventSignatures(ctx, "ERC20", abi.ABI{})
+	assert.Nil(t, c.eventSignatures)
+	assert.Empty(t, c.eventSignatures.label([]ethtypes.HexBytes0xPrefix{ethtypes.MustNewHexBytes0xPrefix(transferTopic0)}))
+
+}
+
+func TestEventSignatureLabelsFileErrors(t *testing.T) {
+
+	dir := t.TempDir()
+	badJSON := filepath.Join(dir, "bad.json")
+	_ = os.WriteFile(badJSON, []byte(`!json`), 0600)
+	badTopic := filepath.Join(dir, "topic.json")
+	_ = os.WriteFile(badTopic, []byte(`{"0x1234":"short"}`), 0600)
+
+	for _, file := range []string{filepath.Join(dir, "missing.json"), badJSON, badTopic} {
+		config.RootConfigReset()
+		conf := config.RootSection("unittest")
+		InitConfig(conf)
+		conf.Set(EventsSignatureLabels, true)
+		conf.Set(EventsSignatureLabelsFile, file)
+		c := &ethConnector{}
+		err := c.initEventSignatures(context.Background(), conf)
+		assert.Regexp(t, "FF23170", err)
+	}
+
+}
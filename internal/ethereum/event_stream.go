@@ -17,14 +17,17 @@
 package ethereum
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
@@ -32,14 +35,86 @@ import (
 	"github.com/hyperledger/firefly-signer/pkg/abi"
 	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// EventOrderingListener delivers the events of each listener in order, with listeners catching up independently
+	EventOrderingListener = "listener"
+	// EventOrderingStream delivers the events of all the listeners in a stream in order, by holding back the
+	// stream while any of its listeners catches up
+	EventOrderingStream = "stream"
+
+	// maxLogTopics is the number of topics a log can have - the event signature, and up to three indexed values
+	maxLogTopics = 4
 )
 
 // eventFilter is our Ethereum specific filter options - an array of these can be configured on each listener
 type eventFilter struct {
-	Event     *abi.Entry                `json:"event"`             // The ABI spec of the event to listen to
-	Address   *ethtypes.Address0xHex    `json:"address,omitempty"` // An optional address to restrict the
-	Topic0    ethtypes.HexBytes0xPrefix `json:"topic0"`            // Topic 0 match
-	Signature string                    `json:"signature"`         // The cached signature of this event
+	Event     *abi.Entry                    `json:"event"`             // The ABI spec of the event to listen to
+	Address   *ethtypes.Address0xHex        `json:"address,omitempty"` // An optional address to restrict the
+	Topics    [][]ethtypes.HexBytes0xPrefix `json:"topics,omitempty"`  // Optional indexed values to match, positional as in eth_getLogs so topics[1] lists the values of the first indexed parameter
+	Topic0    ethtypes.HexBytes0xPrefix     `json:"topic0"`            // Topic 0 match
+	Signature string                        `json:"signature"`         // The cached signature of this event
+
+	topicValues []map[string]bool // the parsed topics, padded to 32 bytes
+}
+
+// matchesTopics returns true if the indexed values of the log match the topics of the filter. The topics of the
+// eth_getLogs query are shared by all the filters of the listeners queried together, so only restrict a position
+// that every filter restricts (see logFilterTopics). Any other values are matched by the connector.
+func (f *eventFilter) matchesTopics(topics []ethtypes.HexBytes0xPrefix) bool {
+	for i, values := range f.topicValues {
+		if i == 0 || len(values) == 0 {
+			continue // topic 0 is the event signature, and an empty position matches any value
+		}
+		if i >= len(topics) || !values[string(topics[i])] {
+			return false
+		}
+	}
+	return true
+}
+
+// logFilterTopics merges the indexed values of a set of filters into the topics of a single eth_getLogs query,
+// positional as in eth_getLogs so an OR-list of values per position. A log must match any one of the filters,
+// so a position is only restricted when every filter restricts it, to the union of their values. Positions that
+// some filter does not restrict match any value, and are omitted from the end of the topics.
+func logFilterTopics(signatures []ethtypes.HexBytes0xPrefix, filters []*eventFilter) [][]ethtypes.HexBytes0xPrefix {
+	topics := [][]ethtypes.HexBytes0xPrefix{signatures}
+	for i := 1; i < maxLogTopics; i++ {
+		var values []ethtypes.HexBytes0xPrefix
+		unique := make(map[string]bool)
+		for _, f := range filters {
+			if i >= len(f.topicValues) || len(f.topicValues[i]) == 0 {
+				values = nil
+				break
+			}
+			for v := range f.topicValues[i] {
+				if !unique[v] {
+					unique[v] = true
+					values = append(values, ethtypes.HexBytes0xPrefix(v))
+				}
+			}
+		}
+		sort.Slice(values, func(a, b int) bool { return bytes.Compare(values[a], values[b]) < 0 })
+		topics = append(topics, values) // a nil position serializes as null, which matches any value
+	}
+	for len(topics) > 1 && topics[len(topics)-1] == nil {
+		topics = topics[:len(topics)-1]
+	}
+	return topics
+}
+
+func (f *eventFilter) topicsSignature() string {
+	positions := make([]string, len(f.Topics))
+	for i, values := range f.Topics {
+		strValues := make([]string, len(values))
+		for j, v := range values {
+			strValues[j] = v.String()
+		}
+		positions[i] = strings.Join(strValues, "|")
+	}
+	return "[" + strings.Join(positions, ",") + "]"
 }
 
 // eventInfo is the top-level structure we pass to applications for each event (through the FFCAPI framework)
@@ -48,6 +123,10 @@ type eventInfo struct {
 	InputMethod string                 `json:"inputMethod,omitempty"` // the method invoked, if it matched one of the signatures in the listener definition
 	InputArgs   *fftypes.JSONAny       `json:"inputArgs,omitempty"`   // the method parameters, if the method matched one of the signatures in the listener definition
 	InputSigner *ethtypes.Address0xHex `json:"inputSigner,omitempty"` // the signing `from` address of the transaction
+	Token       *tokenEventInfo        `json:"token,omitempty"`       // normalized token information, if the listener enables tokenTransfers and this is a standard token event
+	EventLabel  string                 `json:"eventLabel,omitempty"`  // the labels registered for the topic0 of the event, if event signature labels are enabled
+
+	checksumAddresses bool // format addresses with EIP-55 checksums when serializing
 }
 
 // eventStream is the state we hold in memory for each eventStream
@@ -62,6 +141,12 @@ type eventStream struct {
 	headBlock      int64
 	streamLoopDone chan struct{}
 	catchup        bool
+	dedupeCache    *lru.Cache   // nil if de-duplication is disabled
+	duplicates     atomic.Int64 // count of duplicate events dropped by the de-duplication cache
+	wal            *eventWAL    // nil if the write-ahead log is disabled
+	paused         bool         // listeners added while the stream is paused start paused
+	rescan         bool         // set by the stream loop when a continuity check requires the lead group to query again from its checkpoint
+	leaderTerm     int64        // the leadership term in which the checkpoints of the listeners were last read
 }
 
 // aggregatedListener is a generated structure that allows use to query/filter logs efficiently across a large number of listeners,
@@ -69,9 +154,13 @@ type eventStream struct {
 // industrial scale of listeners, that might share event signatures. For example listening to 1000 different "transfer" events for
 // different contract addresses.
 type aggregatedListener struct {
-	signatureSet      []ethtypes.HexBytes0xPrefix // a list of unique topic[0] event signatures to listener for
-	listenersByTopic0 map[string][]*listener      // a map of all listeners that are interested in an event signature - they may not be interested in the event itself (depending on sub-selection)
-	listeners         []*listener                 // list of all listeners
+	signatureSet      []ethtypes.HexBytes0xPrefix   // a list of unique topic[0] event signatures to listener for
+	topics            [][]ethtypes.HexBytes0xPrefix // the topics of the log queries - the signatureSet, followed by the indexed values all the filters restrict
+	listenersByTopic0 map[string][]*listener        // a map of all listeners that are interested in an event signature - they may not be interested in the event itself (depending on sub-selection)
+	listeners         []*listener                   // list of all listeners
+	addressSet        logFilterAddresses            // union of the addresses of all filters - nil if any filter matches all addresses
+	factories         bool                          // true if any listener discovers child contracts from a factory
+	transactionData   bool                          // true if any listener enriches events with the input data or signer of the transaction
 }
 
 func parseEventFilters(ctx context.Context, filters []fftypes.JSONAny) (string, []*eventFilter, error) {
@@ -98,6 +187,12 @@ func parseEventFilters(ctx context.Context, filters []fftypes.JSONAny) (string,
 		} else {
 			sigStrings[i] = "*:" + ethFilters[i].Event.String()
 		}
+		if len(ethFilters[i].Topics) > 0 {
+			if ethFilters[i].topicValues, err = parseTopicValues(ctx, ethFilters[i].Topics, msgs.MsgInvalidFilterTopic); err != nil {
+				return "", nil, err
+			}
+			sigStrings[i] += ":" + ethFilters[i].topicsSignature()
+		}
 	}
 	var signature string
 	if len(sigStrings) == 1 {
@@ -119,6 +214,12 @@ func (es *eventStream) addEventListener(ctx context.Context, req *ffcapi.EventLi
 	var checkpoint *listenerCheckpoint
 	if req.Checkpoint != nil {
 		checkpoint = req.Checkpoint.(*listenerCheckpoint)
+		// Everything up to the checkpoint FFTM persisted has been delivered
+		es.wal.acknowledge(req.ListenerID, checkpoint)
+	} else if es.c.snapshots != nil {
+		if checkpoint = es.c.snapshots.restoredCheckpoint(req.ListenerID); checkpoint != nil {
+			log.L(ctx).Infof("Listener %s starting from block %d restored from snapshot", req.ListenerID, checkpoint.Block)
+		}
 	}
 
 	signature, filters, err := parseEventFilters(ctx, req.Filters)
@@ -126,6 +227,11 @@ func (es *eventStream) addEventListener(ctx context.Context, req *ffcapi.EventLi
 		// Should not happen as we've previously been called with EventListenerVerifyOptions
 		return nil, i18n.NewError(ctx, msgs.MsgInvalidListenerOptions, err)
 	}
+	if es.c.eventSignatures != nil {
+		for _, f := range filters {
+			es.c.eventSignatures.addEvent(ctx, "", f.Event)
+		}
+	}
 
 	options, err := parseListenerOptions(ctx, req.Options)
 	if err != nil {
@@ -146,8 +252,49 @@ func (es *eventStream) addEventListener(ctx context.Context, req *ffcapi.EventLi
 		},
 	}
 	l.ee = &eventEnricher{
-		connector:     l.c,
-		extractSigner: l.config.options.Signer,
+		connector:      l.c,
+		extractSigner:  l.config.options.Signer,
+		tokenTransfers: l.config.options.TokenTransfers,
+		serializer:     l.c.serializerForOptions(l.config.options),
+	}
+	l.ee.checksumAddresses = l.c.checksumAddresses
+	if l.config.options.ChecksumAddresses != nil {
+		l.ee.checksumAddresses = *l.config.options.ChecksumAddresses
+	}
+	l.ee.schemaVersion = l.c.eventsSchemaVersion
+	if l.config.options.SchemaVersion != "" {
+		l.ee.schemaVersion = l.config.options.SchemaVersion
+	}
+	if l.ee.schemaVersion == EventSchemaEthConnect {
+		l.ee.subID = l.id.String()
+	}
+	if l.config.options.Factory != nil {
+		if l.factory, err = newFactoryFilter(ctx, l.config.options.Factory); err != nil {
+			return nil, err
+		}
+		l.config.filters = append(l.config.filters, l.factory.eventFilter)
+		l.config.signature = l.factory.listenerSignature(signature)
+		// The children discovered before the checkpoint are restored, and an empty set matches no children
+		children := l.config.options.Addresses
+		if checkpoint != nil {
+			children = append(append([]*ethtypes.Address0xHex{}, children...), checkpoint.Addresses...)
+		}
+		l.addresses.Store(newListenerAddresses(children))
+	} else if len(l.config.options.Addresses) > 0 {
+		l.addresses.Store(newListenerAddresses(l.config.options.Addresses))
+	}
+	if l.config.options.Exclude != nil {
+		if l.exclusions, err = newListenerExclusions(ctx, l.config.options.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	if l.config.options.Fields != nil {
+		if l.ee.fields, err = newFieldProjection(ctx, l.config.options.Fields); err != nil {
+			return nil, err
+		}
+	}
+	if es.c.wildcardEventRate > 0 && isWildcardListener(l.config.filters, l.config.options) {
+		l.wildcardLimiter = rate.NewLimiter(rate.Limit(es.c.wildcardEventRate), es.c.wildcardEventRate)
 	}
 	if checkpoint != nil {
 		l.hwmBlock = checkpoint.Block
@@ -155,20 +302,61 @@ func (es *eventStream) addEventListener(ctx context.Context, req *ffcapi.EventLi
 	if err := l.ensureHWM(ctx); err != nil {
 		return nil, err
 	}
+	if checkpoint == nil && l.config.options.Bootstrap != nil {
+		if es.c.orderedStreams {
+			return nil, i18n.NewError(ctx, msgs.MsgBootstrapOrderedStreams)
+		}
+		if l.bootstrap, err = es.c.loadBootstrap(ctx, l.config.options.Bootstrap, l.hwmBlock); err != nil {
+			return nil, err
+		}
+	}
 	log.L(es.ctx).Infof("Initialized listener '%s' (FromBlock=%s) Block=%d Checkpoint=%+v", l.id, l.config.fromBlock, l.hwmBlock, checkpoint)
 
 	es.updateCount++
 	es.listeners[*req.ListenerID] = l
+	if es.paused {
+		es.pauseListenerLocked(l)
+	}
 
 	return l, nil
 }
 
 func (es *eventStream) startEventListener(l *listener) {
-	readyForLead, removed := l.checkReadyForLeadPackOrRemoved(es.ctx)
-	l.catchup = !readyForLead
-	if l.catchup && !removed {
-		l.catchupLoopDone = make(chan struct{})
-		go l.listenerCatchupLoop()
+	es.startEventListeners([]*listener{l})
+}
+
+// startEventListeners determines which of the supplied listeners need to catch up, and starts a catchup loop
+// for each set of those listeners that are catching up from the same block. Each set shares combined
+// eth_getLogs queries, with the results demultiplexed to the individual listeners.
+func (es *eventStream) startEventListeners(listeners []*listener) {
+	catchupGroups := make(map[int64][]*listener)
+	var groups [][]*listener
+	for _, l := range listeners {
+		readyForLead, removed := l.checkReadyForLeadPackOrRemoved(es.ctx)
+		if es.c.orderedStreams {
+			// The lead group catches up on behalf of every listener, so that events are merged across all
+			// listeners in the order they occurred on the chain
+			readyForLead = true
+		}
+		l.catchup = !readyForLead || l.bootstrap != nil
+		switch {
+		case removed || !l.catchup:
+		case l.bootstrap != nil:
+			// The high water mark moves to the end of the export once it is delivered
+			groups = append(groups, []*listener{l})
+		default:
+			catchupGroups[l.hwmBlock] = append(catchupGroups[l.hwmBlock], l)
+		}
+	}
+	for _, group := range catchupGroups {
+		groups = append(groups, group)
+	}
+	for _, group := range groups {
+		catchupLoopDone := make(chan struct{})
+		for _, l := range group {
+			l.catchupLoopDone = catchupLoopDone
+		}
+		go es.listenerCatchupLoop(group, catchupLoopDone)
 	}
 }
 
@@ -180,6 +368,7 @@ func (es *eventStream) removeEventListener(listenerID *fftypes.UUID) {
 	if l != nil {
 		es.updateCount++
 		delete(es.listeners, *listenerID)
+		es.wal.acknowledge(listenerID, nil)
 		l.hwmMux.Lock()
 		l.removed = true
 		l.hwmMux.Unlock()
@@ -201,7 +390,13 @@ func (es *eventStream) buildReuseLeadGroupListener(lastUpdate *int, ag **aggrega
 	if *lastUpdate != es.updateCount {
 		listeners := make([]*listener, 0, len(es.listeners))
 		for _, l := range es.listeners {
-			if !l.catchup {
+			switch {
+			case l.parked != nil:
+				// A paused listener leaves the lead group once the batch it was part of has been dispatched
+				if !l.catchup {
+					es.parkListenerLocked(l)
+				}
+			case !l.catchup:
 				listeners = append(listeners, l)
 			}
 		}
@@ -260,20 +455,36 @@ func (es *eventStream) leadGroupCatchup() bool {
 		}
 
 		// Poll in the range for events
-		toBlock := fromBlock + es.c.catchupPageSize - 1
-		events, err := es.getBlockRangeEvents(es.ctx, ag, fromBlock, toBlock)
+		term := es.syncedTerm()
+		toBlock := fromBlock + es.c.catchupPageSize.Load() - 1
+		pollCtx, span := es.c.startSpan(es.ctx, "EventStreamCatchup", attrFromBlock.Int64(fromBlock), attrToBlock.Int64(toBlock))
+		events, err := es.getBlockRangeEvents(withRPCPriority(pollCtx, rpcPriorityBulk), ag, fromBlock, toBlock)
+		if err == nil {
+			err = es.limitWildcardEvents(pollCtx, ag, events)
+		}
+		span.SetAttributes(attrEventCount.Int(len(events)))
+		endSpan(span, err)
 		if err != nil {
 			log.L(es.ctx).Errorf("Failed to query block range fromBlock=%d toBlock=%d headBlock=%d: %s", fromBlock, toBlock, chainHeadBlock, err)
 			failCount++
 			continue
 		}
+		if es.c.orderedStreams {
+			events = es.dropDelivered(ag, events)
+		}
 		log.L(es.ctx).Infof("Stream catchup fromBlock=%d toBlock=%d headBlock=%d events=%d listeners=%d", fromBlock, toBlock, chainHeadBlock, len(events), len(ag.listeners))
 
 		// Dispatch the events
-		if es.dispatchSetHWMCheckExit(ag, events, toBlock+1 /* hwm is the next block after our poll */) {
+		exiting, err := es.dispatchSetHWMCheckExit(ag, events, toBlock+1 /* hwm is the next block after our poll */, term)
+		if exiting {
 			log.L(es.ctx).Debugf("Stream catchup loop exiting")
 			return true
 		}
+		if err != nil {
+			log.L(es.ctx).Errorf("Failed to record events fromBlock=%d toBlock=%d in write-ahead log: %s", fromBlock, toBlock, err)
+			failCount++
+			continue
+		}
 
 		// Reset retry count for a successful loop
 		failCount = 0
@@ -350,9 +561,7 @@ func (es *eventStream) leadGroupSteadyState() bool {
 				// Create the new filter
 				err := es.c.backend.CallRPC(es.ctx, &filter, "eth_newFilter", &logFilterJSONRPC{
 					FromBlock: ethtypes.NewHexInteger64(fromBlock),
-					Topics: [][]ethtypes.HexBytes0xPrefix{
-						ag.signatureSet,
-					},
+					Topics:    ag.topics,
 				})
 				// If we fail to create the filter, we need to keep retrying
 				if err != nil {
@@ -363,11 +572,14 @@ func (es *eventStream) leadGroupSteadyState() bool {
 				log.L(es.ctx).Infof("Filter '%v' established", filter)
 			}
 			// Get the next batch of logs
+			term := es.syncedTerm()
 			var ethLogs []*logJSONRPC
-			rpcErr := es.c.backend.CallRPC(es.ctx, &ethLogs, filterRPC, filter)
+			pollCtx, span := es.c.startSpan(es.ctx, "EventStreamPoll")
+			rpcErr := es.c.backend.CallRPC(pollCtx, &ethLogs, filterRPC, filter)
 			// If we fail to query we just retry - setting filter to nil if not found
 			if rpcErr != nil {
-				if mapError(filterRPCMethods, rpcErr.Error()) == ffcapi.ErrorReasonNotFound {
+				endSpan(span, rpcErr.Error())
+				if es.c.mapRPCError(filterRPCMethods, rpcErr) == ffcapi.ErrorReasonNotFound {
 					log.L(es.ctx).Infof("Filter '%v' reset: %s", filter, rpcErr.Message)
 					filter = ""
 				}
@@ -378,7 +590,16 @@ func (es *eventStream) leadGroupSteadyState() bool {
 			filterRPC = "eth_getFilterChanges"
 
 			// Enrich the events
-			events, enrichErr := es.filterEnrichSort(es.ctx, ag, ethLogs)
+			ethLogs, enrichErr := es.c.sanitizeLogs(pollCtx, &logFilterJSONRPC{Topics: ag.topics}, ethLogs)
+			var events ffcapi.ListenerEvents
+			if enrichErr == nil {
+				events, enrichErr = es.filterEnrichSort(pollCtx, ag, ethLogs)
+			}
+			if enrichErr == nil {
+				enrichErr = es.limitWildcardEvents(pollCtx, ag, events)
+			}
+			span.SetAttributes(attrEventCount.Int(len(events)))
+			endSpan(span, enrichErr)
 			if enrichErr != nil {
 				log.L(es.ctx).Errorf("Failed to enrich events: %v", enrichErr)
 				// We have to reset our filter, as otherwise we'll skip past these events.
@@ -388,10 +609,24 @@ func (es *eventStream) leadGroupSteadyState() bool {
 			}
 
 			// Dispatch the events
-			if es.dispatchSetHWMCheckExit(ag, events, hwmBlock) {
+			exiting, err := es.dispatchSetHWMCheckExit(ag, events, hwmBlock, term)
+			if exiting {
 				log.L(es.ctx).Debugf("Stream loop exiting")
 				return true
 			}
+			if err != nil {
+				log.L(es.ctx).Errorf("Failed to record events in write-ahead log: %s", err)
+				// We have to reset our filter, as otherwise we'll skip past these events.
+				filterResetRequired = true
+				failCount++
+				continue
+			}
+
+			if es.rescan {
+				// The filter is re-established from the checkpoint of the listeners
+				filterResetRequired = true
+				continue
+			}
 
 			// Update the head block to be the hwm block
 			es.mux.Lock()
@@ -404,7 +639,10 @@ func (es *eventStream) leadGroupSteadyState() bool {
 
 		// Sleep for the polling interval
 		select {
-		case <-time.After(es.c.eventFilterPollingInterval):
+		case <-time.After(es.c.tuned().eventFilterPollingInterval):
+		case <-es.c.shutdown.drained():
+			log.L(es.ctx).Debugf("Stream loop stopping for shutdown")
+			return true
 		case <-es.ctx.Done():
 			log.L(es.ctx).Debugf("Stream loop stopping")
 			return true
@@ -431,9 +669,11 @@ func (es *eventStream) preStartProcessing() {
 	}
 
 	// Now we've done that, we can start all the listeners
+	listeners := make([]*listener, 0, len(es.listeners))
 	for _, l := range es.listeners {
-		es.startEventListener(l)
+		listeners = append(listeners, l)
 	}
+	es.startEventListeners(listeners)
 }
 
 func (es *eventStream) streamLoop() {
@@ -441,6 +681,10 @@ func (es *eventStream) streamLoop() {
 
 	es.preStartProcessing()
 
+	if es.replayWAL() {
+		return
+	}
+
 	for {
 		// When we first start, we might find our leading pack of listeners are all way behind
 		// the head of the chain. So we run a catchup mode loop to ensure we don't ask the blockchain
@@ -458,33 +702,125 @@ func (es *eventStream) streamLoop() {
 
 }
 
-func (es *eventStream) dispatchSetHWMCheckExit(ag *aggregatedListener, events ffcapi.ListenerEvents, hwm int64) (exiting bool) {
+func (es *eventStream) dispatchSetHWMCheckExit(ag *aggregatedListener, events ffcapi.ListenerEvents, hwm, term int64) (exiting bool, err error) {
+
+	// A standby instance holds the events until it is elected leader. If it has been elected since they were
+	// queried, they are discarded and the HWM stays put, so they are queried again from the persisted checkpoints.
+	exiting, es.rescan = es.waitLeader(term, len(events) > 0)
+	if exiting {
+		return true, nil
+	}
+	if es.rescan {
+		return false, nil
+	}
 
 	// Dispatch the events, updating the in-memory checkpoint for all listeners.
 	if len(events) == 0 {
 		select {
 		case <-es.ctx.Done():
-			return true
+			return true, nil
 		default:
 		}
 	} else {
-		for _, event := range events {
+		var batch ffcapi.ListenerEvents
+		var err error
+		if batch, es.rescan, err = es.filterBatch(ag.listeners, events); err != nil {
+			// Nothing is dispatched, and the HWM stays put so the events are queried again
+			return false, err
+		}
+		for _, event := range batch {
 			log.L(es.ctx).Debugf("Detected event %s", event.Event)
 			select {
 			case es.events <- event:
+				es.wal.markDispatched(event)
 			case <-es.ctx.Done():
-				return true
+				return true, nil
 			}
 		}
 	}
 
-	// Move the HWM on all each listener forwards, if they are behind the base HWM for the event stream itself
-	for _, l := range ag.listeners {
-		l.moveHWM(hwm)
+	// Move the HWM on all each listener forwards, if they are behind the base HWM for the event stream itself.
+	// If events were held back by the continuity check, the HWM stays put so they are queried again.
+	if !es.rescan {
+		for _, l := range ag.listeners {
+			l.moveHWM(hwm)
+		}
 	}
 
+	// On shutdown we stop between batches, once the checkpoint has moved past the events delivered
+	return es.c.shutdown.isDraining(), nil
+
+}
+
+// filterBatch removes duplicates from a batch of events, and the events held back by the continuity check of the
+// listeners, then records the remainder in the write-ahead log before they are dispatched. The listeners only move
+// past the batch once it is recorded. When events are held back, or the log cannot be written, nothing is dispatched
+// and the batch is removed from the de-duplication cache, so it is delivered when it is queried again.
+func (es *eventStream) filterBatch(listeners []*listener, events ffcapi.ListenerEvents) (_ ffcapi.ListenerEvents, rescan bool, err error) {
+	batch := make(ffcapi.ListenerEvents, 0, len(events))
+	for _, event := range events {
+		if !es.isDuplicate(event) {
+			batch = append(batch, event)
+		}
+	}
+	checked, rescan, commit := es.checkContinuity(listeners, batch)
+	if !rescan {
+		err = es.wal.append(checked)
+	}
+	if rescan || err != nil {
+		es.forgetDelivered(batch)
+		return nil, true, err
+	}
+	commit()
+	return checked, false, nil
+}
+
+// replayWAL re-delivers the events that were in-flight when the connector stopped, ahead of polling the chain.
+// They are marked as delivered in the de-duplication cache, so are not delivered again when they are re-detected.
+func (es *eventStream) replayWAL() (exiting bool) {
+	// Once elected, the events delivered by the previous leader are acknowledged from the persisted checkpoints
+	if exiting, _ := es.waitLeader(es.syncedTerm(), true); exiting {
+		return true
+	}
+	for _, event := range es.wal.pending() {
+		listenerID := event.Event.ID.ListenerID
+		es.mux.Lock()
+		l := es.listeners[*listenerID]
+		es.mux.Unlock()
+		if l == nil {
+			es.wal.acknowledge(listenerID, nil)
+			continue
+		}
+		es.markDelivered(event)
+		log.L(es.ctx).Infof("Re-delivering event %s from write-ahead log", event.Event)
+		select {
+		case es.events <- event:
+			es.wal.markDispatched(event)
+		case <-es.ctx.Done():
+			return true
+		}
+	}
 	return false
+}
 
+// dropDelivered removes the events in blocks below the high water mark of their listener. When the lead group
+// catches up from the lowest high water mark of all its listeners, these have already been delivered.
+func (es *eventStream) dropDelivered(ag *aggregatedListener, events ffcapi.ListenerEvents) ffcapi.ListenerEvents {
+	hwms := make(map[fftypes.UUID]int64, len(ag.listeners))
+	for _, l := range ag.listeners {
+		l.hwmMux.Lock()
+		hwms[*l.id] = l.hwmBlock
+		l.hwmMux.Unlock()
+	}
+	undelivered := make(ffcapi.ListenerEvents, 0, len(events))
+	for _, event := range events {
+		id := &event.Event.ID
+		if hwm, ok := hwms[*id.ListenerID]; ok && int64(id.BlockNumber) < hwm {
+			continue
+		}
+		undelivered = append(undelivered, event)
+	}
+	return undelivered
 }
 
 func (es *eventStream) buildAggregatedListener(listeners []*listener) *aggregatedListener {
@@ -492,8 +828,33 @@ func (es *eventStream) buildAggregatedListener(listeners []*listener) *aggregate
 		listeners:         listeners,
 		listenersByTopic0: make(map[string][]*listener),
 	}
+	allAddressed := true
+	uniqueAddresses := make(map[ethtypes.Address0xHex]bool)
+	var filters []*eventFilter
+	addAddress := func(a *ethtypes.Address0xHex) {
+		if !uniqueAddresses[*a] {
+			uniqueAddresses[*a] = true
+			ag.addressSet = append(ag.addressSet, a)
+		}
+	}
 	for _, l := range listeners {
+		ag.factories = ag.factories || l.factory != nil
+		if o := l.config.options; o != nil {
+			ag.transactionData = ag.transactionData || len(o.Methods) > 0 || o.Signer
+		}
+		la := l.addresses.Load()
+		filters = append(filters, l.config.filters...)
 		for _, f := range l.config.filters {
+			switch {
+			case f.Address != nil:
+				addAddress(f.Address)
+			case la != nil:
+				for _, a := range la.list {
+					addAddress(a)
+				}
+			default:
+				allAddressed = false
+			}
 			sigStr := f.Topic0.String()
 			topicListeners, existing := ag.listenersByTopic0[sigStr]
 			if !existing {
@@ -502,9 +863,53 @@ func (es *eventStream) buildAggregatedListener(listeners []*listener) *aggregate
 			ag.listenersByTopic0[sigStr] = append(topicListeners, l)
 		}
 	}
+	if !allAddressed {
+		ag.addressSet = nil
+	}
+	ag.topics = logFilterTopics(ag.signatureSet, filters)
 	return ag
 }
 
+// isDuplicate checks if we have recently delivered the event to the listener, as can happen when a filter is re-created,
+// a block is replayed after a re-org, or a catchup query overlaps with the head of the chain. The block hash is part of
+// the key, so a different event that is re-org'd into the same position is still delivered.
+func (es *eventStream) isDuplicate(event *ffcapi.ListenerEvent) bool {
+	key := es.dedupeKey(event)
+	if key == "" {
+		return false
+	}
+	if found, _ := es.dedupeCache.ContainsOrAdd(key, true); found {
+		duplicates := es.duplicates.Add(1)
+		log.L(es.ctx).Debugf("Dropped duplicate event %s (stream duplicates=%d)", key, duplicates)
+		return true
+	}
+	return false
+}
+
+// markDelivered records an event in the de-duplication cache, without counting it as a duplicate if it is already there
+func (es *eventStream) markDelivered(event *ffcapi.ListenerEvent) {
+	if key := es.dedupeKey(event); key != "" {
+		es.dedupeCache.Add(key, true)
+	}
+}
+
+// forgetDelivered removes a batch of events that is not dispatched from the de-duplication cache
+func (es *eventStream) forgetDelivered(events ffcapi.ListenerEvents) {
+	for _, event := range events {
+		if key := es.dedupeKey(event); key != "" {
+			es.dedupeCache.Remove(key)
+		}
+	}
+}
+
+func (es *eventStream) dedupeKey(event *ffcapi.ListenerEvent) string {
+	if es.dedupeCache == nil || event.Event == nil || event.Removed {
+		return ""
+	}
+	id := &event.Event.ID
+	return fmt.Sprintf("%s/%s/%s", id.ListenerID, getEventProtoID(int64(id.BlockNumber), int64(id.TransactionIndex), int64(id.LogIndex)), id.BlockHash)
+}
+
 func getEventProtoID(blockNumber, transactionIndex, logIndex int64) string {
 	return fmt.Sprintf("%.12d/%.6d/%.6d", blockNumber, transactionIndex, logIndex)
 }
@@ -512,43 +917,145 @@ func getEventProtoID(blockNumber, transactionIndex, logIndex int64) string {
 func (es *eventStream) filterEnrichSort(ctx context.Context, ag *aggregatedListener, ethLogs []*logJSONRPC) (ffcapi.ListenerEvents, error) {
 	updates := make(ffcapi.ListenerEvents, 0, len(ethLogs))
 	for _, ethLog := range ethLogs {
-		listeners := ag.listenersByTopic0[ethLog.Topics[0].String()]
-		for _, l := range listeners {
-			for _, f := range l.config.filters {
-				lu, matches, err := l.filterEnrichEthLog(ctx, f, l.config.options.Methods, ethLog)
-				if err != nil {
-					return nil, err
-				}
-				if matches {
-					updates = append(updates, lu)
-					break // A single listener cannot emit the event twice
-				}
-			}
+		var err error
+		if updates, err = es.filterEnrichLog(ctx, ag, ethLog, updates); err != nil {
+			return nil, err
 		}
 	}
 	sort.Sort(updates)
 	return updates, nil
 }
 
+// filterEnrichLog appends an event to the updates for each listener in the group that matches the log
+func (es *eventStream) filterEnrichLog(ctx context.Context, ag *aggregatedListener, ethLog *logJSONRPC, updates ffcapi.ListenerEvents) (ffcapi.ListenerEvents, error) {
+	listeners := ag.listenersByTopic0[ethLog.Topics[0].String()]
+	for _, l := range listeners {
+		for _, f := range l.config.filters {
+			lu, matches, err := l.filterEnrichEthLog(ctx, f, l.config.options.Methods, ethLog)
+			if err != nil {
+				return nil, err
+			}
+			if matches {
+				updates = append(updates, lu)
+				break // A single listener cannot emit the event twice
+			}
+		}
+	}
+	return updates, nil
+}
+
+// limitWildcardEvents holds back the events of listeners that match events from all contracts, to the
+// configured rate. It is called once the response of a query is fully read, so the connection to the
+// node and the slots for queries are not held while waiting.
+func (es *eventStream) limitWildcardEvents(ctx context.Context, ag *aggregatedListener, events ffcapi.ListenerEvents) error {
+	var limiters map[fftypes.UUID]*rate.Limiter
+	for _, l := range ag.listeners {
+		if l.wildcardLimiter != nil {
+			if limiters == nil {
+				limiters = make(map[fftypes.UUID]*rate.Limiter)
+			}
+			limiters[*l.id] = l.wildcardLimiter
+		}
+	}
+	if limiters == nil {
+		return nil
+	}
+	for _, e := range events {
+		if limiter := limiters[*e.Event.ID.ListenerID]; limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (es *eventStream) getBlockRangeEvents(ctx context.Context, ag *aggregatedListener, fromBlock, toBlock int64) (ffcapi.ListenerEvents, error) {
+	for {
+		events, err := es.queryBlockRangeEvents(ctx, ag, fromBlock, toBlock)
+		if err != nil || !ag.factories || ag.addressSet == nil {
+			return events, err
+		}
+		// If a factory created child contracts in the range, we query it again to include the events of the children
+		updated := es.buildAggregatedListener(ag.listeners)
+		if len(updated.addressSet) == len(ag.addressSet) {
+			return events, nil
+		}
+		log.L(ctx).Infof("Querying block range fromBlock=%d toBlock=%d again for %d new child contracts", fromBlock, toBlock, len(updated.addressSet)-len(ag.addressSet))
+		ag = updated
+	}
+}
+
+func (es *eventStream) queryBlockRangeEvents(ctx context.Context, ag *aggregatedListener, fromBlock, toBlock int64) (ffcapi.ListenerEvents, error) {
 	var ethLogs []*logJSONRPC
-	logFilterJSONRPCReq := &logFilterJSONRPC{
-		FromBlock: ethtypes.NewHexInteger64(fromBlock),
-		ToBlock:   ethtypes.NewHexInteger64(toBlock),
-		Topics: [][]ethtypes.HexBytes0xPrefix{
-			ag.signatureSet,
-		},
+	logFilters, err := es.blockRangeLogFilters(ctx, ag, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(ag.listeners) == 1 && len(ag.listeners[0].config.filters) == 1 {
-		logFilterJSONRPCReq.Address = ag.listeners[0].config.filters[0].Address
+	// GraphQL is only used for range queries, as it does not support queries pinned by block hash
+	if es.c.graphqlEnabled() && logFilters[0].BlockHash == nil {
+		if ethLogs, err = es.c.getLogsGraphQL(ctx, logFilters[0]); err == nil {
+			ethLogs, err = es.c.sanitizeLogs(ctx, logFilters[0], ethLogs)
+		}
+		if err == nil {
+			es.recordLogIndex(ctx, ag, fromBlock, toBlock, ethLogs)
+			return es.filterEnrichSort(ctx, ag, ethLogs)
+		}
+		log.L(ctx).Warnf("Falling back to JSON/RPC for block range fromBlock=%d toBlock=%d: %s", fromBlock, toBlock, err)
 	}
 
-	rpcErr := es.c.backend.CallRPC(ctx, &ethLogs, "eth_getLogs", logFilterJSONRPCReq)
-	if rpcErr != nil {
-		return nil, rpcErr.Error()
+	// Each log is filtered as it is decoded, so only the matching events are held in memory
+	updates := make(ffcapi.ListenerEvents, 0)
+	var indexLogs []*logJSONRPC
+	decode := func(ethLog *logJSONRPC) (err error) {
+		updates, err = es.filterEnrichLog(ctx, ag, ethLog, updates)
+		return err
+	}
+	prefetcher := es.c.newBlockPrefetcher(ctx, ag, decode)
+	for _, logFilter := range logFilters {
+		sanitizer := es.c.newLogSanitizer(ctx, logFilter, func(ethLog *logJSONRPC) error {
+			if es.c.logIndex != nil && len(ethLog.Topics) > 0 {
+				indexLogs = append(indexLogs, &logJSONRPC{Address: ethLog.Address, BlockNumber: ethLog.BlockNumber, Topics: ethLog.Topics[0:1]})
+			}
+			if prefetcher != nil {
+				return prefetcher.add(ethLog)
+			}
+			return decode(ethLog)
+		})
+		if err = es.c.getLogs(ctx, logFilter, sanitizer.add); err == nil {
+			err = sanitizer.flush()
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err = prefetcher.close(err); err != nil {
+		return nil, err
+	}
+	es.recordLogIndex(ctx, ag, fromBlock, toBlock, indexLogs)
+	sort.Sort(updates)
+	return updates, nil
+}
+
+// recordLogIndex adds the part of a successful block range query that is at least catchupThreshold
+// behind the head of the chain to the log index, if enabled. A query restricted by indexed values does not
+// return every log of its address/signature pairs, so is not recorded - or the index would show the blocks with
+// only the other logs as empty, and a listener added later without those restrictions would skip them.
+func (es *eventStream) recordLogIndex(ctx context.Context, ag *aggregatedListener, fromBlock, toBlock int64, ethLogs []*logJSONRPC) {
+	if es.c.logIndex == nil || ag.addressSet == nil || len(ag.topics) > 1 {
+		return
+	}
+	chainHead, ok := es.c.blockListener.getHighestBlock(ctx)
+	if !ok {
+		return
+	}
+	if toBlock > chainHead-es.c.catchupThreshold {
+		toBlock = chainHead - es.c.catchupThreshold
+	}
+	if toBlock >= fromBlock {
+		es.c.logIndex.record(ag, fromBlock, toBlock, ethLogs)
 	}
-	return es.filterEnrichSort(ctx, ag, ethLogs)
 }
 
 func (es *eventStream) getListenerHWM(ctx context.Context, listenerID *fftypes.UUID) (*ffcapi.EventListenerHWMResponse, ffcapi.ErrorReason, error) {
@@ -558,8 +1065,27 @@ func (es *eventStream) getListenerHWM(ctx context.Context, listenerID *fftypes.U
 	if l == nil {
 		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgListenerNotStarted, listenerID, es.id)
 	}
-	return &ffcapi.EventListenerHWMResponse{
-		Checkpoint: l.getHWMCheckpoint(),
-		Catchup:    l.catchup || es.catchup, // dirty read of whether the listener is in catchup, or the head group of the stream is in catchup
-	}, "", nil
+	// Acknowledges the events dispatched before FFTM previously requested the checkpoint, which it has since delivered
+	es.wal.checkpointRequested(listenerID)
+	res := &ffcapi.EventListenerHWMResponse{
+		Catchup: l.catchup || es.catchup, // dirty read of whether the listener is in catchup, or the head group of the stream is in catchup
+	}
+	// A standby has delivered nothing, so reports no checkpoint. FFTM then keeps the one it has, rather than
+	// overwriting the newer checkpoint of the leader with the position of the standby.
+	if es.c.leaderElection.isLeader() {
+		res.Checkpoint = l.getHWMCheckpoint()
+	}
+	return res, "", nil
+}
+
+// getCatchupBlockRangeEvents queries a block range for a group of catchup listeners, limiting the number
+// of parallel catchup queries across all groups to the configured parallelism
+func (es *eventStream) getCatchupBlockRangeEvents(ctx context.Context, ag *aggregatedListener, fromBlock, toBlock int64) (ffcapi.ListenerEvents, error) {
+	select {
+	case es.c.catchupSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, i18n.NewError(ctx, i18n.MsgContextCanceled)
+	}
+	defer func() { <-es.c.catchupSlots }()
+	return es.getBlockRangeEvents(withRPCPriority(ctx, rpcPriorityBulk), ag, fromBlock, toBlock)
 }
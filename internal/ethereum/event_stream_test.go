@@ -22,6 +22,8 @@ import (
 	"testing"
 	"time"
 
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
 	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
@@ -49,8 +51,8 @@ func testEventStreamExistingConnector(t *testing.T, ctx context.Context, done fu
 	})
 	assert.NoError(t, err)
 	es := c.eventStreams[*esID]
-	es.c.eventFilterPollingInterval = 1 * time.Millisecond
-	es.c.retry.MaximumDelay = 1 * time.Microsecond
+	es.c.tuned().eventFilterPollingInterval = 1 * time.Millisecond
+	es.c.tuned().retry.MaximumDelay = 1 * time.Microsecond
 	assert.NotNil(t, es)
 
 	es.preStartProcessing()
@@ -241,7 +243,7 @@ func TestCatchupThenRejoinLeadGroup(t *testing.T) {
 				},
 				Data: ethtypes.MustNewHexBytes0xPrefix("0x00000000000000000000000000000000000000000000000000000000000003e8"),
 			})
-		case es.c.catchupPageSize + 1000:
+		case es.c.catchupPageSize.Load() + 1000:
 			if !closed {
 				close(listenerCaughtUp)
 				closed = true
@@ -749,15 +751,88 @@ func TestDispatchListenerDone(t *testing.T) {
 	cancel()
 	es := &eventStream{
 		ctx:    doneCtx,
+		c:      &ethConnector{},
 		events: make(chan<- *ffcapi.ListenerEvent),
 	}
-	exiting := es.dispatchSetHWMCheckExit(&aggregatedListener{}, ffcapi.ListenerEvents{
+	exiting, _ := es.dispatchSetHWMCheckExit(&aggregatedListener{}, ffcapi.ListenerEvents{
 		{},
-	}, -1)
+	}, -1, 0)
 	assert.True(t, exiting)
 
 }
 
+func TestDispatchSuppressesDuplicates(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t)
+	defer done()
+
+	dedupeCache, err := lru.New(10)
+	assert.NoError(t, err)
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	es := &eventStream{
+		ctx:         context.Background(),
+		c:           c,
+		events:      events,
+		dedupeCache: dedupeCache,
+	}
+	l1, l2 := fftypes.NewUUID(), fftypes.NewUUID()
+	newEvent := func(listenerID *fftypes.UUID, blockHash string, removed bool) *ffcapi.ListenerEvent {
+		return &ffcapi.ListenerEvent{
+			Event: &ffcapi.Event{ID: ffcapi.EventID{
+				ListenerID:  listenerID,
+				BlockHash:   blockHash,
+				BlockNumber: 100,
+				LogIndex:    1,
+			}},
+			Removed: removed,
+		}
+	}
+	exiting, _ := es.dispatchSetHWMCheckExit(&aggregatedListener{}, ffcapi.ListenerEvents{
+		newEvent(l1, "0x1111", false),
+		newEvent(l1, "0x1111", false), // duplicate
+		newEvent(l2, "0x1111", false), // different listener
+		newEvent(l1, "0x2222", false), // re-org'd into the same position
+		newEvent(l1, "0x1111", true),  // removal is always delivered
+		{BlockEvent: &ffcapi.BlockEvent{}},
+	}, -1, 0)
+	assert.False(t, exiting)
+	exiting, _ = es.dispatchSetHWMCheckExit(&aggregatedListener{}, ffcapi.ListenerEvents{
+		newEvent(l2, "0x1111", false), // duplicate, from an overlapping query
+	}, -1, 0)
+	assert.False(t, exiting)
+	assert.Len(t, events, 5)
+	assert.Equal(t, int64(2), es.duplicates.Load())
+
+	// Disabled
+	es.dedupeCache = nil
+	assert.False(t, es.isDuplicate(newEvent(l1, "0x1111", false)))
+
+}
+
+func TestEventStreamDedupeDisabledByDefault(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	mockStreamLoopEmpty(mRPC)
+	es, _, _, done := testEventStreamExistingConnector(t, ctx, done, c, mRPC)
+	defer done()
+	assert.Nil(t, es.dedupeCache)
+
+}
+
+func TestEventStreamDedupeEnabled(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsDedupeCacheSize, 10)
+	})
+	mockStreamLoopEmpty(mRPC)
+	es, _, _, done := testEventStreamExistingConnector(t, ctx, done, c, mRPC)
+	defer done()
+	assert.NotNil(t, es.dedupeCache)
+	es.duplicates.Store(3)
+	assert.Equal(t, map[string]int64{es.id.String(): 3}, c.getEventDuplicates())
+
+}
+
 func TestGetListenerHWMNotFound(t *testing.T) {
 
 	es := &eventStream{
@@ -770,3 +845,83 @@ func TestGetListenerHWMNotFound(t *testing.T) {
 	assert.Equal(t, ffcapi.ErrorReasonNotFound, rc)
 
 }
+
+func TestOrderedStreamCatchupMergesListeners(t *testing.T) {
+
+	newReq := func(checkpoint *listenerCheckpoint) *ffcapi.EventListenerAddRequest {
+		req := &ffcapi.EventListenerAddRequest{
+			ListenerID: fftypes.NewUUID(),
+			EventListenerOptions: ffcapi.EventListenerOptions{
+				Filters: []fftypes.JSONAny{
+					*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`),
+				},
+				Options:   fftypes.JSONAnyPtr(`{}`),
+				FromBlock: "0",
+			},
+		}
+		if checkpoint != nil {
+			req.Checkpoint = checkpoint
+		}
+		return req
+	}
+	l1req := newReq(nil)
+	l2req := newReq(&listenerCheckpoint{Block: 1000, TransactionIndex: -1, LogIndex: -1})
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsOrdering, EventOrderingStream)
+	})
+	mockStreamLoopEmpty(mRPC)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1024),
+		}
+	}).Maybe()
+	mockLogsFrom := func(fromBlock int64, logs ...*logJSONRPC) {
+		mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+			return f.FromBlock.Int64() == fromBlock
+		})).Return(nil).Run(func(args mock.Arguments) {
+			*args[1].(*[]*logJSONRPC) = logs
+		}).Once()
+	}
+	logAt := func(block int64) *logJSONRPC {
+		ethLog := sampleTransferLog()
+		ethLog.BlockNumber = ethtypes.NewHexInteger64(block)
+		return ethLog
+	}
+	// The lead group catches up both listeners from block 0, dropping the events before the checkpoint of the second
+	mockLogsFrom(0, logAt(10))
+	mockLogsFrom(500, logAt(600))
+	mockLogsFrom(1000, logAt(1024))
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(nil).Maybe()
+
+	es, events, _, done := testEventStreamExistingConnector(t, ctx, done, c, mRPC, l1req, l2req)
+	defer done()
+	for _, l := range es.listeners {
+		assert.False(t, l.catchup)
+	}
+
+	e := <-events
+	assert.Equal(t, l1req.ListenerID, e.Event.ID.ListenerID)
+	assert.Equal(t, uint64(10), e.Event.ID.BlockNumber.Uint64())
+	e = <-events
+	assert.Equal(t, l1req.ListenerID, e.Event.ID.ListenerID)
+	assert.Equal(t, uint64(600), e.Event.ID.BlockNumber.Uint64())
+	received := map[fftypes.UUID]bool{}
+	for i := 0; i < 2; i++ {
+		e = <-events
+		assert.Equal(t, uint64(1024), e.Event.ID.BlockNumber.Uint64())
+		received[*e.Event.ID.ListenerID] = true
+	}
+	assert.True(t, received[*l1req.ListenerID])
+	assert.True(t, received[*l2req.ListenerID])
+
+}
+
+func TestEventOrderingInvalid(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(EventsOrdering, "global")
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23145.*global", err)
+
+}
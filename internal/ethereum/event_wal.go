@@ -0,0 +1,297 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// walEntry is a line of the write-ahead log, holding a dispatched event with its concrete checkpoint type
+type walEntry struct {
+	Checkpoint *listenerCheckpoint `json:"checkpoint"`
+	Event      *ffcapi.Event       `json:"event"`
+}
+
+// eventWAL is the write-ahead log of an event stream. Each batch of events is synced to disk before it is
+// dispatched, and stays in the log until it is acknowledged by FFTM. As the connector is not told when an
+// event is delivered, an acknowledgement is inferred from the checkpoint FFTM supplies when the listener is
+// started, and from FFTM advancing its checkpoint at runtime. FFTM requests the high water mark of a listener
+// when it writes a checkpoint with no batch in flight, so by the time of the next request the events dispatched
+// before the previous one have been delivered and checkpointed. The high water mark we return is itself not an
+// acknowledgement, as it can be ahead of events that are still in-flight.
+//
+// Whatever remains in the log when the stream starts was in-flight when the connector stopped, and is
+// re-delivered ahead of polling the chain. A log that is full fails the append, so the batch is not dispatched
+// and is queried again once FFTM has acknowledged earlier events - rather than discarding events not yet delivered.
+type eventWAL struct {
+	ctx        context.Context
+	mux        sync.Mutex
+	path       string
+	file       *os.File
+	closed     bool
+	maxEntries int
+	entries    []*walEntry
+	dispatched map[fftypes.UUID]*listenerCheckpoint // the latest event dispatched to each listener
+	marked     map[fftypes.UUID]*listenerCheckpoint // the latest event dispatched to each listener when FFTM last requested its checkpoint
+}
+
+func (c *ethConnector) openEventWAL(ctx context.Context, streamID *fftypes.UUID) (*eventWAL, error) {
+	if c.walPath == "" {
+		return nil, nil
+	}
+	w := &eventWAL{
+		ctx:        ctx,
+		path:       filepath.Join(c.walPath, streamID.String()+".wal"),
+		maxEntries: c.walMaxEntries,
+		dispatched: make(map[fftypes.UUID]*listenerCheckpoint),
+		marked:     make(map[fftypes.UUID]*listenerCheckpoint),
+	}
+	if err := os.MkdirAll(c.walPath, 0700); err != nil {
+		return nil, i18n.WrapError(ctx, err, msgs.MsgEventWALFailed, c.walPath)
+	}
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	if err := w.rewrite(); err != nil {
+		return nil, err
+	}
+	if len(w.entries) > 0 {
+		log.L(ctx).Infof("Loaded %d unacknowledged events from write-ahead log '%s'", len(w.entries), w.path)
+	}
+	return w, nil
+}
+
+func (w *eventWAL) load() error {
+	f, err := os.Open(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return i18n.WrapError(w.ctx, err, msgs.MsgEventWALFailed, w.path)
+	}
+	defer f.Close()
+	decoder := json.NewDecoder(f)
+	for {
+		var entry walEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if err != io.EOF {
+				// A crash part way through an append leaves a partial last line, for events that were never dispatched
+				log.L(w.ctx).Warnf("Ignoring incomplete entry at the end of write-ahead log '%s': %s", w.path, err)
+			}
+			return nil
+		}
+		if entry.Event != nil && entry.Event.ID.ListenerID != nil {
+			w.entries = append(w.entries, &entry)
+		}
+	}
+}
+
+// append syncs a batch of events to the log, before it is dispatched. The entries are only added in memory once
+// they are on disk, so a batch that fails is not replayed when it is appended again on retry.
+func (w *eventWAL) append(events ffcapi.ListenerEvents) error {
+	if w == nil || len(events) == 0 {
+		return nil
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if len(w.entries)+len(events) > w.maxEntries {
+		return i18n.NewError(w.ctx, msgs.MsgEventWALFull, w.path, len(w.entries), w.maxEntries)
+	}
+	var buff bytes.Buffer
+	entries := make([]*walEntry, 0, len(events))
+	for _, event := range events {
+		cp, _ := event.Checkpoint.(*listenerCheckpoint)
+		entry := &walEntry{Checkpoint: cp, Event: event.Event}
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buff.Write(b)
+		buff.WriteByte('\n')
+		entries = append(entries, entry)
+	}
+	if w.file == nil {
+		// A failed rewrite leaves the log closed, but with the entries still on disk
+		if w.closed {
+			return i18n.NewError(w.ctx, msgs.MsgEventWALFailed, w.path)
+		}
+		var err error
+		if w.file, err = os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err != nil {
+			return i18n.WrapError(w.ctx, err, msgs.MsgEventWALFailed, w.path)
+		}
+	}
+	if _, err := w.file.Write(buff.Bytes()); err != nil {
+		return i18n.WrapError(w.ctx, err, msgs.MsgEventWALFailed, w.path)
+	}
+	if err := w.file.Sync(); err != nil {
+		return i18n.WrapError(w.ctx, err, msgs.MsgEventWALFailed, w.path)
+	}
+	w.entries = append(w.entries, entries...)
+	return nil
+}
+
+// markDispatched records the position of an event once it is dispatched to FFTM
+func (w *eventWAL) markDispatched(event *ffcapi.ListenerEvent) {
+	if w == nil || event.Event == nil {
+		return
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.dispatched[*event.Event.ID.ListenerID] = eventPosition(event)
+}
+
+// checkpointRequested acknowledges the events of a listener that were dispatched before FFTM last requested its
+// checkpoint, which have since been delivered and checkpointed by FFTM
+func (w *eventWAL) checkpointRequested(listenerID *fftypes.UUID) {
+	if w == nil {
+		return
+	}
+	w.mux.Lock()
+	delivered := w.marked[*listenerID]
+	w.marked[*listenerID] = w.dispatched[*listenerID]
+	w.mux.Unlock()
+	if delivered != nil {
+		w.acknowledge(listenerID, delivered)
+	}
+}
+
+// acknowledge removes the events of a listener up to and including the checkpoint, or all events of the
+// listener if the checkpoint is nil
+func (w *eventWAL) acknowledge(listenerID *fftypes.UUID, checkpoint *listenerCheckpoint) {
+	if w == nil {
+		return
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	remaining := make([]*walEntry, 0, len(w.entries))
+	for _, entry := range w.entries {
+		id := &entry.Event.ID
+		if id.ListenerID.Equals(listenerID) && (checkpoint == nil || !checkpoint.LessThan(&listenerCheckpoint{
+			Block:            int64(id.BlockNumber),
+			TransactionIndex: int64(id.TransactionIndex),
+			LogIndex:         int64(id.LogIndex),
+		})) {
+			continue
+		}
+		remaining = append(remaining, entry)
+	}
+	if len(remaining) == len(w.entries) {
+		return
+	}
+	if checkpoint == nil {
+		delete(w.dispatched, *listenerID)
+		delete(w.marked, *listenerID)
+	}
+	log.L(w.ctx).Debugf("Acknowledged %d events of listener %s in write-ahead log", len(w.entries)-len(remaining), listenerID)
+	w.entries = remaining
+	if err := w.rewrite(); err != nil {
+		// The events are acknowledged in memory, and will be removed from disk by the next rewrite
+		log.L(w.ctx).Errorf("Failed to compact write-ahead log: %s", err)
+	}
+}
+
+// pending returns the events that have not been acknowledged
+func (w *eventWAL) pending() ffcapi.ListenerEvents {
+	if w == nil {
+		return nil
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	events := make(ffcapi.ListenerEvents, len(w.entries))
+	for i, entry := range w.entries {
+		events[i] = &ffcapi.ListenerEvent{Checkpoint: entry.Checkpoint, Event: entry.Event}
+	}
+	return events
+}
+
+// rewrite atomically replaces the log with the current entries, and re-opens it for appending. The new log, and
+// the directory holding it, are synced before it is used - so a crash cannot leave the log empty or missing.
+func (w *eventWAL) rewrite() error {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	var buff bytes.Buffer
+	encoder := json.NewEncoder(&buff)
+	for _, entry := range w.entries {
+		_ = encoder.Encode(entry) // marshalled previously without error
+	}
+	tmpPath := w.path + ".tmp"
+	err := writeFileSync(tmpPath, buff.Bytes())
+	if err == nil {
+		err = os.Rename(tmpPath, w.path)
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(w.path))
+	}
+	if err == nil {
+		w.file, err = os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0600)
+	}
+	if err != nil {
+		return i18n.WrapError(w.ctx, err, msgs.MsgEventWALFailed, w.path)
+	}
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (w *eventWAL) close() {
+	if w == nil {
+		return
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.closed = true
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func testWALEvent(listenerID *fftypes.UUID, block, txIndex, logIndex int64) *ffcapi.ListenerEvent {
+	return &ffcapi.ListenerEvent{
+		Checkpoint: &listenerCheckpoint{Block: block, TransactionIndex: txIndex, LogIndex: logIndex},
+		Event: &ffcapi.Event{
+			ID: ffcapi.EventID{
+				ListenerID:       listenerID,
+				BlockHash:        testBlockHash(block),
+				BlockNumber:      fftypes.FFuint64(block),
+				TransactionIndex: fftypes.FFuint64(txIndex),
+				LogIndex:         fftypes.FFuint64(logIndex),
+			},
+			Info: map[string]interface{}{"address": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431"},
+			Data: fftypes.JSONAnyPtr(`{"value":"1000"}`),
+		},
+	}
+}
+
+func TestEventWALAcknowledgeAndReload(t *testing.T) {
+
+	walDir := t.TempDir()
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsWALPath, walDir)
+		conf.Set(EventsWALMaxEntries, 100)
+	})
+	defer done()
+
+	streamID := fftypes.NewUUID()
+	l1, l2 := fftypes.NewUUID(), fftypes.NewUUID()
+	w, err := c.openEventWAL(ctx, streamID)
+	assert.NoError(t, err)
+	assert.Empty(t, w.pending())
+
+	assert.NoError(t, w.append(ffcapi.ListenerEvents{
+		testWALEvent(l1, 1000, 1, 0),
+		testWALEvent(l2, 1000, 2, 0),
+		testWALEvent(l1, 1001, 0, 5),
+	}))
+	assert.NoError(t, w.append(ffcapi.ListenerEvents{}))
+
+	// Acknowledges the first event of l1, but not the second
+	w.acknowledge(l1, &listenerCheckpoint{Block: 1000, TransactionIndex: 1, LogIndex: 0})
+	w.acknowledge(l1, &listenerCheckpoint{Block: 1000, TransactionIndex: 1, LogIndex: 0})
+	w.close()
+
+	// Simulate a crash part way through an append
+	f, err := os.OpenFile(filepath.Join(walDir, streamID.String()+".wal"), os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"checkpoint":{"blo`)
+	assert.NoError(t, err)
+	f.Close()
+
+	w, err = c.openEventWAL(ctx, streamID)
+	assert.NoError(t, err)
+	defer w.close()
+	pending := w.pending()
+	assert.Len(t, pending, 2)
+	assert.Equal(t, l2, pending[0].Event.ID.ListenerID)
+	assert.Equal(t, int64(1001), pending[1].Checkpoint.(*listenerCheckpoint).Block)
+	assert.JSONEq(t, `{"value":"1000"}`, pending[1].Event.Data.String())
+
+	w.acknowledge(l2, nil)
+	assert.Len(t, w.pending(), 1)
+
+}
+
+func TestEventWALMaxEntries(t *testing.T) {
+
+	walDir := t.TempDir()
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsWALPath, walDir)
+		conf.Set(EventsWALMaxEntries, 2)
+	})
+	defer done()
+
+	lID := fftypes.NewUUID()
+	w, err := c.openEventWAL(ctx, fftypes.NewUUID())
+	assert.NoError(t, err)
+	defer w.close()
+
+	// Events not yet acknowledged are never discarded to make room
+	err = w.append(ffcapi.ListenerEvents{
+		testWALEvent(lID, 1000, 0, 0),
+		testWALEvent(lID, 1001, 0, 0),
+		testWALEvent(lID, 1002, 0, 0),
+	})
+	assert.Regexp(t, "FF23200", err)
+	assert.Empty(t, w.pending())
+
+	assert.NoError(t, w.append(ffcapi.ListenerEvents{
+		testWALEvent(lID, 1000, 0, 0),
+		testWALEvent(lID, 1001, 0, 0),
+	}))
+	assert.Regexp(t, "FF23200", w.append(ffcapi.ListenerEvents{testWALEvent(lID, 1002, 0, 0)}))
+
+	w.acknowledge(lID, &listenerCheckpoint{Block: 1000})
+	assert.NoError(t, w.append(ffcapi.ListenerEvents{testWALEvent(lID, 1002, 0, 0)}))
+	pending := w.pending()
+	assert.Len(t, pending, 2)
+	assert.Equal(t, fftypes.FFuint64(1001), pending[0].Event.ID.BlockNumber)
+
+}
+
+func TestEventWALAppendFailRetry(t *testing.T) {
+
+	walDir := t.TempDir()
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsWALPath, walDir)
+	})
+	defer done()
+
+	streamID, lID := fftypes.NewUUID(), fftypes.NewUUID()
+	w, err := c.openEventWAL(ctx, streamID)
+	assert.NoError(t, err)
+
+	// A failed write does not add the batch, so it is not duplicated when it is appended again
+	w.file.Close()
+	err = w.append(ffcapi.ListenerEvents{testWALEvent(lID, 1000, 0, 0)})
+	assert.Regexp(t, "FF23150", err)
+	assert.Empty(t, w.pending())
+
+	// A log left closed by a failed rewrite is re-opened
+	w.file = nil
+	assert.NoError(t, w.append(ffcapi.ListenerEvents{testWALEvent(lID, 1000, 0, 0)}))
+	assert.Len(t, w.pending(), 1)
+
+	w.close()
+	w, err = c.openEventWAL(ctx, streamID)
+	assert.NoError(t, err)
+	defer w.close()
+	assert.Len(t, w.pending(), 1)
+
+}
+
+func TestEventWALCheckpointRequested(t *testing.T) {
+
+	walDir := t.TempDir()
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsWALPath, walDir)
+	})
+	defer done()
+
+	lID := fftypes.NewUUID()
+	w, err := c.openEventWAL(ctx, fftypes.NewUUID())
+	assert.NoError(t, err)
+	defer w.close()
+
+	e1, e2 := testWALEvent(lID, 1000, 0, 0), testWALEvent(lID, 1001, 0, 0)
+	assert.NoError(t, w.append(ffcapi.ListenerEvents{e1, e2}))
+	w.markDispatched(e1)
+
+	// The first request only marks the events dispatched so far, as they might still be in-flight
+	w.checkpointRequested(lID)
+	assert.Len(t, w.pending(), 2)
+	w.markDispatched(e2)
+
+	// By the next request FFTM has delivered them
+	w.checkpointRequested(lID)
+	pending := w.pending()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, fftypes.FFuint64(1001), pending[0].Event.ID.BlockNumber)
+	w.checkpointRequested(lID)
+	assert.Empty(t, w.pending())
+
+}
+
+func TestEventWALOpenFail(t *testing.T) {
+
+	walDir := t.TempDir()
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsWALPath, walDir)
+		conf.Set(EventsWALMaxEntries, 100)
+	})
+	defer done()
+
+	c.walPath = filepath.Join(walDir, "file")
+	assert.NoError(t, os.WriteFile(c.walPath, []byte{}, 0600))
+	_, err := c.openEventWAL(ctx, fftypes.NewUUID())
+	assert.Regexp(t, "FF23150", err)
+
+	streamID := fftypes.NewUUID()
+	c.walPath = walDir
+	assert.NoError(t, os.Mkdir(filepath.Join(walDir, streamID.String()+".wal"), 0700))
+	_, err = c.openEventWAL(ctx, streamID)
+	assert.Regexp(t, "FF23150", err)
+
+}
+
+func TestEventWALDisabled(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	w, err := c.openEventWAL(ctx, fftypes.NewUUID())
+	assert.NoError(t, err)
+	assert.Nil(t, w)
+	assert.NoError(t, w.append(ffcapi.ListenerEvents{testWALEvent(fftypes.NewUUID(), 1000, 0, 0)}))
+	w.acknowledge(fftypes.NewUUID(), nil)
+	w.markDispatched(testWALEvent(fftypes.NewUUID(), 1000, 0, 0))
+	w.checkpointRequested(fftypes.NewUUID())
+	assert.Empty(t, w.pending())
+	w.close()
+
+}
+
+func TestEventStreamReplayWAL(t *testing.T) {
+
+	walDir := t.TempDir()
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsWALPath, walDir)
+		conf.Set(EventsWALMaxEntries, 100)
+	})
+	defer done()
+
+	streamID := fftypes.NewUUID()
+	lID, removedID := fftypes.NewUUID(), fftypes.NewUUID()
+	w, err := c.openEventWAL(ctx, streamID)
+	assert.NoError(t, err)
+	defer w.close()
+	assert.NoError(t, w.append(ffcapi.ListenerEvents{
+		testWALEvent(removedID, 1000, 0, 0),
+		testWALEvent(lID, 1000, 1, 0),
+	}))
+
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	es := &eventStream{
+		id:        streamID,
+		ctx:       ctx,
+		c:         c,
+		events:    events,
+		listeners: map[fftypes.UUID]*listener{*lID: {id: lID}},
+		wal:       w,
+	}
+	es.dedupeCache, _ = lru.New(100)
+
+	assert.False(t, es.replayWAL())
+	assert.Len(t, events, 1)
+	assert.Equal(t, lID, (<-events).Event.ID.ListenerID)
+	assert.Len(t, w.pending(), 1)
+
+	// Re-detecting the event once replayed does not deliver it again
+	batch, _, err := es.filterBatch(nil, ffcapi.ListenerEvents{testWALEvent(lID, 1000, 1, 0)})
+	assert.NoError(t, err)
+	assert.Empty(t, batch)
+	batch, _, err = es.filterBatch(nil, ffcapi.ListenerEvents{testWALEvent(lID, 1001, 0, 0)})
+	assert.NoError(t, err)
+	assert.Len(t, batch, 1)
+	assert.Len(t, w.pending(), 2)
+
+	// Fails to write once closed, so the event is not dispatched, and is not treated as a duplicate on retry
+	w.close()
+	_, _, err = es.filterBatch(nil, ffcapi.ListenerEvents{testWALEvent(lID, 1002, 0, 0)})
+	assert.Regexp(t, "FF23150", err)
+	assert.False(t, es.dedupeCache.Contains(es.dedupeKey(testWALEvent(lID, 1002, 0, 0))))
+	exiting, err := es.dispatchSetHWMCheckExit(&aggregatedListener{listeners: []*listener{es.listeners[*lID]}}, ffcapi.ListenerEvents{testWALEvent(lID, 1002, 0, 0)}, 1003, 0)
+	assert.False(t, exiting)
+	assert.Regexp(t, "FF23150", err)
+	assert.True(t, es.rescan)
+	assert.Empty(t, events)
+	assert.Equal(t, int64(0), es.listeners[*lID].hwmBlock)
+
+	// Exits if the stream stops during replay
+	es.events = make(chan *ffcapi.ListenerEvent)
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	es.ctx = cancelledCtx
+	assert.True(t, es.replayWAL())
+
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
@@ -48,7 +49,10 @@ var (
 	defaultErrorID = defaultError.FunctionSelectorBytes()
 )
 
-func (c *ethConnector) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (*ffcapi.QueryInvokeResponse, ffcapi.ErrorReason, error) {
+func (c *ethConnector) QueryInvoke(ctx context.Context, req *ffcapi.QueryInvokeRequest) (_ *ffcapi.QueryInvokeResponse, _ ffcapi.ErrorReason, err error) {
+	ctx, span := c.startSpan(ctx, "QueryInvoke")
+	defer func() { endSpan(span, err) }()
+
 	// Parse the input JSON data, to build the call data
 	callData, method, err := c.prepareCallData(ctx, &req.TransactionInput)
 	if err != nil {
@@ -103,17 +107,21 @@ func (c *ethConnector) callTransaction(ctx context.Context, tx *ethsigner.Transa
 
 	// Do the raw call
 	var outputData ethtypes.HexBytes0xPrefix
-	blockNumberStr := "latest"
-	if blockNumber != nil {
-		blockNumberStr = *blockNumber
+	if blockNumber == nil && c.pendingState {
+		pending := "pending"
+		blockNumber = &pending
+	}
+	block, err := callBlockParam(ctx, blockNumber)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
 	}
-	rpcErr := c.backend.CallRPC(ctx, &outputData, "eth_call", tx, blockNumberStr)
+	rpcErr := c.backend.CallRPC(ctx, &outputData, "eth_call", tx, block)
 	if rpcErr != nil {
 		if reason, revertErr := c.attemptProcessingRevertData(ctx, errors, rpcErr); revertErr != nil {
 			return nil, reason, revertErr
 		}
 
-		reason := mapError(callRPCMethods, rpcErr.Error())
+		reason := c.mapRPCError(callRPCMethods, rpcErr)
 		err := rpcErr.Error()
 		if reason == ffcapi.ErrorReasonTransactionReverted {
 			err = i18n.NewError(ctx, msgs.MsgReverted, rpcErr.Error())
@@ -143,6 +151,9 @@ func (c *ethConnector) callTransaction(ctx context.Context, tx *ethsigner.Transa
 	var jsonData []byte
 	outputValueTree, err := method.Outputs.DecodeABIDataCtx(ctx, outputData, 0)
 	if err == nil {
+		if c.checksumAddresses {
+			checksumABIAddresses(outputValueTree)
+		}
 		// Serialize down to JSON, and wrap in a JSONAny
 		jsonData, err = c.serializer.SerializeJSONCtx(ctx, outputValueTree)
 	}
@@ -153,6 +164,33 @@ func (c *ethConnector) callTransaction(ctx context.Context, tx *ethsigner.Transa
 	return fftypes.JSONAnyPtrBytes(jsonData), "", nil
 }
 
+// blockHashParam is the EIP-1898 form of a block parameter, for querying the state at a specific block hash.
+// A canonical block is required, so that a query pinned to a block that has been re-org'd out fails.
+type blockHashParam struct {
+	BlockHash        ethtypes.HexBytes0xPrefix `json:"blockHash"`
+	RequireCanonical bool                      `json:"requireCanonical"`
+}
+
+// callBlockParam pins a call to the state at a block number (decimal or hex), a block hash, or a tag such
+// as "finalized" or "safe" - defaulting to "latest"
+func callBlockParam(ctx context.Context, blockNumber *string) (interface{}, error) {
+	if blockNumber == nil {
+		return "latest", nil
+	}
+	if strings.HasPrefix(*blockNumber, "0x") && len(*blockNumber) == 66 {
+		blockHash, err := ethtypes.NewHexBytes0xPrefix(*blockNumber)
+		if err != nil {
+			return nil, i18n.NewError(ctx, msgs.MsgInvalidCallBlock, *blockNumber)
+		}
+		return &blockHashParam{BlockHash: blockHash, RequireCanonical: true}, nil
+	}
+	block, ok := blockNumberOrTag(*blockNumber)
+	if !ok {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidCallBlock, *blockNumber)
+	}
+	return block, nil
+}
+
 // processRevertReason returns under 3 different circumstances:
 // 1. non-empty string - parsed by us: valid reason has been successfully parsed
 // 2. non-empty string - assumed to already be parsed by node: error detail was present but failed to parse, string was raw data
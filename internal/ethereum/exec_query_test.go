@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
@@ -122,6 +123,38 @@ func TestExecQueryOKResponse(t *testing.T) {
 
 }
 
+func TestExecQueryPendingState(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(PendingState, true)
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything, "pending").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x00000000000000000000000000000000000000000000000000000000baadf00d0000000000000000000000000000000000000000000000000000000000000040000000000000000000000000000000000000000000000000000000000000000b68656c6c6f20776f726c64000000000000000000000000000000000000000000")
+		}).
+		Return(nil).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+
+	var req ffcapi.QueryInvokeRequest
+	err := json.Unmarshal([]byte(sampleExecQuery), &req)
+	assert.NoError(t, err)
+
+	res, _, err := c.QueryInvoke(ctx, &req)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"output": "3131961357", "output1":"hello world"}`, res.Outputs.String())
+
+	// A block requested on the query takes precedence
+	req.BlockNumber = strPtr("latest")
+	_, _, err = c.QueryInvoke(ctx, &req)
+	assert.Regexp(t, "pop", err)
+
+	mRPC.AssertExpectations(t)
+
+}
+
 func TestExecQueryOKNilResponse(t *testing.T) {
 
 	ctx, c, mRPC, done := newTestConnector(t)
@@ -419,3 +452,52 @@ func TestExecQueryFailBadToParams(t *testing.T) {
 	assert.Regexp(t, "FF22037", err)
 
 }
+
+func TestExecQueryBlockParams(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	blockHash := "0x6b012339fbb85b70c58ecfd97b31950c4a28bcef5226e12dbe551cb1abaf3b4c"
+	for input, expected := range map[string]interface{}{
+		"finalized": "finalized",
+		"safe":      "safe",
+		"1024":      "0x400",
+		blockHash: &blockHashParam{
+			BlockHash:        ethtypes.MustNewHexBytes0xPrefix(blockHash),
+			RequireCanonical: true,
+		},
+	} {
+		mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything, expected).Return(nil).Once()
+
+		var req ffcapi.QueryInvokeRequest
+		err := json.Unmarshal([]byte(sampleExecQuery), &req)
+		assert.NoError(t, err)
+		req.BlockNumber = strPtr(input)
+		_, _, err = c.QueryInvoke(ctx, &req)
+		assert.NoError(t, err)
+	}
+	mRPC.AssertExpectations(t)
+
+	b, err := json.Marshal(&blockHashParam{BlockHash: ethtypes.MustNewHexBytes0xPrefix(blockHash), RequireCanonical: true})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"blockHash":"`+blockHash+`","requireCanonical":true}`, string(b))
+
+}
+
+func TestExecQueryBadBlockParam(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	for _, input := range []string{"wrong", "-1", "0x6b012339fbb85b70c58ecfd97b31950c4a28bcef5226e12dbe551cb1abaf3bzz"} {
+		var req ffcapi.QueryInvokeRequest
+		err := json.Unmarshal([]byte(sampleExecQuery), &req)
+		assert.NoError(t, err)
+		req.BlockNumber = strPtr(input)
+		_, reason, err := c.QueryInvoke(ctx, &req)
+		assert.Regexp(t, "FF23106", err)
+		assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+	}
+
+}
@@ -0,0 +1,56 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Extensions are the operations of the connector that are not part of the ffcapi.API of the transaction
+// manager. They are called directly by services embedding the connector, and served over HTTP by the
+// extensions API server when it is enabled.
+type Extensions interface {
+	TransactionReplace(ctx context.Context, req *TransactionReplaceRequest) (*TransactionReplaceResponse, ffcapi.ErrorReason, error)
+	NewBlockInfoListener(ctx context.Context, req *NewBlockInfoListenerRequest) (*NewBlockInfoListenerResponse, ffcapi.ErrorReason, error)
+	TransactionSendRaw(ctx context.Context, req *TransactionSendRawRequest) (*TransactionSendRawResponse, ffcapi.ErrorReason, error)
+	FeeHistory(ctx context.Context, req *FeeHistoryRequest) (*FeeHistoryResponse, ffcapi.ErrorReason, error)
+	EventListenerUpdateAddresses(ctx context.Context, req *EventListenerAddressesRequest) (*EventListenerAddressesResponse, ffcapi.ErrorReason, error)
+	NewReceiptListener(ctx context.Context, req *ReceiptListenerRequest) (*ReceiptListenerResponse, ffcapi.ErrorReason, error)
+	ReceiptWatch(ctx context.Context, req *ReceiptWatchRequest) (*ReceiptWatchResponse, ffcapi.ErrorReason, error)
+	CanonicalChain(ctx context.Context, req *CanonicalChainRequest) (*CanonicalChainResponse, ffcapi.ErrorReason, error)
+	Proof(ctx context.Context, req *ProofRequest) (*ProofResponse, ffcapi.ErrorReason, error)
+	TxPool(ctx context.Context, req *TxPoolRequest) (*TxPoolResponse, ffcapi.ErrorReason, error)
+	DeployContracts(ctx context.Context, req *DeployContractsRequest) (*DeployContractsResponse, ffcapi.ErrorReason, error)
+	AcknowledgeReorg(ctx context.Context, req *AcknowledgeReorgRequest) (*AcknowledgeReorgResponse, ffcapi.ErrorReason, error)
+	TransactionByNonce(ctx context.Context, req *TransactionByNonceRequest) (*TransactionByNonceResponse, ffcapi.ErrorReason, error)
+	NewStorageWatcher(ctx context.Context, req *StorageWatchRequest) (*StorageWatchResponse, ffcapi.ErrorReason, error)
+	NewAddressActivityListener(ctx context.Context, req *AddressActivityListenerRequest) (*AddressActivityListenerResponse, ffcapi.ErrorReason, error)
+	NodeDiagnostics(ctx context.Context, req *NodeDiagnosticsRequest) (*NodeDiagnosticsResponse, ffcapi.ErrorReason, error)
+	EventStreamPause(ctx context.Context, req *EventStreamPauseRequest) (*EventStreamPauseResponse, ffcapi.ErrorReason, error)
+	EventStreamResume(ctx context.Context, req *EventStreamResumeRequest) (*EventStreamPauseResponse, ffcapi.ErrorReason, error)
+	EncodeCallData(ctx context.Context, req *EncodeCallDataRequest) (*EncodeCallDataResponse, ffcapi.ErrorReason, error)
+	DecodeCallData(ctx context.Context, req *DecodeCallDataRequest) (*DecodedCallData, ffcapi.ErrorReason, error)
+	PrivateTransactionStatus(ctx context.Context, req *PrivateTransactionStatusRequest) (*PrivateTransactionStatusResponse, ffcapi.ErrorReason, error)
+	DependentTransactionSend(ctx context.Context, req *DependentTransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error)
+	ReorgStatistics(ctx context.Context, req *ReorgStatisticsRequest) (*ReorgStatisticsResponse, ffcapi.ErrorReason, error)
+	EventFilterDryRun(ctx context.Context, req *EventFilterDryRunRequest) (*EventFilterDryRunResponse, ffcapi.ErrorReason, error)
+	TransactionConfirmations(ctx context.Context, req *TransactionConfirmationsRequest) (*TransactionConfirmationsResponse, ffcapi.ErrorReason, error)
+}
+
+var _ Extensions = &ethConnector{}
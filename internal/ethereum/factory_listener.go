@@ -0,0 +1,104 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// factoryOptions configures a listener to discover child contracts from the creation events of a factory contract.
+// The filters of the listener that do not specify an address are then matched against each child contract,
+// from the block in which it was created.
+type factoryOptions struct {
+	Address    *ethtypes.Address0xHex `json:"address"`    // The address of the factory contract
+	Event      *abi.Entry             `json:"event"`      // The ABI of the event emitted by the factory when it creates a child contract
+	ChildField string                 `json:"childField"` // The name of the address parameter of the event that holds the child contract address
+}
+
+// factoryFilter is the additional filter a factory listener has for the creation events of the factory
+type factoryFilter struct {
+	*eventFilter
+	childIndex int
+}
+
+func newFactoryFilter(ctx context.Context, fo *factoryOptions) (*factoryFilter, error) {
+	if fo.Address == nil || fo.Event == nil {
+		return nil, i18n.NewError(ctx, msgs.MsgBadFactoryOptions)
+	}
+	topic0, err := fo.Event.SignatureHashCtx(ctx)
+	if err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidEventFilter, err)
+	}
+	for i, p := range fo.Event.Inputs {
+		if p.Name == fo.ChildField && p.Type == "address" {
+			return &factoryFilter{
+				eventFilter: &eventFilter{
+					Event:     fo.Event,
+					Address:   fo.Address,
+					Topic0:    topic0,
+					Signature: fo.Event.String(),
+				},
+				childIndex: i,
+			}, nil
+		}
+	}
+	return nil, i18n.NewError(ctx, msgs.MsgBadFactoryChildField, fo.Event.Name, fo.ChildField)
+}
+
+// listenerSignature includes the factory in the signature of the listener, as the same child events
+// might be listened to for the children of different factories
+func (ff *factoryFilter) listenerSignature(childSignature string) string {
+	return "factory(" + ff.Address.String() + ":" + ff.Signature + ")/" + childSignature
+}
+
+// addFactoryChild extracts the child contract address from a creation event, and adds it to the addresses of
+// the listener. The stream re-derives its filters, so the events of the child are queried from this block.
+func (l *listener) addFactoryChild(ctx context.Context, ethLog *logJSONRPC) {
+	v, err := l.factory.Event.DecodeEventDataCtx(ctx, ethLog.Topics, ethLog.Data)
+	if err != nil {
+		log.L(ctx).Warnf("Listener %s failed to decode factory event in block %d: %s", l.id, ethLog.BlockNumber.BigInt().Int64(), err)
+		return
+	}
+	child := &ethtypes.Address0xHex{}
+	v.Children[l.factory.childIndex].Value.(*big.Int).FillBytes(child[:])
+
+	l.es.mux.Lock()
+	defer l.es.mux.Unlock()
+	la := l.addresses.Load()
+	if la.set[*child] {
+		return
+	}
+	l.addresses.Store(la.update([]*ethtypes.Address0xHex{child}, nil))
+	l.es.updateCount++
+	log.L(ctx).Infof("Listener '%s' discovered child contract %s of factory %s in block %d", l.id, child, l.factory.Address, ethLog.BlockNumber.BigInt().Int64())
+}
+
+// factoryCheckpointAddresses returns the child contracts to store in the checkpoints of a factory listener,
+// so they are restored when the listener restarts
+func (l *listener) factoryCheckpointAddresses() []*ethtypes.Address0xHex {
+	if l.factory == nil {
+		return nil
+	}
+	return l.addresses.Load().list
+}
@@ -0,0 +1,181 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const abiPairCreatedEvent = `{
+	"type": "event",
+	"name": "PairCreated",
+	"inputs": [
+		{"name": "token", "type": "address", "indexed": true},
+		{"name": "pair", "type": "address"}
+	]
+}`
+
+func testFactoryOptions(extra string) string {
+	return `{"factory":{"address":"` + testAddress3 + `","event":` + abiPairCreatedEvent + `,"childField":"pair"}` + extra + `}`
+}
+
+func samplePairCreatedLog(t *testing.T) *logJSONRPC {
+	var e abi.Entry
+	err := json.Unmarshal([]byte(abiPairCreatedEvent), &e)
+	assert.NoError(t, err)
+	data, err := abi.ParameterArray{{Type: "address"}}.EncodeABIDataValues([]interface{}{testAddress1})
+	assert.NoError(t, err)
+	ethLog := sampleTransferLog()
+	ethLog.Address = ethtypes.MustNewAddress(testAddress3)
+	ethLog.LogIndex = ethtypes.NewHexInteger64(1)
+	ethLog.Topics = []ethtypes.HexBytes0xPrefix{
+		e.SignatureHashBytes(),
+		ethtypes.MustNewHexBytes0xPrefix("0x0000000000000000000000003968ef051b422d3d1cdc182a88bba8dd922e6fa4"),
+	}
+	ethLog.Data = data
+	return ethLog
+}
+
+func TestFactoryListenerDiscoversChildren(t *testing.T) {
+
+	l1req := testAddressesListenerReq(testFactoryOptions(""))
+	es, _, mRPC, done := testEventStream(t, l1req)
+	done() // stop it so we can safely call the listener directly
+	es.ctx = context.Background()
+	es.c.eventBlockTimestamps = false
+
+	l := es.listeners[*l1req.ListenerID]
+	assert.Equal(t, "factory("+testAddress3+":PairCreated(address,address))/*:Transfer(address,address,uint256)", l.config.signature)
+	l.hwmBlock = 0
+	ag := es.buildAggregatedListener([]*listener{l})
+	assert.Equal(t, logFilterAddresses{ethtypes.MustNewAddress(testAddress3)}, ag.addressSet)
+
+	// The first query only includes the factory, and the second includes the child it created
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		return len(f.Address) == 1
+	})).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{samplePairCreatedLog(t)}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		return len(f.Address) == 2
+	})).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{samplePairCreatedLog(t), sampleTransferLog()}
+	}).Once()
+
+	events, err := es.getBlockRangeEvents(context.Background(), ag, 1000, 1100)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "PairCreated", events[0].Event.ID.Signature[0:11])
+	assert.Equal(t, int64(2), events[1].Checkpoint.(*listenerCheckpoint).LogIndex)
+	children := []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testAddress1)}
+	assert.Equal(t, children, events[1].Checkpoint.(*listenerCheckpoint).Addresses)
+	assert.Equal(t, children, l.getHWMCheckpoint().Addresses)
+
+	// Discovering the same child again is a no-op
+	es.mux.Lock()
+	updateCount := es.updateCount
+	es.mux.Unlock()
+	l.addFactoryChild(context.Background(), samplePairCreatedLog(t))
+	es.mux.Lock()
+	assert.Equal(t, updateCount, es.updateCount)
+	es.mux.Unlock()
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestFactoryListenerRestoreFromCheckpoint(t *testing.T) {
+
+	l1req := testAddressesListenerReq(testFactoryOptions(`,"addresses":["` + testAddress2 + `"]`))
+	l1req.Checkpoint = &listenerCheckpoint{
+		Block:     testHighBlock,
+		Addresses: []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testAddress1), ethtypes.MustNewAddress(testAddress2)},
+	}
+	l2req := testAddressesListenerReq(testFactoryOptions(""))
+	es, _, _, done := testEventStream(t, l1req, l2req)
+	defer done()
+
+	l1 := es.listeners[*l1req.ListenerID]
+	assert.Equal(t, []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testAddress2), ethtypes.MustNewAddress(testAddress1)}, l1.getHWMCheckpoint().Addresses)
+
+	// With no children yet, the child filters match nothing
+	l2 := es.listeners[*l2req.ListenerID]
+	assert.False(t, l2.matchesAddress(l2.config.filters[0], sampleTransferLog()))
+	assert.Empty(t, l2.getHWMCheckpoint().Addresses)
+
+	// Children can be removed from a factory listener, leaving none
+	res, _, err := es.c.EventListenerUpdateAddresses(context.Background(), &EventListenerAddressesRequest{
+		StreamID:   es.id,
+		ListenerID: l1req.ListenerID,
+		Remove:     []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testAddress1), ethtypes.MustNewAddress(testAddress2)},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, res.Addresses)
+
+}
+
+func TestFactoryListenerBadDecode(t *testing.T) {
+
+	l1req := testAddressesListenerReq(testFactoryOptions(""))
+	es, _, _, done := testEventStream(t, l1req)
+	defer done()
+
+	l := es.listeners[*l1req.ListenerID]
+	ethLog := samplePairCreatedLog(t)
+	ethLog.Data = nil
+	l.addFactoryChild(context.Background(), ethLog)
+	assert.Empty(t, l.addresses.Load().list)
+
+}
+
+func TestFactoryListenerVerifyOptions(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	req := &ffcapi.EventListenerVerifyOptionsRequest{
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`)},
+			Options: fftypes.JSONAnyPtr(testFactoryOptions("")),
+		},
+	}
+	res, _, err := c.EventListenerVerifyOptions(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "factory("+testAddress3+":PairCreated(address,address))/*:Transfer(address,address,uint256)", res.ResolvedSignature)
+
+	req.Options = fftypes.JSONAnyPtr(`{"factory":{"event":` + abiPairCreatedEvent + `,"childField":"pair"}}`)
+	_, _, err = c.EventListenerVerifyOptions(ctx, req)
+	assert.Regexp(t, "FF23087", err)
+
+	req.Options = fftypes.JSONAnyPtr(`{"factory":{"address":"` + testAddress3 + `","event":` + abiPairCreatedEvent + `,"childField":"wrong"}}`)
+	_, _, err = c.EventListenerVerifyOptions(ctx, req)
+	assert.Regexp(t, "FF23088", err)
+
+	req.Options = fftypes.JSONAnyPtr(`{"factory":{"address":"` + testAddress3 + `","event":{"name":"bad","inputs":[{"name":"pair","type":"wrong"}]},"childField":"pair"}}`)
+	_, _, err = c.EventListenerVerifyOptions(ctx, req)
+	assert.Regexp(t, "FF23036", err)
+
+}
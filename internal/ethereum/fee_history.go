@@ -0,0 +1,121 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// maxFeeHistoryBlocks is the most blocks a node will return in a single eth_feeHistory call
+const maxFeeHistoryBlocks = 1024
+
+type FeeHistoryRequest struct {
+	BlockCount        int       `json:"blockCount"`                  // the number of blocks to return, up to 1024
+	NewestBlock       string    `json:"newestBlock,omitempty"`       // a block number, or a tag such as "pending" - defaults to "latest"
+	RewardPercentiles []float64 `json:"rewardPercentiles,omitempty"` // increasing percentiles of the priority fees paid in each block to return
+}
+
+type FeeHistoryResponse struct {
+	OldestBlock   *fftypes.FFBigInt     `json:"oldestBlock"`
+	BaseFeePerGas []*fftypes.FFBigInt   `json:"baseFeePerGas"` // includes the base fee of the block after the newest block
+	GasUsedRatio  []float64             `json:"gasUsedRatio"`
+	Reward        [][]*fftypes.FFBigInt `json:"reward,omitempty"` // per block, the priority fee at each of the requested percentiles
+}
+
+type feeHistoryJSONRPC struct {
+	OldestBlock   *ethtypes.HexInteger     `json:"oldestBlock"`
+	BaseFeePerGas []*ethtypes.HexInteger   `json:"baseFeePerGas"`
+	GasUsedRatio  []float64                `json:"gasUsedRatio"`
+	Reward        [][]*ethtypes.HexInteger `json:"reward"`
+}
+
+// FeeHistory returns the eth_feeHistory of the node with all values in decimal, so that fee policies can
+// implement their own strategies using the base fee trend and the priority fees paid in recent blocks.
+func (c *ethConnector) FeeHistory(ctx context.Context, req *FeeHistoryRequest) (*FeeHistoryResponse, ffcapi.ErrorReason, error) {
+
+	if req.BlockCount < 1 || req.BlockCount > maxFeeHistoryBlocks {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgBadFeeHistoryBlockCount, req.BlockCount, maxFeeHistoryBlocks)
+	}
+	newestBlock, ok := blockNumberOrTag(req.NewestBlock)
+	if !ok {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgBadFeeHistoryNewestBlock, req.NewestBlock)
+	}
+	for i, p := range req.RewardPercentiles {
+		if p < 0 || p > 100 || (i > 0 && p < req.RewardPercentiles[i-1]) {
+			return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgBadRewardPercentiles, req.RewardPercentiles)
+		}
+	}
+	percentiles := req.RewardPercentiles
+	if percentiles == nil {
+		percentiles = []float64{}
+	}
+
+	var fh feeHistoryJSONRPC
+	rpcErr := c.backend.CallRPC(ctx, &fh, "eth_feeHistory", ethtypes.NewHexInteger64(int64(req.BlockCount)), newestBlock, percentiles)
+	if rpcErr != nil {
+		return nil, "", rpcErr.Error()
+	}
+
+	res := &FeeHistoryResponse{
+		OldestBlock:   (*fftypes.FFBigInt)(fh.OldestBlock.BigInt()),
+		BaseFeePerGas: decimalIntegers(fh.BaseFeePerGas),
+		GasUsedRatio:  fh.GasUsedRatio,
+	}
+	if res.GasUsedRatio == nil {
+		res.GasUsedRatio = []float64{}
+	}
+	if len(fh.Reward) > 0 {
+		res.Reward = make([][]*fftypes.FFBigInt, len(fh.Reward))
+		for i, blockRewards := range fh.Reward {
+			res.Reward[i] = decimalIntegers(blockRewards)
+		}
+	}
+	return res, "", nil
+
+}
+
+func decimalIntegers(values []*ethtypes.HexInteger) []*fftypes.FFBigInt {
+	res := make([]*fftypes.FFBigInt, len(values))
+	for i, v := range values {
+		res[i] = (*fftypes.FFBigInt)(v.BigInt())
+	}
+	return res
+}
+
+// blockNumberOrTag converts a decimal or hex block number to the hex JSON/RPC parameter, passing through
+// block tags, and defaulting to "latest"
+func blockNumberOrTag(block string) (string, bool) {
+	switch block {
+	case "":
+		return "latest", true
+	case "latest", "pending", "earliest", "safe", "finalized":
+		return block, true
+	default:
+		blockNumber, ok := new(big.Int).SetString(block, 0)
+		if !ok || blockNumber.Sign() < 0 {
+			return "", false
+		}
+		return (*ethtypes.HexInteger)(blockNumber).String(), true
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleFeeHistoryJSONRPC = `{
+	"oldestBlock": "0x10",
+	"baseFeePerGas": ["0x3b9aca00", "0x3b9aca01", "0x3b9aca02"],
+	"gasUsedRatio": [0.5, 0.25],
+	"reward": [["0x1", "0x59682f00"], ["0x2", "0x77359400"]]
+}`
+
+func TestFeeHistoryOK(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_feeHistory",
+		mock.MatchedBy(func(count *ethtypes.HexInteger) bool { return count.BigInt().Int64() == 2 }),
+		"0x11",
+		[]float64{10, 90},
+	).Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(sampleFeeHistoryJSONRPC), args[1])
+		assert.NoError(t, err)
+	})
+
+	res, reason, err := c.FeeHistory(ctx, &FeeHistoryRequest{
+		BlockCount:        2,
+		NewestBlock:       "17",
+		RewardPercentiles: []float64{10, 90},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+	b, err := json.Marshal(res)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"oldestBlock": "16",
+		"baseFeePerGas": ["1000000000", "1000000001", "1000000002"],
+		"gasUsedRatio": [0.5, 0.25],
+		"reward": [["1", "1500000000"], ["2", "2000000000"]]
+	}`, string(b))
+
+}
+
+func TestFeeHistoryDefaultsNoRewards(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_feeHistory", mock.Anything, "latest", []float64{}).
+		Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(`{"oldestBlock":"0x10","baseFeePerGas":["0x0","0x0"]}`), args[1])
+		assert.NoError(t, err)
+	})
+
+	res, _, err := c.FeeHistory(ctx, &FeeHistoryRequest{BlockCount: 1})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(res)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"oldestBlock":"16","baseFeePerGas":["0","0"],"gasUsedRatio":[]}`, string(b))
+
+}
+
+func TestFeeHistoryBadInputs(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.FeeHistory(ctx, &FeeHistoryRequest{BlockCount: 0})
+	assert.Regexp(t, "FF23082", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, _, err = c.FeeHistory(ctx, &FeeHistoryRequest{BlockCount: 1025})
+	assert.Regexp(t, "FF23082", err)
+
+	_, _, err = c.FeeHistory(ctx, &FeeHistoryRequest{BlockCount: 1, NewestBlock: "wrong"})
+	assert.Regexp(t, "FF23083", err)
+
+	_, _, err = c.FeeHistory(ctx, &FeeHistoryRequest{BlockCount: 1, NewestBlock: "-1"})
+	assert.Regexp(t, "FF23083", err)
+
+	_, _, err = c.FeeHistory(ctx, &FeeHistoryRequest{BlockCount: 1, RewardPercentiles: []float64{50, 20}})
+	assert.Regexp(t, "FF23084", err)
+
+	_, _, err = c.FeeHistory(ctx, &FeeHistoryRequest{BlockCount: 1, RewardPercentiles: []float64{101}})
+	assert.Regexp(t, "FF23084", err)
+
+}
+
+func TestFeeHistoryRPCFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_feeHistory", mock.Anything, "pending", []float64{}).
+		Return(&rpcbackend.RPCError{Message: "pop"})
+
+	_, _, err := c.FeeHistory(ctx, &FeeHistoryRequest{BlockCount: 1, NewestBlock: "pending"})
+	assert.Regexp(t, "pop", err)
+
+}
@@ -0,0 +1,73 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+)
+
+type FeeMode string
+
+const (
+	FeeModeLegacy  FeeMode = "legacy"  // gasPrice only
+	FeeModeEIP1559 FeeMode = "eip1559" // maxFeePerGas and maxPriorityFeePerGas
+)
+
+// A chain detected as legacy is checked again after this interval, in case it has since forked to support EIP-1559
+const legacyFeeModeRecheckInterval = 10 * time.Minute
+
+// chainFeeMode detects whether the chain supports EIP-1559, from the presence of a base fee in the latest block.
+// If detection fails we assume EIP-1559 is supported, so that the fees are submitted as requested.
+func (c *ethConnector) chainFeeMode(ctx context.Context) FeeMode {
+	c.feeModeMux.Lock()
+	defer c.feeModeMux.Unlock()
+	if c.feeMode == FeeModeEIP1559 || (c.feeMode == FeeModeLegacy && time.Since(c.feeModeChecked) < legacyFeeModeRecheckInterval) {
+		return c.feeMode
+	}
+
+	var head *blockInfoJSONRPC
+	if rpcErr := c.backend.CallRPC(ctx, &head, "eth_getBlockByNumber", "latest", false); rpcErr != nil || head == nil {
+		log.L(ctx).Warnf("Unable to detect EIP-1559 support from the latest block: %v", rpcErr)
+		return FeeModeEIP1559
+	}
+	feeMode := FeeModeEIP1559
+	if head.BaseFeePerGas == nil {
+		feeMode = FeeModeLegacy
+	}
+	if feeMode != c.feeMode {
+		log.L(ctx).Infof("Detected fee mode '%s' from block %s", feeMode, head.Number.BigInt())
+	}
+	c.feeMode = feeMode
+	c.feeModeChecked = time.Now()
+	return feeMode
+}
+
+// legacyFeeFallback converts EIP-1559 fees to a legacy gas price on a chain that does not support EIP-1559.
+// Without a base fee to burn the sender pays the whole gas price, so maxFeePerGas is the equivalent maximum.
+func (c *ethConnector) legacyFeeFallback(ctx context.Context, tx *ethsigner.Transaction) {
+	if !c.legacyFeeFallbackEnabled || c.chainFeeMode(ctx) != FeeModeLegacy {
+		return
+	}
+	log.L(ctx).Infof("Chain does not support EIP-1559 - submitting maxFeePerGas=%s as legacy gasPrice", tx.MaxFeePerGas)
+	tx.GasPrice = tx.MaxFeePerGas
+	tx.MaxFeePerGas = nil
+	tx.MaxPriorityFeePerGas = nil
+}
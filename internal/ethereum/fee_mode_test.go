@@ -0,0 +1,119 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockLatestBlock(mRPC *rpcbackendmocks.Backend, baseFee *ethtypes.HexInteger) *mock.Call {
+	return mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "latest", false).
+		Run(func(args mock.Arguments) {
+			*(args[1].(**blockInfoJSONRPC)) = &blockInfoJSONRPC{
+				Number:        ethtypes.NewHexInteger64(1000),
+				BaseFeePerGas: baseFee,
+			}
+		}).
+		Return(nil)
+}
+
+func TestSendTransactionLegacyFeeFallback(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockLatestBlock(mRPC, nil).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction",
+		mock.MatchedBy(func(tx *ethsigner.Transaction) bool {
+			return tx.GasPrice.BigInt().Int64() == 65535 && tx.MaxFeePerGas == nil && tx.MaxPriorityFeePerGas == nil
+		})).
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc")
+		}).
+		Return(nil)
+
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTXGasPriceEIP1559), &req)
+	assert.NoError(t, err)
+	_, _, err = c.TransactionSend(ctx, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, FeeModeLegacy, c.feeMode)
+
+	// The detected mode is reported in the readiness details
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil)
+	ready, _, err := c.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy", ready.DownstreamDetails.JSONObject().GetString("feeMode"))
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestChainFeeModeRecheck(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "latest", false).
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mockLatestBlock(mRPC, nil).Once()
+	mockLatestBlock(mRPC, ethtypes.NewHexInteger64(7)).Once()
+
+	// Failure to detect leaves the fees as requested, and is retried
+	assert.Equal(t, FeeModeEIP1559, c.chainFeeMode(ctx))
+	assert.Empty(t, c.feeMode)
+
+	// Legacy is cached until the recheck interval
+	assert.Equal(t, FeeModeLegacy, c.chainFeeMode(ctx))
+	assert.Equal(t, FeeModeLegacy, c.chainFeeMode(ctx))
+
+	// Then detection of EIP-1559 support is permanent
+	c.feeModeChecked = time.Now().Add(-legacyFeeModeRecheckInterval)
+	assert.Equal(t, FeeModeEIP1559, c.chainFeeMode(ctx))
+	assert.Equal(t, FeeModeEIP1559, c.chainFeeMode(ctx))
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestLegacyFeeFallbackDisabled(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(LegacyFeeFallback, false)
+	})
+	defer done()
+
+	tx := &ethsigner.Transaction{
+		MaxFeePerGas:         ethtypes.NewHexInteger64(100),
+		MaxPriorityFeePerGas: ethtypes.NewHexInteger64(10),
+	}
+	c.legacyFeeFallback(ctx, tx)
+	assert.Nil(t, tx.GasPrice)
+	assert.Equal(t, int64(100), tx.MaxFeePerGas.BigInt().Int64())
+
+}
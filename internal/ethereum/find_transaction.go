@@ -0,0 +1,144 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type TransactionSearchSource string
+
+const (
+	TransactionSearchIndex TransactionSearchSource = "index" // found with the ots_getTransactionBySenderAndNonce index of Erigon/Otterscan
+	TransactionSearchScan  TransactionSearchSource = "scan"  // found by scanning back through the blocks from the head of the chain
+)
+
+type TransactionByNonceRequest struct {
+	From      string            `json:"from"`
+	Nonce     *fftypes.FFBigInt `json:"nonce"`
+	MaxBlocks int64             `json:"maxBlocks,omitempty"` // limits the blocks scanned, if lower than the configured transactionSearch.maxBlocks
+}
+
+type TransactionByNonceResponse struct {
+	TransactionHash string                             `json:"transactionHash"`
+	Source          TransactionSearchSource            `json:"source"`
+	Receipt         *ffcapi.TransactionReceiptResponse `json:"receipt"`
+}
+
+// blockTransactionsJSONRPC is a block obtained with the full transaction objects, rather than just the hashes
+type blockTransactionsJSONRPC struct {
+	Number       *ethtypes.HexInteger `json:"number"`
+	Transactions []*txInfoJSONRPC     `json:"transactions"`
+}
+
+// TransactionByNonce finds the mined transaction from a sender with a nonce, for recovery when the hash of a
+// submitted transaction was lost. The ots_getTransactionBySenderAndNonce index is used if the node has one,
+// otherwise a bounded number of blocks are scanned back from the head of the chain.
+func (c *ethConnector) TransactionByNonce(ctx context.Context, req *TransactionByNonceRequest) (*TransactionByNonceResponse, ffcapi.ErrorReason, error) {
+
+	from, err := ethtypes.NewAddress(req.From)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidFromAddress, req.From, err)
+	}
+	if req.Nonce == nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgMissingNonce)
+	}
+
+	// Check the nonce has been used by a mined transaction, before searching for it
+	var txnCount ethtypes.HexInteger
+	if rpcErr := c.backend.CallRPC(ctx, &txnCount, "eth_getTransactionCount", from, "latest"); rpcErr != nil {
+		return nil, "", rpcErr.Error()
+	}
+	if txnCount.BigInt().Cmp(req.Nonce.Int()) <= 0 {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgNonceNotMined, req.Nonce, from, txnCount.BigInt())
+	}
+
+	source := TransactionSearchIndex
+	var txHash *ethtypes.HexBytes0xPrefix
+	rpcErr := c.backend.CallRPC(ctx, &txHash, "ots_getTransactionBySenderAndNonce", from, (*ethtypes.HexInteger)(req.Nonce))
+	if rpcErr != nil && rpcErr.Code != rpcCodeMethodNotFound {
+		return nil, "", rpcErr.Error()
+	}
+	if txHash == nil {
+		log.L(ctx).Debugf("Transaction index not available for nonce %s from %s - scanning blocks", req.Nonce, from)
+		source = TransactionSearchScan
+		var reason ffcapi.ErrorReason
+		if txHash, reason, err = c.scanForTransactionByNonce(ctx, from, req.Nonce, req.MaxBlocks); err != nil {
+			return nil, reason, err
+		}
+	}
+
+	receipt, reason, err := c.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash.String()})
+	if err != nil {
+		return nil, reason, err
+	}
+	log.L(ctx).Infof("Found transaction %s with nonce %s from %s (source=%s)", txHash, req.Nonce, from, source)
+	return &TransactionByNonceResponse{
+		TransactionHash: txHash.String(),
+		Source:          source,
+		Receipt:         receipt,
+	}, "", nil
+
+}
+
+func (c *ethConnector) scanForTransactionByNonce(ctx context.Context, from *ethtypes.Address0xHex, nonce *fftypes.FFBigInt, maxBlocks int64) (*ethtypes.HexBytes0xPrefix, ffcapi.ErrorReason, error) {
+	if maxBlocks <= 0 || maxBlocks > c.txSearchMaxBlocks {
+		maxBlocks = c.txSearchMaxBlocks
+	}
+	head, ok := c.blockListener.getHighestBlock(ctx)
+	if !ok {
+		return nil, "", i18n.NewError(ctx, msgs.MsgTimedOutQueryingChainHead)
+	}
+	lowest := head - maxBlocks + 1
+	if lowest < 0 {
+		lowest = 0
+	}
+	for blockNumber := head; blockNumber >= lowest; blockNumber-- {
+		var block *blockTransactionsJSONRPC
+		if rpcErr := c.backend.CallRPC(ctx, &block, "eth_getBlockByNumber", ethtypes.NewHexInteger64(blockNumber), true /* full transactions */); rpcErr != nil {
+			return nil, "", rpcErr.Error()
+		}
+		if block == nil {
+			continue
+		}
+		earlierNonce := false
+		for _, tx := range block.Transactions {
+			if tx.From == nil || *tx.From != *from || tx.Nonce == nil {
+				continue
+			}
+			switch tx.Nonce.BigInt().Cmp(nonce.Int()) {
+			case 0:
+				return &tx.Hash, "", nil
+			case -1:
+				earlierNonce = true
+			}
+		}
+		if earlierNonce {
+			// Nonces are mined in order, so the transaction is in a later block we have already scanned
+			lowest = blockNumber
+			break
+		}
+	}
+	return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgTransactionNotFoundNonce, nonce, from, head-lowest+1, lowest, head)
+}
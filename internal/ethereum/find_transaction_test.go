@@ -0,0 +1,244 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	sampleNonceSender = "0x302259069aaa5b10dc6f29a9a3f72a8e52837cc3"
+	sampleNonceTXHash = "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2"
+)
+
+func mockNonceSearchHead(mRPC *rpcbackendmocks.Backend, txnCount, head int64) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionCount", mock.Anything, "latest").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexInteger) = *ethtypes.NewHexInteger64(txnCount)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexInteger) = *ethtypes.NewHexInteger64(head)
+	}).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_newBlockFilter").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*string) = "filter_id1"
+	}).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", mock.Anything).Return(nil).Maybe()
+}
+
+func mockBlockTransactions(mRPC *rpcbackendmocks.Backend, blockNumber int64, txs string) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(blockNumber), true).Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(`{"transactions":`+txs+`}`), args[1])
+		if err != nil {
+			panic(err)
+		}
+	}).Once()
+}
+
+func mockNonceSearchReceipt(mRPC *rpcbackendmocks.Backend) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", sampleNonceTXHash).Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(`{
+			"blockHash": "0x6197ef1a58a2a592bb447efb651f0db7945de21aa8048801b250bd7b7431f9b6",
+			"blockNumber": "0x3ec",
+			"status": "0x1",
+			"transactionHash": "`+sampleNonceTXHash+`",
+			"transactionIndex": "0x1"
+		}`), args[1])
+		if err != nil {
+			panic(err)
+		}
+	})
+}
+
+func TestTransactionByNonceIndex(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockNonceSearchHead(mRPC, 5, 1005)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "ots_getTransactionBySenderAndNonce", ethtypes.MustNewAddress(sampleNonceSender), ethtypes.NewHexInteger64(3)).
+		Return(nil).Run(func(args mock.Arguments) {
+		hash := ethtypes.MustNewHexBytes0xPrefix(sampleNonceTXHash)
+		*args[1].(**ethtypes.HexBytes0xPrefix) = &hash
+	})
+	mockNonceSearchReceipt(mRPC)
+
+	res, reason, err := c.TransactionByNonce(ctx, &TransactionByNonceRequest{
+		From:  sampleNonceSender,
+		Nonce: fftypes.NewFFBigInt(3),
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, sampleNonceTXHash, res.TransactionHash)
+	assert.Equal(t, TransactionSearchIndex, res.Source)
+	assert.Equal(t, int64(1004), res.Receipt.BlockNumber.Int64())
+	assert.True(t, res.Receipt.Success)
+
+}
+
+func TestTransactionByNonceScan(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockNonceSearchHead(mRPC, 5, 1005)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "ots_getTransactionBySenderAndNonce", mock.Anything, mock.Anything).
+		Return(&rpcbackend.RPCError{Code: rpcCodeMethodNotFound, Message: "the method ots_getTransactionBySenderAndNonce does not exist"})
+	mockBlockTransactions(mRPC, 1005, `[
+		{"hash":"0x01","from":"`+sampleNonceSender+`","nonce":"0x4"},
+		{"hash":"0x02","from":"`+testAddress1+`","nonce":"0x3"}
+	]`)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(1004), true).Return(nil).Once() // null block
+	mockBlockTransactions(mRPC, 1003, `[
+		{"hash":"0x03","from":"`+testAddress1+`","nonce":"0x3"},
+		{"hash":"`+sampleNonceTXHash+`","from":"`+sampleNonceSender+`","nonce":"0x3"}
+	]`)
+	mockNonceSearchReceipt(mRPC)
+
+	res, reason, err := c.TransactionByNonce(ctx, &TransactionByNonceRequest{
+		From:      sampleNonceSender,
+		Nonce:     fftypes.NewFFBigInt(3),
+		MaxBlocks: 10,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, sampleNonceTXHash, res.TransactionHash)
+	assert.Equal(t, TransactionSearchScan, res.Source)
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestTransactionByNonceScanNotFound(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(TransactionSearchMaxBlocks, 2)
+	})
+	defer done()
+
+	mockNonceSearchHead(mRPC, 5, 1005)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "ots_getTransactionBySenderAndNonce", mock.Anything, mock.Anything).Return(nil) // null from the index
+	mockBlockTransactions(mRPC, 1005, `[]`)
+	mockBlockTransactions(mRPC, 1004, `[{"hash":"0x01"}]`)
+
+	// The request cannot scan more than the configured maximum
+	_, reason, err := c.TransactionByNonce(ctx, &TransactionByNonceRequest{
+		From:      sampleNonceSender,
+		Nonce:     fftypes.NewFFBigInt(3),
+		MaxBlocks: 100,
+	})
+	assert.Regexp(t, "FF23124.*2 blocks from 1,004 to 1,005", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	// Scanning stops at an earlier nonce of the sender, as the transaction must be in a later block
+	mockBlockTransactions(mRPC, 1005, `[{"hash":"0x01","from":"`+sampleNonceSender+`","nonce":"0x2"}]`)
+	_, reason, err = c.TransactionByNonce(ctx, &TransactionByNonceRequest{
+		From:  sampleNonceSender,
+		Nonce: fftypes.NewFFBigInt(3),
+	})
+	assert.Regexp(t, "FF23124.*1 blocks from 1,005 to 1,005", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestTransactionByNonceNotMined(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockNonceSearchHead(mRPC, 3, 1005)
+
+	_, reason, err := c.TransactionByNonce(ctx, &TransactionByNonceRequest{
+		From:  sampleNonceSender,
+		Nonce: fftypes.NewFFBigInt(3),
+	})
+	assert.Regexp(t, "FF23123.*next nonce is 3", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+}
+
+func TestTransactionByNonceBadRequest(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.TransactionByNonce(ctx, &TransactionByNonceRequest{
+		From:  "wrong",
+		Nonce: fftypes.NewFFBigInt(3),
+	})
+	assert.Regexp(t, "FF23019", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = c.TransactionByNonce(ctx, &TransactionByNonceRequest{
+		From: sampleNonceSender,
+	})
+	assert.Regexp(t, "FF23122", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestTransactionByNonceRPCErrors(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	req := &TransactionByNonceRequest{
+		From:  sampleNonceSender,
+		Nonce: fftypes.NewFFBigInt(3),
+	}
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionCount", mock.Anything, "latest").Return(&rpcbackend.RPCError{Message: "pop1"}).Once()
+	_, _, err := c.TransactionByNonce(ctx, req)
+	assert.Regexp(t, "pop1", err)
+
+	mockNonceSearchHead(mRPC, 5, 1005)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "ots_getTransactionBySenderAndNonce", mock.Anything, mock.Anything).Return(&rpcbackend.RPCError{Message: "pop2"}).Once()
+	_, _, err = c.TransactionByNonce(ctx, req)
+	assert.Regexp(t, "pop2", err)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "ots_getTransactionBySenderAndNonce", mock.Anything, mock.Anything).Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", mock.Anything, true).Return(&rpcbackend.RPCError{Message: "pop3"}).Once()
+	_, _, err = c.TransactionByNonce(ctx, req)
+	assert.Regexp(t, "pop3", err)
+
+	mockBlockTransactions(mRPC, 1005, `[{"hash":"`+sampleNonceTXHash+`","from":"`+sampleNonceSender+`","nonce":"0x3"}]`)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).Return(nil) // not available
+	_, reason, err := c.TransactionByNonce(ctx, req)
+	assert.Regexp(t, "FF23012", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+}
+
+func TestTransactionByNonceNoChainHead(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(&rpcbackend.RPCError{Message: "pop"}).Maybe()
+	done()
+
+	_, _, err := c.scanForTransactionByNonce(ctx, ethtypes.MustNewAddress(sampleNonceSender), fftypes.NewFFBigInt(3), 0)
+	assert.Regexp(t, "FF23046", err)
+
+}
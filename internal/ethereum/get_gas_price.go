@@ -21,13 +21,24 @@ import (
 	"fmt"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
 func (c *ethConnector) GasPriceEstimate(ctx context.Context, _ *ffcapi.GasPriceEstimateRequest) (*ffcapi.GasPriceEstimateResponse, ffcapi.ErrorReason, error) {
 
-	// Note we use simple (pre London fork) gas fee approach.
+	if c.priorityFee != nil && c.chainFeeMode(ctx) == FeeModeEIP1559 {
+		maxPriorityFee, maxFee, err := c.priorityFee.estimateFees(ctx, c)
+		if err == nil {
+			return &ffcapi.GasPriceEstimateResponse{
+				GasPrice: fftypes.JSONAnyPtr(fmt.Sprintf(`{"maxPriorityFeePerGas":"%s","maxFeePerGas":"%s"}`, maxPriorityFee.Text(10), maxFee.Text(10))),
+			}, "", nil
+		}
+		log.L(ctx).Warnf("Unable to estimate EIP-1559 fees, falling back to eth_gasPrice: %s", err)
+	}
+
+	// Otherwise we use simple (pre London fork) gas fee approach.
 	// See https://github.com/ethereum/pm/issues/328#issuecomment-853234014 for a bit of color
 	var gasPrice ethtypes.HexInteger
 	rpcErr := c.backend.CallRPC(ctx, &gasPrice, "eth_gasPrice")
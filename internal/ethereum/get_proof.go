@@ -0,0 +1,124 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type ProofRequest struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys,omitempty"` // storage slots in decimal or hex
+	Block       string   `json:"block,omitempty"`       // a block number, or a tag such as "finalized" - defaults to "latest"
+}
+
+type StorageProof struct {
+	Key   string                      `json:"key"` // the slot as 32 bytes of hex
+	Value *fftypes.FFBigInt           `json:"value"`
+	Proof []ethtypes.HexBytes0xPrefix `json:"proof"`
+}
+
+type ProofResponse struct {
+	Address      string                      `json:"address"`
+	Balance      *fftypes.FFBigInt           `json:"balance"`
+	Nonce        *fftypes.FFBigInt           `json:"nonce"`
+	CodeHash     ethtypes.HexBytes0xPrefix   `json:"codeHash"`
+	StorageHash  ethtypes.HexBytes0xPrefix   `json:"storageHash"`
+	AccountProof []ethtypes.HexBytes0xPrefix `json:"accountProof"`
+	StorageProof []*StorageProof             `json:"storageProof"`
+}
+
+type proofJSONRPC struct {
+	Balance      *ethtypes.HexInteger        `json:"balance"`
+	Nonce        *ethtypes.HexInteger        `json:"nonce"`
+	CodeHash     ethtypes.HexBytes0xPrefix   `json:"codeHash"`
+	StorageHash  ethtypes.HexBytes0xPrefix   `json:"storageHash"`
+	AccountProof []ethtypes.HexBytes0xPrefix `json:"accountProof"`
+	StorageProof []*storageProofJSONRPC      `json:"storageProof"`
+}
+
+type storageProofJSONRPC struct {
+	Key   *ethtypes.HexInteger        `json:"key"` // nodes differ in whether the key is zero padded
+	Value *ethtypes.HexInteger        `json:"value"`
+	Proof []ethtypes.HexBytes0xPrefix `json:"proof"`
+}
+
+// Proof returns the EIP-1186 account proof of an address, and the storage proofs of the requested slots, from
+// eth_getProof. Numeric values are returned in decimal, so that light clients and bridges can verify state
+// against a block's state root without a direct connection to the node.
+func (c *ethConnector) Proof(ctx context.Context, req *ProofRequest) (*ProofResponse, ffcapi.ErrorReason, error) {
+
+	address, err := ethtypes.NewAddress(req.Address)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidProofAddress, req.Address, err)
+	}
+	storageKeys := make([]string, len(req.StorageKeys))
+	for i, k := range req.StorageKeys {
+		slot, ok := new(big.Int).SetString(k, 0)
+		if !ok || slot.Sign() < 0 || slot.BitLen() > 256 {
+			return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidProofStorageKey, k)
+		}
+		storageKeys[i] = storageSlotHex(slot)
+	}
+	block, ok := blockNumberOrTag(req.Block)
+	if !ok {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidProofBlock, req.Block)
+	}
+
+	var proof proofJSONRPC
+	rpcErr := c.backend.CallRPC(ctx, &proof, "eth_getProof", address, storageKeys, block)
+	if rpcErr != nil {
+		return nil, c.mapRPCError(blockRPCMethods, rpcErr), rpcErr.Error()
+	}
+
+	res := &ProofResponse{
+		Address:      c.formatAddress(address),
+		Balance:      (*fftypes.FFBigInt)(proof.Balance.BigInt()),
+		Nonce:        (*fftypes.FFBigInt)(proof.Nonce.BigInt()),
+		CodeHash:     proof.CodeHash,
+		StorageHash:  proof.StorageHash,
+		AccountProof: proof.AccountProof,
+		StorageProof: make([]*StorageProof, len(proof.StorageProof)),
+	}
+	if res.AccountProof == nil {
+		res.AccountProof = []ethtypes.HexBytes0xPrefix{}
+	}
+	for i, sp := range proof.StorageProof {
+		res.StorageProof[i] = &StorageProof{
+			Key:   storageSlotHex(sp.Key.BigInt()),
+			Value: (*fftypes.FFBigInt)(sp.Value.BigInt()),
+			Proof: sp.Proof,
+		}
+		if res.StorageProof[i].Proof == nil {
+			res.StorageProof[i].Proof = []ethtypes.HexBytes0xPrefix{}
+		}
+	}
+	return res, "", nil
+
+}
+
+func storageSlotHex(slot *big.Int) string {
+	return fmt.Sprintf("0x%064x", slot)
+}
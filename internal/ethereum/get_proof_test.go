@@ -0,0 +1,147 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleProofJSONRPC = `{
+	"address": "0x7f0d15c7faae65896648c8273b6d7e43f58fa842",
+	"accountProof": ["0xf90211a0", "0xf87180"],
+	"balance": "0xde0b6b3a7640000",
+	"codeHash": "0xc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+	"nonce": "0x2",
+	"storageHash": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+	"storageProof": [{
+		"key": "0x1",
+		"value": "0x3e8",
+		"proof": ["0xe3a120"]
+	}]
+}`
+
+func TestProofOK(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getProof",
+		mock.MatchedBy(func(a *ethtypes.Address0xHex) bool { return a.String() == "0x7f0d15c7faae65896648c8273b6d7e43f58fa842" }),
+		[]string{"0x0000000000000000000000000000000000000000000000000000000000000001"},
+		"0x11",
+	).Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(sampleProofJSONRPC), args[1])
+		assert.NoError(t, err)
+	})
+
+	res, reason, err := c.Proof(ctx, &ProofRequest{
+		Address:     "0x7F0D15C7FAAE65896648C8273B6D7E43F58FA842",
+		StorageKeys: []string{"1"},
+		Block:       "17",
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+	b, err := json.Marshal(res)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"address": "0x7f0d15c7faae65896648c8273b6d7e43f58fa842",
+		"accountProof": ["0xf90211a0", "0xf87180"],
+		"balance": "1000000000000000000",
+		"codeHash": "0xc5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+		"nonce": "2",
+		"storageHash": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+		"storageProof": [{
+			"key": "0x0000000000000000000000000000000000000000000000000000000000000001",
+			"value": "1000",
+			"proof": ["0xe3a120"]
+		}]
+	}`, string(b))
+
+}
+
+func TestProofNoStorageChecksumAddress(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ChecksumAddresses, true)
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getProof", mock.Anything, []string{}, "finalized").
+		Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(`{"balance":"0x0","nonce":"0x0","storageProof":[{"key":"0x0","value":"0x0"}]}`), args[1])
+		assert.NoError(t, err)
+	})
+
+	res, _, err := c.Proof(ctx, &ProofRequest{
+		Address: "0x7f0d15c7faae65896648c8273b6d7e43f58fa842",
+		Block:   "finalized",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x7F0d15C7FAae65896648C8273B6d7E43f58Fa842", res.Address)
+	assert.Empty(t, res.AccountProof)
+	assert.NotNil(t, res.AccountProof)
+	assert.NotNil(t, res.StorageProof[0].Proof)
+
+}
+
+func TestProofBadInputs(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.Proof(ctx, &ProofRequest{Address: "wrong"})
+	assert.Regexp(t, "FF23102", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = c.Proof(ctx, &ProofRequest{
+		Address:     "0x7f0d15c7faae65896648c8273b6d7e43f58fa842",
+		StorageKeys: []string{"0x10000000000000000000000000000000000000000000000000000000000000000"},
+	})
+	assert.Regexp(t, "FF23103", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = c.Proof(ctx, &ProofRequest{
+		Address: "0x7f0d15c7faae65896648c8273b6d7e43f58fa842",
+		Block:   "-1",
+	})
+	assert.Regexp(t, "FF23104", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestProofFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getProof", mock.Anything, mock.Anything, "latest").
+		Return(&rpcbackend.RPCError{Message: "cannot query unfinalized data"})
+
+	_, reason, err := c.Proof(ctx, &ProofRequest{Address: "0x7f0d15c7faae65896648c8273b6d7e43f58fa842"})
+	assert.Regexp(t, "unfinalized", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
@@ -47,6 +48,10 @@ type txReceiptJSONRPC struct {
 	TransactionHash   ethtypes.HexBytes0xPrefix  `json:"transactionHash"`
 	TransactionIndex  *ethtypes.HexInteger       `json:"transactionIndex"`
 	RevertReason      *ethtypes.HexBytes0xPrefix `json:"revertReason"`
+	EffectiveGasPrice *ethtypes.HexInteger       `json:"effectiveGasPrice"`
+	Type              *ethtypes.HexInteger       `json:"type"`
+	BlobGasUsed       *ethtypes.HexInteger       `json:"blobGasUsed"`  // EIP-4844 only
+	BlobGasPrice      *ethtypes.HexInteger       `json:"blobGasPrice"` // EIP-4844 only
 }
 
 // receiptExtraInfo is the version of the receipt we store under the TX.
@@ -62,23 +67,33 @@ type receiptExtraInfo struct {
 	Status            *fftypes.FFBigInt      `json:"status"`
 	ErrorMessage      *string                `json:"errorMessage"`
 	ReturnValue       *string                `json:"returnValue,omitempty"`
+	EffectiveGasPrice *fftypes.FFBigInt      `json:"effectiveGasPrice,omitempty"`
+	Type              *fftypes.FFBigInt      `json:"type,omitempty"`
+	BlobGasUsed       *fftypes.FFBigInt      `json:"blobGasUsed,omitempty"`
+	BlobGasPrice      *fftypes.FFBigInt      `json:"blobGasPrice,omitempty"`
+	Deployment        *deploymentMetadata    `json:"deployment,omitempty"`
+
+	checksumAddresses bool // format addresses with EIP-55 checksums when serializing
 }
 
 // txInfoJSONRPC is the transaction info obtained over JSON/RPC from the ethereum client, with input data
 type txInfoJSONRPC struct {
-	BlockHash        ethtypes.HexBytes0xPrefix `json:"blockHash"`   // null if pending
-	BlockNumber      *ethtypes.HexInteger      `json:"blockNumber"` // null if pending
-	From             *ethtypes.Address0xHex    `json:"from"`
-	Gas              *ethtypes.HexInteger      `json:"gas"`
-	GasPrice         *ethtypes.HexInteger      `json:"gasPrice"`
-	Hash             ethtypes.HexBytes0xPrefix `json:"hash"`
-	Input            ethtypes.HexBytes0xPrefix `json:"input"`
-	R                *ethtypes.HexInteger      `json:"r"`
-	S                *ethtypes.HexInteger      `json:"s"`
-	To               *ethtypes.Address0xHex    `json:"to"`
-	TransactionIndex *ethtypes.HexInteger      `json:"transactionIndex"` // null if pending
-	V                *ethtypes.HexInteger      `json:"v"`
-	Value            *ethtypes.HexInteger      `json:"value"`
+	BlockHash            ethtypes.HexBytes0xPrefix `json:"blockHash"`   // null if pending
+	BlockNumber          *ethtypes.HexInteger      `json:"blockNumber"` // null if pending
+	From                 *ethtypes.Address0xHex    `json:"from"`
+	Gas                  *ethtypes.HexInteger      `json:"gas"`
+	GasPrice             *ethtypes.HexInteger      `json:"gasPrice"`
+	MaxFeePerGas         *ethtypes.HexInteger      `json:"maxFeePerGas,omitempty"`         // EIP-1559 only
+	MaxPriorityFeePerGas *ethtypes.HexInteger      `json:"maxPriorityFeePerGas,omitempty"` // EIP-1559 only
+	Hash                 ethtypes.HexBytes0xPrefix `json:"hash"`
+	Input                ethtypes.HexBytes0xPrefix `json:"input"`
+	Nonce                *ethtypes.HexInteger      `json:"nonce"`
+	R                    *ethtypes.HexInteger      `json:"r"`
+	S                    *ethtypes.HexInteger      `json:"s"`
+	To                   *ethtypes.Address0xHex    `json:"to"`
+	TransactionIndex     *ethtypes.HexInteger      `json:"transactionIndex"` // null if pending
+	V                    *ethtypes.HexInteger      `json:"v"`
+	Value                *ethtypes.HexInteger      `json:"value"`
 }
 
 type StructLog struct {
@@ -184,7 +199,63 @@ func (c *ethConnector) getErrorInfo(ctx context.Context, transactionHash string,
 	return &revertReason, &errorMessage
 }
 
-func (c *ethConnector) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (_ *ffcapi.TransactionReceiptResponse, _ ffcapi.ErrorReason, err error) {
+// getTransactionReceipt queries the receipt, retrying a configurable number of times if the node returns
+// null - as some nodes only make receipts available some time after the block containing them.
+// If a grace period is configured, we also keep retrying for up to that long if the transaction is already
+// in a block, so that a freshly mined transaction is not reported as not found.
+func (c *ethConnector) getTransactionReceipt(ctx context.Context, txHash string) (*txReceiptJSONRPC, error) {
+	start := time.Now()
+	var mined *bool // checked at most once per call
+	for attempt := 0; ; attempt++ {
+		var ethReceipt *txReceiptJSONRPC
+		rpcErr := c.readQuorum.callRPC(ctx, c.backend, &ethReceipt, "eth_getTransactionReceipt", txHash)
+		if rpcErr != nil {
+			return nil, rpcErr.Error()
+		}
+		if ethReceipt != nil {
+			return ethReceipt, nil
+		}
+		if attempt >= c.receiptsNotFoundRetries {
+			if c.receiptsNotFoundGrace <= 0 || time.Since(start) >= c.receiptsNotFoundGrace {
+				return nil, nil
+			}
+			if mined == nil {
+				isMined := c.isTransactionMined(ctx, txHash)
+				mined = &isMined
+			}
+			if !*mined {
+				return nil, nil
+			}
+		}
+		log.L(ctx).Debugf("Receipt for %s not available (attempt=%d), retrying in %s", txHash, attempt+1, c.receiptsNotFoundRetryDelay)
+		select {
+		case <-time.After(c.receiptsNotFoundRetryDelay):
+		case <-ctx.Done():
+			return nil, i18n.NewError(ctx, i18n.MsgContextCanceled)
+		}
+	}
+}
+
+// isTransactionMined checks whether the node has the transaction in a block, bypassing the transaction cache
+// as the transaction might have been cached while it was pending
+func (c *ethConnector) isTransactionMined(ctx context.Context, txHash string) bool {
+	var txInfo *txInfoJSONRPC
+	if rpcErr := c.backend.CallRPC(ctx, &txInfo, "eth_getTransactionByHash", txHash); rpcErr != nil {
+		log.L(ctx).Warnf("Failed to check whether transaction %s is mined: %s", txHash, rpcErr.Message)
+		return false
+	}
+	return txInfo != nil && txInfo.BlockNumber != nil
+}
+
+func (c *ethConnector) TransactionReceipt(ctx context.Context, req *ffcapi.TransactionReceiptRequest) (_ *ffcapi.TransactionReceiptResponse, reason ffcapi.ErrorReason, err error) {
+	ctx, span := c.startSpan(ctx, "TransactionReceipt")
+	defer func() {
+		spanErr := err
+		if reason == ffcapi.ErrorReasonNotFound {
+			spanErr = nil // the transaction manager polls for receipts until they are available
+		}
+		endSpan(span, spanErr)
+	}()
 
 	var filters []*eventFilter
 	var methods []*abi.Entry
@@ -206,15 +277,17 @@ func (c *ethConnector) TransactionReceipt(ctx context.Context, req *ffcapi.Trans
 	}
 
 	// Get the receipt in the back-end JSON/RPC format
-	var ethReceipt *txReceiptJSONRPC
-	rpcErr := c.backend.CallRPC(ctx, &ethReceipt, "eth_getTransactionReceipt", req.TransactionHash)
-	if rpcErr != nil {
-		return nil, "", rpcErr.Error()
+	ethReceipt, err := c.getTransactionReceipt(ctx, req.TransactionHash)
+	if err != nil {
+		return nil, "", err
 	}
 	if ethReceipt == nil {
 		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgReceiptNotAvailable, req.TransactionHash)
 	}
 	isSuccess := (ethReceipt.Status != nil && ethReceipt.Status.BigInt().Int64() > 0)
+	if ethReceipt.BlockNumber != nil {
+		span.SetAttributes(attrBlockNumber.Int64(ethReceipt.BlockNumber.BigInt().Int64()))
+	}
 
 	var returnDataString *string
 	var transactionErrorMessage *string
@@ -223,6 +296,13 @@ func (c *ethConnector) TransactionReceipt(ctx context.Context, req *ffcapi.Trans
 		returnDataString, transactionErrorMessage = c.getErrorInfo(ctx, req.TransactionHash, ethReceipt.RevertReason)
 	}
 
+	var deployment *deploymentMetadata
+	if c.constructorArgs != nil && isSuccess && ethReceipt.ContractAddress != nil {
+		if deployment, err = c.getDeploymentMetadata(ctx, ethReceipt); err != nil {
+			return nil, "", err
+		}
+	}
+
 	fullReceipt, _ := json.Marshal(&receiptExtraInfo{
 		ContractAddress:   ethReceipt.ContractAddress,
 		CumulativeGasUsed: (*fftypes.FFBigInt)(ethReceipt.CumulativeGasUsed),
@@ -232,6 +312,12 @@ func (c *ethConnector) TransactionReceipt(ctx context.Context, req *ffcapi.Trans
 		Status:            (*fftypes.FFBigInt)(ethReceipt.Status),
 		ReturnValue:       returnDataString,
 		ErrorMessage:      transactionErrorMessage,
+		EffectiveGasPrice: (*fftypes.FFBigInt)(ethReceipt.EffectiveGasPrice),
+		Type:              (*fftypes.FFBigInt)(ethReceipt.Type),
+		BlobGasUsed:       (*fftypes.FFBigInt)(ethReceipt.BlobGasUsed),
+		BlobGasPrice:      (*fftypes.FFBigInt)(ethReceipt.BlobGasPrice),
+		Deployment:        deployment,
+		checksumAddresses: c.checksumAddresses,
 	})
 
 	var txIndex int64
@@ -252,15 +338,17 @@ func (c *ethConnector) TransactionReceipt(ctx context.Context, req *ffcapi.Trans
 	if req.IncludeLogs {
 		receiptResponse.Logs = make([]fftypes.JSONAny, len(ethReceipt.Logs))
 		for i, l := range ethReceipt.Logs {
-			b, _ := json.Marshal(l) // no error injectable here as we unmarshalled to a struct we control
+			b, _ := json.Marshal(&eventInfo{logJSONRPC: *l, checksumAddresses: c.checksumAddresses}) // no error injectable here as we unmarshalled to a struct we control
 			receiptResponse.Logs[i] = *fftypes.JSONAnyPtrBytes(b)
 		}
 	}
 	// Try to decode the events etc. if we have filters supplied
 	if len(filters) > 0 {
 		ee := &eventEnricher{
-			connector:     c,
-			extractSigner: req.ExtractSigner,
+			connector:         c,
+			extractSigner:     req.ExtractSigner,
+			serializer:        c.serializer,
+			checksumAddresses: c.checksumAddresses,
 		}
 		for _, ethLog := range ethReceipt.Logs {
 			var bestMatch *ffcapi.Event
@@ -280,8 +368,12 @@ func (c *ethConnector) TransactionReceipt(ctx context.Context, req *ffcapi.Trans
 
 	}
 	if ethReceipt.ContractAddress != nil {
+		address := ethReceipt.ContractAddress.String()
+		if c.checksumAddresses {
+			address = checksumAddress(ethReceipt.ContractAddress).String()
+		}
 		location, _ := json.Marshal(map[string]string{
-			"address": ethReceipt.ContractAddress.String(),
+			"address": address,
 		})
 		receiptResponse.ContractLocation = fftypes.JSONAnyPtrBytes(location)
 	}
@@ -17,9 +17,11 @@
 package ethereum
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
@@ -246,6 +248,57 @@ func TestGetReceiptOkSuccess(t *testing.T) {
 	assert.Equal(t, int64(1977), res.BlockNumber.Int64())
 	assert.Equal(t, int64(30), res.TransactionIndex.Int64())
 
+	var extraInfo receiptExtraInfo
+	err = json.Unmarshal(res.ExtraInfo.Bytes(), &extraInfo)
+	assert.NoError(t, err)
+	assert.Equal(t, "0", extraInfo.EffectiveGasPrice.String())
+	assert.Equal(t, "0", extraInfo.Type.String())
+	assert.Nil(t, extraInfo.BlobGasUsed)
+	assert.Nil(t, extraInfo.BlobGasPrice)
+
+}
+
+func TestGetReceiptOkBlobFields(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte(`{
+				"blockHash": "0x6197ef1a58a2a592bb447efb651f0db7945de21aa8048801b250bd7b7431f9b6",
+				"blockNumber": "0x7b9",
+				"cumulativeGasUsed": "0x8414",
+				"effectiveGasPrice": "0x3b9aca07",
+				"from": "0x2b1c769ef5ad304a4889f2a07a6617cd935849ae",
+				"gasUsed": "0x5208",
+				"status": "0x1",
+				"to": "0x302259069aaa5b10dc6f29a9a3f72a8e52837cc3",
+				"transactionHash": "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+				"transactionIndex": "0x1",
+				"type": "0x3",
+				"blobGasUsed": "0x20000",
+				"blobGasPrice": "0x1"
+			}`), args[1])
+			assert.NoError(t, err)
+		})
+
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+	res, reason, err := c.TransactionReceipt(ctx, &req)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+	var extraInfo map[string]interface{}
+	err = json.Unmarshal(res.ExtraInfo.Bytes(), &extraInfo)
+	assert.NoError(t, err)
+	assert.Equal(t, "1000000007", extraInfo["effectiveGasPrice"])
+	assert.Equal(t, "3", extraInfo["type"])
+	assert.Equal(t, "131072", extraInfo["blobGasUsed"])
+	assert.Equal(t, "1", extraInfo["blobGasPrice"])
+
 }
 
 func TestGetReceiptNotFound(t *testing.T) {
@@ -270,6 +323,164 @@ func TestGetReceiptNotFound(t *testing.T) {
 
 }
 
+func TestGetReceiptNotFoundRetryOK(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	c.receiptsNotFoundRetries = 2
+	c.receiptsNotFoundRetryDelay = 1 * time.Millisecond
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte("null"), args[1])
+			assert.NoError(t, err)
+		}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte(sampleJSONRPCReceipt), args[1])
+			assert.NoError(t, err)
+		}).Once()
+
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+	res, _, err := c.TransactionReceipt(ctx, &req)
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+
+}
+
+func TestGetReceiptNotFoundRetryCancelled(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	c.receiptsNotFoundRetries = 2
+	c.receiptsNotFoundRetryDelay = 1 * time.Hour
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte("null"), args[1])
+			assert.NoError(t, err)
+			cancel()
+		}).Once()
+
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+	_, _, err = c.TransactionReceipt(cancelCtx, &req)
+	assert.Regexp(t, "FF00154", err)
+
+}
+
+func TestGetReceiptNotFoundGracePeriodMined(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	c.receiptsNotFoundRetryDelay = 1 * time.Millisecond
+	c.receiptsNotFoundGrace = 1 * time.Minute
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte("null"), args[1])
+			assert.NoError(t, err)
+		}).Times(3)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte(sampleJSONRPCReceipt), args[1])
+			assert.NoError(t, err)
+		}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte(`{"blockNumber":"0x10"}`), args[1])
+			assert.NoError(t, err)
+		}).Once()
+
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+	res, _, err := c.TransactionReceipt(ctx, &req)
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestGetReceiptNotFoundGracePeriodNotMined(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	c.receiptsNotFoundRetryDelay = 1 * time.Hour
+	c.receiptsNotFoundGrace = 1 * time.Hour
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte("null"), args[1])
+			assert.NoError(t, err)
+		})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte(`{"blockNumber":null}`), args[1])
+			assert.NoError(t, err)
+		}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+
+	// Pending transactions are not waited for
+	_, reason, err := c.TransactionReceipt(ctx, &req)
+	assert.Regexp(t, "FF23012", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	// Nor are transactions we fail to check
+	_, reason, err = c.TransactionReceipt(ctx, &req)
+	assert.Regexp(t, "FF23012", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestGetReceiptNotFoundGracePeriodExpired(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	c.receiptsNotFoundRetryDelay = 5 * time.Millisecond
+	c.receiptsNotFoundGrace = 1 * time.Millisecond
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte("null"), args[1])
+			assert.NoError(t, err)
+		})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte(`{"blockNumber":"0x10"}`), args[1])
+			assert.NoError(t, err)
+		}).Once()
+
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+	_, reason, err := c.TransactionReceipt(ctx, &req)
+	assert.Regexp(t, "FF23012", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+	mRPC.AssertExpectations(t)
+
+}
+
 func TestGetReceiptError(t *testing.T) {
 
 	ctx, c, mRPC, done := newTestConnector(t)
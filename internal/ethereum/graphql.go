@@ -0,0 +1,283 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// graphqlLogsQueryTemplate uses the EIP-1767 GraphQL schema (as implemented by Besu) to retrieve the logs for a block range,
+// along with the block and transaction information we need to enrich them, in a single round trip.
+// The EIP-1559 fee fields are inserted separately, as they are not in the schema of nodes that pre-date the London fork.
+const graphqlLogsQueryTemplate = `query($filter: FilterCriteria!) {
+  logs(filter: $filter) {
+    index topics data
+    account { address }
+    transaction {
+      hash nonce index value gas gasPrice%s inputData
+      from { address }
+      to { address }
+      block {
+        number hash timestamp gasLimit gasUsed%s
+        parent { hash }
+        transactions { hash }
+      }
+    }
+  }
+}`
+
+var (
+	graphqlLogsQuery            = fmt.Sprintf(graphqlLogsQueryTemplate, " maxFeePerGas maxPriorityFeePerGas", " baseFeePerGas")
+	graphqlLogsQueryNoFeeMarket = fmt.Sprintf(graphqlLogsQueryTemplate, "", "")
+)
+
+// graphqlSchemaErrors are the (lower case) fragments of the error messages returned by Besu and Geth when the query
+// is not supported by the schema of the node. Any other error could be transient, so does not disable GraphQL.
+var graphqlSchemaErrors = []string{"validation error", "cannot query field", "is undefined", "unknown field", "not supported"}
+
+// graphqlFeeMarketFields are the fields of the query that are only in the schema of nodes with EIP-1559 support
+var graphqlFeeMarketFields = []string{"maxfeepergas", "maxpriorityfeepergas", "basefeepergas"}
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphqlFilterCriteria struct {
+	FromBlock int64                         `json:"fromBlock"`
+	ToBlock   int64                         `json:"toBlock"`
+	Addresses []*ethtypes.Address0xHex      `json:"addresses,omitempty"`
+	Topics    [][]ethtypes.HexBytes0xPrefix `json:"topics,omitempty"`
+}
+
+// graphqlTopics replaces the positions of the topics that match any value, which are null in a JSON/RPC
+// filter, with an empty list - as EIP-1767 does not allow a null position
+func graphqlTopics(topics [][]ethtypes.HexBytes0xPrefix) [][]ethtypes.HexBytes0xPrefix {
+	gqlTopics := make([][]ethtypes.HexBytes0xPrefix, len(topics))
+	for i, values := range topics {
+		if values == nil {
+			values = []ethtypes.HexBytes0xPrefix{}
+		}
+		gqlTopics[i] = values
+	}
+	return gqlTopics
+}
+
+type graphqlLogsResponse struct {
+	Data *struct {
+		Logs []*graphqlLog `json:"logs"`
+	} `json:"data"`
+	Errors []*graphqlError `json:"errors"`
+}
+
+type graphqlError struct {
+	Message string `json:"message"`
+}
+
+type graphqlAccount struct {
+	Address *ethtypes.Address0xHex `json:"address"`
+}
+
+type graphqlHash struct {
+	Hash ethtypes.HexBytes0xPrefix `json:"hash"`
+}
+
+type graphqlBlock struct {
+	Number        *ethtypes.HexInteger      `json:"number"`
+	Hash          ethtypes.HexBytes0xPrefix `json:"hash"`
+	Parent        *graphqlHash              `json:"parent"` // null for the genesis block
+	Timestamp     *ethtypes.HexInteger      `json:"timestamp"`
+	GasLimit      *ethtypes.HexInteger      `json:"gasLimit"`
+	GasUsed       *ethtypes.HexInteger      `json:"gasUsed"`
+	BaseFeePerGas *ethtypes.HexInteger      `json:"baseFeePerGas"`
+	Transactions  []*graphqlHash            `json:"transactions"`
+}
+
+type graphqlTransaction struct {
+	Hash                 ethtypes.HexBytes0xPrefix `json:"hash"`
+	Nonce                *ethtypes.HexInteger      `json:"nonce"`
+	Index                *ethtypes.HexInteger      `json:"index"`
+	Value                *ethtypes.HexInteger      `json:"value"`
+	Gas                  *ethtypes.HexInteger      `json:"gas"`
+	GasPrice             *ethtypes.HexInteger      `json:"gasPrice"`
+	MaxFeePerGas         *ethtypes.HexInteger      `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *ethtypes.HexInteger      `json:"maxPriorityFeePerGas"`
+	InputData            ethtypes.HexBytes0xPrefix `json:"inputData"`
+	From                 *graphqlAccount           `json:"from"`
+	To                   *graphqlAccount           `json:"to"` // null for contract deployment
+	Block                *graphqlBlock             `json:"block"`
+}
+
+type graphqlLog struct {
+	Index       *ethtypes.HexInteger        `json:"index"`
+	Topics      []ethtypes.HexBytes0xPrefix `json:"topics"`
+	Data        ethtypes.HexBytes0xPrefix   `json:"data"`
+	Account     *graphqlAccount             `json:"account"`
+	Transaction *graphqlTransaction         `json:"transaction"`
+}
+
+func (c *ethConnector) graphqlEnabled() bool {
+	return c.graphqlURL != "" && !c.graphqlUnavailable.Load()
+}
+
+// getLogsGraphQL queries the logs for a block range over GraphQL, populating the block and transaction caches
+// with the information returned alongside each log - so enrichment does not require further round trips.
+// If the node reports that GraphQL, or the query, is not supported then it is disabled for the life of the connector.
+// A schema without the EIP-1559 fee fields is supported, by querying without them.
+func (c *ethConnector) getLogsGraphQL(ctx context.Context, filter *logFilterJSONRPC) ([]*logJSONRPC, error) {
+	query := graphqlLogsQuery
+	if c.graphqlNoFeeMarket.Load() {
+		query = graphqlLogsQueryNoFeeMarket
+	}
+	var gqlRes graphqlLogsResponse
+	res, err := c.graphqlClient.R().
+		SetContext(ctx).
+		SetBody(&graphqlRequest{
+			Query: query,
+			Variables: map[string]interface{}{
+				"filter": &graphqlFilterCriteria{
+					FromBlock: filter.FromBlock.BigInt().Int64(),
+					ToBlock:   filter.ToBlock.BigInt().Int64(),
+					Addresses: []*ethtypes.Address0xHex(filter.Address),
+					Topics:    graphqlTopics(filter.Topics),
+				},
+			},
+		}).
+		SetResult(&gqlRes).
+		SetError(&gqlRes).
+		Post(c.graphqlURL)
+	if err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgGraphQLQueryFailed, err)
+	}
+	if len(gqlRes.Errors) > 0 {
+		errorMessages := make([]string, len(gqlRes.Errors))
+		for i, e := range gqlRes.Errors {
+			errorMessages[i] = e.Message
+		}
+		reason := strings.Join(errorMessages, "; ")
+		if isGraphQLSchemaError(reason) {
+			if query == graphqlLogsQuery && containsAny(strings.ToLower(reason), graphqlFeeMarketFields) {
+				log.L(ctx).Infof("GraphQL schema at %s does not support EIP-1559 fee fields - querying without them", c.graphqlURL)
+				c.graphqlNoFeeMarket.Store(true)
+				return c.getLogsGraphQL(ctx, filter)
+			}
+			c.disableGraphQL(ctx, reason)
+		}
+		return nil, i18n.NewError(ctx, msgs.MsgGraphQLQueryFailed, reason)
+	}
+	if res.IsError() {
+		switch res.StatusCode() {
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			c.disableGraphQL(ctx, res.Status())
+		}
+		return nil, i18n.NewError(ctx, msgs.MsgGraphQLQueryFailed, res.Status())
+	}
+	if gqlRes.Data == nil {
+		return nil, i18n.NewError(ctx, msgs.MsgGraphQLQueryFailed, "no data in response")
+	}
+
+	ethLogs := make([]*logJSONRPC, 0, len(gqlRes.Data.Logs))
+	for _, gl := range gqlRes.Data.Logs {
+		tx := gl.Transaction
+		if tx == nil || tx.Block == nil || gl.Account == nil {
+			return nil, i18n.NewError(ctx, msgs.MsgGraphQLQueryFailed, "incomplete log in response")
+		}
+		c.cacheGraphQLTransaction(tx)
+		ethLogs = append(ethLogs, &logJSONRPC{
+			LogIndex:         gl.Index,
+			TransactionIndex: tx.Index,
+			BlockNumber:      tx.Block.Number,
+			TransactionHash:  tx.Hash,
+			BlockHash:        tx.Block.Hash,
+			Address:          gl.Account.Address,
+			Data:             gl.Data,
+			Topics:           gl.Topics,
+		})
+	}
+	log.L(ctx).Debugf("GraphQL query fromBlock=%s toBlock=%s returned %d logs", filter.FromBlock, filter.ToBlock, len(ethLogs))
+	return ethLogs, nil
+}
+
+func isGraphQLSchemaError(reason string) bool {
+	return containsAny(strings.ToLower(reason), graphqlSchemaErrors)
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ethConnector) disableGraphQL(ctx context.Context, reason string) {
+	if !c.graphqlUnavailable.Swap(true) {
+		log.L(ctx).Warnf("GraphQL is not available at %s (%s) - using JSON/RPC for all queries", c.graphqlURL, reason)
+	}
+}
+
+func (c *ethConnector) cacheGraphQLTransaction(tx *graphqlTransaction) {
+	gb := tx.Block
+	if _, cached := c.blockListener.blockCache.Get(gb.Hash.String()); !cached {
+		bi := &blockInfoJSONRPC{
+			Number:        gb.Number,
+			Hash:          gb.Hash,
+			Timestamp:     gb.Timestamp,
+			GasLimit:      gb.GasLimit,
+			GasUsed:       gb.GasUsed,
+			BaseFeePerGas: gb.BaseFeePerGas,
+			Transactions:  make([]ethtypes.HexBytes0xPrefix, len(gb.Transactions)),
+		}
+		if gb.Parent != nil {
+			bi.ParentHash = gb.Parent.Hash
+		}
+		for i, t := range gb.Transactions {
+			bi.Transactions[i] = t.Hash
+		}
+		c.blockListener.addToBlockCache(bi)
+	}
+
+	txInfo := &txInfoJSONRPC{
+		BlockHash:            gb.Hash,
+		BlockNumber:          gb.Number,
+		Gas:                  tx.Gas,
+		GasPrice:             tx.GasPrice,
+		MaxFeePerGas:         tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+		Hash:                 tx.Hash,
+		Input:                tx.InputData,
+		Nonce:                tx.Nonce,
+		TransactionIndex:     tx.Index,
+		Value:                tx.Value,
+	}
+	if tx.From != nil {
+		txInfo.From = tx.From.Address
+	}
+	if tx.To != nil {
+		txInfo.To = tx.To.Address
+	}
+	c.txCache.Add(tx.Hash.String(), txInfo)
+}
@@ -0,0 +1,214 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleGraphQLTransferLogs = `{
+	"data": {
+		"logs": [{
+			"index": "0x2",
+			"topics": [
+				"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+				"0x0000000000000000000000003968ef051b422d3d1cdc182a88bba8dd922e6fa4",
+				"0x000000000000000000000000d0f2f5103fd050739a9fb567251bc460cc24d091"
+			],
+			"data": "0x00000000000000000000000000000000000000000000000000000000000003e8",
+			"account": { "address": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431" },
+			"transaction": {
+				"hash": "0x1a1f797ee000c529b6a2dd330cedd0d081417a30d16a4eecb3f863ab4657246f",
+				"nonce": "0x0",
+				"index": 64,
+				"value": "0x0",
+				"gas": "0x5208",
+				"gasPrice": "0x0",
+				"maxFeePerGas": null,
+				"maxPriorityFeePerGas": null,
+				"inputData": "0xa9059cbb000000000000000000000000d0f2f5103fd050739a9fb567251bc460cc24d09100000000000000000000000000000000000000000000000000000000000003e8",
+				"from": { "address": "0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4" },
+				"to": { "address": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431" },
+				"block": {
+					"number": "0x400",
+					"hash": "0x6b012339fbb85b70c58ecfd97b31950c4a28bcef5226e12dbe551cb1abaf3b4c",
+					"timestamp": "0x62a0b2a3",
+					"gasLimit": "0x1c9c380",
+					"gasUsed": "0x5208",
+					"baseFeePerGas": null,
+					"parent": { "hash": "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2" },
+					"transactions": [{ "hash": "0x1a1f797ee000c529b6a2dd330cedd0d081417a30d16a4eecb3f863ab4657246f" }]
+				}
+			}
+		}]
+	}
+}`
+
+func newTestGraphQLServer(t *testing.T, status int, body string) (string, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graphqlRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		assert.NoError(t, err)
+		assert.Equal(t, graphqlLogsQuery, req.Query)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	return server.URL + "/graphql", server.Close
+}
+
+func TestGetBlockRangeEventsGraphQLOK(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, true)
+	url, close := newTestGraphQLServer(t, 200, sampleGraphQLTransferLogs)
+	defer close()
+	l.c.graphqlURL = url
+	l.c.graphqlClient = resty.New()
+
+	ag := l.es.buildAggregatedListener([]*listener{l})
+	events, err := l.es.getBlockRangeEvents(context.Background(), ag, 1000, 1100)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	ei := events[0].Event.Info.(*eventInfo)
+	assert.Equal(t, int64(1024), ei.BlockNumber.BigInt().Int64())
+	assert.Equal(t, int64(64), ei.TransactionIndex.BigInt().Int64())
+	assert.Equal(t, int64(2), ei.LogIndex.BigInt().Int64())
+	assert.Equal(t, "transfer(address,uint256)", ei.InputMethod)
+	assert.Equal(t, "0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4", ei.InputSigner.String())
+	assert.Equal(t, int64(1654698659), events[0].Event.ID.Timestamp.Time().Unix())
+
+	// The block and transaction were cached from the GraphQL response, so no JSON/RPC calls were made
+	mRPC.AssertNotCalled(t, "CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything)
+	cached, ok := l.c.blockListener.blockCache.Get("0x6b012339fbb85b70c58ecfd97b31950c4a28bcef5226e12dbe551cb1abaf3b4c")
+	assert.True(t, ok)
+	assert.Equal(t, "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2", cached.(*blockInfoJSONRPC).ParentHash.String())
+	assert.Len(t, cached.(*blockInfoJSONRPC).Transactions, 1)
+
+}
+
+func TestGetBlockRangeEventsGraphQLFallback(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		status   int
+		body     string
+		disabled bool
+	}{
+		{name: "server error", status: 500, body: `{}`},
+		{name: "no data", status: 200, body: `{}`},
+		{name: "incomplete log", status: 200, body: `{"data":{"logs":[{"index":"0x0"}]}}`},
+		{name: "not found", status: 404, body: `{}`, disabled: true},
+		{name: "schema errors", status: 400, body: `{"errors":[{"message":"Validation error"},{"message":"unknown field"}]}`, disabled: true},
+		{name: "transient errors", status: 200, body: `{"errors":[{"message":"Timeout"}]}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, mRPC, _ := newTestListener(t, false)
+			url, close := newTestGraphQLServer(t, tc.status, tc.body)
+			defer close()
+			l.c.graphqlURL = url
+			l.c.graphqlClient = resty.New()
+
+			mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				*args[1].(*[]*logJSONRPC) = []*logJSONRPC{}
+			})
+
+			ag := l.es.buildAggregatedListener([]*listener{l})
+			events, err := l.es.getBlockRangeEvents(context.Background(), ag, 1000, 1100)
+			assert.NoError(t, err)
+			assert.Empty(t, events)
+			assert.Equal(t, !tc.disabled, l.c.graphqlEnabled())
+		})
+	}
+
+}
+
+func TestGetLogsGraphQLNoFeeMarket(t *testing.T) {
+
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graphqlRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		assert.NoError(t, err)
+		queries = append(queries, req.Query)
+		w.Header().Set("Content-Type", "application/json")
+		if req.Query == graphqlLogsQuery {
+			w.WriteHeader(400)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"Validation error (FieldUndefined@[logs/transaction/maxFeePerGas]) : Field 'maxFeePerGas' in type 'Transaction' is undefined"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(sampleGraphQLTransferLogs))
+	}))
+	defer server.Close()
+
+	l, _, _ := newTestListener(t, false)
+	l.c.graphqlURL = server.URL + "/graphql"
+	l.c.graphqlClient = resty.New()
+
+	for i := 0; i < 2; i++ {
+		logs, err := l.c.getLogsGraphQL(context.Background(), &logFilterJSONRPC{
+			FromBlock: ethtypes.NewHexInteger64(1000),
+			ToBlock:   ethtypes.NewHexInteger64(1100),
+		})
+		assert.NoError(t, err)
+		assert.Len(t, logs, 1)
+	}
+	assert.True(t, l.c.graphqlEnabled())
+	assert.Equal(t, []string{graphqlLogsQuery, graphqlLogsQueryNoFeeMarket, graphqlLogsQueryNoFeeMarket}, queries)
+
+}
+
+func TestGetLogsGraphQLRequestFail(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t)
+	defer done()
+	c.graphqlURL = "http://localhost:0/graphql"
+	c.graphqlClient = resty.New()
+
+	_, err := c.getLogsGraphQL(context.Background(), &logFilterJSONRPC{
+		FromBlock: ethtypes.NewHexInteger64(0),
+		ToBlock:   ethtypes.NewHexInteger64(100),
+	})
+	assert.Regexp(t, "FF23075", err)
+	assert.True(t, c.graphqlEnabled())
+
+}
+
+func TestGraphQLConfigured(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(GraphQLURL, "http://localhost:8547/graphql")
+	})
+	defer done()
+	assert.True(t, c.graphqlEnabled())
+	assert.NotNil(t, c.graphqlClient)
+
+	c.disableGraphQL(context.Background(), "test")
+	c.disableGraphQL(context.Background(), "test")
+	assert.False(t, c.graphqlEnabled())
+
+}
@@ -0,0 +1,282 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+)
+
+// The prefix of the error we return when no JSON/RPC response is received over the IPC socket, which
+// is treated in the same way as a failed HTTP request
+const ipcRequestFailedPrefix = "FF23169"
+
+// ipcBackend sends JSON/RPC requests over a unix domain socket, such as the geth.ipc socket of a co-located
+// node, rather than over HTTP. Requests are multiplexed over a single connection, with the responses
+// matched to the requests by ID. The connection is established on first use, and again after it fails.
+// The retry and concurrency settings of the HTTP client are applied to the requests.
+type ipcBackend struct {
+	path             string
+	dialTimeout      time.Duration
+	retry            bool
+	retryCount       int
+	retryInitDelay   time.Duration
+	retryMaxDelay    time.Duration
+	concurrencySlots chan bool
+	requestCounter   atomic.Int64
+	mux              sync.Mutex
+	conn             *ipcConnection
+}
+
+type ipcConnection struct {
+	conn     net.Conn
+	writeMux sync.Mutex
+	mux      sync.Mutex
+	pending  map[string]chan *rpcbackend.RPCResponse
+	closed   chan struct{}
+	err      error
+}
+
+func newIPCBackend(path string, dialTimeout time.Duration, httpConf *ffresty.Config, maxConcurrentRequests int64) *ipcBackend {
+	ib := &ipcBackend{
+		path:           path,
+		dialTimeout:    dialTimeout,
+		retry:          httpConf.Retry,
+		retryCount:     httpConf.RetryCount,
+		retryInitDelay: time.Duration(httpConf.RetryInitialDelay),
+		retryMaxDelay:  time.Duration(httpConf.RetryMaximumDelay),
+	}
+	if maxConcurrentRequests > 0 {
+		ib.concurrencySlots = make(chan bool, maxConcurrentRequests)
+	}
+	return ib
+}
+
+func (ib *ipcBackend) CallRPC(ctx context.Context, result interface{}, method string, params ...interface{}) *rpcbackend.RPCError {
+	rpcReq := &rpcbackend.RPCRequest{
+		JSONRpc: "2.0",
+		Method:  method,
+		Params:  make([]*fftypes.JSONAny, len(params)),
+	}
+	for i, param := range params {
+		b, err := json.Marshal(param)
+		if err != nil {
+			return &rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeInvalidRequest), Message: err.Error()}
+		}
+		rpcReq.Params[i] = fftypes.JSONAnyPtrBytes(b)
+	}
+	res, err := ib.SyncRequest(ctx, rpcReq)
+	if err != nil {
+		if res != nil && res.Error != nil && res.Error.Code != 0 {
+			return res.Error
+		}
+		return &rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeInternalError), Message: err.Error()}
+	}
+	if err := json.Unmarshal(res.Result.Bytes(), &result); err != nil {
+		return &rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeParseError), Message: err.Error()}
+	}
+	return nil
+}
+
+// SyncRequest sends a request over the socket and waits for the response. As with the HTTP client, the
+// returned response is populated on all paths, including errors.
+func (ib *ipcBackend) SyncRequest(ctx context.Context, rpcReq *rpcbackend.RPCRequest) (*rpcbackend.RPCResponse, error) {
+	if ib.concurrencySlots != nil {
+		select {
+		case ib.concurrencySlots <- true:
+		case <-ctx.Done():
+			err := i18n.NewError(ctx, msgs.MsgIPCRequestFailed, ctx.Err())
+			return rpcbackend.RPCErrorResponse(err, rpcReq.ID, rpcbackend.RPCCodeInternalError), err
+		}
+		defer func() {
+			<-ib.concurrencySlots
+		}()
+	}
+
+	// The back-end ID is always set, as requests from multiple front-end clients might have clashing IDs
+	beReq := *rpcReq
+	beReq.JSONRpc = "2.0"
+	reqID := fmt.Sprintf(`%.9d`, ib.requestCounter.Add(1))
+	beReq.ID = fftypes.JSONAnyPtr(`"` + reqID + `"`)
+	rpcTraceID := "ipc:" + reqID
+	if rpcReq.ID != nil {
+		rpcTraceID = fmt.Sprintf("%s->%s", rpcReq.ID, rpcTraceID)
+	}
+
+	log.L(ctx).Debugf("RPC[%s] --> %s", rpcTraceID, rpcReq.Method)
+	rpcStartTime := time.Now()
+	delay := ib.retryInitDelay
+	var rpcRes *rpcbackend.RPCResponse
+	var err error
+	for attempt := 0; ; attempt++ {
+		rpcRes, err = ib.send(ctx, reqID, &beReq)
+		if err == nil || !ib.retry || attempt >= ib.retryCount || ctx.Err() != nil {
+			break
+		}
+		log.L(ctx).Warnf("RPC[%s] <-- ERROR: %s (retrying after %s)", rpcTraceID, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		if delay *= 2; delay > ib.retryMaxDelay {
+			delay = ib.retryMaxDelay
+		}
+	}
+	if err != nil {
+		err = i18n.NewError(ctx, msgs.MsgIPCRequestFailed, err)
+		log.L(ctx).Errorf("RPC[%s] <-- ERROR: %s", rpcTraceID, err)
+		return rpcbackend.RPCErrorResponse(err, rpcReq.ID, rpcbackend.RPCCodeInternalError), err
+	}
+
+	// Restore the original ID
+	rpcRes.ID = rpcReq.ID
+	if rpcRes.Error != nil && rpcRes.Error.Code != 0 {
+		log.L(ctx).Errorf("RPC[%s] <-- %s", rpcTraceID, rpcRes.Message())
+		return rpcRes, fmt.Errorf("%s", rpcRes.Message())
+	}
+	log.L(ctx).Infof("RPC[%s] <-- %s OK (%.2fms)", rpcTraceID, rpcReq.Method, float64(time.Since(rpcStartTime))/float64(time.Millisecond))
+	if rpcRes.Result == nil {
+		rpcRes.Result = fftypes.JSONAnyPtr(fftypes.NullString)
+	}
+	return rpcRes, nil
+}
+
+// send writes the request to the socket, and waits for the response with the same ID
+func (ib *ipcBackend) send(ctx context.Context, reqID string, rpcReq *rpcbackend.RPCRequest) (*rpcbackend.RPCResponse, error) {
+	ic, err := ib.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(rpcReq)
+	if err != nil {
+		return nil, err
+	}
+	resChan := make(chan *rpcbackend.RPCResponse, 1)
+	ic.mux.Lock()
+	ic.pending[reqID] = resChan
+	ic.mux.Unlock()
+	defer func() {
+		ic.mux.Lock()
+		delete(ic.pending, reqID)
+		ic.mux.Unlock()
+	}()
+
+	ic.writeMux.Lock()
+	_, err = ic.conn.Write(b)
+	ic.writeMux.Unlock()
+	if err != nil {
+		ic.close(err)
+		return nil, err
+	}
+	select {
+	case rpcRes := <-resChan:
+		return rpcRes, nil
+	case <-ic.closed:
+		return nil, ic.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// connect returns the current connection, dialing a new one if there is none or it has failed
+func (ib *ipcBackend) connect(ctx context.Context) (*ipcConnection, error) {
+	ib.mux.Lock()
+	defer ib.mux.Unlock()
+	if ib.conn != nil {
+		select {
+		case <-ib.conn.closed:
+		default:
+			return ib.conn, nil
+		}
+	}
+	dialer := &net.Dialer{Timeout: ib.dialTimeout}
+	conn, err := dialer.DialContext(ctx, "unix", ib.path)
+	if err != nil {
+		return nil, err
+	}
+	log.L(ctx).Infof("Connected to JSON/RPC IPC socket %s", ib.path)
+	ib.conn = &ipcConnection{
+		conn:    conn,
+		pending: make(map[string]chan *rpcbackend.RPCResponse),
+		closed:  make(chan struct{}),
+	}
+	go ib.conn.readLoop()
+	return ib.conn, nil
+}
+
+// readLoop dispatches the responses on the socket to the waiting requests, until the connection fails
+func (ic *ipcConnection) readLoop() {
+	decoder := json.NewDecoder(ic.conn)
+	for {
+		var rpcRes *rpcbackend.RPCResponse
+		if err := decoder.Decode(&rpcRes); err != nil {
+			ic.close(err)
+			return
+		}
+		if rpcRes == nil || rpcRes.ID == nil {
+			// Notifications are not used, as subscriptions are not made over the socket
+			continue
+		}
+		var reqID string
+		_ = json.Unmarshal(rpcRes.ID.Bytes(), &reqID)
+		ic.mux.Lock()
+		resChan := ic.pending[reqID]
+		ic.mux.Unlock()
+		if resChan != nil {
+			select {
+			case resChan <- rpcRes:
+			default: // a duplicate response
+			}
+		}
+	}
+}
+
+func (ic *ipcConnection) close(err error) {
+	ic.mux.Lock()
+	defer ic.mux.Unlock()
+	select {
+	case <-ic.closed:
+	default:
+		log.L(context.Background()).Warnf("JSON/RPC IPC connection closed: %s", err)
+		ic.err = err
+		close(ic.closed)
+		_ = ic.conn.Close()
+	}
+}
+
+func (ib *ipcBackend) close() {
+	if ib == nil {
+		return
+	}
+	ib.mux.Lock()
+	defer ib.mux.Unlock()
+	if ib.conn != nil {
+		ib.conn.close(net.ErrClosed)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestIPCServer listens on a unix socket, and calls the handler for each request received on a connection.
+// The handler returns nil to close the connection without a response.
+func newTestIPCServer(t *testing.T, handler func(req *rpcbackend.RPCRequest) *rpcbackend.RPCResponse) string {
+	path := filepath.Join(t.TempDir(), "test.ipc")
+	listener, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				decoder := json.NewDecoder(conn)
+				var writeMux sync.Mutex
+				for {
+					var req *rpcbackend.RPCRequest
+					if err := decoder.Decode(&req); err != nil {
+						return
+					}
+					res := handler(req)
+					if res == nil {
+						return
+					}
+					res.ID = req.ID
+					b, _ := json.Marshal(res)
+					writeMux.Lock()
+					_, _ = conn.Write(b)
+					writeMux.Unlock()
+				}
+			}()
+		}
+	}()
+	return path
+}
+
+func newTestIPCBackend(path string, retries int) *ipcBackend {
+	return newIPCBackend(path, 1*time.Second, &ffresty.Config{
+		HTTPConfig: ffresty.HTTPConfig{
+			Retry:             retries > 0,
+			RetryCount:        retries,
+			RetryInitialDelay: fftypes.FFDuration(1 * time.Millisecond),
+			RetryMaximumDelay: fftypes.FFDuration(2 * time.Millisecond),
+		},
+	}, 10)
+}
+
+func TestIPCBackendCallRPC(t *testing.T) {
+
+	path := newTestIPCServer(t, func(req *rpcbackend.RPCRequest) *rpcbackend.RPCResponse {
+		switch req.Method {
+		case "eth_blockNumber":
+			return &rpcbackend.RPCResponse{JSONRpc: "2.0", Result: fftypes.JSONAnyPtr(`"0x3039"`)}
+		case "eth_getTransactionByHash":
+			return &rpcbackend.RPCResponse{JSONRpc: "2.0"}
+		default:
+			return &rpcbackend.RPCResponse{JSONRpc: "2.0", Error: &rpcbackend.RPCError{Code: -32601, Message: "method not found"}}
+		}
+	})
+	ib := newTestIPCBackend(path, 0)
+	defer ib.close()
+
+	// Requests are multiplexed over the connection
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var blockNumber ethtypes.HexInteger
+			rpcErr := ib.CallRPC(context.Background(), &blockNumber, "eth_blockNumber")
+			assert.Nil(t, rpcErr)
+			assert.Equal(t, int64(12345), blockNumber.BigInt().Int64())
+		}()
+	}
+	wg.Wait()
+
+	var txInfo *txInfoJSONRPC
+	rpcErr := ib.CallRPC(context.Background(), &txInfo, "eth_getTransactionByHash", "0x12345")
+	assert.Nil(t, rpcErr)
+	assert.Nil(t, txInfo)
+
+	rpcErr = ib.CallRPC(context.Background(), &txInfo, "eth_wrong")
+	assert.Equal(t, int64(-32601), rpcErr.Code)
+	assert.Equal(t, "method not found", rpcErr.Message)
+
+	var wrongType bool
+	rpcErr = ib.CallRPC(context.Background(), &wrongType, "eth_blockNumber")
+	assert.Equal(t, int64(rpcbackend.RPCCodeParseError), rpcErr.Code)
+
+	rpcErr = ib.CallRPC(context.Background(), &wrongType, "eth_blockNumber", map[bool]bool{true: true})
+	assert.Equal(t, int64(rpcbackend.RPCCodeInvalidRequest), rpcErr.Code)
+
+	// The front-end ID is restored on the response
+	res, err := ib.SyncRequest(context.Background(), &rpcbackend.RPCRequest{ID: fftypes.JSONAnyPtr(`"abc"`), Method: "eth_blockNumber"})
+	assert.NoError(t, err)
+	assert.Equal(t, `"abc"`, res.ID.String())
+
+}
+
+func TestIPCBackendReconnect(t *testing.T) {
+
+	var mux sync.Mutex
+	calls := 0
+	path := newTestIPCServer(t, func(req *rpcbackend.RPCRequest) *rpcbackend.RPCResponse {
+		mux.Lock()
+		defer mux.Unlock()
+		calls++
+		if calls == 1 {
+			return nil // drop the connection
+		}
+		return &rpcbackend.RPCResponse{JSONRpc: "2.0", Result: fftypes.JSONAnyPtr(`"0x1"`)}
+	})
+
+	ib := newTestIPCBackend(path, 1)
+	defer ib.close()
+	var blockNumber ethtypes.HexInteger
+	rpcErr := ib.CallRPC(context.Background(), &blockNumber, "eth_blockNumber")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(1), blockNumber.BigInt().Int64())
+	assert.Equal(t, 2, calls)
+
+}
+
+func TestIPCBackendFailures(t *testing.T) {
+
+	// Not listening, with retries
+	ib := newTestIPCBackend(filepath.Join(t.TempDir(), "missing.ipc"), 2)
+	var blockNumber ethtypes.HexInteger
+	rpcErr := ib.CallRPC(context.Background(), &blockNumber, "eth_blockNumber")
+	assert.Equal(t, int64(rpcbackend.RPCCodeInternalError), rpcErr.Code)
+	assert.Regexp(t, "^"+ipcRequestFailedPrefix, rpcErr.Message)
+
+	// The connection is dropped without a response
+	path := newTestIPCServer(t, func(req *rpcbackend.RPCRequest) *rpcbackend.RPCResponse { return nil })
+	ib = newTestIPCBackend(path, 0)
+	rpcErr = ib.CallRPC(context.Background(), &blockNumber, "eth_blockNumber")
+	assert.Regexp(t, "FF23169", rpcErr.Message)
+
+	// No response before the context is cancelled, and no slot available
+	blocked := make(chan struct{})
+	defer close(blocked)
+	path = newTestIPCServer(t, func(req *rpcbackend.RPCRequest) *rpcbackend.RPCResponse {
+		<-blocked
+		return nil
+	})
+	ib = newTestIPCBackend(path, 0)
+	defer ib.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	rpcErr = ib.CallRPC(ctx, &blockNumber, "eth_blockNumber")
+	assert.Regexp(t, "FF23169.*deadline", rpcErr.Message)
+	ib.concurrencySlots = make(chan bool)
+	rpcErr = ib.CallRPC(ctx, &blockNumber, "eth_blockNumber")
+	assert.Regexp(t, "FF23169.*deadline", rpcErr.Message)
+
+	// A failed request opens the circuit, as for HTTP
+	cb := newCircuitBreaker(newTestIPCBackend(filepath.Join(t.TempDir(), "missing.ipc"), 0), 1, 1*time.Hour)
+	_ = cb.CallRPC(context.Background(), &blockNumber, "eth_blockNumber")
+	assert.Equal(t, CircuitOpen, cb.state)
+
+	var nilBackend *ipcBackend
+	nilBackend.close()
+
+}
+
+func TestConnectorInitIPC(t *testing.T) {
+
+	config.RootConfigReset()
+	conf := config.RootSection("unittest")
+	InitConfig(conf)
+	conf.Set(IPCPath, "/tmp/geth.ipc")
+	conf.Set(BlockPollingInterval, "1h")
+	ctx, cancel := context.WithCancel(context.Background())
+	cc, err := NewEthereumConnector(ctx, conf)
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+	assert.Equal(t, c.ipc, c.backend)
+	assert.False(t, c.logsStreaming)
+	assert.Equal(t, 50, cap(c.ipc.concurrencySlots))
+
+	conf.Set(AdaptiveConcurrencyEnabled, true)
+	cc, err = NewEthereumConnector(ctx, conf)
+	assert.NoError(t, err)
+	c = cc.(*ethConnector)
+	assert.Equal(t, c.ipc, c.adaptiveConcurrency.Backend)
+	assert.Nil(t, c.ipc.concurrencySlots)
+
+	cancel()
+	c.WaitClosed()
+
+}
@@ -0,0 +1,324 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+
+	// Registers the postgres driver used for the leader lease
+	_ "github.com/lib/pq"
+)
+
+const (
+	leaseTableDDL = `CREATE TABLE IF NOT EXISTS evmconnect_leader_leases (
+		name    VARCHAR(64)  PRIMARY KEY,
+		holder  VARCHAR(256) NOT NULL,
+		expires TIMESTAMPTZ  NOT NULL
+	)`
+	// The lease is taken if it is free, held by us, or has expired. The clock of the database is used
+	// for the expiry, so the instances do not need synchronized clocks.
+	leaseAcquireSQL = `INSERT INTO evmconnect_leader_leases (name, holder, expires)
+		VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires = EXCLUDED.expires
+		WHERE evmconnect_leader_leases.holder = EXCLUDED.holder OR evmconnect_leader_leases.expires < now()`
+	leaseReleaseSQL = `DELETE FROM evmconnect_leader_leases WHERE name = $1 AND holder = $2`
+	// The checkpoints of an event stream, in the table FFTM persists them to
+	streamCheckpointsSQL = `SELECT listeners FROM checkpoints WHERE id = $1`
+)
+
+// leaderElector runs a warm standby mode, where instances of the connector sharing a lease in a Postgres
+// database elect a leader. Every instance keeps its block listener and canonical chain up to date, but only
+// the leader dispatches events and submits transactions. The lease is held in the database FFTM persists to,
+// so when a standby acquires the lease each event stream re-reads the checkpoints of its listeners from the
+// ones FFTM persisted, and discards the events it queried while on standby. It takes over quickly, without
+// re-delivering what the previous leader delivered.
+type leaderElector struct {
+	db            *sql.DB
+	name          string
+	instanceID    string
+	leaseDuration time.Duration
+	renewInterval time.Duration
+	leader        atomic.Bool
+	term          atomic.Int64 // incremented each time this instance is elected leader
+	lastRenewed   time.Time
+	tableReady    bool
+	loopDone      chan struct{}
+
+	mux     sync.Mutex
+	changed chan struct{} // closed, and replaced, each time leadership changes
+}
+
+func (c *ethConnector) initLeaderElection(ctx context.Context, conf config.Section) error {
+	dbURL := conf.GetString(LeaderElectionURL)
+	if dbURL == "" {
+		return nil
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return i18n.WrapError(ctx, err, msgs.MsgLeaderElectionFailed)
+	}
+	instanceID := conf.GetString(LeaderElectionInstanceID)
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+	c.leaderElection = &leaderElector{
+		db:            db,
+		name:          conf.GetString(LeaderElectionName),
+		instanceID:    instanceID,
+		leaseDuration: conf.GetDuration(LeaderElectionLeaseDuration),
+		renewInterval: conf.GetDuration(LeaderElectionRenewInterval),
+		loopDone:      make(chan struct{}),
+		changed:       make(chan struct{}),
+	}
+	log.L(ctx).Infof("Leader election enabled for '%s' as instance '%s'. Starting in standby", c.leaderElection.name, instanceID)
+	return nil
+}
+
+func (le *leaderElector) start(ctx context.Context) {
+	go le.electionLoop(ctx)
+}
+
+func (le *leaderElector) electionLoop(ctx context.Context) {
+	defer close(le.loopDone)
+	for {
+		le.renew(ctx)
+		select {
+		case <-time.After(le.renewInterval):
+		case <-ctx.Done():
+			le.release()
+			log.L(ctx).Debugf("Leader election loop exiting")
+			return
+		}
+	}
+}
+
+// renew acquires or extends the lease. We do not step down on an error while the lease we last renewed has
+// long enough to run that the next renew can still extend it, as a transient database error should not cause a
+// failover. Otherwise we step down before the lease expires in the database, and a standby can acquire it.
+func (le *leaderElector) renew(ctx context.Context) {
+	attempted := time.Now() // the database sets the expiry after this
+	acquired, err := le.tryAcquire(ctx)
+	if err != nil {
+		log.L(ctx).Errorf("Failed to renew leader lease '%s': %s", le.name, err)
+		if le.leader.Load() && !time.Now().Before(le.stepDownDeadline()) {
+			le.setLeader(ctx, false)
+		}
+		return
+	}
+	if acquired {
+		le.lastRenewed = attempted
+	}
+	le.setLeader(ctx, acquired)
+}
+
+// stepDownMargin allows for the time taken by a renew, and for the clocks of this instance and the database
+// running at different rates, when deciding if the next renew will be in time
+func (le *leaderElector) stepDownMargin() time.Duration {
+	return le.renewInterval / 2
+}
+
+// stepDownDeadline is the latest time the leader can fail to renew the lease, and still have the chance
+// to renew it once more before it expires
+func (le *leaderElector) stepDownDeadline() time.Time {
+	return le.lastRenewed.Add(le.leaseDuration - le.renewInterval - le.stepDownMargin())
+}
+
+func (le *leaderElector) tryAcquire(ctx context.Context) (bool, error) {
+	// A renew that hangs must not hold off stepping down, so is bounded well within the margin
+	ctx, cancel := context.WithTimeout(ctx, le.stepDownMargin()/2)
+	defer cancel()
+	if !le.tableReady {
+		if _, err := le.db.ExecContext(ctx, leaseTableDDL); err != nil {
+			return false, err
+		}
+		le.tableReady = true
+	}
+	res, err := le.db.ExecContext(ctx, leaseAcquireSQL, le.name, le.instanceID, le.leaseDuration.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows == 1, err
+}
+
+// release gives up the lease on shutdown, so that a standby can take over without waiting for it to expire
+func (le *leaderElector) release() {
+	if le.leader.Load() {
+		if _, err := le.db.Exec(leaseReleaseSQL, le.name, le.instanceID); err != nil {
+			log.L(context.Background()).Warnf("Failed to release leader lease '%s': %s", le.name, err)
+		}
+	}
+	le.setLeader(context.Background(), false)
+	_ = le.db.Close()
+}
+
+func (le *leaderElector) setLeader(ctx context.Context, leader bool) {
+	if le.leader.Swap(leader) == leader {
+		return
+	}
+	if leader {
+		le.term.Add(1)
+		log.L(ctx).Infof("Instance '%s' elected leader for '%s'. Dispatching events and submitting transactions", le.instanceID, le.name)
+	} else {
+		log.L(ctx).Warnf("Instance '%s' is no longer leader for '%s'. Entering standby", le.instanceID, le.name)
+	}
+	le.mux.Lock()
+	close(le.changed)
+	le.changed = make(chan struct{})
+	le.mux.Unlock()
+}
+
+// isLeader returns true if this instance is the leader, or leader election is disabled
+func (le *leaderElector) isLeader() bool {
+	return le == nil || le.leader.Load()
+}
+
+// waitLeader blocks while this instance is on standby
+func (le *leaderElector) waitLeader(ctx context.Context) (exiting bool) {
+	for !le.isLeader() {
+		le.mux.Lock()
+		changed := le.changed
+		le.mux.Unlock()
+		if le.isLeader() {
+			break
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return true
+		}
+	}
+	return false
+}
+
+// currentTerm returns the number of times this instance has been elected leader
+func (le *leaderElector) currentTerm() int64 {
+	if le == nil {
+		return 0
+	}
+	return le.term.Load()
+}
+
+// persistedCheckpoints reads the latest checkpoints of the listeners of a stream, as persisted by FFTM
+func (le *leaderElector) persistedCheckpoints(ctx context.Context, streamID *fftypes.UUID) (map[fftypes.UUID]*listenerCheckpoint, error) {
+	var listeners []byte
+	err := le.db.QueryRowContext(ctx, streamCheckpointsSQL, streamID.String()).Scan(&listeners)
+	if errors.Is(err, sql.ErrNoRows) {
+		return map[fftypes.UUID]*listenerCheckpoint{}, nil
+	} else if err != nil {
+		return nil, i18n.WrapError(ctx, err, msgs.MsgPersistedCheckpointsRead, streamID)
+	}
+	var checkpoints map[fftypes.UUID]*listenerCheckpoint
+	if err := json.Unmarshal(listeners, &checkpoints); err != nil {
+		return nil, i18n.WrapError(ctx, err, msgs.MsgPersistedCheckpointsRead, streamID)
+	}
+	return checkpoints, nil
+}
+
+// checkLeader refuses submission of transactions on a standby instance
+func (c *ethConnector) checkLeader(ctx context.Context) (ffcapi.ErrorReason, error) {
+	if !c.leaderElection.isLeader() {
+		return ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, msgs.MsgStandbyInstance, c.leaderElection.instanceID)
+	}
+	return "", nil
+}
+
+// syncedTerm returns the leadership term in which the checkpoints of the listeners were last read. It is captured
+// before querying for events, so a batch queried on standby is recognized as stale once this instance is elected.
+func (es *eventStream) syncedTerm() int64 {
+	es.mux.Lock()
+	defer es.mux.Unlock()
+	return es.leaderTerm
+}
+
+// waitLeader blocks while this instance is on standby, if there are events to dispatch. Once leader, the checkpoints
+// of the listeners are re-read if this instance has been elected since they were last read, and a batch queried
+// before that is stale - so must be discarded and queried again from the new checkpoints.
+func (es *eventStream) waitLeader(queriedTerm int64, wait bool) (exiting, stale bool) {
+	le := es.c.leaderElection
+	if le == nil {
+		return false, false
+	}
+	if wait && le.waitLeader(es.ctx) {
+		return true, false
+	}
+	for failCount := 0; le.isLeader(); failCount++ {
+		if es.c.doFailureDelay(es.ctx, failCount) {
+			return true, false
+		}
+		err := es.resyncCheckpoints()
+		if err == nil {
+			break
+		}
+		log.L(es.ctx).Errorf("Failed to resume event stream from persisted checkpoints: %s", err)
+	}
+	return false, es.syncedTerm() != queriedTerm
+}
+
+// resyncCheckpoints moves each listener to the checkpoint FFTM last persisted for it, once per leadership term.
+// A listener without a persisted checkpoint has not delivered any events, so stays where it is.
+func (es *eventStream) resyncCheckpoints() error {
+	le := es.c.leaderElection
+	term := le.currentTerm()
+	if es.syncedTerm() == term {
+		return nil
+	}
+	checkpoints, err := le.persistedCheckpoints(es.ctx, es.id)
+	if err != nil {
+		return err
+	}
+	es.mux.Lock()
+	defer es.mux.Unlock()
+	if es.leaderTerm == term {
+		return nil
+	}
+	for id, l := range es.listeners {
+		checkpoint := checkpoints[id]
+		l.hwmMux.Lock()
+		if checkpoint != nil {
+			l.hwmBlock = checkpoint.Block
+		}
+		l.lastDelivered = nil
+		l.continuityRescans = 0
+		l.delivered = nil
+		l.hwmMux.Unlock()
+		if checkpoint != nil {
+			es.wal.acknowledge(l.id, checkpoint)
+			log.L(es.ctx).Infof("Listener %s resuming from persisted checkpoint block %d", l.id, checkpoint.Block)
+		}
+	}
+	if es.dedupeCache != nil {
+		// Events this instance delivered in an earlier term might not have been checkpointed
+		es.dedupeCache.Purge()
+	}
+	es.leaderTerm = term
+	return nil
+}
@@ -0,0 +1,280 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLeaderElector(t *testing.T) (*leaderElector, sqlmock.Sqlmock) {
+	db, mdb, err := sqlmock.New()
+	assert.NoError(t, err)
+	return &leaderElector{
+		db:            db,
+		name:          "evmconnect",
+		instanceID:    "instance1",
+		leaseDuration: 15 * time.Second,
+		renewInterval: 5 * time.Second,
+		loopDone:      make(chan struct{}),
+		changed:       make(chan struct{}),
+	}, mdb
+}
+
+func TestLeaderElectionAcquireAndLose(t *testing.T) {
+
+	le, mdb := newTestLeaderElector(t)
+	ctx := context.Background()
+
+	mdb.ExpectExec("CREATE TABLE IF NOT EXISTS evmconnect_leader_leases").WillReturnResult(sqlmock.NewResult(0, 0))
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").
+		WithArgs("evmconnect", "instance1", int64(15000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	le.renew(ctx)
+	assert.True(t, le.isLeader())
+	assert.False(t, le.waitLeader(ctx))
+
+	// Another instance took over the expired lease
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").WillReturnResult(sqlmock.NewResult(0, 0))
+	le.renew(ctx)
+	assert.False(t, le.isLeader())
+
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.True(t, le.waitLeader(cancelledCtx))
+	assert.NoError(t, mdb.ExpectationsWereMet())
+
+}
+
+func TestLeaderElectionErrors(t *testing.T) {
+
+	le, mdb := newTestLeaderElector(t)
+	ctx := context.Background()
+
+	mdb.ExpectExec("CREATE TABLE IF NOT EXISTS evmconnect_leader_leases").WillReturnError(fmt.Errorf("pop"))
+	le.renew(ctx)
+	assert.False(t, le.isLeader())
+
+	mdb.ExpectExec("CREATE TABLE IF NOT EXISTS evmconnect_leader_leases").WillReturnResult(sqlmock.NewResult(0, 0))
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").WillReturnResult(sqlmock.NewResult(0, 1))
+	le.renew(ctx)
+	assert.True(t, le.isLeader())
+
+	// The leader keeps the lease through an error, while the next renew can still extend it
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").WillReturnError(fmt.Errorf("pop"))
+	le.renew(ctx)
+	assert.True(t, le.isLeader())
+
+	le.lastRenewed = time.Now().Add(-1 * time.Minute)
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").WillReturnResult(sqlmock.NewErrorResult(fmt.Errorf("pop")))
+	le.renew(ctx)
+	assert.False(t, le.isLeader())
+	assert.NoError(t, mdb.ExpectationsWereMet())
+
+}
+
+func TestLeaderElectionStepsDownBeforeLeaseExpires(t *testing.T) {
+
+	le, mdb := newTestLeaderElector(t)
+	ctx := context.Background()
+	le.tableReady = true
+	le.setLeader(ctx, true)
+
+	// With a 15s lease renewed every 5s, the leader steps down on a failure 7.5s after it last renewed - so it
+	// has stopped before a standby can acquire the lease at 15s, even if the next renew would have hung
+	le.lastRenewed = time.Now().Add(-7 * time.Second)
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").WillReturnError(fmt.Errorf("pop"))
+	le.renew(ctx)
+	assert.True(t, le.isLeader())
+
+	le.lastRenewed = time.Now().Add(-8 * time.Second)
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").WillReturnError(fmt.Errorf("pop"))
+	le.renew(ctx)
+	assert.False(t, le.isLeader())
+	assert.True(t, time.Now().Before(le.lastRenewed.Add(le.leaseDuration-le.stepDownMargin())))
+
+	// A renew that hangs times out within the margin
+	le.setLeader(ctx, true)
+	le.renewInterval = 100 * time.Millisecond
+	le.leaseDuration = 200 * time.Millisecond
+	le.lastRenewed = time.Now().Add(-50 * time.Millisecond)
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").WillDelayFor(1 * time.Second).WillReturnResult(sqlmock.NewResult(0, 1))
+	le.renew(ctx)
+	assert.False(t, le.isLeader())
+	assert.True(t, time.Now().Before(le.lastRenewed.Add(le.leaseDuration)))
+
+	// The expiry of a renewed lease is measured from before the renew was attempted
+	before := time.Now()
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").WillReturnResult(sqlmock.NewResult(0, 1))
+	le.renew(ctx)
+	assert.True(t, le.isLeader())
+	assert.False(t, le.lastRenewed.Before(before))
+	assert.NoError(t, mdb.ExpectationsWereMet())
+
+}
+
+func TestLeaderElectionLoopReleasesLease(t *testing.T) {
+
+	le, mdb := newTestLeaderElector(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mdb.ExpectExec("CREATE TABLE IF NOT EXISTS evmconnect_leader_leases").WillReturnResult(sqlmock.NewResult(0, 0))
+	mdb.ExpectExec("INSERT INTO evmconnect_leader_leases").WillReturnResult(sqlmock.NewResult(0, 1))
+	mdb.ExpectExec("DELETE FROM evmconnect_leader_leases").
+		WithArgs("evmconnect", "instance1").
+		WillReturnError(fmt.Errorf("pop"))
+	mdb.ExpectClose()
+
+	le.start(ctx)
+	assert.False(t, le.waitLeader(ctx))
+	cancel()
+	<-le.loopDone
+	assert.False(t, le.isLeader())
+	assert.NoError(t, mdb.ExpectationsWereMet())
+
+}
+
+func TestLeaderElectionStandbyRefusesSubmission(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	le, _ := newTestLeaderElector(t)
+	close(le.loopDone) // not started
+	c.leaderElection = le
+
+	_, err := c.checkSubmissionHealth(ctx)
+	assert.Regexp(t, "FF23154.*instance1", err)
+	_, reason, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{})
+	assert.Regexp(t, "FF23154", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+	_, _, err = c.DeployContracts(ctx, &DeployContractsRequest{})
+	assert.Regexp(t, "FF23154", err)
+
+	// Dispatch resumes once elected
+	go le.setLeader(ctx, true)
+	assert.False(t, le.waitLeader(ctx))
+	_, err = c.checkSubmissionHealth(ctx)
+	assert.NoError(t, err)
+
+}
+
+func TestInitLeaderElection(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(LeaderElectionURL, "postgres://localhost:1/evmconnect?sslmode=disable&connect_timeout=1")
+	})
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, c.leaderElection.instanceID)
+	assert.False(t, c.leaderElection.isLeader())
+	done()
+
+}
+
+func TestLeaderElectionTakeoverResumesFromPersistedCheckpoints(t *testing.T) {
+
+	l, _, cancelCtx := newTestListener(t, false)
+	defer cancelCtx()
+	es := l.es
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	es.events = events
+	le, mdb := newTestLeaderElector(t)
+	es.c.leaderElection = le
+	l.hwmBlock = 100
+	ag := &aggregatedListener{listeners: []*listener{l}}
+
+	// The standby reports no checkpoint, so FFTM keeps the one persisted by the leader
+	res, _, err := es.getListenerHWM(context.Background(), l.id)
+	assert.NoError(t, err)
+	assert.Nil(t, res.Checkpoint)
+
+	// A batch queried on standby is held, then discarded once elected
+	term := es.syncedTerm()
+	dispatched := make(chan bool)
+	go func() {
+		exiting, err := es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{
+			newContinuityEvent(l, 120, 0, 0, false),
+		}, 200, term)
+		assert.NoError(t, err)
+		dispatched <- exiting
+	}()
+	mdb.ExpectQuery("SELECT listeners FROM checkpoints").
+		WithArgs(es.id.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"listeners"}).AddRow(`{"` + l.id.String() + `":{"block":150,"transactionIndex":1,"logIndex":0}}`))
+	le.setLeader(context.Background(), true)
+	assert.False(t, <-dispatched)
+	assert.True(t, es.rescan)
+	assert.Empty(t, events)
+	assert.Equal(t, int64(150), l.hwmBlock)
+
+	// Queried again from the persisted checkpoint, the batch is dispatched
+	exiting, err := es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{
+		newContinuityEvent(l, 150, 2, 0, false),
+	}, 200, es.syncedTerm())
+	assert.False(t, exiting)
+	assert.NoError(t, err)
+	assert.False(t, es.rescan)
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(200), l.hwmBlock)
+	assert.NoError(t, mdb.ExpectationsWereMet())
+
+}
+
+func TestLeaderElectionTakeoverNoPersistedCheckpoint(t *testing.T) {
+
+	l, _, cancelCtx := newTestListener(t, false)
+	defer cancelCtx()
+	es := l.es
+	le, mdb := newTestLeaderElector(t)
+	es.c.leaderElection = le
+	l.hwmBlock = 100
+
+	le.setLeader(context.Background(), true)
+	mdb.ExpectQuery("SELECT listeners FROM checkpoints").WillReturnError(fmt.Errorf("pop"))
+	mdb.ExpectQuery("SELECT listeners FROM checkpoints").WillReturnError(sql.ErrNoRows)
+	exiting, stale := es.waitLeader(0, true)
+	assert.False(t, exiting)
+	assert.True(t, stale)
+	assert.Equal(t, int64(100), l.hwmBlock)
+	assert.NoError(t, mdb.ExpectationsWereMet())
+
+	// Invalid JSON
+	mdb.ExpectQuery("SELECT listeners FROM checkpoints").WillReturnRows(sqlmock.NewRows([]string{"listeners"}).AddRow(`!json`))
+	_, err := le.persistedCheckpoints(context.Background(), es.id)
+	assert.Regexp(t, "FF23195", err)
+	res, _, err := es.getListenerHWM(context.Background(), l.id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), res.Checkpoint.(*listenerCheckpoint).Block) // leader
+
+	// Stops retrying when the stream stops
+	le.setLeader(context.Background(), false)
+	le.setLeader(context.Background(), true)
+	cancelCtx()
+	exiting, _ = es.waitLeader(es.syncedTerm(), true)
+	assert.True(t, exiting)
+	assert.NoError(t, mdb.ExpectationsWereMet())
+
+}
@@ -0,0 +1,118 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type EventListenerAddressesRequest struct {
+	StreamID   *fftypes.UUID            `json:"streamId"`
+	ListenerID *fftypes.UUID            `json:"listenerId"`
+	Add        []*ethtypes.Address0xHex `json:"add,omitempty"`
+	Remove     []*ethtypes.Address0xHex `json:"remove,omitempty"`
+}
+
+type EventListenerAddressesResponse struct {
+	Addresses []*ethtypes.Address0xHex `json:"addresses"` // the updated set, to be stored as the addresses option of the listener
+}
+
+// listenerAddresses is the set of contract addresses a listener matches for filters that do not specify an
+// address. It is immutable, and replaced as a whole when updated, so it can be read without locking.
+type listenerAddresses struct {
+	list logFilterAddresses
+	set  map[ethtypes.Address0xHex]bool
+}
+
+func newListenerAddresses(addresses []*ethtypes.Address0xHex) *listenerAddresses {
+	la := &listenerAddresses{
+		list: make(logFilterAddresses, 0, len(addresses)),
+		set:  make(map[ethtypes.Address0xHex]bool, len(addresses)),
+	}
+	for _, a := range addresses {
+		if a != nil && !la.set[*a] {
+			la.set[*a] = true
+			la.list = append(la.list, a)
+		}
+	}
+	return la
+}
+
+func (la *listenerAddresses) update(add, remove []*ethtypes.Address0xHex) *listenerAddresses {
+	removeSet := newListenerAddresses(remove).set
+	updated := make([]*ethtypes.Address0xHex, 0, len(la.list)+len(add))
+	for _, addresses := range [][]*ethtypes.Address0xHex{la.list, add} {
+		for _, a := range addresses {
+			if a != nil && !removeSet[*a] {
+				updated = append(updated, a)
+			}
+		}
+	}
+	return newListenerAddresses(updated)
+}
+
+// matchesAddress checks a log against the address set of the listener, for filters that do not
+// specify their own address
+func (l *listener) matchesAddress(f *eventFilter, ethLog *logJSONRPC) bool {
+	la := l.addresses.Load()
+	if f.Address != nil || la == nil {
+		return true
+	}
+	return ethLog.Address != nil && la.set[*ethLog.Address]
+}
+
+// EventListenerUpdateAddresses adds and removes contract addresses on a running listener that was created with the
+// addresses or factory option, such as to track new contract instances created by a factory. The checkpoint of the listener
+// is retained, so events are delivered for added addresses from the current high water mark of the listener.
+func (c *ethConnector) EventListenerUpdateAddresses(ctx context.Context, req *EventListenerAddressesRequest) (*EventListenerAddressesResponse, ffcapi.ErrorReason, error) {
+	c.mux.Lock()
+	es := c.eventStreams[*req.StreamID]
+	c.mux.Unlock()
+	if es == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgStreamNotStarted, req.StreamID)
+	}
+
+	es.mux.Lock()
+	defer es.mux.Unlock()
+	l := es.listeners[*req.ListenerID]
+	if l == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgListenerNotStarted, req.ListenerID, es.id)
+	}
+	la := l.addresses.Load()
+	if la == nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgListenerNoAddresses, req.ListenerID)
+	}
+	updated := la.update(req.Add, req.Remove)
+	if len(updated.list) == 0 && l.factory == nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgListenerAddressesEmpty, req.ListenerID)
+	}
+	l.addresses.Store(updated)
+	// The aggregated filters are re-derived on the next poll, for the lead group and catchup groups
+	es.updateCount++
+	log.L(ctx).Infof("Listener '%s' addresses updated (added=%d removed=%d total=%d)", l.id, len(req.Add), len(req.Remove), len(updated.list))
+
+	return &EventListenerAddressesResponse{
+		Addresses: updated.list,
+	}, "", nil
+}
@@ -0,0 +1,165 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAddress1 = "0x20355f3e852d4b6a9944ada8d5399ddd3409a431"
+	testAddress2 = "0x302259069aaa5b10dc6f29a9a3f72a8e52837cc3"
+	testAddress3 = "0x87ae94ab290932c4e6269648bb47c86978af4436"
+)
+
+func testAddressesListenerReq(options string) *ffcapi.EventListenerAddRequest {
+	return &ffcapi.EventListenerAddRequest{
+		ListenerID: fftypes.NewUUID(),
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{
+				*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`),
+			},
+			Options:   fftypes.JSONAnyPtr(options),
+			FromBlock: strconv.Itoa(testHighBlock),
+		},
+	}
+}
+
+func TestEventListenerUpdateAddresses(t *testing.T) {
+
+	l1req := testAddressesListenerReq(`{"addresses":["` + testAddress1 + `","` + testAddress2 + `","` + testAddress1 + `"]}`)
+	es, _, _, done := testEventStream(t, l1req)
+	defer done()
+
+	l := es.listeners[*l1req.ListenerID]
+	ag := es.buildAggregatedListener([]*listener{l})
+	assert.Equal(t, logFilterAddresses{ethtypes.MustNewAddress(testAddress1), ethtypes.MustNewAddress(testAddress2)}, ag.addressSet)
+
+	es.mux.Lock()
+	updateCount := es.updateCount
+	es.mux.Unlock()
+	res, reason, err := es.c.EventListenerUpdateAddresses(context.Background(), &EventListenerAddressesRequest{
+		StreamID:   es.id,
+		ListenerID: l1req.ListenerID,
+		Add:        []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testAddress3), ethtypes.MustNewAddress(testAddress2)},
+		Remove:     []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testAddress1)},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testAddress2), ethtypes.MustNewAddress(testAddress3)}, res.Addresses)
+	es.mux.Lock()
+	assert.Equal(t, updateCount+1, es.updateCount)
+	es.mux.Unlock()
+
+	ag = es.buildAggregatedListener([]*listener{l})
+	assert.Equal(t, logFilterAddresses{ethtypes.MustNewAddress(testAddress2), ethtypes.MustNewAddress(testAddress3)}, ag.addressSet)
+
+	ethLog := sampleTransferLog() // from testAddress1
+	assert.False(t, l.matchesAddress(l.config.filters[0], ethLog))
+	ethLog.Address = ethtypes.MustNewAddress(testAddress3)
+	assert.True(t, l.matchesAddress(l.config.filters[0], ethLog))
+
+}
+
+func TestEventListenerAddressesMixedFilters(t *testing.T) {
+
+	l1req := testAddressesListenerReq(`{"addresses":["` + testAddress2 + `"]}`)
+	l2req := testAddressesListenerReq(`{"addresses":["` + testAddress2 + `"]}`)
+	l2req.Filters = []fftypes.JSONAny{
+		*fftypes.JSONAnyPtr(`{"address":"` + testAddress3 + `","event":` + abiTransferEvent + `}`),
+	}
+	es, _, _, done := testEventStream(t, l1req, l2req)
+	defer done()
+
+	l1 := es.listeners[*l1req.ListenerID]
+	l2 := es.listeners[*l2req.ListenerID]
+	ag := es.buildAggregatedListener([]*listener{l1, l2})
+	assert.ElementsMatch(t, logFilterAddresses{ethtypes.MustNewAddress(testAddress2), ethtypes.MustNewAddress(testAddress3)}, ag.addressSet)
+
+	// The filter address takes precedence over the listener addresses
+	ethLog := sampleTransferLog()
+	assert.True(t, l2.matchesAddress(l2.config.filters[0], ethLog))
+
+	// Listeners without the option still match all addresses
+	l3req := testAddressesListenerReq(`{}`)
+	l3, err := es.addEventListener(es.ctx, l3req)
+	assert.NoError(t, err)
+	assert.True(t, l3.matchesAddress(l3.config.filters[0], ethLog))
+	ag = es.buildAggregatedListener([]*listener{l1, l2, l3})
+	assert.Nil(t, ag.addressSet)
+
+}
+
+func TestFilterEnrichEthLogNotInListenerAddresses(t *testing.T) {
+
+	l1req := testAddressesListenerReq(`{"addresses":["` + testAddress2 + `"]}`)
+	es, _, _, done := testEventStream(t, l1req)
+	done() // stop it so we can safely call the listener directly
+
+	l := es.listeners[*l1req.ListenerID]
+	l.hwmBlock = 0
+	_, ok, err := l.filterEnrichEthLog(context.Background(), l.config.filters[0], nil, sampleTransferLog())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+}
+
+func TestEventListenerUpdateAddressesErrors(t *testing.T) {
+
+	l1req := testAddressesListenerReq(`{"addresses":["` + testAddress1 + `"]}`)
+	l2req := testAddressesListenerReq(`{}`)
+	es, _, _, done := testEventStream(t, l1req, l2req)
+	defer done()
+
+	_, reason, err := es.c.EventListenerUpdateAddresses(context.Background(), &EventListenerAddressesRequest{
+		StreamID:   fftypes.NewUUID(),
+		ListenerID: l1req.ListenerID,
+	})
+	assert.Regexp(t, "FF23041", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	_, reason, err = es.c.EventListenerUpdateAddresses(context.Background(), &EventListenerAddressesRequest{
+		StreamID:   es.id,
+		ListenerID: fftypes.NewUUID(),
+	})
+	assert.Regexp(t, "FF23043", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	_, reason, err = es.c.EventListenerUpdateAddresses(context.Background(), &EventListenerAddressesRequest{
+		StreamID:   es.id,
+		ListenerID: l2req.ListenerID,
+		Add:        []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testAddress2)},
+	})
+	assert.Regexp(t, "FF23085", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	_, reason, err = es.c.EventListenerUpdateAddresses(context.Background(), &EventListenerAddressesRequest{
+		StreamID:   es.id,
+		ListenerID: l1req.ListenerID,
+		Remove:     []*ethtypes.Address0xHex{ethtypes.MustNewAddress(testAddress1)},
+	})
+	assert.Regexp(t, "FF23086", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
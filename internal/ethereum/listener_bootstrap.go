@@ -0,0 +1,128 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// bootstrapOptions seed a new listener with the historic events of its contracts from an export of an external
+// indexer, rather than querying every block from the fromBlock of the listener
+type bootstrapOptions struct {
+	File string `json:"file"` // the name of an export file in the configured events.bootstrapDirectory
+}
+
+// bootstrapExport is the schema of an export file. The logs are in the format returned by eth_getLogs, and
+// are decoded and filtered in the same way as logs from the chain. The listener polls the chain from the
+// block after toBlock, so the export must include every log up to and including that block.
+type bootstrapExport struct {
+	ToBlock int64         `json:"toBlock"`
+	Logs    []*logJSONRPC `json:"logs"`
+}
+
+func (c *ethConnector) loadBootstrap(ctx context.Context, options *bootstrapOptions, fromBlock int64) (*bootstrapExport, error) {
+	if c.bootstrapDirectory == "" {
+		return nil, i18n.NewError(ctx, msgs.MsgBootstrapDisabled)
+	}
+	if options.File == "" || options.File != filepath.Base(options.File) {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidBootstrapFile, options.File, "must be the name of a file in the bootstrap directory")
+	}
+	b, err := os.ReadFile(filepath.Join(c.bootstrapDirectory, options.File))
+	if err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidBootstrapFile, options.File, err)
+	}
+	var export bootstrapExport
+	if err := json.Unmarshal(b, &export); err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidBootstrapFile, options.File, err)
+	}
+	if export.ToBlock < fromBlock {
+		return nil, i18n.NewError(ctx, msgs.MsgBootstrapBeforeFromBlock, options.File, export.ToBlock, fromBlock)
+	}
+	log.L(ctx).Infof("Loaded bootstrap export '%s' with %d logs up to block %d", options.File, len(export.Logs), export.ToBlock)
+	return &export, nil
+}
+
+// bootstrapListener delivers the events of a listener from its bootstrap export, and moves its high water mark
+// to the end of the export. Returns true if the stream is stopping.
+func (es *eventStream) bootstrapListener(ctx context.Context, l *listener) bool {
+	export := l.bootstrap
+	ag := es.buildAggregatedListener([]*listener{l})
+	var events ffcapi.ListenerEvents
+	for failCount := 0; ; failCount++ {
+		if es.c.doFailureDelay(ctx, failCount) {
+			return true
+		}
+		term := es.syncedTerm()
+		var err error
+		if events, err = es.decodeBootstrapLogs(ctx, ag, export); err != nil {
+			log.L(ctx).Errorf("Failed to decode bootstrap logs: %s", err)
+			continue
+		}
+		exiting, stale := es.waitLeader(term, true)
+		if exiting {
+			return true
+		}
+		if stale {
+			// Decoded again, skipping the events before the persisted checkpoint of the listener
+			continue
+		}
+		var batch ffcapi.ListenerEvents
+		// The export is a complete history up to its toBlock, so there is nothing to check its continuity against
+		if batch, _, err = es.filterBatch(nil, events); err == nil {
+			log.L(ctx).Infof("Listener bootstrap toBlock=%d logs=%d events=%d", export.ToBlock, len(export.Logs), len(events))
+			events = batch
+			break
+		}
+		log.L(ctx).Errorf("Failed to record bootstrap events in write-ahead log: %s", err)
+	}
+
+	for _, event := range events {
+		log.L(ctx).Debugf("Detected event %s (listener bootstrap)", event.Event)
+		select {
+		case es.events <- event:
+		case <-es.ctx.Done():
+			return true
+		}
+	}
+	l.moveHWM(export.ToBlock + 1)
+	l.bootstrap = nil
+	return false
+}
+
+func (es *eventStream) decodeBootstrapLogs(ctx context.Context, ag *aggregatedListener, export *bootstrapExport) (ffcapi.ListenerEvents, error) {
+	events := make(ffcapi.ListenerEvents, 0)
+	for _, ethLog := range export.Logs {
+		if ethLog.Removed || len(ethLog.Topics) == 0 || ethLog.BlockNumber == nil || ethLog.BlockNumber.BigInt().Int64() > export.ToBlock {
+			continue
+		}
+		var err error
+		if events, err = es.filterEnrichLog(ctx, ag, ethLog, events); err != nil {
+			return nil, err
+		}
+	}
+	sort.Sort(events)
+	return events, nil
+}
@@ -0,0 +1,168 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func writeTestBootstrapExport(t *testing.T, dir, name string, export *bootstrapExport) {
+	b, err := json.Marshal(export)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, name), b, 0600)
+	assert.NoError(t, err)
+}
+
+func testBootstrapExport() *bootstrapExport {
+	removed := sampleTransferLog()
+	removed.Removed = true
+	afterExport := sampleTransferLog()
+	afterExport.BlockNumber = ethtypes.NewHexInteger64(2000)
+	return &bootstrapExport{
+		ToBlock: 1500,
+		Logs:    []*logJSONRPC{sampleTransferLog(), removed, afterExport},
+	}
+}
+
+func TestLoadBootstrap(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, err := c.loadBootstrap(ctx, &bootstrapOptions{File: "export.json"}, 0)
+	assert.Regexp(t, "FF23176", err)
+
+	c.bootstrapDirectory = t.TempDir()
+	_, err = c.loadBootstrap(ctx, &bootstrapOptions{File: "../export.json"}, 0)
+	assert.Regexp(t, "FF23177.*must be the name of a file", err)
+
+	_, err = c.loadBootstrap(ctx, &bootstrapOptions{File: "export.json"}, 0)
+	assert.Regexp(t, "FF23177", err)
+
+	err = os.WriteFile(filepath.Join(c.bootstrapDirectory, "bad.json"), []byte(`!json`), 0600)
+	assert.NoError(t, err)
+	_, err = c.loadBootstrap(ctx, &bootstrapOptions{File: "bad.json"}, 0)
+	assert.Regexp(t, "FF23177", err)
+
+	writeTestBootstrapExport(t, c.bootstrapDirectory, "export.json", testBootstrapExport())
+	_, err = c.loadBootstrap(ctx, &bootstrapOptions{File: "export.json"}, 1501)
+	assert.Regexp(t, "FF23178.*1,500.*1,501", err)
+
+	export, err := c.loadBootstrap(ctx, &bootstrapOptions{File: "export.json"}, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1500), export.ToBlock)
+	assert.Len(t, export.Logs, 3)
+
+}
+
+func TestListenerBootstrapThenCatchup(t *testing.T) {
+
+	l, mRPC, cancelCtx := newTestListener(t, false)
+	l.c.eventBlockTimestamps = false
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	l.es.events = events
+	l.bootstrap = testBootstrapExport()
+
+	// Catchup continues from the block after the export
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		return f.FromBlock.BigInt().Int64() == 1501
+	})).Return(&rpcbackend.RPCError{Message: "pop"}).Run(func(args mock.Arguments) {
+		cancelCtx()
+	})
+
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
+
+	assert.Len(t, events, 1)
+	event := <-events
+	assert.Equal(t, fftypes.FFuint64(1024), event.Event.ID.BlockNumber)
+	assert.Equal(t, int64(1501), l.hwmBlock)
+	assert.Nil(t, l.bootstrap)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestListenerBootstrapStopping(t *testing.T) {
+
+	l, _, cancelCtx := newTestListener(t, false)
+	l.c.eventBlockTimestamps = false
+	l.es.events = make(chan *ffcapi.ListenerEvent) // nobody receiving
+	l.bootstrap = testBootstrapExport()
+	cancelCtx()
+
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
+	assert.NotNil(t, l.bootstrap)
+
+}
+
+func TestListenerBootstrapDecodeRetry(t *testing.T) {
+
+	l, mRPC, cancelCtx := newTestListener(t, true)
+	l.c.eventBlockTimestamps = false
+	l.bootstrap = testBootstrapExport()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).Return(&rpcbackend.RPCError{Message: "pop"}).Run(func(args mock.Arguments) {
+		cancelCtx()
+	})
+
+	assert.True(t, l.es.bootstrapListener(l.es.ctx, l))
+	assert.Equal(t, int64(0), l.hwmBlock)
+
+}
+
+func TestAddListenerWithBootstrap(t *testing.T) {
+
+	l, _, _ := newTestListener(t, false)
+	es := l.es
+	ctx := context.Background()
+	req := &ffcapi.EventListenerAddRequest{
+		ListenerID: fftypes.NewUUID(),
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{
+				*fftypes.JSONAnyPtr(`{"address":"0x20355f3E852D4b6a9944AdA8d5399dDD3409A431","event":` + abiTransferEvent + `}`),
+			},
+			Options:   fftypes.JSONAnyPtr(`{"bootstrap":{"file":"export.json"}}`),
+			FromBlock: "1000",
+		},
+	}
+
+	_, err := es.addEventListener(ctx, req)
+	assert.Regexp(t, "FF23176", err)
+
+	es.c.bootstrapDirectory = t.TempDir()
+	writeTestBootstrapExport(t, es.c.bootstrapDirectory, "export.json", testBootstrapExport())
+	es.c.orderedStreams = true
+	_, err = es.addEventListener(ctx, req)
+	assert.Regexp(t, "FF23179", err)
+
+	es.c.orderedStreams = false
+	added, err := es.addEventListener(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1500), added.bootstrap.ToBlock)
+	assert.Equal(t, int64(1000), added.hwmBlock)
+
+}
@@ -0,0 +1,86 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+const topicLength = 32
+
+// exclusionOptions are the addresses and topic values of events to drop, such as Transfer events from a burn address.
+// They are applied by the connector to the logs returned by the node, as eth_getLogs cannot express negative filters.
+type exclusionOptions struct {
+	Addresses []*ethtypes.Address0xHex      `json:"addresses,omitempty"` // Events emitted by any of these contracts are excluded
+	Topics    [][]ethtypes.HexBytes0xPrefix `json:"topics,omitempty"`    // Positional as in eth_getLogs, so topics[1] lists values of the first indexed parameter to exclude. Shorter values such as addresses are left padded to 32 bytes
+}
+
+// listenerExclusions is the parsed form of the exclusion options, for fast matching
+type listenerExclusions struct {
+	addresses map[ethtypes.Address0xHex]bool
+	topics    []map[string]bool // by position, with the 32 byte topic value as a string key
+}
+
+func newListenerExclusions(ctx context.Context, o *exclusionOptions) (*listenerExclusions, error) {
+	le := &listenerExclusions{
+		addresses: make(map[ethtypes.Address0xHex]bool, len(o.Addresses)),
+	}
+	for _, a := range o.Addresses {
+		if a != nil {
+			le.addresses[*a] = true
+		}
+	}
+	var err error
+	if le.topics, err = parseTopicValues(ctx, o.Topics, msgs.MsgInvalidExcludeTopic); err != nil {
+		return nil, err
+	}
+	return le, nil
+}
+
+// parseTopicValues left pads the values in each topic position to 32 bytes, as a set keyed by the padded value
+func parseTopicValues(ctx context.Context, topics [][]ethtypes.HexBytes0xPrefix, errKey i18n.ErrorMessageKey) ([]map[string]bool, error) {
+	sets := make([]map[string]bool, len(topics))
+	for i, values := range topics {
+		sets[i] = make(map[string]bool, len(values))
+		for _, v := range values {
+			if len(v) > topicLength {
+				return nil, i18n.NewError(ctx, errKey, i, v)
+			}
+			padded := make([]byte, topicLength)
+			copy(padded[topicLength-len(v):], v)
+			sets[i][string(padded)] = true
+		}
+	}
+	return sets, nil
+}
+
+// excludes returns true if the log was emitted by an excluded address, or has an excluded value in any topic position
+func (le *listenerExclusions) excludes(ethLog *logJSONRPC) bool {
+	if ethLog.Address != nil && le.addresses[*ethLog.Address] {
+		return true
+	}
+	for i, topic := range ethLog.Topics {
+		if i < len(le.topics) && le.topics[i][string(topic)] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerExclusions(t *testing.T) {
+
+	// Exclude transfers from the sender of the sample log, given as an address rather than a full topic
+	l1req := testAddressesListenerReq(`{"exclude":{"addresses":["` + testAddress2 + `"],"topics":[[],["0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4"]]}}`)
+	es, _, _, done := testEventStream(t, l1req)
+	done() // stop it so we can safely call the listener directly
+
+	l := es.listeners[*l1req.ListenerID]
+	l.hwmBlock = 0
+	ethLog := sampleTransferLog()
+	assert.True(t, l.exclusions.excludes(ethLog))
+	_, ok, err := l.filterEnrichEthLog(context.Background(), l.config.filters[0], nil, ethLog)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// The same value in another topic position is not excluded
+	ethLog.Topics[1], ethLog.Topics[2] = ethLog.Topics[2], ethLog.Topics[1]
+	assert.False(t, l.exclusions.excludes(ethLog))
+
+	ethLog.Address = ethtypes.MustNewAddress(testAddress2)
+	assert.True(t, l.exclusions.excludes(ethLog))
+
+}
+
+func TestListenerExclusionsBadTopic(t *testing.T) {
+
+	_, err := parseListenerOptions(context.Background(), fftypes.JSONAnyPtr(`{"exclude":{"topics":[["0x`+
+		`000000000000000000000000000000000000000000000000000000000000000000"]]}}`))
+	assert.Regexp(t, "FF23120", err)
+
+}
@@ -0,0 +1,190 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"sort"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// logIndex is an optional in-memory index, built as a side effect of the catchup queries of event streams.
+// For each contract address and event signature, it records the block ranges that have been scanned, and the
+// blocks within those ranges that contained a log. A listener created later with an old fromBlock can then skip
+// the ranges the index shows to be empty, rather than querying the whole history of the chain again.
+//
+// Only ranges at least catchupThreshold blocks behind the head of the chain are recorded, so re-orgs are not a concern.
+// An entry evicted from the index just means the ranges for that address/signature are queried again.
+//
+// The active blocks are held as ranges, so a contract that logs in every block does not grow an entry. Each entry
+// holds at most logIndexMaxActiveRanges of them, with the oldest forgotten - along with the scanned ranges up to them.
+type logIndex struct {
+	entries *lru.Cache // of *logIndexEntry, keyed by logIndexKey
+}
+
+// logIndexMaxActiveRanges bounds the memory used by each entry of the log index
+const logIndexMaxActiveRanges = 1000
+
+type logIndexEntry struct {
+	mux     sync.Mutex
+	scanned []blockRange // sorted, and merged so no two ranges overlap or are adjacent
+	active  []blockRange // the blocks containing at least one log, sorted and merged in the same way
+}
+
+type blockRange struct {
+	from int64
+	to   int64
+}
+
+func newLogIndex(size int) *logIndex {
+	entries, _ := lru.New(size) // only errors on a size <= 0
+	return &logIndex{entries: entries}
+}
+
+func logIndexKey(address *ethtypes.Address0xHex, topic0 ethtypes.HexBytes0xPrefix) string {
+	return address.String() + "/" + topic0.String()
+}
+
+func (li *logIndex) getEntry(key string, create bool) *logIndexEntry {
+	if e, ok := li.entries.Get(key); ok {
+		return e.(*logIndexEntry)
+	}
+	if !create {
+		return nil
+	}
+	e := &logIndexEntry{}
+	if existing, found, _ := li.entries.PeekOrAdd(key, e); found {
+		return existing.(*logIndexEntry)
+	}
+	return e
+}
+
+// record adds the results of a query of fromBlock->toBlock, for every address/signature pair in the filter.
+// The logs can be any superset of those in the range, as each is checked against the range.
+func (li *logIndex) record(ag *aggregatedListener, fromBlock, toBlock int64, logs []*logJSONRPC) {
+	activeBlocks := make(map[string][]int64)
+	for _, ethLog := range logs {
+		if ethLog.Address == nil || len(ethLog.Topics) == 0 || ethLog.BlockNumber == nil {
+			continue
+		}
+		block := ethLog.BlockNumber.Int64()
+		if block >= fromBlock && block <= toBlock {
+			key := logIndexKey(ethLog.Address, ethLog.Topics[0])
+			activeBlocks[key] = append(activeBlocks[key], block)
+		}
+	}
+	for _, a := range ag.addressSet {
+		for _, s := range ag.signatureSet {
+			key := logIndexKey(a, s)
+			li.getEntry(key, true).add(fromBlock, toBlock, activeBlocks[key])
+		}
+	}
+}
+
+// skipEmpty returns the first block at or after fromBlock that might contain a log for any address/signature
+// pair in the filter. That is fromBlock itself, unless every pair has a scanned range that includes fromBlock.
+func (li *logIndex) skipEmpty(ag *aggregatedListener, fromBlock int64) int64 {
+	next := int64(-1)
+	for _, a := range ag.addressSet {
+		for _, s := range ag.signatureSet {
+			e := li.getEntry(logIndexKey(a, s), false)
+			if e == nil {
+				return fromBlock
+			}
+			pairNext := e.nextCandidate(fromBlock)
+			if pairNext == fromBlock {
+				return fromBlock
+			}
+			if next < 0 || pairNext < next {
+				next = pairNext
+			}
+		}
+	}
+	if next < 0 {
+		return fromBlock
+	}
+	return next
+}
+
+func (e *logIndexEntry) add(fromBlock, toBlock int64, activeBlocks []int64) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	for _, b := range activeBlocks {
+		e.active = mergeBlockRange(e.active, blockRange{from: b, to: b})
+	}
+	e.scanned = mergeBlockRange(e.scanned, blockRange{from: fromBlock, to: toBlock})
+
+	// Forget the oldest blocks if there are too many active ranges. Blocks up to the last one forgotten are
+	// no longer known to be empty, so are no longer treated as scanned.
+	if excess := len(e.active) - logIndexMaxActiveRanges; excess > 0 {
+		forgetTo := e.active[excess-1].to
+		e.active = append([]blockRange(nil), e.active[excess:]...)
+		scanned := make([]blockRange, 0, len(e.scanned))
+		for _, r := range e.scanned {
+			if r.to > forgetTo {
+				r.from = max(r.from, forgetTo+1)
+				scanned = append(scanned, r)
+			}
+		}
+		e.scanned = scanned
+	}
+}
+
+// mergeBlockRange inserts a range into a sorted list, merging it with any ranges it overlaps or touches
+func mergeBlockRange(ranges []blockRange, newRange blockRange) []blockRange {
+	merged := make([]blockRange, 0, len(ranges)+1)
+	inserted := false
+	for _, r := range ranges {
+		switch {
+		case r.to+1 < newRange.from:
+			merged = append(merged, r)
+		case newRange.to+1 < r.from:
+			if !inserted {
+				merged = append(merged, newRange)
+				inserted = true
+			}
+			merged = append(merged, r)
+		default:
+			newRange.from = min(newRange.from, r.from)
+			newRange.to = max(newRange.to, r.to)
+		}
+	}
+	if !inserted {
+		merged = append(merged, newRange)
+	}
+	return merged
+}
+
+// nextCandidate returns the first block at or after fromBlock that is either not scanned, or contains a log
+func (e *logIndexEntry) nextCandidate(fromBlock int64) int64 {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	i := sort.Search(len(e.scanned), func(i int) bool { return e.scanned[i].to >= fromBlock })
+	if i == len(e.scanned) || e.scanned[i].from > fromBlock {
+		return fromBlock
+	}
+	next := e.scanned[i].to + 1
+	j := sort.Search(len(e.active), func(j int) bool { return e.active[j].to >= fromBlock })
+	if j < len(e.active) && e.active[j].from < next {
+		next = max(e.active[j].from, fromBlock)
+	}
+	return next
+}
@@ -0,0 +1,158 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+)
+
+// logSanitizer sits between a log query and the handler of the logs, holding back the logs of each block until
+// the block is complete. Some providers return duplicate or out of order log indexes during re-orgs (typically
+// by mixing the logs of two versions of the block), and as the protocol ID of an event is built from its log index
+// these would be delivered with corrupt ordering. When that is detected the block is queried again by hash.
+//
+// Logs must be supplied in block order, as returned by eth_getLogs.
+type logSanitizer struct {
+	ctx     context.Context
+	c       *ethConnector
+	filter  logFilterJSONRPC // the query, without the block range, to re-query a single block by hash
+	handler func(ethLog *logJSONRPC) error
+	block   []*logJSONRPC
+}
+
+func (c *ethConnector) newLogSanitizer(ctx context.Context, filter *logFilterJSONRPC, handler func(ethLog *logJSONRPC) error) *logSanitizer {
+	ls := &logSanitizer{
+		ctx:     ctx,
+		c:       c,
+		filter:  *filter,
+		handler: handler,
+	}
+	ls.filter.FromBlock = nil
+	ls.filter.ToBlock = nil
+	ls.filter.BlockHash = nil
+	return ls
+}
+
+// sanitizeLogs checks a complete set of logs, such as the result of a filter or a GraphQL query
+func (c *ethConnector) sanitizeLogs(ctx context.Context, filter *logFilterJSONRPC, ethLogs []*logJSONRPC) ([]*logJSONRPC, error) {
+	sanitized := make([]*logJSONRPC, 0, len(ethLogs))
+	ls := c.newLogSanitizer(ctx, filter, func(ethLog *logJSONRPC) error {
+		sanitized = append(sanitized, ethLog)
+		return nil
+	})
+	for _, ethLog := range ethLogs {
+		if err := ls.add(ethLog); err != nil {
+			return nil, err
+		}
+	}
+	if err := ls.flush(); err != nil {
+		return nil, err
+	}
+	return sanitized, nil
+}
+
+func (ls *logSanitizer) add(ethLog *logJSONRPC) error {
+	// Nothing to check for removed logs from a filter, which repeat the log index of the original
+	unchecked := ethLog.BlockNumber == nil || ethLog.LogIndex == nil || ethLog.Removed
+	if ls.block != nil && (unchecked || ls.block[0].BlockNumber.BigInt().Cmp(ethLog.BlockNumber.BigInt()) != 0) {
+		if err := ls.flush(); err != nil {
+			return err
+		}
+	}
+	if unchecked {
+		return ls.handler(ethLog)
+	}
+	ls.block = append(ls.block, ethLog)
+	return nil
+}
+
+// flush passes on the logs of the current block, after checking them. Must be called after the last log.
+func (ls *logSanitizer) flush() error {
+	block := ls.block
+	ls.block = nil
+	if anomaly := logIndexAnomaly(block); anomaly != "" {
+		var err error
+		if block, err = ls.c.requeryBlockLogs(ls.ctx, &ls.filter, block[len(block)-1], anomaly); err != nil {
+			return err
+		}
+	}
+	for _, ethLog := range block {
+		if err := ls.handler(ethLog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logIndexAnomaly returns a description of the first problem with the logs of a single block, or an empty string.
+// A log that is simply repeated is not a problem here, as the duplicate is suppressed on dispatch.
+func logIndexAnomaly(block []*logJSONRPC) string {
+	for i := 1; i < len(block); i++ {
+		prev, ethLog := block[i-1], block[i]
+		switch cmp := ethLog.LogIndex.BigInt().Cmp(prev.LogIndex.BigInt()); {
+		case !bytes.Equal(prev.BlockHash, ethLog.BlockHash):
+			return fmt.Sprintf("logs from blocks %s and %s", prev.BlockHash, ethLog.BlockHash)
+		case cmp == 0 && !sameLog(prev, ethLog):
+			return fmt.Sprintf("duplicate logIndex %s", ethLog.LogIndex)
+		case cmp < 0:
+			return fmt.Sprintf("logIndex %s after %s", ethLog.LogIndex, prev.LogIndex)
+		}
+	}
+	return ""
+}
+
+func sameLog(a, b *logJSONRPC) bool {
+	if !bytes.Equal(a.TransactionHash, b.TransactionHash) || !bytes.Equal(a.Data, b.Data) || len(a.Topics) != len(b.Topics) {
+		return false
+	}
+	for i := range a.Topics {
+		if !bytes.Equal(a.Topics[i], b.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// requeryBlockLogs queries the logs of a block by hash, taking the hash of the latest log returned for the block
+// as the one the provider has since moved to
+func (c *ethConnector) requeryBlockLogs(ctx context.Context, filter *logFilterJSONRPC, latest *logJSONRPC, anomaly string) ([]*logJSONRPC, error) {
+	c.logIndexAnomalies.Add(1)
+	log.L(ctx).Warnf("Log index anomaly in block %s (%s): %s - querying the block again by hash", latest.BlockNumber, latest.BlockHash, anomaly)
+
+	blockFilter := *filter
+	blockFilter.BlockHash = latest.BlockHash
+	var block []*logJSONRPC
+	err := c.getLogs(ctx, &blockFilter, func(ethLog *logJSONRPC) error {
+		if ethLog.LogIndex != nil {
+			block = append(block, ethLog)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if anomaly := logIndexAnomaly(block); anomaly != "" {
+		return nil, i18n.NewError(ctx, msgs.MsgLogIndexAnomaly, latest.BlockNumber, latest.BlockHash, anomaly)
+	}
+	return block, nil
+}
@@ -0,0 +1,157 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func sampleIndexedLog(blockNumber, logIndex int64, blockHash string) *logJSONRPC {
+	ethLog := sampleTransferLog()
+	ethLog.BlockNumber = ethtypes.NewHexInteger64(blockNumber)
+	ethLog.BlockHash = ethtypes.MustNewHexBytes0xPrefix(blockHash)
+	ethLog.LogIndex = ethtypes.NewHexInteger64(logIndex)
+	ethLog.TransactionHash = ethtypes.MustNewHexBytes0xPrefix(fmt.Sprintf("0x%.64x", blockNumber*1000+logIndex))
+	return ethLog
+}
+
+func byBlockHash(blockHash string) interface{} {
+	return mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		return f.BlockHash.String() == blockHash && f.FromBlock == nil && f.ToBlock == nil
+	})
+}
+
+func TestLogIndexAnomaly(t *testing.T) {
+
+	hashA, hashB := testBlockHash(1), testBlockHash(2)
+	assert.Empty(t, logIndexAnomaly(nil))
+	assert.Empty(t, logIndexAnomaly([]*logJSONRPC{sampleIndexedLog(1, 0, hashA), sampleIndexedLog(1, 2, hashA)}))
+	assert.Empty(t, logIndexAnomaly([]*logJSONRPC{sampleIndexedLog(1, 0, hashA), sampleIndexedLog(1, 0, hashA)}))
+
+	conflicting := sampleIndexedLog(1, 0, hashA)
+	conflicting.TransactionHash = sampleIndexedLog(1, 1, hashA).TransactionHash
+	assert.Equal(t, "duplicate logIndex 0x0", logIndexAnomaly([]*logJSONRPC{sampleIndexedLog(1, 0, hashA), conflicting}))
+	assert.Equal(t, "logIndex 0x1 after 0x2", logIndexAnomaly([]*logJSONRPC{sampleIndexedLog(1, 2, hashA), sampleIndexedLog(1, 1, hashA)}))
+	assert.Regexp(t, "logs from blocks", logIndexAnomaly([]*logJSONRPC{sampleIndexedLog(1, 0, hashA), sampleIndexedLog(1, 1, hashB)}))
+
+	differentTopics := sampleIndexedLog(1, 0, hashA)
+	differentTopics.Topics = differentTopics.Topics[0:1]
+	assert.False(t, sameLog(sampleIndexedLog(1, 0, hashA), differentTopics))
+	differentTopics.Topics = append(differentTopics.Topics, ethtypes.MustNewHexBytes0xPrefix("0xfeedbeef"), differentTopics.Topics[0])
+	assert.False(t, sameLog(sampleIndexedLog(1, 0, hashA), differentTopics))
+
+}
+
+func TestSanitizeLogsRequeriesBlockByHash(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	staleHash, blockHash := testBlockHash(1), testBlockHash(2)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", byBlockHash(blockHash)).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{sampleIndexedLog(1001, 0, blockHash), sampleIndexedLog(1001, 1, blockHash)}
+	}).Once()
+
+	// The logs of block 1001 are mixed from two versions of the block
+	removed := sampleIndexedLog(1000, 5, staleHash)
+	removed.Removed = true
+	sanitized, err := c.sanitizeLogs(ctx, &logFilterJSONRPC{FromBlock: ethtypes.NewHexInteger64(1000)}, []*logJSONRPC{
+		sampleIndexedLog(1000, 3, staleHash),
+		removed,
+		sampleIndexedLog(1001, 0, staleHash),
+		sampleIndexedLog(1001, 0, blockHash),
+		sampleIndexedLog(1001, 1, blockHash),
+		sampleIndexedLog(1002, 0, blockHash),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, sanitized, 5)
+	assert.Equal(t, removed, sanitized[1])
+	assert.Equal(t, blockHash, sanitized[2].BlockHash.String())
+	assert.Equal(t, int64(1), sanitized[3].LogIndex.BigInt().Int64())
+	assert.Equal(t, int64(1002), sanitized[4].BlockNumber.BigInt().Int64())
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil)
+	res, _, err := c.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, res.DownstreamDetails.String(), `"logIndexAnomalies":1`)
+
+}
+
+func TestSanitizeLogsRequeryStillInconsistent(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	blockHash := testBlockHash(1)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", byBlockHash(blockHash)).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{sampleIndexedLog(1001, 1, blockHash), sampleIndexedLog(1001, 0, blockHash)}
+	}).Once()
+
+	_, err := c.sanitizeLogs(ctx, &logFilterJSONRPC{}, []*logJSONRPC{sampleIndexedLog(1001, 1, blockHash), sampleIndexedLog(1001, 0, blockHash)})
+	assert.Regexp(t, "FF23175.*0x3e9", err)
+
+}
+
+func TestSanitizeLogsRequeryFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	blockHash := testBlockHash(1)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", byBlockHash(blockHash)).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+
+	_, err := c.sanitizeLogs(ctx, &logFilterJSONRPC{}, []*logJSONRPC{sampleIndexedLog(1001, 1, blockHash), sampleIndexedLog(1001, 0, blockHash)})
+	assert.Regexp(t, "pop", err)
+
+	// Errors from the handler are returned on flush
+	ls := c.newLogSanitizer(ctx, &logFilterJSONRPC{}, func(ethLog *logJSONRPC) error { return fmt.Errorf("handler") })
+	assert.NoError(t, ls.add(sampleIndexedLog(1001, 0, blockHash)))
+	assert.Regexp(t, "handler", ls.add(sampleIndexedLog(1002, 0, blockHash)))
+
+}
+
+func TestQueryBlockRangeEventsLogIndexAnomaly(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, false)
+	l.c.eventBlockTimestamps = false
+	ag := l.es.buildAggregatedListener([]*listener{l})
+
+	blockHash := testBlockHash(1024)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", byBlockHash(blockHash)).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{sampleIndexedLog(1024, 0, blockHash), sampleIndexedLog(1024, 1, blockHash)}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{sampleIndexedLog(1024, 1, blockHash), sampleIndexedLog(1024, 0, blockHash)}
+	}).Once()
+
+	events, err := l.es.queryBlockRangeEvents(context.Background(), ag, 1024, 1024)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, fftypes.FFuint64(0), events[0].Event.ID.LogIndex)
+	assert.Equal(t, fftypes.FFuint64(1), events[1].Event.ID.LogIndex)
+	assert.Equal(t, int64(1), l.c.logIndexAnomalies.Load())
+	mRPC.AssertExpectations(t)
+
+}
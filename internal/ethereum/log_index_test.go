@@ -0,0 +1,192 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testLogIndexListener() *aggregatedListener {
+	sampleLog := sampleTransferLog()
+	return &aggregatedListener{
+		addressSet:   logFilterAddresses{sampleLog.Address},
+		signatureSet: []ethtypes.HexBytes0xPrefix{sampleLog.Topics[0]},
+	}
+}
+
+func TestLogIndexConfig(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t)
+	assert.Nil(t, c.logIndex)
+	done()
+
+	_, c, _, done = newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsLogIndexSize, 100)
+	})
+	defer done()
+	assert.NotNil(t, c.logIndex)
+
+}
+
+func TestLogIndexRecordAndSkip(t *testing.T) {
+
+	li := newLogIndex(10)
+	al := testLogIndexListener()
+
+	// Nothing is known before the first query
+	assert.Equal(t, int64(0), li.skipEmpty(al, 0))
+
+	sampleLog := sampleTransferLog() // block 1024
+	otherLog := sampleTransferLog()
+	otherLog.Address = ethtypes.MustNewAddress("0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4")
+	otherLog.BlockNumber = ethtypes.NewHexInteger64(10)
+	li.record(al, 0, 1999, []*logJSONRPC{sampleLog, sampleLog, otherLog, {}})
+	li.record(al, 3000, 3999, nil)
+
+	assert.Equal(t, int64(1024), li.skipEmpty(al, 0))
+	assert.Equal(t, int64(1024), li.skipEmpty(al, 1024))
+	assert.Equal(t, int64(2000), li.skipEmpty(al, 1025))
+	assert.Equal(t, int64(2000), li.skipEmpty(al, 2000))
+	assert.Equal(t, int64(4000), li.skipEmpty(al, 3000))
+
+	// Filling the gap merges the ranges
+	li.record(al, 2000, 2999, nil)
+	assert.Equal(t, int64(4000), li.skipEmpty(al, 1025))
+	li.record(al, 5000, 5999, nil)
+	li.record(al, 4500, 4599, nil)
+	e := li.getEntry(logIndexKey(al.addressSet[0], al.signatureSet[0]), false)
+	assert.Equal(t, []blockRange{{0, 3999}, {4500, 4599}, {5000, 5999}}, e.scanned)
+	assert.Equal(t, []blockRange{{1024, 1024}}, e.active)
+
+	// The other address was not part of the query, so is not indexed
+	al.addressSet = append(al.addressSet, otherLog.Address)
+	assert.Equal(t, int64(0), li.skipEmpty(al, 0))
+
+	// An empty filter cannot be skipped
+	assert.Equal(t, int64(0), li.skipEmpty(&aggregatedListener{}, 0))
+
+}
+
+func TestLogIndexActiveRangesBounded(t *testing.T) {
+
+	li := newLogIndex(10)
+	al := testLogIndexListener()
+	key := logIndexKey(al.addressSet[0], al.signatureSet[0])
+	logAt := func(blocks ...int64) []*logJSONRPC {
+		logs := make([]*logJSONRPC, len(blocks))
+		for i, b := range blocks {
+			logs[i] = sampleTransferLog()
+			logs[i].BlockNumber = ethtypes.NewHexInteger64(b)
+		}
+		return logs
+	}
+
+	// Logs in consecutive blocks are held as a single range
+	li.record(al, 0, 99, logAt(10, 11, 12, 14))
+	e := li.getEntry(key, false)
+	assert.Equal(t, []blockRange{{10, 12}, {14, 14}}, e.active)
+	assert.Equal(t, int64(11), li.skipEmpty(al, 11))
+	assert.Equal(t, int64(14), li.skipEmpty(al, 13))
+	assert.Equal(t, int64(100), li.skipEmpty(al, 15))
+
+	// Logs in every other block fill the entry, and the oldest are forgotten
+	blocks := make([]int64, 0, logIndexMaxActiveRanges)
+	for b := int64(100); b < 100+2*logIndexMaxActiveRanges; b += 2 {
+		blocks = append(blocks, b)
+	}
+	li.record(al, 100, 100+2*logIndexMaxActiveRanges, logAt(blocks...))
+	assert.Len(t, e.active, logIndexMaxActiveRanges)
+	assert.Equal(t, blockRange{100, 100}, e.active[0])
+	assert.Equal(t, []blockRange{{15, 100 + 2*logIndexMaxActiveRanges}}, e.scanned)
+	assert.Equal(t, int64(0), li.skipEmpty(al, 0))
+	assert.Equal(t, int64(14), li.skipEmpty(al, 14))
+	assert.Equal(t, int64(100), li.skipEmpty(al, 15))
+	assert.Equal(t, int64(102), li.skipEmpty(al, 101))
+
+}
+
+func TestListenerCatchupSkipsEmptyRanges(t *testing.T) {
+
+	l, mRPC, cancelCtx := newTestListener(t, false)
+	events := make(chan *ffcapi.ListenerEvent, 10)
+	l.es.events = events
+	l.c.logIndex = newLogIndex(10)
+
+	// A previous catchup found a single event in the first 2000 blocks
+	al := l.es.buildAggregatedListener([]*listener{l})
+	l.c.logIndex.record(al, 0, 1999, []*logJSONRPC{sampleTransferLog()})
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1001),
+		}
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		return f.FromBlock.Int64() == 1024
+	})).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{sampleTransferLog()}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *logFilterJSONRPC) bool {
+		return f.FromBlock.Int64() == 2000
+	})).Return(&rpcbackend.RPCError{Message: "pop"}).Run(func(args mock.Arguments) {
+		cancelCtx()
+	}).Once()
+
+	l.es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
+
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(2000), l.hwmBlock)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestRecordLogIndexNearHead(t *testing.T) {
+
+	l, _, cancelCtx := newTestListener(t, false)
+	defer cancelCtx()
+	l.c.logIndex = newLogIndex(10)
+	al := l.es.buildAggregatedListener([]*listener{l})
+
+	// Blocks within the catchup threshold of the head could yet be re-org'd, so are not recorded
+	l.es.recordLogIndex(context.Background(), al, testHighBlock-10, testHighBlock, nil)
+	assert.Equal(t, 0, l.c.logIndex.entries.Len())
+
+	l.es.recordLogIndex(context.Background(), al, 0, testHighBlock, nil)
+	e := l.c.logIndex.getEntry(logIndexKey(al.addressSet[0], al.signatureSet[0]), false)
+	assert.Equal(t, []blockRange{{0, testHighBlock - l.c.catchupThreshold}}, e.scanned)
+
+	// A filter for all addresses is not recorded
+	al.addressSet = nil
+	l.es.recordLogIndex(context.Background(), al, 0, 100, nil)
+	assert.Equal(t, 1, l.c.logIndex.entries.Len())
+
+	// Nor is a query restricted by indexed values, which does not return every log of the address/signature pairs
+	l.c.logIndex = newLogIndex(10)
+	al = l.es.buildAggregatedListener([]*listener{l})
+	al.topics = append(al.topics, []ethtypes.HexBytes0xPrefix{ethtypes.MustNewHexBytes0xPrefix("0x0000000000000000000000000000000000000000000000000000000000000001")})
+	l.es.recordLogIndex(context.Background(), al, 0, testHighBlock, nil)
+	assert.Equal(t, 0, l.c.logIndex.entries.Len())
+
+}
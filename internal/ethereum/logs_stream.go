@@ -0,0 +1,258 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+)
+
+// logsResponseReader fails the read once more than the maximum size of response has been read
+type logsResponseReader struct {
+	ctx      context.Context
+	r        io.Reader
+	read     int64
+	max      int64
+	exceeded bool
+}
+
+func (lr *logsResponseReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	if lr.max > 0 && lr.read > lr.max {
+		lr.exceeded = true
+		return n, i18n.NewError(lr.ctx, msgs.MsgLogsResponseTooLarge, lr.max)
+	}
+	return n, err
+}
+
+// The prefix of the error we return when no response is received for a streamed eth_getLogs query, which
+// is treated in the same way as a failed HTTP request
+const logsRequestFailedPrefix = "FF23201"
+
+// logsStreamBackend is the JSON/RPC backend for an HTTP endpoint, at the bottom of the chain of wrappers that
+// apply the circuit breaker, concurrency limits, priorities and audit. It streams the response of an eth_getLogs
+// query that is made with a *logsStream result, and passes all other requests to the RPC client. The concurrency
+// limit of the HTTP client is applied here, rather than by the RPC client, so streamed queries share it.
+type logsStreamBackend struct {
+	rpcbackend.Backend
+	client           *resty.Client
+	maxResponseSize  int64
+	concurrencySlots chan bool
+	requestCounter   atomic.Int64
+}
+
+// logsStream is the result of a streamed eth_getLogs query, with the handler each log is passed to as it is decoded
+type logsStream struct {
+	handler    func(ethLog *logJSONRPC) error
+	handlerErr error                // set if the handler failed, ending the query
+	nodeErr    *rpcbackend.RPCError // set if the node returned a JSON/RPC error
+}
+
+func newLogsStreamBackend(client *resty.Client, maxResponseSize, maxConcurrentRequests int64) *logsStreamBackend {
+	lb := &logsStreamBackend{
+		Backend:         rpcbackend.NewRPCClient(client),
+		client:          client,
+		maxResponseSize: maxResponseSize,
+	}
+	if maxConcurrentRequests > 0 {
+		lb.concurrencySlots = make(chan bool, maxConcurrentRequests)
+	}
+	return lb
+}
+
+func (lb *logsStreamBackend) CallRPC(ctx context.Context, result interface{}, method string, params ...interface{}) *rpcbackend.RPCError {
+	if err := lb.acquire(ctx); err != nil {
+		return &rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeInternalError), Message: err.Error()}
+	}
+	defer lb.release()
+	if stream, ok := result.(*logsStream); ok && method == "eth_getLogs" {
+		return lb.streamLogs(ctx, stream, params)
+	}
+	return lb.Backend.CallRPC(ctx, result, method, params...)
+}
+
+func (lb *logsStreamBackend) SyncRequest(ctx context.Context, rpcReq *rpcbackend.RPCRequest) (*rpcbackend.RPCResponse, error) {
+	if err := lb.acquire(ctx); err != nil {
+		return rpcbackend.RPCErrorResponse(err, rpcReq.ID, rpcbackend.RPCCodeInternalError), err
+	}
+	defer lb.release()
+	return lb.Backend.SyncRequest(ctx, rpcReq)
+}
+
+func (lb *logsStreamBackend) acquire(ctx context.Context) error {
+	if lb.concurrencySlots == nil {
+		return nil
+	}
+	select {
+	case lb.concurrencySlots <- true:
+		return nil
+	case <-ctx.Done():
+		return i18n.NewError(ctx, i18n.MsgContextCanceled)
+	}
+}
+
+func (lb *logsStreamBackend) release() {
+	if lb.concurrencySlots != nil {
+		<-lb.concurrencySlots
+	}
+}
+
+// streamLogs decodes the logs incrementally from the HTTP response, rather than buffering the whole response, as
+// responses for busy contracts can be very large. The response size is limited by events.maxLogsResponseSize.
+func (lb *logsStreamBackend) streamLogs(ctx context.Context, stream *logsStream, params []interface{}) *rpcbackend.RPCError {
+	res, err := lb.client.R().
+		SetContext(ctx).
+		SetBody(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      lb.requestCounter.Add(1),
+			"method":  "eth_getLogs",
+			"params":  params,
+		}).
+		SetDoNotParseResponse(true).
+		Post("")
+	if err != nil {
+		return rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInternalError, msgs.MsgLogsRequestFailed, err)
+	}
+	body := res.RawBody()
+	defer body.Close()
+	if res.StatusCode() == http.StatusTooManyRequests {
+		return rpcbackend.NewRPCError(ctx, http.StatusTooManyRequests, msgs.MsgLogsQueryFailed, res.Status())
+	}
+
+	lr := &logsResponseReader{ctx: ctx, r: body, max: lb.maxResponseSize}
+	count, err := decodeLogsResponse(ctx, json.NewDecoder(lr), stream)
+	switch {
+	case lr.exceeded:
+		return rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInternalError, msgs.MsgLogsResponseTooLarge, lr.max)
+	case stream.nodeErr != nil:
+		return stream.nodeErr
+	case err != nil:
+		return &rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeInternalError), Message: err.Error()}
+	case res.IsError():
+		return rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInternalError, msgs.MsgLogsQueryFailed, res.Status())
+	}
+	log.L(ctx).Debugf("eth_getLogs returned %d logs in %d bytes", count, lr.read)
+	return nil
+}
+
+// getLogs runs an eth_getLogs query, calling the handler for each log. Over HTTP the logs are streamed from the
+// response by the logsStreamBackend, otherwise (such as over IPC) the whole response is decoded first.
+func (c *ethConnector) getLogs(ctx context.Context, filter *logFilterJSONRPC, handler func(ethLog *logJSONRPC) error) error {
+	if c.logsStreaming {
+		stream := &logsStream{handler: handler}
+		if rpcErr := c.backend.CallRPC(ctx, stream, "eth_getLogs", filter); rpcErr != nil {
+			if stream.handlerErr != nil {
+				return stream.handlerErr
+			}
+			return rpcErr.Error()
+		}
+		return nil
+	}
+
+	var ethLogs []*logJSONRPC
+	if rpcErr := c.backend.CallRPC(ctx, &ethLogs, "eth_getLogs", filter); rpcErr != nil {
+		return rpcErr.Error()
+	}
+	for _, ethLog := range ethLogs {
+		if err := handler(ethLog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeLogsResponse decodes a JSON/RPC response object one field at a time, and the result array one log at a time
+func decodeLogsResponse(ctx context.Context, dec *json.Decoder, stream *logsStream) (count int, err error) {
+	if err := expectLogsResponseDelim(ctx, dec, '{'); err != nil {
+		return 0, err
+	}
+	gotResult := false
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return count, i18n.NewError(ctx, msgs.MsgLogsQueryFailed, err)
+		}
+		switch key {
+		case "result":
+			gotResult = true
+			if count, err = decodeLogsResult(ctx, dec, stream); err != nil {
+				return count, err
+			}
+		case "error":
+			var rpcErr rpcbackend.RPCError
+			if err := dec.Decode(&rpcErr); err != nil {
+				return count, i18n.NewError(ctx, msgs.MsgLogsQueryFailed, err)
+			}
+			stream.nodeErr = &rpcErr
+			return count, rpcErr.Error()
+		default:
+			var ignored json.RawMessage
+			if err := dec.Decode(&ignored); err != nil {
+				return count, i18n.NewError(ctx, msgs.MsgLogsQueryFailed, err)
+			}
+		}
+	}
+	if !gotResult {
+		return count, i18n.NewError(ctx, msgs.MsgLogsQueryFailed, "missing result in response")
+	}
+	return count, nil
+}
+
+func decodeLogsResult(ctx context.Context, dec *json.Decoder, stream *logsStream) (count int, err error) {
+	t, err := dec.Token()
+	switch {
+	case err != nil:
+		return 0, i18n.NewError(ctx, msgs.MsgLogsQueryFailed, err)
+	case t == nil:
+		return 0, nil // a null result is returned by some nodes when there are no logs
+	case t != json.Delim('['):
+		return 0, i18n.NewError(ctx, msgs.MsgLogsQueryFailed, "unexpected token in response")
+	}
+	for dec.More() {
+		var ethLog logJSONRPC
+		if err := dec.Decode(&ethLog); err != nil {
+			return count, i18n.NewError(ctx, msgs.MsgLogsQueryFailed, err)
+		}
+		count++
+		if err := stream.handler(&ethLog); err != nil {
+			stream.handlerErr = err
+			return count, err
+		}
+	}
+	return count, expectLogsResponseDelim(ctx, dec, ']')
+}
+
+func expectLogsResponseDelim(ctx context.Context, dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return i18n.NewError(ctx, msgs.MsgLogsQueryFailed, err)
+	}
+	if t != delim {
+		return i18n.NewError(ctx, msgs.MsgLogsQueryFailed, "unexpected token in response")
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestLogsServer(t *testing.T, status int, body string) (*resty.Client, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		assert.NoError(t, err)
+		assert.Equal(t, "eth_getLogs", req["method"])
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	return resty.New().SetBaseURL(server.URL), server.Close
+}
+
+// setTestLogsServer streams eth_getLogs from the server, with all other requests sent to the mock backend
+func setTestLogsServer(c *ethConnector, client *resty.Client) *logsStreamBackend {
+	lb := newLogsStreamBackend(client, c.maxLogsResponseSize, 0)
+	lb.Backend = c.backend
+	c.backend = lb
+	c.logsStreaming = true
+	return lb
+}
+
+func testLogsResponse(t *testing.T, count int) string {
+	logs := make([]string, count)
+	for i := range logs {
+		b, err := json.Marshal(sampleTransferLog())
+		assert.NoError(t, err)
+		logs[i] = string(b)
+	}
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":[%s]}`, strings.Join(logs, ","))
+}
+
+func testLogsFilter() *logFilterJSONRPC {
+	return &logFilterJSONRPC{
+		FromBlock: ethtypes.NewHexInteger64(1000),
+		ToBlock:   ethtypes.NewHexInteger64(1100),
+	}
+}
+
+func TestGetBlockRangeEventsStreamedLogs(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, false)
+	client, close := newTestLogsServer(t, 200, testLogsResponse(t, 3))
+	defer close()
+	setTestLogsServer(l.c, client)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1024),
+		}
+	})
+
+	ag := l.es.buildAggregatedListener([]*listener{l})
+	events, err := l.es.getBlockRangeEvents(context.Background(), ag, 1000, 1100)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, int64(1024), events[0].Event.Info.(*eventInfo).BlockNumber.BigInt().Int64())
+	mRPC.AssertNotCalled(t, "CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything)
+
+}
+
+func TestGetLogsStreamed(t *testing.T) {
+
+	testCases := []struct {
+		name   string
+		status int
+		body   string
+		count  int
+		err    string
+	}{
+		{name: "ok", status: 200, body: testLogsResponse(t, 5), count: 5},
+		{name: "fields before result", status: 200, body: `{"id":1,"jsonrpc":"2.0","result":[]}`},
+		{name: "null result", status: 200, body: `{"jsonrpc":"2.0","id":1,"result":null}`},
+		{name: "rpc error", status: 200, body: `{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"query returned more than 10000 results"}}`, err: "more than 10000 results"},
+		{name: "server error", status: 500, body: `{"jsonrpc":"2.0","id":1}`, err: "FF23101.*missing result"},
+		{name: "gateway error", status: 502, body: `{}`, err: "FF23101"},
+		{name: "not json", status: 200, body: `<html>`, err: "FF23101"},
+		{name: "not an object", status: 200, body: `[]`, err: "FF23101"},
+		{name: "result not an array", status: 200, body: `{"result":{}}`, err: "FF23101"},
+		{name: "bad log", status: 200, body: `{"result":[{"blockNumber":false}]}`, err: "FF23101"},
+		{name: "truncated", status: 200, body: `{"result":[{}`, count: 1, err: "FF23101"},
+		{name: "bad error", status: 200, body: `{"error":"pop"}`, err: "FF23101"},
+		{name: "bad other field", status: 200, body: `{"id":[`, err: "FF23101"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, c, _, done := newTestConnector(t)
+			defer done()
+			client, close := newTestLogsServer(t, tc.status, tc.body)
+			defer close()
+			setTestLogsServer(c, client)
+
+			count := 0
+			err := c.getLogs(context.Background(), testLogsFilter(), func(ethLog *logJSONRPC) error {
+				count++
+				return nil
+			})
+			if tc.err != "" {
+				assert.Regexp(t, tc.err, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.count, count)
+		})
+	}
+
+}
+
+func TestGetLogsStreamedTooLarge(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(EventsMaxLogsResponseSize, "1kb")
+	})
+	defer done()
+	assert.Equal(t, int64(1024), c.maxLogsResponseSize)
+	client, close := newTestLogsServer(t, 200, testLogsResponse(t, 100))
+	defer close()
+	setTestLogsServer(c, client)
+
+	err := c.getLogs(context.Background(), testLogsFilter(), func(ethLog *logJSONRPC) error { return nil })
+	assert.Regexp(t, "FF23100", err)
+
+	// The error matches the default pattern, so catchup queries are reduced in size
+	assert.Regexp(t, regexp.MustCompile(DefaultEventsCatchupDownscaleRegex), err.Error())
+
+}
+
+func TestGetLogsStreamedHandlerError(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t)
+	defer done()
+	client, close := newTestLogsServer(t, 200, testLogsResponse(t, 5))
+	defer close()
+	setTestLogsServer(c, client)
+
+	count := 0
+	err := c.getLogs(context.Background(), testLogsFilter(), func(ethLog *logJSONRPC) error {
+		count++
+		return fmt.Errorf("pop")
+	})
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, 1, count)
+
+}
+
+func TestGetLogsStreamedRequestFail(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t)
+	defer done()
+	client, close := newTestLogsServer(t, 200, `{}`)
+	close()
+	setTestLogsServer(c, client)
+
+	err := c.getLogs(context.Background(), testLogsFilter(), func(ethLog *logJSONRPC) error { return nil })
+	assert.Regexp(t, "FF23201", err)
+
+}
+
+func TestGetLogsStreamedThrottled(t *testing.T) {
+
+	client, close := newTestLogsServer(t, 429, `rate limited`)
+	defer close()
+	lb := newLogsStreamBackend(client, 0, 0)
+
+	rpcErr := lb.CallRPC(context.Background(), &logsStream{}, "eth_getLogs", testLogsFilter())
+	assert.True(t, isThrottled(rpcErr))
+
+}
+
+func TestLogsStreamBackendWrapped(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t)
+	defer done()
+	client, close := newTestLogsServer(t, 200, `{}`)
+	close()
+	lb := setTestLogsServer(c, client)
+
+	// Streamed queries that get no response count towards opening the circuit
+	c.circuitBreaker = newCircuitBreaker(lb, 2, time.Hour)
+	c.backend = c.circuitBreaker
+	for i := 0; i < 3; i++ {
+		_ = c.getLogs(context.Background(), testLogsFilter(), func(ethLog *logJSONRPC) error { return nil })
+	}
+	assert.Equal(t, CircuitOpen, c.circuitBreaker.getState())
+	err := c.getLogs(context.Background(), testLogsFilter(), func(ethLog *logJSONRPC) error { return nil })
+	assert.Regexp(t, "FF23107", err)
+
+}
+
+func TestLogsStreamBackendConcurrency(t *testing.T) {
+
+	mRPC := &rpcbackendmocks.Backend{}
+	lb := newLogsStreamBackend(resty.New(), 0, 1)
+	lb.Backend = mRPC
+	mRPC.On("SyncRequest", mock.Anything, mock.Anything).Return(&rpcbackend.RPCResponse{}, nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil)
+
+	_, err := lb.SyncRequest(context.Background(), &rpcbackend.RPCRequest{})
+	assert.NoError(t, err)
+	assert.Nil(t, lb.CallRPC(context.Background(), nil, "eth_blockNumber"))
+
+	// The only slot is taken, such as by a streamed query
+	lb.concurrencySlots <- true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rpcErr := lb.CallRPC(ctx, &logsStream{}, "eth_getLogs", testLogsFilter())
+	assert.Regexp(t, "FF00154", rpcErr.Message)
+	_, err = lb.SyncRequest(ctx, &rpcbackend.RPCRequest{})
+	assert.Regexp(t, "FF00154", err)
+
+}
+
+func TestConnectorInitLogsStreaming(t *testing.T) {
+
+	config.RootConfigReset()
+	conf := config.RootSection("unittest")
+	InitConfig(conf)
+	conf.Set(ffresty.HTTPConfigURL, "http://localhost:8545")
+	conf.Set(MaxConcurrentRequests, 5)
+	conf.Set(BlockPollingInterval, "1h")
+	ctx, cancel := context.WithCancel(context.Background())
+	cc, err := NewEthereumConnector(ctx, conf)
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+	assert.True(t, c.logsStreaming)
+	assert.Equal(t, 5, cap(c.backend.(*logsStreamBackend).concurrencySlots))
+
+	cancel()
+	c.WaitClosed()
+
+}
@@ -19,9 +19,38 @@ package ethereum
 import (
 	"context"
 
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
+// NewBlockInfo is the header information for a new block, which allows fee logic to be
+// performed by the consumer without further JSON/RPC round trips
+type NewBlockInfo struct {
+	BlockNumber   *fftypes.FFBigInt `json:"blockNumber"`
+	BlockHash     string            `json:"blockHash"`
+	ParentHash    string            `json:"parentHash"`
+	Timestamp     *fftypes.FFBigInt `json:"timestamp"`
+	BaseFeePerGas *fftypes.FFBigInt `json:"baseFeePerGas,omitempty"` // omitted before the London fork
+	GasLimit      *fftypes.FFBigInt `json:"gasLimit"`
+	GasUsed       *fftypes.FFBigInt `json:"gasUsed"`
+}
+
+// NewBlockInfoEvent is the same notification as a ffcapi.BlockHashEvent, with the header information for each block
+type NewBlockInfoEvent struct {
+	ffcapi.BlockHashEvent
+	Blocks []*NewBlockInfo `json:"blocks"` // blocks no longer available (due to a re-org) are omitted
+}
+
+type NewBlockInfoListenerRequest struct {
+	ID              *fftypes.UUID             // unique identifier for this listener
+	ListenerContext context.Context           // context that will be cancelled when the listener is no longer required
+	BlockListener   chan<- *NewBlockInfoEvent // channel to deliver block info events to
+}
+
+type NewBlockInfoListenerResponse struct {
+}
+
 func (c *ethConnector) NewBlockListener(ctx context.Context, req *ffcapi.NewBlockListenerRequest) (*ffcapi.NewBlockListenerResponse, ffcapi.ErrorReason, error) {
 	// Add the block consumer
 	c.blockListener.addConsumer(&blockUpdateConsumer{
@@ -32,3 +61,42 @@ func (c *ethConnector) NewBlockListener(ctx context.Context, req *ffcapi.NewBloc
 
 	return &ffcapi.NewBlockListenerResponse{}, "", nil
 }
+
+// NewBlockInfoListener registers a consumer of new block notifications, in the same way as NewBlockListener,
+// but where each notification includes the header information (fees, gas and timestamp) of the blocks.
+// It is available to services embedding the connector, and streamed by the extensions API - the block events
+// consumed by FFTM, and so by its policy engine, are the unchanged ffcapi.BlockHashEvent notifications of NewBlockListener.
+func (c *ethConnector) NewBlockInfoListener(ctx context.Context, req *NewBlockInfoListenerRequest) (*NewBlockInfoListenerResponse, ffcapi.ErrorReason, error) {
+	c.blockListener.addConsumer(&blockUpdateConsumer{
+		id:          req.ID,
+		ctx:         req.ListenerContext,
+		infoUpdates: req.BlockListener,
+	})
+
+	return &NewBlockInfoListenerResponse{}, "", nil
+}
+
+func (bl *blockListener) buildNewBlockInfoEvent(update *ffcapi.BlockHashEvent) *NewBlockInfoEvent {
+	infoUpdate := &NewBlockInfoEvent{
+		BlockHashEvent: *update,
+		Blocks:         make([]*NewBlockInfo, 0, len(update.BlockHashes)),
+	}
+	for _, h := range update.BlockHashes {
+		// These will almost always be in the cache, as we have just used them to build the canonical chain
+		bi, err := bl.getBlockInfoByHash(bl.ctx, h)
+		if err != nil || bi == nil {
+			log.L(bl.ctx).Debugf("Block '%s' not available for block info notification: %v", h, err)
+			continue
+		}
+		infoUpdate.Blocks = append(infoUpdate.Blocks, &NewBlockInfo{
+			BlockNumber:   (*fftypes.FFBigInt)(bi.Number),
+			BlockHash:     bi.Hash.String(),
+			ParentHash:    bi.ParentHash.String(),
+			Timestamp:     (*fftypes.FFBigInt)(bi.Timestamp),
+			BaseFeePerGas: (*fftypes.FFBigInt)(bi.BaseFeePerGas),
+			GasLimit:      (*fftypes.FFBigInt)(bi.GasLimit),
+			GasUsed:       (*fftypes.FFBigInt)(bi.GasUsed),
+		})
+	}
+	return infoUpdate
+}
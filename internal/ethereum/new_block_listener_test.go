@@ -18,6 +18,7 @@ package ethereum
 
 import (
 	"testing"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
@@ -54,3 +55,76 @@ func TestNewBlockListenerOK(t *testing.T) {
 	assert.NotNil(t, res)
 
 }
+
+func TestNewBlockInfoListenerOK(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	c.blockListener.blockPollingInterval = 1 * time.Microsecond
+
+	block1000Hash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	block1001Hash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		hbh := args[1].(*ethtypes.HexInteger)
+		*hbh = *ethtypes.NewHexInteger64(1000)
+	}).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_newBlockFilter").Return(nil).Run(func(args mock.Arguments) {
+		hbh := args[1].(*string)
+		*hbh = "filter_id1"
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", "filter_id1").Return(nil).Run(func(args mock.Arguments) {
+		hbh := args[1].(*[]ethtypes.HexBytes0xPrefix)
+		*hbh = []ethtypes.HexBytes0xPrefix{block1001Hash}
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", "filter_id1").Return(nil).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", block1001Hash.String(), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number:        ethtypes.NewHexInteger64(1001),
+			Hash:          block1001Hash,
+			ParentHash:    block1000Hash,
+			Timestamp:     ethtypes.NewHexInteger64(1700000000),
+			BaseFeePerGas: ethtypes.NewHexInteger64(7),
+			GasLimit:      ethtypes.NewHexInteger64(30000000),
+			GasUsed:       ethtypes.NewHexInteger64(21000),
+		}
+	})
+
+	updates := make(chan *NewBlockInfoEvent)
+	res, _, err := c.NewBlockInfoListener(ctx, &NewBlockInfoListenerRequest{
+		ID:              fftypes.NewUUID(),
+		ListenerContext: ctx,
+		BlockListener:   updates,
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, res)
+
+	bu := <-updates
+	assert.Equal(t, []string{block1001Hash.String()}, bu.BlockHashes)
+	assert.Len(t, bu.Blocks, 1)
+	assert.Equal(t, int64(1001), bu.Blocks[0].BlockNumber.Int64())
+	assert.Equal(t, block1001Hash.String(), bu.Blocks[0].BlockHash)
+	assert.Equal(t, block1000Hash.String(), bu.Blocks[0].ParentHash)
+	assert.Equal(t, int64(1700000000), bu.Blocks[0].Timestamp.Int64())
+	assert.Equal(t, int64(7), bu.Blocks[0].BaseFeePerGas.Int64())
+	assert.Equal(t, int64(30000000), bu.Blocks[0].GasLimit.Int64())
+	assert.Equal(t, int64(21000), bu.Blocks[0].GasUsed.Int64())
+
+}
+
+func TestBuildNewBlockInfoEventBlockUnavailable(t *testing.T) {
+
+	_, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil)
+
+	infoUpdate := c.blockListener.buildNewBlockInfoEvent(&ffcapi.BlockHashEvent{
+		BlockHashes:  []string{fftypes.NewRandB32().String()},
+		GapPotential: true,
+	})
+	assert.True(t, infoUpdate.GapPotential)
+	assert.Len(t, infoUpdate.BlockHashes, 1)
+	assert.Empty(t, infoUpdate.Blocks)
+
+}
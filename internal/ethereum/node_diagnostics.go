@@ -0,0 +1,97 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type NodeDiagnosticsRequest struct {
+}
+
+type NodeDiagnosticsResponse struct {
+	ClientVersion  string            `json:"clientVersion,omitempty"`  // web3_clientVersion
+	NetworkID      string            `json:"networkId,omitempty"`      // net_version
+	PeerCount      *fftypes.FFBigInt `json:"peerCount,omitempty"`      // net_peerCount
+	Syncing        bool              `json:"syncing"`                  // true if eth_syncing reports the node is syncing
+	SyncStatus     *fftypes.JSONAny  `json:"syncStatus,omitempty"`     // the progress reported by eth_syncing, while syncing
+	LatestBlock    *fftypes.FFBigInt `json:"latestBlock,omitempty"`    // number of the "latest" block
+	SafeBlock      *fftypes.FFBigInt `json:"safeBlock,omitempty"`      // number of the "safe" block - not available on all chains
+	FinalizedBlock *fftypes.FFBigInt `json:"finalizedBlock,omitempty"` // number of the "finalized" block - not available on all chains
+	GasPrice       *fftypes.FFBigInt `json:"gasPrice,omitempty"`       // eth_gasPrice
+	Errors         map[string]string `json:"errors,omitempty"`         // the error of each JSON/RPC call that failed, keyed by the field it was for
+}
+
+// NodeDiagnostics gathers the state of the node from a set of JSON/RPC calls into a single response, for operator
+// dashboards and automated pre-flight checks. A failed call does not fail the operation, but is reported in the
+// errors of the response, as not every node supports every method (or the safe and finalized block tags).
+func (c *ethConnector) NodeDiagnostics(ctx context.Context, _ *NodeDiagnosticsRequest) (*NodeDiagnosticsResponse, ffcapi.ErrorReason, error) {
+	res := &NodeDiagnosticsResponse{}
+	recordErr := func(field string, rpcErr *rpcbackend.RPCError) bool {
+		if rpcErr == nil {
+			return true
+		}
+		log.L(ctx).Debugf("Node diagnostics %s failed: %s", field, rpcErr.Message)
+		if res.Errors == nil {
+			res.Errors = make(map[string]string)
+		}
+		res.Errors[field] = rpcErr.Message
+		return false
+	}
+
+	_ = recordErr("clientVersion", c.backend.CallRPC(ctx, &res.ClientVersion, "web3_clientVersion"))
+	_ = recordErr("networkId", c.backend.CallRPC(ctx, &res.NetworkID, "net_version"))
+
+	var peerCount ethtypes.HexInteger
+	if recordErr("peerCount", c.backend.CallRPC(ctx, &peerCount, "net_peerCount")) {
+		res.PeerCount = (*fftypes.FFBigInt)(&peerCount)
+	}
+
+	var syncing *fftypes.JSONAny // false, or an object describing sync progress
+	if recordErr("syncStatus", c.backend.CallRPC(ctx, &syncing, "eth_syncing")) && syncing != nil && syncing.String() != "false" {
+		res.Syncing = true
+		res.SyncStatus = syncing
+	}
+
+	for _, b := range []struct {
+		tag    string
+		field  string
+		target **fftypes.FFBigInt
+	}{
+		{"latest", "latestBlock", &res.LatestBlock},
+		{"safe", "safeBlock", &res.SafeBlock},
+		{"finalized", "finalizedBlock", &res.FinalizedBlock},
+	} {
+		var bi *blockInfoJSONRPC
+		if recordErr(b.field, c.backend.CallRPC(ctx, &bi, "eth_getBlockByNumber", b.tag, false)) && bi != nil {
+			*b.target = (*fftypes.FFBigInt)(bi.Number)
+		}
+	}
+
+	var gasPrice ethtypes.HexInteger
+	if recordErr("gasPrice", c.backend.CallRPC(ctx, &gasPrice, "eth_gasPrice")) {
+		res.GasPrice = (*fftypes.FFBigInt)(&gasPrice)
+	}
+
+	return res, "", nil
+}
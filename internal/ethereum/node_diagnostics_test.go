@@ -0,0 +1,112 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockDiagnosticsBlock(mRPC *rpcbackendmocks.Backend, tag string, blockNumber int64) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", tag, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{Number: ethtypes.NewHexInteger64(blockNumber)}
+	})
+}
+
+func TestNodeDiagnosticsOK(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "web3_clientVersion").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*string) = "Geth/v1.14.0"
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*string) = "1337"
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_peerCount").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexInteger) = *ethtypes.NewHexInteger64(25)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_syncing").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**fftypes.JSONAny) = fftypes.JSONAnyPtr(`{"currentBlock":"0x10","highestBlock":"0x20"}`)
+	})
+	mockDiagnosticsBlock(mRPC, "latest", 1000)
+	mockDiagnosticsBlock(mRPC, "safe", 968)
+	mockDiagnosticsBlock(mRPC, "finalized", 936)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_gasPrice").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexInteger) = *ethtypes.NewHexInteger64(12345)
+	})
+
+	res, _, err := c.NodeDiagnostics(ctx, &NodeDiagnosticsRequest{})
+	assert.NoError(t, err)
+	b, _ := json.Marshal(res)
+	assert.JSONEq(t, `{
+		"clientVersion": "Geth/v1.14.0",
+		"networkId": "1337",
+		"peerCount": "25",
+		"syncing": true,
+		"syncStatus": {"currentBlock":"0x10","highestBlock":"0x20"},
+		"latestBlock": "1000",
+		"safeBlock": "968",
+		"finalizedBlock": "936",
+		"gasPrice": "12345"
+	}`, string(b))
+
+}
+
+func TestNodeDiagnosticsPartialFailure(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "web3_clientVersion").Return(&rpcbackend.RPCError{Message: "method not found"})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*string) = "1337"
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_peerCount").Return(&rpcbackend.RPCError{Message: "method not found"})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_syncing").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**fftypes.JSONAny) = fftypes.JSONAnyPtr(`false`)
+	})
+	mockDiagnosticsBlock(mRPC, "latest", 1000)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "safe", false).Return(&rpcbackend.RPCError{Message: "unknown block"})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "finalized", false).Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_gasPrice").Return(&rpcbackend.RPCError{Message: "pop"})
+
+	res, _, err := c.NodeDiagnostics(ctx, &NodeDiagnosticsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "1337", res.NetworkID)
+	assert.False(t, res.Syncing)
+	assert.Nil(t, res.SyncStatus)
+	assert.Equal(t, int64(1000), res.LatestBlock.Int64())
+	assert.Nil(t, res.SafeBlock)
+	assert.Nil(t, res.FinalizedBlock)
+	assert.Nil(t, res.PeerCount)
+	assert.Equal(t, map[string]string{
+		"clientVersion": "method not found",
+		"peerCount":     "method not found",
+		"safeBlock":     "unknown block",
+		"gasPrice":      "pop",
+	}, res.Errors)
+
+}
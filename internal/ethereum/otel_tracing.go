@@ -0,0 +1,142 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/hyperledger/firefly-evmconnect"
+
+// The attributes of spans for the blocks they relate to
+const (
+	attrBlockNumber = attribute.Key("eth.block_number")
+	attrFromBlock   = attribute.Key("eth.from_block")
+	attrToBlock     = attribute.Key("eth.to_block")
+	attrEventCount  = attribute.Key("eth.event_count")
+)
+
+// initOTLPTracing sets up the export of OpenTelemetry spans over OTLP/HTTP, if configured.
+// Otherwise no spans are recorded.
+func (c *ethConnector) initOTLPTracing(ctx context.Context, conf config.Section) error {
+	url := conf.GetString(TracingOTLPURL)
+	if url == "" {
+		return nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(url))
+	if err != nil {
+		return i18n.WrapError(ctx, err, msgs.MsgOTLPExporterInit)
+	}
+	c.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(conf.GetString(TracingOTLPServiceName)))),
+	)
+	c.tracer = c.tracerProvider.Tracer(tracerName)
+	log.L(ctx).Infof("Exporting trace spans to %s", url)
+	return nil
+}
+
+// startSpan starts a span for an operation of the connector. An FFCAPI operation continues the trace
+// of the inbound request to the transaction manager, when it has a traceparent header.
+func (c *ethConnector) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, noop.Span{}
+	}
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		if headers, ok := ctx.Value(ffapi.CtxHeadersKey{}).(http.Header); ok {
+			ctx = propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(headers))
+		}
+	}
+	return c.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// flushSpans exports the spans that are still buffered, on shutdown
+func (c *ethConnector) flushSpans(ctx context.Context) {
+	if c.tracerProvider != nil {
+		if err := c.tracerProvider.Shutdown(ctx); err != nil {
+			log.L(ctx).Warnf("Failed to export trace spans: %s", err)
+		}
+	}
+}
+
+// tracedBackend records a client span for each JSON/RPC request sent to the node
+type tracedBackend struct {
+	rpcbackend.Backend
+	tracer trace.Tracer
+}
+
+func (tb *tracedBackend) CallRPC(ctx context.Context, result interface{}, method string, params ...interface{}) *rpcbackend.RPCError {
+	var filter *logFilterJSONRPC
+	if len(params) == 1 {
+		filter, _ = params[0].(*logFilterJSONRPC)
+	}
+	ctx, span := tb.tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(rpcSpanAttributes(method, filter)...))
+	rpcErr := tb.Backend.CallRPC(ctx, result, method, params...)
+	if rpcErr != nil {
+		endSpan(span, rpcErr.Error())
+	} else {
+		endSpan(span, nil)
+	}
+	return rpcErr
+}
+
+func (tb *tracedBackend) SyncRequest(ctx context.Context, rpcReq *rpcbackend.RPCRequest) (*rpcbackend.RPCResponse, error) {
+	ctx, span := tb.tracer.Start(ctx, rpcReq.Method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(rpcSpanAttributes(rpcReq.Method, nil)...))
+	rpcRes, err := tb.Backend.SyncRequest(ctx, rpcReq)
+	endSpan(span, err)
+	return rpcRes, err
+}
+
+// rpcSpanAttributes returns the attributes of the span of a JSON/RPC request, including the block range of a log query
+func rpcSpanAttributes(method string, filter *logFilterJSONRPC) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemKey.String("jsonrpc"),
+		semconv.RPCMethod(method),
+	}
+	if filter != nil && filter.FromBlock != nil {
+		attrs = append(attrs, attrFromBlock.Int64(filter.FromBlock.BigInt().Int64()))
+	}
+	if filter != nil && filter.ToBlock != nil {
+		attrs = append(attrs, attrToBlock.Int64(filter.ToBlock.BigInt().Int64()))
+	}
+	return attrs
+}
@@ -0,0 +1,147 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOTLPTracingInit(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(TracingOTLPURL, "http://localhost:4318/v1/traces")
+	})
+	defer done()
+	assert.NotNil(t, c.tracer)
+	assert.NotNil(t, c.tracerProvider)
+
+	c.flushSpans(context.Background())
+
+}
+
+func TestOTLPTracingReceiptSpans(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	sr := tracetest.NewSpanRecorder()
+	c.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer(tracerName)
+	c.backend = &tracedBackend{Backend: mRPC, tracer: c.tracer}
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte(sampleJSONRPCReceipt), args[1])
+			assert.NoError(t, err)
+		}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte("null"), args[1])
+			assert.NoError(t, err)
+		}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+
+	// The inbound request to the transaction manager had a traceparent
+	inboundTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx = context.WithValue(ctx, ffapi.CtxHeadersKey{}, http.Header{
+		"Traceparent": []string{fmt.Sprintf("00-%s-00f067aa0ba902b7-01", inboundTraceID)},
+	})
+
+	var req ffcapi.TransactionReceiptRequest
+	err := json.Unmarshal([]byte(sampleGetReceipt), &req)
+	assert.NoError(t, err)
+	_, _, err = c.TransactionReceipt(ctx, &req)
+	assert.NoError(t, err)
+	_, reason, err := c.TransactionReceipt(ctx, &req)
+	assert.Regexp(t, "FF23012", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+	_, _, err = c.TransactionReceipt(ctx, &req)
+	assert.Regexp(t, "pop", err)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 6)
+	rpcSpan, receiptSpan := spans[0], spans[1]
+	assert.Equal(t, "eth_getTransactionReceipt", rpcSpan.Name())
+	assert.Equal(t, trace.SpanKindClient, rpcSpan.SpanKind())
+	assert.Equal(t, receiptSpan.SpanContext().SpanID(), rpcSpan.Parent().SpanID())
+	assert.Equal(t, "TransactionReceipt", receiptSpan.Name())
+	assert.Equal(t, inboundTraceID, receiptSpan.SpanContext().TraceID().String())
+	assert.Contains(t, receiptSpan.Attributes(), attrBlockNumber.Int64(0x7b9))
+
+	// Not found is not an error of the operation
+	assert.Equal(t, codes.Unset, spans[3].Status().Code)
+	assert.Equal(t, codes.Error, spans[4].Status().Code)
+	assert.Equal(t, codes.Error, spans[5].Status().Code)
+
+}
+
+func TestOTLPTracingSyncRequest(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	sr := tracetest.NewSpanRecorder()
+	c.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer(tracerName)
+	c.backend = &tracedBackend{Backend: mRPC, tracer: c.tracer}
+
+	mRPC.On("SyncRequest", mock.Anything, mock.Anything).Return(&rpcbackend.RPCResponse{}, nil)
+
+	_, err := c.backend.SyncRequest(ctx, &rpcbackend.RPCRequest{Method: "eth_chainId"})
+	assert.NoError(t, err)
+	assert.Equal(t, "eth_chainId", sr.Ended()[0].Name())
+
+}
+
+func TestOTLPTracingLogFilterAttributes(t *testing.T) {
+
+	attrs := rpcSpanAttributes("eth_getLogs", &logFilterJSONRPC{
+		FromBlock: ethtypes.NewHexInteger64(1000),
+		ToBlock:   ethtypes.NewHexInteger64(1099),
+	})
+	assert.Contains(t, attrs, attrFromBlock.Int64(1000))
+	assert.Contains(t, attrs, attrToBlock.Int64(1099))
+
+}
+
+func TestOTLPTracingTraceparent(t *testing.T) {
+
+	sr := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer(tracerName)
+	ctx, span := tracer.Start(context.Background(), "eth_chainId")
+	defer span.End()
+
+	// The traceparent identifies the span recorded for the request
+	sc := span.SpanContext()
+	assert.Equal(t, fmt.Sprintf("00-%s-%s-01", sc.TraceID(), sc.SpanID()), buildTraceparent(ctx, ""))
+
+}
@@ -40,7 +40,31 @@ const (
 	txTypePrePrepared
 )
 
-func (c *ethConnector) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (res *ffcapi.TransactionPrepareResponse, reason ffcapi.ErrorReason, err error) {
+// DecodedCallData echoes back the method and parameters that were encoded into the call data of a transaction,
+// decoded again from the call data itself, so that encoding mistakes can be caught before submission
+type DecodedCallData struct {
+	Method       string           `json:"method"` // the full signature, such as "transfer(address,uint256)"
+	Name         string           `json:"name"`
+	Selector     string           `json:"selector"`
+	CallDataSize int              `json:"callDataSize"`
+	Params       *fftypes.JSONAny `json:"params"`
+}
+
+type TransactionPrepareDecodedResponse struct {
+	ffcapi.TransactionPrepareResponse
+	Decoded *DecodedCallData `json:"decoded"`
+}
+
+func (c *ethConnector) TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := c.TransactionPrepareDecoded(ctx, req)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &res.TransactionPrepareResponse, "", nil
+}
+
+// TransactionPrepareDecoded is TransactionPrepare, with the decoded call data included in the response
+func (c *ethConnector) TransactionPrepareDecoded(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (res *TransactionPrepareDecodedResponse, reason ffcapi.ErrorReason, err error) {
 
 	// Parse the input JSON data, to build the call data
 	callData, method, err := c.prepareCallData(ctx, &req.TransactionInput)
@@ -63,15 +87,41 @@ func (c *ethConnector) TransactionPrepare(ctx context.Context, req *ffcapi.Trans
 	if req.Gas, reason, err = c.ensureGasEstimate(ctx, tx, method, errors, req.Gas); err != nil {
 		return nil, reason, err
 	}
-	log.L(ctx).Infof("Prepared transaction method=%s dataLen=%d gas=%s", method.String(), len(callData), req.Gas.Int())
-
-	return &ffcapi.TransactionPrepareResponse{
-		Gas:             req.Gas,
-		TransactionData: ethtypes.HexBytes0xPrefix(callData).String(),
+	decoded, err := c.decodeCallData(ctx, method, callData)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	log.L(ctx).Infof("Prepared transaction method=%s selector=%s dataLen=%d gas=%s", decoded.Method, decoded.Selector, len(callData), req.Gas.Int())
+
+	return &TransactionPrepareDecodedResponse{
+		TransactionPrepareResponse: ffcapi.TransactionPrepareResponse{
+			Gas:             req.Gas,
+			TransactionData: ethtypes.HexBytes0xPrefix(callData).String(),
+		},
+		Decoded: decoded,
 	}, "", nil
 
 }
 
+func (c *ethConnector) decodeCallData(ctx context.Context, method *abi.Entry, callData []byte) (*DecodedCallData, error) {
+	v, err := method.DecodeCallDataCtx(ctx, callData)
+	var b []byte
+	if err == nil {
+		// Always objects keyed by parameter name, whatever the configured data format, so the output is canonical
+		b, err = c.newSerializer().SetFormattingMode(abi.FormatAsObjects).SerializeJSONCtx(ctx, v)
+	}
+	if err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgDecodeCallDataFailed, method.String(), err)
+	}
+	return &DecodedCallData{
+		Method:       method.String(),
+		Name:         method.Name,
+		Selector:     ethtypes.HexBytes0xPrefix(method.FunctionSelectorBytes()).String(),
+		CallDataSize: len(callData),
+		Params:       fftypes.JSONAnyPtrBytes(b),
+	}, nil
+}
+
 func buildErrorsABI(ctx context.Context, errorSpecs []*fftypes.JSONAny) ([]*abi.Entry, error) {
 	errors := make([]*abi.Entry, len(errorSpecs))
 	for i, e := range errorSpecs {
@@ -104,21 +154,8 @@ func (c *ethConnector) prepareCallData(ctx context.Context, req *ffcapi.Transact
 		return nil, nil, i18n.NewError(ctx, msgs.MsgUnmarshalABIMethodFail, err)
 	}
 
-	// Parse the params into the standard semantics of Go JSON unmarshalling, with []interface{}
-	ethParams := make([]interface{}, len(req.Params))
-	for i, p := range req.Params {
-		if p != nil {
-			err := json.Unmarshal([]byte(*p), &ethParams[i])
-			if err != nil {
-				return nil, nil, i18n.NewError(ctx, msgs.MsgUnmarshalParamFail, i, err)
-			}
-		}
-	}
-
-	// Match the parameters to the ABI call data for the method.
-	// Note the FireFly ABI decoding package handles formatting errors / translation etc.
 	var callData []byte
-	paramValues, err := method.Inputs.ParseExternalDataCtx(ctx, ethParams)
+	paramValues, err := parseABIParams(ctx, method, req.Params)
 	if err == nil {
 		callData, err = method.EncodeCallDataCtx(ctx, paramValues)
 	}
@@ -148,11 +185,15 @@ func (c *ethConnector) buildTx(ctx context.Context, txType txType, fromString, t
 		tx.From = json.RawMessage(fmt.Sprintf(`"%s"`, from))
 	}
 
-	// Parse the to address - required for preparing an invoke, and must be valid if set
+	// Parse the to address - required for preparing an invoke, and must be valid if set (or an ENS name, if enabled)
 	var to *ethtypes.Address0xHex
 	if txType != txTypeDeployContract && (txType != txTypePrePrepared || toString != "") {
-		to, err = ethtypes.NewAddress(toString)
-		if err != nil {
+		if c.isENSName(toString) {
+			to, err = c.resolveENSName(ctx, toString)
+			if err != nil {
+				return nil, err
+			}
+		} else if to, err = ethtypes.NewAddress(toString); err != nil {
 			return nil, i18n.NewError(ctx, msgs.MsgInvalidToAddress, toString, err)
 		}
 		tx.To = to
@@ -21,7 +21,9 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/hyperledger/firefly-common/pkg/config"
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
 	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
 	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
 	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
@@ -198,6 +200,61 @@ func TestPrepareTransactionWithEstimate(t *testing.T) {
 
 }
 
+func TestPrepareTransactionDecoded(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ConfigDataFormat, "flat_array")
+	})
+	defer done()
+
+	var req ffcapi.TransactionPrepareRequest
+	err := json.Unmarshal([]byte(samplePrepareTXEstimateGas), &req)
+	assert.NoError(t, err)
+	req.Gas = fftypes.NewFFBigInt(50000)
+	res, reason, err := c.TransactionPrepareDecoded(ctx, &req)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+	assert.Equal(t, "0x60fe47b100000000000000000000000000000000000000000000000000000000feedbeef", res.TransactionData)
+	assert.Equal(t, &DecodedCallData{
+		Method:       "set(uint256)",
+		Name:         "set",
+		Selector:     "0x60fe47b1",
+		CallDataSize: 36,
+		Params:       fftypes.JSONAnyPtr(`{"x":"4276993775"}`),
+	}, res.Decoded)
+
+	b, err := json.Marshal(res)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"gas": "50000",
+		"transactionData": "0x60fe47b100000000000000000000000000000000000000000000000000000000feedbeef",
+		"decoded": {
+			"method": "set(uint256)",
+			"name": "set",
+			"selector": "0x60fe47b1",
+			"callDataSize": 36,
+			"params": {"x":"4276993775"}
+		}
+	}`, string(b))
+
+}
+
+func TestDecodeCallDataFail(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	method := &abi.Entry{
+		Type:   abi.Function,
+		Name:   "set",
+		Inputs: abi.ParameterArray{{Name: "x", Type: "uint256"}},
+	}
+	_, err := c.decodeCallData(ctx, method, ethtypes.MustNewHexBytes0xPrefix("0x60fe47b1"))
+	assert.Regexp(t, "FF23119.*set\\(uint256\\)", err)
+
+}
+
 func TestPrepareTransactionWithEstimateRevert(t *testing.T) {
 
 	ctx, c, mRPC, done := newTestConnector(t)
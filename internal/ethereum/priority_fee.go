@@ -0,0 +1,134 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// The percentile of the priority fees paid in each block that is used as the effective tip of that block
+const priorityFeeBlockPercentile = 50
+
+// priorityFeeEstimator smooths the priority fee suggested by the node, which is an instantaneous sample that can
+// oscillate from one call to the next, using the median of the effective tips paid over a window of recent blocks
+type priorityFeeEstimator struct {
+	window     int
+	spikeClamp *big.Float
+}
+
+func (c *ethConnector) initPriorityFee(ctx context.Context, conf config.Section) error {
+	if !conf.GetBool(PriorityFeeEnabled) {
+		return nil
+	}
+	window := conf.GetInt(PriorityFeeWindow)
+	if window < 1 || window > maxFeeHistoryBlocks {
+		return i18n.NewError(ctx, msgs.MsgInvalidPriorityFeeConfig, PriorityFeeWindow, window)
+	}
+	spikeClamp := conf.GetFloat64(PriorityFeeSpikeClamp)
+	if spikeClamp < 1 {
+		return i18n.NewError(ctx, msgs.MsgInvalidPriorityFeeConfig, PriorityFeeSpikeClamp, spikeClamp)
+	}
+	c.priorityFee = &priorityFeeEstimator{
+		window:     window,
+		spikeClamp: big.NewFloat(spikeClamp),
+	}
+	return nil
+}
+
+// estimateFees returns the maxPriorityFeePerGas and maxFeePerGas to use for a transaction. The priority fee is the
+// average of the node's eth_maxPriorityFeePerGas (clamped to within spikeClamp of the median) and the median tip of
+// recent blocks. The maxFeePerGas allows for the base fee to double before the transaction is mined.
+func (pf *priorityFeeEstimator) estimateFees(ctx context.Context, c *ethConnector) (maxPriorityFee, maxFee *big.Int, err error) {
+	var fh feeHistoryJSONRPC
+	rpcErr := c.backend.CallRPC(ctx, &fh, "eth_feeHistory", ethtypes.NewHexInteger64(int64(pf.window)), "latest", []float64{priorityFeeBlockPercentile})
+	if rpcErr != nil {
+		return nil, nil, rpcErr.Error()
+	}
+	if len(fh.BaseFeePerGas) == 0 || fh.BaseFeePerGas[len(fh.BaseFeePerGas)-1] == nil {
+		return nil, nil, i18n.NewError(ctx, msgs.MsgPriorityFeeNoHistory)
+	}
+	nextBaseFee := fh.BaseFeePerGas[len(fh.BaseFeePerGas)-1].BigInt()
+
+	// Empty blocks report a tip of zero, so are excluded from the median
+	tips := make([]*big.Int, 0, len(fh.Reward))
+	for i, blockRewards := range fh.Reward {
+		if len(blockRewards) > 0 && blockRewards[0] != nil && (i >= len(fh.GasUsedRatio) || fh.GasUsedRatio[i] > 0) {
+			tips = append(tips, blockRewards[0].BigInt())
+		}
+	}
+	median := medianBigInt(tips)
+
+	var sample ethtypes.HexInteger
+	rpcErr = c.backend.CallRPC(ctx, &sample, "eth_maxPriorityFeePerGas")
+	switch {
+	case rpcErr != nil && median == nil:
+		return nil, nil, rpcErr.Error()
+	case rpcErr != nil:
+		log.L(ctx).Debugf("eth_maxPriorityFeePerGas not available, using median of recent blocks: %s", rpcErr.Message)
+		maxPriorityFee = median
+	case median == nil:
+		maxPriorityFee = sample.BigInt()
+	default:
+		clamped := pf.clamp(sample.BigInt(), median)
+		maxPriorityFee = new(big.Int).Add(clamped, median)
+		maxPriorityFee.Rsh(maxPriorityFee, 1)
+		log.L(ctx).Debugf("Priority fee sample=%s clamped=%s median=%s blended=%s", sample.BigInt(), clamped, median, maxPriorityFee)
+	}
+
+	maxFee = new(big.Int).Lsh(nextBaseFee, 1)
+	maxFee.Add(maxFee, maxPriorityFee)
+	return maxPriorityFee, maxFee, nil
+}
+
+// clamp limits a sample to within the range median/spikeClamp -> median*spikeClamp
+func (pf *priorityFeeEstimator) clamp(sample, median *big.Int) *big.Int {
+	fMedian := new(big.Float).SetInt(median)
+	upper, _ := new(big.Float).Mul(fMedian, pf.spikeClamp).Int(nil)
+	lower, _ := new(big.Float).Quo(fMedian, pf.spikeClamp).Int(nil)
+	switch {
+	case sample.Cmp(upper) > 0:
+		return upper
+	case sample.Cmp(lower) < 0:
+		return lower
+	default:
+		return sample
+	}
+}
+
+// medianBigInt returns the median of the values (the mean of the middle two for an even count), or nil if empty
+func medianBigInt(values []*big.Int) *big.Int {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := make([]*big.Int, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return new(big.Int).Set(sorted[mid])
+	}
+	median := new(big.Int).Add(sorted[mid-1], sorted[mid])
+	return median.Rsh(median, 1)
+}
@@ -0,0 +1,217 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockFeeHistory(mRPC *rpcbackendmocks.Backend, fh *feeHistoryJSONRPC) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_feeHistory", ethtypes.NewHexInteger64(4), "latest", []float64{50}).
+		Run(func(args mock.Arguments) {
+			*(args[1].(*feeHistoryJSONRPC)) = *fh
+		}).
+		Return(nil).Once()
+}
+
+func sampleFeeHistory() *feeHistoryJSONRPC {
+	return &feeHistoryJSONRPC{
+		BaseFeePerGas: []*ethtypes.HexInteger{
+			ethtypes.NewHexInteger64(90), ethtypes.NewHexInteger64(95), ethtypes.NewHexInteger64(90),
+			ethtypes.NewHexInteger64(95), ethtypes.NewHexInteger64(100),
+		},
+		GasUsedRatio: []float64{0.5, 0, 0.5, 0.5},
+		Reward: [][]*ethtypes.HexInteger{
+			{ethtypes.NewHexInteger64(10)}, {ethtypes.NewHexInteger64(0)}, {ethtypes.NewHexInteger64(30)}, {ethtypes.NewHexInteger64(20)},
+		},
+	}
+}
+
+func mockMaxPriorityFee(mRPC *rpcbackendmocks.Backend, fee int64) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_maxPriorityFeePerGas").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(fee)
+		}).
+		Return(nil).Once()
+}
+
+func TestGasPriceEstimatePriorityFeeBlended(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(PriorityFeeEnabled, true)
+		conf.Set(PriorityFeeWindow, 4)
+	})
+	defer done()
+	mockLatestBlock(mRPC, ethtypes.NewHexInteger64(90))
+
+	// The median of the non-empty blocks is 20, so a spike to 100 is clamped to 40 before blending
+	mockFeeHistory(mRPC, sampleFeeHistory())
+	mockMaxPriorityFee(mRPC, 100)
+	res, _, err := c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxPriorityFeePerGas":"30","maxFeePerGas":"230"}`, res.GasPrice.String())
+
+	// Within the clamp the sample is blended as is
+	mockFeeHistory(mRPC, sampleFeeHistory())
+	mockMaxPriorityFee(mRPC, 24)
+	res, _, err = c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxPriorityFeePerGas":"22","maxFeePerGas":"222"}`, res.GasPrice.String())
+
+	// A dip is clamped too
+	mockFeeHistory(mRPC, sampleFeeHistory())
+	mockMaxPriorityFee(mRPC, 1)
+	res, _, err = c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxPriorityFeePerGas":"15","maxFeePerGas":"215"}`, res.GasPrice.String())
+
+}
+
+func TestGasPriceEstimatePriorityFeeNoNodeSample(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(PriorityFeeEnabled, true)
+		conf.Set(PriorityFeeWindow, 4)
+	})
+	defer done()
+	mockLatestBlock(mRPC, ethtypes.NewHexInteger64(90))
+
+	mockFeeHistory(mRPC, sampleFeeHistory())
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_maxPriorityFeePerGas").
+		Return(&rpcbackend.RPCError{Message: "method not found"}).Once()
+	res, _, err := c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxPriorityFeePerGas":"20","maxFeePerGas":"220"}`, res.GasPrice.String())
+
+}
+
+func TestGasPriceEstimatePriorityFeeEmptyBlocks(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(PriorityFeeEnabled, true)
+		conf.Set(PriorityFeeWindow, 4)
+	})
+	defer done()
+	mockLatestBlock(mRPC, ethtypes.NewHexInteger64(90))
+
+	fh := &feeHistoryJSONRPC{
+		BaseFeePerGas: []*ethtypes.HexInteger{ethtypes.NewHexInteger64(100), ethtypes.NewHexInteger64(90)},
+		GasUsedRatio:  []float64{0},
+		Reward:        [][]*ethtypes.HexInteger{{ethtypes.NewHexInteger64(0)}},
+	}
+	mockFeeHistory(mRPC, fh)
+	mockMaxPriorityFee(mRPC, 7)
+	res, _, err := c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxPriorityFeePerGas":"7","maxFeePerGas":"187"}`, res.GasPrice.String())
+
+	// With no sample either, we fall back to eth_gasPrice
+	mockFeeHistory(mRPC, fh)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_maxPriorityFeePerGas").
+		Return(&rpcbackend.RPCError{Message: "method not found"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_gasPrice").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(12345)
+		}).
+		Return(nil)
+	res, _, err = c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, `"12345"`, res.GasPrice.String())
+
+}
+
+func TestGasPriceEstimatePriorityFeeHistoryFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(PriorityFeeEnabled, true)
+		conf.Set(PriorityFeeWindow, 4)
+	})
+	defer done()
+	mockLatestBlock(mRPC, ethtypes.NewHexInteger64(90))
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_feeHistory", mock.Anything, "latest", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mockFeeHistory(mRPC, &feeHistoryJSONRPC{})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_gasPrice").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(12345)
+		}).
+		Return(nil).Twice()
+
+	res, _, err := c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, `"12345"`, res.GasPrice.String())
+
+	// No base fee in the history
+	res, _, err = c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, `"12345"`, res.GasPrice.String())
+
+}
+
+func TestGasPriceEstimatePriorityFeeLegacyChain(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(PriorityFeeEnabled, true)
+	})
+	defer done()
+
+	mockLatestBlock(mRPC, nil).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_gasPrice").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(12345)
+		}).
+		Return(nil)
+
+	res, _, err := c.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, `"12345"`, res.GasPrice.String())
+
+}
+
+func TestPriorityFeeBadConfig(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(PriorityFeeEnabled, true)
+	conf.Set(PriorityFeeWindow, 0)
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23138.*priorityFee.window", err)
+
+	conf.Set(PriorityFeeWindow, 10)
+	conf.Set(PriorityFeeSpikeClamp, 0.5)
+	_, err = NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23138.*priorityFee.spikeClamp", err)
+
+}
+
+func TestMedianBigInt(t *testing.T) {
+
+	assert.Nil(t, medianBigInt(nil))
+	assert.Equal(t, int64(2), medianBigInt([]*big.Int{big.NewInt(3), big.NewInt(1), big.NewInt(2)}).Int64())
+	assert.Equal(t, int64(25), medianBigInt([]*big.Int{big.NewInt(30), big.NewInt(20)}).Int64())
+
+}
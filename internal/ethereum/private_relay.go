@@ -0,0 +1,237 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const (
+	RelayMethodPrivateTransaction = "eth_sendPrivateTransaction"
+	RelayMethodRawTransaction     = "eth_sendRawTransaction"
+)
+
+type PrivateTransactionState string
+
+const (
+	PrivateTransactionPending  PrivateTransactionState = "pending"
+	PrivateTransactionIncluded PrivateTransactionState = "included"
+	PrivateTransactionExpired  PrivateTransactionState = "expired" // dropped by the relay, and sent again on the next submission
+)
+
+type PrivateTransactionStatusRequest struct {
+	TransactionHash string `json:"transactionHash"`
+}
+
+type PrivateTransactionStatusResponse struct {
+	TransactionHash string                  `json:"transactionHash"`
+	State           PrivateTransactionState `json:"state"`
+	Submitted       *fftypes.FFTime         `json:"submitted"`
+	MaxBlockNumber  *fftypes.FFBigInt       `json:"maxBlockNumber"`
+	BlockNumber     *fftypes.FFBigInt       `json:"blockNumber,omitempty"` // once included
+}
+
+// privateRelay sends signed transactions to a private orderflow relay, such as Flashbots Protect or MEV Blocker,
+// rather than to the public mempool of the node, so that they cannot be front-run. The relay only attempts to
+// include a transaction for a limited number of blocks, so we track each one until it is included or expires.
+type privateRelay struct {
+	url         string
+	method      string
+	maxBlocks   int64
+	addresses   map[ethtypes.Address0xHex]bool // empty to relay all signed transactions
+	backend     rpcbackend.Backend
+	submissions *lru.Cache
+}
+
+type privateSubmission struct {
+	submitted      time.Time
+	maxBlockNumber int64
+}
+
+type privateTransactionParams struct {
+	Tx             string               `json:"tx"`
+	MaxBlockNumber *ethtypes.HexInteger `json:"maxBlockNumber,omitempty"`
+}
+
+func (c *ethConnector) initPrivateRelay(ctx context.Context, conf config.Section, httpConf *ffresty.Config) (err error) {
+	url := conf.GetString(SubmissionRelayURL)
+	if url == "" {
+		return nil
+	}
+	pr := &privateRelay{
+		url:       url,
+		method:    conf.GetString(SubmissionRelayMethod),
+		maxBlocks: conf.GetInt64(SubmissionRelayMaxBlocks),
+		addresses: make(map[ethtypes.Address0xHex]bool),
+	}
+	switch pr.method {
+	case RelayMethodPrivateTransaction, RelayMethodRawTransaction:
+	default:
+		return i18n.NewError(ctx, msgs.MsgInvalidRelayMethod, pr.method, strings.Join([]string{RelayMethodPrivateTransaction, RelayMethodRawTransaction}, ","))
+	}
+	for _, a := range conf.GetStringSlice(SubmissionRelayAddresses) {
+		address, err := ethtypes.NewAddress(a)
+		if err != nil {
+			return i18n.NewError(ctx, msgs.MsgBadRelayAddress, a)
+		}
+		pr.addresses[*address] = true
+	}
+	if pr.submissions, err = lru.New(conf.GetInt(SubmissionRelayCacheSize)); err != nil {
+		return i18n.WrapError(ctx, err, msgs.MsgCacheInitFail, "private relay")
+	}
+
+	relayHTTPConf := *httpConf
+	relayHTTPConf.URL = url
+	relayHTTPConf.AuthUsername = conf.GetString(SubmissionRelayAuthUsername)
+	relayHTTPConf.AuthPassword = conf.GetString(SubmissionRelayAuthPassword)
+	relayHTTPConf.HTTPHeaders = nil
+	if relayHTTPConf.TLSClientConfig, err = endpointTLSConfig(ctx, conf.SubSection(SubmissionRelayTLS), httpConf.TLSClientConfig); err != nil {
+		return err
+	}
+	httpClient := ffresty.NewWithConfig(ctx, relayHTTPConf)
+	if err := configureRPCProxy(ctx, conf, httpClient); err != nil {
+		return err
+	}
+	pr.backend = rpcbackend.NewRPCClient(httpClient)
+	log.L(ctx).Infof("Private relay at %s using %s for %d addresses (all signed transactions if none)", url, pr.method, len(pr.addresses))
+	c.privateRelay = pr
+	return nil
+}
+
+// relays returns true if transactions from the address are sent via the relay. Transactions are only
+// sent via the relay if they are signed before submission, unless addresses are configured.
+func (pr *privateRelay) relays(from *ethtypes.Address0xHex, signed bool) bool {
+	if pr == nil {
+		return false
+	}
+	if len(pr.addresses) == 0 {
+		return signed
+	}
+	return from != nil && pr.addresses[*from]
+}
+
+// sendPrivateTransaction sends a signed transaction to the relay, which attempts to include it up to maxBlocks
+// after the current head of the chain
+func (c *ethConnector) sendPrivateTransaction(ctx context.Context, rawTX string) (ethtypes.HexBytes0xPrefix, *rpcbackend.RPCError) {
+	pr := c.privateRelay
+	headBlock, ok := c.blockListener.getHighestBlock(ctx)
+	if !ok {
+		return nil, &rpcbackend.RPCError{Message: i18n.NewError(ctx, msgs.MsgTimedOutQueryingChainHead).Error()}
+	}
+	maxBlockNumber := headBlock + pr.maxBlocks
+
+	var txHash ethtypes.HexBytes0xPrefix
+	var rpcErr *rpcbackend.RPCError
+	if pr.method == RelayMethodPrivateTransaction {
+		rpcErr = pr.backend.CallRPC(ctx, &txHash, pr.method, &privateTransactionParams{
+			Tx:             rawTX,
+			MaxBlockNumber: ethtypes.NewHexInteger64(maxBlockNumber),
+		})
+	} else {
+		rpcErr = pr.backend.CallRPC(ctx, &txHash, pr.method, rawTX)
+	}
+	if rpcErr == nil {
+		log.L(ctx).Infof("Transaction %s sent via private relay %s for inclusion by block %d", txHash, pr.url, maxBlockNumber)
+		pr.submissions.Add(txHash.String(), &privateSubmission{
+			submitted:      time.Now(),
+			maxBlockNumber: maxBlockNumber,
+		})
+	}
+	return txHash, rpcErr
+}
+
+// privateTransactionState checks whether a relayed transaction has been included in a block, or has passed
+// the last block that the relay would include it in
+func (c *ethConnector) privateTransactionState(ctx context.Context, txHash string, ps *privateSubmission) (PrivateTransactionState, *ethtypes.HexInteger, error) {
+	var receipt *txReceiptJSONRPC
+	if rpcErr := c.backend.CallRPC(ctx, &receipt, "eth_getTransactionReceipt", txHash); rpcErr != nil {
+		return "", nil, rpcErr.Error()
+	}
+	if receipt != nil && receipt.BlockNumber != nil {
+		return PrivateTransactionIncluded, receipt.BlockNumber, nil
+	}
+	headBlock, ok := c.blockListener.getHighestBlock(ctx)
+	if !ok {
+		return "", nil, i18n.NewError(ctx, msgs.MsgTimedOutQueryingChainHead)
+	}
+	if headBlock > ps.maxBlockNumber {
+		return PrivateTransactionExpired, nil, nil
+	}
+	return PrivateTransactionPending, nil, nil
+}
+
+// privateTransactionExpired returns true if the transaction was sent via the relay, and dropped without being
+// included, so that a re-submission of the same transaction is sent again rather than returning the hash
+func (c *ethConnector) privateTransactionExpired(ctx context.Context, txHash string) bool {
+	if c.privateRelay == nil {
+		return false
+	}
+	cached, ok := c.privateRelay.submissions.Get(txHash)
+	if !ok {
+		return false
+	}
+	state, _, err := c.privateTransactionState(ctx, txHash, cached.(*privateSubmission))
+	if err != nil {
+		log.L(ctx).Warnf("Unable to check whether private transaction %s has expired: %s", txHash, err)
+		return false
+	}
+	if state == PrivateTransactionExpired {
+		log.L(ctx).Infof("Private transaction %s expired without being included. Sending again", txHash)
+		return true
+	}
+	return false
+}
+
+// PrivateTransactionStatus returns whether a transaction sent via the private relay is still pending, has been
+// included in a block, or has expired - in which case the next submission of the transaction sends it again
+func (c *ethConnector) PrivateTransactionStatus(ctx context.Context, req *PrivateTransactionStatusRequest) (*PrivateTransactionStatusResponse, ffcapi.ErrorReason, error) {
+	txHash := strings.ToLower(req.TransactionHash)
+	var cached interface{}
+	ok := false
+	if c.privateRelay != nil {
+		cached, ok = c.privateRelay.submissions.Get(txHash)
+	}
+	if !ok {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgRelayNotTracked, req.TransactionHash)
+	}
+	ps := cached.(*privateSubmission)
+	state, blockNumber, err := c.privateTransactionState(ctx, txHash, ps)
+	if err != nil {
+		return nil, "", err
+	}
+	submitted := fftypes.FFTime(ps.submitted)
+	return &PrivateTransactionStatusResponse{
+		TransactionHash: txHash,
+		State:           state,
+		Submitted:       &submitted,
+		MaxBlockNumber:  fftypes.NewFFBigInt(ps.maxBlockNumber),
+		BlockNumber:     (*fftypes.FFBigInt)(blockNumber),
+	}, "", nil
+}
@@ -0,0 +1,256 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-signer/pkg/secp256k1"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestPrivateRelay(t *testing.T, requests chan<- *rpcbackend.RPCRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rpcReq rpcbackend.RPCRequest
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &rpcReq))
+		requests <- &rpcReq
+		rawTX := rpcReq.Params[0].AsString()
+		if rpcReq.Method == RelayMethodPrivateTransaction {
+			var p privateTransactionParams
+			assert.NoError(t, json.Unmarshal(rpcReq.Params[0].Bytes(), &p))
+			rawTX = p.Tx
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"%s"}`, rawTransactionHash(ethtypes.MustNewHexBytes0xPrefix(rawTX)))
+	}))
+}
+
+func mockRelayChainHead(mRPC interface {
+	On(string, ...interface{}) *mock.Call
+}, head int64) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(head)
+	}).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_newBlockFilter").Return(nil).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", mock.Anything).Return(nil).Maybe()
+}
+
+func TestSendTransactionPrivateRelay(t *testing.T) {
+
+	requests := make(chan *rpcbackend.RPCRequest, 1)
+	server := newTestPrivateRelay(t, requests)
+	defer server.Close()
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionRelayURL, server.URL)
+		conf.Set(SubmissionRelayMaxBlocks, 10)
+		conf.Set(SubmissionIdempotencyWindow, "1m")
+	})
+	defer done()
+	mockRelayChainHead(mRPC, 1000)
+
+	kp, _ := secp256k1.GenerateSecp256k1KeyPair()
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+	txHash := rawTransactionHash(raw).String()
+
+	res, reason, err := c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: kp.Address.String()},
+		TransactionData:    ethtypes.HexBytes0xPrefix(raw).String(),
+		PreSigned:          true,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, txHash, res.TransactionHash)
+	rpcReq := <-requests
+	assert.Equal(t, RelayMethodPrivateTransaction, rpcReq.Method)
+	assert.JSONEq(t, fmt.Sprintf(`{"tx":"%s","maxBlockNumber":"0x3f2"}`, ethtypes.HexBytes0xPrefix(raw)), rpcReq.Params[0].String())
+
+	// Not yet included
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", txHash).Return(nil).Once()
+	status, _, err := c.PrivateTransactionStatus(ctx, &PrivateTransactionStatusRequest{TransactionHash: txHash})
+	assert.NoError(t, err)
+	assert.Equal(t, PrivateTransactionPending, status.State)
+	assert.Equal(t, int64(1010), status.MaxBlockNumber.Int64())
+
+	// Expired, so the same submission is sent again rather than returning the previous hash
+	c.blockListener.mux.Lock()
+	c.blockListener.highestBlock = 1011
+	c.blockListener.mux.Unlock()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", txHash).Return(nil).Once()
+	res, _, err = c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: kp.Address.String()},
+		TransactionData:    ethtypes.HexBytes0xPrefix(raw).String(),
+		PreSigned:          true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, txHash, res.TransactionHash)
+	rpcReq = <-requests
+	assert.JSONEq(t, fmt.Sprintf(`{"tx":"%s","maxBlockNumber":"0x3fd"}`, ethtypes.HexBytes0xPrefix(raw)), rpcReq.Params[0].String())
+
+	// Included
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", txHash).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(**txReceiptJSONRPC)) = &txReceiptJSONRPC{BlockNumber: ethtypes.NewHexInteger64(1015)}
+	})
+	status, _, err = c.PrivateTransactionStatus(ctx, &PrivateTransactionStatusRequest{TransactionHash: txHash})
+	assert.NoError(t, err)
+	assert.Equal(t, PrivateTransactionIncluded, status.State)
+	assert.Equal(t, int64(1015), status.BlockNumber.Int64())
+
+	// A re-submission within the idempotency window returns the hash
+	res, _, err = c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: kp.Address.String()},
+		TransactionData:    ethtypes.HexBytes0xPrefix(raw).String(),
+		PreSigned:          true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, txHash, res.TransactionHash)
+	assert.Empty(t, requests)
+
+}
+
+func TestSendTransactionPrivateRelayAddresses(t *testing.T) {
+
+	requests := make(chan *rpcbackend.RPCRequest, 1)
+	server := newTestPrivateRelay(t, requests)
+	defer server.Close()
+
+	kp, _ := secp256k1.GenerateSecp256k1KeyPair()
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionRelayURL, server.URL)
+		conf.Set(SubmissionRelayMethod, RelayMethodRawTransaction)
+		conf.Set(SubmissionRelayAddresses, []string{kp.Address.String()})
+	})
+	defer done()
+	mockRelayChainHead(mRPC, 1000)
+
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+	_, _, err = c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+		PreSigned:       true,
+	})
+	assert.NoError(t, err)
+	rpcReq := <-requests
+	assert.Equal(t, RelayMethodRawTransaction, rpcReq.Method)
+	assert.Equal(t, ethtypes.HexBytes0xPrefix(raw).String(), rpcReq.Params[0].AsString())
+
+	// Signed transactions from other addresses go to the node
+	kp2, _ := secp256k1.GenerateSecp256k1KeyPair()
+	raw2, err := newRawTestTX().SignEIP1559(kp2, 1337)
+	assert.NoError(t, err)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", ethtypes.HexBytes0xPrefix(raw2).String()).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = rawTransactionHash(raw2)
+	})
+	_, _, err = c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionData: ethtypes.HexBytes0xPrefix(raw2).String(),
+		PreSigned:       true,
+	})
+	assert.NoError(t, err)
+
+	// Unsigned transactions from a relayed address are refused, as the node would broadcast them
+	_, reason, err := c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: kp.Address.String(),
+			To:   "0x497eedc4299dea2f2a364be10025d0ad0f702de3",
+		},
+		TransactionData: "0xfeedbeef",
+	})
+	assert.Regexp(t, "FF23165", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestPrivateRelayErrors(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.PrivateTransactionStatus(ctx, &PrivateTransactionStatusRequest{TransactionHash: sampleRawTXHash})
+	assert.Regexp(t, "FF23166", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+	assert.False(t, c.privateTransactionExpired(ctx, sampleRawTXHash))
+
+	c.privateRelay = &privateRelay{maxBlocks: 10}
+	c.privateRelay.submissions, _ = lru.New(10)
+	c.privateRelay.submissions.Add(sampleRawTXHash, &privateSubmission{maxBlockNumber: 1010})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", sampleRawTXHash).Return(&rpcbackend.RPCError{Message: "pop"})
+	_, _, err = c.PrivateTransactionStatus(ctx, &PrivateTransactionStatusRequest{TransactionHash: sampleRawTXHash})
+	assert.Regexp(t, "pop", err)
+	assert.False(t, c.privateTransactionExpired(ctx, sampleRawTXHash))
+	assert.False(t, c.privateTransactionExpired(ctx, "0x1234"))
+
+}
+
+func TestPrivateRelayChainHeadUnavailable(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(&rpcbackend.RPCError{Message: "pop"}).Maybe()
+	c.privateRelay = &privateRelay{maxBlocks: 10}
+	c.privateRelay.submissions, _ = lru.New(10)
+	c.privateRelay.submissions.Add(sampleRawTXHash, &privateSubmission{maxBlockNumber: 1010})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", sampleRawTXHash).Return(nil)
+
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, rpcErr := c.sendPrivateTransaction(cancelledCtx, "0x")
+	assert.Regexp(t, "FF23046", rpcErr.Message)
+	_, _, err := c.PrivateTransactionStatus(cancelledCtx, &PrivateTransactionStatusRequest{TransactionHash: sampleRawTXHash})
+	assert.Regexp(t, "FF23046", err)
+	done()
+
+}
+
+func TestPrivateRelayConfigErrors(t *testing.T) {
+
+	ctx := context.Background()
+	httpConf := &ffresty.Config{}
+	for _, tc := range []struct {
+		setup func(conf config.Section)
+		err   string
+	}{
+		{func(conf config.Section) { conf.Set(SubmissionRelayMethod, "eth_sendBundle") }, "FF23163"},
+		{func(conf config.Section) { conf.Set(SubmissionRelayAddresses, []string{"wrong"}) }, "FF23164"},
+		{func(conf config.Section) { conf.Set(SubmissionRelayCacheSize, 0) }, "FF23040"},
+		{func(conf config.Section) { conf.Set(ffresty.HTTPConfigProxyURL, "ftp://proxy") }, "FF23"},
+	} {
+		config.RootConfigReset()
+		conf := config.RootSection("unittest")
+		InitConfig(conf)
+		conf.Set(SubmissionRelayURL, "http://localhost:0")
+		tc.setup(conf)
+		c := &ethConnector{}
+		err := c.initPrivateRelay(ctx, conf, httpConf)
+		assert.Regexp(t, tc.err, err)
+		assert.Nil(t, c.privateRelay)
+	}
+
+}
@@ -0,0 +1,193 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+)
+
+type QuorumMismatchMode string
+
+const (
+	QuorumPreferPrimary QuorumMismatchMode = "prefer_primary" // log and count the mismatch, and use the result of the primary
+	QuorumFail          QuorumMismatchMode = "fail"           // fail the read, so that it is retried by the caller
+	QuorumRetry         QuorumMismatchMode = "retry"          // query both again, failing if they still disagree after the retries
+)
+
+type ReadQuorumStatus struct {
+	Mode        QuorumMismatchMode `json:"mode"`
+	Checks      int64              `json:"checks"`
+	Mismatches  int64              `json:"mismatches"`
+	Unavailable int64              `json:"unavailable"` // reads the secondary could not answer, so were not checked
+}
+
+// readQuorum cross-checks the reads that confirmations depend on - receipts, and the hashes of blocks - against a
+// secondary JSON/RPC endpoint, so that a single misbehaving provider cannot report a transaction or block that the
+// rest of the network disagrees with. A secondary that is behind, or unavailable, does not fail the read.
+type readQuorum struct {
+	secondary   rpcbackend.RPC
+	mode        QuorumMismatchMode
+	retries     int
+	retryDelay  time.Duration
+	checks      atomic.Int64
+	mismatches  atomic.Int64
+	unavailable atomic.Int64
+}
+
+// quorumFields are the fields of receipts and blocks that must match between the endpoints. Other fields
+// are not compared, as they legitimately differ between node implementations.
+type quorumFields struct {
+	Hash            string `json:"hash"`
+	Number          string `json:"number"`
+	ParentHash      string `json:"parentHash"`
+	BlockHash       string `json:"blockHash"`
+	BlockNumber     string `json:"blockNumber"`
+	TransactionHash string `json:"transactionHash"`
+	Status          string `json:"status"`
+	ContractAddress string `json:"contractAddress"`
+}
+
+func (c *ethConnector) initReadQuorum(ctx context.Context, conf config.Section, httpConf *ffresty.Config) error {
+	url := conf.GetString(ReadQuorumURL)
+	if url == "" {
+		return nil
+	}
+	rq := &readQuorum{
+		mode:       QuorumMismatchMode(conf.GetString(ReadQuorumMode)),
+		retries:    conf.GetInt(ReadQuorumRetries),
+		retryDelay: conf.GetDuration(ReadQuorumRetryDelay),
+	}
+	switch rq.mode {
+	case QuorumPreferPrimary, QuorumFail, QuorumRetry:
+	default:
+		return i18n.NewError(ctx, msgs.MsgInvalidReadQuorumMode, rq.mode, strings.Join([]string{string(QuorumPreferPrimary), string(QuorumFail), string(QuorumRetry)}, ","))
+	}
+
+	// The secondary is usually a different provider, so does not share the credentials or headers of the primary,
+	// and can have its own TLS configuration
+	secondaryHTTPConf := *httpConf
+	secondaryHTTPConf.URL = url
+	secondaryHTTPConf.AuthUsername = conf.GetString(ReadQuorumAuthUsername)
+	secondaryHTTPConf.AuthPassword = conf.GetString(ReadQuorumAuthPassword)
+	secondaryHTTPConf.HTTPHeaders = nil
+	tlsConfig, err := endpointTLSConfig(ctx, conf.SubSection(ReadQuorumTLS), httpConf.TLSClientConfig)
+	if err != nil {
+		return err
+	}
+	secondaryHTTPConf.TLSClientConfig = tlsConfig
+	httpClient := ffresty.NewWithConfig(ctx, secondaryHTTPConf)
+	if err := configureRPCProxy(ctx, conf, httpClient); err != nil {
+		return err
+	}
+	rq.secondary = rpcbackend.NewRPCClient(httpClient)
+	log.L(ctx).Infof("Read quorum with secondary endpoint %s (mode=%s)", url, rq.mode)
+	c.readQuorum = rq
+	return nil
+}
+
+// callRPC makes a critical read against the primary backend, and checks the result against the secondary.
+// If read quorum is disabled, this is just a call to the primary.
+func (rq *readQuorum) callRPC(ctx context.Context, primary rpcbackend.RPC, result interface{}, method string, params ...interface{}) *rpcbackend.RPCError {
+	if rq == nil {
+		return primary.CallRPC(ctx, result, method, params...)
+	}
+	for attempt := 0; ; attempt++ {
+		var primaryResult json.RawMessage
+		if rpcErr := primary.CallRPC(ctx, &primaryResult, method, params...); rpcErr != nil {
+			return rpcErr
+		}
+		mismatch := rq.check(ctx, primaryResult, method, params)
+		if mismatch != "" {
+			switch {
+			case rq.mode == QuorumRetry && attempt < rq.retries:
+				log.L(ctx).Infof("Retrying %s after read quorum mismatch (attempt=%d)", method, attempt+1)
+				select {
+				case <-time.After(rq.retryDelay):
+					continue
+				case <-ctx.Done():
+				}
+				fallthrough
+			case rq.mode != QuorumPreferPrimary:
+				return rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInternalError, msgs.MsgReadQuorumMismatch, method, mismatch)
+			}
+		}
+		if err := json.Unmarshal(primaryResult, result); err != nil {
+			return &rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeInternalError), Message: err.Error()}
+		}
+		return nil
+	}
+}
+
+// check returns a description of the mismatch between the primary result and that of the secondary, or an empty
+// string if they match - or could not be compared, because the secondary failed or does not yet have the result
+func (rq *readQuorum) check(ctx context.Context, primaryResult json.RawMessage, method string, params []interface{}) string {
+	var primaryFields *quorumFields
+	if err := json.Unmarshal(primaryResult, &primaryFields); err != nil || primaryFields == nil {
+		// Nothing to check if the primary does not have the result - the caller will try again later
+		return ""
+	}
+	var secondaryFields *quorumFields
+	if rpcErr := rq.secondary.CallRPC(ctx, &secondaryFields, method, params...); rpcErr != nil || secondaryFields == nil {
+		rq.unavailable.Add(1)
+		log.L(ctx).Debugf("Read quorum secondary unable to answer %s: %v", method, rpcErr)
+		return ""
+	}
+	rq.checks.Add(1)
+
+	var diffs []string
+	compare := func(name, p, s string) {
+		if !strings.EqualFold(p, s) {
+			diffs = append(diffs, fmt.Sprintf("%s primary=%s secondary=%s", name, p, s))
+		}
+	}
+	compare("hash", primaryFields.Hash, secondaryFields.Hash)
+	compare("number", primaryFields.Number, secondaryFields.Number)
+	compare("parentHash", primaryFields.ParentHash, secondaryFields.ParentHash)
+	compare("blockHash", primaryFields.BlockHash, secondaryFields.BlockHash)
+	compare("blockNumber", primaryFields.BlockNumber, secondaryFields.BlockNumber)
+	compare("transactionHash", primaryFields.TransactionHash, secondaryFields.TransactionHash)
+	compare("status", primaryFields.Status, secondaryFields.Status)
+	compare("contractAddress", primaryFields.ContractAddress, secondaryFields.ContractAddress)
+	if len(diffs) == 0 {
+		return ""
+	}
+	rq.mismatches.Add(1)
+	mismatch := strings.Join(diffs, ", ")
+	log.L(ctx).Warnf("Read quorum mismatch for %s %v: %s", method, params, mismatch)
+	return mismatch
+}
+
+func (rq *readQuorum) getStatus() *ReadQuorumStatus {
+	return &ReadQuorumStatus{
+		Mode:        rq.mode,
+		Checks:      rq.checks.Load(),
+		Mismatches:  rq.mismatches.Load(),
+		Unavailable: rq.unavailable.Load(),
+	}
+}
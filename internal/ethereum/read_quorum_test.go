@@ -0,0 +1,223 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleQuorumReceipt = `{
+	"blockHash": "0x6197ef1a58a2a592bb447efb651f0db7945de21aa8048801b250bd7b7431f9b6",
+	"blockNumber": "0x7b9",
+	"transactionHash": "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+	"status": "0x1",
+	"gasUsed": "0x5208"
+}`
+
+func newTestReadQuorum(t *testing.T, mode QuorumMismatchMode) (*readQuorum, *rpcbackendmocks.Backend, *rpcbackendmocks.Backend) {
+	mPrimary := &rpcbackendmocks.Backend{}
+	mSecondary := &rpcbackendmocks.Backend{}
+	t.Cleanup(func() {
+		mPrimary.AssertExpectations(t)
+		mSecondary.AssertExpectations(t)
+	})
+	return &readQuorum{
+		secondary:  mSecondary,
+		mode:       mode,
+		retries:    1,
+		retryDelay: 1 * time.Millisecond,
+	}, mPrimary, mSecondary
+}
+
+func mockQuorumResult(m *rpcbackendmocks.Backend, result string) *mock.Call {
+	return m.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", "0x7d48").Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(result), args[1])
+		if err != nil {
+			panic(err)
+		}
+	})
+}
+
+func TestReadQuorumMatch(t *testing.T) {
+
+	rq, mPrimary, mSecondary := newTestReadQuorum(t, QuorumFail)
+	mockQuorumResult(mPrimary, sampleQuorumReceipt)
+	// Fields that are not compared can differ, and hex is compared case insensitively
+	mockQuorumResult(mSecondary, `{
+		"blockHash": "0x6197EF1A58A2A592BB447EFB651F0DB7945DE21AA8048801B250BD7B7431F9B6",
+		"blockNumber": "0x7b9",
+		"transactionHash": "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+		"status": "0x1",
+		"gasUsed": "0x0"
+	}`)
+
+	var receipt *txReceiptJSONRPC
+	rpcErr := rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(1977), receipt.BlockNumber.BigInt().Int64())
+	assert.Equal(t, &ReadQuorumStatus{Mode: QuorumFail, Checks: 1}, rq.getStatus())
+
+}
+
+func TestReadQuorumMismatchPreferPrimary(t *testing.T) {
+
+	rq, mPrimary, mSecondary := newTestReadQuorum(t, QuorumPreferPrimary)
+	mockQuorumResult(mPrimary, sampleQuorumReceipt)
+	mockQuorumResult(mSecondary, `{"blockHash": "0x1111", "blockNumber": "0x7b9", "transactionHash": "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2", "status": "0x1"}`)
+
+	var receipt *txReceiptJSONRPC
+	rpcErr := rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, "0x6197ef1a58a2a592bb447efb651f0db7945de21aa8048801b250bd7b7431f9b6", receipt.BlockHash.String())
+	assert.Equal(t, int64(1), rq.getStatus().Mismatches)
+
+}
+
+func TestReadQuorumMismatchFail(t *testing.T) {
+
+	rq, mPrimary, mSecondary := newTestReadQuorum(t, QuorumFail)
+	mockQuorumResult(mPrimary, sampleQuorumReceipt)
+	mockQuorumResult(mSecondary, `{"blockHash": "0x6197ef1a58a2a592bb447efb651f0db7945de21aa8048801b250bd7b7431f9b6", "blockNumber": "0x7b9", "transactionHash": "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2", "status": "0x0"}`)
+
+	var receipt *txReceiptJSONRPC
+	rpcErr := rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Regexp(t, "FF23168.*eth_getTransactionReceipt.*status primary=0x1 secondary=0x0", rpcErr.Message)
+	assert.Nil(t, receipt)
+
+}
+
+func TestReadQuorumMismatchRetry(t *testing.T) {
+
+	rq, mPrimary, mSecondary := newTestReadQuorum(t, QuorumRetry)
+	mockQuorumResult(mPrimary, sampleQuorumReceipt).Twice()
+	mockQuorumResult(mSecondary, `{"blockHash": "0x1111"}`).Once()
+	mockQuorumResult(mSecondary, sampleQuorumReceipt).Once()
+
+	var receipt *txReceiptJSONRPC
+	rpcErr := rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Nil(t, rpcErr)
+	assert.NotNil(t, receipt)
+	assert.Equal(t, &ReadQuorumStatus{Mode: QuorumRetry, Checks: 2, Mismatches: 1}, rq.getStatus())
+
+	// Still mismatched after the retries
+	mockQuorumResult(mPrimary, sampleQuorumReceipt).Twice()
+	mockQuorumResult(mSecondary, `{"blockHash": "0x1111"}`).Twice()
+	rpcErr = rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Regexp(t, "FF23168", rpcErr.Message)
+
+	// Cancelled while waiting to retry
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rq.retryDelay = 1 * time.Hour
+	mockQuorumResult(mPrimary, sampleQuorumReceipt).Once()
+	mockQuorumResult(mSecondary, `{"blockHash": "0x1111"}`).Once()
+	rpcErr = rq.callRPC(ctx, mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Regexp(t, "FF23168", rpcErr.Message)
+
+}
+
+func TestReadQuorumNotCompared(t *testing.T) {
+
+	rq, mPrimary, mSecondary := newTestReadQuorum(t, QuorumFail)
+
+	// The primary does not have the receipt
+	mockQuorumResult(mPrimary, `null`).Once()
+	var receipt *txReceiptJSONRPC
+	rpcErr := rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Nil(t, rpcErr)
+	assert.Nil(t, receipt)
+
+	// The secondary does not have the receipt, or fails
+	mockQuorumResult(mPrimary, sampleQuorumReceipt).Twice()
+	mockQuorumResult(mSecondary, `null`).Once()
+	mSecondary.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", "0x7d48").Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	rpcErr = rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Nil(t, rpcErr)
+	assert.NotNil(t, receipt)
+	rpcErr = rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, &ReadQuorumStatus{Mode: QuorumFail, Unavailable: 2}, rq.getStatus())
+
+	// Errors from the primary are returned, as is a result that does not match the type
+	mPrimary.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", "0x7d48").Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	rpcErr = rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Regexp(t, "pop", rpcErr.Message)
+	mockQuorumResult(mPrimary, `"not a receipt"`).Once()
+	rpcErr = rq.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.NotNil(t, rpcErr)
+
+	// Disabled
+	var disabled *readQuorum
+	mockQuorumResult(mPrimary, sampleQuorumReceipt).Once()
+	rpcErr = disabled.callRPC(context.Background(), mPrimary, &receipt, "eth_getTransactionReceipt", "0x7d48")
+	assert.Nil(t, rpcErr)
+
+}
+
+func TestReadQuorumConnector(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ReadQuorumURL, "http://localhost:0")
+		conf.Set(ReadQuorumMode, "fail")
+	})
+	defer done()
+	mSecondary := &rpcbackendmocks.Backend{}
+	c.readQuorum.secondary = mSecondary
+
+	mockQuorumResult(mRPC, sampleQuorumReceipt)
+	mockQuorumResult(mSecondary, `{"blockHash": "0x1111"}`)
+	_, err := c.getTransactionReceipt(ctx, "0x7d48")
+	assert.Regexp(t, "FF23168", err)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil)
+	res, _, err := c.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.Contains(t, res.DownstreamDetails.String(), `"readQuorum":{"mode":"fail","checks":1,"mismatches":1,"unavailable":0}`)
+
+}
+
+func TestReadQuorumConfigErrors(t *testing.T) {
+
+	for _, tc := range []struct {
+		setup func(conf config.Section)
+		err   string
+	}{
+		{func(conf config.Section) { conf.Set(ReadQuorumMode, "majority") }, "FF23167"},
+		{func(conf config.Section) { conf.Set(ffresty.HTTPConfigProxyURL, "ftp://proxy") }, "FF23080"},
+	} {
+		config.RootConfigReset()
+		conf := config.RootSection("unittest")
+		InitConfig(conf)
+		conf.Set(ReadQuorumURL, "http://localhost:0")
+		tc.setup(conf)
+		c := &ethConnector{}
+		err := c.initReadQuorum(context.Background(), conf, &ffresty.Config{})
+		assert.Regexp(t, tc.err, err)
+		assert.Nil(t, c.readQuorum)
+	}
+
+}
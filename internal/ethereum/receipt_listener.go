@@ -0,0 +1,211 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// ReceiptNotification is pushed to a receipt listener when a watched transaction is mined in a new block.
+// The consumer remains responsible for tracking confirmations, as the block might yet be re-org'd.
+type ReceiptNotification struct {
+	TransactionHash string                             `json:"transactionHash"`
+	BlockNumber     *fftypes.FFBigInt                  `json:"blockNumber"`
+	BlockHash       string                             `json:"blockHash"`
+	Receipt         *ffcapi.TransactionReceiptResponse `json:"receipt,omitempty"` // omitted if the receipt could not be retrieved
+}
+
+type ReceiptListenerRequest struct {
+	ID              *fftypes.UUID               // unique identifier for this listener
+	ListenerContext context.Context             // context that will be cancelled when the listener is no longer required
+	ReceiptListener chan<- *ReceiptNotification // channel to deliver receipt notifications to
+}
+
+type ReceiptListenerResponse struct {
+}
+
+type ReceiptWatchRequest struct {
+	ListenerID        *fftypes.UUID `json:"listenerId"`
+	TransactionHashes []string      `json:"transactionHashes"`
+}
+
+type ReceiptWatchResponse struct {
+	Watching int `json:"watching"` // the number of transactions the listener is waiting for
+}
+
+// receiptListener checks each new block for the transactions that have been registered with it, and notifies
+// the consumer once for each transaction, removing it from the watched set
+type receiptListener struct {
+	id            *fftypes.UUID
+	ctx           context.Context
+	c             *ethConnector
+	notifications chan<- *ReceiptNotification
+	blockUpdates  chan *ffcapi.BlockHashEvent
+	mux           sync.Mutex
+	watched       map[string]bool // lower case transaction hash -> false until the first receipt check
+}
+
+// NewReceiptListener registers a listener that pushes a notification as soon as each of the transactions later
+// registered with ReceiptWatch is mined, so the consumer does not need to poll for receipts. It is available to
+// services embedding the connector, and streamed by the extensions API - FFTM does not use it, and still polls
+// TransactionReceipt.
+func (c *ethConnector) NewReceiptListener(ctx context.Context, req *ReceiptListenerRequest) (*ReceiptListenerResponse, ffcapi.ErrorReason, error) {
+	rl := &receiptListener{
+		id:            req.ID,
+		ctx:           req.ListenerContext,
+		c:             c,
+		notifications: req.ReceiptListener,
+		blockUpdates:  make(chan *ffcapi.BlockHashEvent, 1),
+		watched:       make(map[string]bool),
+	}
+	c.mux.Lock()
+	c.receiptListeners[*req.ID] = rl
+	c.mux.Unlock()
+
+	c.blockListener.addConsumer(&blockUpdateConsumer{
+		id:      req.ID,
+		ctx:     req.ListenerContext,
+		updates: rl.blockUpdates,
+	})
+	go rl.run()
+
+	return &ReceiptListenerResponse{}, "", nil
+}
+
+// ReceiptWatch registers transactions with a receipt listener. Transactions that have already been mined
+// are notified on the next block.
+func (c *ethConnector) ReceiptWatch(ctx context.Context, req *ReceiptWatchRequest) (*ReceiptWatchResponse, ffcapi.ErrorReason, error) {
+	if req.ListenerID == nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgMissingReceiptListenerID)
+	}
+	c.mux.Lock()
+	rl := c.receiptListeners[*req.ListenerID]
+	c.mux.Unlock()
+	if rl == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgReceiptListenerNotFound, req.ListenerID)
+	}
+
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+	for _, h := range req.TransactionHashes {
+		h = strings.ToLower(h)
+		if _, ok := rl.watched[h]; !ok {
+			rl.watched[h] = false
+		}
+	}
+	return &ReceiptWatchResponse{Watching: len(rl.watched)}, "", nil
+}
+
+func (rl *receiptListener) run() {
+	defer func() {
+		rl.c.mux.Lock()
+		delete(rl.c.receiptListeners, *rl.id)
+		rl.c.mux.Unlock()
+	}()
+	for {
+		select {
+		case update := <-rl.blockUpdates:
+			if !rl.checkNewWatched(update.GapPotential) || !rl.checkBlocks(update.BlockHashes) {
+				return
+			}
+		case <-rl.ctx.Done():
+			log.L(rl.ctx).Debugf("Receipt listener %s closed", rl.id)
+			return
+		}
+	}
+}
+
+// checkNewWatched queries the receipts of transactions that were registered since the last block, as they might
+// have been mined before they were registered. All are checked if blocks might have been missed.
+func (rl *receiptListener) checkNewWatched(all bool) bool {
+	rl.mux.Lock()
+	toCheck := make([]string, 0)
+	for h, checked := range rl.watched {
+		if !checked || all {
+			toCheck = append(toCheck, h)
+			rl.watched[h] = true
+		}
+	}
+	rl.mux.Unlock()
+
+	for _, h := range toCheck {
+		var ethReceipt *txReceiptJSONRPC
+		if err := rl.c.backend.CallRPC(rl.ctx, &ethReceipt, "eth_getTransactionReceipt", h); err != nil {
+			log.L(rl.ctx).Warnf("Receipt listener %s failed to check receipt for %s: %s", rl.id, h, err.Message)
+			continue
+		}
+		if ethReceipt != nil && rl.unwatch(h) && !rl.notify(h, (*fftypes.FFBigInt)(ethReceipt.BlockNumber), ethReceipt.BlockHash.String()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rl *receiptListener) checkBlocks(blockHashes []string) bool {
+	for _, blockHash := range blockHashes {
+		bi, err := rl.c.blockListener.getBlockInfoByHash(rl.ctx, blockHash)
+		if err != nil || bi == nil {
+			log.L(rl.ctx).Debugf("Block '%s' not available for receipt checks: %v", blockHash, err)
+			continue
+		}
+		for _, txHash := range bi.Transactions {
+			h := txHash.String()
+			if rl.unwatch(h) && !rl.notify(h, (*fftypes.FFBigInt)(bi.Number), bi.Hash.String()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (rl *receiptListener) unwatch(txHash string) bool {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+	_, watched := rl.watched[txHash]
+	delete(rl.watched, txHash)
+	return watched
+}
+
+func (rl *receiptListener) notify(txHash string, blockNumber *fftypes.FFBigInt, blockHash string) bool {
+	n := &ReceiptNotification{
+		TransactionHash: txHash,
+		BlockNumber:     blockNumber,
+		BlockHash:       blockHash,
+	}
+	receipt, _, err := rl.c.TransactionReceipt(rl.ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+	if err != nil {
+		log.L(rl.ctx).Warnf("Receipt listener %s failed to get receipt for %s mined in block %s: %s", rl.id, txHash, blockNumber, err)
+	} else {
+		n.Receipt = receipt
+	}
+	log.L(rl.ctx).Debugf("Receipt listener %s notifying transaction %s mined in block %s", rl.id, txHash, blockNumber)
+	select {
+	case rl.notifications <- n:
+		return true
+	case <-rl.ctx.Done():
+		log.L(rl.ctx).Debugf("Receipt listener %s closed", rl.id)
+		return false
+	}
+}
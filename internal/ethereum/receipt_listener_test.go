@@ -0,0 +1,197 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testReceiptTX1 = "0x1a1f797ee000c529b6a2dd330cedd0d081417a30d16a4eecb3f863ab4657246f"
+	testReceiptTX2 = "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2"
+)
+
+func newTestReceiptListener(t *testing.T) (context.Context, *receiptListener, chan *ReceiptNotification, *rpcbackendmocks.Backend, func()) {
+	ctx, c, mRPC, done := newTestConnector(t)
+	notifications := make(chan *ReceiptNotification, 10)
+	rl := &receiptListener{
+		id:            fftypes.NewUUID(),
+		ctx:           ctx,
+		c:             c,
+		notifications: notifications,
+		blockUpdates:  make(chan *ffcapi.BlockHashEvent, 1),
+		watched:       make(map[string]bool),
+	}
+	c.receiptListeners[*rl.id] = rl
+	return ctx, rl, notifications, mRPC, done
+}
+
+func mockReceipt(mRPC *rpcbackendmocks.Backend, txHash string, blockNumber int64) *mock.Call {
+	return mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", txHash).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**txReceiptJSONRPC) = &txReceiptJSONRPC{
+			BlockNumber:      ethtypes.NewHexInteger64(blockNumber),
+			BlockHash:        ethtypes.MustNewHexBytes0xPrefix(testBlockHash(blockNumber)),
+			TransactionIndex: ethtypes.NewHexInteger64(0),
+			Status:           ethtypes.NewHexInteger64(1),
+		}
+	})
+}
+
+func testBlockHash(blockNumber int64) string {
+	return ethtypes.HexBytes0xPrefix(ethtypes.NewHexInteger64(blockNumber).BigInt().FillBytes(make([]byte, 32))).String()
+}
+
+func TestReceiptListenerNotifiesMinedTransactions(t *testing.T) {
+
+	ctx, rl, notifications, mRPC, done := newTestReceiptListener(t)
+	defer done()
+	go rl.run()
+
+	// TX1 is already mined when registered, and TX2 is mined in the next block
+	mockReceipt(mRPC, testReceiptTX1, 1000)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", testReceiptTX2).Return(nil).Once()
+	mockReceipt(mRPC, testReceiptTX2, 1001).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", testBlockHash(1001), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1001),
+			Hash:   ethtypes.MustNewHexBytes0xPrefix(testBlockHash(1001)),
+			Transactions: []ethtypes.HexBytes0xPrefix{
+				ethtypes.MustNewHexBytes0xPrefix(testReceiptTX1),
+				ethtypes.MustNewHexBytes0xPrefix(testReceiptTX2),
+			},
+		}
+	})
+
+	res, _, err := rl.c.ReceiptWatch(ctx, &ReceiptWatchRequest{
+		ListenerID:        rl.id,
+		TransactionHashes: []string{testReceiptTX1, "0x7D48AE971FAF089878B57E3C28E3035540D34F38AF395958D2C73C36C57C83A2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, res.Watching)
+
+	rl.blockUpdates <- &ffcapi.BlockHashEvent{BlockHashes: []string{testBlockHash(1001)}}
+
+	n := <-notifications
+	assert.Equal(t, testReceiptTX1, n.TransactionHash)
+	assert.Equal(t, int64(1000), n.BlockNumber.Int64())
+	assert.True(t, n.Receipt.Success)
+	n = <-notifications
+	assert.Equal(t, testReceiptTX2, n.TransactionHash)
+	assert.Equal(t, testBlockHash(1001), n.BlockHash)
+	assert.Equal(t, int64(1001), n.Receipt.BlockNumber.Int64())
+
+	res, _, err = rl.c.ReceiptWatch(ctx, &ReceiptWatchRequest{ListenerID: rl.id})
+	assert.NoError(t, err)
+	assert.Zero(t, res.Watching)
+
+}
+
+func TestReceiptListenerGapPotentialReceiptFailures(t *testing.T) {
+
+	_, rl, notifications, mRPC, done := newTestReceiptListener(t)
+	defer done()
+	rl.c.receiptsNotFoundRetries = 0
+
+	rl.watched[testReceiptTX1] = true
+	rl.watched[testReceiptTX2] = true
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", testReceiptTX1).Return(&rpcbackend.RPCError{Message: "pop"})
+	mockReceipt(mRPC, testReceiptTX2, 1000).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", testReceiptTX2).Return(&rpcbackend.RPCError{Message: "pop"})
+
+	assert.True(t, rl.checkNewWatched(true))
+	n := <-notifications
+	assert.Equal(t, testReceiptTX2, n.TransactionHash)
+	assert.Nil(t, n.Receipt)
+	assert.Equal(t, map[string]bool{testReceiptTX1: true}, rl.watched)
+
+}
+
+func TestReceiptListenerBlockUnavailable(t *testing.T) {
+
+	_, rl, _, mRPC, done := newTestReceiptListener(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil)
+
+	assert.True(t, rl.checkBlocks([]string{testBlockHash(1001)}))
+
+}
+
+func TestReceiptListenerClosed(t *testing.T) {
+
+	ctx, rl, _, mRPC, done := newTestReceiptListener(t)
+	rl.notifications = make(chan *ReceiptNotification)
+	mockReceipt(mRPC, testReceiptTX1, 1000).Maybe()
+
+	rl.watched[testReceiptTX1] = false
+	rl.blockUpdates <- &ffcapi.BlockHashEvent{}
+	done()
+	rl.run()
+
+	_, reason, err := rl.c.ReceiptWatch(ctx, &ReceiptWatchRequest{ListenerID: rl.id})
+	assert.Regexp(t, "FF23089", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	_, reason, err = rl.c.ReceiptWatch(ctx, &ReceiptWatchRequest{})
+	assert.Regexp(t, "FF23203", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestNewReceiptListener(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		hbh := args[1].(*ethtypes.HexInteger)
+		*hbh = *ethtypes.NewHexInteger64(1000)
+	}).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_newBlockFilter").Return(nil).Run(func(args mock.Arguments) {
+		hbh := args[1].(*string)
+		*hbh = "filter_id1"
+	}).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", "filter_id1").Return(nil).Maybe()
+
+	listenerCtx, cancelListener := context.WithCancel(ctx)
+	id := fftypes.NewUUID()
+	res, _, err := c.NewReceiptListener(ctx, &ReceiptListenerRequest{
+		ID:              id,
+		ListenerContext: listenerCtx,
+		ReceiptListener: make(chan *ReceiptNotification),
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, res)
+
+	cancelListener()
+	assert.Eventually(t, func() bool {
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		return len(c.receiptListeners) == 0
+	}, 5*time.Second, time.Millisecond)
+	done()
+
+}
@@ -0,0 +1,106 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// ReorgHalt describes a re-org deeper than the configured maximum, which has halted new block notifications
+type ReorgHalt struct {
+	Detected     *fftypes.FFTime `json:"detected"`
+	ForkBlock    int64           `json:"forkBlock"`    // the first block number that changed
+	PreviousHead int64           `json:"previousHead"` // the head of the chain before the re-org
+	Depth        int64           `json:"depth"`
+	AutoResume   *fftypes.FFTime `json:"autoResume,omitempty"` // if configured, when notifications resume without acknowledgement
+	acknowledged bool
+}
+
+type AcknowledgeReorgRequest struct {
+}
+
+type AcknowledgeReorgResponse struct {
+	ReorgHalt *ReorgHalt `json:"reorgHalt"`
+}
+
+// haltForDeepReorg is called from the listen loop when the chain has changed from forkBlock onwards, and returns
+// true if notifications are halted - either already, or because this change replaced too many blocks
+func (bl *blockListener) haltForDeepReorg(previousHead, forkBlock int64) bool {
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+	if bl.reorgHalt != nil {
+		return true
+	}
+	depth := reorgDepth(previousHead, forkBlock)
+	if bl.maxReorgDepth <= 0 || depth <= bl.maxReorgDepth {
+		return false
+	}
+	bl.deepReorgs++
+	bl.reorgHalt = &ReorgHalt{
+		Detected:     fftypes.Now(),
+		ForkBlock:    forkBlock,
+		PreviousHead: previousHead,
+		Depth:        depth,
+	}
+	if bl.reorgAutoResumeDelay > 0 {
+		autoResume := fftypes.FFTime(time.Now().Add(bl.reorgAutoResumeDelay))
+		bl.reorgHalt.AutoResume = &autoResume
+	}
+	log.L(bl.ctx).Errorf("ALERT: Re-org of %d blocks from block %d (head was %d) exceeds the maximum depth of %d - new block notifications are halted until acknowledged (auto-resume=%s)",
+		depth, forkBlock, previousHead, bl.maxReorgDepth, bl.reorgHalt.AutoResume)
+	return true
+}
+
+// resumeAfterReorgHalt is called from the listen loop, and returns true once when a halt is lifted
+func (bl *blockListener) resumeAfterReorgHalt() bool {
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+	halt := bl.reorgHalt
+	if halt == nil || !(halt.acknowledged || (halt.AutoResume != nil && time.Now().After(*halt.AutoResume.Time()))) {
+		return false
+	}
+	log.L(bl.ctx).Warnf("Resuming new block notifications after re-org of %d blocks from block %d (acknowledged=%t)", halt.Depth, halt.ForkBlock, halt.acknowledged)
+	bl.reorgHalt = nil
+	return true
+}
+
+func (bl *blockListener) getReorgHalt() (*ReorgHalt, int64) {
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+	return bl.reorgHalt, bl.deepReorgs
+}
+
+// AcknowledgeReorg is called by an operator who has confirmed a re-org deeper than the configured maximum is
+// legitimate, to resume new block notifications from the next poll of the block listener
+func (c *ethConnector) AcknowledgeReorg(ctx context.Context, _ *AcknowledgeReorgRequest) (*AcknowledgeReorgResponse, ffcapi.ErrorReason, error) {
+	bl := c.blockListener
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+	if bl.reorgHalt == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgNoReorgHalt)
+	}
+	bl.reorgHalt.acknowledged = true
+	log.L(ctx).Infof("Re-org of %d blocks from block %d acknowledged", bl.reorgHalt.Depth, bl.reorgHalt.ForkBlock)
+	return &AcknowledgeReorgResponse{ReorgHalt: bl.reorgHalt}, "", nil
+}
@@ -0,0 +1,163 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockBlockByHash(mRPC *rpcbackendmocks.Backend, number int64, hash, parentHash ethtypes.HexBytes0xPrefix) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", hash.String(), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number:     ethtypes.NewHexInteger64(number),
+			Hash:       hash,
+			ParentHash: parentHash,
+		}
+	})
+}
+
+func mockFilterChanges(mRPC *rpcbackendmocks.Backend, hashes ...ethtypes.HexBytes0xPrefix) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", "filter_id1").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]ethtypes.HexBytes0xPrefix) = hashes
+	}).Once()
+}
+
+func TestBlockListenerDeepReorgHaltsUntilAcknowledged(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ReorgMaxDepth, 1)
+	})
+	bl := c.blockListener
+	bl.blockPollingInterval = 1 * time.Microsecond
+
+	_, _, err := c.AcknowledgeReorg(ctx, &AcknowledgeReorgRequest{})
+	assert.Regexp(t, "FF23118", err)
+
+	block1000Hash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	block1001Hash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	block1002HashA := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	block1003HashA := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	block1002HashB := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	block1003HashB := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexInteger) = *ethtypes.NewHexInteger64(1000)
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_newBlockFilter").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*string) = "filter_id1"
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*string) = "1337"
+	})
+	mockFilterChanges(mRPC, block1001Hash, block1002HashA)
+	mockFilterChanges(mRPC, block1003HashA)
+	mockFilterChanges(mRPC, block1002HashB) // replaces 1002 and 1003 - a re-org of depth 2
+	mockFilterChanges(mRPC, block1003HashB)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", mock.Anything).Return(nil)
+	mockBlockByHash(mRPC, 1001, block1001Hash, block1000Hash)
+	mockBlockByHash(mRPC, 1002, block1002HashA, block1001Hash)
+	mockBlockByHash(mRPC, 1003, block1003HashA, block1002HashA)
+	mockBlockByHash(mRPC, 1002, block1002HashB, block1001Hash)
+	mockBlockByHash(mRPC, 1003, block1003HashB, block1002HashB)
+
+	updates := make(chan *ffcapi.BlockHashEvent)
+	bl.addConsumer(&blockUpdateConsumer{
+		id:      fftypes.NewUUID(),
+		ctx:     context.Background(),
+		updates: updates,
+	})
+
+	bu := <-updates
+	assert.Equal(t, []string{block1001Hash.String(), block1002HashA.String()}, bu.BlockHashes)
+	bu = <-updates
+	assert.Equal(t, []string{block1003HashA.String()}, bu.BlockHashes)
+
+	// Wait for the listener to follow the fork, without notifying
+	var chain *CanonicalChainResponse
+	for chain == nil || len(chain.Blocks) == 0 || chain.Blocks[len(chain.Blocks)-1].BlockHash != block1003HashB.String() {
+		time.Sleep(1 * time.Millisecond)
+		chain, _, _ = c.CanonicalChain(ctx, &CanonicalChainRequest{})
+	}
+	assert.Equal(t, int64(1002), chain.ReorgHalt.ForkBlock)
+	assert.Equal(t, int64(1003), chain.ReorgHalt.PreviousHead)
+	assert.Equal(t, int64(2), chain.ReorgHalt.Depth)
+	assert.Nil(t, chain.ReorgHalt.AutoResume)
+
+	ready, _, err := c.IsReady(ctx)
+	assert.NoError(t, err)
+	details := ready.DownstreamDetails.JSONObject()
+	assert.Equal(t, int64(1), details.GetInt64("deepReorgs"))
+	assert.Equal(t, int64(2), details.GetObject("reorgHalt").GetInt64("depth"))
+
+	// Once acknowledged, consumers are notified of the whole unstable head
+	res, _, err := c.AcknowledgeReorg(ctx, &AcknowledgeReorgRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), res.ReorgHalt.Depth)
+	bu = <-updates
+	assert.Equal(t, []string{block1001Hash.String(), block1002HashB.String(), block1003HashB.String()}, bu.BlockHashes)
+	assert.True(t, bu.GapPotential)
+
+	done()
+	<-bl.listenLoopDone
+
+	reorgHalt, deepReorgs := bl.getReorgHalt()
+	assert.Nil(t, reorgHalt)
+	assert.Equal(t, int64(1), deepReorgs)
+
+}
+
+func TestReorgHaltAutoResume(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ReorgMaxDepth, 5)
+		conf.Set(ReorgAutoResumeDelay, "1ms")
+	})
+	defer done()
+	bl := c.blockListener
+
+	assert.False(t, bl.haltForDeepReorg(1010, 1006))
+	assert.False(t, bl.resumeAfterReorgHalt())
+	assert.True(t, bl.haltForDeepReorg(1010, 1005))
+	assert.True(t, bl.haltForDeepReorg(1010, 1010)) // remains halted
+	reorgHalt, _ := bl.getReorgHalt()
+	assert.NotNil(t, reorgHalt.AutoResume)
+
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, bl.resumeAfterReorgHalt())
+	assert.False(t, bl.resumeAfterReorgHalt())
+	assert.False(t, bl.haltForDeepReorg(1010, 1010))
+
+}
+
+func TestReorgHaltDisabled(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t)
+	defer done()
+
+	assert.False(t, c.blockListener.haltForDeepReorg(1100, 1000))
+
+}
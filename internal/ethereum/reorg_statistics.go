@@ -0,0 +1,195 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// The statistics are saved on every re-org, and after this many new blocks
+const reorgStatisticsSaveBlocks = 1000
+
+// ReorgStatistics is the distribution of re-org depths observed by the block listener. The depth of a re-org is
+// the number of blocks at the head of the chain it replaced, so a transaction that had at least that many blocks
+// on top of its own was not affected.
+type ReorgStatistics struct {
+	Since          *fftypes.FFTime `json:"since"`
+	BlocksObserved int64           `json:"blocksObserved"`
+	Reorgs         int64           `json:"reorgs"`
+	Depths         map[int64]int64 `json:"depths"` // the number of re-orgs of each depth
+	MaxDepth       int64           `json:"maxDepth"`
+	LastReorg      *fftypes.FFTime `json:"lastReorg,omitempty"`
+}
+
+type ReorgStatisticsRequest struct {
+}
+
+type ReorgStatisticsResponse struct {
+	ReorgStatistics
+	DepthPercentiles map[string]int64 `json:"depthPercentiles"` // depths that the given percentage of re-orgs did not exceed
+	ImmutableDepth   int64            `json:"immutableDepth"`   // blocks on top of a transaction's block, beyond which no re-org has been observed
+}
+
+// reorgStatistics accumulates the statistics in memory, and optionally persists them to a file so they build up
+// across restarts of the connector
+type reorgStatistics struct {
+	mux     sync.Mutex
+	file    string
+	stats   ReorgStatistics
+	head    int64 // highest block counted
+	unsaved int64
+}
+
+func newReorgStatistics(ctx context.Context, file string) (*reorgStatistics, error) {
+	rs := &reorgStatistics{
+		file: file,
+		head: -1,
+		stats: ReorgStatistics{
+			Since:  fftypes.Now(),
+			Depths: make(map[int64]int64),
+		},
+	}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			log.L(ctx).Infof("Starting new re-org statistics in '%s'", file)
+		case err != nil:
+			return nil, i18n.NewError(ctx, msgs.MsgInvalidReorgStatistics, file, err)
+		default:
+			if err := json.Unmarshal(b, &rs.stats); err != nil {
+				return nil, i18n.NewError(ctx, msgs.MsgInvalidReorgStatistics, file, err)
+			}
+			if rs.stats.Depths == nil {
+				rs.stats.Depths = make(map[int64]int64)
+			}
+		}
+	}
+	return rs, nil
+}
+
+// reorgDepth is the number of blocks at the head of the chain replaced by a change from forkBlock onwards,
+// which is zero or less if the chain was only extended
+func reorgDepth(previousHead, forkBlock int64) int64 {
+	return previousHead - forkBlock + 1
+}
+
+// record is called from the listen loop each time the canonical chain changes from forkBlock onwards. This is
+// the single place re-orgs are tracked - the deepest seen is also used to switch block range log queries to be
+// pinned by block hash.
+func (rs *reorgStatistics) record(ctx context.Context, previousHead, forkBlock, newHead int64) {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	save := false
+	if depth := reorgDepth(previousHead, forkBlock); previousHead >= 0 && depth > 0 {
+		rs.stats.Reorgs++
+		rs.stats.Depths[depth]++
+		if depth > rs.stats.MaxDepth {
+			rs.stats.MaxDepth = depth
+		}
+		rs.stats.LastReorg = fftypes.Now()
+		save = true
+	}
+	if rs.head >= 0 && newHead > rs.head {
+		rs.stats.BlocksObserved += newHead - rs.head
+		rs.unsaved += newHead - rs.head
+	}
+	if newHead > rs.head {
+		rs.head = newHead
+	}
+	if save || rs.unsaved >= reorgStatisticsSaveBlocks {
+		rs.saveLocked(ctx)
+	}
+}
+
+func (rs *reorgStatistics) save(ctx context.Context) {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	rs.saveLocked(ctx)
+}
+
+func (rs *reorgStatistics) saveLocked(ctx context.Context) {
+	rs.unsaved = 0
+	if rs.file == "" {
+		return
+	}
+	b, _ := json.Marshal(&rs.stats)
+	tmpFile := rs.file + ".tmp"
+	err := os.WriteFile(tmpFile, b, 0600)
+	if err == nil {
+		err = os.Rename(tmpFile, rs.file)
+	}
+	if err != nil {
+		// The statistics are still available in memory
+		log.L(ctx).Errorf("Failed to save re-org statistics to '%s': %s", rs.file, err)
+	}
+}
+
+// maxDepth is the deepest re-org observed - including those before a restart, if the statistics are persisted
+func (rs *reorgStatistics) maxDepth() int64 {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	return rs.stats.MaxDepth
+}
+
+func (rs *reorgStatistics) getStatistics() *ReorgStatisticsResponse {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	res := &ReorgStatisticsResponse{
+		ReorgStatistics:  rs.stats,
+		DepthPercentiles: make(map[string]int64),
+		ImmutableDepth:   rs.stats.MaxDepth,
+	}
+	res.Depths = make(map[int64]int64, len(rs.stats.Depths))
+	depths := make([]int64, 0, len(rs.stats.Depths))
+	for depth, count := range rs.stats.Depths {
+		res.Depths[depth] = count
+		depths = append(depths, depth)
+	}
+	sort.Slice(depths, func(i, j int) bool { return depths[i] < depths[j] })
+	for _, p := range []struct {
+		name    string
+		percent int64
+	}{{"p50", 50}, {"p90", 90}, {"p99", 99}} {
+		var cumulative int64
+		for _, depth := range depths {
+			cumulative += rs.stats.Depths[depth]
+			if cumulative*100 >= rs.stats.Reorgs*p.percent {
+				res.DepthPercentiles[p.name] = depth
+				break
+			}
+		}
+	}
+	return res
+}
+
+// ReorgStatistics returns the distribution of re-org depths observed on the chain, to help operators choose
+// the number of confirmations required for transactions and events
+func (c *ethConnector) ReorgStatistics(_ context.Context, _ *ReorgStatisticsRequest) (*ReorgStatisticsResponse, ffcapi.ErrorReason, error) {
+	return c.blockListener.reorgStats.getStatistics(), "", nil
+}
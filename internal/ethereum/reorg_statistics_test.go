@@ -0,0 +1,135 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReorgStatisticsRecord(t *testing.T) {
+
+	ctx := context.Background()
+	rs, err := newReorgStatistics(ctx, "")
+	assert.NoError(t, err)
+
+	rs.record(ctx, -1, 1000, 1000) // first block
+	rs.record(ctx, 1000, 1001, 1001)
+	for i := 0; i < 8; i++ {
+		rs.record(ctx, 1001, 1001, 1001) // depth 1
+	}
+	rs.record(ctx, 1001, 1000, 1002) // depth 2
+	rs.record(ctx, 1002, 998, 1002)  // depth 5
+
+	res := rs.getStatistics()
+	assert.Equal(t, int64(2), res.BlocksObserved)
+	assert.Equal(t, int64(10), res.Reorgs)
+	assert.Equal(t, map[int64]int64{1: 8, 2: 1, 5: 1}, res.Depths)
+	assert.Equal(t, int64(5), res.MaxDepth)
+	assert.Equal(t, int64(5), res.ImmutableDepth)
+	assert.Equal(t, map[string]int64{"p50": 1, "p90": 2, "p99": 5}, res.DepthPercentiles)
+	assert.NotNil(t, res.LastReorg)
+
+	// The response is a copy
+	res.Depths[1] = 100
+	assert.Equal(t, int64(8), rs.getStatistics().Depths[1])
+
+}
+
+func TestReorgStatisticsPersisted(t *testing.T) {
+
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "reorgs.json")
+	rs, err := newReorgStatistics(ctx, file)
+	assert.NoError(t, err)
+
+	rs.record(ctx, -1, 1000, 1000)
+	rs.record(ctx, 1000, 1000+reorgStatisticsSaveBlocks, 1000+reorgStatisticsSaveBlocks)
+	rs, err = newReorgStatistics(ctx, file)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(reorgStatisticsSaveBlocks), rs.getStatistics().BlocksObserved)
+
+	rs.record(ctx, 1000, 1000, 1000)
+	rs, err = newReorgStatistics(ctx, file)
+	assert.NoError(t, err)
+	res := rs.getStatistics()
+	assert.Equal(t, int64(1), res.Reorgs)
+	assert.Equal(t, map[int64]int64{1: 1}, res.Depths)
+
+	// Save failures are logged
+	rs.file = filepath.Join(file, "not-a-dir")
+	rs.record(ctx, 1000, 1000, 1000)
+	assert.Equal(t, int64(2), rs.getStatistics().Reorgs)
+
+}
+
+func TestReorgStatisticsBadFile(t *testing.T) {
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	_, err := newReorgStatistics(ctx, dir)
+	assert.Regexp(t, "FF23180", err)
+
+	file := filepath.Join(dir, "reorgs.json")
+	err = os.WriteFile(file, []byte(`!json`), 0600)
+	assert.NoError(t, err)
+	_, err = newReorgStatistics(ctx, file)
+	assert.Regexp(t, "FF23180", err)
+
+	err = os.WriteFile(file, []byte(`{}`), 0600)
+	assert.NoError(t, err)
+	rs, err := newReorgStatistics(ctx, file)
+	assert.NoError(t, err)
+	assert.NotNil(t, rs.stats.Depths)
+
+	config.RootConfigReset()
+	conf := config.RootSection("unittest")
+	InitConfig(conf)
+	conf.Set(ffresty.HTTPConfigURL, "http://localhost:8545")
+	conf.Set(ReorgStatisticsFile, dir)
+	_, err = NewEthereumConnector(ctx, conf)
+	assert.Regexp(t, "FF23180", err)
+
+}
+
+func TestReorgStatisticsStatus(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil)
+	res, _, err := c.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.NotContains(t, res.DownstreamDetails.String(), "reorgStatistics")
+
+	c.blockListener.reorgStats.record(ctx, 1003, 1002, 1003)
+	res, _, err = c.IsReady(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), res.DownstreamDetails.JSONObject().GetObject("reorgStatistics").GetInt64("maxDepth"))
+
+	stats, _, err := c.ReorgStatistics(ctx, &ReorgStatisticsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.ImmutableDepth)
+
+}
@@ -0,0 +1,197 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/big"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type FeeBumpPolicy string
+
+const (
+	// FeeBumpPolicyPercent increases the fees of the original transaction by the bump percentage
+	FeeBumpPolicyPercent FeeBumpPolicy = "percent"
+	// FeeBumpPolicyOracle uses the current eth_gasPrice of the node, if that is higher than the percentage bump
+	FeeBumpPolicyOracle FeeBumpPolicy = "oracle"
+)
+
+// replacementTransferGas is the gas limit used for the default zero-value transfer
+const replacementTransferGas = 21000
+
+// minReplacementFeeBumpPercent is the smallest increase in fees that nodes accept for a replacement transaction,
+// such as the default price bump of the geth txpool
+const minReplacementFeeBumpPercent = 10
+
+type TransactionReplaceRequest struct {
+	TransactionHash string            `json:"transactionHash"`          // the pending transaction to replace
+	To              string            `json:"to,omitempty"`             // defaults to a self-send to the signer of the pending transaction
+	Value           *fftypes.FFBigInt `json:"value,omitempty"`          // defaults to zero
+	Gas             *fftypes.FFBigInt `json:"gas,omitempty"`            // defaults to the gas of a simple transfer
+	FeeBumpPolicy   FeeBumpPolicy     `json:"feeBumpPolicy,omitempty"`  // defaults to "percent"
+	FeeBumpPercent  *float64          `json:"feeBumpPercent,omitempty"` // defaults to the configured replacementFeeBumpPercent
+}
+
+type TransactionReplaceResponse struct {
+	OriginalTransactionHash string           `json:"originalTransactionHash"`
+	TransactionHash         string           `json:"transactionHash"`
+	GasPrice                *fftypes.JSONAny `json:"gasPrice"`
+}
+
+// TransactionReplace builds and submits a transaction with the same signer and nonce as a pending
+// transaction, with fees bumped sufficiently for the node to accept it as a replacement.
+// By default this is a zero-value send back to the signer, which effectively cancels the original.
+func (c *ethConnector) TransactionReplace(ctx context.Context, req *TransactionReplaceRequest) (*TransactionReplaceResponse, ffcapi.ErrorReason, error) {
+	if reason, err := c.checkLeader(ctx); err != nil {
+		return nil, reason, err
+	}
+	done, reason, err := c.beginWork(ctx)
+	if err != nil {
+		return nil, reason, err
+	}
+	defer done()
+
+	originalHash, err := ethtypes.NewHexBytes0xPrefix(req.TransactionHash)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidTXHash, req.TransactionHash, err)
+	}
+
+	feeBumpPolicy := req.FeeBumpPolicy
+	switch feeBumpPolicy {
+	case "":
+		feeBumpPolicy = FeeBumpPolicyPercent
+	case FeeBumpPolicyPercent, FeeBumpPolicyOracle:
+	default:
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgBadFeeBumpPolicy, feeBumpPolicy, "percent,oracle")
+	}
+	feeBumpPercent := c.replacementFeeBumpPercent
+	if req.FeeBumpPercent != nil {
+		feeBumpPercent = *req.FeeBumpPercent
+		if feeBumpPercent < minReplacementFeeBumpPercent {
+			return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgFeeBumpPercentTooLow, "feeBumpPercent", feeBumpPercent, minReplacementFeeBumpPercent)
+		}
+	}
+
+	// We deliberately bypass the transaction cache, as we need the latest state of the transaction
+	var txInfo *txInfoJSONRPC
+	if rpcErr := c.backend.CallRPC(ctx, &txInfo, "eth_getTransactionByHash", originalHash); rpcErr != nil {
+		return nil, "", rpcErr.Error()
+	}
+	if txInfo == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgTransactionNotFound, originalHash)
+	}
+	if txInfo.BlockNumber != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgTransactionAlreadyMined, originalHash, txInfo.BlockNumber.BigInt())
+	}
+
+	to := req.To
+	if to == "" {
+		to = txInfo.From.String()
+	}
+	value := req.Value
+	if value == nil {
+		value = fftypes.NewFFBigInt(0)
+	}
+	gas := req.Gas
+	if gas == nil {
+		gas = fftypes.NewFFBigInt(replacementTransferGas)
+	}
+	tx, err := c.buildTx(ctx, txTypePrePrepared, txInfo.From.String(), to, (*fftypes.FFBigInt)(txInfo.Nonce), gas, value, nil)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+
+	var oraclePrice *big.Int
+	if feeBumpPolicy == FeeBumpPolicyOracle {
+		var gasPrice ethtypes.HexInteger
+		if rpcErr := c.backend.CallRPC(ctx, &gasPrice, "eth_gasPrice"); rpcErr != nil {
+			return nil, "", rpcErr.Error()
+		}
+		oraclePrice = gasPrice.BigInt()
+	}
+	gasPrice := bumpReplacementFees(txInfo, tx, feeBumpPercent, oraclePrice)
+	log.L(ctx).Infof("Replacing transaction %s nonce=%s policy=%s bump=%.2f%% gasPrice=%s", originalHash, tx.Nonce.BigInt(), feeBumpPolicy, feeBumpPercent, gasPrice)
+
+	hookTx := unsignedHookTransaction(tx)
+	if reason, err := c.runPreSubmitHook(ctx, hookTx, tx); err != nil {
+		return nil, reason, err
+	}
+
+	var txHash ethtypes.HexBytes0xPrefix
+	rpcError := c.signingBackend(ctx, txInfo.From.String()).CallRPC(ctx, &txHash, "eth_sendTransaction", tx)
+	if rpcError == nil && len(txHash) != 32 {
+		rpcError = &rpcbackend.RPCError{Message: i18n.NewError(ctx, msgs.MsgInvalidTXHashReturned, len(txHash)).Error()}
+	}
+	if rpcError != nil {
+		return nil, c.mapRPCError(sendRPCMethods, rpcError), rpcError.Error()
+	}
+	c.runPostSubmitHook(ctx, hookTx, txHash.String())
+	return &TransactionReplaceResponse{
+		OriginalTransactionHash: originalHash.String(),
+		TransactionHash:         txHash.String(),
+		GasPrice:                gasPrice,
+	}, "", nil
+
+}
+
+// bumpReplacementFees sets the fees on the replacement transaction, using the same fee model (legacy or EIP-1559)
+// as the original transaction, and returns them in the same JSON format accepted by mapGasPrice
+func bumpReplacementFees(txInfo *txInfoJSONRPC, tx *ethsigner.Transaction, feeBumpPercent float64, oraclePrice *big.Int) *fftypes.JSONAny {
+	var gasPrice map[string]*fftypes.FFBigInt
+	if txInfo.MaxFeePerGas.BigInt().Sign() > 0 {
+		tx.MaxFeePerGas = (*ethtypes.HexInteger)(maxBigInt(bumpFee(txInfo.MaxFeePerGas.BigInt(), feeBumpPercent), oraclePrice))
+		tx.MaxPriorityFeePerGas = (*ethtypes.HexInteger)(bumpFee(txInfo.MaxPriorityFeePerGas.BigInt(), feeBumpPercent))
+		gasPrice = map[string]*fftypes.FFBigInt{
+			"maxFeePerGas":         (*fftypes.FFBigInt)(tx.MaxFeePerGas),
+			"maxPriorityFeePerGas": (*fftypes.FFBigInt)(tx.MaxPriorityFeePerGas),
+		}
+	} else {
+		tx.GasPrice = (*ethtypes.HexInteger)(maxBigInt(bumpFee(txInfo.GasPrice.BigInt(), feeBumpPercent), oraclePrice))
+		gasPrice = map[string]*fftypes.FFBigInt{
+			"gasPrice": (*fftypes.FFBigInt)(tx.GasPrice),
+		}
+	}
+	b, _ := json.Marshal(gasPrice)
+	return fftypes.JSONAnyPtrBytes(b)
+}
+
+// bumpFee increases the fee by the percentage, rounding up so the bump is never less than requested. The
+// percentage is applied in basis points with integer arithmetic, so large fees are bumped exactly.
+func bumpFee(fee *big.Int, feeBumpPercent float64) *big.Int {
+	basisPoints := big.NewInt(10000 + int64(math.Round(feeBumpPercent*100)))
+	bumped := new(big.Int).Mul(fee, basisPoints)
+	bumped.Add(bumped, big.NewInt(9999))
+	return bumped.Quo(bumped, big.NewInt(10000))
+}
+
+func maxBigInt(a, b *big.Int) *big.Int {
+	if b != nil && b.Cmp(a) > 0 {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,293 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleReplaceTXHash = "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2"
+
+func mockPendingTX(mRPC *rpcbackendmocks.Backend, txInfo *txInfoJSONRPC) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.MatchedBy(func(h ethtypes.HexBytes0xPrefix) bool {
+		return h.String() == sampleReplaceTXHash
+	})).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(**txInfoJSONRPC)) = txInfo
+	})
+}
+
+func TestTransactionReplaceLegacyCancelOK(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockPendingTX(mRPC, &txInfoJSONRPC{
+		From:     ethtypes.MustNewAddress("0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8"),
+		Nonce:    ethtypes.NewHexInteger64(111),
+		GasPrice: ethtypes.NewHexInteger64(1000000000),
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.MatchedBy(func(tx *ethsigner.Transaction) bool {
+		return tx.To.String() == "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8" &&
+			tx.Nonce.BigInt().Int64() == 111 &&
+			tx.Value.BigInt().Int64() == 0 &&
+			tx.GasLimit.BigInt().Int64() == 21000 &&
+			tx.GasPrice.BigInt().Int64() == 1125000000 &&
+			len(tx.Data) == 0
+	})).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x3a5fba5b6e4ee1e4ffaf1bc44e6ec2f0b1dd0ef5fe2a4cc6a6a5d5c6c3b1d5e8")
+	})
+
+	res, reason, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, sampleReplaceTXHash, res.OriginalTransactionHash)
+	assert.Equal(t, "0x3a5fba5b6e4ee1e4ffaf1bc44e6ec2f0b1dd0ef5fe2a4cc6a6a5d5c6c3b1d5e8", res.TransactionHash)
+	assert.JSONEq(t, `{"gasPrice":"1125000000"}`, res.GasPrice.String())
+
+}
+
+func TestTransactionReplaceEIP1559OracleOK(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockPendingTX(mRPC, &txInfoJSONRPC{
+		From:                 ethtypes.MustNewAddress("0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8"),
+		Nonce:                ethtypes.NewHexInteger64(111),
+		MaxFeePerGas:         ethtypes.NewHexInteger64(1000),
+		MaxPriorityFeePerGas: ethtypes.NewHexInteger64(100),
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_gasPrice").Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(5000)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.MatchedBy(func(tx *ethsigner.Transaction) bool {
+		return tx.To.String() == "0xe1a078b9e2b145d0a7387f09277c6ae1d9470771" &&
+			tx.Value.BigInt().Int64() == 10 &&
+			tx.GasLimit.BigInt().Int64() == 50000 &&
+			tx.GasPrice == nil &&
+			tx.MaxFeePerGas.BigInt().Int64() == 5000 &&
+			tx.MaxPriorityFeePerGas.BigInt().Int64() == 121
+	})).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x3a5fba5b6e4ee1e4ffaf1bc44e6ec2f0b1dd0ef5fe2a4cc6a6a5d5c6c3b1d5e8")
+	})
+
+	bump := 20.5
+	res, _, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+		To:              "0xe1a078b9e2b145d0a7387f09277c6ae1d9470771",
+		Value:           fftypes.NewFFBigInt(10),
+		Gas:             fftypes.NewFFBigInt(50000),
+		FeeBumpPolicy:   FeeBumpPolicyOracle,
+		FeeBumpPercent:  &bump,
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"maxFeePerGas":"5000","maxPriorityFeePerGas":"121"}`, res.GasPrice.String())
+
+}
+
+func TestTransactionReplaceBadHash(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: "not hex",
+	})
+	assert.Regexp(t, "FF23059", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestTransactionReplaceBadPolicy(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+		FeeBumpPolicy:   "wrong",
+	})
+	assert.Regexp(t, "FF23062", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestTransactionReplaceBadFeeBumpPercent(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	for _, bump := range []float64{-10, 0, 9.99} {
+		_, reason, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+			TransactionHash: sampleReplaceTXHash,
+			FeeBumpPercent:  &bump,
+		})
+		assert.Regexp(t, "FF23202.*feeBumpPercent", err)
+		assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+	}
+
+}
+
+func TestTransactionReplaceGetTXFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"})
+
+	_, _, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+	})
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestTransactionReplaceNotFound(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockPendingTX(mRPC, nil)
+
+	_, reason, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+	})
+	assert.Regexp(t, "FF23060", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+}
+
+func TestTransactionReplaceAlreadyMined(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockPendingTX(mRPC, &txInfoJSONRPC{
+		BlockNumber: ethtypes.NewHexInteger64(12345),
+	})
+
+	_, reason, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+	})
+	assert.Regexp(t, "FF23061.*12345", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestTransactionReplaceBadTo(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockPendingTX(mRPC, &txInfoJSONRPC{
+		From:  ethtypes.MustNewAddress("0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8"),
+		Nonce: ethtypes.NewHexInteger64(111),
+	})
+
+	_, reason, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+		To:              "wrong",
+	})
+	assert.Regexp(t, "FF23020", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestTransactionReplaceGasPriceFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockPendingTX(mRPC, &txInfoJSONRPC{
+		From:  ethtypes.MustNewAddress("0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8"),
+		Nonce: ethtypes.NewHexInteger64(111),
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_gasPrice").Return(&rpcbackend.RPCError{Message: "pop"})
+
+	_, _, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+		FeeBumpPolicy:   FeeBumpPolicyOracle,
+	})
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestTransactionReplaceSendFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockPendingTX(mRPC, &txInfoJSONRPC{
+		From:     ethtypes.MustNewAddress("0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8"),
+		Nonce:    ethtypes.NewHexInteger64(111),
+		GasPrice: ethtypes.NewHexInteger64(100),
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "replacement transaction underpriced"})
+
+	_, reason, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+	})
+	assert.Regexp(t, "underpriced", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, reason)
+
+}
+
+func TestTransactionReplaceBadHashReturned(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockPendingTX(mRPC, &txInfoJSONRPC{
+		From:     ethtypes.MustNewAddress("0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8"),
+		Nonce:    ethtypes.NewHexInteger64(111),
+		GasPrice: ethtypes.NewHexInteger64(100),
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).Return(nil)
+
+	_, _, err := c.TransactionReplace(ctx, &TransactionReplaceRequest{
+		TransactionHash: sampleReplaceTXHash,
+	})
+	assert.Regexp(t, "FF23048", err)
+
+}
+
+func TestBumpFeeRoundsUp(t *testing.T) {
+
+	assert.Equal(t, int64(12), bumpFee(big.NewInt(10), 12.5).Int64())
+	assert.Equal(t, int64(1125), bumpFee(big.NewInt(1000), 12.5).Int64())
+	assert.Equal(t, int64(0), bumpFee(big.NewInt(0), 12.5).Int64())
+	assert.Equal(t, int64(11), bumpFee(big.NewInt(10), 10).Int64())
+
+	// Exact for fees beyond the precision of a float64
+	fee, _ := new(big.Int).SetString("1000000000000000000000000000001", 10)
+	assert.Equal(t, "1125000000000000000000000000002", bumpFee(fee, 12.5).String())
+
+}
@@ -28,11 +28,12 @@ func (c *ethConnector) doFailureDelay(ctx context.Context, failureCount int) boo
 		return false
 	}
 
-	retryDelay := c.retry.InitialDelay
+	r := c.tuned().retry
+	retryDelay := r.InitialDelay
 	for i := 0; i < (failureCount - 1); i++ {
-		retryDelay = time.Duration(float64(retryDelay) * c.retry.Factor)
-		if retryDelay > c.retry.MaximumDelay {
-			retryDelay = c.retry.MaximumDelay
+		retryDelay = time.Duration(float64(retryDelay) * r.Factor)
+		if retryDelay > r.MaximumDelay {
+			retryDelay = r.MaximumDelay
 			break
 		}
 	}
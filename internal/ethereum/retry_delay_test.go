@@ -19,17 +19,18 @@ package ethereum
 import (
 	"context"
 	"testing"
-	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
 )
 
 func TestRetryDelay(t *testing.T) {
 
-	_, c, _, done := newTestConnector(t)
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(RetryMaxDelay, "1us")
+		conf.Set(RetryInitDelay, "100us")
+	})
 	defer done()
 
-	c.retry.MaximumDelay = 1 * time.Microsecond
-	c.retry.InitialDelay = 100 * time.Microsecond
-
 	c.doFailureDelay(context.Background(), 1)
 	c.doFailureDelay(context.Background(), 10)
 
@@ -0,0 +1,159 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+)
+
+// oauth2TokenExpirySkew is how long before the advertised expiry we refresh a token
+const oauth2TokenExpirySkew = 30 * time.Second
+
+// configureRPCAuth wraps the transport of the HTTP JSON/RPC client with request authentication,
+// for the schemes beyond basic auth that are commonly required by reverse proxies in front of nodes
+func configureRPCAuth(ctx context.Context, conf config.Section, client *resty.Client) error {
+	oauth2TokenURL := conf.GetString(AuthOAuth2TokenURL)
+	sigV4Region := conf.GetString(AuthSigV4Region)
+	if oauth2TokenURL != "" && sigV4Region != "" {
+		return i18n.NewError(ctx, msgs.MsgMultipleRPCAuthSchemes)
+	}
+
+	base := client.GetClient().Transport
+	switch {
+	case oauth2TokenURL != "":
+		log.L(ctx).Infof("JSON/RPC requests will be authenticated with OAuth2 client credentials from %s", oauth2TokenURL)
+		client.SetTransport(&oauth2Transport{
+			base:         base,
+			tokenURL:     oauth2TokenURL,
+			clientID:     conf.GetString(AuthOAuth2ClientID),
+			clientSecret: conf.GetString(AuthOAuth2ClientSecret),
+			scopes:       conf.GetStringSlice(AuthOAuth2Scopes),
+		})
+	case sigV4Region != "":
+		t := &sigV4Transport{
+			base:            base,
+			region:          sigV4Region,
+			service:         conf.GetString(AuthSigV4Service),
+			accessKeyID:     conf.GetString(AuthSigV4AccessKeyID),
+			secretAccessKey: conf.GetString(AuthSigV4SecretAccessKey),
+			sessionToken:    conf.GetString(AuthSigV4SessionToken),
+			now:             time.Now,
+		}
+		if t.accessKeyID == "" {
+			// Fall back to the standard AWS environment variables
+			t.accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+			t.secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			t.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+		if t.accessKeyID == "" || t.secretAccessKey == "" {
+			return i18n.NewError(ctx, msgs.MsgMissingSigV4Credentials)
+		}
+		log.L(ctx).Infof("JSON/RPC requests will be signed with AWS SigV4 region=%s service=%s", t.region, t.service)
+		client.SetTransport(t)
+	}
+	return nil
+}
+
+type oauth2Transport struct {
+	base         http.RoundTripper
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mux    sync.Mutex
+	token  string
+	expiry time.Time // zero if the token server did not advertise an expiry
+}
+
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (t *oauth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.getToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Authorization", "Bearer "+token)
+	res, err := t.base.RoundTrip(authReq)
+	if err == nil && res.StatusCode == http.StatusUnauthorized {
+		// The token might have been revoked before expiry, so we get a new one for the next request
+		t.mux.Lock()
+		t.token = ""
+		t.mux.Unlock()
+	}
+	return res, err
+}
+
+func (t *oauth2Transport) getToken(ctx context.Context) (string, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.token != "" && (t.expiry.IsZero() || time.Now().Before(t.expiry)) {
+		return t.token, nil
+	}
+
+	form := url.Values{"grant_type": []string{"client_credentials"}}
+	if len(t.scopes) > 0 {
+		form.Set("scope", strings.Join(t.scopes, " "))
+	}
+	tokenReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", i18n.NewError(ctx, msgs.MsgOAuth2TokenFailed, t.tokenURL, err)
+	}
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.Header.Set("Accept", "application/json")
+	tokenReq.SetBasicAuth(url.QueryEscape(t.clientID), url.QueryEscape(t.clientSecret))
+	res, err := t.base.RoundTrip(tokenReq)
+	if err != nil {
+		return "", i18n.NewError(ctx, msgs.MsgOAuth2TokenFailed, t.tokenURL, err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", i18n.NewError(ctx, msgs.MsgOAuth2TokenFailed, t.tokenURL, res.Status)
+	}
+	var tokenRes oauth2TokenResponse
+	if err := json.Unmarshal(body, &tokenRes); err != nil || tokenRes.AccessToken == "" {
+		return "", i18n.NewError(ctx, msgs.MsgOAuth2TokenFailed, t.tokenURL, "no access_token in response")
+	}
+
+	t.token = tokenRes.AccessToken
+	t.expiry = time.Time{}
+	if tokenRes.ExpiresIn > 0 {
+		t.expiry = time.Now().Add(time.Duration(tokenRes.ExpiresIn)*time.Second - oauth2TokenExpirySkew)
+	}
+	log.L(ctx).Debugf("Obtained OAuth2 access token from %s (expires_in=%ds)", t.tokenURL, tokenRes.ExpiresIn)
+	return t.token, nil
+}
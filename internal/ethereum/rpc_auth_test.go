@@ -0,0 +1,241 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("pop")
+}
+
+func newTestAuthConf(t *testing.T, url string) config.Section {
+	config.RootConfigReset()
+	conf := config.RootSection("unittest")
+	InitConfig(conf)
+	conf.Set(ffresty.HTTPConfigURL, url)
+	conf.Set(BlockPollingInterval, "1h")
+	return conf
+}
+
+func TestSigV4GetVanillaTestVector(t *testing.T) {
+
+	// The "get-vanilla" case from the AWS SigV4 test suite
+	var authHeader string
+	st := &sigV4Transport{
+		base: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			authHeader = req.Header.Get("Authorization")
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}),
+		region:          "us-east-1",
+		service:         "service",
+		accessKeyID:     "AKIDEXAMPLE",
+		secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		now: func() time.Time {
+			return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+		},
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com", nil)
+	assert.NoError(t, err)
+	req.Host = "" // use the URL host
+	_, err = st.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", authHeader)
+
+}
+
+func TestSigV4SignsJSONRPCRequests(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=AKID/\d{8}/us-west-2/managedblockchain/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token, Signature=[0-9a-f]{64}$`, r.Header.Get("Authorization"))
+		assert.Equal(t, "session1", r.Header.Get("X-Amz-Security-Token"))
+		assert.Equal(t, "b", r.URL.Query().Get("a"))
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), "eth_chainId")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x539"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session1")
+	conf := newTestAuthConf(t, server.URL+"/rpc?a=b")
+	conf.Set(AuthSigV4Region, "us-west-2")
+
+	cc, err := NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+	var chainID string
+	rpcErr := c.backend.CallRPC(context.Background(), &chainID, "eth_chainId")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, "0x539", chainID)
+
+}
+
+func TestSigV4BodyReadFail(t *testing.T) {
+
+	st := &sigV4Transport{now: time.Now}
+	req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com", errReader{})
+	assert.NoError(t, err)
+	_, err = st.RoundTrip(req)
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestSigV4MissingCredentials(t *testing.T) {
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(AuthSigV4Region, "us-west-2")
+
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23064", err)
+
+}
+
+func TestRPCAuthMultipleSchemes(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(AuthSigV4Region, "us-west-2")
+	conf.Set(AuthOAuth2TokenURL, "http://localhost:8080/token")
+
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23063", err)
+
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+
+	tokenCount := 0
+	rpcCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			tokenCount++
+			clientID, clientSecret, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "client1", clientID)
+			assert.Equal(t, "secret1", clientSecret)
+			assert.NoError(t, r.ParseForm())
+			assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			assert.Equal(t, "rpc.read rpc.write", r.PostForm.Get("scope"))
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"access_token":"token%d","token_type":"Bearer","expires_in":3600}`, tokenCount)))
+		default:
+			rpcCount++
+			if rpcCount == 2 {
+				// Simulate revocation of the first token
+				assert.Equal(t, "Bearer token1", r.Header.Get("Authorization"))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, fmt.Sprintf("Bearer token%d", tokenCount), r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x539"}`))
+		}
+	}))
+	defer server.Close()
+
+	conf := newTestAuthConf(t, server.URL)
+	conf.Set(AuthOAuth2TokenURL, server.URL+"/token")
+	conf.Set(AuthOAuth2ClientID, "client1")
+	conf.Set(AuthOAuth2ClientSecret, "secret1")
+	conf.Set(AuthOAuth2Scopes, []string{"rpc.read", "rpc.write"})
+	conf.Set(ffresty.HTTPConfigRetryEnabled, false)
+
+	cc, err := NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+
+	var chainID string
+	rpcErr := c.backend.CallRPC(context.Background(), &chainID, "eth_chainId")
+	assert.Nil(t, rpcErr)
+	rpcErr = c.backend.CallRPC(context.Background(), &chainID, "eth_chainId")
+	assert.NotNil(t, rpcErr)
+	rpcErr = c.backend.CallRPC(context.Background(), &chainID, "eth_chainId")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, 2, tokenCount)
+	assert.Equal(t, 3, rpcCount)
+
+}
+
+func TestOAuth2NoExpiryCachedIndefinitely(t *testing.T) {
+
+	tokenCount := 0
+	ot := &oauth2Transport{
+		tokenURL: "http://localhost/token",
+		base: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			tokenCount++
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"access_token":"token1"}`))}, nil
+		}),
+	}
+	for i := 0; i < 3; i++ {
+		token, err := ot.getToken(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "token1", token)
+	}
+	assert.Equal(t, 1, tokenCount)
+
+}
+
+func TestOAuth2TokenErrors(t *testing.T) {
+
+	respond := func(status int, body string) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: status, Status: fmt.Sprintf("%d", status), Body: io.NopCloser(strings.NewReader(body))}, nil
+		})
+	}
+
+	ot := &oauth2Transport{tokenURL: "http://localhost/token", base: respond(401, `{}`)}
+	_, err := ot.RoundTrip(httptest.NewRequest(http.MethodPost, "http://localhost/rpc", nil))
+	assert.Regexp(t, "FF23065.*401", err)
+
+	ot = &oauth2Transport{tokenURL: "http://localhost/token", base: respond(200, `!json`)}
+	_, err = ot.getToken(context.Background())
+	assert.Regexp(t, "FF23065.*access_token", err)
+
+	ot = &oauth2Transport{tokenURL: "http://localhost/token", base: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, fmt.Errorf("pop")
+	})}
+	_, err = ot.getToken(context.Background())
+	assert.Regexp(t, "FF23065.*pop", err)
+
+	ot = &oauth2Transport{tokenURL: "::wrong"}
+	_, err = ot.getToken(context.Background())
+	assert.Regexp(t, "FF23065", err)
+
+}
@@ -0,0 +1,190 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+)
+
+type rpcPriority int
+
+const (
+	rpcPriorityBulk     rpcPriority = iota // catch-up of event streams, which can generate a large volume of requests
+	rpcPriorityNormal                      // everything else
+	rpcPriorityCritical                    // transaction submissions and receipts for pending operations
+	rpcPriorityCount
+)
+
+// criticalRPCMethods are always critical, whichever context they are called from
+var criticalRPCMethods = map[string]bool{
+	"eth_sendTransaction":       true,
+	"eth_sendRawTransaction":    true,
+	"eth_getTransactionReceipt": true,
+}
+
+type rpcPriorityKey struct{}
+
+// withRPCPriority marks the JSON/RPC requests made with the context, such as the queries of a catch-up loop
+func withRPCPriority(ctx context.Context, p rpcPriority) context.Context {
+	return context.WithValue(ctx, rpcPriorityKey{}, p)
+}
+
+func rpcPriorityFor(ctx context.Context, method string) rpcPriority {
+	if criticalRPCMethods[method] {
+		return rpcPriorityCritical
+	}
+	if p, ok := ctx.Value(rpcPriorityKey{}).(rpcPriority); ok {
+		return p
+	}
+	return rpcPriorityNormal
+}
+
+// priorityGate wraps the JSON/RPC backend, to admit requests up to the concurrency limit in priority order.
+// When the limit is reached, a waiting submission or receipt fetch takes the next free slot ahead of any waiting
+// catch-up query, so a backfill of a new event stream cannot delay time critical requests.
+type priorityGate struct {
+	rpcbackend.Backend
+	limit    func() int
+	mux      sync.Mutex
+	inFlight int
+	waiting  [rpcPriorityCount][]chan struct{} // FIFO within each priority
+}
+
+type RPCPriorityStatus struct {
+	Limit    int            `json:"limit"`
+	InFlight int            `json:"inFlight"`
+	Queued   map[string]int `json:"queued"`
+}
+
+func newPriorityGate(backend rpcbackend.Backend, limit func() int) *priorityGate {
+	return &priorityGate{
+		Backend: backend,
+		limit:   limit,
+	}
+}
+
+func (pg *priorityGate) CallRPC(ctx context.Context, result interface{}, method string, params ...interface{}) *rpcbackend.RPCError {
+	if err := pg.acquire(ctx, rpcPriorityFor(ctx, method)); err != nil {
+		return &rpcbackend.RPCError{Code: int64(rpcbackend.RPCCodeInternalError), Message: err.Error()}
+	}
+	defer pg.release()
+	return pg.Backend.CallRPC(ctx, result, method, params...)
+}
+
+func (pg *priorityGate) SyncRequest(ctx context.Context, rpcReq *rpcbackend.RPCRequest) (*rpcbackend.RPCResponse, error) {
+	if err := pg.acquire(ctx, rpcPriorityFor(ctx, rpcReq.Method)); err != nil {
+		return rpcbackend.RPCErrorResponse(err, rpcReq.ID, rpcbackend.RPCCodeInternalError), err
+	}
+	defer pg.release()
+	return pg.Backend.SyncRequest(ctx, rpcReq)
+}
+
+func (pg *priorityGate) acquire(ctx context.Context, p rpcPriority) error {
+	pg.mux.Lock()
+	if pg.inFlight < pg.limit() && !pg.waitingAtLocked(p) {
+		pg.inFlight++
+		pg.mux.Unlock()
+		return nil
+	}
+	admitted := make(chan struct{})
+	pg.waiting[p] = append(pg.waiting[p], admitted)
+	pg.mux.Unlock()
+
+	select {
+	case <-admitted:
+		return nil
+	case <-ctx.Done():
+	}
+	pg.mux.Lock()
+	defer pg.mux.Unlock()
+	for i, w := range pg.waiting[p] {
+		if w == admitted {
+			pg.waiting[p] = append(pg.waiting[p][:i], pg.waiting[p][i+1:]...)
+			return i18n.NewError(ctx, i18n.MsgContextCanceled)
+		}
+	}
+	// We were admitted at the same time as being cancelled, so pass the slot on
+	pg.inFlight--
+	pg.admitLocked()
+	return i18n.NewError(ctx, i18n.MsgContextCanceled)
+}
+
+func (pg *priorityGate) release() {
+	pg.mux.Lock()
+	defer pg.mux.Unlock()
+	pg.inFlight--
+	pg.admitLocked()
+}
+
+// waitingAtLocked returns whether there is a request of the same or higher priority waiting ahead of us
+func (pg *priorityGate) waitingAtLocked(p rpcPriority) bool {
+	for ; p < rpcPriorityCount; p++ {
+		if len(pg.waiting[p]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// admitLocked hands the free slots to the waiting requests, highest priority first. The limit is checked on
+// each admission, as an adaptive concurrency limit changes as requests complete.
+func (pg *priorityGate) admitLocked() {
+	for p := rpcPriorityCount - 1; p >= 0; p-- {
+		for len(pg.waiting[p]) > 0 && pg.inFlight < pg.limit() {
+			close(pg.waiting[p][0])
+			pg.waiting[p] = pg.waiting[p][1:]
+			pg.inFlight++
+		}
+	}
+}
+
+func (pg *priorityGate) getStatus() *RPCPriorityStatus {
+	pg.mux.Lock()
+	defer pg.mux.Unlock()
+	return &RPCPriorityStatus{
+		Limit:    pg.limit(),
+		InFlight: pg.inFlight,
+		Queued: map[string]int{
+			"critical": len(pg.waiting[rpcPriorityCritical]),
+			"normal":   len(pg.waiting[rpcPriorityNormal]),
+			"bulk":     len(pg.waiting[rpcPriorityBulk]),
+		},
+	}
+}
+
+func (c *ethConnector) initRPCPriority(ctx context.Context, conf config.Section) {
+	if !conf.GetBool(RPCPriorityEnabled) {
+		return
+	}
+	maxConcurrentRequests := conf.GetInt(MaxConcurrentRequests)
+	limit := func() int { return maxConcurrentRequests }
+	switch {
+	case c.adaptiveConcurrency != nil:
+		limit = func() int { return c.adaptiveConcurrency.getStatus().Limit }
+	case maxConcurrentRequests <= 0:
+		log.L(ctx).Warnf("RPC priorities are disabled, as there is no limit on concurrent requests")
+		return
+	}
+	c.rpcPriority = newPriorityGate(c.backend, limit)
+	c.backend = c.rpcPriority
+}
@@ -0,0 +1,147 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRPCPriorityFor(t *testing.T) {
+
+	ctx := context.Background()
+	bulkCtx := withRPCPriority(ctx, rpcPriorityBulk)
+	assert.Equal(t, rpcPriorityNormal, rpcPriorityFor(ctx, "eth_getLogs"))
+	assert.Equal(t, rpcPriorityBulk, rpcPriorityFor(bulkCtx, "eth_getLogs"))
+	assert.Equal(t, rpcPriorityCritical, rpcPriorityFor(bulkCtx, "eth_getTransactionReceipt"))
+	assert.Equal(t, rpcPriorityCritical, rpcPriorityFor(ctx, "eth_sendRawTransaction"))
+
+}
+
+func TestRPCPriorityAdmitsHighestFirst(t *testing.T) {
+
+	pg := newPriorityGate(&rpcbackendmocks.Backend{}, func() int { return 1 })
+	ctx := context.Background()
+	assert.NoError(t, pg.acquire(ctx, rpcPriorityNormal))
+
+	admitted := make(chan rpcPriority, 3)
+	queue := func(p rpcPriority, queued int) {
+		go func() {
+			assert.NoError(t, pg.acquire(ctx, p))
+			admitted <- p
+		}()
+		assert.Eventually(t, func() bool {
+			status := pg.getStatus()
+			return status.Queued["bulk"]+status.Queued["normal"]+status.Queued["critical"] == queued
+		}, time.Second, time.Millisecond)
+	}
+	queue(rpcPriorityBulk, 1)
+	queue(rpcPriorityNormal, 2)
+	queue(rpcPriorityCritical, 3)
+	assert.Equal(t, &RPCPriorityStatus{
+		Limit:    1,
+		InFlight: 1,
+		Queued:   map[string]int{"critical": 1, "normal": 1, "bulk": 1},
+	}, pg.getStatus())
+
+	// A new request joins the back of the queue, rather than taking a slot ahead of those waiting
+	for _, expected := range []rpcPriority{rpcPriorityCritical, rpcPriorityNormal, rpcPriorityBulk} {
+		pg.release()
+		assert.Equal(t, expected, <-admitted)
+	}
+	pg.release()
+	assert.Equal(t, 0, pg.getStatus().InFlight)
+
+}
+
+func TestRPCPriorityCancelWaiting(t *testing.T) {
+
+	pg := newPriorityGate(&rpcbackendmocks.Backend{}, func() int { return 1 })
+	assert.NoError(t, pg.acquire(context.Background(), rpcPriorityCritical))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := pg.acquire(ctx, rpcPriorityNormal)
+	assert.Regexp(t, "FF00154", err)
+	assert.Equal(t, 0, pg.getStatus().Queued["normal"])
+
+	pg.release()
+	err = pg.acquire(context.Background(), rpcPriorityNormal)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pg.getStatus().InFlight)
+	pg.release()
+
+}
+
+func TestRPCPriorityBackend(t *testing.T) {
+
+	mRPC := &rpcbackendmocks.Backend{}
+	pg := newPriorityGate(mRPC, func() int { return 1 })
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_chainId").Return(nil)
+	mRPC.On("SyncRequest", mock.Anything, mock.Anything).Return(&rpcbackend.RPCResponse{}, nil)
+
+	ctx := context.Background()
+	var chainID string
+	assert.Nil(t, pg.CallRPC(ctx, &chainID, "eth_chainId"))
+	_, err := pg.SyncRequest(ctx, &rpcbackend.RPCRequest{Method: "eth_chainId"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pg.getStatus().InFlight)
+
+	// Requests fail if cancelled while waiting for a slot
+	assert.NoError(t, pg.acquire(ctx, rpcPriorityNormal))
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	rpcErr := pg.CallRPC(cancelledCtx, &chainID, "eth_chainId")
+	assert.Regexp(t, "FF00154", rpcErr.Message)
+	_, err = pg.SyncRequest(cancelledCtx, &rpcbackend.RPCRequest{Method: "eth_chainId"})
+	assert.Regexp(t, "FF00154", err)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestRPCPriorityConnectorInit(t *testing.T) {
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(RPCPriorityEnabled, true)
+		conf.Set(MaxConcurrentRequests, 10)
+	})
+	done()
+	assert.Equal(t, 10, c.rpcPriority.getStatus().Limit)
+
+	_, c, _, done = newTestConnector(t, func(conf config.Section) {
+		conf.Set(RPCPriorityEnabled, true)
+		conf.Set(AdaptiveConcurrencyEnabled, true)
+		conf.Set(AdaptiveConcurrencyInitial, 5)
+	})
+	done()
+	assert.Equal(t, 5, c.rpcPriority.getStatus().Limit)
+
+	_, c, _, done = newTestConnector(t, func(conf config.Section) {
+		conf.Set(RPCPriorityEnabled, true)
+		conf.Set(MaxConcurrentRequests, 0)
+	})
+	done()
+	assert.Nil(t, c.rpcPriority)
+
+}
@@ -0,0 +1,115 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-common/pkg/wsclient"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// proxyFunc is the signature of http.Transport.Proxy
+type proxyFunc func(u *url.URL) (*url.URL, error)
+
+// buildRPCProxy returns the function to select the proxy for a target URL, from the proxy.url
+// configuration shared with the HTTP client, or nil if no proxy is configured.
+// Proxy servers can be an HTTP CONNECT proxy (http/https scheme) or a SOCKS5 proxy (socks5 scheme),
+// with credentials taken from the URL or the proxy.username/proxy.password settings.
+// Hosts matching the proxy.noProxy list are connected to directly, using the same rules as NO_PROXY.
+func buildRPCProxy(ctx context.Context, conf config.Section) (proxyFunc, error) {
+	proxyURL := conf.GetString(ffresty.HTTPConfigProxyURL)
+	if proxyURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidProxyURL, proxyURL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidProxyURL, proxyURL)
+	}
+	if username := conf.GetString(ProxyUsername); username != "" {
+		u.User = url.UserPassword(username, conf.GetString(ProxyPassword))
+	}
+
+	log.L(ctx).Infof("Outbound JSON/RPC connections will use %s proxy %s (noProxy=%v)", u.Scheme, u.Host, conf.GetStringSlice(ProxyNoProxy))
+	pc := &httpproxy.Config{
+		HTTPProxy:  u.String(),
+		HTTPSProxy: u.String(),
+		NoProxy:    strings.Join(conf.GetStringSlice(ProxyNoProxy), ","),
+	}
+	return pc.ProxyFunc(), nil
+}
+
+// configureRPCProxy replaces the proxy selection on the transport of the HTTP JSON/RPC client, which
+// must be called before any wrapping of the transport (such as authentication)
+func configureRPCProxy(ctx context.Context, conf config.Section, client *resty.Client) error {
+	pf, err := buildRPCProxy(ctx, conf)
+	if err != nil || pf == nil {
+		return err
+	}
+	if transport, ok := client.GetClient().Transport.(*http.Transport); ok {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return pf(req.URL)
+		}
+	}
+	return nil
+}
+
+// checkWebSocketProxy warns if the WebSocket URL would need to go via the configured proxy. WebSocket connections
+// are always made directly, as the Dialer of the firefly-common wsclient cannot be configured with a proxy.
+func checkWebSocketProxy(ctx context.Context, conf config.Section, wsConf *wsclient.WSConfig) error {
+	pf, err := buildRPCProxy(ctx, conf)
+	if err != nil || pf == nil {
+		return err
+	}
+	wsURL := wsConf.WebSocketURL
+	if wsURL == "" {
+		wsURL = wsConf.HTTPURL
+	}
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return err
+	}
+	// The proxy rules are keyed on the HTTP scheme the WebSocket upgrade runs over
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	proxy, err := pf(u)
+	if err != nil {
+		return err
+	}
+	if proxy != nil {
+		log.L(ctx).Warn(i18n.NewError(ctx, msgs.MsgWebSocketProxyUnsupported, u.Host))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftls"
+)
+
+// initEndpointTLSConfig registers the tls section of an additional JSON/RPC endpoint, which has
+// the same settings as the tls section of the node, including the SNI override
+func initEndpointTLSConfig(tlsConf config.Section) {
+	fftls.InitTLSConfig(tlsConf)
+	tlsConf.AddKnownKey(TLSServerName)
+}
+
+// endpointTLSConfig returns the TLS client configuration for an additional JSON/RPC endpoint.
+// If TLS is enabled in the tls section of the endpoint, it has its own CA bundle and client
+// certificate/key (for mTLS). Otherwise it inherits the TLS configuration of the node, except
+// for the SNI override which is specific to each endpoint.
+func endpointTLSConfig(ctx context.Context, tlsConf config.Section, inherited *tls.Config) (*tls.Config, error) {
+	tlsConfig := inherited
+	if tlsConf.GetBool(fftls.HTTPConfTLSEnabled) {
+		var err error
+		if tlsConfig, err = fftls.ConstructTLSConfig(ctx, tlsConf, fftls.ClientType); err != nil {
+			return nil, err
+		}
+	}
+	return withServerName(tlsConfig, tlsConf.GetString(TLSServerName)), nil
+}
+
+// withServerName returns a TLS client configuration that sends the server name in the SNI extension,
+// and verifies the certificate of the server against it (rather than the host in the URL).
+// The supplied configuration is cloned rather than modified, as it might be shared.
+func withServerName(tlsConfig *tls.Config, serverName string) *tls.Config {
+	switch {
+	case tlsConfig == nil && serverName == "":
+		return nil
+	case tlsConfig == nil:
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	case tlsConfig.ServerName == serverName:
+		return tlsConfig
+	default:
+		tlsConfig = tlsConfig.Clone()
+	}
+	tlsConfig.ServerName = serverName
+	return tlsConfig
+}
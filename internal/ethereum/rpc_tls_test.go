@@ -0,0 +1,201 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftls"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM string
+	keyPEM  string
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, usage x509.ExtKeyUsage) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+	}
+	signerCert, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		template.DNSNames = []string{cn}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		keyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}
+}
+
+// newTestMTLSServer starts a JSON/RPC server that requires a client certificate signed by the CA,
+// with a server certificate for a host name that does not match the 127.0.0.1 URL of the server
+func newTestMTLSServer(t *testing.T, ca *testCert, serverName string) *httptest.Server {
+	serverCert := newTestCert(t, serverName, ca, x509.ExtKeyUsageServerAuth)
+	keyPair, err := tls.X509KeyPair([]byte(serverCert.certPEM), []byte(serverCert.keyPEM))
+	assert.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, serverName, r.TLS.ServerName)
+		assert.Equal(t, "client1", r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x7a69"}`))
+	}))
+	server.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{keyPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	return server
+}
+
+func setTestEndpointTLS(tlsConf config.Section, ca, client *testCert, serverName string) {
+	tlsConf.Set(fftls.HTTPConfTLSEnabled, true)
+	tlsConf.Set(fftls.HTTPConfTLSCA, ca.certPEM)
+	tlsConf.Set(fftls.HTTPConfTLSCert, client.certPEM)
+	tlsConf.Set(fftls.HTTPConfTLSKey, client.keyPEM)
+	tlsConf.Set(TLSServerName, serverName)
+}
+
+func TestSignerRouteMTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, 0)
+	client := newTestCert(t, "client1", ca, x509.ExtKeyUsageClientAuth)
+	server := newTestMTLSServer(t, ca, "signer.example.com")
+	defer server.Close()
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		signer0 := setTestSigner(conf, 0, server.URL)
+		signer0.Set(SignerAddresses, []string{"0x20355f3e852d4b6a9944ada8d5399ddd3409a431"})
+		setTestEndpointTLS(signer0.SubSection(SignerTLS), ca, client, "signer.example.com")
+	})
+	defer done()
+
+	var chainID ethtypes.HexInteger
+	rpcErr := c.signerRoutes[0].backend.CallRPC(ctx, &chainID, "eth_chainId")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(31337), chainID.BigInt().Int64())
+}
+
+func TestReadQuorumMTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, 0)
+	client := newTestCert(t, "client1", ca, x509.ExtKeyUsageClientAuth)
+	server := newTestMTLSServer(t, ca, "secondary.example.com")
+	defer server.Close()
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ReadQuorumURL, server.URL)
+		setTestEndpointTLS(conf.SubSection(ReadQuorumTLS), ca, client, "secondary.example.com")
+	})
+	defer done()
+
+	var chainID ethtypes.HexInteger
+	rpcErr := c.readQuorum.secondary.CallRPC(ctx, &chainID, "eth_chainId")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(31337), chainID.BigInt().Int64())
+}
+
+func TestEndpointTLSBadConfig(t *testing.T) {
+	for _, tlsConf := range []func(conf config.Section) config.Section{
+		func(conf config.Section) config.Section {
+			return setTestSigner(conf, 0, "http://localhost:0").SubSection(SignerTLS)
+		},
+		func(conf config.Section) config.Section {
+			conf.Set(ReadQuorumURL, "http://localhost:0")
+			return conf.SubSection(ReadQuorumTLS)
+		},
+		func(conf config.Section) config.Section {
+			conf.Set(SubmissionRelayURL, "http://localhost:0")
+			return conf.SubSection(SubmissionRelayTLS)
+		},
+	} {
+		conf := newTestAuthConf(t, "http://localhost:8545")
+		ts := tlsConf(conf)
+		ts.Set(fftls.HTTPConfTLSEnabled, true)
+		ts.Set(fftls.HTTPConfTLSCA, "not a PEM")
+		_, err := NewEthereumConnector(context.Background(), conf)
+		assert.Regexp(t, "FF00152", err)
+	}
+}
+
+func TestEndpointTLSInheritsNode(t *testing.T) {
+	conf := config.RootSection("unittest")
+	initEndpointTLSConfig(conf)
+	inherited := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: "node.example.com"}
+
+	// The node configuration is used, without its SNI override
+	tlsConfig, err := endpointTLSConfig(context.Background(), conf, inherited)
+	assert.NoError(t, err)
+	assert.Empty(t, tlsConfig.ServerName)
+	assert.Equal(t, "node.example.com", inherited.ServerName)
+
+	conf.Set(TLSServerName, "signer.example.com")
+	tlsConfig, err = endpointTLSConfig(context.Background(), conf, inherited)
+	assert.NoError(t, err)
+	assert.Equal(t, "signer.example.com", tlsConfig.ServerName)
+}
+
+func TestWithServerName(t *testing.T) {
+	assert.Nil(t, withServerName(nil, ""))
+	assert.Equal(t, "node.example.com", withServerName(nil, "node.example.com").ServerName)
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	assert.Same(t, tlsConfig, withServerName(tlsConfig, ""))
+	assert.Equal(t, "node.example.com", withServerName(tlsConfig, "node.example.com").ServerName)
+	assert.Empty(t, tlsConfig.ServerName)
+}
@@ -0,0 +1,85 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// https://www.w3.org/TR/trace-context/#traceparent-header
+const traceparentHeader = "traceparent"
+
+var traceparentRegex = regexp.MustCompile(`^00-([0-9a-f]{32})-[0-9a-f]{16}-([0-9a-f]{2})$`)
+
+// configureRPCTracing adds a W3C traceparent header to every request of the HTTP JSON/RPC client,
+// so that the logs of node providers can be correlated with the FireFly operations that caused them.
+// Static headers, such as API keys, are configured with the headers setting of the HTTP client.
+func configureRPCTracing(ctx context.Context, conf config.Section, client *resty.Client) {
+	if !conf.GetBool(TracingEnabled) {
+		return
+	}
+	log.L(ctx).Infof("JSON/RPC requests will include a %s header", traceparentHeader)
+	client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		tp := buildTraceparent(req.Context(), req.Header.Get(traceparentHeader))
+		log.L(req.Context()).Tracef("%s: %s", traceparentHeader, tp)
+		req.Header.Set(traceparentHeader, tp)
+		return nil
+	})
+}
+
+// buildTraceparent returns the traceparent for a new span within the trace of the inbound request.
+// The trace is taken from a traceparent received with the inbound request if there is one, otherwise
+// derived from the FireFly request ID so that all the calls for a request share a trace ID.
+// When spans are exported, the span recorded for the request is used instead.
+func buildTraceparent(ctx context.Context, existing string) string {
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		sc := span.SpanContext()
+		return fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+	}
+	if existing == "" {
+		if headers, ok := ctx.Value(ffapi.CtxHeadersKey{}).(http.Header); ok {
+			existing = headers.Get(traceparentHeader)
+		}
+	}
+	spanID := make([]byte, 8)
+	_, _ = rand.Read(spanID)
+
+	if m := traceparentRegex.FindStringSubmatch(existing); m != nil && m[1] != "00000000000000000000000000000000" {
+		return fmt.Sprintf("00-%s-%s-%s", m[1], hex.EncodeToString(spanID), m[2])
+	}
+	var traceID []byte
+	if requestID, ok := ctx.Value(ffapi.CtxFFRequestIDKey{}).(string); ok && requestID != "" {
+		hash := sha256.Sum256([]byte(requestID))
+		traceID = hash[0:16]
+	} else {
+		traceID = make([]byte, 16)
+		_, _ = rand.Read(traceID)
+	}
+	return fmt.Sprintf("00-%s-%s-00", hex.EncodeToString(traceID), hex.EncodeToString(spanID))
+}
@@ -0,0 +1,101 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/ffapi"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceparentAndStaticHeaders(t *testing.T) {
+
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key1", r.Header.Get("X-Api-Key"))
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x539"}`))
+	}))
+	defer server.Close()
+
+	conf := newTestAuthConf(t, server.URL)
+	conf.Set(TracingEnabled, true)
+	conf.Set(ffresty.HTTPConfigHeaders, map[string]interface{}{"X-Api-Key": "key1"})
+	cc, err := NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+	c := cc.(*ethConnector)
+
+	ctx := context.WithValue(context.Background(), ffapi.CtxFFRequestIDKey{}, "abcd1234")
+	var chainID string
+	for i := 0; i < 2; i++ {
+		rpcErr := c.backend.CallRPC(ctx, &chainID, "eth_chainId")
+		assert.Nil(t, rpcErr)
+	}
+
+	// Calls for the same request share a trace, as separate spans
+	assert.Len(t, traceparents, 2)
+	assert.Regexp(t, traceparentRegex, traceparents[0])
+	assert.Equal(t, traceparents[0][0:36], traceparents[1][0:36])
+	assert.NotEqual(t, traceparents[0], traceparents[1])
+
+}
+
+func TestBuildTraceparent(t *testing.T) {
+
+	inbound := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	// Continues an inbound trace, with a new span ID
+	tp := buildTraceparent(context.Background(), inbound)
+	assert.Regexp(t, "^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01$", tp)
+	assert.NotEqual(t, inbound, tp)
+
+	// Including from the inbound headers, when they are not passed through
+	ctx := context.WithValue(context.Background(), ffapi.CtxHeadersKey{}, http.Header{"Traceparent": []string{inbound}})
+	tp = buildTraceparent(ctx, "")
+	assert.Regexp(t, "^00-4bf92f3577b34da6a3ce929d0e0e4736-", tp)
+
+	// Otherwise a new random trace, ignoring invalid values
+	tp1 := buildTraceparent(context.Background(), "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	tp2 := buildTraceparent(context.Background(), "wrong")
+	assert.Regexp(t, traceparentRegex, tp1)
+	assert.Regexp(t, traceparentRegex, tp2)
+	assert.NotEqual(t, tp1[0:36], tp2[0:36])
+
+}
+
+func TestTracingDisabled(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("traceparent"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x539"}`))
+	}))
+	defer server.Close()
+
+	cc, err := NewEthereumConnector(context.Background(), newTestAuthConf(t, server.URL))
+	assert.NoError(t, err)
+	var chainID string
+	rpcErr := cc.(*ethConnector).backend.CallRPC(context.Background(), &chainID, "eth_chainId")
+	assert.Nil(t, rpcErr)
+
+}
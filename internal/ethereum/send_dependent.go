@@ -0,0 +1,94 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// DependentTransactionSendRequest is a TransactionSend that is held until the operations it depends on are mined
+type DependentTransactionSendRequest struct {
+	ffcapi.TransactionSendRequest
+	ID            string   `json:"id,omitempty"`            // operation ID to record the submission under, for later submissions to depend on
+	DependsOn     []string `json:"dependsOn,omitempty"`     // operation IDs of earlier submissions through this connector
+	Confirmations *int64   `json:"confirmations,omitempty"` // blocks required on top of each dependency - overrides the configured default
+}
+
+// DependentTransactionSend waits for each of the operations the submission depends on to be mined successfully,
+// to the required number of confirmations, before sending it. This allows multi-step contract workflows to be
+// submitted up front, without the caller having to sequence them on receipts.
+//
+// Operation IDs are mapped to the latest transaction hash submitted under them, so a re-submission with a new
+// gas price replaces the transaction that dependents wait for.
+func (c *ethConnector) DependentTransactionSend(ctx context.Context, req *DependentTransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	confirmations := c.dependencyConfirmations
+	if req.Confirmations != nil {
+		confirmations = *req.Confirmations
+	}
+	for _, dep := range req.DependsOn {
+		if reason, err := c.waitForDependency(ctx, dep, confirmations); err != nil {
+			return nil, reason, err
+		}
+	}
+
+	res, reason, err := c.TransactionSend(ctx, &req.TransactionSendRequest)
+	if err != nil {
+		return nil, reason, err
+	}
+	if req.ID != "" {
+		c.submittedOps.Add(req.ID, res.TransactionHash)
+	}
+	return res, "", nil
+}
+
+// waitForDependency polls for the receipt of the latest transaction submitted for the operation, with the standard
+// retry backoff, until it is mined with enough blocks on top or the dependency timeout is reached
+func (c *ethConnector) waitForDependency(ctx context.Context, opID string, confirmations int64) (ffcapi.ErrorReason, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.dependencyTimeout)
+	defer cancel()
+	var txHash string
+	for attempt := 0; ; attempt++ {
+		if c.doFailureDelay(ctx, attempt) {
+			return ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgDependencyTimeout, opID, txHash)
+		}
+		cached, ok := c.submittedOps.Get(opID)
+		if !ok {
+			return ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgUnknownDependency, opID)
+		}
+		txHash = cached.(string)
+		receipt, _, err := c.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: txHash})
+		if err != nil {
+			log.L(ctx).Debugf("Waiting for dependency '%s' to be mined (tx=%s): %s", opID, txHash, err)
+			continue
+		}
+		if !receipt.Success {
+			return ffcapi.ErrorReasonTransactionReverted, i18n.NewError(ctx, msgs.MsgDependencyReverted, opID, txHash)
+		}
+		if confirmations <= 0 {
+			return "", nil
+		}
+		if head, ok := c.blockListener.getHighestBlock(ctx); ok && head-receipt.BlockNumber.Int64() >= confirmations {
+			return "", nil
+		}
+		log.L(ctx).Debugf("Waiting for dependency '%s' in block %s to reach %d confirmations (tx=%s)", opID, receipt.BlockNumber, confirmations, txHash)
+	}
+}
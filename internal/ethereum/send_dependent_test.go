@@ -0,0 +1,167 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const dependencyTXHash = "0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc"
+
+func mockDependencySend(mRPC *rpcbackendmocks.Backend) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(dependencyTXHash)
+		}).
+		Return(nil)
+}
+
+func mockDependencyReceipt(mRPC *rpcbackendmocks.Backend, status string) *mock.Call {
+	return mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", dependencyTXHash).
+		Run(func(args mock.Arguments) {
+			err := json.Unmarshal([]byte(fmt.Sprintf(`{
+				"blockHash": "0x6197ef1a58a2a592bb447efb651f0db7945de21aa8048801b250bd7b7431f9b6",
+				"blockNumber": "0x7b9",
+				"status": "%s",
+				"transactionHash": "%s",
+				"transactionIndex": "0x0"
+			}`, status, dependencyTXHash)), args[1])
+			if err != nil {
+				panic(err)
+			}
+		}).
+		Return(nil)
+}
+
+func testDependentSendRequest(t *testing.T, id string, gasPrice string, dependsOn ...string) *DependentTransactionSendRequest {
+	req := &DependentTransactionSendRequest{
+		TransactionSendRequest: *testSendRequest(t),
+		ID:                     id,
+		DependsOn:              dependsOn,
+	}
+	req.GasPrice = fftypes.JSONAnyPtr(gasPrice)
+	return req
+}
+
+func TestDependentTransactionSendWaitsForDependency(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockDependencySend(mRPC)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", dependencyTXHash).
+		Return(&rpcbackend.RPCError{Message: "not mined yet"}).Once()
+	mockDependencyReceipt(mRPC, "0x1")
+
+	res, _, err := c.DependentTransactionSend(ctx, testDependentSendRequest(t, "op1", `"1000"`))
+	assert.NoError(t, err)
+	assert.Equal(t, dependencyTXHash, res.TransactionHash)
+
+	res, reason, err := c.DependentTransactionSend(ctx, testDependentSendRequest(t, "op2", `"1001"`, "op1"))
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, dependencyTXHash, res.TransactionHash)
+	mRPC.AssertNumberOfCalls(t, "CallRPC", 4)
+
+	cached, ok := c.submittedOps.Get("op2")
+	assert.True(t, ok)
+	assert.Equal(t, dependencyTXHash, cached)
+
+}
+
+func TestDependentTransactionSendConfirmations(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionDependencyConfs, 2)
+		conf.Set(SubmissionDependencyTimeout, "50ms")
+	})
+	defer done()
+
+	mockDependencySend(mRPC)
+	mockDependencyReceipt(mRPC, "0x1")
+	mockRelayChainHead(mRPC, 0x7b9+1)
+	c.submittedOps.Add("op1", dependencyTXHash)
+
+	_, reason, err := c.DependentTransactionSend(ctx, testDependentSendRequest(t, "op2", `"1000"`, "op1"))
+	assert.Regexp(t, "FF23174.*op1.*"+dependencyTXHash, err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	// The request can wait for fewer confirmations than the default
+	one := int64(1)
+	req := testDependentSendRequest(t, "op2", `"1000"`, "op1")
+	req.Confirmations = &one
+	_, _, err = c.DependentTransactionSend(ctx, req)
+	assert.NoError(t, err)
+
+	c.blockListener.mux.Lock()
+	c.blockListener.highestBlock = 0x7b9 + 2
+	c.blockListener.mux.Unlock()
+	_, _, err = c.DependentTransactionSend(ctx, testDependentSendRequest(t, "op3", `"1001"`, "op1"))
+	assert.NoError(t, err)
+
+}
+
+func TestDependentTransactionSendUnknownDependency(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.DependentTransactionSend(ctx, testDependentSendRequest(t, "op2", `"1000"`, "op1"))
+	assert.Regexp(t, "FF23172.*op1", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+}
+
+func TestDependentTransactionSendDependencyReverted(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockDependencyReceipt(mRPC, "0x0")
+	c.submittedOps.Add("op1", dependencyTXHash)
+
+	_, reason, err := c.DependentTransactionSend(ctx, testDependentSendRequest(t, "op2", `"1000"`, "op1"))
+	assert.Regexp(t, "FF23173.*op1", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, reason)
+
+}
+
+func TestDependentTransactionSendFailNotRecorded(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"})
+
+	_, _, err := c.DependentTransactionSend(ctx, testDependentSendRequest(t, "op1", `"1000"`))
+	assert.Regexp(t, "pop", err)
+	_, ok := c.submittedOps.Get("op1")
+	assert.False(t, ok)
+
+}
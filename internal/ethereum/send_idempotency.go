@@ -0,0 +1,68 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type sentTransaction struct {
+	txHash string
+	sent   time.Time
+}
+
+// sendIdempotencyKey identifies a prepared transaction submission. The FFCAPI send request does not carry the
+// ID of the operation, so the key is a hash of the whole request - a re-submission of the same operation has
+// the same nonce and transaction data, while a deliberate re-submission with a bumped gas price does not match.
+func sendIdempotencyKey(req *ffcapi.TransactionSendRequest) string {
+	b, _ := json.Marshal(req)
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:])
+}
+
+// previouslySent returns the hash of an identical transaction submitted within the idempotency window, so that
+// a repeated request is not signed and sent again - which for signers that assign their own nonce would
+// double-spend gas on a second copy of the transaction
+func (c *ethConnector) previouslySent(ctx context.Context, key string) (string, bool) {
+	if c.sentTxCache == nil {
+		return "", false
+	}
+	cached, ok := c.sentTxCache.Get(key)
+	if !ok {
+		return "", false
+	}
+	st := cached.(*sentTransaction)
+	if time.Since(st.sent) > c.sendIdempotencyWindow {
+		c.sentTxCache.Remove(key)
+		return "", false
+	}
+	log.L(ctx).Infof("Returning transaction hash %s of identical transaction sent at %s", st.txHash, st.sent)
+	return st.txHash, true
+}
+
+func (c *ethConnector) recordSent(key, txHash string) {
+	if c.sentTxCache != nil {
+		c.sentTxCache.Add(key, &sentTransaction{txHash: txHash, sent: time.Now()})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testSendRequest(t *testing.T) *ffcapi.TransactionSendRequest {
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &req)
+	assert.NoError(t, err)
+	return &req
+}
+
+func TestSendTransactionIdempotent(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionIdempotencyWindow, "1m")
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc")
+		}).
+		Return(nil).Twice()
+
+	res, _, err := c.TransactionSend(ctx, testSendRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc", res.TransactionHash)
+
+	// The same prepared transaction is not sent again
+	res, _, err = c.TransactionSend(ctx, testSendRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc", res.TransactionHash)
+	mRPC.AssertNumberOfCalls(t, "CallRPC", 1)
+
+	// A re-submission with a different gas price is sent
+	req := testSendRequest(t)
+	req.GasPrice = fftypes.JSONAnyPtr(`"12346"`)
+	_, _, err = c.TransactionSend(ctx, req)
+	assert.NoError(t, err)
+	mRPC.AssertNumberOfCalls(t, "CallRPC", 2)
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestSendTransactionIdempotencyWindowExpired(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionIdempotencyWindow, "1m")
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc")
+		}).
+		Return(nil).Twice()
+
+	req := testSendRequest(t)
+	_, _, err := c.TransactionSend(ctx, req)
+	assert.NoError(t, err)
+
+	cached, ok := c.sentTxCache.Get(sendIdempotencyKey(req))
+	assert.True(t, ok)
+	cached.(*sentTransaction).sent = time.Now().Add(-c.sendIdempotencyWindow - time.Second)
+
+	_, _, err = c.TransactionSend(ctx, req)
+	assert.NoError(t, err)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestSendTransactionIdempotencyFailureNotCached(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionIdempotencyWindow, "1m")
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"}).Twice()
+
+	_, _, err := c.TransactionSend(ctx, testSendRequest(t))
+	assert.Regexp(t, "pop", err)
+	_, _, err = c.TransactionSend(ctx, testSendRequest(t))
+	assert.Regexp(t, "pop", err)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestSendTransactionIdempotencyDisabled(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	assert.Nil(t, c.sentTxCache)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc")
+		}).
+		Return(nil).Twice()
+
+	_, _, err := c.TransactionSend(ctx, testSendRequest(t))
+	assert.NoError(t, err)
+	_, _, err = c.TransactionSend(ctx, testSendRequest(t))
+	assert.NoError(t, err)
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestSendTransactionIdempotencyBadCacheSize(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(SubmissionIdempotencyWindow, "1m")
+	conf.Set(SubmissionIdempotencySize, -1)
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23185.*submission.idempotency.cacheSize", err)
+
+}
@@ -0,0 +1,282 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rlp"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-signer/pkg/secp256k1"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"golang.org/x/crypto/sha3"
+)
+
+type TransactionSendRawRequest struct {
+	TransactionData string            `json:"transactionData"` // the hex encoded signed transaction
+	From            string            `json:"from,omitempty"`  // if set, the transaction must be signed by this address
+	Nonce           *fftypes.FFBigInt `json:"nonce,omitempty"` // if set, the transaction must have this nonce
+}
+
+type TransactionSendRawResponse struct {
+	TransactionHash string          `json:"transactionHash"`
+	Transaction     *RawTransaction `json:"transaction"`
+	AlreadyKnown    bool            `json:"alreadyKnown,omitempty"` // the node already had this transaction, from an earlier submission
+}
+
+// RawTransaction is the decoded form of a signed transaction
+type RawTransaction struct {
+	Type                 int                       `json:"type"`
+	ChainID              *fftypes.FFBigInt         `json:"chainId,omitempty"` // omitted for legacy transactions without EIP-155 replay protection
+	Nonce                *fftypes.FFBigInt         `json:"nonce"`
+	GasPrice             *fftypes.FFBigInt         `json:"gasPrice,omitempty"`
+	MaxPriorityFeePerGas *fftypes.FFBigInt         `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerGas         *fftypes.FFBigInt         `json:"maxFeePerGas,omitempty"`
+	Gas                  *fftypes.FFBigInt         `json:"gas"`
+	To                   *ethtypes.Address0xHex    `json:"to,omitempty"` // omitted for contract deployment
+	Value                *fftypes.FFBigInt         `json:"value"`
+	Data                 ethtypes.HexBytes0xPrefix `json:"data"`
+	From                 *ethtypes.Address0xHex    `json:"from"` // recovered from the signature
+	Hash                 ethtypes.HexBytes0xPrefix `json:"hash"`
+}
+
+// rawTxLayout describes the positions of the fields in the RLP list of each transaction type
+type rawTxLayout struct {
+	fields                                                int
+	chainID, nonce, gasPrice, maxPriorityFee, maxFee, gas int
+	to, value, data, accessList                           int
+	signatureStart                                        int
+}
+
+var rawTxLayouts = map[byte]*rawTxLayout{
+	// rlp([nonce, gasPrice, gas, to, value, data, v, r, s])
+	ethsigner.TransactionTypeLegacy: {fields: 9, chainID: -1, nonce: 0, gasPrice: 1, maxPriorityFee: -1, maxFee: -1, gas: 2, to: 3, value: 4, data: 5, accessList: -1, signatureStart: 6},
+	// 0x01 || rlp([chainId, nonce, gasPrice, gas, to, value, data, accessList, yParity, r, s])
+	ethsigner.TransactionType2930: {fields: 11, chainID: 0, nonce: 1, gasPrice: 2, maxPriorityFee: -1, maxFee: -1, gas: 3, to: 4, value: 5, data: 6, accessList: 7, signatureStart: 8},
+	// 0x02 || rlp([chainId, nonce, maxPriorityFeePerGas, maxFeePerGas, gas, to, value, data, accessList, yParity, r, s])
+	ethsigner.TransactionType1559: {fields: 12, chainID: 0, nonce: 1, gasPrice: -1, maxPriorityFee: 2, maxFee: 3, gas: 4, to: 5, value: 6, data: 7, accessList: 8, signatureStart: 9},
+}
+
+// TransactionSendRaw submits a transaction signed outside of the connector (by a HSM for example),
+// after decoding it and validating it against the chain - so problems are reported before submission
+func (c *ethConnector) TransactionSendRaw(ctx context.Context, req *TransactionSendRawRequest) (*TransactionSendRawResponse, ffcapi.ErrorReason, error) {
+	raw, err := ethtypes.NewHexBytes0xPrefix(req.TransactionData)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, err)
+	}
+	tx, err := DecodeRawTransaction(ctx, raw)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	if reason, err := c.validateRawTransaction(ctx, req, tx); err != nil {
+		return nil, reason, err
+	}
+	if reason, err := c.checkTransactionSize(ctx, tx.Data, tx.To == nil, tx.Gas.Int()); err != nil {
+		return nil, reason, err
+	}
+	done, reason, err := c.beginWork(ctx)
+	if err != nil {
+		return nil, reason, err
+	}
+	defer done()
+	if reason, err := c.checkSubmissionHealth(ctx); err != nil {
+		return nil, reason, err
+	}
+	hookTx := signedHookTransaction(tx)
+	if reason, err := c.runPreSubmitHook(ctx, hookTx, nil); err != nil {
+		return nil, reason, err
+	}
+
+	var txHash ethtypes.HexBytes0xPrefix
+	rpcError := c.backend.CallRPC(ctx, &txHash, "eth_sendRawTransaction", raw)
+	if rpcError == nil && len(txHash) != 32 {
+		rpcError = &rpcbackend.RPCError{Message: i18n.NewError(ctx, msgs.MsgInvalidTXHashReturned, len(txHash)).Error()}
+	}
+	if rpcError != nil {
+		reason := c.mapRPCError(sendRPCMethods, rpcError)
+		if reason != ffcapi.ErrorKnownTransaction {
+			return nil, reason, rpcError.Error()
+		}
+		log.L(ctx).Infof("Transaction %s is already known to the node: %s", tx.Hash, rpcError.Message)
+		return &TransactionSendRawResponse{
+			TransactionHash: tx.Hash.String(),
+			Transaction:     tx,
+			AlreadyKnown:    true,
+		}, "", nil
+	}
+	c.runPostSubmitHook(ctx, hookTx, txHash.String())
+	return &TransactionSendRawResponse{
+		TransactionHash: txHash.String(),
+		Transaction:     tx,
+	}, "", nil
+}
+
+func (c *ethConnector) validateRawTransaction(ctx context.Context, req *TransactionSendRawRequest, tx *RawTransaction) (ffcapi.ErrorReason, error) {
+	if req.From != "" && !strings.EqualFold(req.From, tx.From.String()) {
+		return ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgRawTxFromMismatch, tx.From, req.From)
+	}
+	if req.Nonce != nil && req.Nonce.Int().Cmp(tx.Nonce.Int()) != 0 {
+		return ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgRawTxNonceMismatch, tx.Nonce, req.Nonce)
+	}
+
+	// Fee caps
+	if tx.MaxFeePerGas != nil && tx.MaxPriorityFeePerGas.Int().Cmp(tx.MaxFeePerGas.Int()) > 0 {
+		return ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgRawTxPriorityFeeTooHigh, tx.MaxPriorityFeePerGas, tx.MaxFeePerGas)
+	}
+	if maxFeePerGas := c.tuned().rawTxMaxFeePerGas; maxFeePerGas != nil {
+		fee := tx.GasPrice
+		if tx.MaxFeePerGas != nil {
+			fee = tx.MaxFeePerGas
+		}
+		if fee.Int().Cmp(maxFeePerGas) > 0 {
+			return ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgRawTxFeeCapExceeded, fee, maxFeePerGas)
+		}
+	}
+
+	// Chain ID - which is not included in legacy transactions that do not have EIP-155 replay protection
+	if tx.ChainID != nil {
+		var chainID ethtypes.HexInteger
+		if rpcErr := c.backend.CallRPC(ctx, &chainID, "eth_chainId"); rpcErr != nil {
+			return "", rpcErr.Error()
+		}
+		if chainID.BigInt().Cmp(tx.ChainID.Int()) != 0 {
+			return ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgRawTxChainIDMismatch, tx.ChainID, chainID.BigInt())
+		}
+	}
+
+	// Nonce sanity - it cannot be lower than the count of mined transactions from the signer
+	var txnCount ethtypes.HexInteger
+	if rpcErr := c.backend.CallRPC(ctx, &txnCount, "eth_getTransactionCount", tx.From, "latest"); rpcErr != nil {
+		return "", rpcErr.Error()
+	}
+	if tx.Nonce.Int().Cmp(txnCount.BigInt()) < 0 {
+		return ffcapi.ErrorReasonNonceTooLow, i18n.NewError(ctx, msgs.MsgRawTxNonceTooLow, tx.Nonce, txnCount.BigInt(), tx.From)
+	}
+
+	log.L(ctx).Debugf("Validated raw transaction %s from=%s nonce=%s", tx.Hash, tx.From, tx.Nonce)
+	return "", nil
+}
+
+// DecodeRawTransaction decodes legacy, EIP-2930 and EIP-1559 signed transactions, recovering the signer
+func DecodeRawTransaction(ctx context.Context, raw []byte) (*RawTransaction, error) {
+	if len(raw) == 0 {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, "empty")
+	}
+	txType := ethsigner.TransactionTypeLegacy
+	rlpBytes := raw
+	if raw[0] <= 0x7f {
+		// EIP-2718 typed transaction envelope
+		txType = raw[0]
+		rlpBytes = raw[1:]
+	}
+	layout := rawTxLayouts[txType]
+	if layout == nil {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, "unsupported transaction type")
+	}
+	decoded, endPos, err := rlp.Decode(rlpBytes)
+	if err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, err)
+	}
+	list, ok := decoded.(rlp.List)
+	if !ok || endPos != len(rlpBytes) || len(list) != layout.fields {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, "unexpected RLP structure")
+	}
+	for i, e := range list {
+		if e.IsList() != (i == layout.accessList) {
+			return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, "unexpected RLP structure")
+		}
+	}
+	intAt := func(i int) *fftypes.FFBigInt {
+		if i < 0 {
+			return nil
+		}
+		return (*fftypes.FFBigInt)(new(big.Int).SetBytes(list[i].(rlp.Data)))
+	}
+
+	tx := &RawTransaction{
+		Type:                 int(txType),
+		ChainID:              intAt(layout.chainID),
+		Nonce:                intAt(layout.nonce),
+		GasPrice:             intAt(layout.gasPrice),
+		MaxPriorityFeePerGas: intAt(layout.maxPriorityFee),
+		MaxFeePerGas:         intAt(layout.maxFee),
+		Gas:                  intAt(layout.gas),
+		Value:                intAt(layout.value),
+		Data:                 ethtypes.HexBytes0xPrefix(list[layout.data].(rlp.Data)),
+	}
+	switch to := list[layout.to].(rlp.Data); len(to) {
+	case 0: // contract deployment
+	case 20:
+		var addr ethtypes.Address0xHex
+		copy(addr[:], to)
+		tx.To = &addr
+	default:
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, "invalid 'to' address")
+	}
+
+	// Build the payload that was signed, so we can recover the signer
+	sig := &secp256k1.SignatureData{
+		V: intAt(layout.signatureStart).Int(),
+		R: intAt(layout.signatureStart + 1).Int(),
+		S: intAt(layout.signatureStart + 2).Int(),
+	}
+	if sig.R.BitLen() > 256 || sig.S.BitLen() > 256 {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, "invalid signature")
+	}
+	var chainID int64
+	var signedPayload []byte
+	if txType == ethsigner.TransactionTypeLegacy {
+		unsigned := list[0:layout.signatureStart]
+		switch v := sig.V.Int64(); {
+		case v == 27 || v == 28:
+			// Original (pre EIP-155) signature, without replay protection
+		case v >= 35:
+			// EIP-155 - the chain ID is encoded into V
+			chainIDBig := new(big.Int).Div(new(big.Int).Sub(sig.V, big.NewInt(35)), big.NewInt(2))
+			tx.ChainID = (*fftypes.FFBigInt)(chainIDBig)
+			chainID = chainIDBig.Int64()
+			unsigned = append(append(rlp.List{}, unsigned...), rlp.WrapInt(chainIDBig), rlp.WrapInt(big.NewInt(0)), rlp.WrapInt(big.NewInt(0)))
+		default:
+			return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, "invalid signature")
+		}
+		signedPayload = unsigned.Encode()
+	} else {
+		chainID = tx.ChainID.Int64()
+		signedPayload = append([]byte{txType}, list[0:layout.signatureStart].Encode()...)
+	}
+	if tx.From, err = sig.Recover(signedPayload, chainID); err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, err)
+	}
+
+	tx.Hash = rawTransactionHash(raw)
+	return tx, nil
+}
+
+// rawTransactionHash is the hash of a signed transaction, which for typed transactions includes the type byte
+func rawTransactionHash(raw []byte) ethtypes.HexBytes0xPrefix {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(raw)
+	return hash.Sum(nil)
+}
@@ -0,0 +1,393 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rlp"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-signer/pkg/secp256k1"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/sha3"
+)
+
+const sampleRawTXHash = "0x3a5fba5b6e4ee1e4ffaf1bc44e6ec2f0b1dd0ef5fe2a4cc6a6a5d5c6c3b1d5e8"
+
+func newRawTestTX() *ethsigner.Transaction {
+	return &ethsigner.Transaction{
+		Nonce:                ethtypes.NewHexInteger64(10),
+		GasPrice:             ethtypes.NewHexInteger64(2000000000),
+		MaxPriorityFeePerGas: ethtypes.NewHexInteger64(1000000000),
+		MaxFeePerGas:         ethtypes.NewHexInteger64(3000000000),
+		GasLimit:             ethtypes.NewHexInteger64(100000),
+		To:                   ethtypes.MustNewAddress("0x497eedc4299dea2f2a364be10025d0ad0f702de3"),
+		Value:                ethtypes.NewHexInteger64(100),
+		Data:                 ethtypes.MustNewHexBytes0xPrefix("0xfeedbeef"),
+	}
+}
+
+func signRaw2930(t *testing.T, kp *secp256k1.KeyPair, tx *ethsigner.Transaction, chainID int64) []byte {
+	list := rlp.List{
+		rlp.WrapInt(big.NewInt(chainID)),
+		rlp.WrapInt(tx.Nonce.BigInt()),
+		rlp.WrapInt(tx.GasPrice.BigInt()),
+		rlp.WrapInt(tx.GasLimit.BigInt()),
+		rlp.WrapAddress(tx.To),
+		rlp.WrapInt(tx.Value.BigInt()),
+		rlp.Data(tx.Data),
+		rlp.List{},
+	}
+	sig, err := kp.Sign(append([]byte{ethsigner.TransactionType2930}, list.Encode()...))
+	assert.NoError(t, err)
+	sig.UpdateEIP2930()
+	list = append(list, rlp.WrapInt(sig.V), rlp.WrapInt(sig.R), rlp.WrapInt(sig.S))
+	return append([]byte{ethsigner.TransactionType2930}, list.Encode()...)
+}
+
+func mockRawTXChecks(mRPC *rpcbackendmocks.Backend, chainID, txCount int64) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_chainId").Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(chainID)
+	}).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionCount", mock.Anything, "latest").Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(txCount)
+	}).Maybe()
+}
+
+func TestDecodeRawTransactionTypes(t *testing.T) {
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	tx := newRawTestTX()
+
+	legacyOriginal, err := tx.SignLegacyOriginal(kp)
+	assert.NoError(t, err)
+	legacyEIP155, err := tx.SignLegacyEIP155(kp, 1337)
+	assert.NoError(t, err)
+	eip2930 := signRaw2930(t, kp, tx, 1337)
+	eip1559, err := tx.SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+
+	for _, raw := range [][]byte{legacyOriginal, legacyEIP155, eip2930, eip1559} {
+		decoded, err := DecodeRawTransaction(context.Background(), raw)
+		assert.NoError(t, err)
+		assert.Equal(t, kp.Address.String(), decoded.From.String())
+		assert.Equal(t, int64(10), decoded.Nonce.Int64())
+		assert.Equal(t, int64(100000), decoded.Gas.Int64())
+		assert.Equal(t, "0x497eedc4299dea2f2a364be10025d0ad0f702de3", decoded.To.String())
+		assert.Equal(t, int64(100), decoded.Value.Int64())
+		assert.Equal(t, "0xfeedbeef", decoded.Data.String())
+		hash := sha3.NewLegacyKeccak256()
+		hash.Write(raw)
+		assert.Equal(t, ethtypes.HexBytes0xPrefix(hash.Sum(nil)), decoded.Hash)
+	}
+
+	decoded, _ := DecodeRawTransaction(context.Background(), legacyOriginal)
+	assert.Nil(t, decoded.ChainID)
+	assert.Equal(t, int64(2000000000), decoded.GasPrice.Int64())
+
+	decoded, _ = DecodeRawTransaction(context.Background(), legacyEIP155)
+	assert.Equal(t, 0, decoded.Type)
+	assert.Equal(t, int64(1337), decoded.ChainID.Int64())
+
+	decoded, _ = DecodeRawTransaction(context.Background(), eip2930)
+	assert.Equal(t, 1, decoded.Type)
+	assert.Equal(t, int64(1337), decoded.ChainID.Int64())
+	assert.Equal(t, int64(2000000000), decoded.GasPrice.Int64())
+
+	decoded, _ = DecodeRawTransaction(context.Background(), eip1559)
+	assert.Equal(t, 2, decoded.Type)
+	assert.Equal(t, int64(1337), decoded.ChainID.Int64())
+	assert.Nil(t, decoded.GasPrice)
+	assert.Equal(t, int64(1000000000), decoded.MaxPriorityFeePerGas.Int64())
+	assert.Equal(t, int64(3000000000), decoded.MaxFeePerGas.Int64())
+
+}
+
+func TestDecodeRawTransactionDeploy(t *testing.T) {
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	tx := newRawTestTX()
+	tx.To = nil
+	raw, err := tx.SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+
+	decoded, err := DecodeRawTransaction(context.Background(), raw)
+	assert.NoError(t, err)
+	assert.Nil(t, decoded.To)
+
+}
+
+func TestDecodeRawTransactionErrors(t *testing.T) {
+
+	sig := rlp.List{rlp.WrapInt(big.NewInt(27)), rlp.WrapInt(big.NewInt(1)), rlp.WrapInt(big.NewInt(1))}
+	legacyFields := rlp.List{rlp.Data{}, rlp.Data{}, rlp.Data{}, rlp.Data{}, rlp.Data{}, rlp.Data{}}
+	withSig := func(fields rlp.List, sig rlp.List) []byte {
+		return append(append(rlp.List{}, fields...), sig...).Encode()
+	}
+
+	testCases := []struct {
+		name string
+		raw  []byte
+		err  string
+	}{
+		{name: "empty", raw: []byte{}, err: "FF23067.*empty"},
+		{name: "unsupported type", raw: []byte{0x03, 0xc0}, err: "FF23067.*unsupported"},
+		{name: "bad rlp", raw: []byte{0xf8}, err: "FF23067"},
+		{name: "not a list", raw: rlp.Data{0x01}.Encode(), err: "FF23067.*structure"},
+		{name: "wrong field count", raw: rlp.List{rlp.Data{}}.Encode(), err: "FF23067.*structure"},
+		{name: "trailing bytes", raw: append(withSig(legacyFields, sig), 0x00), err: "FF23067.*structure"},
+		{name: "unexpected list", raw: withSig(rlp.List{rlp.List{}, rlp.Data{}, rlp.Data{}, rlp.Data{}, rlp.Data{}, rlp.Data{}}, sig), err: "FF23067.*structure"},
+		{name: "bad to", raw: withSig(rlp.List{rlp.Data{}, rlp.Data{}, rlp.Data{}, rlp.Data{0x01}, rlp.Data{}, rlp.Data{}}, sig), err: "FF23067.*'to'"},
+		{name: "bad legacy v", raw: withSig(legacyFields, rlp.List{rlp.WrapInt(big.NewInt(1)), rlp.WrapInt(big.NewInt(1)), rlp.WrapInt(big.NewInt(1))}), err: "FF23067.*signature"},
+		{name: "long r", raw: withSig(legacyFields, rlp.List{rlp.WrapInt(big.NewInt(27)), append(rlp.Data{0x01}, make(rlp.Data, 32)...), rlp.WrapInt(big.NewInt(1))}), err: "FF23067.*signature"},
+		{name: "unrecoverable", raw: withSig(legacyFields, rlp.List{rlp.WrapInt(big.NewInt(27)), rlp.Data{}, rlp.Data{}}), err: "FF23067"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeRawTransaction(context.Background(), tc.raw)
+			assert.Regexp(t, tc.err, err)
+		})
+	}
+
+}
+
+func TestTransactionSendRawOK(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+
+	mockRawTXChecks(mRPC, 1337, 10)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", mock.MatchedBy(func(b ethtypes.HexBytes0xPrefix) bool {
+		return b.String() == ethtypes.HexBytes0xPrefix(raw).String()
+	})).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(sampleRawTXHash)
+	})
+
+	res, reason, err := c.TransactionSendRaw(ctx, &TransactionSendRawRequest{
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+		From:            kp.Address.String(),
+		Nonce:           fftypes.NewFFBigInt(10),
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, sampleRawTXHash, res.TransactionHash)
+	assert.Equal(t, kp.Address.String(), res.Transaction.From.String())
+
+}
+
+func TestTransactionSendRawLegacyOriginalSkipsChainID(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	raw, err := newRawTestTX().SignLegacyOriginal(kp)
+	assert.NoError(t, err)
+
+	mockRawTXChecks(mRPC, 1337, 0)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(sampleRawTXHash)
+	})
+
+	_, _, err = c.TransactionSendRaw(ctx, &TransactionSendRawRequest{
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+	})
+	assert.NoError(t, err)
+	mRPC.AssertNotCalled(t, "CallRPC", mock.Anything, mock.Anything, "eth_chainId")
+
+}
+
+func TestTransactionSendRawValidationFailures(t *testing.T) {
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	tx := newRawTestTX()
+	raw1559, err := tx.SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+	rawLegacy, err := tx.SignLegacyEIP155(kp, 1337)
+	assert.NoError(t, err)
+	tx.MaxPriorityFeePerGas = ethtypes.NewHexInteger64(4000000000)
+	rawBadPriority, err := tx.SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		raw      []byte
+		req      TransactionSendRawRequest
+		chainID  int64
+		txCount  int64
+		maxFee   string
+		reason   ffcapi.ErrorReason
+		errRegex string
+	}{
+		{name: "bad hex", req: TransactionSendRawRequest{TransactionData: "not hex"}, reason: ffcapi.ErrorReasonInvalidInputs, errRegex: "FF23067"},
+		{name: "bad decode", raw: []byte{0x03}, reason: ffcapi.ErrorReasonInvalidInputs, errRegex: "FF23067"},
+		{name: "from mismatch", raw: raw1559, req: TransactionSendRawRequest{From: "0x497eedc4299dea2f2a364be10025d0ad0f702de3"}, reason: ffcapi.ErrorReasonInvalidInputs, errRegex: "FF23068"},
+		{name: "nonce mismatch", raw: raw1559, req: TransactionSendRawRequest{Nonce: fftypes.NewFFBigInt(11)}, reason: ffcapi.ErrorReasonInvalidInputs, errRegex: "FF23069"},
+		{name: "chain mismatch", raw: raw1559, chainID: 1, reason: ffcapi.ErrorReasonInvalidInputs, errRegex: "FF23070"},
+		{name: "nonce too low", raw: raw1559, chainID: 1337, txCount: 11, reason: ffcapi.ErrorReasonNonceTooLow, errRegex: "FF23071"},
+		{name: "priority fee too high", raw: rawBadPriority, reason: ffcapi.ErrorReasonInvalidInputs, errRegex: "FF23072"},
+		{name: "max fee exceeded", raw: raw1559, maxFee: "2000000000", reason: ffcapi.ErrorReasonInvalidInputs, errRegex: "FF23073.*3000000000"},
+		{name: "gas price exceeded", raw: rawLegacy, maxFee: "1000000000", reason: ffcapi.ErrorReasonInvalidInputs, errRegex: "FF23073.*2000000000"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+				conf.Set(RawTransactionsMaxFeePerGas, tc.maxFee)
+			})
+			defer done()
+			mockRawTXChecks(mRPC, tc.chainID, tc.txCount)
+
+			req := tc.req
+			if tc.raw != nil {
+				req.TransactionData = ethtypes.HexBytes0xPrefix(tc.raw).String()
+			}
+			_, reason, err := c.TransactionSendRaw(ctx, &req)
+			assert.Regexp(t, tc.errRegex, err)
+			assert.Equal(t, tc.reason, reason)
+		})
+	}
+
+}
+
+func TestTransactionSendRawRPCFailures(t *testing.T) {
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+	req := &TransactionSendRawRequest{TransactionData: ethtypes.HexBytes0xPrefix(raw).String()}
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_chainId").Return(&rpcbackend.RPCError{Message: "pop"})
+	_, _, err = c.TransactionSendRaw(ctx, req)
+	assert.Regexp(t, "pop", err)
+	done()
+
+	ctx, c, mRPC, done = newTestConnector(t)
+	mockRawTXChecks(mRPC, 1337, -1)
+	mRPC.ExpectedCalls = mRPC.ExpectedCalls[0:1]
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionCount", mock.Anything, "latest").Return(&rpcbackend.RPCError{Message: "pop"})
+	_, _, err = c.TransactionSendRaw(ctx, req)
+	assert.Regexp(t, "pop", err)
+	done()
+
+	ctx, c, mRPC, done = newTestConnector(t)
+	mockRawTXChecks(mRPC, 1337, 10)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", mock.Anything).Return(&rpcbackend.RPCError{Message: "nonce too low"})
+	_, reason, err := c.TransactionSendRaw(ctx, req)
+	assert.Regexp(t, "nonce too low", err)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, reason)
+	done()
+
+	ctx, c, mRPC, done = newTestConnector(t)
+	mockRawTXChecks(mRPC, 1337, 10)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", mock.Anything).Return(nil)
+	_, _, err = c.TransactionSendRaw(ctx, req)
+	assert.Regexp(t, "FF23048", err)
+	done()
+
+}
+
+func TestTransactionSendRawAlreadyKnown(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+
+	mockRawTXChecks(mRPC, 1337, 10)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", mock.Anything).Return(&rpcbackend.RPCError{Code: -32000, Message: "already known"})
+
+	res, reason, err := c.TransactionSendRaw(ctx, &TransactionSendRawRequest{
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.True(t, res.AlreadyKnown)
+	assert.Equal(t, res.Transaction.Hash.String(), res.TransactionHash)
+	assert.Len(t, res.Transaction.Hash, 32)
+
+}
+
+func TestTransactionSendPreSignedValidated(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(RawTransactionsValidate, true)
+	})
+	defer done()
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+
+	mockRawTXChecks(mRPC, 1337, 10)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", ethtypes.HexBytes0xPrefix(raw).String()).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(sampleRawTXHash)
+	}).Once()
+
+	req := &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{From: kp.Address.String()},
+		PreSigned:          true,
+		TransactionData:    ethtypes.HexBytes0xPrefix(raw).String(),
+	}
+	res, reason, err := c.TransactionSend(ctx, req)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, sampleRawTXHash, res.TransactionHash)
+
+	// The transaction is refused before submission if it is not signed by the sender FFTM expects
+	req.From = "0x497eedc4299dea2f2a364be10025d0ad0f702de3"
+	_, reason, err = c.TransactionSend(ctx, req)
+	assert.Regexp(t, "FF23068", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestNewEthereumConnectorBadRawTXFeeCap(t *testing.T) {
+
+	config.RootConfigReset()
+	conf := config.RootSection("unittest")
+	InitConfig(conf)
+	conf.Set(RawTransactionsMaxFeePerGas, "lots")
+	conf.Set("url", "http://localhost:8545")
+
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23074", err)
+
+}
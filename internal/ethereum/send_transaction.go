@@ -32,11 +32,58 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
-func (c *ethConnector) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+func (c *ethConnector) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (_ *ffcapi.TransactionSendResponse, _ ffcapi.ErrorReason, err error) {
+	ctx, span := c.startSpan(ctx, "TransactionSend")
+	defer func() { endSpan(span, err) }()
+	idempotencyKey := sendIdempotencyKey(req)
+	if txHash, ok := c.previouslySent(ctx, idempotencyKey); ok && !c.privateTransactionExpired(ctx, txHash) {
+		return &ffcapi.TransactionSendResponse{
+			TransactionHash: txHash,
+		}, "", nil
+	}
+	done, reason, err := c.beginWork(ctx)
+	if err != nil {
+		return nil, reason, err
+	}
+	defer done()
+	if reason, err := c.checkSubmissionHealth(ctx); err != nil {
+		return nil, reason, err
+	}
+
 	var rpcError *rpcbackend.RPCError
 	var txHash ethtypes.HexBytes0xPrefix
+	var hookTx *SubmissionHookTransaction
 	if req.PreSigned {
-		rpcError = c.backend.CallRPC(ctx, &txHash, "eth_sendRawTransaction", req.TransactionData)
+		var signedTx *RawTransaction
+		if c.preSubmitHook != nil || c.postSubmitHook != nil || c.submissionMaxDataSize > 0 || c.submissionIntrinsicGas || c.submissionBalanceCheck || c.privateRelay != nil || c.rawTxValidate {
+			raw, err := ethtypes.NewHexBytes0xPrefix(req.TransactionData)
+			if err != nil {
+				return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidRawTransaction, err)
+			}
+			if signedTx, err = DecodeRawTransaction(ctx, raw); err != nil {
+				return nil, ffcapi.ErrorReasonInvalidInputs, err
+			}
+			if c.rawTxValidate {
+				if reason, err := c.validateRawTransaction(ctx, &TransactionSendRawRequest{From: req.From, Nonce: req.Nonce}, signedTx); err != nil {
+					return nil, reason, err
+				}
+			}
+			if reason, err := c.checkTransactionSize(ctx, signedTx.Data, signedTx.To == nil, signedTx.Gas.Int()); err != nil {
+				return nil, reason, err
+			}
+			if reason, err := c.checkBalance(ctx, signedTx.From, signedTx.Value.Int(), signedTx.Gas.Int(), signedTx.GasPrice.Int(), signedTx.MaxFeePerGas.Int()); err != nil {
+				return nil, reason, err
+			}
+			hookTx = signedHookTransaction(signedTx)
+			if reason, err := c.runPreSubmitHook(ctx, hookTx, nil); err != nil {
+				return nil, reason, err
+			}
+		}
+		if signedTx != nil && c.privateRelay.relays(signedTx.From, true) {
+			txHash, rpcError = c.sendPrivateTransaction(ctx, req.TransactionData)
+		} else {
+			rpcError = c.backend.CallRPC(ctx, &txHash, "eth_sendRawTransaction", req.TransactionData)
+		}
 	} else {
 		txData, err := hex.DecodeString(strings.TrimPrefix(req.TransactionData, "0x"))
 		if err != nil {
@@ -47,12 +94,27 @@ func (c *ethConnector) TransactionSend(ctx context.Context, req *ffcapi.Transact
 		if err != nil {
 			return nil, ffcapi.ErrorReasonInvalidInputs, err
 		}
+		if from, _ := ethtypes.NewAddress(req.From); c.privateRelay.relays(from, false) {
+			// The node would broadcast the transaction to the public mempool as it signs it
+			return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgRelayRequiresSigned, from)
+		}
+		if reason, err := c.checkTransactionSize(ctx, txData, tx.To == nil, tx.GasLimit.BigInt()); err != nil {
+			return nil, reason, err
+		}
 
 		err = c.mapGasPrice(ctx, req.GasPrice, tx)
 		if err != nil {
 			return nil, ffcapi.ErrorReasonInvalidInputs, err
 		}
-		rpcError = c.backend.CallRPC(ctx, &txHash, "eth_sendTransaction", tx)
+		from, _ := ethtypes.NewAddress(req.From)
+		if reason, err := c.checkBalance(ctx, from, tx.Value.BigInt(), tx.GasLimit.BigInt(), tx.GasPrice.BigInt(), tx.MaxFeePerGas.BigInt()); err != nil {
+			return nil, reason, err
+		}
+		hookTx = unsignedHookTransaction(tx)
+		if reason, err := c.runPreSubmitHook(ctx, hookTx, tx); err != nil {
+			return nil, reason, err
+		}
+		rpcError = c.signingBackend(ctx, req.From).CallRPC(ctx, &txHash, "eth_sendTransaction", tx)
 	}
 
 	if rpcError == nil && len(txHash) != 32 {
@@ -61,7 +123,24 @@ func (c *ethConnector) TransactionSend(ctx context.Context, req *ffcapi.Transact
 	if rpcError != nil {
 		// send transaction responses never returns error details, only the error message
 		// so no need to parse the error data
-		return nil, mapError(sendRPCMethods, rpcError.Error()), rpcError.Error()
+		reason := c.mapRPCError(sendRPCMethods, rpcError)
+		if reason == ffcapi.ErrorKnownTransaction && req.PreSigned {
+			// A re-submission of the identical signed transaction, so an earlier submission succeeded. We know the
+			// hash of a signed transaction, so report success rather than have the transaction manager retry.
+			if raw, err := ethtypes.NewHexBytes0xPrefix(req.TransactionData); err == nil {
+				txHash = rawTransactionHash(raw)
+				log.L(ctx).Infof("Transaction %s is already known to the node: %s", txHash, rpcError.Message)
+				c.recordSent(idempotencyKey, txHash.String())
+				return &ffcapi.TransactionSendResponse{
+					TransactionHash: txHash.String(),
+				}, "", nil
+			}
+		}
+		return nil, reason, rpcError.Error()
+	}
+	c.recordSent(idempotencyKey, txHash.String())
+	if hookTx != nil {
+		c.runPostSubmitHook(ctx, hookTx, txHash.String())
 	}
 	return &ffcapi.TransactionSendResponse{
 		TransactionHash: txHash.String(),
@@ -91,6 +170,7 @@ func (c *ethConnector) mapGasPrice(ctx context.Context, input *fftypes.JSONAny,
 		tx.MaxPriorityFeePerGas = maxPriorityFeePerGas
 		tx.MaxFeePerGas = maxFeePerGas
 		log.L(ctx).Debugf("maxPriorityFeePerGas=%s maxFeePerGas=%s", tx.MaxPriorityFeePerGas, tx.MaxFeePerGas)
+		c.legacyFeeFallback(ctx, tx)
 		return nil
 	}
 	tx.GasPrice = (*ethtypes.HexInteger)(gasPriceObject.GetInteger("gasPrice"))
@@ -255,12 +255,33 @@ func TestSendPreSignedTransactionKnownTransaction(t *testing.T) {
 		})).
 		Return(&rpcbackend.RPCError{Message: "known transaction"})
 
+	// The re-submission of an identical signed transaction succeeds, with the hash of the transaction
 	var req ffcapi.TransactionSendRequest
 	err := json.Unmarshal([]byte(sampleSendRawTX), &req)
 	assert.NoError(t, err)
+	res, reason, err := c.TransactionSend(ctx, &req)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, rawTransactionHash(ethtypes.MustNewHexBytes0xPrefix(req.TransactionData)).String(), res.TransactionHash)
+
+	mRPC.AssertExpectations(t)
+}
+
+func TestSendTransactionKnownTransactionUnsigned(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "ALREADY_EXISTS: transaction already exists"})
+
+	// The hash is assigned by the signer, so is not known to us
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &req)
+	assert.NoError(t, err)
 	_, reason, err := c.TransactionSend(ctx, &req)
-	assert.Regexp(t, "known_transaction", reason)
-	assert.Regexp(t, "known transaction", err.Error())
+	assert.Equal(t, ffcapi.ErrorKnownTransaction, reason)
+	assert.Regexp(t, "ALREADY_EXISTS", err)
 
 	mRPC.AssertExpectations(t)
 }
@@ -410,6 +431,7 @@ func TestSendTransactionGasPriceEIP1559(t *testing.T) {
 	ctx, c, mRPC, done := newTestConnector(t)
 	defer done()
 
+	mockLatestBlock(mRPC, ethtypes.NewHexInteger64(7)).Once()
 	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction",
 		mock.MatchedBy(func(tx *ethsigner.Transaction) bool {
 			assert.Equal(t, int64(65535), tx.MaxFeePerGas.BigInt().Int64())
@@ -0,0 +1,169 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// GracefulShutdown is implemented by the connector, to drain in-flight work before the contexts are cancelled.
+// Cancelling the contexts directly abandons event batches part way through delivery, so the checkpoints
+// written by the transaction manager on the way down can be behind the events it has already processed.
+type GracefulShutdown interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdownCoordinator tracks the submissions in-flight, so that a shutdown can wait for them to complete
+// after new work is refused
+type shutdownCoordinator struct {
+	timeout  time.Duration
+	mux      sync.Mutex
+	inflight int
+	draining chan struct{} // closed when the shutdown starts
+	idle     chan struct{} // closed when there are no submissions in-flight during a shutdown
+}
+
+func newShutdownCoordinator(timeout time.Duration) *shutdownCoordinator {
+	return &shutdownCoordinator{
+		timeout:  timeout,
+		draining: make(chan struct{}),
+		idle:     make(chan struct{}),
+	}
+}
+
+func (sc *shutdownCoordinator) isDraining() bool {
+	if sc == nil {
+		return false
+	}
+	select {
+	case <-sc.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// drained returns a channel that is closed when a shutdown starts
+func (sc *shutdownCoordinator) drained() <-chan struct{} {
+	if sc == nil {
+		return nil // never closed
+	}
+	return sc.draining
+}
+
+// beginWork refuses new work once a shutdown has started. Otherwise the returned function must be called
+// when the work is complete.
+func (c *ethConnector) beginWork(ctx context.Context) (done func(), reason ffcapi.ErrorReason, err error) {
+	sc := c.shutdown
+	if sc == nil {
+		return func() {}, "", nil
+	}
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	if sc.isDraining() {
+		return nil, ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, msgs.MsgShuttingDown)
+	}
+	sc.inflight++
+	return func() {
+		sc.mux.Lock()
+		defer sc.mux.Unlock()
+		sc.inflight--
+		if sc.inflight == 0 && sc.isDraining() {
+			close(sc.idle)
+		}
+	}, "", nil
+}
+
+// Shutdown refuses new submissions and event streams, then waits up to the configured deadline for the
+// submissions in-flight to complete, and for each event stream to finish delivering its current batch of
+// events and move its checkpoint past them. The WebSocket subscriptions of the block listener are closed,
+// the re-org statistics saved, and any buffered trace spans exported. The contexts of the connector should be cancelled afterwards as usual.
+func (c *ethConnector) Shutdown(ctx context.Context) error {
+	sc := c.shutdown
+	sc.mux.Lock()
+	if sc.isDraining() {
+		sc.mux.Unlock()
+		return nil
+	}
+	close(sc.draining)
+	if sc.inflight == 0 {
+		close(sc.idle)
+	}
+	inflight := sc.inflight
+	sc.mux.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, sc.timeout)
+	defer cancel()
+	log.L(ctx).Infof("Shutting down with %d submissions in-flight (timeout=%s)", inflight, sc.timeout)
+
+	var err error
+	select {
+	case <-sc.idle:
+	case <-ctx.Done():
+		err = i18n.NewError(ctx, msgs.MsgShutdownTimeout, sc.timeout, "submissions")
+	}
+	if err == nil {
+		err = c.drainEventStreams(ctx)
+	}
+
+	c.blockListener.reorgStats.save(ctx)
+	c.flushSpans(ctx)
+	if bl := c.blockListener; bl.wsBackend != nil {
+		if err := bl.wsBackend.UnsubscribeAll(ctx); err != nil {
+			log.L(ctx).Warnf("Failed to close WebSocket subscriptions: %s", err.Message)
+		}
+	}
+	if err != nil {
+		log.L(ctx).Errorf("Shutdown incomplete: %s", err)
+		return err
+	}
+	log.L(ctx).Infof("Shutdown drained all in-flight work")
+	return nil
+}
+
+// drainEventStreams waits for the stream loop, and listener catchup loops, of each event stream to exit.
+// They check for the shutdown between batches of events.
+func (c *ethConnector) drainEventStreams(ctx context.Context) error {
+	c.mux.Lock()
+	loopsDone := make([]chan struct{}, 0, len(c.eventStreams))
+	for _, es := range c.eventStreams {
+		loopsDone = append(loopsDone, es.streamLoopDone)
+		es.mux.Lock()
+		for _, l := range es.listeners {
+			if l.catchupLoopDone != nil {
+				loopsDone = append(loopsDone, l.catchupLoopDone)
+			}
+		}
+		es.mux.Unlock()
+	}
+	c.mux.Unlock()
+	for _, loopDone := range loopsDone {
+		select {
+		case <-loopDone:
+		case <-ctx.Done():
+			return i18n.NewError(ctx, msgs.MsgShutdownTimeout, c.shutdown.timeout, "event streams")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestShutdownDrainsSubmissions(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(WebSocketsEnabled, true)
+	})
+	defer done()
+
+	workDone, _, err := c.beginWork(ctx)
+	assert.NoError(t, err)
+
+	shutdownDone := make(chan error)
+	go func() {
+		shutdownDone <- c.Shutdown(ctx)
+	}()
+	<-c.shutdown.drained()
+
+	// New work is refused while the in-flight submission completes
+	_, reason, err := c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{})
+	assert.Regexp(t, "FF23181", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+	_, _, err = c.EventStreamStart(ctx, &ffcapi.EventStreamStartRequest{ID: fftypes.NewUUID()})
+	assert.Regexp(t, "FF23181", err)
+	_, _, err = c.EventListenerAdd(ctx, &ffcapi.EventListenerAddRequest{StreamID: fftypes.NewUUID()})
+	assert.Regexp(t, "FF23181", err)
+
+	workDone()
+	assert.NoError(t, <-shutdownDone)
+	assert.NoError(t, c.Shutdown(ctx)) // no-op once started
+
+}
+
+func TestShutdownSubmissionsTimeout(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ShutdownTimeout, "1ms")
+	})
+	defer done()
+
+	_, _, err := c.beginWork(ctx)
+	assert.NoError(t, err)
+
+	err = c.Shutdown(ctx)
+	assert.Regexp(t, "FF23182.*submissions", err)
+
+}
+
+func TestShutdownEventStreamsTimeout(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ShutdownTimeout, "1ms")
+	})
+	defer done()
+
+	streamID := fftypes.NewUUID()
+	c.eventStreams[*streamID] = &eventStream{
+		streamLoopDone: make(chan struct{}), // never exits
+		listeners: map[fftypes.UUID]*listener{
+			*fftypes.NewUUID(): {catchupLoopDone: make(chan struct{})},
+		},
+	}
+
+	err := c.Shutdown(ctx)
+	assert.Regexp(t, "FF23182.*event streams", err)
+	delete(c.eventStreams, *streamID)
+
+}
+
+func TestShutdownStopsEventStreamBetweenBatches(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, false)
+	es := l.es
+	ag := es.buildAggregatedListener([]*listener{l})
+
+	exiting, err := es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{}, 1000, 0)
+	assert.False(t, exiting)
+	assert.NoError(t, err)
+	close(es.c.shutdown.draining)
+	exiting, err = es.dispatchSetHWMCheckExit(ag, ffcapi.ListenerEvents{}, 1001, 0)
+	assert.True(t, exiting)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1001), l.hwmBlock)
+
+	// A listener in catchup stops after delivering its current page
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*[]*logJSONRPC) = []*logJSONRPC{}
+	}).Once()
+	l.hwmBlock = 0
+	es.listenerCatchupLoop([]*listener{l}, l.catchupLoopDone)
+	assert.Equal(t, es.c.catchupPageSize.Load(), l.hwmBlock)
+	mRPC.AssertExpectations(t)
+
+	// The stream loop in steady state stops at its next poll
+	es.listeners = map[fftypes.UUID]*listener{}
+	assert.True(t, es.leadGroupSteadyState())
+
+}
+
+func TestShutdownNotConfigured(t *testing.T) {
+
+	c := &ethConnector{}
+	workDone, _, err := c.beginWork(context.Background())
+	assert.NoError(t, err)
+	workDone()
+	assert.False(t, c.shutdown.isDraining())
+	assert.Nil(t, c.shutdown.drained())
+
+}
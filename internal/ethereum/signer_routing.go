@@ -0,0 +1,154 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+)
+
+// signerRoute sends eth_sendTransaction for a set of addresses to a different JSON/RPC endpoint than the node,
+// such as a firefly-signer instance or a remote KMS, so one connector can use keys held in different systems
+type signerRoute struct {
+	url       string
+	backend   rpcbackend.Backend
+	addresses map[ethtypes.Address0xHex]bool
+	ranges    []*signerAddressRange
+}
+
+// signerAddressRange is an inclusive range of addresses
+type signerAddressRange struct {
+	start ethtypes.Address0xHex
+	end   ethtypes.Address0xHex
+}
+
+func initSignersConfig(signers config.ArraySection) config.ArraySection {
+	signers.AddKnownKey(SignerURL)
+	signers.AddKnownKey(SignerAddresses)
+	signers.AddKnownKey(SignerAddressRanges)
+	signers.AddKnownKey(SignerAuthUsername)
+	signers.AddKnownKey(SignerAuthPassword)
+	initEndpointTLSConfig(signers.SubSection(SignerTLS))
+	return signers
+}
+
+// newSignerRoutes builds a backend for each configured signer. Each shares the HTTP configuration of the
+// JSON/RPC endpoint of the node (timeouts, retries, headers and proxy) with its own URL and basic auth.
+// TLS is inherited from the node, unless the signer has its own tls section enabled (for example for mTLS).
+func newSignerRoutes(ctx context.Context, conf config.Section, httpConf *ffresty.Config) ([]*signerRoute, error) {
+	signers := initSignersConfig(conf.SubArray(Signers))
+	routes := make([]*signerRoute, signers.ArraySize())
+	for i := range routes {
+		signerConf := signers.ArrayEntry(i)
+		route := &signerRoute{
+			url:       signerConf.GetString(SignerURL),
+			addresses: make(map[ethtypes.Address0xHex]bool),
+		}
+		if route.url == "" {
+			return nil, i18n.NewError(ctx, msgs.MsgMissingSignerURL, i)
+		}
+		for _, a := range signerConf.GetStringSlice(SignerAddresses) {
+			address, err := ethtypes.NewAddress(a)
+			if err != nil {
+				return nil, i18n.NewError(ctx, msgs.MsgBadSignerAddress, a, i)
+			}
+			route.addresses[*address] = true
+		}
+		for _, r := range signerConf.GetStringSlice(SignerAddressRanges) {
+			addressRange, ok := parseSignerAddressRange(r)
+			if !ok {
+				return nil, i18n.NewError(ctx, msgs.MsgBadSignerAddressRange, r, i)
+			}
+			route.ranges = append(route.ranges, addressRange)
+		}
+
+		// The signer shares the TLS and timeout settings of the JSON/RPC client, but not the credentials or headers of the node
+		routeHTTPConf := *httpConf
+		routeHTTPConf.URL = route.url
+		routeHTTPConf.AuthUsername = signerConf.GetString(SignerAuthUsername)
+		routeHTTPConf.AuthPassword = signerConf.GetString(SignerAuthPassword)
+		routeHTTPConf.HTTPHeaders = nil
+		tlsConfig, err := endpointTLSConfig(ctx, signerConf.SubSection(SignerTLS), httpConf.TLSClientConfig)
+		if err != nil {
+			return nil, err
+		}
+		routeHTTPConf.TLSClientConfig = tlsConfig
+		httpClient := ffresty.NewWithConfig(ctx, routeHTTPConf)
+		if err := configureRPCProxy(ctx, conf, httpClient); err != nil {
+			return nil, err
+		}
+		route.backend = rpcbackend.NewRPCClientWithOption(httpClient, rpcbackend.RPCClientOptions{
+			MaxConcurrentRequest: conf.GetInt64(MaxConcurrentRequests),
+		})
+		log.L(ctx).Infof("Signer %d at %s for %d addresses and %d address ranges", i, route.url, len(route.addresses), len(route.ranges))
+		routes[i] = route
+	}
+	return routes, nil
+}
+
+func parseSignerAddressRange(r string) (*signerAddressRange, bool) {
+	startEnd := strings.Split(r, "-")
+	if len(startEnd) != 2 {
+		return nil, false
+	}
+	start, err := ethtypes.NewAddress(strings.TrimSpace(startEnd[0]))
+	if err != nil {
+		return nil, false
+	}
+	end, err := ethtypes.NewAddress(strings.TrimSpace(startEnd[1]))
+	if err != nil || bytes.Compare(start[:], end[:]) > 0 {
+		return nil, false
+	}
+	return &signerAddressRange{start: *start, end: *end}, true
+}
+
+func (r *signerRoute) matches(from *ethtypes.Address0xHex) bool {
+	if r.addresses[*from] {
+		return true
+	}
+	for _, ar := range r.ranges {
+		if bytes.Compare(from[:], ar.start[:]) >= 0 && bytes.Compare(from[:], ar.end[:]) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// signingBackend returns the backend to use for eth_sendTransaction from the supplied address - the first
+// configured signer that matches, or the node if none match
+func (c *ethConnector) signingBackend(ctx context.Context, from string) rpcbackend.Backend {
+	if len(c.signerRoutes) > 0 {
+		if address, err := ethtypes.NewAddress(from); err == nil {
+			for _, r := range c.signerRoutes {
+				if r.matches(address) {
+					log.L(ctx).Debugf("Sending transaction from %s via signer %s", address, r.url)
+					return r.backend
+				}
+			}
+		}
+	}
+	return c.backend
+}
@@ -0,0 +1,111 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setTestSigner(conf config.Section, i int, url string) config.Section {
+	signerConf := initSignersConfig(conf.SubArray(Signers)).ArrayEntry(i)
+	signerConf.Set(SignerURL, url)
+	return signerConf
+}
+
+func TestSendTransactionSignerRouting(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "signer1", username)
+		assert.Equal(t, "pass1", password)
+		assert.Empty(t, r.Header.Get("X-Node-Api-Key"))
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), "eth_sendTransaction")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc"}`))
+	}))
+	defer server.Close()
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(ffresty.HTTPConfigHeaders, map[string]interface{}{"X-Node-Api-Key": "secret"})
+		signer0 := setTestSigner(conf, 0, server.URL)
+		signer0.Set(SignerAddressRanges, []string{"0xb000000000000000000000000000000000000000 - 0xbfffffffffffffffffffffffffffffffffffffff"})
+		signer0.Set(SignerAuthUsername, "signer1")
+		signer0.Set(SignerAuthPassword, "pass1")
+		signer1 := setTestSigner(conf, 1, "http://localhost:0")
+		signer1.Set(SignerAddresses, []string{"0x20355f3e852d4b6a9944ada8d5399ddd3409a431"})
+	})
+	defer done()
+	assert.Len(t, c.signerRoutes, 2)
+
+	// Sent via the first signer, as the from address is in its range
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &req)
+	assert.NoError(t, err)
+	res, _, err := c.TransactionSend(ctx, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc", res.TransactionHash)
+
+	// Sent to the node, as no signer matches
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x332db2d926128920c2dc1b2067de4e86d073975fd018e22ed2470449e755b508")
+	})
+	req.From = "0xc480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8"
+	res, _, err = c.TransactionSend(ctx, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x332db2d926128920c2dc1b2067de4e86d073975fd018e22ed2470449e755b508", res.TransactionHash)
+
+	assert.Equal(t, c.signerRoutes[1].backend, c.signingBackend(context.Background(), "0x20355F3E852D4B6A9944ADA8D5399DDD3409A431"))
+	assert.Equal(t, c.backend, c.signingBackend(context.Background(), "wrong"))
+
+}
+
+func TestSignerRoutingBadConfig(t *testing.T) {
+
+	for _, tc := range []struct {
+		url    string
+		key    string
+		values []string
+		err    string
+	}{
+		{key: SignerAddresses, values: []string{"0x20355f3e852d4b6a9944ada8d5399ddd3409a431"}, err: "FF23090"},
+		{url: "http://localhost:0", key: SignerAddresses, values: []string{"wrong"}, err: "FF23091"},
+		{url: "http://localhost:0", key: SignerAddressRanges, values: []string{"0xb000000000000000000000000000000000000000"}, err: "FF23092"},
+		{url: "http://localhost:0", key: SignerAddressRanges, values: []string{"wrong-0xb000000000000000000000000000000000000000"}, err: "FF23092"},
+		{url: "http://localhost:0", key: SignerAddressRanges, values: []string{"0xb000000000000000000000000000000000000000-wrong"}, err: "FF23092"},
+		{url: "http://localhost:0", key: SignerAddressRanges, values: []string{"0xc000000000000000000000000000000000000000-0xb000000000000000000000000000000000000000"}, err: "FF23092"},
+	} {
+		conf := newTestAuthConf(t, "http://localhost:8545")
+		setTestSigner(conf, 0, tc.url).Set(tc.key, tc.values)
+		_, err := NewEthereumConnector(context.Background(), conf)
+		assert.Regexp(t, tc.err, err)
+	}
+
+}
@@ -0,0 +1,295 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+)
+
+const (
+	snapshotObjectPrefix = "snapshot-"
+	snapshotTimeFormat   = "20060102T150405.000Z" // sorts lexically in time order
+	snapshotRestoreLast  = "latest"
+)
+
+// Snapshot is the document written to object storage, so that a connector without any local state can
+// be restarted from the checkpoints of its listeners at the time of the snapshot
+type Snapshot struct {
+	Created        *fftypes.FFTime         `json:"created"`
+	CanonicalChain *CanonicalChainResponse `json:"canonicalChain"`
+	EventStreams   []*SnapshotEventStream  `json:"eventStreams"`
+}
+
+type SnapshotEventStream struct {
+	ID        *fftypes.UUID       `json:"id"`
+	HeadBlock int64               `json:"headBlock"`
+	Listeners []*SnapshotListener `json:"listeners"`
+}
+
+type SnapshotListener struct {
+	ID         *fftypes.UUID       `json:"id"`
+	Name       string              `json:"name,omitempty"`
+	Checkpoint *listenerCheckpoint `json:"checkpoint"`
+}
+
+// snapshotExporter periodically writes a snapshot to an S3 compatible bucket (including GCS in its
+// interoperability mode), and prunes old snapshots beyond the configured retention
+type snapshotExporter struct {
+	c         *ethConnector
+	client    *resty.Client
+	prefix    string
+	interval  time.Duration
+	retention int
+	loopDone  chan struct{}
+
+	restoreMux sync.Mutex
+	restored   map[fftypes.UUID]*listenerCheckpoint // consumed as each listener is started
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (c *ethConnector) initSnapshots(ctx context.Context, conf config.Section) error {
+	bucketURL := conf.GetString(SnapshotsURL)
+	if bucketURL == "" {
+		return nil
+	}
+	client := resty.New().SetBaseURL(strings.TrimSuffix(bucketURL, "/"))
+	t := &sigV4Transport{
+		base:            http.DefaultTransport,
+		region:          conf.GetString(SnapshotsRegion),
+		service:         "s3",
+		accessKeyID:     conf.GetString(SnapshotsAccessKeyID),
+		secretAccessKey: conf.GetString(SnapshotsSecretAccessKey),
+		sessionToken:    conf.GetString(SnapshotsSessionToken),
+		now:             time.Now,
+	}
+	if t.accessKeyID == "" {
+		t.accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		t.secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		t.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if t.accessKeyID != "" {
+		client.SetTransport(t)
+	}
+	c.snapshots = &snapshotExporter{
+		c:         c,
+		client:    client,
+		prefix:    conf.GetString(SnapshotsPrefix),
+		interval:  conf.GetDuration(SnapshotsInterval),
+		retention: conf.GetInt(SnapshotsRetention),
+		loopDone:  make(chan struct{}),
+	}
+	log.L(ctx).Infof("Snapshots will be written to %s every %s", bucketURL, c.snapshots.interval)
+	if restore := conf.GetString(SnapshotsRestore); restore != "" {
+		if err := c.snapshots.restore(ctx, restore); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (se *snapshotExporter) start(ctx context.Context) {
+	go se.snapshotLoop(ctx)
+}
+
+func (se *snapshotExporter) snapshotLoop(ctx context.Context) {
+	defer close(se.loopDone)
+	for {
+		select {
+		case <-time.After(se.interval):
+		case <-ctx.Done():
+			log.L(ctx).Debugf("Snapshot loop exiting")
+			return
+		}
+		if err := se.export(ctx); err != nil {
+			// We will try again at the next interval
+			log.L(ctx).Errorf("Failed to write snapshot: %s", err)
+		}
+	}
+}
+
+// export writes a new snapshot, then deletes the oldest snapshots beyond the retention count
+func (se *snapshotExporter) export(ctx context.Context) error {
+	snapshot := se.build(ctx)
+	name := se.prefix + snapshotObjectPrefix + snapshot.Created.Time().UTC().Format(snapshotTimeFormat) + ".json"
+	if _, err := se.do(ctx, http.MethodPut, name, snapshot); err != nil {
+		return err
+	}
+	log.L(ctx).Infof("Wrote snapshot %s with %d event streams", name, len(snapshot.EventStreams))
+
+	if se.retention <= 0 {
+		return nil
+	}
+	names, err := se.list(ctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(names)-se.retention; i++ {
+		if _, err := se.do(ctx, http.MethodDelete, names[i], nil); err != nil {
+			return err
+		}
+		log.L(ctx).Debugf("Deleted snapshot %s", names[i])
+	}
+	return nil
+}
+
+func (se *snapshotExporter) build(ctx context.Context) *Snapshot {
+	snapshot := &Snapshot{
+		Created:      fftypes.Now(),
+		EventStreams: []*SnapshotEventStream{},
+	}
+	snapshot.CanonicalChain, _, _ = se.c.CanonicalChain(ctx, &CanonicalChainRequest{})
+
+	se.c.mux.Lock()
+	streams := make([]*eventStream, 0, len(se.c.eventStreams))
+	for _, es := range se.c.eventStreams {
+		streams = append(streams, es)
+	}
+	se.c.mux.Unlock()
+
+	for _, es := range streams {
+		es.mux.Lock()
+		ses := &SnapshotEventStream{
+			ID:        es.id,
+			HeadBlock: es.headBlock,
+			Listeners: make([]*SnapshotListener, 0, len(es.listeners)),
+		}
+		for _, l := range es.listeners {
+			ses.Listeners = append(ses.Listeners, &SnapshotListener{
+				ID:         l.id,
+				Name:       l.config.name,
+				Checkpoint: l.getHWMCheckpoint(),
+			})
+		}
+		es.mux.Unlock()
+		snapshot.EventStreams = append(snapshot.EventStreams, ses)
+	}
+	return snapshot
+}
+
+// list returns the names of all snapshots in the bucket, oldest first
+func (se *snapshotExporter) list(ctx context.Context) ([]string, error) {
+	var names []string
+	continuationToken := ""
+	for {
+		req := se.client.R().SetContext(ctx).
+			SetQueryParam("list-type", "2").
+			SetQueryParam("prefix", se.prefix+snapshotObjectPrefix)
+		if continuationToken != "" {
+			req.SetQueryParam("continuation-token", continuationToken)
+		}
+		res, err := req.Get("/")
+		if err != nil {
+			return nil, err
+		}
+		if res.IsError() {
+			return nil, i18n.NewError(ctx, msgs.MsgSnapshotRequestFailed, http.MethodGet, "/", res.StatusCode(), res.String())
+		}
+		var result s3ListBucketResult
+		if err := xml.Unmarshal(res.Body(), &result); err != nil {
+			return nil, i18n.NewError(ctx, msgs.MsgSnapshotInvalid, "/", err)
+		}
+		for _, o := range result.Contents {
+			names = append(names, o.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (se *snapshotExporter) do(ctx context.Context, method, name string, body interface{}) ([]byte, error) {
+	req := se.client.R().SetContext(ctx)
+	if body != nil {
+		req.SetHeader("Content-Type", "application/json").SetBody(body)
+	}
+	res, err := req.Execute(method, "/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if res.IsError() {
+		return nil, i18n.NewError(ctx, msgs.MsgSnapshotRequestFailed, method, name, res.StatusCode(), res.String())
+	}
+	return res.Body(), nil
+}
+
+// restore loads the checkpoints of the named snapshot (or the latest), to use for listeners that
+// are started without a checkpoint
+func (se *snapshotExporter) restore(ctx context.Context, name string) error {
+	if name == snapshotRestoreLast {
+		names, err := se.list(ctx)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return i18n.NewError(ctx, msgs.MsgSnapshotNotFound, name)
+		}
+		name = names[len(names)-1]
+	}
+	data, err := se.do(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return i18n.NewError(ctx, msgs.MsgSnapshotInvalid, name, err)
+	}
+	se.restored = make(map[fftypes.UUID]*listenerCheckpoint)
+	for _, ses := range snapshot.EventStreams {
+		for _, sl := range ses.Listeners {
+			if sl.ID != nil && sl.Checkpoint != nil {
+				se.restored[*sl.ID] = sl.Checkpoint
+			}
+		}
+	}
+	log.L(ctx).Infof("Restoring checkpoints of %d listeners from snapshot %s created %s", len(se.restored), name, snapshot.Created)
+	return nil
+}
+
+// restoredCheckpoint returns the checkpoint for a listener from the restored snapshot, if there is one.
+// Each checkpoint is only returned once, so a listener that is later deleted and re-created starts afresh.
+func (se *snapshotExporter) restoredCheckpoint(listenerID *fftypes.UUID) *listenerCheckpoint {
+	se.restoreMux.Lock()
+	defer se.restoreMux.Unlock()
+	checkpoint := se.restored[*listenerID]
+	delete(se.restored, *listenerID)
+	return checkpoint
+}
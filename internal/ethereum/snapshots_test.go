@@ -0,0 +1,245 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// testBucket is a minimal in-memory S3 bucket, supporting path-style PUT/GET/DELETE and ListObjectsV2
+type testBucket struct {
+	mux      sync.Mutex
+	objects  map[string][]byte
+	pageSize int
+	authz    []string
+}
+
+func newTestBucket(t *testing.T) (*testBucket, string, func()) {
+	b := &testBucket{objects: make(map[string][]byte), pageSize: 1000}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.mux.Lock()
+		defer b.mux.Unlock()
+		b.authz = append(b.authz, r.Header.Get("Authorization"))
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			b.list(w, r)
+		case r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			b.objects[key] = data
+		case r.Method == http.MethodGet:
+			data, ok := b.objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		case r.Method == http.MethodDelete:
+			delete(b.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	return b, server.URL + "/bucket", server.Close
+}
+
+func (b *testBucket) list(w http.ResponseWriter, r *http.Request) {
+	keys := []string{}
+	for k := range b.objects {
+		if strings.HasPrefix(k, r.URL.Query().Get("prefix")) && k > r.URL.Query().Get("continuation-token") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	truncated := len(keys) > b.pageSize
+	if truncated {
+		keys = keys[:b.pageSize]
+	}
+	var sb strings.Builder
+	sb.WriteString("<ListBucketResult>")
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("<Contents><Key>%s</Key></Contents>", k))
+	}
+	if truncated {
+		sb.WriteString(fmt.Sprintf("<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>", keys[len(keys)-1]))
+	}
+	sb.WriteString("</ListBucketResult>")
+	_, _ = w.Write([]byte(sb.String()))
+}
+
+func (b *testBucket) keys() []string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	keys := []string{}
+	for k := range b.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestSnapshotExportAndRetention(t *testing.T) {
+
+	bucket, bucketURL, closeBucket := newTestBucket(t)
+	defer closeBucket()
+	bucket.pageSize = 1
+
+	l, _, cancelCtx := newTestListener(t, false)
+	defer cancelCtx()
+	c := l.c
+	l.moveHWM(12345)
+	c.eventStreams[*l.es.id] = l.es
+	conf := config.RootSection("snapshottest")
+	InitConfig(conf)
+	conf.Set(SnapshotsURL, bucketURL)
+	conf.Set(SnapshotsRetention, 2)
+	conf.Set(SnapshotsAccessKeyID, "AKID")
+	conf.Set(SnapshotsSecretAccessKey, "secret")
+	assert.NoError(t, c.initSnapshots(context.Background(), conf))
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, c.snapshots.export(context.Background()))
+		time.Sleep(2 * time.Millisecond) // snapshot names have millisecond precision
+	}
+	keys := bucket.keys()
+	assert.Len(t, keys, 2)
+	assert.True(t, strings.HasPrefix(keys[0], "evmconnect/snapshot-"))
+	for _, authz := range bucket.authz {
+		assert.Contains(t, authz, "Credential=AKID/")
+		assert.Contains(t, authz, "x-amz-content-sha256")
+	}
+
+	var snapshot Snapshot
+	assert.NoError(t, json.Unmarshal(bucket.objects[keys[1]], &snapshot))
+	assert.Len(t, snapshot.EventStreams, 1)
+	assert.Equal(t, l.id, snapshot.EventStreams[0].Listeners[0].ID)
+	assert.Equal(t, int64(12345), snapshot.EventStreams[0].Listeners[0].Checkpoint.Block)
+	assert.NotNil(t, snapshot.CanonicalChain)
+
+}
+
+func TestSnapshotRestoreLatest(t *testing.T) {
+
+	bucket, bucketURL, closeBucket := newTestBucket(t)
+	defer closeBucket()
+	listenerID := fftypes.NewUUID()
+	bucket.objects["evmconnect/snapshot-20240101T000000.000Z.json"] = []byte(fmt.Sprintf(`{"eventStreams":[{"listeners":[{"id":"%s","checkpoint":{"block":100}}]}]}`, listenerID))
+	bucket.objects["evmconnect/snapshot-20240102T000000.000Z.json"] = []byte(fmt.Sprintf(`{"eventStreams":[{"listeners":[{"id":"%s","checkpoint":{"block":200}}]}]}`, listenerID))
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SnapshotsURL, bucketURL)
+		conf.Set(SnapshotsRestore, "latest")
+	})
+	defer done()
+
+	// Requests are unsigned without credentials
+	assert.Equal(t, "", bucket.authz[0])
+	assert.Equal(t, int64(200), c.snapshots.restoredCheckpoint(listenerID).Block)
+	assert.Nil(t, c.snapshots.restoredCheckpoint(listenerID))
+
+}
+
+func TestSnapshotRestoreListenerCheckpoint(t *testing.T) {
+
+	bucket, bucketURL, closeBucket := newTestBucket(t)
+	defer closeBucket()
+
+	l, _, cancelCtx := newTestListener(t, false)
+	defer cancelCtx()
+	conf := config.RootSection("snapshottest")
+	InitConfig(conf)
+	conf.Set(SnapshotsURL, bucketURL)
+	listenerID := fftypes.NewUUID()
+	bucket.objects["named.json"] = []byte(fmt.Sprintf(`{"eventStreams":[{"listeners":[{"id":"%s","checkpoint":{"block":5000,"transactionIndex":-1,"logIndex":-1}}]}]}`, listenerID))
+	conf.Set(SnapshotsRestore, "named.json")
+	assert.NoError(t, l.c.initSnapshots(context.Background(), conf))
+
+	req := &ffcapi.EventListenerAddRequest{
+		ListenerID: listenerID,
+		EventListenerOptions: ffcapi.EventListenerOptions{
+			Filters: []fftypes.JSONAny{*fftypes.JSONAnyPtr(`{"event":` + abiTransferEvent + `}`)},
+			Options: fftypes.JSONAnyPtr(`{}`),
+		},
+	}
+	restored, err := l.es.addEventListener(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5000), restored.hwmBlock)
+
+}
+
+func TestSnapshotRestoreFail(t *testing.T) {
+
+	bucket, bucketURL, closeBucket := newTestBucket(t)
+	defer closeBucket()
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(SnapshotsURL, bucketURL)
+	conf.Set(SnapshotsRestore, "latest")
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23143.*latest", err)
+
+	conf.Set(SnapshotsRestore, "missing.json")
+	_, err = NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23142.*GET missing.json.*404", err)
+
+	bucket.objects["bad.json"] = []byte("!json")
+	conf.Set(SnapshotsRestore, "bad.json")
+	_, err = NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23144.*bad.json", err)
+
+}
+
+func TestSnapshotExportFail(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("!xml"))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SnapshotsURL, server.URL)
+		conf.Set(SnapshotsInterval, "1ms")
+	})
+	err := c.snapshots.export(context.Background())
+	assert.Regexp(t, "FF23142.*PUT.*403", err)
+
+	_, err = c.snapshots.list(context.Background())
+	assert.Regexp(t, "FF23144", err)
+
+	// The loop logs failures and exits when the context is cancelled
+	time.Sleep(5 * time.Millisecond)
+	done()
+
+}
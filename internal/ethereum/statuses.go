@@ -35,15 +35,77 @@ func (c *ethConnector) IsReady(ctx context.Context) (*ffcapi.ReadyResponse, ffca
 	if err != nil {
 		return &ffcapi.ReadyResponse{
 			Ready: false,
-		}, mapError(netVersionRPCMethods, err.Error()), err.Error()
+		}, c.mapRPCError(netVersionRPCMethods, err), err.Error()
+	}
+
+	if err := c.blockListener.getCheckpointMismatch(); err != nil {
+		return &ffcapi.ReadyResponse{
+			Ready: false,
+		}, "", err
 	}
 
 	details := &fftypes.JSONObject{
 		"chainID": chainID,
 	}
+	if c.dedupeCacheSize > 0 {
+		(*details)["eventDuplicates"] = c.getEventDuplicates()
+	}
+	c.feeModeMux.Lock()
+	if c.feeMode != "" {
+		(*details)["feeMode"] = c.feeMode
+	}
+	c.feeModeMux.Unlock()
+	if c.circuitBreaker != nil {
+		(*details)["circuitBreaker"] = c.circuitBreaker.getState()
+	}
+	if c.readQuorum != nil {
+		(*details)["readQuorum"] = c.readQuorum.getStatus()
+	}
+	if anomalies := c.logIndexAnomalies.Load(); anomalies > 0 {
+		(*details)["logIndexAnomalies"] = anomalies
+	}
+	if violations := c.continuityViolations.Load(); violations > 0 {
+		(*details)["continuityViolations"] = violations
+	}
+	if c.addressActivitySparseScan {
+		(*details)["addressActivity"] = map[string]int64{
+			"blocksScanned": c.addressActivityScanned.Load(),
+			"blocksSkipped": c.addressActivitySkipped.Load(),
+		}
+	}
+	if c.rpcPriority != nil {
+		(*details)["rpcPriority"] = c.rpcPriority.getStatus()
+	}
+	if c.adaptiveConcurrency != nil {
+		(*details)["concurrency"] = c.adaptiveConcurrency.getStatus()
+	}
+	if c.blockHashQueries != BlockHashQueriesNever {
+		(*details)["blockHashQueries"] = c.getBlockHashQueriesStatus(ctx)
+	}
+	if reorgStats := c.blockListener.reorgStats.getStatistics(); reorgStats.Reorgs > 0 {
+		(*details)["reorgStatistics"] = reorgStats
+	}
+	if c.blockListener.maxReorgDepth > 0 {
+		reorgHalt, deepReorgs := c.blockListener.getReorgHalt()
+		(*details)["deepReorgs"] = deepReorgs
+		if reorgHalt != nil {
+			(*details)["reorgHalt"] = reorgHalt
+		}
+	}
 
 	return &ffcapi.ReadyResponse{
 		Ready:             true,
 		DownstreamDetails: fftypes.JSONAnyPtr(details.String()),
 	}, "", nil
 }
+
+// getEventDuplicates returns the number of duplicate events dropped by each running event stream
+func (c *ethConnector) getEventDuplicates() map[string]int64 {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	duplicates := make(map[string]int64, len(c.eventStreams))
+	for id, es := range c.eventStreams {
+		duplicates[id.String()] = es.duplicates.Load()
+	}
+	return duplicates
+}
@@ -51,6 +51,7 @@ func TestIsReady(t *testing.T) {
 
 	details := status.DownstreamDetails.JSONObject()
 	assert.Equal(t, details.GetString("chainID"), "80001")
+	assert.NotContains(t, details, "eventDuplicates")
 }
 
 func TestIsReadyError(t *testing.T) {
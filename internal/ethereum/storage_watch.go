@@ -0,0 +1,204 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"golang.org/x/crypto/sha3"
+)
+
+// StorageChangeNotification is pushed to a storage watcher when the value of a watched slot differs in a new block
+// from the value last seen. The consumer remains responsible for tracking confirmations, as the block might yet be re-org'd.
+type StorageChangeNotification struct {
+	Address     string                    `json:"address"`
+	Slot        ethtypes.HexBytes0xPrefix `json:"slot"`
+	MappingSlot ethtypes.HexBytes0xPrefix `json:"mappingSlot,omitempty"` // set if the slot is the entry of a key in a mapping
+	MappingKey  ethtypes.HexBytes0xPrefix `json:"mappingKey,omitempty"`
+	BlockNumber *fftypes.FFBigInt         `json:"blockNumber"`
+	BlockHash   string                    `json:"blockHash"`
+	OldValue    ethtypes.HexBytes0xPrefix `json:"oldValue"`
+	NewValue    ethtypes.HexBytes0xPrefix `json:"newValue"`
+}
+
+// StorageWatchMapping identifies entries of a Solidity mapping, by the slot the mapping is declared in and the keys.
+// Keys are ABI encoded values, such as an address, which are left padded to 32 bytes.
+type StorageWatchMapping struct {
+	Slot ethtypes.HexBytes0xPrefix   `json:"slot"`
+	Keys []ethtypes.HexBytes0xPrefix `json:"keys"`
+}
+
+type StorageWatchRequest struct {
+	ID              *fftypes.UUID                     // unique identifier for this watcher
+	ListenerContext context.Context                   // context that will be cancelled when the watcher is no longer required
+	Address         *ethtypes.Address0xHex            // the contract whose storage is watched
+	Slots           []ethtypes.HexBytes0xPrefix       // storage slots to watch
+	Mappings        []*StorageWatchMapping            // mapping entries to watch
+	StorageChanges  chan<- *StorageChangeNotification // channel to deliver change notifications to
+}
+
+type StorageWatchResponse struct {
+}
+
+// storageWatcher reads each watched slot at every new block with eth_getStorageAt, and notifies the consumer
+// of any change. This allows consumers to follow contracts that update state without emitting events.
+type storageWatcher struct {
+	id           *fftypes.UUID
+	ctx          context.Context
+	c            *ethConnector
+	address      *ethtypes.Address0xHex
+	slots        []*watchedSlot
+	changes      chan<- *StorageChangeNotification
+	blockUpdates chan *ffcapi.BlockHashEvent
+}
+
+type watchedSlot struct {
+	slot        ethtypes.HexBytes0xPrefix
+	mappingSlot ethtypes.HexBytes0xPrefix
+	mappingKey  ethtypes.HexBytes0xPrefix
+	value       ethtypes.HexBytes0xPrefix
+}
+
+// NewStorageWatcher registers a watcher for a set of storage slots of a contract. The current values are read when
+// the watcher is registered, and each new block is then checked for changes to them.
+func (c *ethConnector) NewStorageWatcher(ctx context.Context, req *StorageWatchRequest) (*StorageWatchResponse, ffcapi.ErrorReason, error) {
+	if req.Address == nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgStorageWatchNoAddress)
+	}
+	sw := &storageWatcher{
+		id:           req.ID,
+		ctx:          req.ListenerContext,
+		c:            c,
+		address:      req.Address,
+		changes:      req.StorageChanges,
+		blockUpdates: make(chan *ffcapi.BlockHashEvent, 1),
+	}
+	for _, slot := range req.Slots {
+		if len(slot) > 32 {
+			return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidStorageSlot, slot)
+		}
+		sw.slots = append(sw.slots, &watchedSlot{slot: padStorageWord(slot)})
+	}
+	for _, m := range req.Mappings {
+		for _, key := range m.Keys {
+			if len(m.Slot) > 32 || len(key) > 32 {
+				return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidStorageSlot, key)
+			}
+			sw.slots = append(sw.slots, &watchedSlot{
+				slot:        mappingEntrySlot(m.Slot, key),
+				mappingSlot: padStorageWord(m.Slot),
+				mappingKey:  padStorageWord(key),
+			})
+		}
+	}
+	if len(sw.slots) == 0 {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgStorageWatchNoSlots)
+	}
+
+	for _, ws := range sw.slots {
+		if err := c.backend.CallRPC(ctx, &ws.value, "eth_getStorageAt", sw.address, ws.slot, "latest"); err != nil {
+			return nil, ffcapi.ErrorReason(""), err.Error()
+		}
+	}
+
+	c.blockListener.addConsumer(&blockUpdateConsumer{
+		id:      req.ID,
+		ctx:     req.ListenerContext,
+		updates: sw.blockUpdates,
+	})
+	go sw.run()
+
+	return &StorageWatchResponse{}, "", nil
+}
+
+// padStorageWord left pads a slot or key to the 32 byte word used in storage
+func padStorageWord(b ethtypes.HexBytes0xPrefix) ethtypes.HexBytes0xPrefix {
+	word := make([]byte, 32)
+	copy(word[32-len(b):], b)
+	return word
+}
+
+// mappingEntrySlot is the storage slot of the value of a key in a mapping declared at the given slot,
+// which Solidity lays out as keccak256(key . slot)
+func mappingEntrySlot(slot, key ethtypes.HexBytes0xPrefix) ethtypes.HexBytes0xPrefix {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(padStorageWord(key))
+	hash.Write(padStorageWord(slot))
+	return hash.Sum(nil)
+}
+
+func (sw *storageWatcher) run() {
+	for {
+		select {
+		case update := <-sw.blockUpdates:
+			if !sw.checkBlocks(update.BlockHashes) {
+				return
+			}
+		case <-sw.ctx.Done():
+			log.L(sw.ctx).Debugf("Storage watcher %s closed", sw.id)
+			return
+		}
+	}
+}
+
+// checkBlocks reads the watched slots at each new block. Changes in blocks that were missed, or could not be
+// read, are notified at the next block that is read.
+func (sw *storageWatcher) checkBlocks(blockHashes []string) bool {
+	for _, blockHash := range blockHashes {
+		bi, err := sw.c.blockListener.getBlockInfoByHash(sw.ctx, blockHash)
+		if err != nil || bi == nil {
+			log.L(sw.ctx).Debugf("Block '%s' not available for storage checks: %v", blockHash, err)
+			continue
+		}
+		for _, ws := range sw.slots {
+			var value ethtypes.HexBytes0xPrefix
+			if rpcErr := sw.c.backend.CallRPC(sw.ctx, &value, "eth_getStorageAt", sw.address, ws.slot, bi.Number); rpcErr != nil {
+				log.L(sw.ctx).Warnf("Storage watcher %s failed to read slot %s at block %s: %s", sw.id, ws.slot, bi.Number.BigInt(), rpcErr.Message)
+				continue
+			}
+			if bytes.Equal(value, ws.value) {
+				continue
+			}
+			n := &StorageChangeNotification{
+				Address:     sw.address.String(),
+				Slot:        ws.slot,
+				MappingSlot: ws.mappingSlot,
+				MappingKey:  ws.mappingKey,
+				BlockNumber: (*fftypes.FFBigInt)(bi.Number),
+				BlockHash:   bi.Hash.String(),
+				OldValue:    ws.value,
+				NewValue:    value,
+			}
+			ws.value = value
+			log.L(sw.ctx).Debugf("Storage watcher %s notifying change of slot %s in block %s", sw.id, ws.slot, bi.Number.BigInt())
+			select {
+			case sw.changes <- n:
+			case <-sw.ctx.Done():
+				log.L(sw.ctx).Debugf("Storage watcher %s closed", sw.id)
+				return false
+			}
+		}
+	}
+	return true
+}
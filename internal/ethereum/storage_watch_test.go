@@ -0,0 +1,177 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testStorageContract = "0x20355f3E852D4b6a9944AdA8d5399dDD3409A431"
+
+func storageWord(v int64) ethtypes.HexBytes0xPrefix {
+	return ethtypes.NewHexInteger64(v).BigInt().FillBytes(make([]byte, 32))
+}
+
+func mockStorageAt(mRPC *rpcbackendmocks.Backend, slot ethtypes.HexBytes0xPrefix, block interface{}, value int64) *mock.Call {
+	return mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getStorageAt", mock.Anything, slot, block).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexBytes0xPrefix) = storageWord(value)
+	})
+}
+
+func mockStorageBlock(mRPC *rpcbackendmocks.Backend, blockNumber int64) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", testBlockHash(blockNumber), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(blockNumber),
+			Hash:   ethtypes.MustNewHexBytes0xPrefix(testBlockHash(blockNumber)),
+		}
+	})
+}
+
+func TestMappingEntrySlot(t *testing.T) {
+
+	// keccak256(abi.encode(0, 0)) - the entry for key 0 of a mapping declared at slot 0
+	assert.Equal(t, "0xad3228b676f7d3cd4284a5443f17f1962b36e491b30a40b2405849e597ba5fb5",
+		mappingEntrySlot(ethtypes.HexBytes0xPrefix{0x00}, ethtypes.HexBytes0xPrefix{}).String())
+
+}
+
+func TestStorageWatcherNotifiesChanges(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mappingSlot := ethtypes.HexBytes0xPrefix{0x03}
+	mappingKey := ethtypes.MustNewHexBytes0xPrefix(testStorageContract)
+	entrySlot := mappingEntrySlot(mappingSlot, mappingKey)
+	changes := make(chan *StorageChangeNotification, 10)
+	sw := &storageWatcher{
+		id:      fftypes.NewUUID(),
+		ctx:     ctx,
+		c:       c,
+		address: ethtypes.MustNewAddress(testStorageContract),
+		slots: []*watchedSlot{
+			{slot: storageWord(1), value: storageWord(100)},
+			{slot: entrySlot, mappingSlot: padStorageWord(mappingSlot), mappingKey: padStorageWord(mappingKey), value: storageWord(0)},
+		},
+		changes: changes,
+	}
+
+	mockStorageBlock(mRPC, 1001)
+	mockStorageBlock(mRPC, 1002)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", testBlockHash(1003), false).Return(nil)
+	mockStorageAt(mRPC, storageWord(1), ethtypes.NewHexInteger64(1001), 100)
+	mockStorageAt(mRPC, entrySlot, ethtypes.NewHexInteger64(1001), 5)
+	mockStorageAt(mRPC, storageWord(1), ethtypes.NewHexInteger64(1002), 101)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getStorageAt", mock.Anything, entrySlot, ethtypes.NewHexInteger64(1002)).
+		Return(&rpcbackend.RPCError{Message: "pop"})
+
+	assert.True(t, sw.checkBlocks([]string{testBlockHash(1001), testBlockHash(1002), testBlockHash(1003)}))
+
+	n := <-changes
+	assert.Equal(t, entrySlot, n.Slot)
+	assert.Equal(t, mappingKey.String(), ethtypes.HexBytes0xPrefix(n.MappingKey[12:]).String())
+	assert.Equal(t, int64(1001), n.BlockNumber.Int64())
+	assert.Equal(t, storageWord(0), n.OldValue)
+	assert.Equal(t, storageWord(5), n.NewValue)
+	n = <-changes
+	assert.Equal(t, storageWord(1), n.Slot)
+	assert.Nil(t, n.MappingSlot)
+	assert.Equal(t, testBlockHash(1002), n.BlockHash)
+	assert.Equal(t, storageWord(100), n.OldValue)
+	assert.Equal(t, storageWord(101), n.NewValue)
+	assert.Empty(t, changes)
+
+}
+
+func TestStorageWatcherClosed(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	sw := &storageWatcher{
+		id:           fftypes.NewUUID(),
+		ctx:          ctx,
+		c:            c,
+		address:      ethtypes.MustNewAddress(testStorageContract),
+		slots:        []*watchedSlot{{slot: storageWord(1), value: storageWord(100)}},
+		changes:      make(chan *StorageChangeNotification),
+		blockUpdates: make(chan *ffcapi.BlockHashEvent, 1),
+	}
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", testBlockHash(1001), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{Number: ethtypes.NewHexInteger64(1001)}
+	}).Maybe()
+	mockStorageAt(mRPC, storageWord(1), ethtypes.NewHexInteger64(1001), 101).Maybe()
+
+	// The change cannot be delivered once the watcher is closed
+	sw.blockUpdates <- &ffcapi.BlockHashEvent{BlockHashes: []string{testBlockHash(1001)}}
+	done()
+	sw.run()
+	sw.slots[0].value = storageWord(100)
+	assert.False(t, sw.checkBlocks([]string{testBlockHash(1001)}))
+
+}
+
+func TestNewStorageWatcher(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.NewStorageWatcher(ctx, &StorageWatchRequest{})
+	assert.Regexp(t, "FF23146", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	req := &StorageWatchRequest{
+		ID:              fftypes.NewUUID(),
+		ListenerContext: ctx,
+		Address:         ethtypes.MustNewAddress(testStorageContract),
+		StorageChanges:  make(chan *StorageChangeNotification),
+	}
+	_, _, err = c.NewStorageWatcher(ctx, req)
+	assert.Regexp(t, "FF23147", err)
+
+	req.Slots = []ethtypes.HexBytes0xPrefix{make([]byte, 33)}
+	_, _, err = c.NewStorageWatcher(ctx, req)
+	assert.Regexp(t, "FF23148", err)
+
+	req.Slots = nil
+	req.Mappings = []*StorageWatchMapping{{Slot: ethtypes.HexBytes0xPrefix{0x01}, Keys: []ethtypes.HexBytes0xPrefix{make([]byte, 33)}}}
+	_, _, err = c.NewStorageWatcher(ctx, req)
+	assert.Regexp(t, "FF23148", err)
+
+	// The current values are read on registration
+	req.Mappings = nil
+	req.Slots = []ethtypes.HexBytes0xPrefix{{0x01}}
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getStorageAt", mock.Anything, storageWord(1), "latest").
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	_, _, err = c.NewStorageWatcher(ctx, req)
+	assert.Regexp(t, "pop", err)
+
+	mockStorageAt(mRPC, storageWord(1), "latest", 100).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_newBlockFilter").Return(nil).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", mock.Anything).Return(nil).Maybe()
+	res, _, err := c.NewStorageWatcher(ctx, req)
+	assert.NoError(t, err)
+	assert.NotNil(t, res)
+
+}
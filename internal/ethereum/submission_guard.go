@@ -0,0 +1,136 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// checkSubmissionHealth refuses submission through a node that is syncing, or whose head block is older
+// than the configured maximum age. A lagging node can hand out nonces that are already used on the network,
+// so we return a retryable error reason and let the transaction manager try again later.
+func (c *ethConnector) checkSubmissionHealth(ctx context.Context) (ffcapi.ErrorReason, error) {
+	if reason, err := c.checkLeader(ctx); err != nil {
+		return reason, err
+	}
+	if c.submissionRejectSyncing {
+		var syncing interface{} // false, or an object describing sync progress
+		if rpcErr := c.backend.CallRPC(ctx, &syncing, "eth_syncing"); rpcErr != nil {
+			return ffcapi.ErrorReasonDownstreamDown, rpcErr.Error()
+		}
+		if syncing != nil && syncing != false {
+			return ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, msgs.MsgNodeSyncing)
+		}
+	}
+	if c.submissionMaxHeadAge > 0 {
+		var head *blockInfoJSONRPC
+		if rpcErr := c.backend.CallRPC(ctx, &head, "eth_getBlockByNumber", "latest", false); rpcErr != nil {
+			return ffcapi.ErrorReasonDownstreamDown, rpcErr.Error()
+		}
+		if head == nil {
+			return ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, msgs.MsgBlockNotAvailable)
+		}
+		age := time.Since(time.Unix(head.Timestamp.BigInt().Int64(), 0))
+		if age > c.submissionMaxHeadAge {
+			return ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, msgs.MsgNodeHeadStale, head.Number.BigInt(), age.Truncate(time.Second), c.submissionMaxHeadAge)
+		}
+	}
+	return "", nil
+}
+
+// Intrinsic gas costs, from the gas schedule of current Ethereum forks (EIP-2028 for calldata, EIP-3860 for initcode)
+const (
+	intrinsicGasTx             = 21000
+	intrinsicGasContractCreate = 32000
+	intrinsicGasZeroByte       = 4
+	intrinsicGasNonZeroByte    = 16
+	intrinsicGasInitCodeWord   = 2
+)
+
+// intrinsicGas returns the gas a transaction is charged before any execution, and the number of zero bytes in the
+// data - which are four times cheaper than non-zero bytes, so indicate how compressible the data is
+func intrinsicGas(data []byte, isDeploy bool) (gas int64, zeroBytes int) {
+	for _, b := range data {
+		if b == 0 {
+			zeroBytes++
+		}
+	}
+	gas = intrinsicGasTx + int64(zeroBytes)*intrinsicGasZeroByte + int64(len(data)-zeroBytes)*intrinsicGasNonZeroByte
+	if isDeploy {
+		gas += intrinsicGasContractCreate + int64((len(data)+31)/32)*intrinsicGasInitCodeWord
+	}
+	return gas, zeroBytes
+}
+
+// checkTransactionSize refuses submission of a transaction that the node would reject for the size of its data,
+// or a gas limit below its intrinsic gas, with an error that describes the data rather than the node's opaque message
+func (c *ethConnector) checkTransactionSize(ctx context.Context, data []byte, isDeploy bool, gasLimit *big.Int) (ffcapi.ErrorReason, error) {
+	if c.submissionMaxDataSize <= 0 && !c.submissionIntrinsicGas {
+		return "", nil
+	}
+	gas, zeroBytes := intrinsicGas(data, isDeploy)
+	if c.submissionMaxDataSize > 0 && int64(len(data)) > c.submissionMaxDataSize {
+		return ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgTxDataTooLarge, len(data), zeroBytes, gas, c.submissionMaxDataSize)
+	}
+	if c.submissionIntrinsicGas && gasLimit != nil && gasLimit.Sign() > 0 && gasLimit.Cmp(big.NewInt(gas)) < 0 {
+		return ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgGasBelowIntrinsic, gasLimit, gas, len(data), zeroBytes)
+	}
+	return "", nil
+}
+
+// checkBalance refuses submission of a transaction whose sender cannot pay the value, plus the gas limit at the
+// maximum fee per gas. Nodes only reject such a transaction as it is submitted, by which time the transaction
+// manager has assigned it a nonce that then blocks the later transactions of the sender.
+// The latest balance is used, rather than the pending balance, as the pending balance already has the cost of a
+// transaction in the txpool deducted - so a re-submission of it, such as with a higher gas price, would be refused.
+func (c *ethConnector) checkBalance(ctx context.Context, from *ethtypes.Address0xHex, value, gasLimit, gasPrice, maxFeePerGas *big.Int) (ffcapi.ErrorReason, error) {
+	if !c.submissionBalanceCheck || from == nil {
+		return "", nil
+	}
+	feePerGas := gasPrice
+	if maxFeePerGas != nil && maxFeePerGas.Sign() > 0 {
+		feePerGas = maxFeePerGas
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	if gasLimit == nil {
+		gasLimit = new(big.Int)
+	}
+	if feePerGas == nil {
+		feePerGas = new(big.Int)
+	}
+	maxCost := new(big.Int).Add(value, new(big.Int).Mul(gasLimit, feePerGas))
+	if maxCost.Sign() == 0 {
+		return "", nil
+	}
+	var balance ethtypes.HexInteger
+	if rpcErr := c.backend.CallRPC(ctx, &balance, "eth_getBalance", from, "latest"); rpcErr != nil {
+		return ffcapi.ErrorReasonDownstreamDown, rpcErr.Error()
+	}
+	if balance.BigInt().Cmp(maxCost) < 0 {
+		return ffcapi.ErrorReasonInsufficientFunds, i18n.NewError(ctx, msgs.MsgInsufficientFunds, balance.BigInt(), from, maxCost, value, gasLimit, feePerGas)
+	}
+	return "", nil
+}
@@ -0,0 +1,334 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-signer/pkg/secp256k1"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func withSubmissionGuard(conf config.Section) {
+	conf.Set(SubmissionMaxHeadAge, "30s")
+	conf.Set(SubmissionRejectSyncing, true)
+}
+
+func mockNodeHealth(mRPC *rpcbackendmocks.Backend, syncing interface{}, headAge time.Duration) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_syncing").Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*interface{})) = syncing
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "latest", false).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(**blockInfoJSONRPC)) = &blockInfoJSONRPC{
+			Number:    ethtypes.NewHexInteger64(12345),
+			Timestamp: ethtypes.NewHexInteger64(time.Now().Add(-headAge).Unix()),
+		}
+	}).Maybe()
+}
+
+func TestSendTransactionHealthyNode(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withSubmissionGuard)
+	defer done()
+
+	mockNodeHealth(mRPC, false, 5*time.Second)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(sampleRawTXHash)
+	})
+
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendRawTX), &req)
+	assert.NoError(t, err)
+	res, reason, err := c.TransactionSend(ctx, &req)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, sampleRawTXHash, res.TransactionHash)
+
+}
+
+func TestSendTransactionNodeSyncing(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withSubmissionGuard)
+	defer done()
+
+	mockNodeHealth(mRPC, map[string]interface{}{"currentBlock": "0x10", "highestBlock": "0x100"}, 0)
+
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendRawTX), &req)
+	assert.NoError(t, err)
+	_, reason, err := c.TransactionSend(ctx, &req)
+	assert.Regexp(t, "FF23078", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+
+}
+
+func TestSendTransactionNodeHeadStale(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withSubmissionGuard)
+	defer done()
+
+	mockNodeHealth(mRPC, false, 5*time.Minute)
+
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &req)
+	assert.NoError(t, err)
+	_, reason, err := c.TransactionSend(ctx, &req)
+	assert.Regexp(t, "FF23079.*12345", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+
+}
+
+func TestSendTransactionRawNodeHeadStale(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withSubmissionGuard)
+	defer done()
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+
+	mockRawTXChecks(mRPC, 1337, 10)
+	mockNodeHealth(mRPC, false, 5*time.Minute)
+
+	_, reason, err := c.TransactionSendRaw(ctx, &TransactionSendRawRequest{
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+	})
+	assert.Regexp(t, "FF23079", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+
+}
+
+func TestCheckSubmissionHealthRPCFailures(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withSubmissionGuard)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_syncing").Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	reason, err := c.checkSubmissionHealth(ctx)
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_syncing").Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "latest", false).Return(&rpcbackend.RPCError{Message: "bang"}).Once()
+	reason, err = c.checkSubmissionHealth(ctx)
+	assert.Regexp(t, "bang", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", "latest", false).Return(nil).Once()
+	reason, err = c.checkSubmissionHealth(ctx)
+	assert.Regexp(t, "FF23011", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+
+}
+
+func TestCheckSubmissionHealthDisabled(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	reason, err := c.checkSubmissionHealth(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+}
+
+func TestIntrinsicGas(t *testing.T) {
+
+	gas, zeroBytes := intrinsicGas([]byte{0, 0, 1, 2}, false)
+	assert.Equal(t, int64(21000+2*4+2*16), gas)
+	assert.Equal(t, 2, zeroBytes)
+
+	// Deployment adds the creation cost, and a cost per 32 byte word of initcode
+	gas, zeroBytes = intrinsicGas(make([]byte, 33), true)
+	assert.Equal(t, int64(21000+32000+33*4+2*2), gas)
+	assert.Equal(t, 33, zeroBytes)
+
+}
+
+func TestSendTransactionDataTooLarge(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionMaxDataSize, "16")
+	})
+	defer done()
+
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &req)
+	assert.NoError(t, err)
+	_, reason, err := c.TransactionSend(ctx, &req)
+	assert.Regexp(t, "FF23140.*36 bytes.*28 zero bytes.*21,240", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestSendTransactionGasBelowIntrinsic(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionIntrinsicGasCheck, true)
+	})
+	defer done()
+
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &req)
+	assert.NoError(t, err)
+	req.Gas = fftypes.NewFFBigInt(21000)
+	_, reason, err := c.TransactionSend(ctx, &req)
+	assert.Regexp(t, "FF23141.*21000.*21,240", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	reason, err = c.checkTransactionSize(ctx, []byte{0x01}, false, big.NewInt(21016))
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+}
+
+func TestSendTransactionPreSignedGasBelowIntrinsic(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionIntrinsicGasCheck, true)
+	})
+	defer done()
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	tx := newRawTestTX()
+	tx.GasLimit = ethtypes.NewHexInteger64(21000)
+	raw, err := tx.SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+
+	_, reason, err := c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		PreSigned:       true,
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+	})
+	assert.Regexp(t, "FF23141", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestSendTransactionRawDataTooLarge(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionMaxDataSize, "2")
+	})
+	defer done()
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+	mockRawTXChecks(mRPC, 1337, 10)
+
+	_, reason, err := c.TransactionSendRaw(ctx, &TransactionSendRawRequest{
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+	})
+	assert.Regexp(t, "FF23140.*4 bytes", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func withBalanceCheck(conf config.Section) {
+	conf.Set(SubmissionBalanceCheck, true)
+}
+
+func mockPendingBalance(mRPC *rpcbackendmocks.Backend, balance int64) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBalance", mock.Anything, "latest").Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexInteger)) = *ethtypes.NewHexInteger64(balance)
+	}).Once()
+}
+
+func TestSendTransactionInsufficientFunds(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withBalanceCheck)
+	defer done()
+
+	mockPendingBalance(mRPC, 1000)
+
+	var req ffcapi.TransactionSendRequest
+	err := json.Unmarshal([]byte(sampleSendTX), &req)
+	assert.NoError(t, err)
+	req.GasPrice = fftypes.JSONAnyPtr(`{"gasPrice": "10"}`)
+	_, reason, err := c.TransactionSend(ctx, &req)
+	assert.Regexp(t, "FF23188", err)
+	assert.Equal(t, ffcapi.ErrorReasonInsufficientFunds, reason)
+	mRPC.AssertNotCalled(t, "CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything)
+
+}
+
+func TestSendTransactionPreSignedBalanceCheck(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withBalanceCheck)
+	defer done()
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+	req := &ffcapi.TransactionSendRequest{
+		PreSigned:       true,
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+	}
+
+	// Value of 100, plus a gas limit of 100000 at the max fee of 3 gwei
+	mockPendingBalance(mRPC, 300000000000099)
+	_, reason, err := c.TransactionSend(ctx, req)
+	assert.Regexp(t, "FF23188", err)
+	assert.Equal(t, ffcapi.ErrorReasonInsufficientFunds, reason)
+
+	mockPendingBalance(mRPC, 300000000000100)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(sampleRawTXHash)
+	})
+	res, _, err := c.TransactionSend(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, sampleRawTXHash, res.TransactionHash)
+
+}
+
+func TestCheckBalance(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t, withBalanceCheck)
+	defer done()
+
+	from := ethtypes.MustNewAddress(testTreasury)
+
+	// Nothing to pay for, so no need to query the balance
+	reason, err := c.checkBalance(ctx, from, nil, big.NewInt(21000), nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBalance", from, "latest").Return(&rpcbackend.RPCError{Message: "pop"})
+	reason, err = c.checkBalance(ctx, from, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+
+	c.submissionBalanceCheck = false
+	reason, err = c.checkBalance(ctx, from, big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+
+}
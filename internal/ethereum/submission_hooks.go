@@ -0,0 +1,179 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// SubmissionHookTransaction is posted to the pre-submit hook before a transaction is submitted to the node,
+// and to the post-submit hook (with the hash) after it has been accepted by the node
+type SubmissionHookTransaction struct {
+	From                 string                    `json:"from"`
+	To                   string                    `json:"to,omitempty"`
+	Nonce                *fftypes.FFBigInt         `json:"nonce,omitempty"`
+	Gas                  *fftypes.FFBigInt         `json:"gas,omitempty"`
+	Value                *fftypes.FFBigInt         `json:"value,omitempty"`
+	Data                 ethtypes.HexBytes0xPrefix `json:"data"`
+	GasPrice             *fftypes.FFBigInt         `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *fftypes.FFBigInt         `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *fftypes.FFBigInt         `json:"maxPriorityFeePerGas,omitempty"`
+	Signed               bool                      `json:"signed"`                    // the fees of a signed transaction cannot be changed by the hook
+	TransactionHash      string                    `json:"transactionHash,omitempty"` // post-submit only
+}
+
+// PreSubmitHookResponse approves or vetoes the submission, optionally replacing the fees of an unsigned transaction
+type PreSubmitHookResponse struct {
+	Approved             bool              `json:"approved"`
+	Reason               string            `json:"reason,omitempty"`
+	GasPrice             *fftypes.FFBigInt `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *fftypes.FFBigInt `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *fftypes.FFBigInt `json:"maxPriorityFeePerGas,omitempty"`
+}
+
+// newSubmissionHook builds the HTTP client for a hook, sharing the TLS and timeout settings of the
+// JSON/RPC client, but not the credentials or headers of the node
+func newSubmissionHook(ctx context.Context, url string, httpConf *ffresty.Config) *resty.Client {
+	if url == "" {
+		return nil
+	}
+	hookHTTPConf := *httpConf
+	hookHTTPConf.URL = url
+	hookHTTPConf.AuthUsername = ""
+	hookHTTPConf.AuthPassword = ""
+	hookHTTPConf.HTTPHeaders = nil
+	log.L(ctx).Infof("Submission hook configured at %s", url)
+	return ffresty.NewWithConfig(ctx, hookHTTPConf)
+}
+
+func (c *ethConnector) initSubmissionHooks(ctx context.Context, conf config.Section, httpConf *ffresty.Config) {
+	c.preSubmitHook = newSubmissionHook(ctx, conf.GetString(SubmissionPreSubmitHook), httpConf)
+	c.postSubmitHook = newSubmissionHook(ctx, conf.GetString(SubmissionPostSubmitHook), httpConf)
+}
+
+func unsignedHookTransaction(tx *ethsigner.Transaction) *SubmissionHookTransaction {
+	ht := &SubmissionHookTransaction{
+		Nonce:                (*fftypes.FFBigInt)(tx.Nonce),
+		Gas:                  (*fftypes.FFBigInt)(tx.GasLimit),
+		Value:                (*fftypes.FFBigInt)(tx.Value),
+		Data:                 tx.Data,
+		GasPrice:             (*fftypes.FFBigInt)(tx.GasPrice),
+		MaxFeePerGas:         (*fftypes.FFBigInt)(tx.MaxFeePerGas),
+		MaxPriorityFeePerGas: (*fftypes.FFBigInt)(tx.MaxPriorityFeePerGas),
+	}
+	var from ethtypes.Address0xHex
+	if err := from.UnmarshalJSON(tx.From); err == nil {
+		ht.From = from.String()
+	}
+	if tx.To != nil {
+		ht.To = tx.To.String()
+	}
+	return ht
+}
+
+func signedHookTransaction(tx *RawTransaction) *SubmissionHookTransaction {
+	ht := &SubmissionHookTransaction{
+		From:                 tx.From.String(),
+		Nonce:                tx.Nonce,
+		Gas:                  tx.Gas,
+		Value:                tx.Value,
+		Data:                 tx.Data,
+		GasPrice:             tx.GasPrice,
+		MaxFeePerGas:         tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+		Signed:               true,
+	}
+	if tx.To != nil {
+		ht.To = tx.To.String()
+	}
+	return ht
+}
+
+// runPreSubmitHook asks the pre-submit hook to approve the transaction, and applies any fees it returns to
+// the unsigned transaction (tx is nil for signed transactions). We do not submit if the hook is unavailable.
+func (c *ethConnector) runPreSubmitHook(ctx context.Context, ht *SubmissionHookTransaction, tx *ethsigner.Transaction) (ffcapi.ErrorReason, error) {
+	if c.preSubmitHook == nil {
+		return "", nil
+	}
+	var hookRes PreSubmitHookResponse
+	res, err := c.preSubmitHook.R().
+		SetContext(ctx).
+		SetBody(ht).
+		SetResult(&hookRes).
+		Post("")
+	if err != nil {
+		return ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, msgs.MsgSubmissionHookFailed, err)
+	}
+	if res.IsError() {
+		return ffcapi.ErrorReasonDownstreamDown, i18n.NewError(ctx, msgs.MsgSubmissionHookFailed, res.Status())
+	}
+	if !hookRes.Approved {
+		return ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgSubmissionVetoed, hookRes.Reason)
+	}
+
+	switch {
+	case tx == nil:
+		// fees are part of the signature
+	case hookRes.MaxFeePerGas != nil || hookRes.MaxPriorityFeePerGas != nil:
+		tx.GasPrice = nil
+		tx.MaxFeePerGas = (*ethtypes.HexInteger)(hookRes.MaxFeePerGas)
+		tx.MaxPriorityFeePerGas = (*ethtypes.HexInteger)(hookRes.MaxPriorityFeePerGas)
+		log.L(ctx).Infof("Pre-submit hook set maxFeePerGas=%s maxPriorityFeePerGas=%s", tx.MaxFeePerGas, tx.MaxPriorityFeePerGas)
+	case hookRes.GasPrice != nil:
+		tx.GasPrice = (*ethtypes.HexInteger)(hookRes.GasPrice)
+		tx.MaxFeePerGas = nil
+		tx.MaxPriorityFeePerGas = nil
+		log.L(ctx).Infof("Pre-submit hook set gasPrice=%s", tx.GasPrice)
+	}
+	if tx != nil {
+		// so the post-submit hook sees the fees that were submitted
+		ht.GasPrice = (*fftypes.FFBigInt)(tx.GasPrice)
+		ht.MaxFeePerGas = (*fftypes.FFBigInt)(tx.MaxFeePerGas)
+		ht.MaxPriorityFeePerGas = (*fftypes.FFBigInt)(tx.MaxPriorityFeePerGas)
+	}
+	return "", nil
+}
+
+// runPostSubmitHook notifies the post-submit hook of an accepted transaction. The transaction has been
+// submitted regardless, so failures are only logged.
+func (c *ethConnector) runPostSubmitHook(ctx context.Context, ht *SubmissionHookTransaction, txHash string) {
+	if c.postSubmitHook == nil {
+		return
+	}
+	ht.TransactionHash = txHash
+	res, err := c.postSubmitHook.R().
+		SetContext(ctx).
+		SetBody(ht).
+		Post("")
+	if err == nil && res.IsError() {
+		err = i18n.NewError(ctx, msgs.MsgSubmissionHookFailed, res.Status())
+	}
+	if err != nil {
+		log.L(ctx).Errorf("Post-submit hook failed for transaction %s: %s", txHash, err)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/secp256k1"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestHookServer(t *testing.T, status int, response string, received chan<- *SubmissionHookTransaction) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ht SubmissionHookTransaction
+		err := json.NewDecoder(r.Body).Decode(&ht)
+		assert.NoError(t, err)
+		received <- &ht
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+func TestSendTransactionSubmissionHooks(t *testing.T) {
+
+	preReceived := make(chan *SubmissionHookTransaction, 1)
+	postReceived := make(chan *SubmissionHookTransaction, 1)
+	preHook := newTestHookServer(t, 200, `{"approved": true, "gasPrice": "999"}`, preReceived)
+	defer preHook.Close()
+	postHook := newTestHookServer(t, 204, ``, postReceived)
+	defer postHook.Close()
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionPreSubmitHook, preHook.URL)
+		conf.Set(SubmissionPostSubmitHook, postHook.URL)
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction",
+		mock.MatchedBy(func(tx *ethsigner.Transaction) bool {
+			return tx.GasPrice.BigInt().Int64() == 999
+		})).
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix("0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc")
+		}).
+		Return(nil)
+
+	_, _, err := c.TransactionSend(ctx, testSendRequest(t))
+	assert.NoError(t, err)
+
+	pre := <-preReceived
+	assert.Equal(t, "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8", pre.From)
+	assert.Equal(t, "0xe1a078b9e2b145d0a7387f09277c6ae1d9470771", pre.To)
+	assert.Equal(t, int64(111), pre.Nonce.Int64())
+	assert.False(t, pre.Signed)
+	assert.Empty(t, pre.TransactionHash)
+	post := <-postReceived
+	assert.Equal(t, "0x3e2398ff4a875a8b9f87a6eeaaa41a139a68adeb509731300d4b90d1bdc1c4fc", post.TransactionHash)
+	assert.Equal(t, int64(999), post.GasPrice.Int64())
+
+	mRPC.AssertExpectations(t)
+
+}
+
+func TestPreSubmitHookEIP1559Fees(t *testing.T) {
+
+	preHook := newTestHookServer(t, 200, `{"approved": true, "maxFeePerGas": "200", "maxPriorityFeePerGas": "20"}`, make(chan *SubmissionHookTransaction, 1))
+	defer preHook.Close()
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionPreSubmitHook, preHook.URL)
+	})
+	defer done()
+
+	tx := &ethsigner.Transaction{GasPrice: ethtypes.NewHexInteger64(100)}
+	_, err := c.runPreSubmitHook(ctx, unsignedHookTransaction(tx), tx)
+	assert.NoError(t, err)
+	assert.Nil(t, tx.GasPrice)
+	assert.Equal(t, int64(200), tx.MaxFeePerGas.BigInt().Int64())
+	assert.Equal(t, int64(20), tx.MaxPriorityFeePerGas.BigInt().Int64())
+
+}
+
+func TestPreSubmitHookVetoed(t *testing.T) {
+
+	preHook := newTestHookServer(t, 200, `{"approved": false, "reason": "sanctioned address"}`, make(chan *SubmissionHookTransaction, 1))
+	defer preHook.Close()
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionPreSubmitHook, preHook.URL)
+	})
+	defer done()
+
+	_, reason, err := c.TransactionSend(ctx, testSendRequest(t))
+	assert.Regexp(t, "FF23111.*sanctioned address", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+	mRPC.AssertNotCalled(t, "CallRPC", mock.Anything, mock.Anything, "eth_sendTransaction", mock.Anything)
+
+}
+
+func TestPreSubmitHookUnavailable(t *testing.T) {
+
+	preHook := newTestHookServer(t, 500, `{}`, make(chan *SubmissionHookTransaction, 1))
+
+	ctx, c, _, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionPreSubmitHook, preHook.URL)
+	})
+	defer done()
+
+	_, reason, err := c.TransactionSend(ctx, testSendRequest(t))
+	assert.Regexp(t, "FF23112.*500", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+
+	preHook.Close()
+	_, reason, err = c.TransactionSend(ctx, testSendRequest(t))
+	assert.Regexp(t, "FF23112", err)
+	assert.Equal(t, ffcapi.ErrorReasonDownstreamDown, reason)
+
+}
+
+func TestSendRawTransactionSubmissionHooks(t *testing.T) {
+
+	preReceived := make(chan *SubmissionHookTransaction, 2)
+	postReceived := make(chan *SubmissionHookTransaction, 2)
+	preHook := newTestHookServer(t, 200, `{"approved": true, "gasPrice": "999"}`, preReceived)
+	defer preHook.Close()
+	postHook := newTestHookServer(t, 500, `{}`, postReceived)
+	defer postHook.Close()
+
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(SubmissionPreSubmitHook, preHook.URL)
+		conf.Set(SubmissionPostSubmitHook, postHook.URL)
+	})
+	defer done()
+
+	kp, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	raw, err := newRawTestTX().SignEIP1559(kp, 1337)
+	assert.NoError(t, err)
+
+	mockRawTXChecks(mRPC, 1337, 10)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_sendRawTransaction", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		*(args[1].(*ethtypes.HexBytes0xPrefix)) = ethtypes.MustNewHexBytes0xPrefix(sampleRawTXHash)
+	})
+
+	// Post-submit failures do not fail the submission, and signed fees are not changed
+	res, _, err := c.TransactionSendRaw(ctx, &TransactionSendRawRequest{
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, sampleRawTXHash, res.TransactionHash)
+	pre := <-preReceived
+	assert.True(t, pre.Signed)
+	assert.Equal(t, kp.Address.String(), pre.From)
+	assert.Equal(t, int64(3000000000), pre.MaxFeePerGas.Int64())
+	assert.Equal(t, sampleRawTXHash, (<-postReceived).TransactionHash)
+
+	// The same applies to pre-signed transactions sent through the FFCAPI
+	_, _, err = c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		PreSigned: true,
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: kp.Address.String(),
+		},
+		TransactionData: ethtypes.HexBytes0xPrefix(raw).String(),
+		GasPrice:        fftypes.JSONAnyPtr(`"1"`),
+	})
+	assert.NoError(t, err)
+	assert.True(t, (<-preReceived).Signed)
+	assert.Equal(t, sampleRawTXHash, (<-postReceived).TransactionHash)
+
+	_, reason, err := c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		PreSigned:       true,
+		TransactionData: "wrong",
+	})
+	assert.Error(t, err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+	_, reason, err = c.TransactionSend(ctx, &ffcapi.TransactionSendRequest{
+		PreSigned:       true,
+		TransactionData: "0xfeedbeef",
+	})
+	assert.Error(t, err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
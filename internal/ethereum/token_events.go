@@ -0,0 +1,274 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const (
+	tokenStandardERC20   = "erc20"
+	tokenStandardERC721  = "erc721"
+	tokenStandardERC1155 = "erc1155"
+
+	tokenEventTransfer = "transfer"
+	tokenEventApproval = "approval"
+)
+
+// tokenEventInfo is the normalized view of a standard token event, added to the event info
+// when the tokenTransfers listener option is set
+type tokenEventInfo struct {
+	Standard string         `json:"standard"`           // erc20, erc721 or erc1155
+	Type     string         `json:"type"`               // transfer or approval
+	Symbol   string         `json:"symbol,omitempty"`   // from symbol() on the contract, for ERC-20 and ERC-721
+	Decimals *int64         `json:"decimals,omitempty"` // from decimals() on the contract, for ERC-20
+	Operator string         `json:"operator,omitempty"` // the operator of an ERC-1155 transfer
+	From     string         `json:"from"`               // the sender of a transfer, or the owner granting an approval
+	To       string         `json:"to"`                 // the recipient of a transfer, or the approved spender
+	Amounts  []*tokenAmount `json:"amounts"`            // one entry per token ID, or a single entry for ERC-20
+}
+
+type tokenAmount struct {
+	TokenID      string `json:"tokenId,omitempty"`
+	Amount       string `json:"amount"`
+	ScaledAmount string `json:"scaledAmount,omitempty"` // the amount with the decimals of the token applied
+}
+
+// tokenMetadata is cached per contract address, as it is not expected to change
+type tokenMetadata struct {
+	symbol   string
+	decimals *int64
+}
+
+type tokenEventType struct {
+	standard  string
+	eventType string
+	event     *abi.Entry
+	topics    int // including topic0
+}
+
+func tokenEvent(standard, eventType, name string, params ...*abi.Parameter) *tokenEventType {
+	t := &tokenEventType{
+		standard:  standard,
+		eventType: eventType,
+		event:     &abi.Entry{Type: abi.Event, Name: name, Inputs: params},
+		topics:    1,
+	}
+	for _, p := range params {
+		if p.Indexed {
+			t.topics++
+		}
+	}
+	return t
+}
+
+// The ERC-20 and ERC-721 Transfer and Approval events share a signature, and are distinguished by
+// whether the final uint256 is indexed - so we index these by topic0 and the number of topics
+var tokenEventTypes = []*tokenEventType{
+	tokenEvent(tokenStandardERC20, tokenEventTransfer, "Transfer",
+		&abi.Parameter{Name: "from", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "to", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "value", Type: "uint256"},
+	),
+	tokenEvent(tokenStandardERC20, tokenEventApproval, "Approval",
+		&abi.Parameter{Name: "owner", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "spender", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "value", Type: "uint256"},
+	),
+	tokenEvent(tokenStandardERC721, tokenEventTransfer, "Transfer",
+		&abi.Parameter{Name: "from", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "to", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "tokenId", Type: "uint256", Indexed: true},
+	),
+	tokenEvent(tokenStandardERC721, tokenEventApproval, "Approval",
+		&abi.Parameter{Name: "owner", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "approved", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "tokenId", Type: "uint256", Indexed: true},
+	),
+	tokenEvent(tokenStandardERC1155, tokenEventTransfer, "TransferSingle",
+		&abi.Parameter{Name: "operator", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "from", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "to", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "id", Type: "uint256"},
+		&abi.Parameter{Name: "value", Type: "uint256"},
+	),
+	tokenEvent(tokenStandardERC1155, tokenEventTransfer, "TransferBatch",
+		&abi.Parameter{Name: "operator", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "from", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "to", Type: "address", Indexed: true},
+		&abi.Parameter{Name: "ids", Type: "uint256[]"},
+		&abi.Parameter{Name: "values", Type: "uint256[]"},
+	),
+}
+
+var (
+	tokenSymbolSelector   = (&abi.Entry{Type: abi.Function, Name: "symbol"}).FunctionSelectorBytes()
+	tokenDecimalsSelector = (&abi.Entry{Type: abi.Function, Name: "decimals"}).FunctionSelectorBytes()
+	tokenSymbolOutputs    = abi.ParameterArray{{Type: "string"}}
+)
+
+func matchTokenEvent(topics []ethtypes.HexBytes0xPrefix) *tokenEventType {
+	for _, t := range tokenEventTypes {
+		if len(topics) == t.topics && bytes.Equal(topics[0], t.event.SignatureHashBytes()) {
+			return t
+		}
+	}
+	return nil
+}
+
+// getTokenEventInfo returns the normalized token information for a log, or nil if the log is not a
+// standard token event
+func (c *ethConnector) getTokenEventInfo(ctx context.Context, ethLog *logJSONRPC, checksumAddresses bool) *tokenEventInfo {
+	t := matchTokenEvent(ethLog.Topics)
+	if t == nil || ethLog.Address == nil {
+		return nil
+	}
+	v, err := t.event.DecodeEventDataCtx(ctx, ethLog.Topics, ethLog.Data)
+	if err != nil {
+		log.L(ctx).Warnf("Failed to decode %s %s event from %s: %s", t.standard, t.event.Name, ethLog.Address, err)
+		return nil
+	}
+	address := func(cv *abi.ComponentValue) string {
+		var addr ethtypes.Address0xHex
+		cv.Value.(*big.Int).FillBytes(addr[:])
+		if checksumAddresses {
+			return checksumAddress(&addr).String()
+		}
+		return addr.String()
+	}
+
+	ti := &tokenEventInfo{
+		Standard: t.standard,
+		Type:     t.eventType,
+	}
+	args := v.Children
+	if t.standard == tokenStandardERC1155 {
+		ti.Operator = address(args[0])
+		args = args[1:]
+	}
+	ti.From = address(args[0])
+	ti.To = address(args[1])
+
+	md := c.getTokenMetadata(ctx, ethLog.Address, t.standard)
+	ti.Symbol, ti.Decimals = md.symbol, md.decimals
+	switch {
+	case t.standard == tokenStandardERC20:
+		ti.Amounts = []*tokenAmount{newTokenAmount(nil, args[2].Value.(*big.Int), ti.Decimals)}
+	case t.standard == tokenStandardERC721:
+		ti.Amounts = []*tokenAmount{newTokenAmount(args[2].Value.(*big.Int), big.NewInt(1), nil)}
+	case t.event.Name == "TransferSingle":
+		ti.Amounts = []*tokenAmount{newTokenAmount(args[2].Value.(*big.Int), args[3].Value.(*big.Int), nil)}
+	default: // TransferBatch
+		ids, values := args[2].Children, args[3].Children
+		if len(ids) != len(values) {
+			log.L(ctx).Warnf("Mismatched ids (%d) and values (%d) in TransferBatch event from %s", len(ids), len(values), ethLog.Address)
+			return nil
+		}
+		ti.Amounts = make([]*tokenAmount, len(ids))
+		for i := range ids {
+			ti.Amounts[i] = newTokenAmount(ids[i].Value.(*big.Int), values[i].Value.(*big.Int), nil)
+		}
+	}
+	return ti
+}
+
+func newTokenAmount(tokenID, amount *big.Int, decimals *int64) *tokenAmount {
+	ta := &tokenAmount{
+		Amount: amount.String(),
+	}
+	if tokenID != nil {
+		ta.TokenID = tokenID.String()
+	}
+	if decimals != nil && *decimals <= maxScaledDecimals {
+		ta.ScaledAmount = scaledDecimalIntSerializer(int(*decimals))(amount).(string)
+	}
+	return ta
+}
+
+// getTokenMetadata queries the symbol (and decimals for ERC-20) of a token contract. This is best effort,
+// as these functions are optional in the standards, so a revert results in empty metadata being cached.
+// Any other failure, such as a timeout, is not cached - so the query is made again for the next event.
+func (c *ethConnector) getTokenMetadata(ctx context.Context, address *ethtypes.Address0xHex, standard string) *tokenMetadata {
+	cacheKey := standard + "/" + address.String()
+	if cached, ok := c.tokenCache.Get(cacheKey); ok {
+		return cached.(*tokenMetadata)
+	}
+
+	md := &tokenMetadata{}
+	cacheable := true
+	if standard != tokenStandardERC1155 {
+		data, ok, final := c.callTokenFunction(ctx, address, tokenSymbolSelector)
+		if ok {
+			md.symbol = decodeTokenSymbol(ctx, data)
+		}
+		cacheable = cacheable && final
+	}
+	if standard == tokenStandardERC20 {
+		data, ok, final := c.callTokenFunction(ctx, address, tokenDecimalsSelector)
+		if ok && len(data) == 32 {
+			decimals := new(big.Int).SetBytes(data)
+			if decimals.IsInt64() {
+				d := decimals.Int64()
+				md.decimals = &d
+			}
+		}
+		cacheable = cacheable && final
+	}
+	log.L(ctx).Debugf("Token metadata for %s contract %s: symbol=%s decimals=%v cached=%t", standard, address, md.symbol, md.decimals, cacheable)
+	if cacheable {
+		c.tokenCache.Add(cacheKey, md)
+	}
+	return md
+}
+
+// callTokenFunction calls a function with no parameters on a token contract. The result is final if the call
+// succeeded or reverted, as the contract will return the same on every call.
+func (c *ethConnector) callTokenFunction(ctx context.Context, address *ethtypes.Address0xHex, selector ethtypes.HexBytes0xPrefix) (data ethtypes.HexBytes0xPrefix, ok, final bool) {
+	rpcErr := c.backend.CallRPC(ctx, &data, "eth_call", &ethsigner.Transaction{
+		To:   address,
+		Data: selector,
+	}, "latest")
+	if rpcErr != nil {
+		log.L(ctx).Debugf("Call to %s on token contract %s failed: %s", selector, address, rpcErr.Message)
+		return nil, false, c.mapRPCError(callRPCMethods, rpcErr) == ffcapi.ErrorReasonTransactionReverted
+	}
+	return data, true, true
+}
+
+// decodeTokenSymbol handles the standard string return, and the bytes32 return used by some
+// tokens that pre-date the ERC-20 standard
+func decodeTokenSymbol(ctx context.Context, data ethtypes.HexBytes0xPrefix) string {
+	if len(data) == 32 {
+		return strings.TrimRight(string(data), "\x00")
+	}
+	v, err := tokenSymbolOutputs.DecodeABIDataCtx(ctx, data, 0)
+	if err != nil || len(v.Children) != 1 {
+		log.L(ctx).Debugf("Invalid symbol returned by token contract: %s", data)
+		return ""
+	}
+	symbol, _ := v.Children[0].Value.(string)
+	return symbol
+}
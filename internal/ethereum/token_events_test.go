@@ -0,0 +1,208 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/abi"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testTopicERC1155TransferSingle = "0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62"
+	testTopicERC1155TransferBatch  = "0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb"
+	testTopicOperator              = "0x0000000000000000000000002b1c769ef5ad304a4889f2a07a6617cd935849ae"
+)
+
+func matchTokenCall(selector ethtypes.HexBytes0xPrefix) interface{} {
+	return mock.MatchedBy(func(tx *ethsigner.Transaction) bool {
+		return tx.Data.String() == selector.String()
+	})
+}
+
+func mockTokenCall(mRPC *rpcbackendmocks.Backend, selector ethtypes.HexBytes0xPrefix, result string) *mock.Call {
+	return mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", matchTokenCall(selector), "latest").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexBytes0xPrefix) = ethtypes.MustNewHexBytes0xPrefix(result)
+	})
+}
+
+func TestFilterEnrichEthLogTokenTransfersERC20(t *testing.T) {
+
+	l, mRPC, _ := newTestListener(t, false)
+	l.ee.tokenTransfers = true
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByHash", mock.Anything, false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(1024),
+		}
+	})
+	symbolData, err := tokenSymbolOutputs.EncodeABIDataValues([]interface{}{"TKN"})
+	assert.NoError(t, err)
+	mockTokenCall(mRPC, tokenSymbolSelector, ethtypes.HexBytes0xPrefix(symbolData).String()).Once()
+	mockTokenCall(mRPC, tokenDecimalsSelector, "0x0000000000000000000000000000000000000000000000000000000000000002").Once()
+
+	ev, ok, err := l.filterEnrichEthLog(context.Background(), l.config.filters[0], nil, sampleTransferLog()) // cache miss
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	ev, ok, err = l.filterEnrichEthLog(context.Background(), l.config.filters[0], nil, sampleTransferLog()) // cache hit
+	assert.True(t, ok)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(ev.Event.Info.(*eventInfo).Token)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"standard": "erc20",
+		"type": "transfer",
+		"symbol": "TKN",
+		"decimals": 2,
+		"from": "0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4",
+		"to": "0xd0f2f5103fd050739a9fb567251bc460cc24d091",
+		"amounts": [{"amount": "1000", "scaledAmount": "10"}]
+	}`, string(b))
+
+}
+
+func TestGetTokenEventInfoERC20ApprovalChecksumNoMetadata(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").Return(&rpcbackend.RPCError{Message: "execution reverted"}).Twice()
+
+	ethLog := sampleTransferLog()
+	ethLog.Topics[0] = tokenEventTypes[1].event.SignatureHashBytes()
+	ti := c.getTokenEventInfo(ctx, ethLog, true)
+	assert.Equal(t, &tokenEventInfo{
+		Standard: tokenStandardERC20,
+		Type:     tokenEventApproval,
+		From:     "0x3968eF051b422D3D1CdC182A88BBA8dD922e6Fa4",
+		To:       "0xD0f2f5103Fd050739a9FB567251bC460CC24D091",
+		Amounts:  []*tokenAmount{{Amount: "1000"}},
+	}, ti)
+
+	// Reverts are cached
+	ti = c.getTokenEventInfo(ctx, ethLog, true)
+	assert.Empty(t, ti.Symbol)
+
+}
+
+func TestGetTokenEventInfoERC20MetadataTransientFailure(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").Return(&rpcbackend.RPCError{Message: "timeout"}).Twice()
+	mockTokenCall(mRPC, tokenSymbolSelector, "0x4d4b520000000000000000000000000000000000000000000000000000000000")
+	mockTokenCall(mRPC, tokenDecimalsSelector, "0x0000000000000000000000000000000000000000000000000000000000000012")
+
+	ethLog := sampleTransferLog()
+	ti := c.getTokenEventInfo(ctx, ethLog, true)
+	assert.Empty(t, ti.Symbol)
+
+	// The failure was not cached, so the metadata is queried again
+	ti = c.getTokenEventInfo(ctx, ethLog, true)
+	assert.Equal(t, "MKR", ti.Symbol)
+	assert.Equal(t, "0.000000000000001", ti.Amounts[0].ScaledAmount)
+	mRPC.AssertNumberOfCalls(t, "CallRPC", 4)
+
+}
+
+func TestGetTokenEventInfoERC721Bytes32Symbol(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mockTokenCall(mRPC, tokenSymbolSelector, "0x4d4b520000000000000000000000000000000000000000000000000000000000")
+
+	ethLog := sampleTransferLog()
+	ethLog.Topics = append(ethLog.Topics, ethtypes.MustNewHexBytes0xPrefix("0x000000000000000000000000000000000000000000000000000000000000002a"))
+	ethLog.Data = nil
+	ti := c.getTokenEventInfo(ctx, ethLog, false)
+	assert.Equal(t, &tokenEventInfo{
+		Standard: tokenStandardERC721,
+		Type:     tokenEventTransfer,
+		Symbol:   "MKR",
+		From:     "0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4",
+		To:       "0xd0f2f5103fd050739a9fb567251bc460cc24d091",
+		Amounts:  []*tokenAmount{{TokenID: "42", Amount: "1"}},
+	}, ti)
+
+}
+
+func TestGetTokenEventInfoERC1155(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	ethLog := sampleTransferLog()
+	ethLog.Topics = append([]ethtypes.HexBytes0xPrefix{
+		ethtypes.MustNewHexBytes0xPrefix(testTopicERC1155TransferSingle),
+		ethtypes.MustNewHexBytes0xPrefix(testTopicOperator),
+	}, ethLog.Topics[1:]...)
+	data, err := abi.ParameterArray{{Type: "uint256"}, {Type: "uint256"}}.EncodeABIDataValues([]interface{}{"7", "5"})
+	assert.NoError(t, err)
+	ethLog.Data = data
+	ti := c.getTokenEventInfo(ctx, ethLog, false)
+	assert.Equal(t, &tokenEventInfo{
+		Standard: tokenStandardERC1155,
+		Type:     tokenEventTransfer,
+		Operator: "0x2b1c769ef5ad304a4889f2a07a6617cd935849ae",
+		From:     "0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4",
+		To:       "0xd0f2f5103fd050739a9fb567251bc460cc24d091",
+		Amounts:  []*tokenAmount{{TokenID: "7", Amount: "5"}},
+	}, ti)
+
+	ethLog.Topics[0] = ethtypes.MustNewHexBytes0xPrefix(testTopicERC1155TransferBatch)
+	data, err = abi.ParameterArray{{Type: "uint256[]"}, {Type: "uint256[]"}}.EncodeABIDataValues([]interface{}{[]string{"1", "2"}, []string{"10", "20"}})
+	assert.NoError(t, err)
+	ethLog.Data = data
+	ti = c.getTokenEventInfo(ctx, ethLog, false)
+	assert.Equal(t, []*tokenAmount{{TokenID: "1", Amount: "10"}, {TokenID: "2", Amount: "20"}}, ti.Amounts)
+
+	data, err = abi.ParameterArray{{Type: "uint256[]"}, {Type: "uint256[]"}}.EncodeABIDataValues([]interface{}{[]string{"1", "2"}, []string{"10"}})
+	assert.NoError(t, err)
+	ethLog.Data = data
+	assert.Nil(t, c.getTokenEventInfo(ctx, ethLog, false))
+
+}
+
+func TestGetTokenEventInfoNotToken(t *testing.T) {
+
+	ctx, c, _, done := newTestConnector(t)
+	defer done()
+
+	ethLog := sampleTransferLog()
+	ethLog.Topics[0] = ethtypes.MustNewHexBytes0xPrefix("0x0000000000000000000000000000000000000000000000000000000000000000")
+	assert.Nil(t, c.getTokenEventInfo(ctx, ethLog, false))
+
+	ethLog = sampleTransferLog()
+	ethLog.Data = ethtypes.MustNewHexBytes0xPrefix("0x00")
+	assert.Nil(t, c.getTokenEventInfo(ctx, ethLog, false))
+
+}
+
+func TestDecodeTokenSymbolInvalid(t *testing.T) {
+	assert.Empty(t, decodeTokenSymbol(context.Background(), ethtypes.MustNewHexBytes0xPrefix("0x00")))
+}
@@ -0,0 +1,122 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+type TransactionConfirmationsRequest struct {
+	TransactionHash       string `json:"transactionHash"`
+	RequiredConfirmations int64  `json:"requiredConfirmations,omitempty"` // as configured in the transaction manager
+}
+
+type TransactionConfirmationsResponse struct {
+	TransactionHash       string                 `json:"transactionHash"`
+	ReceiptBlock          *CanonicalChainBlock   `json:"receiptBlock"`              // the block the node returned the receipt in
+	CanonicalAnchor       *CanonicalChainBlock   `json:"canonicalAnchor,omitempty"` // the canonical block at the height of the receipt, if any
+	Forked                bool                   `json:"forked"`                    // the canonical block at the height of the receipt is a different block
+	Confirmations         []*CanonicalChainBlock `json:"confirmations"`             // the blocks held by the block listener that build on the receipt block, oldest first
+	ConfirmationCount     int64                  `json:"confirmationCount"`
+	RequiredConfirmations int64                  `json:"requiredConfirmations"`
+	Confirmed             bool                   `json:"confirmed"`
+	HighestBlock          int64                  `json:"highestBlock"`        // -1 until the block height has been established
+	ReorgHalt             *ReorgHalt             `json:"reorgHalt,omitempty"` // set while notifications are halted by a deep re-org
+}
+
+// TransactionConfirmations evaluates the confirmations of a mined transaction right now, against the view of the
+// head of the chain held by the block listener - which is what drives the new block notifications the transaction
+// manager counts confirmations from. This is intended for support engineers reproducing a confirmation issue
+// reported by the transaction manager, such as a transaction that never reaches the required confirmations.
+//
+// When the receipt block is older than the blocks held by the block listener, there are no blocks to check the
+// receipt block is an ancestor of. The block at its height is queried from the node instead, and if it is the
+// receipt block the count is the distance to the highest block. A receipt block the block listener has not
+// received yet is not confirmed.
+func (c *ethConnector) TransactionConfirmations(ctx context.Context, req *TransactionConfirmationsRequest) (*TransactionConfirmationsResponse, ffcapi.ErrorReason, error) {
+	txHash, err := ethtypes.NewHexBytes0xPrefix(req.TransactionHash)
+	if err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidTXHash, req.TransactionHash, err)
+	}
+
+	ethReceipt, err := c.getTransactionReceipt(ctx, txHash.String())
+	if err != nil {
+		return nil, "", err
+	}
+	if ethReceipt == nil || ethReceipt.BlockNumber == nil {
+		return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgReceiptNotAvailable, req.TransactionHash)
+	}
+
+	bl := c.blockListener
+	bl.mux.Lock()
+	view := bl.canonicalChainView
+	res := &TransactionConfirmationsResponse{
+		TransactionHash: txHash.String(),
+		ReceiptBlock: &CanonicalChainBlock{
+			BlockNumber: (*fftypes.FFBigInt)(ethReceipt.BlockNumber),
+			BlockHash:   ethReceipt.BlockHash.String(),
+		},
+		Confirmations:         []*CanonicalChainBlock{},
+		RequiredConfirmations: req.RequiredConfirmations,
+		HighestBlock:          bl.highestBlock,
+		ReorgHalt:             bl.reorgHalt,
+	}
+	bl.mux.Unlock()
+
+	receiptBlockNumber := ethReceipt.BlockNumber.BigInt().Int64()
+	if len(view) > 0 && receiptBlockNumber < view[0].number {
+		// The cache is not used, as it might hold a block that has since been replaced by a re-org
+		bi, reason, err := bl.getBlockInfoByNumber(ctx, receiptBlockNumber, false, "")
+		if err != nil {
+			return nil, reason, err
+		}
+		if bi == nil {
+			return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgBlockNotAvailable)
+		}
+		res.CanonicalAnchor = &CanonicalChainBlock{
+			BlockNumber: (*fftypes.FFBigInt)(bi.Number),
+			BlockHash:   bi.Hash.String(),
+			ParentHash:  bi.ParentHash.String(),
+		}
+		res.Forked = res.CanonicalAnchor.BlockHash != res.ReceiptBlock.BlockHash
+		if !res.Forked {
+			res.ConfirmationCount = res.HighestBlock - receiptBlockNumber
+		}
+		res.Confirmed = !res.Forked && res.ConfirmationCount >= res.RequiredConfirmations
+		return res, "", nil
+	}
+	var parentHash string
+	for _, mbi := range view {
+		if mbi.number == receiptBlockNumber {
+			res.CanonicalAnchor = canonicalChainBlock(mbi)
+			res.Forked = mbi.hash != res.ReceiptBlock.BlockHash
+			parentHash = mbi.hash
+		} else if res.CanonicalAnchor != nil && !res.Forked && mbi.parentHash == parentHash {
+			res.Confirmations = append(res.Confirmations, canonicalChainBlock(mbi))
+			parentHash = mbi.hash
+		}
+	}
+	res.ConfirmationCount = int64(len(res.Confirmations))
+	res.Confirmed = res.CanonicalAnchor != nil && !res.Forked && res.ConfirmationCount >= res.RequiredConfirmations
+	return res, "", nil
+}
@@ -0,0 +1,197 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleReceiptBlockHash = "0x6197ef1a58a2a592bb447efb651f0db7945de21aa8048801b250bd7b7431f9b6"
+
+func mockSampleReceipt(mRPC *mock.Mock) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			_ = json.Unmarshal([]byte(sampleJSONRPCReceipt), args[1])
+		})
+}
+
+func TestTransactionConfirmations(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	mockSampleReceipt(&mRPC.Mock)
+
+	bl := c.blockListener
+	bl.highestBlock = 1980
+	bl.canonicalChainView = []*minimalBlockInfo{
+		{number: 1976, hash: "0x1976", parentHash: "0x1975"},
+		{number: 1977, hash: sampleReceiptBlockHash, parentHash: "0x1976"},
+		{number: 1978, hash: "0x1978", parentHash: sampleReceiptBlockHash},
+		{number: 1979, hash: "0x1979", parentHash: "0x1978"},
+		{number: 1980, hash: "0x1980", parentHash: "0x1979"},
+	}
+
+	res, _, err := c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{
+		TransactionHash:       "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+		RequiredConfirmations: 3,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1977), res.ReceiptBlock.BlockNumber.Int64())
+	assert.Equal(t, sampleReceiptBlockHash, res.CanonicalAnchor.BlockHash)
+	assert.False(t, res.Forked)
+	assert.Len(t, res.Confirmations, 3)
+	assert.Equal(t, "0x1980", res.Confirmations[2].BlockHash)
+	assert.Equal(t, int64(3), res.ConfirmationCount)
+	assert.True(t, res.Confirmed)
+
+	res, _, err = c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{
+		TransactionHash:       "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+		RequiredConfirmations: 4,
+	})
+	assert.NoError(t, err)
+	assert.False(t, res.Confirmed)
+
+}
+
+func TestTransactionConfirmationsForked(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	mockSampleReceipt(&mRPC.Mock)
+
+	bl := c.blockListener
+	bl.highestBlock = 1978
+	bl.canonicalChainView = []*minimalBlockInfo{
+		{number: 1977, hash: "0x1977b", parentHash: "0x1976"},
+		{number: 1978, hash: "0x1978", parentHash: "0x1977b"},
+	}
+
+	res, _, err := c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{
+		TransactionHash: "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x1977b", res.CanonicalAnchor.BlockHash)
+	assert.True(t, res.Forked)
+	assert.Empty(t, res.Confirmations)
+	assert.False(t, res.Confirmed)
+
+}
+
+func TestTransactionConfirmationsOutsideView(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+	mockSampleReceipt(&mRPC.Mock)
+
+	bl := c.blockListener
+	bl.highestBlock = 2000
+	bl.canonicalChainView = []*minimalBlockInfo{
+		{number: 2000, hash: "0x2000", parentHash: "0x1999"},
+	}
+
+	// Older than the blocks held by the block listener, so checked against the block the node holds at its height
+	mockBlockAtReceipt := func(hash string) *mock.Call {
+		return mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(1977), false).Return(nil).Run(func(args mock.Arguments) {
+			*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+				Number:     ethtypes.NewHexInteger64(1977),
+				Hash:       ethtypes.MustNewHexBytes0xPrefix(hash),
+				ParentHash: ethtypes.MustNewHexBytes0xPrefix("0x1976"),
+			}
+		}).Once()
+	}
+	mockBlockAtReceipt(sampleReceiptBlockHash)
+	res, _, err := c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{
+		TransactionHash:       "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+		RequiredConfirmations: 20,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, sampleReceiptBlockHash, res.CanonicalAnchor.BlockHash)
+	assert.False(t, res.Forked)
+	assert.Equal(t, int64(23), res.ConfirmationCount)
+	assert.True(t, res.Confirmed)
+
+	// The receipt block has since been replaced by a re-org
+	mockBlockAtReceipt("0x1977b")
+	res, _, err = c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{
+		TransactionHash:       "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+		RequiredConfirmations: 20,
+	})
+	assert.NoError(t, err)
+	assert.True(t, res.Forked)
+	assert.Zero(t, res.ConfirmationCount)
+	assert.False(t, res.Confirmed)
+
+	// The block is not available
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(1977), false).Return(nil).Once()
+	_, reason, err := c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{
+		TransactionHash: "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+	})
+	assert.Regexp(t, "FF23011", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(1977), false).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	_, _, err = c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{
+		TransactionHash: "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+	})
+	assert.Regexp(t, "pop", err)
+
+	// Not yet received by the block listener
+	bl.highestBlock = 1976
+	bl.canonicalChainView = []*minimalBlockInfo{
+		{number: 1976, hash: "0x1976", parentHash: "0x1975"},
+	}
+	res, _, err = c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{
+		TransactionHash: "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2",
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, res.CanonicalAnchor)
+	assert.Equal(t, int64(0), res.ConfirmationCount)
+	assert.False(t, res.Confirmed)
+
+}
+
+func TestTransactionConfirmationsErrors(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	_, reason, err := c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{TransactionHash: "wrong"})
+	assert.Regexp(t, "FF23059", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			_ = json.Unmarshal([]byte("null"), args[1])
+		}).Once()
+	_, reason, err = c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{TransactionHash: "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2"})
+	assert.Regexp(t, "FF23012", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionReceipt", mock.Anything).
+		Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	_, _, err = c.TransactionConfirmations(ctx, &TransactionConfirmationsRequest{TransactionHash: "0x7d48ae971faf089878b57e3c28e3035540d34f38af395958d2c73c36c57c83a2"})
+	assert.Regexp(t, "pop", err)
+
+}
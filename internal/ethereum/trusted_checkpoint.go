@@ -0,0 +1,84 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// trustedCheckpoint is a block the node must agree with, before the block listener builds its view of the chain.
+// It protects against a connector configured for one network being pointed at a node on another network or fork.
+type trustedCheckpoint struct {
+	number int64
+	hash   ethtypes.HexBytes0xPrefix
+}
+
+func parseTrustedCheckpoint(ctx context.Context, conf config.Section) (*trustedCheckpoint, error) {
+	hashString := conf.GetString(TrustedCheckpointHash)
+	if hashString == "" {
+		return nil, nil
+	}
+	hash, err := ethtypes.NewHexBytes0xPrefix(hashString)
+	if err != nil || len(hash) != 32 {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidTrustedCheckpoint, TrustedCheckpointHash, hashString)
+	}
+	number := conf.GetInt64(TrustedCheckpointNumber)
+	if number < 0 {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidTrustedCheckpoint, TrustedCheckpointNumber, strconv.FormatInt(number, 10))
+	}
+	return &trustedCheckpoint{number: number, hash: hash}, nil
+}
+
+// verifyTrustedCheckpoint is called while establishing the initial block height. It retries while the node has not
+// yet synced to the checkpoint block. A different hash stops the block listener, and is reported by IsReady.
+func (bl *blockListener) verifyTrustedCheckpoint(head int64) (retry bool, err error) {
+	cp := bl.trustedCheckpoint
+	if head < cp.number {
+		log.L(bl.ctx).Warnf("Node has not yet reached trusted checkpoint block %d (head=%d)", cp.number, head)
+		return true, i18n.NewError(bl.ctx, msgs.MsgCheckpointNotReached, cp.number, head)
+	}
+	bi, _, err := bl.getBlockInfoByNumber(bl.ctx, cp.number, false, "")
+	if err != nil {
+		return true, err
+	}
+	if bi == nil {
+		return true, i18n.NewError(bl.ctx, msgs.MsgCheckpointNotReached, cp.number, head)
+	}
+	if bi.Hash.String() != cp.hash.String() {
+		err := i18n.NewError(bl.ctx, msgs.MsgCheckpointMismatch, cp.number, bi.Hash, cp.hash)
+		log.L(bl.ctx).Errorf("Block listener will not start: %s", err)
+		bl.mux.Lock()
+		bl.checkpointMismatch = err
+		bl.mux.Unlock()
+		return false, err
+	}
+	log.L(bl.ctx).Infof("Node agrees with trusted checkpoint block %d / %s", cp.number, cp.hash)
+	return false, nil
+}
+
+func (bl *blockListener) getCheckpointMismatch() error {
+	bl.mux.Lock()
+	defer bl.mux.Unlock()
+	return bl.checkpointMismatch
+}
@@ -0,0 +1,113 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/mocks/rpcbackendmocks"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockCheckpointBlock(mRPC *rpcbackendmocks.Backend, hash ethtypes.HexBytes0xPrefix) {
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(100), false).Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(**blockInfoJSONRPC) = &blockInfoJSONRPC{
+			Number: ethtypes.NewHexInteger64(100),
+			Hash:   hash,
+		}
+	}).Once()
+}
+
+func TestTrustedCheckpointVerified(t *testing.T) {
+
+	checkpointHash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(TrustedCheckpointNumber, 100)
+		conf.Set(TrustedCheckpointHash, checkpointHash.String())
+		conf.Set(RetryInitDelay, "1ms")
+	})
+	defer done()
+
+	// The node has not synced to the checkpoint, then does not return the block, then agrees with it
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexInteger) = *ethtypes.NewHexInteger64(50)
+	}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexInteger) = *ethtypes.NewHexInteger64(200)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(100), false).Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getBlockByNumber", ethtypes.NewHexInteger64(100), false).Return(nil).Once()
+	mockCheckpointBlock(mRPC, checkpointHash)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_newBlockFilter").Return(nil).Maybe()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getFilterChanges", mock.Anything).Return(nil).Maybe()
+
+	head, ok := c.blockListener.getHighestBlock(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, int64(200), head)
+	assert.NoError(t, c.blockListener.getCheckpointMismatch())
+
+}
+
+func TestTrustedCheckpointMismatch(t *testing.T) {
+
+	checkpointHash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	ctx, c, mRPC, done := newTestConnector(t, func(conf config.Section) {
+		conf.Set(TrustedCheckpointNumber, 100)
+		conf.Set(TrustedCheckpointHash, checkpointHash.String())
+		conf.Set(RetryInitDelay, "1ms")
+	})
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*ethtypes.HexInteger) = *ethtypes.NewHexInteger64(200)
+	}).Once()
+	otherHash := ethtypes.MustNewHexBytes0xPrefix(fftypes.NewRandB32().String())
+	mockCheckpointBlock(mRPC, otherHash)
+
+	// The block listener does not start, so there is no chain head
+	_, ok := c.blockListener.getHighestBlock(ctx)
+	assert.False(t, ok)
+	<-c.blockListener.listenLoopDone
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "net_version").Return(nil).Run(func(args mock.Arguments) {
+		*args[1].(*string) = "1337"
+	})
+	status, _, err := c.IsReady(ctx)
+	assert.Regexp(t, "FF23137.*"+otherHash.String()+".*"+checkpointHash.String(), err)
+	assert.False(t, status.Ready)
+
+}
+
+func TestTrustedCheckpointBadConfig(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	conf.Set(TrustedCheckpointHash, "0xfeedbeef")
+	_, err := NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23135.*blockHash", err)
+
+	conf.Set(TrustedCheckpointHash, fftypes.NewRandB32().String())
+	conf.Set(TrustedCheckpointNumber, -1)
+	_, err = NewEthereumConnector(context.Background(), conf)
+	assert.Regexp(t, "FF23135.*blockNumber", err)
+
+}
@@ -0,0 +1,107 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-common/pkg/retry"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+)
+
+// ConfigReloader is implemented by the connector, to apply changes to tunable settings without a restart
+type ConfigReloader interface {
+	ReloadConfig(ctx context.Context, conf config.Section) error
+}
+
+// tunables are the settings that can be changed by ReloadConfig while the connector is running.
+// They are replaced as a whole, so code that uses more than one should take a single snapshot.
+type tunables struct {
+	retry                      *retry.Retry
+	gasEstimationFactor        *big.Float
+	gasEstimationFloor         *big.Int // nil if not limited
+	gasEstimationCeiling       *big.Int // nil if not limited
+	gasEstimationFallback      bool
+	rawTxMaxFeePerGas          *big.Int // nil if not limited
+	eventFilterPollingInterval time.Duration
+}
+
+func newTunables(ctx context.Context, conf config.Section) (*tunables, error) {
+	t := &tunables{
+		retry: &retry.Retry{
+			InitialDelay: conf.GetDuration(RetryInitDelay),
+			MaximumDelay: conf.GetDuration(RetryMaxDelay),
+			Factor:       conf.GetFloat64(RetryFactor),
+		},
+		gasEstimationFactor:        big.NewFloat(conf.GetFloat64(ConfigGasEstimationFactor)),
+		gasEstimationFallback:      conf.GetBool(ConfigGasEstimationFallback),
+		eventFilterPollingInterval: conf.GetDuration(EventsFilterPollingInterval),
+	}
+	if maxFee := conf.GetString(RawTransactionsMaxFeePerGas); maxFee != "" {
+		var ok bool
+		if t.rawTxMaxFeePerGas, ok = new(big.Int).SetString(maxFee, 0); !ok {
+			return nil, i18n.NewError(ctx, msgs.MsgInvalidRawTxFeeCap, maxFee)
+		}
+	}
+	var err error
+	if t.gasEstimationFloor, err = getGasLimitConfig(ctx, conf, ConfigGasEstimationFloor); err != nil {
+		return nil, err
+	}
+	if t.gasEstimationCeiling, err = getGasLimitConfig(ctx, conf, ConfigGasEstimationCeiling); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func getGasLimitConfig(ctx context.Context, conf config.Section, key string) (*big.Int, error) {
+	s := conf.GetString(key)
+	if s == "" {
+		return nil, nil
+	}
+	i, ok := new(big.Int).SetString(s, 0)
+	if !ok || i.Sign() <= 0 {
+		return nil, i18n.NewError(ctx, msgs.MsgInvalidGasLimitConfig, key, s)
+	}
+	return i, nil
+}
+
+func (c *ethConnector) tuned() *tunables {
+	return c.tunables.Load()
+}
+
+// ReloadConfig applies the current values of the tunable settings, leaving event stream checkpoints and
+// WebSocket connections untouched. Other settings are only read on startup. If any value is invalid,
+// none are applied.
+func (c *ethConnector) ReloadConfig(ctx context.Context, conf config.Section) error {
+	t, err := newTunables(ctx, conf)
+	if err != nil {
+		return err
+	}
+	c.tunables.Store(t)
+	blockPollingInterval := conf.GetDuration(BlockPollingInterval)
+	c.blockListener.mux.Lock()
+	c.blockListener.blockPollingInterval = blockPollingInterval
+	c.blockListener.mux.Unlock()
+	log.L(ctx).Infof("Reloaded configuration: blockPollingInterval=%s events.filterPollingInterval=%s retry=%s/%s/%.2f gasEstimationFactor=%s gasEstimationFloor=%s gasEstimationCeiling=%s gasEstimationFallback=%t rawTransactions.maxFeePerGas=%s",
+		blockPollingInterval, t.eventFilterPollingInterval, t.retry.InitialDelay, t.retry.MaximumDelay, t.retry.Factor, t.gasEstimationFactor, t.gasEstimationFloor, t.gasEstimationCeiling, t.gasEstimationFallback, t.rawTxMaxFeePerGas)
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadConfig(t *testing.T) {
+
+	conf := newTestAuthConf(t, "http://localhost:8545")
+	cc, err := NewEthereumConnector(context.Background(), conf)
+	assert.NoError(t, err)
+	var reloader ConfigReloader = cc.(*ethConnector)
+	c := cc.(*ethConnector)
+	before := c.tuned()
+	assert.Nil(t, before.rawTxMaxFeePerGas)
+
+	conf.Set(RetryMaxDelay, "5s")
+	conf.Set(ConfigGasEstimationFactor, 2.0)
+	conf.Set(RawTransactionsMaxFeePerGas, "1000000000")
+	conf.Set(EventsFilterPollingInterval, "3s")
+	conf.Set(BlockPollingInterval, "7s")
+	err = reloader.ReloadConfig(context.Background(), conf)
+	assert.NoError(t, err)
+
+	after := c.tuned()
+	assert.Equal(t, 5*time.Second, after.retry.MaximumDelay)
+	f, _ := after.gasEstimationFactor.Float64()
+	assert.Equal(t, 2.0, f)
+	assert.Equal(t, int64(1000000000), after.rawTxMaxFeePerGas.Int64())
+	assert.Equal(t, 3*time.Second, after.eventFilterPollingInterval)
+	assert.Equal(t, 7*time.Second, c.blockListener.getBlockPollingInterval())
+	// A snapshot taken before the reload is unchanged
+	assert.NotEqual(t, 5*time.Second, before.retry.MaximumDelay)
+
+	// Nothing is applied if a value is invalid
+	conf.Set(RetryMaxDelay, "10s")
+	conf.Set(RawTransactionsMaxFeePerGas, "wrong")
+	err = c.ReloadConfig(context.Background(), conf)
+	assert.Regexp(t, "FF23074", err)
+	assert.Equal(t, after, c.tuned())
+
+}
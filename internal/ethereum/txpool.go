@@ -0,0 +1,200 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// The JSON/RPC error code for a method that does not exist, or whose API module is not enabled on the node
+const rpcCodeMethodNotFound = -32601
+
+type TxPoolStatus string
+
+const (
+	TxPoolPending TxPoolStatus = "pending" // executable, and waiting to be mined
+	TxPoolQueued  TxPoolStatus = "queued"  // not executable, such as when there is a gap in the nonces of the sender
+)
+
+type TxPoolRequest struct {
+	From string `json:"from,omitempty"` // only return the transactions of this sender
+}
+
+type TxPoolTransaction struct {
+	Hash                 string            `json:"hash"`
+	From                 string            `json:"from"`
+	To                   string            `json:"to,omitempty"`
+	Nonce                *fftypes.FFBigInt `json:"nonce"`
+	Gas                  *fftypes.FFBigInt `json:"gas"`
+	GasPrice             *fftypes.FFBigInt `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *fftypes.FFBigInt `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *fftypes.FFBigInt `json:"maxPriorityFeePerGas,omitempty"`
+	Status               TxPoolStatus      `json:"status"`
+}
+
+type TxPoolResponse struct {
+	Pending      int64                `json:"pending"` // across all senders
+	Queued       int64                `json:"queued"`  // across all senders - always zero for Besu, which does not distinguish queued transactions
+	Transactions []*TxPoolTransaction `json:"transactions"`
+}
+
+type txPoolStatusJSONRPC struct {
+	Pending *ethtypes.HexInteger `json:"pending"`
+	Queued  *ethtypes.HexInteger `json:"queued"`
+}
+
+type besuTxPoolStatisticsJSONRPC struct {
+	LocalCount  int64 `json:"localCount"`
+	RemoteCount int64 `json:"remoteCount"`
+}
+
+type besuTxPoolTransactionJSONRPC struct {
+	Hash ethtypes.HexBytes0xPrefix `json:"hash"`
+}
+
+// TxPool returns the transactions held in the mempool of the node, so that the transaction manager can show
+// whether a transaction that is taking a long time to be mined is actually pending on the node, and at what
+// gas price. The geth txpool API is used if available, otherwise the Besu equivalents.
+func (c *ethConnector) TxPool(ctx context.Context, req *TxPoolRequest) (*TxPoolResponse, ffcapi.ErrorReason, error) {
+
+	var from *ethtypes.Address0xHex
+	if req.From != "" {
+		var err error
+		if from, err = ethtypes.NewAddress(req.From); err != nil {
+			return nil, ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgInvalidFromAddress, req.From, err)
+		}
+	}
+
+	res, rpcErr := c.txPoolGeth(ctx, from)
+	if rpcErr != nil && rpcErr.Code == rpcCodeMethodNotFound {
+		log.L(ctx).Debugf("geth txpool API not available, using Besu txpool API: %s", rpcErr.Message)
+		res, rpcErr = c.txPoolBesu(ctx, from)
+	}
+	if rpcErr != nil {
+		if rpcErr.Code == rpcCodeMethodNotFound {
+			return nil, ffcapi.ErrorReasonNotFound, i18n.NewError(ctx, msgs.MsgTxPoolNotAvailable, rpcErr.Message)
+		}
+		return nil, "", rpcErr.Error()
+	}
+
+	sort.Slice(res.Transactions, func(i, j int) bool {
+		ti, tj := res.Transactions[i], res.Transactions[j]
+		if ti.From != tj.From {
+			return ti.From < tj.From
+		}
+		return ti.Nonce.Int64() < tj.Nonce.Int64()
+	})
+	return res, "", nil
+
+}
+
+func (c *ethConnector) txPoolGeth(ctx context.Context, from *ethtypes.Address0xHex) (*TxPoolResponse, *rpcbackend.RPCError) {
+	var status txPoolStatusJSONRPC
+	if rpcErr := c.backend.CallRPC(ctx, &status, "txpool_status"); rpcErr != nil {
+		return nil, rpcErr
+	}
+	res := &TxPoolResponse{
+		Pending:      status.Pending.BigInt().Int64(),
+		Queued:       status.Queued.BigInt().Int64(),
+		Transactions: []*TxPoolTransaction{},
+	}
+
+	// Transactions are grouped by status, then nonce - and by sender as well, unless querying a single sender
+	addTransactions := func(status TxPoolStatus, byNonce map[string]*txInfoJSONRPC) {
+		for _, tx := range byNonce {
+			res.Transactions = append(res.Transactions, c.txPoolTransaction(tx, status))
+		}
+	}
+	if from != nil {
+		var content map[TxPoolStatus]map[string]*txInfoJSONRPC
+		if rpcErr := c.backend.CallRPC(ctx, &content, "txpool_contentFrom", from); rpcErr != nil {
+			return nil, rpcErr
+		}
+		addTransactions(TxPoolPending, content[TxPoolPending])
+		addTransactions(TxPoolQueued, content[TxPoolQueued])
+	} else {
+		var content map[TxPoolStatus]map[string]map[string]*txInfoJSONRPC
+		if rpcErr := c.backend.CallRPC(ctx, &content, "txpool_content"); rpcErr != nil {
+			return nil, rpcErr
+		}
+		for _, status := range []TxPoolStatus{TxPoolPending, TxPoolQueued} {
+			for _, byNonce := range content[status] {
+				addTransactions(status, byNonce)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (c *ethConnector) txPoolBesu(ctx context.Context, from *ethtypes.Address0xHex) (*TxPoolResponse, *rpcbackend.RPCError) {
+	var stats besuTxPoolStatisticsJSONRPC
+	if rpcErr := c.backend.CallRPC(ctx, &stats, "txpool_besuStatistics"); rpcErr != nil {
+		return nil, rpcErr
+	}
+	res := &TxPoolResponse{
+		Pending:      stats.LocalCount + stats.RemoteCount,
+		Transactions: []*TxPoolTransaction{},
+	}
+
+	// Besu only returns the hashes, so we query each transaction to find the sender and gas price.
+	// We do not use the transaction cache, as that is for transactions that have been mined.
+	var pooled []*besuTxPoolTransactionJSONRPC
+	if rpcErr := c.backend.CallRPC(ctx, &pooled, "txpool_besuTransactions"); rpcErr != nil {
+		return nil, rpcErr
+	}
+	for _, ptx := range pooled {
+		var tx *txInfoJSONRPC
+		if rpcErr := c.backend.CallRPC(ctx, &tx, "eth_getTransactionByHash", ptx.Hash); rpcErr != nil {
+			return nil, rpcErr
+		}
+		if tx == nil || tx.BlockNumber != nil {
+			continue // mined or dropped since we listed the pool
+		}
+		if from == nil || (tx.From != nil && *tx.From == *from) {
+			res.Transactions = append(res.Transactions, c.txPoolTransaction(tx, TxPoolPending))
+		}
+	}
+	return res, nil
+}
+
+func (c *ethConnector) txPoolTransaction(tx *txInfoJSONRPC, status TxPoolStatus) *TxPoolTransaction {
+	ptx := &TxPoolTransaction{
+		Hash:                 tx.Hash.String(),
+		Nonce:                (*fftypes.FFBigInt)(tx.Nonce),
+		Gas:                  (*fftypes.FFBigInt)(tx.Gas),
+		GasPrice:             (*fftypes.FFBigInt)(tx.GasPrice),
+		MaxFeePerGas:         (*fftypes.FFBigInt)(tx.MaxFeePerGas),
+		MaxPriorityFeePerGas: (*fftypes.FFBigInt)(tx.MaxPriorityFeePerGas),
+		Status:               status,
+	}
+	if tx.From != nil {
+		ptx.From = c.formatAddress(tx.From)
+	}
+	if tx.To != nil {
+		ptx.To = c.formatAddress(tx.To)
+	}
+	return ptx
+}
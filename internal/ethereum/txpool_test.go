@@ -0,0 +1,216 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const sampleGethTxPoolContent = `{
+	"pending": {
+		"0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8": {
+			"11": {"hash": "0x1b", "from": "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8", "to": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431", "nonce": "0xb", "gas": "0x5208", "maxFeePerGas": "0x77359400", "maxPriorityFeePerGas": "0x3b9aca00"},
+			"10": {"hash": "0x1a", "from": "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8", "to": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431", "nonce": "0xa", "gas": "0x5208", "gasPrice": "0x3b9aca00"}
+		},
+		"0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4": {
+			"1": {"hash": "0x2a", "from": "0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4", "nonce": "0x1", "gas": "0x5208", "gasPrice": "0x1"}
+		}
+	},
+	"queued": {
+		"0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8": {
+			"13": {"hash": "0x1c", "from": "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8", "nonce": "0xd", "gas": "0x5208", "gasPrice": "0x3b9aca00"}
+		}
+	}
+}`
+
+func methodNotFound(method string) *rpcbackend.RPCError {
+	return &rpcbackend.RPCError{Code: rpcCodeMethodNotFound, Message: "the method " + method + " does not exist/is not available"}
+}
+
+func TestTxPoolGeth(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_status").Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(`{"pending":"0x3","queued":"0x1"}`), args[1])
+		assert.NoError(t, err)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_content").Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(sampleGethTxPoolContent), args[1])
+		assert.NoError(t, err)
+	})
+
+	res, reason, err := c.TxPool(ctx, &TxPoolRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, int64(3), res.Pending)
+	assert.Equal(t, int64(1), res.Queued)
+	assert.Len(t, res.Transactions, 4)
+	assert.Equal(t, "0x2a", res.Transactions[0].Hash)
+	assert.Equal(t, "0x1a", res.Transactions[1].Hash)
+	assert.Equal(t, "0x1b", res.Transactions[2].Hash)
+	assert.Equal(t, "0x1c", res.Transactions[3].Hash)
+	assert.Equal(t, TxPoolQueued, res.Transactions[3].Status)
+
+	b, err := json.Marshal(res.Transactions[2])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"hash": "0x1b",
+		"from": "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8",
+		"to": "0x20355f3e852d4b6a9944ada8d5399ddd3409a431",
+		"nonce": "11",
+		"gas": "21000",
+		"maxFeePerGas": "2000000000",
+		"maxPriorityFeePerGas": "1000000000",
+		"status": "pending"
+	}`, string(b))
+
+}
+
+func TestTxPoolGethFrom(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_status").Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_contentFrom", mock.MatchedBy(func(a *ethtypes.Address0xHex) bool {
+		return a.String() == "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8"
+	})).Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(`{
+			"pending": {"10": {"hash": "0x1a", "from": "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8", "nonce": "0xa"}},
+			"queued": {}
+		}`), args[1])
+		assert.NoError(t, err)
+	})
+
+	res, _, err := c.TxPool(ctx, &TxPoolRequest{From: "0xb480F96c0a3d6E9e9a263e4665a39bFa6c4d01E8"})
+	assert.NoError(t, err)
+	assert.Len(t, res.Transactions, 1)
+	assert.Equal(t, int64(10), res.Transactions[0].Nonce.Int64())
+
+}
+
+func TestTxPoolBesu(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_status").Return(methodNotFound("txpool_status"))
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_besuStatistics").Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(`{"maxSize":4096,"localCount":2,"remoteCount":2}`), args[1])
+		assert.NoError(t, err)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_besuTransactions").Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(`[
+			{"hash":"0x1a","isReceivedFromLocalSource":true,"addedToPoolAt":"2024-01-01T00:00:00Z"},
+			{"hash":"0x2a","isReceivedFromLocalSource":false,"addedToPoolAt":"2024-01-01T00:00:00Z"},
+			{"hash":"0x3a","isReceivedFromLocalSource":false,"addedToPoolAt":"2024-01-01T00:00:00Z"},
+			{"hash":"0x4a","isReceivedFromLocalSource":false,"addedToPoolAt":"2024-01-01T00:00:00Z"}
+		]`), args[1])
+		assert.NoError(t, err)
+	})
+	txByHash := map[string]string{
+		"0x1a": `{"hash": "0x1a", "from": "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8", "nonce": "0xa", "gasPrice": "0x3b9aca00"}`,
+		"0x2a": `{"hash": "0x2a", "from": "0x3968ef051b422d3d1cdc182a88bba8dd922e6fa4", "nonce": "0x1"}`,
+		"0x3a": `{"hash": "0x3a", "from": "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8", "nonce": "0x9", "blockNumber": "0x10"}`,
+		"0x4a": `null`,
+	}
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(txByHash[args[3].(ethtypes.HexBytes0xPrefix).String()]), args[1])
+		assert.NoError(t, err)
+	})
+
+	res, _, err := c.TxPool(ctx, &TxPoolRequest{From: "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), res.Pending)
+	assert.Zero(t, res.Queued)
+	assert.Len(t, res.Transactions, 1)
+	assert.Equal(t, "0x1a", res.Transactions[0].Hash)
+	assert.Equal(t, TxPoolPending, res.Transactions[0].Status)
+	assert.Equal(t, int64(1000000000), res.Transactions[0].GasPrice.Int64())
+
+	res, _, err = c.TxPool(ctx, &TxPoolRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, res.Transactions, 2)
+
+}
+
+func TestTxPoolNotAvailable(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_status").Return(methodNotFound("txpool_status"))
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_besuStatistics").Return(methodNotFound("txpool_besuStatistics"))
+
+	_, reason, err := c.TxPool(ctx, &TxPoolRequest{})
+	assert.Regexp(t, "FF23105", err)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, reason)
+
+}
+
+func TestTxPoolFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_status").Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_content").Return(&rpcbackend.RPCError{Message: "pop"})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_contentFrom", mock.Anything).Return(&rpcbackend.RPCError{Message: "pop"})
+
+	_, reason, err := c.TxPool(ctx, &TxPoolRequest{})
+	assert.Regexp(t, "pop", err)
+	assert.Empty(t, reason)
+
+	_, _, err = c.TxPool(ctx, &TxPoolRequest{From: "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8"})
+	assert.Regexp(t, "pop", err)
+
+	_, reason, err = c.TxPool(ctx, &TxPoolRequest{From: "wrong"})
+	assert.Regexp(t, "FF23019", err)
+	assert.Equal(t, ffcapi.ErrorReasonInvalidInputs, reason)
+
+}
+
+func TestTxPoolBesuFail(t *testing.T) {
+
+	ctx, c, mRPC, done := newTestConnector(t)
+	defer done()
+
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_status").Return(methodNotFound("txpool_status"))
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_besuStatistics").Return(nil)
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_besuTransactions").Return(&rpcbackend.RPCError{Message: "pop"}).Once()
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "txpool_besuTransactions").Return(nil).Run(func(args mock.Arguments) {
+		err := json.Unmarshal([]byte(`[{"hash":"0x1a"}]`), args[1])
+		assert.NoError(t, err)
+	})
+	mRPC.On("CallRPC", mock.Anything, mock.Anything, "eth_getTransactionByHash", mock.Anything).Return(&rpcbackend.RPCError{Message: "bang"})
+
+	_, _, err := c.TxPool(ctx, &TxPoolRequest{})
+	assert.Regexp(t, "pop", err)
+
+	_, _, err = c.TxPool(ctx, &TxPoolRequest{})
+	assert.Regexp(t, "bang", err)
+
+}
@@ -0,0 +1,245 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package extensions provides an optional HTTP server for the operations of the connector that are not
+// part of the ffcapi.API, so are not reachable through the APIs of the transaction manager. Each operation
+// is a POST to /api/v1/{operation} with the JSON request of the operation, returning the JSON response.
+// Operations that deliver notifications stream a line of JSON for each, until the client disconnects.
+//
+// The transactionSendRaw, deployContracts and dependentTransactionSend operations submit transactions
+// outside of the nonce management of the transaction manager, which assigns the nonce of each transaction
+// it sends from a count it keeps per signing key. So they must be used with signing keys that are not
+// also used through the transaction manager, or the nonces of the two conflict.
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/httpserver"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/ethereum"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const (
+	Enabled = "enabled"
+)
+
+const defaultPort = 5010
+
+const apiPrefix = "/api/v1/"
+
+func InitConfig(conf config.Section) {
+	conf.AddKnownKey(Enabled, false)
+	httpserver.InitHTTPConfig(conf, defaultPort)
+	httpserver.InitCORSConfig(conf.SubSection("cors"))
+}
+
+// Server serves the extension operations of the connector
+type Server struct {
+	ctx     context.Context
+	c       ethereum.Extensions
+	reload  func(ctx context.Context) error
+	server  httpserver.HTTPServer
+	onClose chan error
+}
+
+type ConfigReloadRequest struct{}
+
+type ConfigReloadResponse struct{}
+
+type errorResponse struct {
+	Error  string             `json:"error"`
+	Reason ffcapi.ErrorReason `json:"reason,omitempty"`
+}
+
+// NewServer starts listening on the configured address. The reload function re-reads the configuration,
+// as on SIGHUP, for the configReload operation.
+func NewServer(ctx context.Context, c ethereum.Extensions, reload func(ctx context.Context) error, conf config.Section) (*Server, error) {
+	s := &Server{
+		ctx:     log.WithLogField(ctx, "role", "extensions"),
+		c:       c,
+		reload:  reload,
+		onClose: make(chan error, 1),
+	}
+	var err error
+	s.server, err = httpserver.NewHTTPServer(s.ctx, "extensions", s.router(), s.onClose, conf, conf.SubSection("cors"))
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.server.Addr()
+}
+
+// Run serves the APIs until the context is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	go s.server.ServeHTTP(ctx)
+	return <-s.onClose
+}
+
+func (s *Server) router() *mux.Router {
+	r := mux.NewRouter()
+	route(r, "transactionReplace", s.c.TransactionReplace)
+	route(r, "transactionSendRaw", s.c.TransactionSendRaw)
+	route(r, "feeHistory", s.c.FeeHistory)
+	route(r, "eventListenerUpdateAddresses", s.c.EventListenerUpdateAddresses)
+	route(r, "canonicalChain", s.c.CanonicalChain)
+	route(r, "proof", s.c.Proof)
+	route(r, "txPool", s.c.TxPool)
+	route(r, "deployContracts", s.c.DeployContracts)
+	route(r, "acknowledgeReorg", s.c.AcknowledgeReorg)
+	route(r, "transactionByNonce", s.c.TransactionByNonce)
+	route(r, "nodeDiagnostics", s.c.NodeDiagnostics)
+	route(r, "eventStreamPause", s.c.EventStreamPause)
+	route(r, "eventStreamResume", s.c.EventStreamResume)
+	route(r, "encodeCallData", s.c.EncodeCallData)
+	route(r, "decodeCallData", s.c.DecodeCallData)
+	route(r, "privateTransactionStatus", s.c.PrivateTransactionStatus)
+	route(r, "dependentTransactionSend", s.c.DependentTransactionSend)
+	route(r, "reorgStatistics", s.c.ReorgStatistics)
+	route(r, "eventFilterDryRun", s.c.EventFilterDryRun)
+	route(r, "transactionConfirmations", s.c.TransactionConfirmations)
+	route(r, "configReload", s.configReload)
+	stream(r, "newBlockInfoListener", s.newBlockInfoListener)
+	stream(r, "newReceiptListener", s.newReceiptListener)
+	route(r, "receiptWatch", s.c.ReceiptWatch)
+	return r
+}
+
+type NewBlockInfoListenerRequest struct{}
+
+func (s *Server) newBlockInfoListener(ctx context.Context, _ *NewBlockInfoListenerRequest, events chan<- *ethereum.NewBlockInfoEvent) (ffcapi.ErrorReason, error) {
+	_, reason, err := s.c.NewBlockInfoListener(ctx, &ethereum.NewBlockInfoListenerRequest{
+		ID:              fftypes.NewUUID(),
+		ListenerContext: ctx,
+		BlockListener:   events,
+	})
+	return reason, err
+}
+
+type NewReceiptListenerRequest struct {
+	ID *fftypes.UUID `json:"id"` // chosen by the client, to register the transactions to notify with receiptWatch
+}
+
+func (s *Server) newReceiptListener(ctx context.Context, req *NewReceiptListenerRequest, notifications chan<- *ethereum.ReceiptNotification) (ffcapi.ErrorReason, error) {
+	if req.ID == nil {
+		return ffcapi.ErrorReasonInvalidInputs, i18n.NewError(ctx, msgs.MsgMissingReceiptListenerID)
+	}
+	_, reason, err := s.c.NewReceiptListener(ctx, &ethereum.ReceiptListenerRequest{
+		ID:              req.ID,
+		ListenerContext: ctx,
+		ReceiptListener: notifications,
+	})
+	return reason, err
+}
+
+// configReload applies the log level and the tunable settings of the connector from the config file, for
+// deployments that cannot send a SIGHUP to the process - such as many container platforms
+func (s *Server) configReload(ctx context.Context, _ *ConfigReloadRequest) (*ConfigReloadResponse, ffcapi.ErrorReason, error) {
+	if err := s.reload(ctx); err != nil {
+		return nil, ffcapi.ErrorReasonInvalidInputs, err
+	}
+	return &ConfigReloadResponse{}, "", nil
+}
+
+// route serves an operation of the connector, which all take a request and return a response, or an error
+// with the reason for the failure
+func route[Req, Res any](r *mux.Router, operation string, op func(ctx context.Context, req *Req) (*Res, ffcapi.ErrorReason, error)) {
+	r.HandleFunc(apiPrefix+operation, func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		var opReq Req
+		if err := json.NewDecoder(req.Body).Decode(&opReq); err != nil {
+			writeJSON(w, http.StatusBadRequest, &errorResponse{Error: i18n.NewError(ctx, msgs.MsgExtensionsBadRequest, operation, err).Error()})
+		} else if res, reason, err := op(ctx, &opReq); err != nil {
+			log.L(ctx).Errorf("%s failed (reason=%s): %s", operation, reason, err)
+			writeJSON(w, reasonStatus(reason), &errorResponse{Error: err.Error(), Reason: reason})
+		} else {
+			writeJSON(w, http.StatusOK, res)
+		}
+	}).Methods(http.MethodPost)
+}
+
+// stream serves an operation that delivers notifications on a channel, writing each notification as a line
+// of JSON as it is delivered, until the client closes the connection - which cancels the context the listener
+// of the operation was registered with, so removes it
+func stream[Req, N any](r *mux.Router, operation string, op func(ctx context.Context, req *Req, notifications chan<- N) (ffcapi.ErrorReason, error)) {
+	r.HandleFunc(apiPrefix+operation, func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		var opReq Req
+		if err := json.NewDecoder(req.Body).Decode(&opReq); err != nil {
+			writeJSON(w, http.StatusBadRequest, &errorResponse{Error: i18n.NewError(ctx, msgs.MsgExtensionsBadRequest, operation, err).Error()})
+			return
+		}
+		notifications := make(chan N)
+		if reason, err := op(ctx, &opReq, notifications); err != nil {
+			log.L(ctx).Errorf("%s failed (reason=%s): %s", operation, reason, err)
+			writeJSON(w, reasonStatus(reason), &errorResponse{Error: err.Error(), Reason: reason})
+			return
+		}
+		// The stream stays open for as long as the client wants notifications, so the write timeout does not apply
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		for {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+			select {
+			case n := <-notifications:
+				if err := encoder.Encode(n); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				log.L(ctx).Debugf("%s stream closed", operation)
+				return
+			}
+		}
+	}).Methods(http.MethodPost)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// reasonStatus maps the reason returned with an error from the connector to an HTTP status
+func reasonStatus(reason ffcapi.ErrorReason) int {
+	switch reason {
+	case ffcapi.ErrorReasonInvalidInputs:
+		return http.StatusBadRequest
+	case ffcapi.ErrorReasonNotFound:
+		return http.StatusNotFound
+	case ffcapi.ErrorReasonTransactionReverted, ffcapi.ErrorReasonNonceTooLow, ffcapi.ErrorKnownTransaction:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
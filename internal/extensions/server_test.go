@@ -0,0 +1,416 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-evmconnect/internal/ethereum"
+	"github.com/hyperledger/firefly-evmconnect/internal/simulator"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeExtensions records the operations called, and returns an empty response or the configured error.
+// Operations that are not served over HTTP are not implemented, so panic if called.
+type fakeExtensions struct {
+	ethereum.Extensions
+	called        string
+	request       interface{}
+	response      interface{}
+	reason        ffcapi.ErrorReason
+	err           error
+	notifications []interface{} // delivered in order to the channel of a listener operation
+}
+
+func fakeCall[Res any](f *fakeExtensions, operation string, req interface{}) (*Res, ffcapi.ErrorReason, error) {
+	f.called = operation
+	f.request = req
+	if f.err != nil {
+		return nil, f.reason, f.err
+	}
+	if res, ok := f.response.(*Res); ok {
+		return res, "", nil
+	}
+	return new(Res), "", nil
+}
+
+func fakeStream[N any](ctx context.Context, f *fakeExtensions, operation string, req interface{}, notifications chan<- N) (ffcapi.ErrorReason, error) {
+	f.called = operation
+	f.request = req
+	if f.err != nil {
+		return f.reason, f.err
+	}
+	go func() {
+		for _, n := range f.notifications {
+			select {
+			case notifications <- n.(N):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return "", nil
+}
+
+func (f *fakeExtensions) TransactionReplace(_ context.Context, req *ethereum.TransactionReplaceRequest) (*ethereum.TransactionReplaceResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.TransactionReplaceResponse](f, "transactionReplace", req)
+}
+
+func (f *fakeExtensions) TransactionSendRaw(_ context.Context, req *ethereum.TransactionSendRawRequest) (*ethereum.TransactionSendRawResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.TransactionSendRawResponse](f, "transactionSendRaw", req)
+}
+
+func (f *fakeExtensions) FeeHistory(_ context.Context, req *ethereum.FeeHistoryRequest) (*ethereum.FeeHistoryResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.FeeHistoryResponse](f, "feeHistory", req)
+}
+
+func (f *fakeExtensions) EventListenerUpdateAddresses(_ context.Context, req *ethereum.EventListenerAddressesRequest) (*ethereum.EventListenerAddressesResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.EventListenerAddressesResponse](f, "eventListenerUpdateAddresses", req)
+}
+
+func (f *fakeExtensions) CanonicalChain(_ context.Context, req *ethereum.CanonicalChainRequest) (*ethereum.CanonicalChainResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.CanonicalChainResponse](f, "canonicalChain", req)
+}
+
+func (f *fakeExtensions) Proof(_ context.Context, req *ethereum.ProofRequest) (*ethereum.ProofResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.ProofResponse](f, "proof", req)
+}
+
+func (f *fakeExtensions) TxPool(_ context.Context, req *ethereum.TxPoolRequest) (*ethereum.TxPoolResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.TxPoolResponse](f, "txPool", req)
+}
+
+func (f *fakeExtensions) DeployContracts(_ context.Context, req *ethereum.DeployContractsRequest) (*ethereum.DeployContractsResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.DeployContractsResponse](f, "deployContracts", req)
+}
+
+func (f *fakeExtensions) AcknowledgeReorg(_ context.Context, req *ethereum.AcknowledgeReorgRequest) (*ethereum.AcknowledgeReorgResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.AcknowledgeReorgResponse](f, "acknowledgeReorg", req)
+}
+
+func (f *fakeExtensions) TransactionByNonce(_ context.Context, req *ethereum.TransactionByNonceRequest) (*ethereum.TransactionByNonceResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.TransactionByNonceResponse](f, "transactionByNonce", req)
+}
+
+func (f *fakeExtensions) NodeDiagnostics(_ context.Context, req *ethereum.NodeDiagnosticsRequest) (*ethereum.NodeDiagnosticsResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.NodeDiagnosticsResponse](f, "nodeDiagnostics", req)
+}
+
+func (f *fakeExtensions) EventStreamPause(_ context.Context, req *ethereum.EventStreamPauseRequest) (*ethereum.EventStreamPauseResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.EventStreamPauseResponse](f, "eventStreamPause", req)
+}
+
+func (f *fakeExtensions) EventStreamResume(_ context.Context, req *ethereum.EventStreamResumeRequest) (*ethereum.EventStreamPauseResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.EventStreamPauseResponse](f, "eventStreamResume", req)
+}
+
+func (f *fakeExtensions) EncodeCallData(_ context.Context, req *ethereum.EncodeCallDataRequest) (*ethereum.EncodeCallDataResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.EncodeCallDataResponse](f, "encodeCallData", req)
+}
+
+func (f *fakeExtensions) DecodeCallData(_ context.Context, req *ethereum.DecodeCallDataRequest) (*ethereum.DecodedCallData, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.DecodedCallData](f, "decodeCallData", req)
+}
+
+func (f *fakeExtensions) PrivateTransactionStatus(_ context.Context, req *ethereum.PrivateTransactionStatusRequest) (*ethereum.PrivateTransactionStatusResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.PrivateTransactionStatusResponse](f, "privateTransactionStatus", req)
+}
+
+func (f *fakeExtensions) DependentTransactionSend(_ context.Context, req *ethereum.DependentTransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ffcapi.TransactionSendResponse](f, "dependentTransactionSend", req)
+}
+
+func (f *fakeExtensions) ReorgStatistics(_ context.Context, req *ethereum.ReorgStatisticsRequest) (*ethereum.ReorgStatisticsResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.ReorgStatisticsResponse](f, "reorgStatistics", req)
+}
+
+func (f *fakeExtensions) EventFilterDryRun(_ context.Context, req *ethereum.EventFilterDryRunRequest) (*ethereum.EventFilterDryRunResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.EventFilterDryRunResponse](f, "eventFilterDryRun", req)
+}
+
+func (f *fakeExtensions) TransactionConfirmations(_ context.Context, req *ethereum.TransactionConfirmationsRequest) (*ethereum.TransactionConfirmationsResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.TransactionConfirmationsResponse](f, "transactionConfirmations", req)
+}
+
+func (f *fakeExtensions) NewBlockInfoListener(_ context.Context, req *ethereum.NewBlockInfoListenerRequest) (*ethereum.NewBlockInfoListenerResponse, ffcapi.ErrorReason, error) {
+	reason, err := fakeStream(req.ListenerContext, f, "newBlockInfoListener", req, req.BlockListener)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &ethereum.NewBlockInfoListenerResponse{}, "", nil
+}
+
+func (f *fakeExtensions) NewReceiptListener(_ context.Context, req *ethereum.ReceiptListenerRequest) (*ethereum.ReceiptListenerResponse, ffcapi.ErrorReason, error) {
+	reason, err := fakeStream(req.ListenerContext, f, "newReceiptListener", req, req.ReceiptListener)
+	if err != nil {
+		return nil, reason, err
+	}
+	return &ethereum.ReceiptListenerResponse{}, "", nil
+}
+
+func (f *fakeExtensions) ReceiptWatch(_ context.Context, req *ethereum.ReceiptWatchRequest) (*ethereum.ReceiptWatchResponse, ffcapi.ErrorReason, error) {
+	return fakeCall[ethereum.ReceiptWatchResponse](f, "receiptWatch", req)
+}
+
+func newTestServer(t *testing.T, c ethereum.Extensions, reload func(ctx context.Context) error) (string, func()) {
+	config.RootConfigReset()
+	conf := config.RootSection("extensions")
+	InitConfig(conf)
+	conf.Set("port", 0)
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	s, err := NewServer(ctx, c, reload, conf)
+	assert.NoError(t, err)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := s.Run(ctx)
+		assert.NoError(t, err)
+	}()
+	return fmt.Sprintf("http://%s%s", s.Addr(), apiPrefix), func() {
+		cancelCtx()
+		<-done
+	}
+}
+
+func post(t *testing.T, url, body string, result interface{}) int {
+	res, err := http.Post(url, "application/json", strings.NewReader(body))
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	err = json.NewDecoder(res.Body).Decode(result)
+	assert.NoError(t, err)
+	return res.StatusCode
+}
+
+// routedOperations are the operations served over HTTP, with a sample request body for each
+var routedOperations = []struct {
+	operation string
+	body      string
+}{
+	{"transactionReplace", `{"transactionHash":"0x12345"}`},
+	{"transactionSendRaw", `{"transactionData":"0x1234"}`},
+	{"feeHistory", `{"blockCount":10}`},
+	{"eventListenerUpdateAddresses", `{"add":["0x20355f3e852d4b6a9944ada8d5399ddd3409a431"]}`},
+	{"canonicalChain", `{}`},
+	{"proof", `{"address":"0x20355f3e852d4b6a9944ada8d5399ddd3409a431"}`},
+	{"txPool", `{"from":"0x20355f3e852d4b6a9944ada8d5399ddd3409a431"}`},
+	{"deployContracts", `{"from":"0x20355f3e852d4b6a9944ada8d5399ddd3409a431","contracts":[]}`},
+	{"acknowledgeReorg", `{}`},
+	{"transactionByNonce", `{"from":"0x20355f3e852d4b6a9944ada8d5399ddd3409a431","nonce":"10"}`},
+	{"nodeDiagnostics", `{}`},
+	{"eventStreamPause", `{"streamId":"6f5b8e4c-5e3a-4b7a-9d2c-1a2b3c4d5e6f","drain":true}`},
+	{"eventStreamResume", `{"streamId":"6f5b8e4c-5e3a-4b7a-9d2c-1a2b3c4d5e6f"}`},
+	{"encodeCallData", `{"method":{"type":"function","name":"set","inputs":[{"name":"x","type":"uint256"}]},"params":[12345]}`},
+	{"decodeCallData", `{"callData":"0x60fe47b10000000000000000000000000000000000000000000000000000000000003039","method":{"type":"function","name":"set","inputs":[{"name":"x","type":"uint256"}]}}`},
+	{"privateTransactionStatus", `{"transactionHash":"0x3d1b1a7f4d6e3c0a2b9f8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a39"}`},
+	{"dependentTransactionSend", `{"id":"op2","dependsOn":["op1"],"from":"0x20355f3e852d4b6a9944ada8d5399ddd3409a431","to":"0x497eedc4299dea2f2a364be10025d0ad0f702de3","nonce":"10","gas":"100000","transactionData":"0x"}`},
+	{"reorgStatistics", `{}`},
+	{"eventFilterDryRun", `{"filters":[{"event":{"type":"event","name":"Changed","inputs":[]}}],"fromBlock":"1000","toBlock":"1999","sampleSize":5}`},
+	{"transactionConfirmations", `{"transactionHash":"0x3d1b1a7f4d6e3c0a2b9f8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a39","requiredConfirmations":20}`},
+	{"receiptWatch", `{"listenerId":"6f5b8e4c-5e3a-4b7a-9d2c-1a2b3c4d5e6f","transactionHashes":["0x3d1b1a7f4d6e3c0a2b9f8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a39"]}`},
+}
+
+func TestRoutedOperations(t *testing.T) {
+	f := &fakeExtensions{}
+	url, done := newTestServer(t, f, nil)
+	defer done()
+
+	for _, ro := range routedOperations {
+		var res map[string]interface{}
+		status := post(t, url+ro.operation, ro.body, &res)
+		assert.Equal(t, http.StatusOK, status, ro.operation)
+		assert.Equal(t, ro.operation, f.called)
+	}
+}
+
+func TestTransactionReplace(t *testing.T) {
+	f := &fakeExtensions{
+		response: &ethereum.TransactionReplaceResponse{OriginalTransactionHash: "0x12345", TransactionHash: "0x67890"},
+	}
+	url, done := newTestServer(t, f, nil)
+	defer done()
+
+	var res ethereum.TransactionReplaceResponse
+	status := post(t, url+"transactionReplace", `{"transactionHash":"0x12345"}`, &res)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "0x12345", f.request.(*ethereum.TransactionReplaceRequest).TransactionHash)
+	assert.Equal(t, "0x67890", res.TransactionHash)
+}
+
+func TestConfigReload(t *testing.T) {
+	reloadErrs := make(chan error, 2)
+	url, done := newTestServer(t, &fakeExtensions{}, func(ctx context.Context) error {
+		return <-reloadErrs
+	})
+	defer done()
+
+	reloadErrs <- nil
+	var res ConfigReloadResponse
+	status := post(t, url+"configReload", `{}`, &res)
+	assert.Equal(t, http.StatusOK, status)
+
+	reloadErrs <- fmt.Errorf("pop")
+	var errRes errorResponse
+	status = post(t, url+"configReload", `{}`, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "pop", errRes.Error)
+	assert.Empty(t, reloadErrs)
+}
+
+func TestNewBlockInfoListenerStream(t *testing.T) {
+	f := &fakeExtensions{notifications: []interface{}{
+		&ethereum.NewBlockInfoEvent{Blocks: []*ethereum.NewBlockInfo{{BlockHash: "0x12345"}}},
+		&ethereum.NewBlockInfoEvent{Blocks: []*ethereum.NewBlockInfo{{BlockHash: "0x67890"}}},
+	}}
+	url, done := newTestServer(t, f, nil)
+	defer done()
+
+	res, err := http.Post(url+"newBlockInfoListener", "application/json", strings.NewReader(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+	decoder := json.NewDecoder(res.Body)
+	for _, blockHash := range []string{"0x12345", "0x67890"} {
+		var event ethereum.NewBlockInfoEvent
+		err = decoder.Decode(&event)
+		assert.NoError(t, err)
+		assert.Equal(t, blockHash, event.Blocks[0].BlockHash)
+	}
+
+	// Closing the stream removes the listener
+	res.Body.Close()
+	<-f.request.(*ethereum.NewBlockInfoListenerRequest).ListenerContext.Done()
+}
+
+func TestNewReceiptListenerStream(t *testing.T) {
+	f := &fakeExtensions{notifications: []interface{}{
+		&ethereum.ReceiptNotification{TransactionHash: "0x12345", BlockHash: "0x67890"},
+	}}
+	url, done := newTestServer(t, f, nil)
+	defer done()
+
+	var errRes errorResponse
+	status := post(t, url+"newReceiptListener", `{}`, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Regexp(t, "FF23203", errRes.Error)
+
+	res, err := http.Post(url+"newReceiptListener", "application/json", strings.NewReader(`{"id":"6f5b8e4c-5e3a-4b7a-9d2c-1a2b3c4d5e6f"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var n ethereum.ReceiptNotification
+	err = json.NewDecoder(res.Body).Decode(&n)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x12345", n.TransactionHash)
+	assert.Equal(t, "6f5b8e4c-5e3a-4b7a-9d2c-1a2b3c4d5e6f", f.request.(*ethereum.ReceiptListenerRequest).ID.String())
+
+	res.Body.Close()
+	<-f.request.(*ethereum.ReceiptListenerRequest).ListenerContext.Done()
+}
+
+func TestOperationErrors(t *testing.T) {
+	url, done := newTestServer(t, &fakeExtensions{
+		reason: ffcapi.ErrorReasonNotFound,
+		err:    fmt.Errorf("pop"),
+	}, nil)
+	defer done()
+
+	var errRes errorResponse
+	status := post(t, url+"transactionReplace", `{"transactionHash":"0x12345"}`, &errRes)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "pop", errRes.Error)
+	assert.Equal(t, ffcapi.ErrorReasonNotFound, errRes.Reason)
+
+	status = post(t, url+"transactionReplace", `!json`, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Regexp(t, "FF23194.*transactionReplace", errRes.Error)
+
+	status = post(t, url+"newBlockInfoListener", `{}`, &errRes)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "pop", errRes.Error)
+
+	status = post(t, url+"newBlockInfoListener", `!json`, &errRes)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Regexp(t, "FF23194.*newBlockInfoListener", errRes.Error)
+
+	for reason, expected := range map[ffcapi.ErrorReason]int{
+		ffcapi.ErrorReasonInvalidInputs:       http.StatusBadRequest,
+		ffcapi.ErrorReasonTransactionReverted: http.StatusConflict,
+		ffcapi.ErrorReasonNonceTooLow:         http.StatusConflict,
+		ffcapi.ErrorKnownTransaction:          http.StatusConflict,
+		ffcapi.ErrorReasonDownstreamDown:      http.StatusInternalServerError,
+	} {
+		assert.Equal(t, expected, reasonStatus(reason))
+	}
+}
+
+func TestNewServerBadConfig(t *testing.T) {
+	config.RootConfigReset()
+	conf := config.RootSection("extensions")
+	InitConfig(conf)
+	conf.Set("address", ":::::::wrong")
+	_, err := NewServer(context.Background(), &fakeExtensions{}, nil, conf)
+	assert.Regexp(t, "FF00151", err)
+}
+
+func TestSendOperationsConflictWithManagedNonces(t *testing.T) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+	node := httptest.NewServer(simulator.NewSimulator(ctx, &simulator.Script{Seed: "test", ChainID: 1337}))
+	defer node.Close()
+
+	config.RootConfigReset()
+	conf := config.RootSection("connector")
+	ethereum.InitConfig(conf)
+	conf.Set(ffresty.HTTPConfigURL, node.URL)
+	c, err := ethereum.NewEthereumConnector(ctx, conf)
+	assert.NoError(t, err)
+
+	url, done := newTestServer(t, c.(ethereum.Extensions), nil)
+	defer done()
+
+	// The transaction manager assigns the next nonce of the signer
+	from := "0x20355f3e852d4b6a9944ada8d5399ddd3409a431"
+	nonceRes, _, err := c.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{Signer: from})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), nonceRes.Nonce.Int64())
+
+	// A send through the extensions API from the same signer takes that nonce
+	var sendRes ffcapi.TransactionSendResponse
+	status := post(t, url+"dependentTransactionSend", `{"id":"op1","from":"`+from+`","to":"0x497eedc4299dea2f2a364be10025d0ad0f702de3","nonce":"0","gas":"100000","transactionData":"0x"}`, &sendRes)
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, sendRes.TransactionHash)
+
+	// So the transaction the transaction manager then sends with it is refused by the node
+	req := &ffcapi.TransactionSendRequest{TransactionHeaders: ffcapi.TransactionHeaders{
+		From:  from,
+		To:    "0x497eedc4299dea2f2a364be10025d0ad0f702de3",
+		Nonce: nonceRes.Nonce,
+		Gas:   fftypes.NewFFBigInt(50000),
+	}, TransactionData: "0x"}
+	_, reason, err := c.TransactionSend(ctx, req)
+	assert.Error(t, err)
+	assert.Contains(t, []ffcapi.ErrorReason{ffcapi.ErrorReasonNonceTooLow, ffcapi.ErrorKnownTransaction}, reason)
+}
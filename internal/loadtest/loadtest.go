@@ -0,0 +1,225 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loadtest submits a steady rate of no-op transactions through the same TransactionPrepare
+// and TransactionSend path used by the transaction manager, and reports the latency of each step.
+// It is intended for sizing the connector and node against a test chain before production cutover.
+package loadtest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+const (
+	Enabled     = "enabled"
+	From        = "from"
+	To          = "to"
+	Rate        = "rate"
+	Duration    = "duration"
+	Gas         = "gas"
+	MaxInFlight = "maxInFlight"
+)
+
+// The method invoked on the target address. Calling a function on an address without code succeeds
+// and does nothing, so the default target of the sending address itself needs no contract deployment.
+const noopMethod = `{"type":"function","name":"noop","inputs":[],"outputs":[]}`
+
+// Connector is the subset of the FFCAPI used by the load test
+type Connector interface {
+	NextNonceForSigner(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) (*ffcapi.NextNonceForSignerResponse, ffcapi.ErrorReason, error)
+	TransactionPrepare(ctx context.Context, req *ffcapi.TransactionPrepareRequest) (*ffcapi.TransactionPrepareResponse, ffcapi.ErrorReason, error)
+	TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error)
+}
+
+type Config struct {
+	From        string
+	To          string
+	Rate        int // transactions per second
+	Duration    time.Duration
+	Gas         *fftypes.FFBigInt // estimated for each transaction if not set
+	MaxInFlight int
+}
+
+func InitConfig(conf config.Section) {
+	conf.AddKnownKey(Enabled, false)
+	conf.AddKnownKey(From)
+	conf.AddKnownKey(To)
+	conf.AddKnownKey(Rate, 10)
+	conf.AddKnownKey(Duration, "1m")
+	conf.AddKnownKey(Gas)
+	conf.AddKnownKey(MaxInFlight, 100)
+}
+
+// ReadConfig fails unless the load test has been explicitly enabled, as it submits real transactions
+func ReadConfig(ctx context.Context, conf config.Section) (*Config, error) {
+	if !conf.GetBool(Enabled) {
+		return nil, i18n.NewError(ctx, msgs.MsgLoadTestNotEnabled)
+	}
+	cfg := &Config{
+		From:        conf.GetString(From),
+		To:          conf.GetString(To),
+		Rate:        conf.GetInt(Rate),
+		Duration:    conf.GetDuration(Duration),
+		MaxInFlight: conf.GetInt(MaxInFlight),
+	}
+	if cfg.From == "" {
+		return nil, i18n.NewError(ctx, msgs.MsgLoadTestMissingFrom)
+	}
+	if cfg.To == "" {
+		cfg.To = cfg.From
+	}
+	if cfg.Rate <= 0 || cfg.MaxInFlight <= 0 {
+		return nil, i18n.NewError(ctx, msgs.MsgLoadTestBadRate, cfg.Rate, cfg.MaxInFlight)
+	}
+	if gas := conf.GetInt64(Gas); gas > 0 {
+		cfg.Gas = fftypes.NewFFBigInt(gas)
+	}
+	return cfg, nil
+}
+
+type loadTest struct {
+	ctx         context.Context
+	c           Connector
+	cfg         *Config
+	report      *Report
+	mux         sync.Mutex
+	inFlight    sync.WaitGroup
+	slots       chan struct{}
+	resyncNonce atomic.Bool
+}
+
+// Run submits transactions at the configured rate until the duration has elapsed, or the context is cancelled,
+// then waits for the submissions in flight before returning the report
+func Run(ctx context.Context, c Connector, cfg *Config) (*Report, error) {
+	lt := &loadTest{
+		ctx:    context.WithoutCancel(ctx), // stopping early allows the transactions in flight to complete
+		c:      c,
+		cfg:    cfg,
+		report: newReport(),
+		slots:  make(chan struct{}, cfg.MaxInFlight),
+	}
+
+	nonce, err := lt.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	log.L(ctx).Infof("Load test submitting %d transactions per second from %s for %s", cfg.Rate, cfg.From, cfg.Duration)
+	start := time.Now()
+	ticker := time.NewTicker(time.Second / time.Duration(cfg.Rate))
+	defer ticker.Stop()
+	deadline := time.After(cfg.Duration)
+	for running := true; running; {
+		select {
+		case <-ticker.C:
+			if lt.resyncNonce.CompareAndSwap(true, false) {
+				if n, err := lt.nextNonce(); err == nil {
+					nonce = n
+				}
+			}
+			select {
+			case lt.slots <- struct{}{}:
+				lt.inFlight.Add(1)
+				go lt.submit(nonce)
+				nonce++
+			default:
+				lt.mux.Lock()
+				lt.report.Skipped++
+				lt.mux.Unlock()
+			}
+		case <-deadline:
+			running = false
+		case <-ctx.Done():
+			log.L(ctx).Infof("Load test stopped early")
+			running = false
+		}
+	}
+	lt.inFlight.Wait()
+	lt.report.Elapsed = fftypes.FFDuration(time.Since(start))
+	return lt.report, nil
+}
+
+func (lt *loadTest) nextNonce() (int64, error) {
+	res, _, err := lt.c.NextNonceForSigner(lt.ctx, &ffcapi.NextNonceForSignerRequest{Signer: lt.cfg.From})
+	if err != nil {
+		return -1, err
+	}
+	return res.Nonce.Int64(), nil
+}
+
+func (lt *loadTest) submit(nonce int64) {
+	defer func() {
+		<-lt.slots
+		lt.inFlight.Done()
+	}()
+
+	headers := ffcapi.TransactionHeaders{
+		From:  lt.cfg.From,
+		To:    lt.cfg.To,
+		Nonce: fftypes.NewFFBigInt(nonce),
+		Gas:   lt.cfg.Gas,
+	}
+	prepareStart := time.Now()
+	prepared, _, err := lt.c.TransactionPrepare(lt.ctx, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: headers,
+			Method:             fftypes.JSONAnyPtr(noopMethod),
+		},
+	})
+	prepareTime := time.Since(prepareStart)
+	if err != nil {
+		lt.failed(nonce, err)
+		return
+	}
+
+	headers.Gas = prepared.Gas
+	sendStart := time.Now()
+	_, _, err = lt.c.TransactionSend(lt.ctx, &ffcapi.TransactionSendRequest{
+		TransactionHeaders: headers,
+		TransactionData:    prepared.TransactionData,
+	})
+	sendTime := time.Since(sendStart)
+	if err != nil {
+		lt.failed(nonce, err)
+		return
+	}
+
+	lt.mux.Lock()
+	defer lt.mux.Unlock()
+	lt.report.Submitted++
+	lt.report.Prepare.record(prepareTime)
+	lt.report.Send.record(sendTime)
+	lt.report.Total.record(prepareTime + sendTime)
+}
+
+func (lt *loadTest) failed(nonce int64, err error) {
+	log.L(lt.ctx).Warnf("Load test transaction with nonce %d failed: %s", nonce, err)
+	// Later transactions would be stuck behind the nonce gap, so re-query the nonce from the node
+	lt.resyncNonce.Store(true)
+	lt.mux.Lock()
+	defer lt.mux.Unlock()
+	lt.report.Failed++
+}
@@ -0,0 +1,182 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testFrom = "0xb480f96c0a3d6e9e9a263e4665a39bfa6c4d01e8"
+
+func newTestConfig(t *testing.T, setup func(conf config.Section)) (*Config, error) {
+	config.RootConfigReset()
+	conf := config.RootSection("loadtest")
+	InitConfig(conf)
+	conf.Set(Enabled, true)
+	conf.Set(From, testFrom)
+	setup(conf)
+	return ReadConfig(context.Background(), conf)
+}
+
+func TestReadConfig(t *testing.T) {
+
+	cfg, err := newTestConfig(t, func(conf config.Section) {
+		conf.Set(Gas, 50000)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, testFrom, cfg.To)
+	assert.Equal(t, int64(50000), cfg.Gas.Int64())
+	assert.Equal(t, 10, cfg.Rate)
+	assert.Equal(t, time.Minute, cfg.Duration)
+
+	_, err = newTestConfig(t, func(conf config.Section) {
+		conf.Set(Enabled, false)
+	})
+	assert.Regexp(t, "FF23097", err)
+
+	_, err = newTestConfig(t, func(conf config.Section) {
+		conf.Set(From, "")
+	})
+	assert.Regexp(t, "FF23098", err)
+
+	_, err = newTestConfig(t, func(conf config.Section) {
+		conf.Set(Rate, 0)
+	})
+	assert.Regexp(t, "FF23099", err)
+
+}
+
+func TestRunLoadTest(t *testing.T) {
+
+	cfg, err := newTestConfig(t, func(conf config.Section) {
+		conf.Set(Rate, 1000)
+		conf.Set(Duration, "50ms")
+	})
+	assert.NoError(t, err)
+
+	mc := &ffcapimocks.API{}
+	mc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{Signer: testFrom}).
+		Return(&ffcapi.NextNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(10)}, ffcapi.ErrorReason(""), nil)
+	mc.On("TransactionPrepare", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionPrepareRequest) bool {
+		return req.From == testFrom && req.To == testFrom && req.Method.String() == noopMethod
+	})).Return(&ffcapi.TransactionPrepareResponse{Gas: fftypes.NewFFBigInt(21064), TransactionData: "0x5dfc2e4a"}, ffcapi.ErrorReason(""), nil)
+	// The first transaction fails, which means the nonce is re-queried
+	mc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.Nonce.Int64() == 10
+	})).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("pop")).Once()
+	mc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.Gas.Int64() == 21064 && req.TransactionData == "0x5dfc2e4a"
+	})).Return(&ffcapi.TransactionSendResponse{TransactionHash: "0x12345"}, ffcapi.ErrorReason(""), nil)
+
+	report, err := Run(context.Background(), mc, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Failed)
+	assert.Greater(t, report.Submitted, 0)
+	assert.Equal(t, report.Submitted, len(report.Total.samples))
+	assert.Greater(t, time.Duration(report.Elapsed), 50*time.Millisecond)
+	mc.AssertCalled(t, "NextNonceForSigner", mock.Anything, mock.Anything)
+
+	var out strings.Builder
+	report.Write(&out)
+	assert.Contains(t, out.String(), "Failed: 1")
+	assert.Contains(t, out.String(), "Latency (total)")
+
+}
+
+func TestRunLoadTestPrepareFailSkipAndCancel(t *testing.T) {
+
+	cfg, err := newTestConfig(t, func(conf config.Section) {
+		conf.Set(Rate, 1000)
+		conf.Set(MaxInFlight, 1)
+		conf.Set(Gas, 50000)
+	})
+	assert.NoError(t, err)
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	prepareCalled := make(chan struct{})
+	mc := &ffcapimocks.API{}
+	mc.On("NextNonceForSigner", mock.Anything, mock.Anything).
+		Return(&ffcapi.NextNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(10)}, ffcapi.ErrorReason(""), nil).Once()
+	mc.On("NextNonceForSigner", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonInvalidInputs, fmt.Errorf("pop")).Run(func(args mock.Arguments) {
+		// Block long enough for later transactions to be skipped
+		select {
+		case prepareCalled <- struct{}{}:
+			time.Sleep(10 * time.Millisecond)
+		default:
+		}
+	})
+
+	go func() {
+		<-prepareCalled
+		time.Sleep(20 * time.Millisecond)
+		cancelCtx()
+	}()
+	report, err := Run(ctx, mc, cfg)
+	assert.NoError(t, err)
+	assert.Zero(t, report.Submitted)
+	assert.Greater(t, report.Failed, 0)
+	assert.Greater(t, report.Skipped, 0)
+
+}
+
+func TestRunLoadTestNonceFail(t *testing.T) {
+
+	cfg, err := newTestConfig(t, func(conf config.Section) {})
+	assert.NoError(t, err)
+
+	mc := &ffcapimocks.API{}
+	mc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	_, err = Run(context.Background(), mc, cfg)
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestLatencyHistogram(t *testing.T) {
+
+	h := newLatencyHistogram()
+	assert.Zero(t, h.Percentile(50))
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+	h.record(time.Minute)
+	assert.Equal(t, 1, h.Counts[0])
+	assert.Equal(t, 1, h.Counts[1])
+	assert.Equal(t, 1, h.Counts[len(h.Counts)-1])
+	assert.Equal(t, time.Millisecond, h.Percentile(0))
+	assert.Equal(t, 51*time.Millisecond, h.Percentile(50))
+	assert.Equal(t, time.Minute, h.Percentile(100))
+
+	var out strings.Builder
+	(&Report{Prepare: h, Send: h, Total: h}).Write(&out)
+	assert.Contains(t, out.String(), "> 10s      1")
+	assert.NotContains(t, out.String(), "Throughput")
+
+}
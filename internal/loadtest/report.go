@@ -0,0 +1,123 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+// The upper bounds of the histogram buckets, with a final bucket for anything slower
+var histogramBuckets = []time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+type Report struct {
+	Submitted int                `json:"submitted"`
+	Failed    int                `json:"failed"`
+	Skipped   int                `json:"skipped"` // not submitted as the maximum number were already in flight
+	Elapsed   fftypes.FFDuration `json:"elapsed"`
+	Prepare   *LatencyHistogram  `json:"prepare"`
+	Send      *LatencyHistogram  `json:"send"`
+	Total     *LatencyHistogram  `json:"total"`
+}
+
+type LatencyHistogram struct {
+	Counts  []int           `json:"counts"` // one for each bucket, plus the final bucket for anything slower
+	samples []time.Duration // kept for calculating the percentiles
+}
+
+func newReport() *Report {
+	return &Report{
+		Prepare: newLatencyHistogram(),
+		Send:    newLatencyHistogram(),
+		Total:   newLatencyHistogram(),
+	}
+}
+
+func newLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{
+		Counts: make([]int, len(histogramBuckets)+1),
+	}
+}
+
+func (h *LatencyHistogram) record(d time.Duration) {
+	bucket := sort.Search(len(histogramBuckets), func(i int) bool { return d <= histogramBuckets[i] })
+	h.Counts[bucket]++
+	h.samples = append(h.samples, d)
+}
+
+// Percentile returns the latency under which the given percentage of samples fall
+func (h *LatencyHistogram) Percentile(p float64) time.Duration {
+	if len(h.samples) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(h.samples))
+	copy(sorted, h.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// Write outputs the report as text
+func (r *Report) Write(w io.Writer) {
+	elapsed := time.Duration(r.Elapsed)
+	fmt.Fprintf(w, "Submitted: %d  Failed: %d  Skipped: %d  Elapsed: %s", r.Submitted, r.Failed, r.Skipped, elapsed)
+	if elapsed > 0 {
+		fmt.Fprintf(w, "  Throughput: %.2f tx/s", float64(r.Submitted)/elapsed.Seconds())
+	}
+	fmt.Fprintln(w)
+	for _, h := range []struct {
+		name string
+		h    *LatencyHistogram
+	}{
+		{"prepare", r.Prepare},
+		{"send", r.Send},
+		{"total", r.Total},
+	} {
+		fmt.Fprintf(w, "\nLatency (%s): p50=%s p95=%s p99=%s\n", h.name, h.h.Percentile(50), h.h.Percentile(95), h.h.Percentile(99))
+		for i, count := range h.h.Counts {
+			if i < len(histogramBuckets) {
+				fmt.Fprintf(w, "  <= %-8s %d\n", histogramBuckets[i], count)
+			} else {
+				fmt.Fprintf(w, "   > %-8s %d\n", histogramBuckets[i-1], count)
+			}
+		}
+	}
+}
@@ -29,18 +29,172 @@ var ffc = func(key, translation string, fieldType string) i18n.ConfigMessageKey
 var (
 	ConfigEthereumURL                 = ffc("config.connector.url", "URL of JSON/RPC endpoint for the Ethereum node/gateway", "string")
 	ConfigEthereumWSEnabled           = ffc("config.connector.ws.enabled", "When true a WebSocket is established for block listening, in addition to the HTTP RPC connections used for other functions", i18n.BooleanType)
+	ConfigEthereumIPCPath             = ffc("config.connector.ipc.path", "The path of the IPC socket of a co-located node, such as geth.ipc. When set, JSON/RPC requests are sent over the socket rather than HTTP, with the same retry and concurrency settings. The url is then only required for features that use HTTP, such as GraphQL", i18n.StringType)
+	ConfigEthereumIPCDialTimeout      = ffc("config.connector.ipc.dialTimeout", "The maximum time to wait to connect to the IPC socket", i18n.TimeDurationType)
 	ConfigEthereumDataFormat          = ffc("config.connector.dataFormat", "Configure the JSON data format for query output and events", "map,flat_array,self_describing")
+	ConfigChecksumAddresses           = ffc("config.connector.checksumAddresses", "Format addresses in receipts, events and query results with EIP-55 mixed-case checksums, rather than as lowercase hex. Can be overridden for each event listener", i18n.BooleanType)
 	ConfigEthereumGasEstimationFactor = ffc("config.connector.gasEstimationFactor", "The factor to apply to the gas estimation to determine the gas limit", "float")
+	ConfigGasEstimationFloor          = ffc("config.connector.gasEstimationFloor", "The minimum gas limit to use after applying the gasEstimationFactor. No minimum if not set", "string")
+	ConfigGasEstimationCeiling        = ffc("config.connector.gasEstimationCeiling", "The maximum gas limit to use after applying the gasEstimationFactor. Also used as the gas limit when eth_estimateGas fails for a reason other than a revert, but an eth_call of the transaction succeeds. No maximum if not set", "string")
+	ConfigGasEstimationFallback       = ffc("config.connector.gasEstimationFallback", "When eth_estimateGas fails for a reason other than a revert, but an eth_call of the transaction succeeds, retry eth_estimateGas with a balance override for the sender, then binary search for the lowest gas limit at which eth_call succeeds. The search is bounded by gasEstimationCeiling, or the gas limit of the latest block if not set", i18n.BooleanType)
+	ConfigGraphQLURL                  = ffc("config.connector.graphql.url", "Optional URL of a GraphQL endpoint on the node (such as the Besu /graphql endpoint) used to retrieve logs with their block and transaction information in a single round trip during catchup. JSON/RPC is used if the endpoint is unavailable", i18n.StringType)
+	ConfigTracingEnabled              = ffc("config.connector.tracing.enabled", "Whether to send a W3C traceparent header with each JSON/RPC request, continuing the trace of the inbound request (or derived from its FireFly request ID) so that the logs of node providers can be correlated with FireFly operations", i18n.BooleanType)
+	ConfigTracingOTLPURL              = ffc("config.connector.tracing.otlp.url", "The OTLP/HTTP endpoint to export OpenTelemetry spans to, such as http://localhost:4318/v1/traces. Spans are recorded for the FFCAPI operations, the polling of event streams and each JSON/RPC request. When tracing is enabled, the traceparent header of a JSON/RPC request identifies its span", i18n.StringType)
+	ConfigTracingOTLPServiceName      = ffc("config.connector.tracing.otlp.serviceName", "The service name of the exported spans", i18n.StringType)
+	ConfigTransactionSearchMaxBlocks  = ffc("config.connector.transactionSearch.maxBlocks", "The maximum number of blocks back from the head of the chain to scan, when finding a transaction by sender and nonce on a node without an index for that lookup", i18n.IntType)
+	ConfigSubmissionDependencyTimeout = ffc("config.connector.submission.dependencies.timeout", "How long a submission that depends on earlier operations is held waiting for them to be confirmed, before it fails", i18n.TimeDurationType)
+	ConfigSubmissionDependencyConfs   = ffc("config.connector.submission.dependencies.confirmations", "The default number of blocks required on top of each dependency before a dependent submission is sent. 0 sends as soon as the dependencies are mined", i18n.IntType)
+	ConfigSubmissionDependencySize    = ffc("config.connector.submission.dependencies.cacheSize", "The number of operation IDs to remember the latest transaction hash for, so later submissions can depend on them", i18n.IntType)
+	ConfigDeployBatchReceiptTimeout   = ffc("config.connector.deployBatch.receiptTimeout", "How long to wait for the receipt of each contract deployed in a batch deployment, before the batch fails", i18n.TimeDurationType)
+	ConfigDeployVerificationEnabled   = ffc("config.connector.deployVerification.enabled", "When true, the receipt of a successful contract deployment includes the keccak256 hash of the deployed runtime bytecode, and the constructor arguments, for contract verification pipelines", i18n.BooleanType)
+	ConfigDeployVerificationCacheSize = ffc("config.connector.deployVerification.cacheSize", "The number of prepared deployments for which the constructor arguments are held in memory until the receipt is available", i18n.IntType)
+	ConfigAddressActivitySparseScan   = ffc("config.connector.addressActivity.sparseScan", "When true, address activity listeners query the transaction count of each new block before reading it with its full transactions, and skip the empty blocks. This reduces the RPC cost on chains where most blocks are empty, such as test networks, at the cost of an extra call for each block that is not", i18n.BooleanType)
+	ConfigENSEnabled                  = ffc("config.connector.ens.enabled", "Whether to resolve ENS names, such as 'mycontract.eth', supplied as the to-address of transactions and queries", i18n.BooleanType)
+	ConfigENSRegistry                 = ffc("config.connector.ens.registry", "The address of the ENS registry contract - the default is the registry deployed on Ethereum mainnet and the public testnets", i18n.StringType)
+	ConfigENSCacheSize                = ffc("config.connector.ens.cacheSize", "The number of resolved ENS names to cache", i18n.IntType)
+	ConfigENSCacheTTL                 = ffc("config.connector.ens.cacheTTL", "How long a resolved ENS name is cached before it is resolved again", i18n.TimeDurationType)
+	ConfigLegacyFeeFallback           = ffc("config.connector.legacyFeeFallback", "Detect chains that do not support EIP-1559, from the absence of a base fee in the latest block, and submit transactions with a legacy gasPrice equal to the maxFeePerGas requested by the policy engine", i18n.BooleanType)
+	ConfigPriorityFeeEnabled          = ffc("config.connector.priorityFee.enabled", "On chains that support EIP-1559, estimate the gas price as maxFeePerGas and maxPriorityFeePerGas. The priority fee blends eth_maxPriorityFeePerGas (where supported) with the median priority fee paid in recent blocks, and maxFeePerGas allows for the base fee to double. When disabled eth_gasPrice is used", i18n.BooleanType)
+	ConfigPriorityFeeWindow           = ffc("config.connector.priorityFee.window", "The number of recent blocks over which the median priority fee is calculated, up to 1024", i18n.IntType)
+	ConfigPriorityFeeSpikeClamp       = ffc("config.connector.priorityFee.spikeClamp", "The eth_maxPriorityFeePerGas sample is clamped to within this factor of the median priority fee of recent blocks, before they are blended. Must be at least 1", "float")
+	ConfigReplacementFeeBumpPercent   = ffc("config.connector.replacementFeeBumpPercent", "The default percentage by which the gas price of a transaction is increased, when submitting a replacement for it with the same nonce. Must be at least 10, as nodes refuse a replacement with a smaller increase", "float")
+	ConfigAdaptiveConcurrencyEnabled  = ffc("config.connector.adaptiveConcurrency.enabled", "Adjust the number of concurrent JSON/RPC requests to what the node or provider can sustain, up to maxConcurrentRequests. The limit grows while requests succeed, and is reduced when the provider throttles requests (HTTP 429 or JSON/RPC -32005) or responses exceed the latency target", i18n.BooleanType)
+	ConfigAdaptiveConcurrencyMin      = ffc("config.connector.adaptiveConcurrency.minLimit", "The lowest the concurrent request limit is reduced to", i18n.IntType)
+	ConfigAdaptiveConcurrencyInitial  = ffc("config.connector.adaptiveConcurrency.initialLimit", "The concurrent request limit on startup", i18n.IntType)
+	ConfigAdaptiveConcurrencyLatency  = ffc("config.connector.adaptiveConcurrency.latencyTarget", "If set, responses slower than this reduce the concurrent request limit in the same way as throttling", i18n.TimeDurationType)
+	ConfigAdaptiveConcurrencyDecrease = ffc("config.connector.adaptiveConcurrency.decreaseFactor", "The factor the concurrent request limit is multiplied by when reduced", i18n.FloatType)
 	ConfigBlockCacheSize              = ffc("config.connector.blockCacheSize", "Maximum of blocks to hold in the block info cache", i18n.IntType)
 	ConfigBlockPollingInterval        = ffc("config.connector.blockPollingInterval", "Interval for polling to check for new blocks", i18n.TimeDurationType)
+	ConfigBlockPollingJitter          = ffc("config.connector.blockPollingJitter", "A random variation applied to each block polling interval, as a fraction of the interval between 0 and 1. For example 0.1 varies each interval by up to 10% either way", "float")
+	ConfigBlockPollingAdaptive        = ffc("config.connector.blockPollingAdaptive.enabled", "Learn the average block time of the chain from the timestamps of recent blocks, and poll for new blocks at that interval instead of the fixed blockPollingInterval", i18n.BooleanType)
+	ConfigBlockPollingMinInterval     = ffc("config.connector.blockPollingAdaptive.minInterval", "The shortest block polling interval to use when adaptive polling is enabled", i18n.TimeDurationType)
+	ConfigBlockPollingMaxInterval     = ffc("config.connector.blockPollingAdaptive.maxInterval", "The longest block polling interval to use when adaptive polling is enabled", i18n.TimeDurationType)
 	ConfigEventsBlockTimestamps       = ffc("config.connector.events.blockTimestamps", "Whether to include the block timestamps in the event information", i18n.BooleanType)
+	ConfigEventsOrdering              = ffc("config.connector.events.ordering", "How the events of the listeners in each stream are ordered - 'listener' to deliver the events of each listener in order, with listeners that start behind the head of the chain catching up independently, or 'stream' to deliver the events of all the listeners of a stream strictly in (blockNumber, transactionIndex, logIndex) order. With 'stream' ordering, a listener added behind the others holds back the events of the whole stream until it has caught up", i18n.StringType)
+	ConfigEventsSchemaVersion         = ffc("config.connector.events.schemaVersion", "The layout of the event information delivered to consumers - 'evmconnect', or 'ethconnect' to be compatible with consumers written for ethconnect. Can be overridden by the schemaVersion option of each listener", i18n.StringType)
 	ConfigEventsCatchupPageSize       = ffc("config.connector.events.catchupPageSize", "Number of blocks to query per poll when catching up to the head of the blockchain", i18n.IntType)
 	ConfigEventsCatchupThreshold      = ffc("config.connector.events.catchupThreshold", "How many blocks behind the chain head an event stream or listener must be on startup, to enter catchup mode", i18n.IntType)
 	ConfigEventsCatchupDownscaleRegex = ffc("config.connector.events.catchupDownscaleRegex", "An error pattern to check for from JSON/RPC providers if they limit response sizes to eth_getLogs(). If an error is returned from eth_getLogs() and that error matches the configured pattern, the number of logs requested (catchupPageSize) will be reduced automatically.", "string")
+	ConfigEventsCatchupParallelism    = ffc("config.connector.events.catchupParallelism", "The maximum number of eth_getLogs queries to run in parallel, across all groups of listeners that are catching up. Listeners that need to catch up from the same block are grouped, and share combined queries", i18n.IntType)
+	ConfigEventsDedupeCacheSize       = ffc("config.connector.events.dedupeCacheSize", "The number of recently delivered events remembered by each event stream, so that events re-detected due to filter re-creation, re-org replays or overlapping catchup queries are not delivered twice. Disabled when 0. The number of duplicates dropped by each event stream is reported in the status of the connector", i18n.IntType)
+	ConfigEventsWALPath               = ffc("config.connector.events.writeAheadLog.path", "A local directory in which each event stream records the batches of events it dispatches, until they are acknowledged by a checkpoint. Events that were in-flight when the connector stopped are re-delivered from the log on restart, rather than being lost or re-detected. Disabled when not set", i18n.StringType)
+	ConfigEventsWALMaxEntries         = ffc("config.connector.events.writeAheadLog.maxEntries", "The maximum number of unacknowledged events kept in the write-ahead log of each event stream. Once it is full, the stream dispatches no more events until FFTM acknowledges earlier ones by advancing its checkpoint", i18n.IntType)
+	ConfigEventsWildcardEventRate     = ffc("config.connector.events.wildcardEventRate", "The maximum number of events per second delivered by a listener that matches events from all contracts, as it has no address filter. As the events of a stream are delivered in order, this also holds back the other listeners in the stream. Set to 0 to disable", i18n.IntType)
+	ConfigEventsBlockPrefetchDepth    = ffc("config.connector.events.blockPrefetchDepth", "When listeners need the input data or signer of the transaction for each event, the full blocks containing the events are fetched with eth_getBlockByNumber this many blocks ahead of decoding, rather than each transaction being queried individually. The transactions are held in the transaction cache, so txCacheSize must be large enough to hold the transactions of this many blocks. Set to 0 to disable", i18n.IntType)
+	ConfigEventsSignatureLabels       = ffc("config.connector.events.signatureLabels.enabled", "When true the events of listeners, and of ABIs uploaded to the ethconnect API, are registered by topic0 with a human-readable label. The label is included in the eventLabel field of the info of each delivered event, and in the logs, to help identify events matched from contracts with an unexpected layout", i18n.BooleanType)
+	ConfigEventsBootstrapDirectory    = ffc("config.connector.events.bootstrapDirectory", "A directory of exports from an external indexer, which new listeners can be seeded from with the bootstrap listener option rather than catching up from their fromBlock. Listener bootstrap is disabled if not set", i18n.StringType)
+	ConfigEventsSignatureLabelsFile   = ffc("config.connector.events.signatureLabels.file", "A JSON file containing an object that maps the topic0 of additional events to their labels", i18n.StringType)
+	ConfigEventsBlockHashQueries      = ffc("config.connector.events.blockHashQueries.mode", "Whether the eth_getLogs queries for a range of blocks are made per block with the blockHash parameter, so that a re-org during the query cannot return logs from a mix of the old and new chains. Each block requires an additional eth_getBlockByNumber call. One of: never, always, auto - which switches on once the block listener sees a re-org of at least the configured depth", i18n.StringType)
+	ConfigEventsBlockHashReorgDepth   = ffc("config.connector.events.blockHashQueries.reorgDepth", "The depth of re-org, in blocks, that switches on log queries pinned by block hash in auto mode. Re-orgs recorded in reorg.statisticsFile before a restart are included", i18n.IntType)
+	ConfigLeaderElectionURL           = ffc("config.connector.leaderElection.url", "The URL of a Postgres database in which instances of the connector share a lease, to run as an active/standby pair. This must be the same URL as 'persistence.postgres.url', with FFTM persisting to Postgres, as a standby that is elected resumes each event stream from the checkpoints FFTM persisted. Only the leader dispatches events and submits transactions, while a standby keeps its view of the chain up to date. Disabled when not set", i18n.StringType)
+	ConfigLeaderElectionName          = ffc("config.connector.leaderElection.name", "The name of the lease, which must be the same for all the instances that take over from each other", i18n.StringType)
+	ConfigLeaderElectionInstanceID    = ffc("config.connector.leaderElection.instanceID", "The unique identifier of this instance in the election. Defaults to the hostname", i18n.StringType)
+	ConfigLeaderElectionLeaseDuration = ffc("config.connector.leaderElection.leaseDuration", "How long the lease is held after it is last renewed, which is the longest a standby waits to take over from a failed leader", i18n.TimeDurationType)
+	ConfigLeaderElectionRenewInterval = ffc("config.connector.leaderElection.renewInterval", "How often the leader renews the lease, and a standby attempts to acquire it. The leader steps down if it fails to renew the lease when less than one and a half times this interval remains, so must be less than two thirds of the lease duration", i18n.TimeDurationType)
+	ConfigEventsLogIndexSize          = ffc("config.connector.events.logIndexSize", "The number of contract address and event signature pairs in an in-memory index of the block ranges scanned by catchup queries, and the blocks in which they emitted logs. New listeners with an old fromBlock use it to skip ranges with no events. Only ranges at least catchupThreshold blocks behind the head of the chain are recorded. Set to 0 to disable", i18n.IntType)
 	ConfigEventsCheckpointBlockGap    = ffc("config.connector.events.checkpointBlockGap", "The number of blocks at the head of the chain that should be considered unstable (could be dropped from the canonical chain after a re-org). Unless events with a full set of confirmations are detected, the restart checkpoint will this many blocks behind the chain head.", i18n.IntType)
+	ConfigEventsDryRunMaxBlocks       = ffc("config.connector.events.dryRunMaxBlocks", "The maximum number of blocks in the range of a dry run of listener filters, which queries the historical events that match the filters without creating a listener", i18n.IntType)
+	ConfigEventsContinuityRescans     = ffc("config.connector.events.continuityRescans", "The number of times the events of a listener are queried again from its checkpoint, when the node returns an event behind the last event delivered to the listener, before that event is delivered out of order. Inconsistent events from a load balanced node that is behind the others are dropped by the re-scan. Set to 0 to disable the check", i18n.IntType)
+	ConfigEventsMaxLogsResponseSize   = ffc("config.connector.events.maxLogsResponseSize", "The maximum size of an eth_getLogs() response. The logs are decoded as the response is received, and a query returning a larger response fails in the same way as a provider limiting response sizes, so the number of logs requested is reduced if it matches catchupDownscaleRegex", i18n.ByteSizeType)
 	ConfigEventsFilterPollingInterval = ffc("config.connector.events.filterPollingInterval", "The interval between polling calls to a filter, when checking for newly arrived events", i18n.TimeDurationType)
+	ConfigTokenCacheSize              = ffc("config.connector.tokenCacheSize", "Maximum number of token contracts to hold in the cache of symbol and decimals used by the tokenTransfers listener option", i18n.IntType)
 	ConfigTxCacheSize                 = ffc("config.connector.txCacheSize", "Maximum of transactions to hold in the transaction info cache", i18n.IntType)
 	ConfigMaxConcurrentRequests       = ffc("config.connector.maxConcurrentRequests", "Maximum of concurrent requests to be submitted to the blockchain", i18n.IntType)
+	ConfigAuditEnabled                = ffc("config.connector.audit.enabled", "Records every FFCAPI operation, and the JSON/RPC calls made to the node for it, with the latency and outcome of each. Health checks are not recorded", i18n.BooleanType)
+	ConfigAuditFile                   = ffc("config.connector.audit.file", "The JSONL file the audit records are written to, which is rotated when it reaches the maximum size. The records are written to the log when not set", i18n.StringType)
+	ConfigAuditMaxSize                = ffc("config.connector.audit.maxSize", "The size at which the audit file is rotated", i18n.ByteSizeType)
+	ConfigAuditMaxBackups             = ffc("config.connector.audit.maxBackups", "The number of rotated audit files to keep", i18n.IntType)
+	ConfigAuditIncludeRequests        = ffc("config.connector.audit.includeRequests", "Include the request of each FFCAPI operation in its audit record, with the redacted fields replaced by a hash of their value. JSON/RPC calls are only ever recorded with a hash of their parameters", i18n.BooleanType)
+	ConfigAuditRedactFields           = ffc("config.connector.audit.redactFields", "The names of the fields, at any depth of an operation request, whose values are redacted from the audit records", i18n.ArrayStringType)
+	ConfigRPCPriorityEnabled          = ffc("config.connector.rpcPriority.enabled", "When the concurrency limit of maxConcurrentRequests (or the adaptive limit) is reached, admit waiting transaction submissions and receipt fetches first, and the block range queries of event stream catch-up last", i18n.BooleanType)
+	ConfigCircuitBreakerThreshold     = ffc("config.connector.circuitBreaker.failureThreshold", "The number of consecutive JSON/RPC requests that fail to get a response from the node, after which requests fail fast with a downstream_down error until the node responds to a probe request. Disabled if zero", i18n.IntType)
+	ConfigCircuitBreakerResetDelay    = ffc("config.connector.circuitBreaker.resetDelay", "How long requests fail fast after the circuit breaker opens, before a single probe request is sent to the node", i18n.TimeDurationType)
+	ConfigReadQuorumURL               = ffc("config.connector.readQuorum.url", "A secondary JSON/RPC endpoint, ideally from a different provider, that receipts and the blocks used for confirmations are cross-checked against. Disabled if not set", i18n.StringType)
+	ConfigReadQuorumMode              = ffc("config.connector.readQuorum.mode", "What to do when the primary and secondary endpoints disagree - prefer_primary to log and count the mismatch, fail to fail the read so it is retried later, or retry to query both again before failing", i18n.StringType)
+	ConfigReadQuorumRetries           = ffc("config.connector.readQuorum.retries", "In retry mode, the number of times to query both endpoints again before failing the read", i18n.IntType)
+	ConfigReadQuorumRetryDelay        = ffc("config.connector.readQuorum.retryDelay", "In retry mode, the delay before querying both endpoints again", i18n.TimeDurationType)
+	ConfigReadQuorumAuthUsername      = ffc("config.connector.readQuorum.auth.username", "Username for basic authentication to the secondary endpoint. Other HTTP settings, except headers, are shared with the primary endpoint", i18n.StringType)
+	ConfigReadQuorumAuthPassword      = ffc("config.connector.readQuorum.auth.password", "Password for basic authentication to the secondary endpoint", i18n.StringType)
+	ConfigReadQuorumTLSEnabled        = ffc("config.connector.readQuorum.tls.enabled", "Use a separate TLS configuration for the secondary endpoint, such as a client certificate for mTLS. When disabled the TLS configuration of the primary endpoint is used", i18n.BooleanType)
+	ConfigReorgMaxDepth               = ffc("config.connector.reorg.maxDepth", "The deepest re-org the block listener treats as legitimate. A deeper fork, which is more likely to be bad data from the node, halts new block notifications - so confirmations stop advancing - until acknowledged by an operator with the acknowledgeReorg operation of the extensions API, or until reorg.autoResumeDelay has passed. Disabled if zero", i18n.IntType)
+	ConfigReorgAutoResumeDelay        = ffc("config.connector.reorg.autoResumeDelay", "If set, new block notifications resume automatically this long after being halted by a re-org deeper than reorg.maxDepth, without operator acknowledgement", i18n.TimeDurationType)
+	ConfigReorgStatisticsFile         = ffc("config.connector.reorg.statisticsFile", "A file in which the distribution of observed re-org depths is kept across restarts, to help choose the number of confirmations for the chain. Statistics are only kept in memory if not set", i18n.StringType)
+	ConfigShutdownTimeout             = ffc("config.connector.shutdown.timeout", "The maximum time a graceful shutdown waits for in-flight submissions to complete, and for event streams to finish delivering their current batch of events", i18n.TimeDurationType)
+	ConfigTrustedCheckpointNumber     = ffc("config.connector.trustedCheckpoint.blockNumber", "The number of the block in trustedCheckpoint.blockHash", i18n.IntType)
+	ConfigTrustedCheckpointHash       = ffc("config.connector.trustedCheckpoint.blockHash", "If set, the block listener only starts once the node has a block with this hash at trustedCheckpoint.blockNumber - protecting against connecting to a node on the wrong network or fork", i18n.StringType)
+	ConfigSnapshotsURL                = ffc("config.connector.snapshots.url", "URL of an S3 compatible bucket (path-style, such as https://s3.us-east-1.amazonaws.com/my-bucket or https://storage.googleapis.com/my-bucket) to periodically write snapshots of the event stream checkpoints and canonical chain to. Disabled if not set", i18n.StringType)
+	ConfigSnapshotsPrefix             = ffc("config.connector.snapshots.prefix", "The prefix of the object names of the snapshots in the bucket", i18n.StringType)
+	ConfigSnapshotsInterval           = ffc("config.connector.snapshots.interval", "How often to write a snapshot", i18n.TimeDurationType)
+	ConfigSnapshotsRetention          = ffc("config.connector.snapshots.retention", "The number of snapshots to keep in the bucket - older snapshots are deleted after each new snapshot is written. All snapshots are kept if zero", i18n.IntType)
+	ConfigSnapshotsRestore            = ffc("config.connector.snapshots.restore", "Set to 'latest', or the object name of a snapshot, to restore the checkpoints of listeners on startup. The checkpoint in the snapshot is used for each listener that is started without a checkpoint from the transaction manager", i18n.StringType)
+	ConfigSnapshotsRegion             = ffc("config.connector.snapshots.region", "The region used in the AWS SigV4 signature of requests to the bucket", i18n.StringType)
+	ConfigSnapshotsAccessKeyID        = ffc("config.connector.snapshots.accessKeyID", "The access key ID used to sign requests to the bucket. If not set, the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables are used, and requests are unsigned if those are not set either", i18n.StringType)
+	ConfigSnapshotsSecretAccessKey    = ffc("config.connector.snapshots.secretAccessKey", "The secret access key used to sign requests to the bucket", i18n.StringType)
+	ConfigSnapshotsSessionToken       = ffc("config.connector.snapshots.sessionToken", "The session token, when using temporary credentials", i18n.StringType)
 	ConfigHederaCompatibilityMode     = ffc("config.connector.hederaCompatibilityMode", "Compatibility mode for Hedera, allowing non-standard block header hashes to be processed", i18n.BooleanType)
+	ConfigAuthOAuth2TokenURL          = ffc("config.connector.auth.oauth2.tokenURL", "The token endpoint of an OAuth2 authorization server. When set, an access token is obtained with the client credentials grant, and sent as a bearer token on each JSON/RPC request", i18n.StringType)
+	ConfigAuthOAuth2ClientID          = ffc("config.connector.auth.oauth2.clientID", "The client ID for the OAuth2 client credentials grant", i18n.StringType)
+	ConfigAuthOAuth2ClientSecret      = ffc("config.connector.auth.oauth2.clientSecret", "The client secret for the OAuth2 client credentials grant", i18n.StringType)
+	ConfigAuthOAuth2Scopes            = ffc("config.connector.auth.oauth2.scopes", "The scopes to request in the OAuth2 client credentials grant", i18n.ArrayStringType)
+	ConfigAuthSigV4Region             = ffc("config.connector.auth.sigv4.region", "The AWS region of the endpoint. When set, each JSON/RPC request is signed with AWS Signature Version 4 - for example for Amazon Managed Blockchain", i18n.StringType)
+	ConfigAuthSigV4Service            = ffc("config.connector.auth.sigv4.service", "The AWS service name used in the SigV4 signing scope", i18n.StringType)
+	ConfigAuthSigV4AccessKeyID        = ffc("config.connector.auth.sigv4.accessKeyID", "The AWS access key ID used to sign requests. If not set, the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables are used", i18n.StringType)
+	ConfigAuthSigV4SecretAccessKey    = ffc("config.connector.auth.sigv4.secretAccessKey", "The AWS secret access key used to sign requests", i18n.StringType)
+	ConfigAuthSigV4SessionToken       = ffc("config.connector.auth.sigv4.sessionToken", "The AWS session token, when using temporary credentials", i18n.StringType)
+	ConfigProxyURL                    = ffc("config.connector.proxy.url", "Optional proxy server for outbound JSON/RPC connections. Supports HTTP CONNECT proxies with an http or https scheme, and SOCKS5 proxies with a socks5 scheme. WebSocket connections are always made directly, with a warning at startup if the WebSocket host is not in proxy.noProxy", i18n.StringType)
+	ConfigProxyUsername               = ffc("config.connector.proxy.username", "Username to authenticate to the proxy server, if not included in the proxy URL", i18n.StringType)
+	ConfigProxyPassword               = ffc("config.connector.proxy.password", "Password to authenticate to the proxy server", i18n.StringType)
+	ConfigProxyNoProxy                = ffc("config.connector.proxy.noProxy", "Hosts, domains, IP addresses and CIDR ranges to connect to directly rather than via the proxy, with the same syntax as the NO_PROXY environment variable", i18n.ArrayStringType)
+	ConfigChainProfile                = ffc("config.connector.chainProfile", "Adjusts the defaults of other settings for a family of chains. Settings explicitly configured to a non-default value are not changed by the profile", "polygon,bsc,avalanche")
+	ConfigReceiptsNotFoundRetries     = ffc("config.connector.receipts.notFoundRetries", "The number of times to retry eth_getTransactionReceipt when no receipt is returned, before reporting the receipt as not found. For nodes that return receipts with eventual consistency", i18n.IntType)
+	ConfigReceiptsNotFoundGracePeriod = ffc("config.connector.receipts.notFoundGracePeriod", "How long to keep retrying eth_getTransactionReceipt, at the notFoundRetryDelay, when no receipt is returned for a transaction the node reports is already in a block. For nodes whose receipts lag the head of the chain. Disabled if zero", i18n.TimeDurationType)
+	ConfigReceiptsNotFoundRetryDelay  = ffc("config.connector.receipts.notFoundRetryDelay", "The delay between retries of eth_getTransactionReceipt when no receipt is returned", i18n.TimeDurationType)
+	ConfigPendingState                = ffc("config.connector.pendingState", "When true, queries that do not specify a blockNumber, and gas estimates, are executed against the pending block - so they see the effects of transactions submitted to the node that are not yet mined", i18n.BooleanType)
 	ConfigTraceTXForRevertReason      = ffc("config.connector.traceTXForRevertReason", "Enable the use of transaction trace functions (e.g. debug_traceTransaction) to obtain transaction revert reasons. This can place a high load on the EVM client.", i18n.BooleanType)
+	ConfigExtensionsEnabled           = ffc("config.extensions.enabled", "Enables the HTTP server for the operations of the connector that are not part of the APIs of the transaction manager, such as transaction replacement", i18n.BooleanType)
+	ConfigExtensionsCorsCredentials   = ffc("config.extensions.cors.credentials", "CORS setting to control whether a browser allows credentials to be sent to the extensions API", i18n.BooleanType)
+	ConfigRawTransactionsMaxFeePerGas = ffc("config.connector.rawTransactions.maxFeePerGas", "The maximum gas price or maxFeePerGas (in wei) accepted for a pre-signed raw transaction, to protect against signing mistakes. No limit if not set", "string")
+	ConfigRawTransactionsValidate     = ffc("config.connector.rawTransactions.validate", "Decode and validate the pre-signed transactions sent by the transaction manager, as for the transactionSendRaw operation, before submitting them - checking the chain ID, the nonce and the fee caps", i18n.BooleanType)
+	ConfigSubmissionMaxHeadAge        = ffc("config.connector.submission.maxHeadAge", "Refuse transaction submission with a retryable error if the timestamp of the latest block on the node is older than this. Disabled if zero", i18n.TimeDurationType)
+	ConfigSubmissionMaxDataSize       = ffc("config.connector.submission.maxDataSize", "Refuse transaction submission if the calldata (or deployment bytecode) is larger than this, with an error that includes the intrinsic gas of the data. Set to the limit of the node, such as 128Kb for geth, to avoid the node rejecting large transactions with an opaque error. Disabled if zero", i18n.ByteSizeType)
+	ConfigSubmissionIntrinsicGas      = ffc("config.connector.submission.checkIntrinsicGas", "Refuse transaction submission if the gas limit is below the intrinsic gas of the transaction - the base cost, plus the cost of the calldata and of any deployment bytecode, using the gas schedule of current Ethereum forks", i18n.BooleanType)
+	ConfigSubmissionBalanceCheck      = ffc("config.connector.submission.checkBalance", "Refuse transaction submission if the latest balance of the sender does not cover the value of the transaction, plus its gas limit at the maximum fee per gas, before the node assigns it a nonce", i18n.BooleanType)
+	ConfigSignersURL                  = ffc("config.connector.signers[].url", "The JSON/RPC endpoint of a signing service, such as firefly-signer, that eth_sendTransaction is sent to for the addresses of this signer. Other requests, and transactions from addresses not matched by any signer, are sent to the node", i18n.StringType)
+	ConfigSignersAddresses            = ffc("config.connector.signers[].addresses", "The addresses to send transactions from via this signer", i18n.ArrayStringType)
+	ConfigSignersAddressRanges        = ffc("config.connector.signers[].addressRanges", "Inclusive ranges of addresses to send transactions from via this signer, each a start and end address separated by '-'", i18n.ArrayStringType)
+	ConfigSignersAuthUsername         = ffc("config.connector.signers[].auth.username", "Username for basic authentication to the signer. Other HTTP settings are shared with the JSON/RPC endpoint of the node", i18n.StringType)
+	ConfigSignersAuthPassword         = ffc("config.connector.signers[].auth.password", "Password for basic authentication to the signer", i18n.StringType)
+	ConfigSignersTLSEnabled           = ffc("config.connector.signers[].tls.enabled", "Use a separate TLS configuration for the signer, such as a client certificate for mTLS. When disabled the TLS configuration of the JSON/RPC endpoint of the node is used", i18n.BooleanType)
+	ConfigErrorMappingsMethods        = ffc("config.connector.errorMappings[].methods", "The categories of JSON/RPC method the mapping applies to: block,call,filter,netVersion,send. Applies to all methods if not set", i18n.ArrayStringType)
+	ConfigErrorMappingsCode           = ffc("config.connector.errorMappings[].code", "The JSON/RPC error code to match", i18n.IntType)
+	ConfigErrorMappingsMessage        = ffc("config.connector.errorMappings[].message", "A regular expression to match against the JSON/RPC error message", i18n.StringType)
+	ConfigErrorMappingsReason         = ffc("config.connector.errorMappings[].reason", "The FFCAPI error reason reported to the transaction manager for matching errors, such as nonce_too_low or downstream_down. Configured mappings are checked before the built in mappings for common Ethereum clients and node providers", i18n.StringType)
+	ConfigSubmissionIdempotencyWindow = ffc("config.connector.submission.idempotency.window", "How long to remember the transaction hash of each transaction sent, so that an identical request to send the same prepared transaction returns the original hash instead of signing and sending it again. The hashes are held in memory, so only repeats within the same process are covered - not repeats after a restart. Must be shorter than the resubmitInterval of the transaction handler, so that a deliberate re-submission of a stuck transaction reaches the node. Disabled if zero", i18n.TimeDurationType)
+	ConfigSubmissionIdempotencySize   = ffc("config.connector.submission.idempotency.cacheSize", "The maximum number of sent transaction hashes to remember for idempotent re-submission", i18n.IntType)
+	ConfigSubmissionPreSubmitHook     = ffc("config.connector.submission.hooks.preSubmitURL", "URL that each transaction is POSTed to before submission. The hook must respond with approved=true for the transaction to be submitted, and can replace the fees of transactions that are signed by the node. Transactions are not submitted while the hook is unavailable", i18n.StringType)
+	ConfigSubmissionPostSubmitHook    = ffc("config.connector.submission.hooks.postSubmitURL", "URL that each transaction is POSTed to, with its hash, after it has been accepted by the node. Failures are logged but do not fail the submission", i18n.StringType)
+	ConfigSubmissionRelayURL          = ffc("config.connector.submission.privateRelay.url", "The JSON/RPC endpoint of a private transaction relay, such as Flashbots Protect, that signed transactions are sent to instead of the public mempool of the node. Disabled if not set", i18n.StringType)
+	ConfigSubmissionRelayMethod       = ffc("config.connector.submission.privateRelay.method", "The JSON/RPC method of the relay - eth_sendPrivateTransaction, which is passed the block after which the relay drops the transaction, or eth_sendRawTransaction for relays that are a drop-in RPC endpoint", i18n.StringType)
+	ConfigSubmissionRelayMaxBlocks    = ffc("config.connector.submission.privateRelay.maxBlocks", "The number of blocks after submission that the relay attempts to include the transaction, after which it is dropped and re-sent on the next submission of the same transaction", i18n.IntType)
+	ConfigSubmissionRelayAddresses    = ffc("config.connector.submission.privateRelay.addresses", "The addresses whose transactions are sent via the relay. Transactions from these addresses must be signed before submission. If not set, all signed transactions are sent via the relay", i18n.ArrayStringType)
+	ConfigSubmissionRelayCacheSize    = ffc("config.connector.submission.privateRelay.cacheSize", "The maximum number of relayed transactions to track for expiry and status queries", i18n.IntType)
+	ConfigSubmissionRelayAuthUsername = ffc("config.connector.submission.privateRelay.auth.username", "Username for basic authentication to the relay. Other HTTP settings are shared with the JSON/RPC endpoint of the node", i18n.StringType)
+	ConfigSubmissionRelayAuthPassword = ffc("config.connector.submission.privateRelay.auth.password", "Password for basic authentication to the relay", i18n.StringType)
+	ConfigSubmissionRelayTLSEnabled   = ffc("config.connector.submission.privateRelay.tls.enabled", "Use a separate TLS configuration for the relay, such as a client certificate for mTLS. When disabled the TLS configuration of the JSON/RPC endpoint of the node is used", i18n.BooleanType)
+	ConfigGlobalTLSServerName         = ffc("config.global.tls.serverName", "Overrides the server name sent in the TLS SNI extension, and verified against the certificate of the server, for an endpoint reached by IP address or via a load balancer. Not inherited by other endpoints", i18n.StringType)
+	ConfigSubmissionRejectSyncing     = ffc("config.connector.submission.rejectWhileSyncing", "Refuse transaction submission with a retryable error while eth_syncing reports the node is syncing", i18n.BooleanType)
+
+	ConfigLoadTestEnabled     = ffc("config.loadtest.enabled", "Must be set to true for the loadtest command to run, as it submits real transactions", i18n.BooleanType)
+	ConfigLoadTestFrom        = ffc("config.loadtest.from", "The address to submit transactions from, which must be able to sign via eth_sendTransaction on the node (or a configured signer)", i18n.StringType)
+	ConfigLoadTestTo          = ffc("config.loadtest.to", "The address each no-op transaction calls. Defaults to the from address", i18n.StringType)
+	ConfigLoadTestRate        = ffc("config.loadtest.rate", "The number of transactions to submit per second", i18n.IntType)
+	ConfigLoadTestDuration    = ffc("config.loadtest.duration", "How long to submit transactions for", i18n.TimeDurationType)
+	ConfigLoadTestGas         = ffc("config.loadtest.gas", "The gas limit of each transaction. If not set, gas is estimated for each transaction as part of the prepare step", i18n.IntType)
+	ConfigLoadTestMaxInFlight = ffc("config.loadtest.maxInFlight", "The maximum number of transactions being prepared and sent at once. Transactions due to be submitted when the maximum is reached are skipped", i18n.IntType)
+
+	ConfigEthConnectEnabled         = ffc("config.ethconnect.enabled", "Enables the HTTP server with the legacy ethconnect REST APIs for ABIs, contract deployment, and method invocation", i18n.BooleanType)
+	ConfigEthConnectStoragePath     = ffc("config.ethconnect.storagePath", "Directory in which the uploaded ABIs and deployed contracts are stored. If not set they are only held in memory, and are lost on restart", i18n.StringType)
+	ConfigEthConnectReceiptTimeout  = ffc("config.ethconnect.receiptTimeout", "The maximum time to wait for the receipt of a transaction submitted with fly-sync=true", i18n.TimeDurationType)
+	ConfigEthConnectReceiptPolling  = ffc("config.ethconnect.receiptPollingInterval", "Interval between queries for the receipt of a transaction, while waiting for it to be mined", i18n.TimeDurationType)
+	ConfigEthConnectCorsCredentials = ffc("config.ethconnect.cors.credentials", "CORS setting to control whether a browser allows credentials to be sent to the ethconnect APIs", i18n.BooleanType)
 )
@@ -41,7 +41,7 @@ var (
 	MsgReverted                  = ffe("FF23021", "EVM reverted: %s")
 	MsgReturnDataInvalid         = ffe("FF23023", "EVM return data invalid: %s")
 	MsgNotInitialized            = ffe("FF23024", "Not initialized")
-	MsgMissingBackendURL         = ffe("FF23025", "URL must be set for the backend JSON/RPC endpoint")
+	MsgMissingBackendURL         = ffe("FF23025", "URL must be set for the backend JSON/RPC endpoint, unless an IPC path is set")
 	MsgBadVersion                = ffe("FF23026", "Bad FFCAPI Version '%s': %s")
 	MsgUnsupportedVersion        = ffe("FF23027", "Unsupported FFCAPI Version '%s'")
 	MsgUnsupportedRequestType    = ffe("FF23028", "Unsupported FFCAPI request type '%s'")
@@ -70,4 +70,153 @@ var (
 	MsgUnableToCallDebug         = ffe("FF23052", "Failed to call debug_traceTransaction to get error detail: %s")
 	MsgReturnValueNotDecoded     = ffe("FF23053", "Error return value for custom error: %s")
 	MsgReturnValueNotAvailable   = ffe("FF23054", "Error return value unavailable")
+	MsgSimulatorScriptInvalid    = ffe("FF23055", "Failed to load simulator script '%s': %s")
+	MsgSimulatorFilterNotFound   = ffe("FF23056", "Filter not found: %s")
+	MsgSimulatorMethodNotFound   = ffe("FF23057", "Method '%s' is not supported by the simulator")
+	MsgSimulatorInvalidParams    = ffe("FF23058", "Invalid parameters for '%s': %s")
+	MsgInvalidTXHash             = ffe("FF23059", "Invalid transaction hash '%s': %s")
+	MsgTransactionNotFound       = ffe("FF23060", "Transaction %s not found")
+	MsgTransactionAlreadyMined   = ffe("FF23061", "Transaction %s has already been mined in block %s and cannot be replaced")
+	MsgBadFeeBumpPolicy          = ffe("FF23062", "Invalid fee bump policy '%s' - supported policies: %s")
+	MsgMultipleRPCAuthSchemes    = ffe("FF23063", "Only one of OAuth2 or AWS SigV4 authentication can be configured for the JSON/RPC endpoint")
+	MsgMissingSigV4Credentials   = ffe("FF23064", "AWS SigV4 authentication requires an access key ID and secret access key, in configuration or the environment")
+	MsgOAuth2TokenFailed         = ffe("FF23065", "Failed to obtain OAuth2 access token from %s: %s")
+	MsgUnknownChainProfile       = ffe("FF23066", "Unknown chain profile '%s' - supported profiles: %s")
+	MsgInvalidRawTransaction     = ffe("FF23067", "Invalid raw transaction: %s")
+	MsgRawTxFromMismatch         = ffe("FF23068", "Raw transaction is signed by %s, not the expected signer %s")
+	MsgRawTxNonceMismatch        = ffe("FF23069", "Raw transaction has nonce %s, not the expected nonce %s")
+	MsgRawTxChainIDMismatch      = ffe("FF23070", "Raw transaction is for chain ID %s, but the node is on chain ID %s")
+	MsgRawTxNonceTooLow          = ffe("FF23071", "Raw transaction nonce %s is lower than the next nonce %s for %s")
+	MsgRawTxPriorityFeeTooHigh   = ffe("FF23072", "Raw transaction maxPriorityFeePerGas %s exceeds maxFeePerGas %s")
+	MsgRawTxFeeCapExceeded       = ffe("FF23073", "Raw transaction fee per gas %s exceeds the configured maximum %s")
+	MsgInvalidRawTxFeeCap        = ffe("FF23074", "Invalid rawTransactions.maxFeePerGas '%s'")
+	MsgGraphQLQueryFailed        = ffe("FF23075", "GraphQL query failed: %s")
+	MsgInvalidIntFormat          = ffe("FF23076", "Invalid intFormat '%s' - supported formats: %s")
+	MsgInvalidScaledDecimals     = ffe("FF23077", "The 'scaled' intFormat requires decimals between 0 and %d")
+	MsgNodeSyncing               = ffe("FF23078", "Transaction submission refused as the node is syncing")
+	MsgNodeHeadStale             = ffe("FF23079", "Transaction submission refused as the node head block %s is %s old (maximum %s)")
+	MsgInvalidProxyURL           = ffe("FF23080", "Invalid proxy URL '%s' - the scheme must be http, https or socks5")
+	MsgWebSocketProxyUnsupported = ffe("FF23081", "WebSocket connections to '%s' are made directly, as they cannot be made via a proxy - add the host to proxy.noProxy, or disable ws.enabled, if the host is only reachable via the proxy")
+	MsgBadFeeHistoryBlockCount   = ffe("FF23082", "Invalid blockCount %d for fee history - must be between 1 and %d")
+	MsgBadFeeHistoryNewestBlock  = ffe("FF23083", "Invalid newestBlock '%s' for fee history - must be a block number or tag")
+	MsgBadRewardPercentiles      = ffe("FF23084", "Invalid rewardPercentiles %v - must be increasing values between 0 and 100")
+	MsgListenerNoAddresses       = ffe("FF23085", "Event listener %s was not created with the addresses option")
+	MsgListenerAddressesEmpty    = ffe("FF23086", "Event listener %s must retain at least one address - remove the listener instead")
+	MsgBadFactoryOptions         = ffe("FF23087", "The factory listener option requires the address and creation event of the factory")
+	MsgBadFactoryChildField      = ffe("FF23088", "The factory event '%s' does not have an address parameter named '%s'")
+	MsgReceiptListenerNotFound   = ffe("FF23089", "Receipt listener %s not found")
+	MsgMissingSignerURL          = ffe("FF23090", "Missing url for signer %d")
+	MsgBadSignerAddress          = ffe("FF23091", "Invalid address '%s' for signer %d")
+	MsgBadSignerAddressRange     = ffe("FF23092", "Invalid address range '%s' for signer %d - must be a start and end address separated by '-'")
+	MsgBadErrorMappingReason     = ffe("FF23093", "Invalid reason '%s' for error mapping %d. Valid reasons: %s")
+	MsgBadErrorMappingMethods    = ffe("FF23094", "Invalid method category '%s' for error mapping %d. Valid categories: %s")
+	MsgBadErrorMappingMessage    = ffe("FF23095", "Invalid message pattern '%s' for error mapping %d")
+	MsgErrorMappingNoMatch       = ffe("FF23096", "Error mapping %d must have a code or message to match")
+	MsgLoadTestNotEnabled        = ffe("FF23097", "The load test submits real transactions, and must be enabled in the loadtest configuration section")
+	MsgLoadTestMissingFrom       = ffe("FF23098", "The load test requires a from address")
+	MsgLoadTestBadRate           = ffe("FF23099", "Invalid load test rate %d or maxInFlight %d - both must be greater than zero")
+	MsgLogsResponseTooLarge      = ffe("FF23100", "Response size is larger than the limit of %d bytes set by events.maxLogsResponseSize")
+	MsgLogsQueryFailed           = ffe("FF23101", "eth_getLogs query failed: %s")
+	MsgInvalidProofAddress       = ffe("FF23102", "Invalid address '%s' for proof: %s")
+	MsgInvalidProofStorageKey    = ffe("FF23103", "Invalid storage key '%s' for proof - must be a 32 byte slot number in decimal or hex")
+	MsgInvalidProofBlock         = ffe("FF23104", "Invalid block '%s' for proof - must be a block number or tag")
+	MsgTxPoolNotAvailable        = ffe("FF23105", "The txpool API is not available on the node - enable the txpool API (geth) or TXPOOL API (Besu): %s")
+	MsgInvalidCallBlock          = ffe("FF23106", "Invalid blockNumber '%s' for query - must be a block number, block hash or tag")
+	MsgCircuitBreakerOpen        = ffe("FF23107", "Requests to the node are suspended after %d consecutive failures to connect - retrying in %s")
+	MsgENSNameNotFound           = ffe("FF23108", "ENS name '%s' is not registered, or does not resolve to an address")
+	MsgENSResolveFailed          = ffe("FF23109", "Failed to resolve ENS name '%s': %s")
+	MsgInvalidENSRegistry        = ffe("FF23110", "Invalid ENS registry address '%s': %s")
+	MsgSubmissionVetoed          = ffe("FF23111", "Transaction submission vetoed by the pre-submit hook: %s")
+	MsgSubmissionHookFailed      = ffe("FF23112", "Submission hook request failed: %s")
+	MsgDeployBatchDuplicateName  = ffe("FF23113", "Duplicate contract name '%s' in deployment batch")
+	MsgDeployBatchUnknownDep     = ffe("FF23114", "Contract '%s' depends on '%s', which is not in the deployment batch")
+	MsgDeployBatchCycle          = ffe("FF23115", "Contracts in the deployment batch have circular dependencies: %s")
+	MsgDeployBatchFailed         = ffe("FF23116", "Deployment of contract '%s' failed: %s")
+	MsgDeployBatchReverted       = ffe("FF23117", "Deployment of contract '%s' reverted in transaction %s")
+	MsgNoReorgHalt               = ffe("FF23118", "Block notifications are not halted by a deep re-org")
+	MsgDecodeCallDataFailed      = ffe("FF23119", "Failed to decode the call data encoded for method %s: %s")
+	MsgInvalidExcludeTopic       = ffe("FF23120", "Excluded value for topic %d is longer than 32 bytes: %s")
+	MsgInvalidGasLimitConfig     = ffe("FF23121", "Invalid %s '%s'")
+	MsgMissingNonce              = ffe("FF23122", "Nonce is required")
+	MsgNonceNotMined             = ffe("FF23123", "No transaction with nonce %s from %s has been mined - the next nonce is %s")
+	MsgInvalidEventSchema        = ffe("FF23125", "Invalid event schemaVersion '%s' - supported versions: %s")
+	MsgTransactionNotFoundNonce  = ffe("FF23124", "Transaction with nonce %s from %s not found in the %d blocks from %d to %d")
+	MsgEthConnectABINotFound     = ffe("FF23126", "ABI '%s' not found", 404)
+	MsgEthConnectNoContract      = ffe("FF23127", "No contract is registered at address '%s'", 404)
+	MsgEthConnectNoMethod        = ffe("FF23128", "Method '%s' not found in the ABI", 404)
+	MsgEthConnectBadRequest      = ffe("FF23129", "Invalid request: %s", 400)
+	MsgEthConnectMissingFrom     = ffe("FF23130", "The 'fly-from' query parameter, or 'x-firefly-from' header, is required", 400)
+	MsgEthConnectMissingParam    = ffe("FF23131", "Missing input '%s' for '%s'", 400)
+	MsgEthConnectNotDeployable   = ffe("FF23132", "ABI '%s' has no bytecode, so the contract cannot be deployed", 400)
+	MsgEthConnectReceiptTimeout  = ffe("FF23133", "Timed out waiting for the receipt of transaction %s")
+	MsgEthConnectStoreFailed     = ffe("FF23134", "Failed to persist '%s' in the ethconnect storage path: %s")
+	MsgInvalidTrustedCheckpoint  = ffe("FF23135", "Invalid %s '%s'")
+	MsgCheckpointNotReached      = ffe("FF23136", "Node has not reached trusted checkpoint block %d (head=%d)")
+	MsgCheckpointMismatch        = ffe("FF23137", "Block %d on the node has hash %s, which does not match the trusted checkpoint hash %s - the node is on a different network or fork")
+	MsgInvalidPriorityFeeConfig  = ffe("FF23138", "Invalid %s '%v'")
+	MsgPriorityFeeNoHistory      = ffe("FF23139", "eth_feeHistory returned no base fee")
+	MsgTxDataTooLarge            = ffe("FF23140", "Transaction data is %d bytes (%d zero bytes, intrinsic gas %d), which exceeds the maximum of %d bytes")
+	MsgGasBelowIntrinsic         = ffe("FF23141", "Transaction gas limit %s is below the intrinsic gas %d of the transaction (%d bytes of data, %d zero bytes)")
+	MsgSnapshotRequestFailed     = ffe("FF23142", "Snapshot storage request %s %s failed with status %d: %s")
+	MsgSnapshotNotFound          = ffe("FF23143", "No snapshot '%s' found to restore")
+	MsgSnapshotInvalid           = ffe("FF23144", "Invalid snapshot '%s': %s")
+	MsgInvalidEventOrdering      = ffe("FF23145", "Invalid events ordering '%s' - supported modes: %s")
+	MsgStorageWatchNoAddress     = ffe("FF23146", "Storage watch requires a contract address")
+	MsgStorageWatchNoSlots       = ffe("FF23147", "Storage watch requires at least one storage slot or mapping key")
+	MsgInvalidStorageSlot        = ffe("FF23148", "Invalid storage slot or mapping key '%s' - must be at most 32 bytes")
+	MsgNoActivityAddresses       = ffe("FF23149", "Address activity listener requires at least one address")
+	MsgEventWALFailed            = ffe("FF23150", "Failed to access the event write-ahead log '%s'")
+	MsgInvalidFilterTopic        = ffe("FF23151", "Filter value for topic %d is longer than 32 bytes: %s")
+	MsgWildcardFromBlockRequired = ffe("FF23152", "A fromBlock must be specified for a listener that matches events from all contracts")
+	MsgLeaderElectionFailed      = ffe("FF23153", "Failed to initialize leader election")
+	MsgStandbyInstance           = ffe("FF23154", "Instance '%s' is on standby, and does not submit transactions until it is elected leader")
+	MsgAuditFailed               = ffe("FF23155", "Failed to write audit records to '%s'")
+	MsgInvalidParamValue         = ffe("FF23156", "Invalid value for '%s' in %s: %s")
+	MsgDrainIncomplete           = ffe("FF23157", "Listener %s did not finish delivering the events already fetched before the request ended. It remains pausing")
+	MsgInvalidInfoField          = ffe("FF23158", "Unknown event metadata field '%s' in the fields option. Valid fields: %s")
+	MsgEncodeTargetInvalid       = ffe("FF23159", "Exactly one of 'method' or 'definition' must be supplied to encode call data")
+	MsgDecodeTargetInvalid       = ffe("FF23160", "Exactly one of 'method' or 'definition' must be supplied to decode call data")
+	MsgInvalidCallData           = ffe("FF23161", "Invalid call data '%s': %s")
+	MsgNoMethodForSelector       = ffe("FF23162", "No function in the ABI definition matches the selector of the call data: %s")
+	MsgInvalidRelayMethod        = ffe("FF23163", "Invalid private relay method '%s'. Valid methods: %s")
+	MsgBadRelayAddress           = ffe("FF23164", "Invalid address '%s' in the private relay addresses")
+	MsgRelayRequiresSigned       = ffe("FF23165", "Transactions from %s are sent via the private relay, so must be signed before submission")
+	MsgRelayNotTracked           = ffe("FF23166", "Transaction %s was not sent via the private relay, or is no longer tracked")
+	MsgInvalidReadQuorumMode     = ffe("FF23167", "Invalid read quorum mode '%s'. Valid modes: %s")
+	MsgReadQuorumMismatch        = ffe("FF23168", "The result of %s from the primary endpoint does not match the secondary endpoint: %s")
+	MsgIPCRequestFailed          = ffe("FF23169", "Backend IPC request failed: %s")
+	MsgInvalidSignatureLabels    = ffe("FF23170", "Invalid event signature labels file '%s': %v")
+	MsgInvalidBlockHashQueries   = ffe("FF23171", "Invalid block hash queries mode '%s'. Valid modes: %s")
+	MsgUnknownDependency         = ffe("FF23172", "Dependency '%s' has not been submitted through this connector")
+	MsgDependencyReverted        = ffe("FF23173", "Dependency '%s' reverted in transaction %s")
+	MsgDependencyTimeout         = ffe("FF23174", "Timed out waiting for dependency '%s' to be confirmed (tx=%s)")
+	MsgLogIndexAnomaly           = ffe("FF23175", "Logs for block %s (%s) have inconsistent log indexes after querying the block by hash: %s")
+	MsgBootstrapDisabled         = ffe("FF23176", "Listener bootstrap is not enabled - events.bootstrapDirectory must be configured")
+	MsgInvalidBootstrapFile      = ffe("FF23177", "Invalid bootstrap export '%s': %v")
+	MsgBootstrapBeforeFromBlock  = ffe("FF23178", "Bootstrap export '%s' ends at block %d, before the fromBlock %d of the listener")
+	MsgBootstrapOrderedStreams   = ffe("FF23179", "Listener bootstrap is not supported with events.ordering=stream")
+	MsgInvalidReorgStatistics    = ffe("FF23180", "Failed to load re-org statistics from '%s': %s")
+	MsgShuttingDown              = ffe("FF23181", "The connector is shutting down")
+	MsgShutdownTimeout           = ffe("FF23182", "Timed out after %s waiting for in-flight %s to complete during shutdown")
+	MsgConfigHasNoEffect         = ffe("FF23183", "Configuration '%s' has no effect unless '%s' is set")
+	MsgReorgDepthBeyondHead      = ffe("FF23184", "Configuration '%s' of %d is deeper than the %d blocks at the head of the chain tracked by the block listener, set by '%s'")
+	MsgInvalidCacheSize          = ffe("FF23185", "Configuration '%s' must be a cache size of at least 1 (%d)")
+	MsgUnknownConfigKeys         = ffe("FF23186", "Unknown configuration keys, which would be ignored: %s")
+	MsgConfirmationsBeyondHead   = ffe("FF23187", "Configuration '%s' of %d is more than the %d blocks at the head of the chain tracked by the block listener, set by '%s'. Re-orgs deeper than this are not detected")
+	MsgInsufficientFunds         = ffe("FF23188", "Pending balance %s of %s does not cover the maximum cost %s of the transaction - value %s plus gas limit %s at %s per gas")
+	MsgInvalidBlockRange         = ffe("FF23189", "Invalid block range fromBlock=%d toBlock=%d")
+	MsgBlockRangeTooLarge        = ffe("FF23190", "Block range of %d blocks is more than the maximum of %d set by '%s'")
+	MsgSimulatorNonceTooLow      = ffe("FF23191", "nonce too low: nonce %d for %s is below the next nonce %d")
+	MsgSimulatorNonceTooHigh     = ffe("FF23192", "Nonce %d for %s is ahead of the next nonce %d")
+	MsgSimulatorKnownTransaction = ffe("FF23193", "Transaction %s already known")
+	MsgExtensionsBadRequest      = ffe("FF23194", "Invalid request for '%s': %s", 400)
+	MsgPersistedCheckpointsRead  = ffe("FF23195", "Failed to read the persisted checkpoints of event stream %s")
+	MsgIdempotencyWindowTooLong  = ffe("FF23197", "Configuration '%s' of %s must be shorter than '%s' of %s, or re-submissions of stuck transactions are answered from the cache instead of reaching the node")
+	MsgLeaseTooShort             = ffe("FF23196", "Configuration '%s' of %s must be more than one and a half times '%s' of %s, for the leader to step down before its lease expires")
+	MsgOTLPExporterInit          = ffe("FF23198", "Failed to initialize the OTLP exporter of trace spans")
+	MsgLeaderElectionPersistence = ffe("FF23199", "Configuration '%s' must be the URL of the Postgres database FFTM persists to, set by '%s' and '%s', as a standby that is elected resumes each event stream from the checkpoints FFTM persisted")
+	MsgEventWALFull              = ffe("FF23200", "Write-ahead log '%s' holds %d events not yet acknowledged by FFTM, and is limited to %d. Events are dispatched once earlier events are acknowledged")
+	MsgLogsRequestFailed         = ffe("FF23201", "eth_getLogs request failed: %s")
+	MsgFeeBumpPercentTooLow      = ffe("FF23202", "Invalid %s %v - must be at least %v, as nodes refuse a replacement transaction with a smaller increase in fees")
+	MsgMissingReceiptListenerID  = ffe("FF23203", "A receipt listener ID is required")
 )
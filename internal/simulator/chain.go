@@ -0,0 +1,159 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+)
+
+// simBlock is a block in the simulated chain. Hashes are derived from the seed, number and
+// fork generation of the block - so the same script always produces the same chain.
+type simBlock struct {
+	number     int64
+	generation int
+	hash       ethtypes.HexBytes0xPrefix
+	parentHash ethtypes.HexBytes0xPrefix
+	timestamp  int64
+	// transactions are kept at the same block number through re-orgs, so they are re-mined in the new fork
+	transactions []*simTransaction
+}
+
+// blockJSONRPC is the block structure returned over JSON/RPC
+type blockJSONRPC struct {
+	Number       *ethtypes.HexInteger      `json:"number"`
+	Hash         ethtypes.HexBytes0xPrefix `json:"hash"`
+	ParentHash   ethtypes.HexBytes0xPrefix `json:"parentHash"`
+	Timestamp    *ethtypes.HexInteger      `json:"timestamp"`
+	Transactions []interface{}             `json:"transactions"` // hashes, or full transactions if requested
+}
+
+// chain is the canonical chain of the simulator. It is not thread safe - the simulator holds its lock
+type chain struct {
+	seed       string
+	startTime  int64
+	generation int
+	blocks     []*simBlock // indexed by block number
+	byHash     map[string]*simBlock
+	pending    []*simTransaction // included in the next block mined
+	txByHash   map[string]*simTransaction
+	nonces     map[string]int64 // next nonce of each address, including pending transactions
+}
+
+func newChain(seed string, startTime int64, startBlock int64) *chain {
+	c := &chain{
+		seed:      seed,
+		startTime: startTime,
+		byHash:    make(map[string]*simBlock),
+		txByHash:  make(map[string]*simTransaction),
+		nonces:    make(map[string]int64),
+	}
+	for i := int64(0); i <= startBlock; i++ {
+		c.mine()
+	}
+	return c
+}
+
+func (c *chain) blockHash(number int64, generation int) ethtypes.HexBytes0xPrefix {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s/%d/%d", c.seed, number, generation)))
+	return h[:]
+}
+
+func (c *chain) head() *simBlock {
+	return c.blocks[len(c.blocks)-1]
+}
+
+func (c *chain) newBlock(number int64) *simBlock {
+	b := &simBlock{
+		number:     number,
+		generation: c.generation,
+		hash:       c.blockHash(number, c.generation),
+		parentHash: make(ethtypes.HexBytes0xPrefix, 32),
+		timestamp:  c.startTime + number,
+	}
+	if number > 0 {
+		b.parentHash = c.blocks[number-1].hash
+	}
+	return b
+}
+
+// mine adds a new block to the head of the chain, containing all the pending transactions
+func (c *chain) mine() *simBlock {
+	b := c.newBlock(int64(len(c.blocks)))
+	b.transactions = c.pending
+	c.pending = nil
+	for i, tx := range b.transactions {
+		tx.blockNumber = b.number
+		tx.index = int64(i)
+	}
+	c.blocks = append(c.blocks, b)
+	c.byHash[b.hash.String()] = b
+	return b
+}
+
+// reorg replaces the top depth blocks of the chain with a new fork of the same length,
+// returning the old and new blocks. Blocks from the old fork are no longer available by hash.
+func (c *chain) reorg(depth int64) (removed, replaced []*simBlock) {
+	if depth > int64(len(c.blocks)-1) {
+		depth = int64(len(c.blocks) - 1) // we never replace the genesis block
+	}
+	c.generation++
+	removed = make([]*simBlock, 0, depth)
+	replaced = make([]*simBlock, 0, depth)
+	for number := int64(len(c.blocks)) - depth; number < int64(len(c.blocks)); number++ {
+		old := c.blocks[number]
+		delete(c.byHash, old.hash.String())
+		removed = append(removed, old)
+		b := c.newBlock(number)
+		b.transactions = old.transactions
+		c.blocks[number] = b
+		c.byHash[b.hash.String()] = b
+		replaced = append(replaced, b)
+	}
+	return removed, replaced
+}
+
+func (c *chain) getBlockByNumber(number int64) *simBlock {
+	if number < 0 || number >= int64(len(c.blocks)) {
+		return nil
+	}
+	return c.blocks[number]
+}
+
+func (c *chain) getBlockByHash(hash string) *simBlock {
+	return c.byHash[hash]
+}
+
+func (b *simBlock) toJSONRPC(fullTransactions bool) *blockJSONRPC {
+	transactions := make([]interface{}, len(b.transactions))
+	for i, tx := range b.transactions {
+		if fullTransactions {
+			transactions[i] = tx.toJSONRPC(b)
+		} else {
+			transactions[i] = tx.hash
+		}
+	}
+	return &blockJSONRPC{
+		Number:       ethtypes.NewHexInteger64(b.number),
+		Hash:         b.hash,
+		ParentHash:   b.parentHash,
+		Timestamp:    ethtypes.NewHexInteger64(b.timestamp),
+		Transactions: transactions,
+	}
+}
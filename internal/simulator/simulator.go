@@ -0,0 +1,423 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simulator provides a deterministic fake EVM chain served over JSON/RPC, with scripted
+// re-orgs, dropped filters and latency injection. It is intended for validating confirmation and
+// re-org behavior of a FireFly deployment against evmconnect, without requiring a real node.
+//
+// Transactions are accepted signed (eth_sendRawTransaction) or for signing by the node (eth_sendTransaction),
+// and are mined into the next block. Contracts are not executed: every transaction succeeds, eth_call returns
+// empty data, and every transaction sent to an address logs an Invoked event (see InvokedEventABI) so that
+// event delivery can be exercised. Transactions stay at the same block number when a re-org replaces
+// their block, so receipts and logs move to the new block hash as they would on a real chain.
+package simulator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"gopkg.in/yaml.v2"
+)
+
+const rpcCodeMethodNotFound rpcbackend.RPCCode = -32601
+
+// Script describes the scenario played out by the simulator. It can be supplied as YAML or JSON.
+type Script struct {
+	Seed          string           `yaml:"seed"`          // seed for the deterministic block hashes and latency jitter
+	ChainID       int64            `yaml:"chainId"`       // returned from eth_chainId and net_version
+	StartBlock    int64            `yaml:"startBlock"`    // the head block number when the simulator starts
+	StartTime     int64            `yaml:"startTime"`     // unix timestamp of the genesis block (each block is one second later)
+	BlockInterval time.Duration    `yaml:"blockInterval"` // interval to mine new blocks - zero means blocks are only mined via evm_mine
+	Latency       time.Duration    `yaml:"latency"`       // fixed latency added to every JSON/RPC response
+	LatencyJitter time.Duration    `yaml:"latencyJitter"` // maximum random latency added on top of the fixed latency
+	Reorgs        []*ScriptedReorg `yaml:"reorgs"`        // re-orgs to perform as the chain grows
+	DropFilters   []int64          `yaml:"dropFilters"`   // block numbers at which all installed filters are dropped
+}
+
+// ScriptedReorg replaces the top Depth blocks of the chain with a new fork, as soon as block AtBlock is mined
+type ScriptedReorg struct {
+	AtBlock int64 `yaml:"atBlock"`
+	Depth   int64 `yaml:"depth"`
+}
+
+type filterType int
+
+const (
+	filterTypeBlock filterType = iota
+	filterTypeLogs
+)
+
+type simFilter struct {
+	filterType  filterType
+	criteria    *logCriteria
+	pending     []ethtypes.HexBytes0xPrefix // block hashes not yet returned by eth_getFilterChanges
+	pendingLogs []*logJSONRPC               // logs not yet returned by eth_getFilterChanges
+}
+
+// Simulator serves a simulated chain over JSON/RPC
+type Simulator struct {
+	ctx         context.Context
+	script      *Script
+	mux         sync.Mutex
+	chain       *chain
+	filters     map[string]*simFilter
+	filterCount int
+	reorgs      map[int64]*ScriptedReorg
+	dropFilters map[int64]bool
+	rand        *rand.Rand
+}
+
+// DefaultScript returns the script used when none is supplied - a chain that mines a block a second
+func DefaultScript() *Script {
+	return &Script{
+		Seed:          "evmconnect",
+		ChainID:       1337,
+		StartTime:     1700000000,
+		BlockInterval: 1 * time.Second,
+	}
+}
+
+// LoadScript reads a simulator script from a YAML or JSON file
+func LoadScript(ctx context.Context, filename string) (*Script, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgSimulatorScriptInvalid, filename, err)
+	}
+	script := DefaultScript()
+	if err := yaml.Unmarshal(b, script); err != nil {
+		return nil, i18n.NewError(ctx, msgs.MsgSimulatorScriptInvalid, filename, err)
+	}
+	return script, nil
+}
+
+func NewSimulator(ctx context.Context, script *Script) *Simulator {
+	seedHash := sha256.Sum256([]byte(script.Seed))
+	s := &Simulator{
+		ctx:         log.WithLogField(ctx, "role", "simulator"),
+		script:      script,
+		chain:       newChain(script.Seed, script.StartTime, script.StartBlock),
+		filters:     make(map[string]*simFilter),
+		reorgs:      make(map[int64]*ScriptedReorg),
+		dropFilters: make(map[int64]bool),
+		rand:        rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(seedHash[0:8])))), //nolint:gosec // determinism, not security
+	}
+	for _, r := range script.Reorgs {
+		s.reorgs[r.AtBlock] = r
+	}
+	for _, b := range script.DropFilters {
+		s.dropFilters[b] = true
+	}
+	return s
+}
+
+// Run serves JSON/RPC on the supplied listener, and mines blocks at the scripted interval, until the context is cancelled
+func (s *Simulator) Run(listener net.Listener) error {
+	ctx, cancelCtx := context.WithCancel(s.ctx)
+	server := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	mineLoopDone := make(chan struct{})
+	go s.mineLoop(ctx, mineLoopDone)
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	log.L(ctx).Infof("Simulator listening on %s (chainId=%d startBlock=%d)", listener.Addr(), s.script.ChainID, s.script.StartBlock)
+	err := server.Serve(listener)
+	cancelCtx()
+	<-mineLoopDone
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (s *Simulator) mineLoop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	if s.script.BlockInterval <= 0 {
+		<-ctx.Done()
+		return
+	}
+	ticker := time.NewTicker(s.script.BlockInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Mine(1)
+		case <-ctx.Done():
+			log.L(ctx).Debugf("Simulator mining loop stopping")
+			return
+		}
+	}
+}
+
+// Mine adds the specified number of blocks to the chain, performing any scripted re-orgs and filter drops
+func (s *Simulator) Mine(count int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for i := 0; i < count; i++ {
+		b := s.chain.mine()
+		newBlocks := []*simBlock{b}
+		var removedBlocks []*simBlock
+		if r := s.reorgs[b.number]; r != nil {
+			log.L(s.ctx).Infof("Scripted re-org at block %d depth=%d", b.number, r.Depth)
+			var removed []*simBlock
+			removed, newBlocks = s.chain.reorg(r.Depth)
+			for _, rb := range removed {
+				// The block just mined was never notified, so neither were its logs
+				if rb.number < b.number {
+					removedBlocks = append(removedBlocks, rb)
+				}
+			}
+		}
+		if s.dropFilters[b.number] {
+			log.L(s.ctx).Infof("Scripted drop of %d filters at block %d", len(s.filters), b.number)
+			s.filters = make(map[string]*simFilter)
+		}
+		for _, f := range s.filters {
+			switch f.filterType {
+			case filterTypeBlock:
+				for _, nb := range newBlocks {
+					f.pending = append(f.pending, nb.hash)
+				}
+			case filterTypeLogs:
+				f.pendingLogs = append(f.pendingLogs, f.criteria.blockLogs(removedBlocks, true)...)
+				f.pendingLogs = append(f.pendingLogs, f.criteria.blockLogs(newBlocks, false)...)
+			}
+		}
+		log.L(s.ctx).Debugf("Mined block %d / %s", b.number, s.chain.head().hash)
+	}
+}
+
+func (s *Simulator) injectLatency() {
+	s.mux.Lock()
+	delay := s.script.Latency
+	if s.script.LatencyJitter > 0 {
+		delay += time.Duration(s.rand.Int63n(int64(s.script.LatencyJitter)))
+	}
+	s.mux.Unlock()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+		}
+	}
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req rpcbackend.RPCRequest
+	var res *rpcbackend.RPCResponse
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res = rpcbackend.RPCErrorResponse(err, nil, rpcbackend.RPCCodeParseError)
+	} else {
+		s.injectLatency()
+		res = s.processRequest(r.Context(), &req)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (s *Simulator) processRequest(ctx context.Context, req *rpcbackend.RPCRequest) *rpcbackend.RPCResponse {
+	result, rpcErr := s.dispatch(ctx, req)
+	if rpcErr != nil {
+		log.L(ctx).Debugf("Simulator %s failed: %s", req.Method, rpcErr.Message)
+		return &rpcbackend.RPCResponse{JSONRpc: "2.0", ID: req.ID, Error: rpcErr}
+	}
+	b, _ := json.Marshal(result)
+	return &rpcbackend.RPCResponse{JSONRpc: "2.0", ID: req.ID, Result: fftypes.JSONAnyPtrBytes(b)}
+}
+
+func (s *Simulator) dispatch(ctx context.Context, req *rpcbackend.RPCRequest) (interface{}, *rpcbackend.RPCError) {
+	if req.Method == "evm_mine" {
+		s.Mine(1)
+		return "0x0", nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	switch req.Method {
+	case "eth_chainId":
+		return ethtypes.NewHexInteger64(s.script.ChainID), nil
+	case "net_version":
+		return strconv.FormatInt(s.script.ChainID, 10), nil
+	case "eth_blockNumber":
+		return ethtypes.NewHexInteger64(s.chain.head().number), nil
+	case "eth_getBlockByNumber":
+		var blockRef string
+		if err := parseParams(ctx, req, &blockRef); err != nil {
+			return nil, err
+		}
+		number, err := s.resolveBlockRef(ctx, req.Method, blockRef)
+		if err != nil {
+			return nil, err
+		}
+		return s.blockResult(s.chain.getBlockByNumber(number), fullTransactions(req)), nil
+	case "eth_getBlockByHash":
+		var hash ethtypes.HexBytes0xPrefix
+		if err := parseParams(ctx, req, &hash); err != nil {
+			return nil, err
+		}
+		return s.blockResult(s.chain.getBlockByHash(hash.String()), fullTransactions(req)), nil
+	case "eth_newBlockFilter":
+		return s.newFilter(filterTypeBlock, nil), nil
+	case "eth_newFilter":
+		var filterJSON logFilterJSONRPC
+		if err := parseParams(ctx, req, &filterJSON); err != nil {
+			return nil, err
+		}
+		criteria, err := parseLogFilter(ctx, req.Method, &filterJSON)
+		if err != nil {
+			return nil, err
+		}
+		return s.newFilter(filterTypeLogs, criteria), nil
+	case "eth_getFilterChanges", "eth_getFilterLogs":
+		var filterID string
+		if err := parseParams(ctx, req, &filterID); err != nil {
+			return nil, err
+		}
+		if f := s.filters[filterID]; f != nil && f.filterType == filterTypeLogs && req.Method == "eth_getFilterLogs" {
+			return s.getLogs(ctx, req.Method, f.criteria)
+		}
+		return s.getFilterChanges(ctx, filterID)
+	case "eth_uninstallFilter":
+		var filterID string
+		if err := parseParams(ctx, req, &filterID); err != nil {
+			return nil, err
+		}
+		_, ok := s.filters[filterID]
+		delete(s.filters, filterID)
+		return ok, nil
+	case "eth_getLogs":
+		var filterJSON logFilterJSONRPC
+		if err := parseParams(ctx, req, &filterJSON); err != nil {
+			return nil, err
+		}
+		criteria, err := parseLogFilter(ctx, req.Method, &filterJSON)
+		if err != nil {
+			return nil, err
+		}
+		return s.getLogs(ctx, req.Method, criteria)
+	case "eth_sendTransaction":
+		return s.sendTransaction(ctx, req)
+	case "eth_sendRawTransaction":
+		return s.sendRawTransaction(ctx, req)
+	case "eth_getTransactionByHash":
+		return s.getTransaction(ctx, req, false)
+	case "eth_getTransactionReceipt":
+		return s.getTransaction(ctx, req, true)
+	case "eth_getTransactionCount":
+		return s.getTransactionCount(ctx, req)
+	case "eth_estimateGas":
+		return s.estimateGas(ctx, req)
+	case "eth_gasPrice":
+		return ethtypes.NewHexInteger64(simGasPrice), nil
+	case "eth_getBalance":
+		return (*ethtypes.HexInteger)(simBalance), nil
+	case "eth_call":
+		// Contracts are not executed, so calls return no data
+		return ethtypes.HexBytes0xPrefix{}, nil
+	default:
+		return nil, rpcbackend.NewRPCError(ctx, rpcCodeMethodNotFound, msgs.MsgSimulatorMethodNotFound, req.Method)
+	}
+}
+
+func parseParams(ctx context.Context, req *rpcbackend.RPCRequest, targets ...interface{}) *rpcbackend.RPCError {
+	if len(req.Params) < len(targets) {
+		return rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInvalidRequest, msgs.MsgSimulatorInvalidParams, req.Method, fmt.Sprintf("expected %d params", len(targets)))
+	}
+	for i, t := range targets {
+		if err := json.Unmarshal(req.Params[i].Bytes(), t); err != nil {
+			return rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInvalidRequest, msgs.MsgSimulatorInvalidParams, req.Method, err)
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) resolveBlockRef(ctx context.Context, method, blockRef string) (int64, *rpcbackend.RPCError) {
+	switch blockRef {
+	case "latest", "pending", "safe", "finalized":
+		return s.chain.head().number, nil
+	case "earliest":
+		return 0, nil
+	}
+	var number ethtypes.HexInteger
+	if err := json.Unmarshal([]byte(strconv.Quote(blockRef)), &number); err != nil {
+		return -1, rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInvalidRequest, msgs.MsgSimulatorInvalidParams, method, err)
+	}
+	return number.BigInt().Int64(), nil
+}
+
+// fullTransactions returns the optional second parameter of eth_getBlockByNumber/eth_getBlockByHash
+func fullTransactions(req *rpcbackend.RPCRequest) bool {
+	var full bool
+	if len(req.Params) > 1 {
+		_ = json.Unmarshal(req.Params[1].Bytes(), &full)
+	}
+	return full
+}
+
+func (s *Simulator) blockResult(b *simBlock, fullTransactions bool) interface{} {
+	if b == nil {
+		return nil
+	}
+	return b.toJSONRPC(fullTransactions)
+}
+
+func (s *Simulator) newFilter(filterType filterType, criteria *logCriteria) string {
+	s.filterCount++
+	filterID := ethtypes.NewHexInteger64(int64(s.filterCount)).String()
+	s.filters[filterID] = &simFilter{filterType: filterType, criteria: criteria}
+	return filterID
+}
+
+func (s *Simulator) getFilterChanges(ctx context.Context, filterID string) (interface{}, *rpcbackend.RPCError) {
+	f := s.filters[filterID]
+	if f == nil {
+		return nil, rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInternalError, msgs.MsgSimulatorFilterNotFound, filterID)
+	}
+	if f.filterType == filterTypeLogs {
+		logs := f.pendingLogs
+		f.pendingLogs = nil
+		if logs == nil {
+			logs = []*logJSONRPC{}
+		}
+		return logs, nil
+	}
+	changes := f.pending
+	f.pending = nil
+	if changes == nil {
+		changes = []ethtypes.HexBytes0xPrefix{}
+	}
+	return changes, nil
+}
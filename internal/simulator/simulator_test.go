@@ -0,0 +1,539 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-signer/pkg/ethsigner"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"github.com/hyperledger/firefly-signer/pkg/secp256k1"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSimulator(t *testing.T, script *Script) (context.Context, *Simulator, rpcbackend.Backend, func()) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	s := NewSimulator(ctx, script)
+	server := httptest.NewServer(s)
+	rpc := rpcbackend.NewRPCClient(ffresty.NewWithConfig(ctx, ffresty.Config{URL: server.URL}))
+	return ctx, s, rpc, func() {
+		cancelCtx()
+		server.Close()
+	}
+}
+
+func TestSimulatorBlocksDeterministic(t *testing.T) {
+	script := &Script{Seed: "test", ChainID: 12345, StartBlock: 10, StartTime: 1000}
+	ctx, _, rpc, done := newTestSimulator(t, script)
+	defer done()
+
+	var blockNumber ethtypes.HexInteger
+	rpcErr := rpc.CallRPC(ctx, &blockNumber, "eth_blockNumber")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(10), blockNumber.BigInt().Int64())
+
+	var chainID ethtypes.HexInteger
+	rpcErr = rpc.CallRPC(ctx, &chainID, "eth_chainId")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(12345), chainID.BigInt().Int64())
+
+	var netVersion string
+	rpcErr = rpc.CallRPC(ctx, &netVersion, "net_version")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, "12345", netVersion)
+
+	var b10, b9, byHash *blockJSONRPC
+	rpcErr = rpc.CallRPC(ctx, &b10, "eth_getBlockByNumber", "latest", false)
+	assert.Nil(t, rpcErr)
+	rpcErr = rpc.CallRPC(ctx, &b9, "eth_getBlockByNumber", ethtypes.NewHexInteger64(9), false)
+	assert.Nil(t, rpcErr)
+	rpcErr = rpc.CallRPC(ctx, &byHash, "eth_getBlockByHash", b10.Hash, false)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, b9.Hash, b10.ParentHash)
+	assert.Equal(t, b10.Hash, byHash.Hash)
+	assert.Equal(t, int64(1010), b10.Timestamp.BigInt().Int64())
+
+	// Same seed, same chain
+	s2 := NewSimulator(ctx, &Script{Seed: "test", StartBlock: 10})
+	assert.Equal(t, b10.Hash, s2.chain.head().hash)
+
+	var genesis, missing *blockJSONRPC
+	rpcErr = rpc.CallRPC(ctx, &genesis, "eth_getBlockByNumber", "earliest", false)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(0), genesis.Number.BigInt().Int64())
+	rpcErr = rpc.CallRPC(ctx, &missing, "eth_getBlockByNumber", ethtypes.NewHexInteger64(11), false)
+	assert.Nil(t, rpcErr)
+	assert.Nil(t, missing)
+}
+
+func TestSimulatorReorgAndFilters(t *testing.T) {
+	script := &Script{
+		Seed:        "test",
+		StartBlock:  5,
+		Reorgs:      []*ScriptedReorg{{AtBlock: 7, Depth: 2}},
+		DropFilters: []int64{8},
+	}
+	ctx, s, rpc, done := newTestSimulator(t, script)
+	defer done()
+
+	var blockFilter, logFilter string
+	rpcErr := rpc.CallRPC(ctx, &blockFilter, "eth_newBlockFilter")
+	assert.Nil(t, rpcErr)
+	rpcErr = rpc.CallRPC(ctx, &logFilter, "eth_newFilter", map[string]interface{}{})
+	assert.Nil(t, rpcErr)
+
+	var b6 *blockJSONRPC
+	s.Mine(1)
+	rpcErr = rpc.CallRPC(ctx, &b6, "eth_getBlockByNumber", "latest", false)
+	assert.Nil(t, rpcErr)
+
+	var hashes []ethtypes.HexBytes0xPrefix
+	rpcErr = rpc.CallRPC(ctx, &hashes, "eth_getFilterChanges", blockFilter)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, []ethtypes.HexBytes0xPrefix{b6.Hash}, hashes)
+
+	// Mining block 7 triggers a re-org of blocks 6+7
+	var res string
+	rpcErr = rpc.CallRPC(ctx, &res, "evm_mine")
+	assert.Nil(t, rpcErr)
+	rpcErr = rpc.CallRPC(ctx, &hashes, "eth_getFilterChanges", blockFilter)
+	assert.Nil(t, rpcErr)
+	assert.Len(t, hashes, 2)
+	assert.NotEqual(t, b6.Hash, hashes[0])
+
+	var oldB6, newB7 *blockJSONRPC
+	rpcErr = rpc.CallRPC(ctx, &oldB6, "eth_getBlockByHash", b6.Hash, false)
+	assert.Nil(t, rpcErr)
+	assert.Nil(t, oldB6)
+	rpcErr = rpc.CallRPC(ctx, &newB7, "eth_getBlockByHash", hashes[1], false)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, hashes[0], newB7.ParentHash)
+
+	var logs []interface{}
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getFilterLogs", logFilter)
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, logs)
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getLogs", map[string]interface{}{})
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, logs)
+
+	// Mining block 8 drops all the filters
+	s.Mine(1)
+	rpcErr = rpc.CallRPC(ctx, &hashes, "eth_getFilterChanges", blockFilter)
+	assert.Regexp(t, "FF23056", rpcErr.Message)
+	assert.True(t, strings.Contains(strings.ToLower(rpcErr.Message), "filter not found"))
+
+	var uninstalled bool
+	rpcErr = rpc.CallRPC(ctx, &uninstalled, "eth_uninstallFilter", logFilter)
+	assert.Nil(t, rpcErr)
+	assert.False(t, uninstalled)
+}
+
+func TestSimulatorEmptyFilterChanges(t *testing.T) {
+	ctx, _, rpc, done := newTestSimulator(t, &Script{Seed: "test"})
+	defer done()
+
+	var blockFilter string
+	rpcErr := rpc.CallRPC(ctx, &blockFilter, "eth_newBlockFilter")
+	assert.Nil(t, rpcErr)
+
+	var hashes []ethtypes.HexBytes0xPrefix
+	rpcErr = rpc.CallRPC(ctx, &hashes, "eth_getFilterChanges", blockFilter)
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, hashes)
+
+	var uninstalled bool
+	rpcErr = rpc.CallRPC(ctx, &uninstalled, "eth_uninstallFilter", blockFilter)
+	assert.Nil(t, rpcErr)
+	assert.True(t, uninstalled)
+}
+
+func TestSimulatorReorgDeeperThanChain(t *testing.T) {
+	s := NewSimulator(context.Background(), &Script{
+		Seed:   "test",
+		Reorgs: []*ScriptedReorg{{AtBlock: 2, Depth: 10}},
+	})
+	genesis := s.chain.getBlockByNumber(0)
+	s.Mine(2)
+	assert.Equal(t, genesis, s.chain.getBlockByNumber(0))
+	assert.Equal(t, 1, s.chain.head().generation)
+}
+
+func TestSimulatorBadRequests(t *testing.T) {
+	ctx, _, rpc, done := newTestSimulator(t, &Script{Seed: "test"})
+	defer done()
+
+	var res interface{}
+	rpcErr := rpc.CallRPC(ctx, &res, "eth_unknown")
+	assert.Regexp(t, "FF23057", rpcErr.Message)
+	assert.Equal(t, int64(rpcCodeMethodNotFound), rpcErr.Code)
+
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getBlockByNumber")
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getBlockByNumber", 12345)
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getBlockByNumber", "wrong")
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getBlockByHash", "wrong")
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getFilterChanges")
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_uninstallFilter")
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+}
+
+func TestSimulatorBadJSON(t *testing.T) {
+	s := NewSimulator(context.Background(), &Script{Seed: "test"})
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	res, err := http.Post(server.URL, "application/json", strings.NewReader("!json"))
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestSimulatorLatency(t *testing.T) {
+	ctx, _, rpc, done := newTestSimulator(t, &Script{
+		Seed:          "test",
+		Latency:       10 * time.Millisecond,
+		LatencyJitter: 1 * time.Millisecond,
+	})
+	defer done()
+
+	start := time.Now()
+	var blockNumber ethtypes.HexInteger
+	rpcErr := rpc.CallRPC(ctx, &blockNumber, "eth_blockNumber")
+	assert.Nil(t, rpcErr)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+}
+
+func TestSimulatorRunMining(t *testing.T) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	s := NewSimulator(ctx, &Script{Seed: "test", BlockInterval: 1 * time.Millisecond})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	runDone := make(chan error)
+	go func() {
+		runDone <- s.Run(l)
+	}()
+
+	for {
+		s.mux.Lock()
+		head := s.chain.head().number
+		s.mux.Unlock()
+		if head >= 3 {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	cancelCtx()
+	assert.NoError(t, <-runDone)
+}
+
+func TestSimulatorRunNoMining(t *testing.T) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	s := NewSimulator(ctx, &Script{Seed: "test"})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	l.Close() // will fail to serve
+
+	err = s.Run(l)
+	assert.Error(t, err)
+	cancelCtx()
+}
+
+func TestLoadScript(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "script.yaml")
+	err := os.WriteFile(filename, []byte(`
+seed: myseed
+chainId: 42
+startBlock: 100
+blockInterval: 2s
+latency: 50ms
+reorgs:
+- atBlock: 110
+  depth: 3
+dropFilters: [105]
+`), 0600)
+	assert.NoError(t, err)
+
+	script, err := LoadScript(context.Background(), filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "myseed", script.Seed)
+	assert.Equal(t, int64(42), script.ChainID)
+	assert.Equal(t, int64(100), script.StartBlock)
+	assert.Equal(t, int64(1700000000), script.StartTime)
+	assert.Equal(t, 2*time.Second, script.BlockInterval)
+	assert.Equal(t, 50*time.Millisecond, script.Latency)
+	assert.Equal(t, []*ScriptedReorg{{AtBlock: 110, Depth: 3}}, script.Reorgs)
+	assert.Equal(t, []int64{105}, script.DropFilters)
+}
+
+func TestLoadScriptFail(t *testing.T) {
+	_, err := LoadScript(context.Background(), t.TempDir())
+	assert.Regexp(t, "FF23055", err)
+
+	filename := filepath.Join(t.TempDir(), "script.yaml")
+	err = os.WriteFile(filename, []byte(`!badness: [`), 0600)
+	assert.NoError(t, err)
+	_, err = LoadScript(context.Background(), filename)
+	assert.Regexp(t, "FF23055", err)
+}
+
+func TestSimulatorTransactionsReceiptsAndLogs(t *testing.T) {
+	script := &Script{
+		Seed:       "test",
+		StartBlock: 5,
+		Reorgs:     []*ScriptedReorg{{AtBlock: 8, Depth: 2}},
+	}
+	ctx, s, rpc, done := newTestSimulator(t, script)
+	defer done()
+
+	from := ethtypes.MustNewAddress("0x1f185718734552d08278aa70f804580bab5fd2b4")
+	to := ethtypes.MustNewAddress("0x497eedc4299dea2f2a364be10025d0ad0f702de3")
+
+	var logFilter, otherFilter string
+	rpcErr := rpc.CallRPC(ctx, &logFilter, "eth_newFilter", map[string]interface{}{
+		"address": to,
+		"topics":  []interface{}{invokedEventTopic, nil},
+	})
+	assert.Nil(t, rpcErr)
+	rpcErr = rpc.CallRPC(ctx, &otherFilter, "eth_newFilter", map[string]interface{}{
+		"address": []string{"0x5d8d9a0f4e8c6e8a3e3c8e1a2f4f0e9e3e8d1c2b"},
+	})
+	assert.Nil(t, rpcErr)
+
+	var gas, gasPrice, nonce ethtypes.HexInteger
+	rpcErr = rpc.CallRPC(ctx, &gas, "eth_estimateGas", map[string]interface{}{"from": from, "to": to, "data": "0xfeedbeef"})
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(21064), gas.BigInt().Int64())
+	rpcErr = rpc.CallRPC(ctx, &gasPrice, "eth_gasPrice")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(simGasPrice), gasPrice.BigInt().Int64())
+
+	var txHash, deployHash ethtypes.HexBytes0xPrefix
+	rpcErr = rpc.CallRPC(ctx, &txHash, "eth_sendTransaction", map[string]interface{}{"from": from, "to": to, "data": "0xfeedbeef"})
+	assert.Nil(t, rpcErr)
+	rpcErr = rpc.CallRPC(ctx, &deployHash, "eth_sendTransaction", map[string]interface{}{"from": from, "nonce": "0x1", "data": "0x6080"})
+	assert.Nil(t, rpcErr)
+	rpcErr = rpc.CallRPC(ctx, &txHash, "eth_sendTransaction", map[string]interface{}{"from": from, "nonce": "0x0"})
+	assert.Regexp(t, "FF23193", rpcErr.Message)
+	rpcErr = rpc.CallRPC(ctx, &txHash, "eth_sendTransaction", map[string]interface{}{"from": from, "nonce": "0x5", "to": to})
+	assert.Regexp(t, "FF23192", rpcErr.Message)
+
+	rpcErr = rpc.CallRPC(ctx, &nonce, "eth_getTransactionCount", from, "pending")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(2), nonce.BigInt().Int64())
+	rpcErr = rpc.CallRPC(ctx, &nonce, "eth_getTransactionCount", from, "latest")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(0), nonce.BigInt().Int64())
+
+	// Pending until mined
+	var pendingTx *transactionJSONRPC
+	var receipt *receiptJSONRPC
+	rpcErr = rpc.CallRPC(ctx, &pendingTx, "eth_getTransactionByHash", txHash)
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, pendingTx.BlockHash)
+	rpcErr = rpc.CallRPC(ctx, &receipt, "eth_getTransactionReceipt", txHash)
+	assert.Nil(t, rpcErr)
+	assert.Nil(t, receipt)
+
+	s.Mine(1)
+	var b6 *blockJSONRPC
+	rpcErr = rpc.CallRPC(ctx, &b6, "eth_getBlockByNumber", "latest", false)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, []interface{}{txHash.String(), deployHash.String()}, b6.Transactions)
+
+	rpcErr = rpc.CallRPC(ctx, &receipt, "eth_getTransactionReceipt", txHash)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, b6.Hash, receipt.BlockHash)
+	assert.Equal(t, int64(1), receipt.Status.BigInt().Int64())
+	assert.Len(t, receipt.Logs, 1)
+	assert.Equal(t, to.String(), receipt.Logs[0].Address.String())
+	assert.Equal(t, "0x0000000000000000000000001f185718734552d08278aa70f804580bab5fd2b4", receipt.Logs[0].Topics[1].String())
+	assert.Equal(t, "0x0000000000000000000000000000000000000000000000000000000000000020"+
+		"0000000000000000000000000000000000000000000000000000000000000004"+
+		"feedbeef00000000000000000000000000000000000000000000000000000000", receipt.Logs[0].Data.String())
+
+	var deployReceipt *receiptJSONRPC
+	rpcErr = rpc.CallRPC(ctx, &deployReceipt, "eth_getTransactionReceipt", deployHash)
+	assert.Nil(t, rpcErr)
+	assert.NotNil(t, deployReceipt.ContractAddress)
+	assert.Empty(t, deployReceipt.Logs)
+	assert.Equal(t, int64(21064+21032), deployReceipt.CumulativeGasUsed.BigInt().Int64())
+
+	var logs []*logJSONRPC
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getFilterChanges", logFilter)
+	assert.Nil(t, rpcErr)
+	assert.Len(t, logs, 1)
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getFilterChanges", otherFilter)
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, logs)
+
+	// Mining block 8 re-orgs blocks 7+8, which does not affect block 6
+	s.Mine(2)
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getFilterChanges", logFilter)
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, logs)
+
+	// Now a transaction in block 9, re-orged by a scripted re-org at block 10
+	script.Reorgs = append(script.Reorgs, &ScriptedReorg{AtBlock: 10, Depth: 2})
+	s.reorgs[10] = script.Reorgs[1]
+	rpcErr = rpc.CallRPC(ctx, &txHash, "eth_sendTransaction", map[string]interface{}{"from": from, "to": to})
+	assert.Nil(t, rpcErr)
+	s.Mine(1)
+	rpcErr = rpc.CallRPC(ctx, &receipt, "eth_getTransactionReceipt", txHash)
+	assert.Nil(t, rpcErr)
+	oldBlockHash := receipt.BlockHash
+	s.Mine(1)
+	rpcErr = rpc.CallRPC(ctx, &receipt, "eth_getTransactionReceipt", txHash)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, int64(9), receipt.BlockNumber.BigInt().Int64())
+	assert.NotEqual(t, oldBlockHash, receipt.BlockHash)
+
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getFilterChanges", logFilter)
+	assert.Nil(t, rpcErr)
+	assert.Len(t, logs, 3)
+	assert.False(t, logs[0].Removed)
+	assert.Equal(t, oldBlockHash, logs[0].BlockHash)
+	assert.True(t, logs[1].Removed)
+	assert.Equal(t, oldBlockHash, logs[1].BlockHash)
+	assert.False(t, logs[2].Removed)
+	assert.Equal(t, receipt.BlockHash, logs[2].BlockHash)
+
+	// Query the logs on the canonical chain
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getFilterLogs", logFilter)
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, logs) // the filter is from the latest block
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getLogs", map[string]interface{}{"fromBlock": "0x0", "address": to})
+	assert.Nil(t, rpcErr)
+	assert.Len(t, logs, 2)
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getLogs", map[string]interface{}{"fromBlock": "earliest", "topics": []interface{}{[]interface{}{invokedEventTopic}, "0x01"}})
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, logs)
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getLogs", map[string]interface{}{"blockHash": receipt.BlockHash})
+	assert.Nil(t, rpcErr)
+	assert.Len(t, logs, 1)
+	rpcErr = rpc.CallRPC(ctx, &logs, "eth_getLogs", map[string]interface{}{"blockHash": oldBlockHash})
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, logs)
+
+	var fullBlock map[string]interface{}
+	rpcErr = rpc.CallRPC(ctx, &fullBlock, "eth_getBlockByHash", receipt.BlockHash, true)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, txHash.String(), fullBlock["transactions"].([]interface{})[0].(map[string]interface{})["hash"])
+
+	var balance ethtypes.HexInteger
+	rpcErr = rpc.CallRPC(ctx, &balance, "eth_getBalance", from, "latest")
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, simBalance.String(), balance.BigInt().String())
+	var callResult ethtypes.HexBytes0xPrefix
+	rpcErr = rpc.CallRPC(ctx, &callResult, "eth_call", map[string]interface{}{"to": to}, "latest")
+	assert.Nil(t, rpcErr)
+	assert.Empty(t, callResult)
+}
+
+func TestSimulatorSendRawTransaction(t *testing.T) {
+	ctx, s, rpc, done := newTestSimulator(t, &Script{Seed: "test", ChainID: 1337})
+	defer done()
+
+	keypair, err := secp256k1.GenerateSecp256k1KeyPair()
+	assert.NoError(t, err)
+	to := ethtypes.MustNewAddress("0x497eedc4299dea2f2a364be10025d0ad0f702de3")
+	signTx := func(nonce int64) ethtypes.HexBytes0xPrefix {
+		raw, err := (&ethsigner.Transaction{
+			Nonce:                ethtypes.NewHexInteger64(nonce),
+			MaxPriorityFeePerGas: ethtypes.NewHexInteger64(1),
+			MaxFeePerGas:         ethtypes.NewHexInteger64(2000000000),
+			GasLimit:             ethtypes.NewHexInteger64(50000),
+			To:                   to,
+			Value:                ethtypes.NewHexInteger64(0),
+			Data:                 ethtypes.MustNewHexBytes0xPrefix("0x01"),
+		}).SignEIP1559(keypair, 1337)
+		assert.NoError(t, err)
+		return raw
+	}
+
+	var txHash ethtypes.HexBytes0xPrefix
+	rpcErr := rpc.CallRPC(ctx, &txHash, "eth_sendRawTransaction", signTx(0))
+	assert.Nil(t, rpcErr)
+	rpcErr = rpc.CallRPC(ctx, &txHash, "eth_sendRawTransaction", signTx(0))
+	assert.Regexp(t, "FF23193.*already known", rpcErr.Message)
+	s.Mine(1)
+	var nonceTooLowHash ethtypes.HexBytes0xPrefix
+	s.chain.nonces[addressKey(&keypair.Address)] = 2
+	rpcErr = rpc.CallRPC(ctx, &nonceTooLowHash, "eth_sendRawTransaction", signTx(1))
+	assert.Regexp(t, "FF23191.*nonce too low", rpcErr.Message)
+
+	var tx *transactionJSONRPC
+	rpcErr = rpc.CallRPC(ctx, &tx, "eth_getTransactionByHash", txHash)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, keypair.Address.String(), tx.From.String())
+	assert.Equal(t, int64(2000000000), tx.GasPrice.BigInt().Int64())
+	assert.Equal(t, int64(1), tx.BlockNumber.BigInt().Int64())
+
+	rpcErr = rpc.CallRPC(ctx, &txHash, "eth_sendRawTransaction", "0x00")
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+}
+
+func TestSimulatorTransactionBadRequests(t *testing.T) {
+	ctx, _, rpc, done := newTestSimulator(t, &Script{Seed: "test"})
+	defer done()
+
+	var res interface{}
+	for _, method := range []string{"eth_sendTransaction", "eth_sendRawTransaction", "eth_getTransactionByHash", "eth_getTransactionReceipt",
+		"eth_getTransactionCount", "eth_estimateGas", "eth_getLogs", "eth_newFilter"} {
+		rpcErr := rpc.CallRPC(ctx, &res, method)
+		assert.Regexp(t, "FF23058", rpcErr.Message, method)
+	}
+	rpcErr := rpc.CallRPC(ctx, &res, "eth_sendTransaction", map[string]interface{}{})
+	assert.Regexp(t, "FF23058.*from", rpcErr.Message)
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getLogs", map[string]interface{}{"address": 12345})
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getLogs", map[string]interface{}{"topics": []interface{}{12345}})
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_newFilter", map[string]interface{}{"address": 12345})
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getLogs", map[string]interface{}{"fromBlock": "wrong"})
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getLogs", map[string]interface{}{"toBlock": "wrong"})
+	assert.Regexp(t, "FF23058", rpcErr.Message)
+	rpcErr = rpc.CallRPC(ctx, &res, "eth_getTransactionReceipt", "0x1234")
+	assert.Nil(t, rpcErr)
+	assert.Nil(t, res)
+}
@@ -0,0 +1,490 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/firefly-evmconnect/internal/ethereum"
+	"github.com/hyperledger/firefly-evmconnect/internal/msgs"
+	"github.com/hyperledger/firefly-signer/pkg/ethtypes"
+	"github.com/hyperledger/firefly-signer/pkg/rlp"
+	"github.com/hyperledger/firefly-signer/pkg/rpcbackend"
+	"golang.org/x/crypto/sha3"
+)
+
+// InvokedEventSignature is the signature of the event logged by every transaction sent to an address.
+// The simulator does not execute contracts, so this gives event listeners something to deliver: the
+// sender is indexed, and the data of the event is the input data of the transaction.
+const InvokedEventSignature = "Invoked(address,bytes)"
+
+// InvokedEventABI is the ABI of the event logged by every transaction sent to an address
+const InvokedEventABI = `{"type":"event","name":"Invoked","inputs":[{"name":"from","type":"address","indexed":true},{"name":"input","type":"bytes"}]}`
+
+const (
+	simGasPrice       = 1000000000 // the gas price of transactions that do not set one, and the result of eth_gasPrice
+	simIntrinsicGas   = 21000
+	simGasPerDataByte = 16
+)
+
+var (
+	invokedEventTopic = ethtypes.HexBytes0xPrefix(keccak256([]byte(InvokedEventSignature)))
+	simBalance        = new(big.Int).Exp(big.NewInt(10), big.NewInt(24), nil) // every account holds 1M ether
+)
+
+// simTransaction is a transaction accepted by the simulator. Every transaction succeeds when it is mined.
+type simTransaction struct {
+	hash        ethtypes.HexBytes0xPrefix
+	from        *ethtypes.Address0xHex
+	to          *ethtypes.Address0xHex // nil for a contract deployment
+	nonce       int64
+	gas         *big.Int
+	gasPrice    *big.Int
+	value       *big.Int
+	input       ethtypes.HexBytes0xPrefix
+	blockNumber int64 // only valid once mined
+	index       int64
+}
+
+// sendTransactionJSONRPC is the transaction object of eth_sendTransaction, eth_call and eth_estimateGas
+type sendTransactionJSONRPC struct {
+	From         *ethtypes.Address0xHex    `json:"from"`
+	To           *ethtypes.Address0xHex    `json:"to"`
+	Nonce        *ethtypes.HexInteger      `json:"nonce"`
+	Gas          *ethtypes.HexInteger      `json:"gas"`
+	GasPrice     *ethtypes.HexInteger      `json:"gasPrice"`
+	MaxFeePerGas *ethtypes.HexInteger      `json:"maxFeePerGas"`
+	Value        *ethtypes.HexInteger      `json:"value"`
+	Data         ethtypes.HexBytes0xPrefix `json:"data"`
+	Input        ethtypes.HexBytes0xPrefix `json:"input"`
+}
+
+type transactionJSONRPC struct {
+	BlockHash        ethtypes.HexBytes0xPrefix `json:"blockHash"`   // null if pending
+	BlockNumber      *ethtypes.HexInteger      `json:"blockNumber"` // null if pending
+	From             *ethtypes.Address0xHex    `json:"from"`
+	Gas              *ethtypes.HexInteger      `json:"gas"`
+	GasPrice         *ethtypes.HexInteger      `json:"gasPrice"`
+	Hash             ethtypes.HexBytes0xPrefix `json:"hash"`
+	Input            ethtypes.HexBytes0xPrefix `json:"input"`
+	Nonce            *ethtypes.HexInteger      `json:"nonce"`
+	To               *ethtypes.Address0xHex    `json:"to"`
+	TransactionIndex *ethtypes.HexInteger      `json:"transactionIndex"` // null if pending
+	Value            *ethtypes.HexInteger      `json:"value"`
+}
+
+type receiptJSONRPC struct {
+	BlockHash         ethtypes.HexBytes0xPrefix `json:"blockHash"`
+	BlockNumber       *ethtypes.HexInteger      `json:"blockNumber"`
+	ContractAddress   *ethtypes.Address0xHex    `json:"contractAddress"`
+	CumulativeGasUsed *ethtypes.HexInteger      `json:"cumulativeGasUsed"`
+	EffectiveGasPrice *ethtypes.HexInteger      `json:"effectiveGasPrice"`
+	From              *ethtypes.Address0xHex    `json:"from"`
+	GasUsed           *ethtypes.HexInteger      `json:"gasUsed"`
+	Logs              []*logJSONRPC             `json:"logs"`
+	Status            *ethtypes.HexInteger      `json:"status"`
+	To                *ethtypes.Address0xHex    `json:"to"`
+	TransactionHash   ethtypes.HexBytes0xPrefix `json:"transactionHash"`
+	TransactionIndex  *ethtypes.HexInteger      `json:"transactionIndex"`
+}
+
+type logJSONRPC struct {
+	Removed          bool                        `json:"removed"`
+	LogIndex         *ethtypes.HexInteger        `json:"logIndex"`
+	TransactionIndex *ethtypes.HexInteger        `json:"transactionIndex"`
+	BlockNumber      *ethtypes.HexInteger        `json:"blockNumber"`
+	TransactionHash  ethtypes.HexBytes0xPrefix   `json:"transactionHash"`
+	BlockHash        ethtypes.HexBytes0xPrefix   `json:"blockHash"`
+	Address          *ethtypes.Address0xHex      `json:"address"`
+	Data             ethtypes.HexBytes0xPrefix   `json:"data"`
+	Topics           []ethtypes.HexBytes0xPrefix `json:"topics"`
+}
+
+// logFilterJSONRPC is the filter object of eth_newFilter and eth_getLogs
+type logFilterJSONRPC struct {
+	FromBlock string                    `json:"fromBlock"`
+	ToBlock   string                    `json:"toBlock"`
+	BlockHash ethtypes.HexBytes0xPrefix `json:"blockHash"`
+	Address   json.RawMessage           `json:"address"` // a single address, or an array of addresses
+	Topics    []json.RawMessage         `json:"topics"`  // each entry is null, a single topic, or an array of topics
+}
+
+// logCriteria is the parsed form of a log filter. Block references are resolved when the filter is queried.
+type logCriteria struct {
+	fromBlock string
+	toBlock   string
+	blockHash string
+	addresses map[string]bool
+	topics    [][]string // nil matches any topic in that position
+}
+
+func keccak256(b []byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(b)
+	return hash.Sum(nil)
+}
+
+func addressKey(a *ethtypes.Address0xHex) string {
+	return strings.ToLower(a.String())
+}
+
+func hexIntOrDefault(i *ethtypes.HexInteger, def int64) *big.Int {
+	if i == nil {
+		return big.NewInt(def)
+	}
+	return i.BigInt()
+}
+
+func (tx *simTransaction) gasUsed() int64 {
+	return simIntrinsicGas + simGasPerDataByte*int64(len(tx.input))
+}
+
+// contractAddress is the address of a deployed contract, derived from the sender and nonce as on a real chain
+func (tx *simTransaction) contractAddress() *ethtypes.Address0xHex {
+	if tx.to != nil {
+		return nil
+	}
+	var addr ethtypes.Address0xHex
+	copy(addr[:], keccak256(rlp.List{rlp.WrapAddress(tx.from), rlp.WrapInt(big.NewInt(tx.nonce))}.Encode())[12:])
+	return &addr
+}
+
+// logs returns the Invoked event logged by the transaction, if it was sent to an address
+func (tx *simTransaction) logs(b *simBlock, removed bool) []*logJSONRPC {
+	if tx.to == nil {
+		return []*logJSONRPC{}
+	}
+	fromTopic := make(ethtypes.HexBytes0xPrefix, 32)
+	copy(fromTopic[12:], tx.from[:])
+	// ABI encoding of a single bytes parameter: offset, length, then the data padded to a 32 byte boundary
+	data := make(ethtypes.HexBytes0xPrefix, 64+((len(tx.input)+31)/32)*32)
+	data[31] = 0x20
+	new(big.Int).SetInt64(int64(len(tx.input))).FillBytes(data[32:64])
+	copy(data[64:], tx.input)
+	return []*logJSONRPC{{
+		Removed:          removed,
+		LogIndex:         ethtypes.NewHexInteger64(tx.index), // one log per transaction
+		TransactionIndex: ethtypes.NewHexInteger64(tx.index),
+		BlockNumber:      ethtypes.NewHexInteger64(b.number),
+		TransactionHash:  tx.hash,
+		BlockHash:        b.hash,
+		Address:          tx.to,
+		Data:             data,
+		Topics:           []ethtypes.HexBytes0xPrefix{invokedEventTopic, fromTopic},
+	}}
+}
+
+func (tx *simTransaction) toJSONRPC(b *simBlock) *transactionJSONRPC {
+	txJSON := &transactionJSONRPC{
+		From:     tx.from,
+		Gas:      (*ethtypes.HexInteger)(tx.gas),
+		GasPrice: (*ethtypes.HexInteger)(tx.gasPrice),
+		Hash:     tx.hash,
+		Input:    tx.input,
+		Nonce:    ethtypes.NewHexInteger64(tx.nonce),
+		To:       tx.to,
+		Value:    (*ethtypes.HexInteger)(tx.value),
+	}
+	if b != nil {
+		txJSON.BlockHash = b.hash
+		txJSON.BlockNumber = ethtypes.NewHexInteger64(b.number)
+		txJSON.TransactionIndex = ethtypes.NewHexInteger64(tx.index)
+	}
+	return txJSON
+}
+
+func (tx *simTransaction) toReceiptJSONRPC(b *simBlock) *receiptJSONRPC {
+	cumulativeGasUsed := int64(0)
+	for _, btx := range b.transactions[0 : tx.index+1] {
+		cumulativeGasUsed += btx.gasUsed()
+	}
+	return &receiptJSONRPC{
+		BlockHash:         b.hash,
+		BlockNumber:       ethtypes.NewHexInteger64(b.number),
+		ContractAddress:   tx.contractAddress(),
+		CumulativeGasUsed: ethtypes.NewHexInteger64(cumulativeGasUsed),
+		EffectiveGasPrice: (*ethtypes.HexInteger)(tx.gasPrice),
+		From:              tx.from,
+		GasUsed:           ethtypes.NewHexInteger64(tx.gasUsed()),
+		Logs:              tx.logs(b, false),
+		Status:            ethtypes.NewHexInteger64(1),
+		To:                tx.to,
+		TransactionHash:   tx.hash,
+		TransactionIndex:  ethtypes.NewHexInteger64(tx.index),
+	}
+}
+
+// minedBlock returns the block containing the transaction, or nil if it is still pending
+func (c *chain) minedBlock(tx *simTransaction) *simBlock {
+	for _, p := range c.pending {
+		if p == tx {
+			return nil
+		}
+	}
+	return c.getBlockByNumber(tx.blockNumber)
+}
+
+// accept adds a transaction to the pending transactions, if its nonce is the next nonce of the sender
+func (c *chain) accept(ctx context.Context, tx *simTransaction) *rpcbackend.RPCError {
+	if c.txByHash[tx.hash.String()] != nil {
+		return rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInternalError, msgs.MsgSimulatorKnownTransaction, tx.hash)
+	}
+	nextNonce := c.nonces[addressKey(tx.from)]
+	switch {
+	case tx.nonce < nextNonce:
+		return rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInternalError, msgs.MsgSimulatorNonceTooLow, tx.nonce, tx.from, nextNonce)
+	case tx.nonce > nextNonce:
+		return rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInternalError, msgs.MsgSimulatorNonceTooHigh, tx.nonce, tx.from, nextNonce)
+	}
+	c.nonces[addressKey(tx.from)] = nextNonce + 1
+	c.pending = append(c.pending, tx)
+	c.txByHash[tx.hash.String()] = tx
+	return nil
+}
+
+// sendTransaction accepts a transaction for signing by the node, with a hash derived from the seed, sender and nonce
+func (s *Simulator) sendTransaction(ctx context.Context, req *rpcbackend.RPCRequest) (interface{}, *rpcbackend.RPCError) {
+	var txJSON sendTransactionJSONRPC
+	if err := parseParams(ctx, req, &txJSON); err != nil {
+		return nil, err
+	}
+	if txJSON.From == nil {
+		return nil, rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInvalidRequest, msgs.MsgSimulatorInvalidParams, req.Method, "missing from")
+	}
+	nonce := s.chain.nonces[addressKey(txJSON.From)]
+	if txJSON.Nonce != nil {
+		nonce = txJSON.Nonce.BigInt().Int64()
+	}
+	gasPrice := txJSON.GasPrice
+	if gasPrice == nil {
+		gasPrice = txJSON.MaxFeePerGas
+	}
+	tx := &simTransaction{
+		hash:     keccak256([]byte(fmt.Sprintf("%s/tx/%s/%d", s.chain.seed, addressKey(txJSON.From), nonce))),
+		from:     txJSON.From,
+		to:       txJSON.To,
+		nonce:    nonce,
+		gasPrice: hexIntOrDefault(gasPrice, simGasPrice),
+		value:    hexIntOrDefault(txJSON.Value, 0),
+		input:    txJSON.inputData(),
+	}
+	tx.gas = hexIntOrDefault(txJSON.Gas, tx.gasUsed())
+	if err := s.chain.accept(ctx, tx); err != nil {
+		return nil, err
+	}
+	return tx.hash, nil
+}
+
+// sendRawTransaction accepts a signed transaction, recovering the sender from the signature
+func (s *Simulator) sendRawTransaction(ctx context.Context, req *rpcbackend.RPCRequest) (interface{}, *rpcbackend.RPCError) {
+	var raw ethtypes.HexBytes0xPrefix
+	if err := parseParams(ctx, req, &raw); err != nil {
+		return nil, err
+	}
+	decoded, err := ethereum.DecodeRawTransaction(ctx, raw)
+	if err != nil {
+		return nil, rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInvalidRequest, msgs.MsgSimulatorInvalidParams, req.Method, err)
+	}
+	gasPrice := decoded.GasPrice
+	if gasPrice == nil {
+		gasPrice = decoded.MaxFeePerGas
+	}
+	tx := &simTransaction{
+		hash:     decoded.Hash,
+		from:     decoded.From,
+		to:       decoded.To,
+		nonce:    decoded.Nonce.Int64(),
+		gas:      decoded.Gas.Int(),
+		gasPrice: gasPrice.Int(),
+		value:    decoded.Value.Int(),
+		input:    decoded.Data,
+	}
+	if err := s.chain.accept(ctx, tx); err != nil {
+		return nil, err
+	}
+	return tx.hash, nil
+}
+
+func (txJSON *sendTransactionJSONRPC) inputData() ethtypes.HexBytes0xPrefix {
+	if len(txJSON.Input) > 0 {
+		return txJSON.Input
+	}
+	if txJSON.Data == nil {
+		return ethtypes.HexBytes0xPrefix{}
+	}
+	return txJSON.Data
+}
+
+func (s *Simulator) estimateGas(ctx context.Context, req *rpcbackend.RPCRequest) (interface{}, *rpcbackend.RPCError) {
+	var txJSON sendTransactionJSONRPC
+	if err := parseParams(ctx, req, &txJSON); err != nil {
+		return nil, err
+	}
+	tx := &simTransaction{input: txJSON.inputData()}
+	return ethtypes.NewHexInteger64(tx.gasUsed()), nil
+}
+
+func (s *Simulator) getTransactionCount(ctx context.Context, req *rpcbackend.RPCRequest) (interface{}, *rpcbackend.RPCError) {
+	var address ethtypes.Address0xHex
+	var blockRef string
+	if err := parseParams(ctx, req, &address, &blockRef); err != nil {
+		return nil, err
+	}
+	nonce := s.chain.nonces[addressKey(&address)]
+	if blockRef != "pending" {
+		for _, tx := range s.chain.pending {
+			if addressKey(tx.from) == addressKey(&address) {
+				nonce--
+			}
+		}
+	}
+	return ethtypes.NewHexInteger64(nonce), nil
+}
+
+func (s *Simulator) getTransaction(ctx context.Context, req *rpcbackend.RPCRequest, receipt bool) (interface{}, *rpcbackend.RPCError) {
+	var hash ethtypes.HexBytes0xPrefix
+	if err := parseParams(ctx, req, &hash); err != nil {
+		return nil, err
+	}
+	tx := s.chain.txByHash[hash.String()]
+	if tx == nil {
+		return nil, nil
+	}
+	b := s.chain.minedBlock(tx)
+	if !receipt {
+		return tx.toJSONRPC(b), nil
+	}
+	if b == nil {
+		return nil, nil
+	}
+	return tx.toReceiptJSONRPC(b), nil
+}
+
+func parseLogFilter(ctx context.Context, method string, filterJSON *logFilterJSONRPC) (*logCriteria, *rpcbackend.RPCError) {
+	criteria := &logCriteria{
+		fromBlock: filterJSON.FromBlock,
+		toBlock:   filterJSON.ToBlock,
+		addresses: make(map[string]bool),
+	}
+	if filterJSON.BlockHash != nil {
+		criteria.blockHash = filterJSON.BlockHash.String()
+	}
+	if len(filterJSON.Address) > 0 && string(filterJSON.Address) != "null" {
+		var addresses []*ethtypes.Address0xHex
+		if err := json.Unmarshal(filterJSON.Address, &addresses); err != nil {
+			var address ethtypes.Address0xHex
+			if err := json.Unmarshal(filterJSON.Address, &address); err != nil {
+				return nil, rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInvalidRequest, msgs.MsgSimulatorInvalidParams, method, err)
+			}
+			addresses = append(addresses, &address)
+		}
+		for _, a := range addresses {
+			criteria.addresses[addressKey(a)] = true
+		}
+	}
+	for _, t := range filterJSON.Topics {
+		var options []ethtypes.HexBytes0xPrefix
+		if string(t) != "null" {
+			if err := json.Unmarshal(t, &options); err != nil {
+				var topic ethtypes.HexBytes0xPrefix
+				if err := json.Unmarshal(t, &topic); err != nil {
+					return nil, rpcbackend.NewRPCError(ctx, rpcbackend.RPCCodeInvalidRequest, msgs.MsgSimulatorInvalidParams, method, err)
+				}
+				options = append(options, topic)
+			}
+		}
+		var topics []string
+		for _, o := range options {
+			topics = append(topics, o.String())
+		}
+		criteria.topics = append(criteria.topics, topics)
+	}
+	return criteria, nil
+}
+
+func (lc *logCriteria) matches(l *logJSONRPC) bool {
+	if len(lc.addresses) > 0 && !lc.addresses[addressKey(l.Address)] {
+		return false
+	}
+	for i, options := range lc.topics {
+		if options == nil {
+			continue
+		}
+		if i >= len(l.Topics) {
+			return false
+		}
+		matched := false
+		for _, o := range options {
+			matched = matched || o == l.Topics[i].String()
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// blockLogs returns the logs in the blocks that match the criteria
+func (lc *logCriteria) blockLogs(blocks []*simBlock, removed bool) []*logJSONRPC {
+	logs := []*logJSONRPC{}
+	for _, b := range blocks {
+		for _, tx := range b.transactions {
+			for _, l := range tx.logs(b, removed) {
+				if lc.matches(l) {
+					logs = append(logs, l)
+				}
+			}
+		}
+	}
+	return logs
+}
+
+// getLogs returns the logs matching the criteria on the current canonical chain
+func (s *Simulator) getLogs(ctx context.Context, method string, lc *logCriteria) (interface{}, *rpcbackend.RPCError) {
+	if lc.blockHash != "" {
+		b := s.chain.getBlockByHash(lc.blockHash)
+		if b == nil {
+			return []*logJSONRPC{}, nil
+		}
+		return lc.blockLogs([]*simBlock{b}, false), nil
+	}
+	resolve := func(blockRef string) (int64, *rpcbackend.RPCError) {
+		if blockRef == "" {
+			blockRef = "latest"
+		}
+		return s.resolveBlockRef(ctx, method, blockRef)
+	}
+	fromBlock, err := resolve(lc.fromBlock)
+	if err != nil {
+		return nil, err
+	}
+	toBlock, err := resolve(lc.toBlock)
+	if err != nil {
+		return nil, err
+	}
+	var blocks []*simBlock
+	for number := fromBlock; number <= toBlock; number++ {
+		if b := s.chain.getBlockByNumber(number); b != nil {
+			blocks = append(blocks, b)
+		}
+	}
+	return lc.blockLogs(blocks, false), nil
+}
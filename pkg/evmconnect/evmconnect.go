@@ -0,0 +1,190 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package evmconnect allows other Go services to embed the connector in-process, calling the ffcapi.API
+// directly rather than running evmconnect as a separate microservice. The connector is configured with the
+// same keys as the connector section of the evmconnect configuration file, documented in config.md.
+package evmconnect
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/hyperledger/firefly-evmconnect/internal/ethereum"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// DefaultConfigSection is the root configuration section used by NewConnector if WithConfigSection is not
+// provided, which is the same as for the standalone evmconnect
+const DefaultConfigSection = "connector"
+
+// GracefulShutdown is implemented by the connector returned by NewConnector. Call Shutdown before cancelling
+// the context passed to NewConnector, to drain in-flight submissions and event batches.
+type GracefulShutdown = ethereum.GracefulShutdown
+
+// ConfigReloader is implemented by the connector returned by NewConnector, to apply changes to the settings
+// that can be changed without a restart
+type ConfigReloader = ethereum.ConfigReloader
+
+// Extensions is implemented by the connector returned by NewConnector, with the operations that are not
+// part of the ffcapi.API
+type Extensions = ethereum.Extensions
+
+// Request and response types of the Extensions operations
+type (
+	TransactionReplaceRequest        = ethereum.TransactionReplaceRequest
+	TransactionReplaceResponse       = ethereum.TransactionReplaceResponse
+	NewBlockInfoListenerRequest      = ethereum.NewBlockInfoListenerRequest
+	NewBlockInfoListenerResponse     = ethereum.NewBlockInfoListenerResponse
+	NewBlockInfoEvent                = ethereum.NewBlockInfoEvent
+	NewBlockInfo                     = ethereum.NewBlockInfo
+	TransactionSendRawRequest        = ethereum.TransactionSendRawRequest
+	TransactionSendRawResponse       = ethereum.TransactionSendRawResponse
+	RawTransaction                   = ethereum.RawTransaction
+	FeeHistoryRequest                = ethereum.FeeHistoryRequest
+	FeeHistoryResponse               = ethereum.FeeHistoryResponse
+	EventListenerAddressesRequest    = ethereum.EventListenerAddressesRequest
+	EventListenerAddressesResponse   = ethereum.EventListenerAddressesResponse
+	ReceiptListenerRequest           = ethereum.ReceiptListenerRequest
+	ReceiptListenerResponse          = ethereum.ReceiptListenerResponse
+	ReceiptNotification              = ethereum.ReceiptNotification
+	ReceiptWatchRequest              = ethereum.ReceiptWatchRequest
+	ReceiptWatchResponse             = ethereum.ReceiptWatchResponse
+	CanonicalChainRequest            = ethereum.CanonicalChainRequest
+	CanonicalChainResponse           = ethereum.CanonicalChainResponse
+	CanonicalChainBlock              = ethereum.CanonicalChainBlock
+	ReorgHalt                        = ethereum.ReorgHalt
+	ProofRequest                     = ethereum.ProofRequest
+	ProofResponse                    = ethereum.ProofResponse
+	StorageProof                     = ethereum.StorageProof
+	TxPoolRequest                    = ethereum.TxPoolRequest
+	TxPoolResponse                   = ethereum.TxPoolResponse
+	TxPoolTransaction                = ethereum.TxPoolTransaction
+	TxPoolStatus                     = ethereum.TxPoolStatus
+	DeployContractsRequest           = ethereum.DeployContractsRequest
+	DeployContractsResponse          = ethereum.DeployContractsResponse
+	ContractToDeploy                 = ethereum.ContractToDeploy
+	DeployedContract                 = ethereum.DeployedContract
+	AcknowledgeReorgRequest          = ethereum.AcknowledgeReorgRequest
+	AcknowledgeReorgResponse         = ethereum.AcknowledgeReorgResponse
+	TransactionByNonceRequest        = ethereum.TransactionByNonceRequest
+	TransactionByNonceResponse       = ethereum.TransactionByNonceResponse
+	TransactionSearchSource          = ethereum.TransactionSearchSource
+	StorageWatchRequest              = ethereum.StorageWatchRequest
+	StorageWatchResponse             = ethereum.StorageWatchResponse
+	StorageWatchMapping              = ethereum.StorageWatchMapping
+	StorageChangeNotification        = ethereum.StorageChangeNotification
+	AddressActivityListenerRequest   = ethereum.AddressActivityListenerRequest
+	AddressActivityListenerResponse  = ethereum.AddressActivityListenerResponse
+	AddressActivityEvent             = ethereum.AddressActivityEvent
+	NodeDiagnosticsRequest           = ethereum.NodeDiagnosticsRequest
+	NodeDiagnosticsResponse          = ethereum.NodeDiagnosticsResponse
+	EventStreamPauseRequest          = ethereum.EventStreamPauseRequest
+	EventStreamPauseResponse         = ethereum.EventStreamPauseResponse
+	ListenerPauseStatus              = ethereum.ListenerPauseStatus
+	ListenerPauseState               = ethereum.ListenerPauseState
+	EventStreamResumeRequest         = ethereum.EventStreamResumeRequest
+	EncodeCallDataRequest            = ethereum.EncodeCallDataRequest
+	EncodeCallDataResponse           = ethereum.EncodeCallDataResponse
+	DecodedCallData                  = ethereum.DecodedCallData
+	DecodeCallDataRequest            = ethereum.DecodeCallDataRequest
+	PrivateTransactionStatusRequest  = ethereum.PrivateTransactionStatusRequest
+	PrivateTransactionStatusResponse = ethereum.PrivateTransactionStatusResponse
+	PrivateTransactionState          = ethereum.PrivateTransactionState
+	DependentTransactionSendRequest  = ethereum.DependentTransactionSendRequest
+	ReorgStatistics                  = ethereum.ReorgStatistics
+	ReorgStatisticsRequest           = ethereum.ReorgStatisticsRequest
+	ReorgStatisticsResponse          = ethereum.ReorgStatisticsResponse
+	EventFilterDryRunRequest         = ethereum.EventFilterDryRunRequest
+	EventFilterDryRunResponse        = ethereum.EventFilterDryRunResponse
+)
+
+// States of a listener reported by EventStreamPause and EventStreamResume
+const (
+	ListenerActive  = ethereum.ListenerActive
+	ListenerPausing = ethereum.ListenerPausing
+	ListenerPaused  = ethereum.ListenerPaused
+)
+
+// States of a transaction submitted to a private relay, reported by PrivateTransactionStatus
+const (
+	PrivateTransactionPending  = ethereum.PrivateTransactionPending
+	PrivateTransactionIncluded = ethereum.PrivateTransactionIncluded
+	PrivateTransactionExpired  = ethereum.PrivateTransactionExpired
+)
+
+// Option customizes the configuration of the connector
+type Option func(o *options)
+
+type options struct {
+	conf   config.Section
+	values []configValue
+}
+
+type configValue struct {
+	key   string
+	value interface{}
+}
+
+// InitConfig registers the configuration keys of the connector, with their defaults, in a section of the
+// configuration of the embedding service. Pass the section to WithConfigSection.
+func InitConfig(conf config.Section) {
+	ethereum.InitConfig(conf)
+}
+
+// WithConfigSection uses a configuration section the embedding service has already initialized with InitConfig,
+// and typically read from its own configuration file
+func WithConfigSection(conf config.Section) Option {
+	return func(o *options) {
+		o.conf = conf
+	}
+}
+
+// WithURL sets the JSON/RPC endpoint of the blockchain node
+func WithURL(url string) Option {
+	return WithConfig(ffresty.HTTPConfigURL, url)
+}
+
+// WithChainProfile applies the defaults of a chain profile, such as "polygon" or "bsc"
+func WithChainProfile(profile string) Option {
+	return WithConfig(ethereum.ChainProfile, profile)
+}
+
+// WithConfig sets any key of the connector configuration, such as "events.catchupPageSize". The options are
+// applied in order, after the configuration file of the embedding service has been read.
+func WithConfig(key string, value interface{}) Option {
+	return func(o *options) {
+		o.values = append(o.values, configValue{key: key, value: value})
+	}
+}
+
+// NewConnector returns a connector that implements the ffcapi.API. Its background processing, such as the
+// block listener, stops when ctx is cancelled.
+func NewConnector(ctx context.Context, opts ...Option) (ffcapi.API, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.conf == nil {
+		o.conf = config.RootSection(DefaultConfigSection)
+		ethereum.InitConfig(o.conf)
+	}
+	for _, v := range o.values {
+		o.conf.Set(v.key, v.value)
+	}
+	return ethereum.NewEthereumConnector(ctx, o.conf)
+}
@@ -0,0 +1,65 @@
+// Copyright © 2024 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evmconnect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/ffresty"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConnectorDefaultSection(t *testing.T) {
+	config.RootConfigReset()
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	_, err := NewConnector(ctx)
+	assert.Regexp(t, "FF23025", err)
+
+	c, err := NewConnector(ctx,
+		WithURL("http://localhost:8545"),
+		WithChainProfile("polygon"),
+		WithConfig("blockPollingInterval", "1h"),
+	)
+	assert.NoError(t, err)
+	_, ok := c.(GracefulShutdown)
+	assert.True(t, ok)
+	_, ok = c.(ConfigReloader)
+	assert.True(t, ok)
+	_, ok = c.(Extensions)
+	assert.True(t, ok)
+	assert.Equal(t, "polygon", config.RootSection(DefaultConfigSection).GetString("chainProfile"))
+}
+
+func TestNewConnectorConfigSection(t *testing.T) {
+	config.RootConfigReset()
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+
+	conf := config.RootSection("myservice").SubSection("evm")
+	InitConfig(conf)
+	conf.Set(ffresty.HTTPConfigURL, "http://localhost:8545")
+
+	_, err := NewConnector(ctx, WithConfigSection(conf), WithConfig("blockCacheSize", 0))
+	assert.Regexp(t, "FF23185.*blockCacheSize", err)
+
+	_, err = NewConnector(ctx, WithConfigSection(conf), WithConfig("blockCacheSize", 10))
+	assert.NoError(t, err)
+}